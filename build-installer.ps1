@@ -10,20 +10,32 @@ $ErrorActionPreference = "Stop"
 $ProjectRoot = $PSScriptRoot
 $EmbedDir = Join-Path $ProjectRoot "cmd\installer\embed"
 $ServiceExe = Join-Path $ProjectRoot "bgStatusService.exe"
+$ServiceExeArm64 = Join-Path $ProjectRoot "bgStatusService_arm64.exe"
 $InstallerExe = Join-Path $ProjectRoot "bgStatusServiceSetup.exe"
 $EmbedExe = Join-Path $EmbedDir "bgStatusService.exe"
+$EmbedExeArm64 = Join-Path $EmbedDir "bgStatusService_arm64.exe"
 $EmbedGo = Join-Path $EmbedDir "embed.go"
 
 Write-Host "=== BgStatusService Installer Build ===" -ForegroundColor Cyan
 Write-Host ""
 
-# Step 1: Build the service executable
-Write-Host "[1/4] Building bgStatusService.exe..." -ForegroundColor Yellow
+# Step 1: Build the service executable for x64 and ARM64
+# The installer embeds both and installs the one matching the machine
+Write-Host "[1/4] Building bgStatusService.exe (x64 and ARM64)..." -ForegroundColor Yellow
+$env:GOOS = "windows"
+$env:GOARCH = "amd64"
 go build -o $ServiceExe ./cmd/statusservice
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: Failed to build bgStatusService.exe" -ForegroundColor Red
     exit 1
 }
+$env:GOARCH = "arm64"
+go build -o $ServiceExeArm64 ./cmd/statusservice
+if ($LASTEXITCODE -ne 0) {
+    Write-Host "ERROR: Failed to build bgStatusService_arm64.exe" -ForegroundColor Red
+    exit 1
+}
+$env:GOARCH = "amd64"
 Write-Host "      Built successfully" -ForegroundColor Green
 
 # Step 2: Copy service exe to embed directory
@@ -32,6 +44,7 @@ if (-not (Test-Path $EmbedDir)) {
     New-Item -ItemType Directory -Path $EmbedDir -Force | Out-Null
 }
 Copy-Item $ServiceExe $EmbedExe -Force
+Copy-Item $ServiceExeArm64 $EmbedExeArm64 -Force
 Write-Host "      Copied successfully" -ForegroundColor Green
 
 # Step 3: Update version in embed.go
@@ -41,7 +54,7 @@ $embedContent = $embedContent -replace 'var Version = "[^"]*"', "var Version = `
 Set-Content $EmbedGo -Value $embedContent -NoNewline
 Write-Host "      Version updated" -ForegroundColor Green
 
-# Step 4: Build the installer
+# Step 4: Build the installer (x64, which also runs on ARM64 under emulation)
 Write-Host "[4/4] Building bgStatusServiceSetup.exe..." -ForegroundColor Yellow
 go build -o $InstallerExe ./cmd/installer
 if ($LASTEXITCODE -ne 0) {
@@ -55,7 +68,9 @@ Write-Host ""
 Write-Host "=== Build Complete ===" -ForegroundColor Cyan
 $installerSize = [math]::Round((Get-Item $InstallerExe).Length / 1MB, 2)
 $serviceSize = [math]::Round((Get-Item $ServiceExe).Length / 1MB, 2)
+$serviceArm64Size = [math]::Round((Get-Item $ServiceExeArm64).Length / 1MB, 2)
 Write-Host "  Service:   $serviceSize MB ($ServiceExe)"
+Write-Host "  ARM64:     $serviceArm64Size MB ($ServiceExeArm64)"
 Write-Host "  Installer: $installerSize MB ($InstallerExe)"
 Write-Host "  Version:   $Version"
 Write-Host ""
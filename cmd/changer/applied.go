@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// AppliedHistoryFileName is the file in the data directory that records the
+// images bgchanger applied, newest last.
+const AppliedHistoryFileName = "applied_history.json"
+
+// maxAppliedHistory is the number of applied images kept in the history.
+const maxAppliedHistory = 50
+
+// appliedImage is one image bgchanger applied and the surfaces it was set on.
+type appliedImage struct {
+	Path      string    `json:"path"`
+	SHA256    string    `json:"sha256"`
+	AppliedAt time.Time `json:"applied_at"`
+
+	Desktop     bool `json:"desktop"`
+	LockScreen  bool `json:"lock_screen"`
+	LoginScreen bool `json:"login_screen"`
+}
+
+// appliedHistoryPath returns the full path to the applied image history.
+func appliedHistoryPath() string {
+	return filepath.Join(config.Dir(), AppliedHistoryFileName)
+}
+
+// loadAppliedHistory reads the applied image history; a missing file yields an empty history.
+func loadAppliedHistory() ([]appliedImage, error) {
+	data, err := os.ReadFile(appliedHistoryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read applied history: %w", err)
+	}
+
+	var history []appliedImage
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse applied history: %w", err)
+	}
+	return history, nil
+}
+
+// recordApplied adds an applied image to the history, so "bgchanger verify" can
+// later check that it is still in place.
+func recordApplied(imagePath string, desktop, lockScreen, loginScreen bool) error {
+	if !desktop && !lockScreen && !loginScreen {
+		return nil
+	}
+
+	absPath, err := filepath.Abs(imagePath)
+	if err != nil {
+		return err
+	}
+	hash, err := hashFile(absPath)
+	if err != nil {
+		return err
+	}
+
+	// A broken history is replaced rather than blocking the record
+	history, _ := loadAppliedHistory()
+	history = append(history, appliedImage{
+		Path:        absPath,
+		SHA256:      hash,
+		AppliedAt:   time.Now(),
+		Desktop:     desktop,
+		LockScreen:  lockScreen,
+		LoginScreen: loginScreen,
+	})
+	if len(history) > maxAppliedHistory {
+		history = history[len(history)-maxAppliedHistory:]
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode applied history: %w", err)
+	}
+	if err := os.MkdirAll(config.Dir(), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(appliedHistoryPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write applied history: %w", err)
+	}
+	return nil
+}
+
+// hashFile returns the hex SHA-256 digest of a file.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
+	"github.com/backgroundchanger/internal/winapi/winapitest"
+)
+
+const (
+	desktopKey = `Control Panel\Desktop`
+	cspKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`
+	policyKey  = `SOFTWARE\Policies\Microsoft\Windows\Personalization`
+	systemKey  = `SOFTWARE\Policies\Microsoft\Windows\System`
+)
+
+// useFakes runs the test on the fake elevated Enterprise machine, with the
+// methods capability detection picks for it.
+func useFakes(t *testing.T) *winapitest.Fakes {
+	t.Helper()
+
+	fakes := winapitest.Use(t)
+	oldCaps := caps
+	caps = capability.Detect()
+	t.Cleanup(func() { caps = oldCaps })
+	return fakes
+}
+
+func TestApplyUndo(t *testing.T) {
+	fakes := useFakes(t)
+	reg := fakes.Reg
+	const original = `C:\Windows\Web\Wallpaper\Windows\img0.jpg`
+	reg.Set(registry.CURRENT_USER, desktopKey, "Wallpaper", winapi.RegistryValue{Type: registry.SZ, String: original})
+
+	path := filepath.Join(t.TempDir(), "wallpaper.jpg")
+	if err := os.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setDesktopWallpaper(path); err != nil {
+		t.Fatalf("setDesktopWallpaper: %v", err)
+	}
+	if err := setLockScreenWallpaper(path); err != nil {
+		t.Fatalf("setLockScreenWallpaper: %v", err)
+	}
+	if err := setLoginScreenBackground(path); err != nil {
+		t.Fatalf("setLoginScreenBackground: %v", err)
+	}
+
+	// The desktop, then the lock screen through the Assets method
+	calls := fakes.SPI.Calls
+	if len(calls) != 2 || calls[0].Action != SPI_SETDESKWALLPAPER || calls[0].Value != path ||
+		calls[1].Action != SPI_SETLOCKSCREENWALLPAPER || calls[1].Value != path {
+		t.Errorf("SystemParametersInfo calls = %+v", calls)
+	}
+	if v, _ := reg.Value(registry.CURRENT_USER, cspKey, "LockScreenImagePath"); v.String != path {
+		t.Errorf("HKCU LockScreenImagePath = %q, want %q", v.String, path)
+	}
+	if v, _ := reg.Value(registry.LOCAL_MACHINE, cspKey, "LockScreenImagePath"); v.String != path {
+		t.Errorf("HKLM LockScreenImagePath = %q, want %q", v.String, path)
+	}
+	if v, _ := reg.Value(registry.LOCAL_MACHINE, policyKey, "LockScreenImage"); v.String != path {
+		t.Errorf("LockScreenImage = %q, want %q", v.String, path)
+	}
+	if v, ok := reg.Value(registry.LOCAL_MACHINE, systemKey, "DisableLogonBackgroundImage"); !ok || v.Integer != 0 {
+		t.Errorf("DisableLogonBackgroundImage = %+v, %v, want 0", v, ok)
+	}
+	if len(fakes.Commands.Calls) != 1 || fakes.Commands.Calls[0].Name != "powershell.exe" {
+		t.Errorf("commands = %v, want the WinRT PowerShell script", fakes.Commands.Calls)
+	}
+
+	// The fake SPI leaves the registry alone; the real call writes the path
+	reg.Set(registry.CURRENT_USER, desktopKey, "Wallpaper", winapi.RegistryValue{Type: registry.SZ, String: path})
+
+	if _, err := journal.Undo(); err != nil {
+		t.Fatalf("journal.Undo: %v", err)
+	}
+	if v, _ := reg.Value(registry.CURRENT_USER, desktopKey, "Wallpaper"); v.String != original {
+		t.Errorf("Wallpaper after undo = %q, want %q", v.String, original)
+	}
+	if _, ok := reg.Value(registry.CURRENT_USER, cspKey, "LockScreenImagePath"); ok {
+		t.Error("HKCU LockScreenImagePath left after undo")
+	}
+	for _, name := range []string{"LockScreenImagePath", "LockScreenImageUrl", "LockScreenImageStatus"} {
+		if _, ok := reg.Value(registry.LOCAL_MACHINE, cspKey, name); ok {
+			t.Errorf("HKLM %s left after undo", name)
+		}
+	}
+	if _, ok := reg.Value(registry.LOCAL_MACHINE, policyKey, "LockScreenImage"); ok {
+		t.Error("LockScreenImage left after undo")
+	}
+	if _, ok := reg.Value(registry.LOCAL_MACHINE, systemKey, "DisableLogonBackgroundImage"); ok {
+		t.Error("DisableLogonBackgroundImage left after undo")
+	}
+	if reg.KeyExists(registry.LOCAL_MACHINE, policyKey) {
+		t.Error("Personalization policy key created by the apply left after undo")
+	}
+}
+
+func TestApplyNoMethod(t *testing.T) {
+	useFakes(t)
+	for i := range caps.LoginScreen {
+		caps.LoginScreen[i].Supported = false
+	}
+
+	if err := setLoginScreenBackground(filepath.Join(t.TempDir(), "wallpaper.jpg")); err == nil {
+		t.Fatal("setLoginScreenBackground succeeded with no supported method")
+	}
+}
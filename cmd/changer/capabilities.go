@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/backgroundchanger/internal/capability"
+)
+
+// caps is the capability report for this run, detected once after elevation
+var caps *capability.Report
+
+// detectCapabilities probes the system and prints which methods were chosen
+func detectCapabilities() {
+	caps = capability.Detect()
+	fmt.Printf("Detected %s (%s), build %d\n", caps.OS.ProductName, caps.OS.EditionID, caps.OS.Build)
+	fmt.Printf("Login screen methods: %s\n", strings.Join(capability.Selected(caps.LoginScreen), ", "))
+	fmt.Printf("Lock screen methods: %s\n", strings.Join(capability.Selected(caps.LockScreen), ", "))
+}
+
+// shouldTryMethod reports whether a method is supported, printing why it is skipped otherwise
+func shouldTryMethod(name, method string) bool {
+	if caps == nil || caps.Supports(method) {
+		return true
+	}
+	fmt.Printf("Skipping method: %s (%s)\n", name, caps.Reason(method))
+	return false
+}
+
+// runCapabilities prints the capability report, as JSON with --json
+func runCapabilities(args []string) error {
+	report := capability.Detect()
+
+	for _, arg := range args {
+		if arg == "--json" {
+			data, err := report.JSON()
+			if err != nil {
+				return fmt.Errorf("failed to encode report: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+	}
+
+	for _, line := range report.FormatLines() {
+		fmt.Println(line)
+	}
+	if !report.Context.Admin {
+		fmt.Println("\nNote: not elevated - run as administrator to see the methods used when applying.")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cached copy of the slide.recipes catalog, kept so repeated runs can use conditional
+// requests and offline machines can still pick from the last known list
+const (
+	catalogCacheFile = "catalog.json"
+	catalogMetaFile  = "catalog_meta.json"
+)
+
+// maxCatalogSize bounds the catalog response; the real list is a few hundred
+// KB.
+const maxCatalogSize = 8 << 20
+
+// catalogMeta holds the HTTP validators for the cached catalog
+type catalogMeta struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// getDataDir returns the persistent directory used by bgchanger.
+// Using ProgramData ensures the files survive reboots and temp cleanup.
+func getDataDir() string {
+	return filepath.Join(os.Getenv("PROGRAMDATA"), "BgChanger")
+}
+
+// loadCachedCatalog reads the cached catalog body and its metadata.
+// Returns nil body if there is no usable cache.
+func loadCachedCatalog() ([]byte, catalogMeta) {
+	var meta catalogMeta
+
+	body, err := os.ReadFile(filepath.Join(getDataDir(), catalogCacheFile))
+	if err != nil {
+		return nil, meta
+	}
+
+	metaData, err := os.ReadFile(filepath.Join(getDataDir(), catalogMetaFile))
+	if err == nil {
+		json.Unmarshal(metaData, &meta)
+	}
+
+	return body, meta
+}
+
+// saveCachedCatalog writes the catalog body and validators to the data directory
+func saveCachedCatalog(body []byte, meta catalogMeta) error {
+	dir := getDataDir()
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, catalogCacheFile), body, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write catalog cache: %w", err)
+	}
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, catalogMetaFile), metaData, 0644)
+}
+
+// fetchCatalog returns the slide.recipes catalog JSON. It sends If-None-Match /
+// If-Modified-Since when a cached copy exists, and falls back to the cached copy
+// if the network request fails.
+func fetchCatalog(catalogURL string) ([]byte, error) {
+	err := safetyGate().CheckURL(catalogURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, meta := loadCachedCatalog()
+
+	req, err := http.NewRequest("GET", catalogURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if cached != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		if cached != nil {
+			fmt.Printf("Could not reach %s (%v), using cached list from %s\n",
+				catalogURL, err, meta.FetchedAt.Format("Jan 2, 2006 3:04 PM"))
+			return cached, nil
+		}
+		return nil, fmt.Errorf("failed to fetch wallpaper list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Not modified - the cached copy is current
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		fmt.Println("Wallpaper list unchanged, using cached copy")
+		return cached, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if cached != nil {
+			fmt.Printf("Wallpaper list returned HTTP %d, using cached copy\n", resp.StatusCode)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("failed to fetch wallpaper list: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCatalogSize+1))
+	if err == nil && len(body) > maxCatalogSize {
+		err = fmt.Errorf("wallpaper list is larger than %d MB", maxCatalogSize>>20)
+	}
+	if err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Only cache responses that parse, so a broken response never replaces a good cache
+	var probe []WallpaperEntry
+	if json.Unmarshal(body, &probe) == nil && len(probe) > 0 {
+		err = saveCachedCatalog(body, catalogMeta{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		})
+		if err != nil {
+			fmt.Printf("Note: could not cache wallpaper list: %v\n", err)
+		}
+	}
+
+	return body, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// randomSource is the chain entry for a random slide.recipes wallpaper.
+const randomSource = "random"
+
+// chainSources returns the configured source chain. A broken config file
+// yields none, which keeps the default slide.recipes rotation.
+func chainSources() []string {
+	cfg, _ := config.Load()
+	return cfg.Sources
+}
+
+// fetchFromChain walks the configured source chain in order and returns the
+// first source that yields a valid image. Sources that fail, or are downloads
+// deferred on a metered connection, are logged and skipped.
+func fetchFromChain(sources []string) (string, error) {
+	var failures []error
+	for _, source := range sources {
+		imagePath, err := fetchChainSource(source)
+		if err == nil {
+			err = loginscreen.CheckImageFile(imagePath, imageLimits())
+		}
+		if err == errFetchDeferred {
+			fmt.Printf("Source %s: deferred (metered connection or low battery), trying the next one\n", source)
+			failures = append(failures, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Source %s failed: %v\n", source, err)
+			failures = append(failures, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
+
+		fmt.Printf("Using source: %s\n", source)
+		return imagePath, nil
+	}
+	return "", fmt.Errorf("no source in the chain yielded an image: %w", errors.Join(failures...))
+}
+
+// fetchChainSource resolves one chain entry: "random", "color:#RRGGBB" or any
+// source accepted on the command line.
+func fetchChainSource(source string) (string, error) {
+	if strings.EqualFold(source, randomSource) {
+		if fetchDeferred() {
+			return "", errFetchDeferred
+		}
+		randomURL, err := fetchRandomWallpaperURL()
+		if err != nil {
+			return "", err
+		}
+		return downloadImage(randomURL)
+	}
+	if hex, ok := config.SourceColor(source); ok {
+		return solidColorImage(hex)
+	}
+	return fetchSource(source)
+}
+
+// solidColorImage writes a screen-sized image of one color to the data
+// directory, the last resort at the end of a chain.
+func solidColorImage(hex string) (string, error) {
+	c, err := overlay.ParseHexColor(hex)
+	if err != nil {
+		return "", err
+	}
+
+	res := sysinfo.GetDisplayResolution()
+	img := image.NewRGBA(image.Rect(0, 0, res.Width, res.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+
+	if err := os.MkdirAll(getDataDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	imagePath := filepath.Join(getDataDir(), "solid_color.png")
+	if err := loginscreen.SaveImage(img, imagePath); err != nil {
+		return "", fmt.Errorf("failed to save solid color image: %w", err)
+	}
+	return imagePath, nil
+}
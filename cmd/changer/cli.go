@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Commands that apply an image. Any other first argument that is not a flag is
+// taken as the source of "set", so "bgchanger C:\Pictures" keeps working.
+const (
+	commandSet     = "set"
+	commandRandom  = "random"
+	commandRestore = "restore"
+)
+
+// targets are the surfaces a command changes.
+type targets struct {
+	desktop     bool
+	lockScreen  bool
+	loginScreen bool
+}
+
+// applyOptions is the parsed command line of set, random and restore.
+type applyOptions struct {
+	command string
+	// source is the image, directory, URL, library or remote source of set.
+	source string
+	// previous makes restore go back to the image before the last one.
+	previous bool
+	// monitors are set's --monitor N SOURCE pairs, which set each monitor's
+	// desktop wallpaper instead of one image everywhere.
+	monitors []monitorAssignment
+	// fit is how the desktop wallpaper is placed, one of wallpaperFits; empty
+	// keeps the current setting.
+	fit string
+	targets
+}
+
+// parseApplyArgs parses the command line of the commands that apply an image:
+//
+//	bgchanger [set] <source> [--fit <fit>] [--desktop-only] [--lockscreen-only] [--login-only]
+//	bgchanger set --monitor <n> <source> [--monitor <n> <source>...] [--fit <fit>]
+//	bgchanger [random] [--fit <fit>] [--desktop-only] [--lockscreen-only] [--login-only]
+//	bgchanger restore [--previous] [--fit <fit>] [--desktop-only] [--lockscreen-only] [--login-only]
+//
+// Flags may come before or after the source. The -only flags can be combined;
+// without any, all three surfaces are changed.
+func parseApplyArgs(args []string) (*applyOptions, error) {
+	opts := &applyOptions{command: commandRandom}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case commandSet, commandRandom, commandRestore:
+			opts.command = args[0]
+			args = args[1:]
+		default:
+			opts.command = commandSet
+		}
+	}
+
+	fs := flag.NewFlagSet(opts.command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.desktop, "desktop-only", false, "")
+	fs.BoolVar(&opts.lockScreen, "lockscreen-only", false, "")
+	fs.BoolVar(&opts.loginScreen, "login-only", false, "")
+	fs.Func("fit", "", func(value string) error {
+		fit, err := parseFit(value)
+		opts.fit = fit
+		return err
+	})
+	if opts.command == commandRestore {
+		fs.BoolVar(&opts.previous, "previous", false, "")
+	}
+	pendingMonitor := 0
+	if opts.command == commandSet {
+		fs.Func("monitor", "", func(value string) error {
+			if pendingMonitor != 0 {
+				return fmt.Errorf("--monitor %d needs an image before the next --monitor", pendingMonitor)
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("monitor numbers start at 1 (see 'bgchanger monitors')")
+			}
+			for _, m := range opts.monitors {
+				if m.number == n {
+					return fmt.Errorf("monitor %d is given twice", n)
+				}
+			}
+			pendingMonitor = n
+			return nil
+		})
+	}
+
+	// The flag package stops at the first positional argument, so parse again
+	// after each one to allow flags after the source too
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%s: %v", opts.command, err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if pendingMonitor != 0 {
+			opts.monitors = append(opts.monitors, monitorAssignment{number: pendingMonitor, source: fs.Arg(0)})
+			pendingMonitor = 0
+		} else {
+			positional = append(positional, fs.Arg(0))
+		}
+		args = fs.Args()[1:]
+	}
+	if pendingMonitor != 0 {
+		return nil, fmt.Errorf("--monitor %d needs an image", pendingMonitor)
+	}
+
+	switch {
+	case len(opts.monitors) > 0:
+		if len(positional) > 0 {
+			return nil, fmt.Errorf("give each image its own --monitor, or set %q without --monitor", positional[0])
+		}
+		if opts.lockScreen || opts.loginScreen {
+			return nil, fmt.Errorf("--monitor only changes the desktop wallpaper")
+		}
+		opts.desktop = true
+	case opts.command == commandSet:
+		if len(positional) != 1 {
+			return nil, fmt.Errorf("set needs exactly one image, directory, URL or source")
+		}
+		opts.source = positional[0]
+	default:
+		if len(positional) > 0 {
+			return nil, fmt.Errorf("%s takes no arguments, got %q", opts.command, positional[0])
+		}
+	}
+
+	if !opts.desktop && !opts.lockScreen && !opts.loginScreen {
+		opts.targets = targets{desktop: true, lockScreen: true, loginScreen: true}
+	}
+	if opts.fit != "" && !opts.desktop {
+		return nil, fmt.Errorf("--fit only changes the desktop wallpaper")
+	}
+	return opts, nil
+}
+
+// restoreImagePath returns the image restore applies: the last image
+// bgchanger applied, or with previous the one before it. Images whose file has
+// since been replaced (e.g. by a newer download) are skipped.
+func restoreImagePath(previous bool) (string, error) {
+	history, err := loadAppliedHistory()
+	if err != nil {
+		return "", err
+	}
+	if previous && len(history) > 0 {
+		history = history[:len(history)-1]
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		hash, err := hashFile(entry.Path)
+		if err == nil && hash == entry.SHA256 {
+			return entry.Path, nil
+		}
+	}
+	if previous {
+		return "", fmt.Errorf("no earlier image is still available; run 'bgchanger status' to see the last one")
+	}
+	return "", fmt.Errorf("no image applied by bgchanger is still available")
+}
+
+// runStatus shows the active images and whether they are still the ones
+// bgchanger last applied. Unlike verify, drift is not an error.
+func runStatus(args []string) error {
+	asJSON := false
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+		}
+	}
+	if !asJSON {
+		if err := runCurrent(nil); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	err := runVerify(args)
+	if errors.Is(err, errDrift) {
+		if !asJSON {
+			fmt.Println("Run 'bgchanger restore' to apply the last image again.")
+		}
+		return nil
+	}
+	return err
+}
+
+// exitUsage prints a command line error with a pointer to the help.
+func exitUsage(err error) {
+	fmt.Printf("Error: %v\n", err)
+	fmt.Println("Run 'bgchanger help' for usage.")
+	os.Exit(1)
+}
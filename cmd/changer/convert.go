@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Register the decoders for formats Windows can't use as a wallpaper
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/backgroundchanger/internal/imageconv"
+	"github.com/backgroundchanger/internal/loginscreen"
+)
+
+// convertedExtensions are the accepted image formats the wallpaper and lock
+// screen APIs don't take, with the format each is converted to: GIFs (only
+// their first frame) to PNG, which keeps their flat colors exact, and WebP,
+// HEIC and AVIF photos to JPEG, or PNG when they are transparent.
+var convertedExtensions = map[string]string{
+	".gif":  ".png",
+	".webp": ".jpg",
+}
+
+func init() {
+	for ext := range imageconv.Extensions {
+		supportedExtensions[ext] = true
+		convertedExtensions[ext] = ".jpg"
+	}
+}
+
+// convertForWindows returns imagePath unchanged when Windows can use it, or
+// converts it into dir as baseName with the new extension and returns that.
+// The original file is left in place.
+func convertForWindows(imagePath, dir, baseName string) (string, error) {
+	ext, ok := convertedExtensions[strings.ToLower(filepath.Ext(imagePath))]
+	if !ok {
+		return imagePath, nil
+	}
+
+	// Decoding a GIF gives its first frame
+	img, err := loginscreen.LoadImage(imagePath, imageLimits())
+	if err != nil {
+		return "", err
+	}
+	if ext == ".jpg" && !opaque(img) {
+		ext = ".png"
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create persistent directory: %w", err)
+	}
+	convertedPath := filepath.Join(dir, baseName+ext)
+	err = loginscreen.SaveImage(img, convertedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s: %w", filepath.Base(imagePath), err)
+	}
+
+	fmt.Printf("Converted %s to %s\n", filepath.Base(imagePath), strings.ToUpper(ext[1:]))
+	return convertedPath, nil
+}
+
+// convertDownloaded is convertForWindows for a file the changer downloaded
+// itself: the converted image replaces it.
+func convertDownloaded(imagePath, dir, baseName string) (string, error) {
+	convertedPath, err := convertForWindows(imagePath, dir, baseName)
+	if convertedPath != imagePath {
+		os.Remove(imagePath)
+	}
+	return convertedPath, err
+}
+
+// opaque reports whether img has no transparent pixels.
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// transcodedWallpaperPath returns the copy of the desktop wallpaper Windows keeps
+// for the current user. It survives the original file being deleted or moved.
+func transcodedWallpaperPath() string {
+	return filepath.Join(os.Getenv("APPDATA"), "Microsoft", "Windows", "Themes", "TranscodedWallpaper")
+}
+
+// currentDesktopWallpaper returns the file the desktop wallpaper can be read from:
+// the path reported by SystemParametersInfo when it still exists, otherwise the
+// TranscodedWallpaper copy.
+func currentDesktopWallpaper() (string, error) {
+	path, err := winapi.SPI.GetString(SPI_GETDESKWALLPAPER)
+	if err == nil && path != "" {
+		if _, statErr := os.Stat(path); statErr == nil {
+			return path, nil
+		}
+	}
+
+	transcoded := transcodedWallpaperPath()
+	if _, statErr := os.Stat(transcoded); statErr == nil {
+		return transcoded, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query desktop wallpaper: %w", err)
+	}
+	if path != "" {
+		return "", fmt.Errorf("desktop wallpaper %s no longer exists", path)
+	}
+	return "", fmt.Errorf("no desktop wallpaper is set")
+}
+
+// runCurrent shows the active desktop wallpaper and lock screen image and, with
+// --export PATH, copies them out. The lock screen image is written next to PATH
+// with a "_lockscreen" suffix.
+func runCurrent(args []string) error {
+	exportPath := ""
+	for i, arg := range args {
+		if arg == "--export" && i+1 < len(args) {
+			exportPath = args[i+1]
+		}
+	}
+
+	desktop, desktopErr := currentDesktopWallpaper()
+	if desktopErr != nil {
+		fmt.Printf("Desktop wallpaper: unknown (%v)\n", desktopErr)
+	} else {
+		fmt.Printf("Desktop wallpaper: %s\n", desktop)
+	}
+
+	// The configured lock screen image; Spotlight and images set in Settings
+	// are only available through the WinRT stream used when exporting
+	lock, lockErr := lockScreenImagePath()
+	if lockErr != nil {
+		fmt.Println("Lock screen image: set by Windows (Spotlight or Settings)")
+	} else {
+		fmt.Printf("Lock screen image: %s\n", lock)
+	}
+
+	if exportPath == "" {
+		return nil
+	}
+
+	exportPath, err := filepath.Abs(exportPath)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(exportPath)
+	lockExportPath := strings.TrimSuffix(exportPath, ext) + "_lockscreen" + ext
+
+	var failed []string
+	if desktopErr != nil {
+		failed = append(failed, "desktop wallpaper")
+	} else if err := copyFile(desktop, exportPath); err != nil {
+		fmt.Printf("Failed to export desktop wallpaper: %v\n", err)
+		failed = append(failed, "desktop wallpaper")
+	} else {
+		fmt.Printf("Exported desktop wallpaper to %s\n", exportPath)
+	}
+
+	if lockErr == nil {
+		err = copyFile(lock, lockExportPath)
+	} else {
+		err = exportLockScreenViaWinRT(lockExportPath)
+	}
+	if err != nil {
+		fmt.Printf("Failed to export lock screen image: %v\n", err)
+		failed = append(failed, "lock screen image")
+	} else {
+		fmt.Printf("Exported lock screen image to %s\n", lockExportPath)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not export the %s", strings.Join(failed, " or the "))
+	}
+	return nil
+}
+
+// exportLockScreenViaWinRT writes the current user's lock screen image, as
+// returned by the Windows Runtime LockScreen API, to a file
+func exportLockScreenViaWinRT(destPath string) error {
+	psScript := fmt.Sprintf(`
+$ErrorActionPreference = "Stop"
+
+Add-Type -AssemblyName System.Runtime.WindowsRuntime
+[Windows.System.UserProfile.LockScreen,Windows.System.UserProfile,ContentType=WindowsRuntime] | Out-Null
+
+$stream = [System.IO.WindowsRuntimeStreamExtensions]::AsStream([Windows.System.UserProfile.LockScreen]::GetImageStream())
+$out = [System.IO.File]::Create('%s')
+try {
+    $stream.CopyTo($out)
+} finally {
+    $out.Close()
+    $stream.Close()
+}
+`, strings.ReplaceAll(destPath, "'", "''"))
+
+	output, err := winapi.Commands.Run(context.Background(), "powershell.exe",
+		"-NoProfile",
+		"-ExecutionPolicy", "Bypass",
+		"-Command", psScript,
+	)
+	if err != nil {
+		return fmt.Errorf("PowerShell WinRT failed: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// copyFile copies src to dst, creating the destination directory if needed
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
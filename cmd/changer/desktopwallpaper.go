@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// IDesktopWallpaper (Windows 8 and later) sets the wallpaper of each monitor,
+// where SystemParametersInfo sets one image for all of them.
+var (
+	clsidDesktopWallpaper = windows.GUID{Data1: 0xC2CF3110, Data2: 0x460E, Data3: 0x4FC1, Data4: [8]byte{0xB9, 0xD0, 0x8A, 0x1C, 0x0C, 0x9C, 0xC4, 0xBD}}
+	iidDesktopWallpaper   = windows.GUID{Data1: 0xB92B56A9, Data2: 0x8B55, Data3: 0x4E14, Data4: [8]byte{0x9A, 0x89, 0x01, 0x99, 0xBB, 0xB6, 0xF9, 0x3B}}
+
+	procCoCreateInstance = windows.NewLazySystemDLL("ole32.dll").NewProc("CoCreateInstance")
+)
+
+// IDesktopWallpaper vtable slots, after the three IUnknown methods.
+const (
+	slotRelease                   = 2
+	slotSetWallpaper              = 3
+	slotGetWallpaper              = 4
+	slotGetMonitorDevicePathAt    = 5
+	slotGetMonitorDevicePathCount = 6
+	slotGetMonitorRECT            = 7
+	slotSetPosition               = 10
+)
+
+const clsctxAll = 0x17
+
+// monitor is one monitor as IDesktopWallpaper reports it.
+type monitor struct {
+	// Number is the 1-based position in IDesktopWallpaper's list, which is
+	// what --monitor takes.
+	Number int `json:"number"`
+	// ID is the monitor's device path, which stays the same across reboots.
+	ID string `json:"id"`
+	// Attached is false for monitors Windows remembers but that are not
+	// connected; they have no position.
+	Attached  bool   `json:"attached"`
+	X         int32  `json:"x"`
+	Y         int32  `json:"y"`
+	Width     int32  `json:"width"`
+	Height    int32  `json:"height"`
+	Wallpaper string `json:"wallpaper"`
+}
+
+// desktopWallpaper is the IDesktopWallpaper COM object.
+type desktopWallpaper struct {
+	vtbl *[19]uintptr
+}
+
+// call invokes a method and returns its HRESULT, failing on error codes.
+func (d *desktopWallpaper) call(slot int, args ...uintptr) (uintptr, error) {
+	hr, _, _ := syscall.SyscallN(d.vtbl[slot], append([]uintptr{uintptr(unsafe.Pointer(d))}, args...)...)
+	if int32(hr) < 0 {
+		return hr, fmt.Errorf("HRESULT 0x%08X", uint32(hr))
+	}
+	return hr, nil
+}
+
+// withDesktopWallpaper runs fn with the IDesktopWallpaper object on a thread
+// initialized for COM.
+func withDesktopWallpaper(fn func(*desktopWallpaper) error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := windows.CoInitializeEx(0, windows.COINIT_APARTMENTTHREADED); err == nil {
+		defer windows.CoUninitialize()
+	}
+
+	var d *desktopWallpaper
+	hr, _, _ := procCoCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidDesktopWallpaper)),
+		0,
+		clsctxAll,
+		uintptr(unsafe.Pointer(&iidDesktopWallpaper)),
+		uintptr(unsafe.Pointer(&d)),
+	)
+	if int32(hr) < 0 {
+		return fmt.Errorf("IDesktopWallpaper is not available (HRESULT 0x%08X); it requires Windows 8 or later", uint32(hr))
+	}
+	defer syscall.SyscallN(d.vtbl[slotRelease], uintptr(unsafe.Pointer(d)))
+	return fn(d)
+}
+
+// monitors lists the monitors with their current wallpaper.
+func (d *desktopWallpaper) monitors() ([]monitor, error) {
+	var count uint32
+	if _, err := d.call(slotGetMonitorDevicePathCount, uintptr(unsafe.Pointer(&count))); err != nil {
+		return nil, fmt.Errorf("failed to count monitors: %w", err)
+	}
+
+	var monitors []monitor
+	for i := uint32(0); i < count; i++ {
+		var id *uint16
+		if _, err := d.call(slotGetMonitorDevicePathAt, uintptr(i), uintptr(unsafe.Pointer(&id))); err != nil {
+			return nil, fmt.Errorf("failed to get monitor %d: %w", i+1, err)
+		}
+		m := monitor{Number: int(i) + 1, ID: windows.UTF16PtrToString(id)}
+		windows.CoTaskMemFree(unsafe.Pointer(id))
+
+		idPtr, err := windows.UTF16PtrFromString(m.ID)
+		if err != nil {
+			return nil, err
+		}
+		var rect windows.Rect
+		// S_FALSE means the monitor is not attached
+		if hr, err := d.call(slotGetMonitorRECT, uintptr(unsafe.Pointer(idPtr)), uintptr(unsafe.Pointer(&rect))); err == nil && hr == 0 {
+			m.Attached = true
+			m.X, m.Y = rect.Left, rect.Top
+			m.Width, m.Height = rect.Right-rect.Left, rect.Bottom-rect.Top
+		}
+
+		var wallpaper *uint16
+		if _, err := d.call(slotGetWallpaper, uintptr(unsafe.Pointer(idPtr)), uintptr(unsafe.Pointer(&wallpaper))); err == nil {
+			m.Wallpaper = windows.UTF16PtrToString(wallpaper)
+			windows.CoTaskMemFree(unsafe.Pointer(wallpaper))
+		}
+		monitors = append(monitors, m)
+	}
+	return monitors, nil
+}
+
+// setWallpaper sets the wallpaper of one monitor by its ID.
+func (d *desktopWallpaper) setWallpaper(monitorID, path string) error {
+	idPtr, err := windows.UTF16PtrFromString(monitorID)
+	if err != nil {
+		return err
+	}
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	_, err = d.call(slotSetWallpaper, uintptr(unsafe.Pointer(idPtr)), uintptr(unsafe.Pointer(pathPtr)))
+	return err
+}
+
+// setPosition sets the fit of every monitor's wallpaper, one of the
+// wallpaperFits positions.
+func (d *desktopWallpaper) setPosition(position uintptr) error {
+	_, err := d.call(slotSetPosition, position)
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// wallpaperFit is how Windows places a wallpaper that doesn't match the
+// screen: the "Choose a fit" setting in Personalization.
+type wallpaperFit struct {
+	// style and tile are the WallpaperStyle and TileWallpaper values under
+	// HKCU\Control Panel\Desktop.
+	style string
+	tile  string
+	// position is the DESKTOP_WALLPAPER_POSITION of IDesktopWallpaper.
+	position uintptr
+}
+
+// wallpaperFits are the values --fit takes.
+var wallpaperFits = map[string]wallpaperFit{
+	"center":  {style: "0", tile: "0", position: 0},
+	"tile":    {style: "0", tile: "1", position: 1},
+	"stretch": {style: "2", tile: "0", position: 2},
+	"fit":     {style: "6", tile: "0", position: 3},
+	"fill":    {style: "10", tile: "0", position: 4},
+	"span":    {style: "22", tile: "0", position: 5},
+}
+
+// parseFit checks a --fit value.
+func parseFit(value string) (string, error) {
+	fit := strings.ToLower(value)
+	if _, ok := wallpaperFits[fit]; ok {
+		return fit, nil
+	}
+	names := make([]string, 0, len(wallpaperFits))
+	for name := range wallpaperFits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown fit %q (use %s)", value, strings.Join(names, ", "))
+}
+
+// setWallpaperFit writes the fit Windows reads when the wallpaper is set next,
+// so call it before setDesktopWallpaper.
+func setWallpaperFit(fit string) error {
+	f := wallpaperFits[fit]
+	recordRegistry(registry.CURRENT_USER, `Control Panel\Desktop`, "WallpaperStyle", "TileWallpaper")
+
+	key, err := winapi.Reg.OpenKey(registry.CURRENT_USER, `Control Panel\Desktop`, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open Control Panel\\Desktop: %w", err)
+	}
+	defer key.Close()
+
+	if err := key.SetStringValue("WallpaperStyle", f.style); err != nil {
+		return fmt.Errorf("failed to set WallpaperStyle: %w", err)
+	}
+	if err := key.SetStringValue("TileWallpaper", f.tile); err != nil {
+		return fmt.Errorf("failed to set TileWallpaper: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/library"
+)
+
+// libraryPrefix selects a configured wallpaper library, e.g. "library:corp"
+const libraryPrefix = "library:"
+
+// isLibrary checks if the input refers to a configured wallpaper library
+func isLibrary(input string) bool {
+	return strings.HasPrefix(strings.ToLower(input), libraryPrefix)
+}
+
+// fetchFromLibrary picks a random image from the named library and downloads it
+func fetchFromLibrary(name string) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+
+	libCfg := cfg.Library(name)
+	if libCfg == nil {
+		return "", fmt.Errorf("no library named %q in %s", name, config.Path())
+	}
+
+	backend, err := library.New(libCfg)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Listing wallpapers in %s\n", backend.Name())
+	keys, err := backend.List()
+	if err != nil {
+		return "", fmt.Errorf("failed to list library: %w", err)
+	}
+
+	var images []string
+	for _, key := range keys {
+		if isImage(key) {
+			images = append(images, key)
+		}
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("no images found in library %s", backend.Name())
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	selected := images[r.Intn(len(images))]
+	fmt.Printf("Selected wallpaper: %s\n", selected)
+
+	body, err := backend.Open(selected)
+	if err != nil {
+		return "", fmt.Errorf("failed to download image: %w", err)
+	}
+	defer body.Close()
+
+	dir := getDataDir()
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create persistent directory: %w", err)
+	}
+	destPath := filepath.Join(dir, "wallpaper"+strings.ToLower(filepath.Ext(selected)))
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, body)
+	if err != nil {
+		os.Remove(destPath)
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+
+	out.Close()
+
+	fmt.Printf("Image downloaded to: %s\n", destPath)
+	return convertDownloaded(destPath, dir, "wallpaper")
+}
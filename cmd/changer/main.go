@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -9,14 +11,19 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 	"unsafe"
 
+	"github.com/backgroundchanger/internal/attribution"
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/installer"
 	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/winapi"
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 )
@@ -24,6 +31,7 @@ import (
 // Windows API constants
 const (
 	SPI_SETDESKWALLPAPER       = 0x0014
+	SPI_GETDESKWALLPAPER       = 0x0073
 	SPI_SETLOCKSCREENWALLPAPER = 0x0115
 	SPIF_UPDATEINIFILE         = 0x01
 	SPIF_SENDCHANGE            = 0x02
@@ -35,6 +43,10 @@ var supportedExtensions = map[string]bool{
 	".jpeg": true,
 	".png":  true,
 	".bmp":  true,
+	// Converted before they are applied, see convertForWindows; HEIC and
+	// AVIF are added from imageconv
+	".webp": true,
+	".gif":  true,
 }
 
 // WallpaperEntry represents an image entry from the slide.recipes API
@@ -58,34 +70,22 @@ func isURL(input string) bool {
 func fetchRandomWallpaperURL() (string, error) {
 	fmt.Printf("Fetching wallpaper list from %s\n", slideRecipesURL)
 
-	// Make HTTP request to get the JSON list
-	resp, err := http.Get(slideRecipesURL)
+	// Fetch the JSON list (conditional request, falls back to the cached copy)
+	body, err := fetchCatalog(slideRecipesURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch wallpaper list: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check for successful response
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch wallpaper list: HTTP %d", resp.StatusCode)
-	}
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return "", err
 	}
 
 	// Parse the JSON response
 	var wallpapers []WallpaperEntry
 	err = json.Unmarshal(body, &wallpapers)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse wallpaper list: %v", err)
+		return "", fmt.Errorf("failed to parse wallpaper list: %w", err)
 	}
 
 	// Check if we got any wallpapers
 	if len(wallpapers) == 0 {
-		return "", fmt.Errorf("no wallpapers found in the list")
+		return "", errs.Mark(errs.ErrNoImage, fmt.Errorf("no wallpapers found in the list"))
 	}
 
 	// Randomly select one wallpaper
@@ -98,18 +98,30 @@ func fetchRandomWallpaperURL() (string, error) {
 
 // downloadImage downloads an image from a URL and saves it to a temporary file
 func downloadImage(imageURL string) (string, error) {
+	return downloadImageTo(imageURL, getDataDir(), "wallpaper")
+}
+
+// downloadImageTo downloads an image from a URL into dir, naming it baseName plus
+// the image's extension, and returns the saved path
+func downloadImageTo(imageURL, dir, baseName string) (string, error) {
 	fmt.Printf("Downloading image from URL: %s\n", imageURL)
 
 	// Parse the URL to extract the filename
 	parsedURL, err := url.Parse(imageURL)
 	if err != nil {
-		return "", fmt.Errorf("invalid URL: %v", err)
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// Reject sources outside the configured allow list before downloading anything
+	err = safetyGate().CheckURL(imageURL)
+	if err != nil {
+		return "", err
 	}
 
 	// Make the HTTP request
 	resp, err := http.Get(imageURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to download image: %v", err)
+		return "", fmt.Errorf("failed to download image: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -135,6 +147,14 @@ func downloadImage(imageURL string) (string, error) {
 			ext = ".png"
 		case "image/bmp":
 			ext = ".bmp"
+		case "image/webp":
+			ext = ".webp"
+		case "image/gif":
+			ext = ".gif"
+		case "image/heic", "image/heif":
+			ext = ".heic"
+		case "image/avif":
+			ext = ".avif"
 		default:
 			ext = ".jpg" // Default to jpg
 		}
@@ -146,30 +166,49 @@ func downloadImage(imageURL string) (string, error) {
 	}
 
 	// Save to a persistent location so the registry can reference it reliably
-	// Using ProgramData ensures the file survives reboots and temp cleanup
-	persistentDir := filepath.Join(os.Getenv("PROGRAMDATA"), "BgChanger")
-	err = os.MkdirAll(persistentDir, 0755)
+	err = os.MkdirAll(dir, 0755)
 	if err != nil {
-		return "", fmt.Errorf("failed to create persistent directory: %v", err)
+		return "", fmt.Errorf("failed to create persistent directory: %w", err)
 	}
-	tempFile := filepath.Join(persistentDir, fmt.Sprintf("wallpaper%s", ext))
+	tempFile := filepath.Join(dir, baseName+ext)
 
 	// Create the file
 	out, err := os.Create(tempFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary file: %v", err)
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
 	}
 	defer out.Close()
 
-	// Copy the response body to the file
-	_, err = io.Copy(out, resp.Body)
+	// Copy the response body to the file, stopping at the size limit
+	limits := imageLimits()
+	maxBytes := limits.FileBytes()
+	n, err := io.Copy(out, io.LimitReader(resp.Body, maxBytes+1))
+	if err == nil && n > maxBytes {
+		err = errs.Mark(errs.ErrImageRejected, fmt.Errorf("image is larger than the %d MB limit", maxBytes>>20))
+	}
 	if err != nil {
+		out.Close()
 		os.Remove(tempFile)
-		return "", fmt.Errorf("failed to save image: %v", err)
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	out.Close()
+
+	// Reject malformed files and decompression bombs before anything decodes them
+	err = loginscreen.CheckImageFile(tempFile, limits)
+	if err != nil {
+		os.Remove(tempFile)
+		return "", err
+	}
+
+	// Run the configured content classifier before the image can be applied
+	err = safetyGate().CheckImage(tempFile)
+	if err != nil {
+		os.Remove(tempFile)
+		return "", err
 	}
 
 	fmt.Printf("Image downloaded to: %s\n", tempFile)
-	return tempFile, nil
+	return convertDownloaded(tempFile, dir, baseName)
 }
 
 // isAdmin checks if the current process is running with administrator privileges
@@ -200,7 +239,7 @@ func isAdmin() bool {
 func runElevated() error {
 	exe, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %v", err)
+		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
 	// Build arguments string (skip the first arg which is the program name)
@@ -227,12 +266,40 @@ func runElevated() error {
 
 	// ShellExecute returns > 32 on success
 	if ret <= 32 {
-		return fmt.Errorf("ShellExecute failed with code %d", ret)
+		return errs.Mark(errs.ErrElevationRequired, fmt.Errorf("ShellExecute failed with code %d", ret))
 	}
 
 	return nil
 }
 
+// ensureAdmin relaunches bgchanger elevated and exits when it isn't running as
+// an administrator, so only the elevated process goes on.
+func ensureAdmin(scheduled bool) {
+	if !isAdmin() && scheduled {
+		// A UAC prompt from a background task would appear out of nowhere
+		fmt.Println("The rotation task needs an administrator account; not changing the wallpaper.")
+		os.Exit(1)
+	}
+	if !isAdmin() {
+		fmt.Println("Administrator privileges required for lock/login screen changes.")
+		fmt.Println("Requesting elevation via UAC...")
+
+		err := runElevated()
+		if err != nil {
+			fmt.Printf("Failed to elevate privileges: %v\n", err)
+			fmt.Println("\nPlease run this application as administrator manually:")
+			fmt.Println("  Right-click the executable and select 'Run as administrator'")
+			os.Exit(1)
+		}
+
+		// Exit the non-elevated process - the elevated one will continue
+		fmt.Println("Elevated process launched. This window can be closed.")
+		os.Exit(0)
+	}
+
+	fmt.Println("Running with administrator privileges.")
+}
+
 // setLoginScreenViaWinRT sets the lock/login screen using PowerShell and the Windows Runtime API
 func setLoginScreenViaWinRT(absPath string) error {
 	// PowerShell script to use Windows Runtime LockScreen API
@@ -274,15 +341,13 @@ Write-Host "Lock screen image set successfully via WinRT API"
 `, absPath)
 
 	// Run PowerShell with execution policy bypass
-	cmd := exec.Command("powershell.exe",
+	output, err := winapi.Commands.Run(context.Background(), "powershell.exe",
 		"-NoProfile",
 		"-ExecutionPolicy", "Bypass",
 		"-Command", psScript,
 	)
-
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("PowerShell WinRT failed: %v\nOutput: %s", err, string(output))
+		return fmt.Errorf("PowerShell WinRT failed: %w\nOutput: %s", err, string(output))
 	}
 
 	fmt.Printf("- WinRT output: %s\n", strings.TrimSpace(string(output)))
@@ -291,38 +356,42 @@ Write-Host "Lock screen image set successfully via WinRT API"
 
 // setLoginScreenViaGroupPolicy sets the login screen using Group Policy registry keys
 func setLoginScreenViaGroupPolicy(absPath string) error {
+	// Journal the previous values so undo-system-changes can restore them
+	recordRegistry(registry.LOCAL_MACHINE, `SOFTWARE\Policies\Microsoft\Windows\Personalization`, "LockScreenImage")
+	recordRegistry(registry.LOCAL_MACHINE, `SOFTWARE\Policies\Microsoft\Windows\System`, "DisableLogonBackgroundImage")
+
 	// Open or create the Personalization policy key
-	key, _, err := registry.CreateKey(
+	key, _, err := winapi.Reg.CreateKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Policies\Microsoft\Windows\Personalization`,
 		registry.ALL_ACCESS,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to open Personalization policy key: %v", err)
+		return fmt.Errorf("failed to open Personalization policy key: %w", err)
 	}
 	defer key.Close()
 
 	// Set LockScreenImage to the image path
 	err = key.SetStringValue("LockScreenImage", absPath)
 	if err != nil {
-		return fmt.Errorf("failed to set LockScreenImage: %v", err)
+		return fmt.Errorf("failed to set LockScreenImage: %w", err)
 	}
 
 	// Also need to ensure DisableLogonBackgroundImage is set to 0 in the System key
-	sysKey, _, err := registry.CreateKey(
+	sysKey, _, err := winapi.Reg.CreateKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Policies\Microsoft\Windows\System`,
 		registry.ALL_ACCESS,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to open System policy key: %v", err)
+		return fmt.Errorf("failed to open System policy key: %w", err)
 	}
 	defer sysKey.Close()
 
 	// Set DisableLogonBackgroundImage to 0 (enable custom background)
 	err = sysKey.SetDWordValue("DisableLogonBackgroundImage", 0)
 	if err != nil {
-		return fmt.Errorf("failed to set DisableLogonBackgroundImage: %v", err)
+		return fmt.Errorf("failed to set DisableLogonBackgroundImage: %w", err)
 	}
 
 	fmt.Println("- Group Policy registry keys set successfully")
@@ -331,22 +400,10 @@ func setLoginScreenViaGroupPolicy(absPath string) error {
 
 // Sets the desktop wallpaper using Windows API
 func setDesktopWallpaper(path string) error {
-	pathPtr, err := syscall.UTF16PtrFromString(path)
-	if err != nil {
-		return err
-	}
-
-	_, _, err = syscall.NewLazyDLL("user32.dll").NewProc("SystemParametersInfoW").Call(
-		uintptr(SPI_SETDESKWALLPAPER),
-		0,
-		uintptr(unsafe.Pointer(pathPtr)),
-		uintptr(SPIF_UPDATEINIFILE|SPIF_SENDCHANGE),
-	)
+	// SPIF_UPDATEINIFILE persists the path to HKCU\Control Panel\Desktop
+	recordRegistry(registry.CURRENT_USER, `Control Panel\Desktop`, "Wallpaper")
 
-	if err != nil && err != syscall.Errno(0) {
-		return err
-	}
-	return nil
+	return winapi.SPI.SetString(SPI_SETDESKWALLPAPER, path, SPIF_UPDATEINIFILE|SPIF_SENDCHANGE)
 }
 
 // Sets the lock screen wallpaper for Windows 10/11
@@ -359,18 +416,22 @@ func setLockScreenWallpaper(path string) error {
 
 	// Try all methods one by one, continuing if one fails
 	methods := []struct {
-		name string
-		fn   func(string) error
+		name   string
+		method string
+		fn     func(string) error
 	}{
-		{"Registry (HKCU)", setLockScreenWallpaperViaRegistry},
-		{"Assets folder", setLockScreenWallpaperViaAssets},
-		{"System Data folder", setLockScreenWallpaperViaSystemData},
-		{"Registry (HKLM)", setLockScreenWallpaperViaHKLM},
+		{"Registry (HKCU)", capability.MethodLockRegistryHKCU, setLockScreenWallpaperViaRegistry},
+		{"Assets folder", capability.MethodLockAssets, setLockScreenWallpaperViaAssets},
+		{"System Data folder", capability.MethodLockSystemData, setLockScreenWallpaperViaSystemData},
+		{"Registry (HKLM)", capability.MethodLockRegistryHKLM, setLockScreenWallpaperViaHKLM},
 	}
 
 	var anySuccess bool
 	var lastError error
 	for _, method := range methods {
+		if !shouldTryMethod(method.name, method.method) {
+			continue
+		}
 		fmt.Printf("Trying method: %s\n", method.name)
 		err := method.fn(absPath)
 		if err != nil {
@@ -383,8 +444,11 @@ func setLockScreenWallpaper(path string) error {
 	}
 
 	// If all methods failed, return the last error
+	if !anySuccess && lastError == nil {
+		return errs.Mark(errs.ErrMethodUnsupported, fmt.Errorf("no lock screen method is supported on this system"))
+	}
 	if !anySuccess {
-		return fmt.Errorf("all methods failed, last error: %v", lastError)
+		return errs.Classify(fmt.Errorf("all methods failed, last error: %w", lastError))
 	}
 
 	return nil
@@ -404,16 +468,20 @@ func setLoginScreenBackground(path string) error {
 	// 1. WinRT API via PowerShell (works on all Windows 10/11 editions)
 	// 2. Group Policy registry (works on Pro/Enterprise)
 	methods := []struct {
-		name string
-		fn   func(string) error
+		name   string
+		method string
+		fn     func(string) error
 	}{
-		{"Windows Runtime API (PowerShell)", setLoginScreenViaWinRT},
-		{"Group Policy Registry", setLoginScreenViaGroupPolicy},
+		{"Windows Runtime API (PowerShell)", capability.MethodWinRT, setLoginScreenViaWinRT},
+		{"Group Policy Registry", capability.MethodGroupPolicy, setLoginScreenViaGroupPolicy},
 	}
 
 	var anySuccess bool
 	var lastError error
 	for _, method := range methods {
+		if !shouldTryMethod(method.name, method.method) {
+			continue
+		}
 		fmt.Printf("Trying method: %s\n", method.name)
 		err := method.fn(absPath)
 		if err != nil {
@@ -426,8 +494,11 @@ func setLoginScreenBackground(path string) error {
 	}
 
 	// If all methods failed, return the last error
+	if !anySuccess && lastError == nil {
+		return errs.Mark(errs.ErrMethodUnsupported, fmt.Errorf("no login screen method is supported on this system"))
+	}
 	if !anySuccess {
-		return fmt.Errorf("all login screen methods failed, last error: %v", lastError)
+		return errs.Classify(fmt.Errorf("all login screen methods failed, last error: %w", lastError))
 	}
 
 	return nil
@@ -435,71 +506,27 @@ func setLoginScreenBackground(path string) error {
 
 // Sets lock screen wallpaper using registry
 func setLockScreenWallpaperViaRegistry(absPath string) error {
-	// Create a key for the lock screen
-	keyPathPtr, err := syscall.UTF16PtrFromString("SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\PersonalizationCSP")
-	if err != nil {
-		return err
-	}
+	recordRegistry(registry.CURRENT_USER, `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
+		"LockScreenImagePath", "LockScreenImageStatus")
 
-	key, _, err := syscall.NewLazyDLL("advapi32.dll").NewProc("RegCreateKeyExW").Call(
-		uintptr(syscall.HKEY_CURRENT_USER),
-		uintptr(unsafe.Pointer(keyPathPtr)),
-		0,
-		0,
-		0,
-		uintptr(syscall.KEY_WRITE),
-		0,
-		0,
-		0,
+	// Create a key for the lock screen
+	key, _, err := winapi.Reg.CreateKey(
+		registry.CURRENT_USER,
+		`SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
+		registry.SET_VALUE,
 	)
-	if err != nil && err != syscall.Errno(0) {
-		return err
-	}
-	defer syscall.RegCloseKey(syscall.Handle(key))
-
-	// Set the LockScreenImagePath value
-	pathPtr, err := syscall.UTF16PtrFromString(absPath)
-	if err != nil {
-		return err
-	}
-
-	valueNamePtr, err := syscall.UTF16PtrFromString("LockScreenImagePath")
 	if err != nil {
 		return err
 	}
+	defer key.Close()
 
-	_, _, err = syscall.NewLazyDLL("advapi32.dll").NewProc("RegSetValueExW").Call(
-		key,
-		uintptr(unsafe.Pointer(valueNamePtr)),
-		0,
-		uintptr(syscall.REG_SZ),
-		uintptr(unsafe.Pointer(pathPtr)),
-		uintptr(2*(len(absPath)+1)),
-	)
-	if err != nil && err != syscall.Errno(0) {
+	// Set the LockScreenImagePath value
+	if err := key.SetStringValue("LockScreenImagePath", absPath); err != nil {
 		return err
 	}
 
 	// Set the LockScreenImageStatus value
-	statusPtr, err := syscall.UTF16PtrFromString("1")
-	if err != nil {
-		return err
-	}
-
-	statusNamePtr, err := syscall.UTF16PtrFromString("LockScreenImageStatus")
-	if err != nil {
-		return err
-	}
-
-	_, _, err = syscall.NewLazyDLL("advapi32.dll").NewProc("RegSetValueExW").Call(
-		key,
-		uintptr(unsafe.Pointer(statusNamePtr)),
-		0,
-		uintptr(syscall.REG_SZ),
-		uintptr(unsafe.Pointer(statusPtr)),
-		uintptr(4),
-	)
-	if err != nil && err != syscall.Errno(0) {
+	if err := key.SetStringValue("LockScreenImageStatus", "1"); err != nil {
 		return err
 	}
 
@@ -518,7 +545,7 @@ func setLockScreenWallpaperViaAssets(absPath string) error {
 	assetsDir := filepath.Join(localAppData, "Packages", "Microsoft.Windows.ContentDeliveryManager_cw5n1h2txyewy", "LocalState", "Assets")
 	err := os.MkdirAll(assetsDir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create assets directory: %v", err)
+		return fmt.Errorf("failed to create assets directory: %w", err)
 	}
 
 	// Generate a unique destination filename
@@ -527,26 +554,16 @@ func setLockScreenWallpaperViaAssets(absPath string) error {
 	// Copy the image file to the assets directory
 	sourceData, err := os.ReadFile(absPath)
 	if err != nil {
-		return fmt.Errorf("failed to read source image: %v", err)
+		return fmt.Errorf("failed to read source image: %w", err)
 	}
 
 	err = os.WriteFile(destFile, sourceData, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write to destination: %v", err)
+		return fmt.Errorf("failed to write to destination: %w", err)
 	}
 
 	// Try also the direct Windows API method
-	pathPtr, err := syscall.UTF16PtrFromString(absPath)
-	if err != nil {
-		return err
-	}
-
-	_, _, _ = syscall.NewLazyDLL("user32.dll").NewProc("SystemParametersInfoW").Call(
-		uintptr(SPI_SETLOCKSCREENWALLPAPER),
-		0,
-		uintptr(unsafe.Pointer(pathPtr)),
-		uintptr(SPIF_UPDATEINIFILE|SPIF_SENDCHANGE),
-	)
+	_ = winapi.SPI.SetString(SPI_SETLOCKSCREENWALLPAPER, absPath, SPIF_UPDATEINIFILE|SPIF_SENDCHANGE)
 
 	// Don't return error from this call as it may not be supported on all Windows versions
 
@@ -555,135 +572,50 @@ func setLockScreenWallpaperViaAssets(absPath string) error {
 
 // Sets lock screen wallpaper via HKEY_LOCAL_MACHINE (requires admin privileges)
 func setLockScreenWallpaperViaHKLM(absPath string) error {
-	// Disable logon background image
-	systemKeyPtr, err := syscall.UTF16PtrFromString("SOFTWARE\\Policies\\Microsoft\\Windows\\System")
-	if err != nil {
-		return err
-	}
+	recordRegistry(registry.LOCAL_MACHINE, `SOFTWARE\Policies\Microsoft\Windows\System`, "DisableLogonBackgroundImage")
+	recordRegistry(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
+		"LockScreenImagePath", "LockScreenImageUrl", "LockScreenImageStatus")
 
-	key, _, err := syscall.NewLazyDLL("advapi32.dll").NewProc("RegCreateKeyExW").Call(
-		uintptr(syscall.HKEY_LOCAL_MACHINE),
-		uintptr(unsafe.Pointer(systemKeyPtr)),
-		0,
-		0,
-		0,
-		uintptr(syscall.KEY_WRITE),
-		0,
-		0,
-		0,
+	// Disable logon background image
+	key, _, err := winapi.Reg.CreateKey(
+		registry.LOCAL_MACHINE,
+		`SOFTWARE\Policies\Microsoft\Windows\System`,
+		registry.SET_VALUE,
 	)
-	if err != nil && err != syscall.Errno(0) {
-		return fmt.Errorf("failed to open HKLM System key: %v", err)
-	}
-	defer syscall.RegCloseKey(syscall.Handle(key))
-
-	// Set DisableLogonBackgroundImage to 0
-	valPtr, err := syscall.UTF16PtrFromString("0")
 	if err != nil {
-		return err
-	}
-
-	disableLogonPtr, err := syscall.UTF16PtrFromString("DisableLogonBackgroundImage")
-	if err != nil {
-		return err
+		return fmt.Errorf("failed to open HKLM System key: %w", err)
 	}
+	defer key.Close()
 
-	_, _, err = syscall.NewLazyDLL("advapi32.dll").NewProc("RegSetValueExW").Call(
-		key,
-		uintptr(unsafe.Pointer(disableLogonPtr)),
-		0,
-		uintptr(syscall.REG_DWORD),
-		uintptr(unsafe.Pointer(valPtr)),
-		uintptr(4),
-	)
-	if err != nil && err != syscall.Errno(0) {
-		return fmt.Errorf("failed to set DisableLogonBackgroundImage: %v", err)
+	// Set DisableLogonBackgroundImage to 0
+	if err := key.SetDWordValue("DisableLogonBackgroundImage", 0); err != nil {
+		return fmt.Errorf("failed to set DisableLogonBackgroundImage: %w", err)
 	}
 
 	// Now set the PersonalizationCSP keys in HKEY_LOCAL_MACHINE
-	personalizationPtr, err := syscall.UTF16PtrFromString("SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\PersonalizationCSP")
-	if err != nil {
-		return err
-	}
-
-	key2, _, err := syscall.NewLazyDLL("advapi32.dll").NewProc("RegCreateKeyExW").Call(
-		uintptr(syscall.HKEY_LOCAL_MACHINE),
-		uintptr(unsafe.Pointer(personalizationPtr)),
-		0,
-		0,
-		0,
-		uintptr(syscall.KEY_WRITE),
-		0,
-		0,
-		0,
+	key2, _, err := winapi.Reg.CreateKey(
+		registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
+		registry.SET_VALUE,
 	)
-	if err != nil && err != syscall.Errno(0) {
-		return fmt.Errorf("failed to open HKLM PersonalizationCSP key: %v", err)
-	}
-	defer syscall.RegCloseKey(syscall.Handle(key2))
-
-	// Set LockScreenImagePath
-	pathPtr, err := syscall.UTF16PtrFromString(absPath)
 	if err != nil {
-		return err
-	}
-
-	lockScreenPathPtr, err := syscall.UTF16PtrFromString("LockScreenImagePath")
-	if err != nil {
-		return err
+		return fmt.Errorf("failed to open HKLM PersonalizationCSP key: %w", err)
 	}
+	defer key2.Close()
 
-	_, _, err = syscall.NewLazyDLL("advapi32.dll").NewProc("RegSetValueExW").Call(
-		key2,
-		uintptr(unsafe.Pointer(lockScreenPathPtr)),
-		0,
-		uintptr(syscall.REG_SZ),
-		uintptr(unsafe.Pointer(pathPtr)),
-		uintptr(2*(len(absPath)+1)),
-	)
-	if err != nil && err != syscall.Errno(0) {
-		return fmt.Errorf("failed to set LockScreenImagePath: %v", err)
+	// Set LockScreenImagePath
+	if err := key2.SetStringValue("LockScreenImagePath", absPath); err != nil {
+		return fmt.Errorf("failed to set LockScreenImagePath: %w", err)
 	}
 
 	// Set LockScreenImageUrl
-	lockScreenUrlPtr, err := syscall.UTF16PtrFromString("LockScreenImageUrl")
-	if err != nil {
-		return err
-	}
-
-	_, _, err = syscall.NewLazyDLL("advapi32.dll").NewProc("RegSetValueExW").Call(
-		key2,
-		uintptr(unsafe.Pointer(lockScreenUrlPtr)),
-		0,
-		uintptr(syscall.REG_SZ),
-		uintptr(unsafe.Pointer(pathPtr)),
-		uintptr(2*(len(absPath)+1)),
-	)
-	if err != nil && err != syscall.Errno(0) {
-		return fmt.Errorf("failed to set LockScreenImageUrl: %v", err)
+	if err := key2.SetStringValue("LockScreenImageUrl", absPath); err != nil {
+		return fmt.Errorf("failed to set LockScreenImageUrl: %w", err)
 	}
 
 	// Set LockScreenImageStatus
-	statusPtr, err := syscall.UTF16PtrFromString("1")
-	if err != nil {
-		return err
-	}
-
-	lockScreenStatusPtr, err := syscall.UTF16PtrFromString("LockScreenImageStatus")
-	if err != nil {
-		return err
-	}
-
-	_, _, err = syscall.NewLazyDLL("advapi32.dll").NewProc("RegSetValueExW").Call(
-		key2,
-		uintptr(unsafe.Pointer(lockScreenStatusPtr)),
-		0,
-		uintptr(syscall.REG_DWORD),
-		uintptr(unsafe.Pointer(statusPtr)),
-		uintptr(4),
-	)
-	if err != nil && err != syscall.Errno(0) {
-		return fmt.Errorf("failed to set LockScreenImageStatus: %v", err)
+	if err := key2.SetDWordValue("LockScreenImageStatus", 1); err != nil {
+		return fmt.Errorf("failed to set LockScreenImageStatus: %w", err)
 	}
 
 	return nil
@@ -701,7 +633,7 @@ func setLockScreenWallpaperViaSystemData(absPath string) error {
 	systemDataDir := filepath.Join(programData, "Microsoft", "Windows", "SystemData")
 	err := os.MkdirAll(systemDataDir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create SystemData directory: %v", err)
+		return fmt.Errorf("failed to create SystemData directory: %w", err)
 	}
 
 	// Copy the image file to the SystemData directory as bg.png
@@ -709,22 +641,30 @@ func setLockScreenWallpaperViaSystemData(absPath string) error {
 
 	sourceData, err := os.ReadFile(absPath)
 	if err != nil {
-		return fmt.Errorf("failed to read source image: %v", err)
+		return fmt.Errorf("failed to read source image: %w", err)
 	}
 
 	err = os.WriteFile(destFile, sourceData, 0644)
 	if err != nil {
-		// Check if it's an access denied error - this is common on modern Windows
-		if strings.Contains(err.Error(), "Access is denied") {
+		// Access denied is common on modern Windows
+		err = errs.Classify(err)
+		if errors.Is(err, errs.ErrAccessDenied) {
 			fmt.Printf("- Note: Access denied to SystemData directory - this method may not work on your Windows version\n")
-			return fmt.Errorf("access denied to SystemData directory: %v", err)
+			return fmt.Errorf("access denied to SystemData directory: %w", err)
 		}
-		return fmt.Errorf("failed to write to destination: %v", err)
+		return fmt.Errorf("failed to write to destination: %w", err)
 	}
 
 	return nil
 }
 
+// imageLimits returns the limits images are checked against before they are
+// decoded; a broken config file keeps the defaults.
+func imageLimits() config.ImageLimitsConfig {
+	cfg, _ := config.Load()
+	return cfg.ImageLimits
+}
+
 // Checks if a file is a supported image
 func isImage(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
@@ -733,6 +673,19 @@ func isImage(path string) bool {
 
 // Gets a random image from a directory
 func getRandomImage(dirPath string) (string, error) {
+	images, err := listImages(dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Use a properly seeded random source
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return images[r.Intn(len(images))], nil
+}
+
+// listImages returns the images in a directory and its subdirectories, in
+// lexical order. Finding none is an ErrNoImage error.
+func listImages(dirPath string) ([]string, error) {
 	var images []string
 
 	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
@@ -746,36 +699,92 @@ func getRandomImage(dirPath string) (string, error) {
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(images) == 0 {
-		return "", fmt.Errorf("no images found in directory: %s", dirPath)
+		return nil, errs.Mark(errs.ErrNoImage, fmt.Errorf("no images found in directory: %s", dirPath))
 	}
-
-	// Use a properly seeded random source
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	return images[r.Intn(len(images))], nil
+	return images, nil
 }
 
 func printHelp() {
-	fmt.Println("Usage: bgchanger [option]")
+	fmt.Println("Usage: bgchanger <command> [flags]")
 	fmt.Println("\nThis tool changes your desktop wallpaper, lock screen, and login screen background.")
-	fmt.Println("\nOptions:")
-	fmt.Println("  (no args)       Download a random wallpaper from slide.recipes")
-	fmt.Println("  <image_path>    Set a specific image as wallpaper (jpg, jpeg, png, bmp)")
-	fmt.Println("  <directory>     Pick a random image from a local directory")
-	fmt.Println("  <url>           Download and set an image from a URL")
+	fmt.Println("\nCommands:")
+	fmt.Println("  set <source>    Set an image on the screens. The source is one of:")
+	fmt.Println("    <image_path>    A specific image (jpg, jpeg, png, bmp, webp, gif, heic, heif, avif)")
+	fmt.Println("    <directory>     A random image from a local directory")
+	fmt.Println("    <url>           An image downloaded from a URL")
+	fmt.Println("    library:<name>  A random image from a configured S3/Azure/WebDAV library")
+	fmt.Println("    bing            Today's Bing image of the day")
+	fmt.Println("    apod            NASA's Astronomy Picture of the Day")
+	fmt.Println("    unsplash        A random Unsplash photo (requires unsplash_access_key)")
+	fmt.Println("  set --monitor <n> <source> [--monitor <n> <source>...]")
+	fmt.Println("                  Set a different desktop wallpaper on each monitor")
+	fmt.Println("  monitors [--json]")
+	fmt.Println("                  List the monitor numbers and IDs with their wallpapers")
+	fmt.Println("  random          Download a random wallpaper from slide.recipes (the default)")
+	fmt.Println("  watch [--interval <interval>] [--order shuffle|sequential] [source]")
+	fmt.Println("                  Keep running and change the wallpaper every interval (default 1h)")
+	fmt.Println("  restore [--previous]")
+	fmt.Println("                  Apply the last image bgchanger applied again, or the one before it")
+	fmt.Println("  status [--json] Show the active images and whether they are still the last applied one")
+	fmt.Println("  update          Update bgchanger to the latest release")
+	fmt.Println("  update --check-only")
+	fmt.Println("                  Only report whether an update is available")
+	fmt.Println("  update --force  Update even on a metered connection or low battery")
+	fmt.Println("  capabilities [--json]")
+	fmt.Println("                  Show the detected edition, build, policies and chosen methods")
+	fmt.Println("  install-rotation [--every <interval>] [source]")
+	fmt.Println("                  Rotate the wallpaper at logon and every interval (default 1h)")
+	fmt.Println("  uninstall-rotation")
+	fmt.Println("                  Remove the rotation task")
+	fmt.Println("  slideshow <folder>")
+	fmt.Println("                  Show a folder of images as your lock screen slideshow")
+	fmt.Println("  slideshow --off Put back the previous slideshow settings")
+	fmt.Println("  current [--export <file>]")
+	fmt.Println("                  Show the active desktop and lock screen images, optionally copying them out")
+	fmt.Println("  verify [--json]  Check that the last applied image is still in place")
+	fmt.Println("                  (exit code 2 when it has been changed)")
+	fmt.Println("  undo-system-changes")
+	fmt.Println("                  Restore every registry value bgchanger/BgStatusService changed")
+	fmt.Println("  version         Show the installed version")
 	fmt.Println("  help            Show this help message")
+	fmt.Println("\nFlags for set, random and restore (combine them to change two screens):")
+	fmt.Println("  --desktop-only    Change only the desktop wallpaper")
+	fmt.Println("  --lockscreen-only Change only the lock screen")
+	fmt.Println("  --login-only      Change only the login screen")
+	fmt.Println("  --fit <fit>       Place the desktop wallpaper: fill, fit, stretch, tile, center or span")
+	fmt.Println("\n'set' can be left out: 'bgchanger <source>' is the same as 'bgchanger set <source>'.")
 	fmt.Println("\nExamples:")
 	fmt.Println("  bgchanger")
-	fmt.Println("  bgchanger C:\\Pictures\\wallpaper.jpg")
-	fmt.Println("  bgchanger C:\\Pictures\\Wallpapers")
-	fmt.Println("  bgchanger https://example.com/image.png")
+	fmt.Println("  bgchanger set C:\\Pictures\\wallpaper.jpg")
+	fmt.Println("  bgchanger set C:\\Pictures\\Wallpapers --desktop-only")
+	fmt.Println("  bgchanger set https://example.com/image.png --lockscreen-only --login-only")
+	fmt.Println("  bgchanger set library:corp")
+	fmt.Println("  bgchanger set --monitor 1 C:\\Pictures\\left.jpg --monitor 2 C:\\Pictures\\right.jpg")
+	fmt.Println("  bgchanger random --desktop-only")
+	fmt.Println("  bgchanger set C:\\Pictures\\panorama.jpg --fit span")
+	fmt.Println("  bgchanger restore --login-only")
+	fmt.Println("  bgchanger watch --interval 30m --order sequential C:\\Pictures\\Wallpapers --desktop-only")
+	fmt.Println("  bgchanger bing")
 	fmt.Println("\nNote: The app will automatically request administrator privileges if needed.")
 }
 
 func main() {
+	// Remove the previous binary left behind by a self-update, if any
+	if exe, err := os.Executable(); err == nil {
+		installer.CleanupOldExecutable(exe)
+	}
+
+	// The rotation task runs without a visible console and nobody to press Enter
+	scheduled := len(os.Args) >= 2 && os.Args[1] == installer.RotationArg
+	if scheduled {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		hideConsole()
+	}
+
 	// Check for help argument first (no privilege escalation needed)
 	if len(os.Args) >= 2 {
 		input := os.Args[1]
@@ -783,33 +792,232 @@ func main() {
 			printHelp()
 			os.Exit(0)
 		}
+		if input == "version" || input == "--version" {
+			fmt.Printf("bgchanger %s\n", version)
+			os.Exit(0)
+		}
+		if input == "capabilities" {
+			err := runCapabilities(os.Args[2:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "undo-system-changes" {
+			err := runUndoSystemChanges()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println("\nPress Enter to exit...")
+				fmt.Scanln()
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "install-rotation" || input == "uninstall-rotation" {
+			if !isAdmin() {
+				fmt.Println("Administrator privileges required to manage the rotation task.")
+				err := runElevated()
+				if err != nil {
+					fmt.Printf("Failed to elevate privileges: %v\n", err)
+					os.Exit(1)
+				}
+				os.Exit(0)
+			}
+			var err error
+			if input == "install-rotation" {
+				err = runInstallRotation(os.Args[2:])
+			} else {
+				err = runUninstallRotation()
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Println("\nPress Enter to exit...")
+				fmt.Scanln()
+				os.Exit(1)
+			}
+			fmt.Println("\nPress Enter to exit...")
+			fmt.Scanln()
+			os.Exit(0)
+		}
+		if input == "slideshow" {
+			err := runSlideshow(os.Args[2:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "current" {
+			err := runCurrent(os.Args[2:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "verify" {
+			err := runVerify(os.Args[2:])
+			if errors.Is(err, errDrift) {
+				os.Exit(2)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "update" {
+			err := runUpdate(os.Args[2:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "monitors" {
+			err := runMonitors(os.Args[2:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "watch" {
+			opts, err := parseWatchArgs(os.Args[2:])
+			if err != nil {
+				exitUsage(err)
+			}
+			// Each change runs as a child that can't ask for elevation itself
+			ensureAdmin(scheduled)
+			if err := runWatch(opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if input == "status" {
+			err := runStatus(os.Args[2:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
+	// Everything else applies an image: set, random or restore
+	opts, err := parseApplyArgs(os.Args[1:])
+	if err != nil {
+		exitUsage(err)
+	}
+
+	// set --monitor N SOURCE... gives each monitor its own desktop wallpaper
+	if len(opts.monitors) > 0 {
+		ensureAdmin(scheduled)
+		if !applyMonitorWallpapers(opts.monitors, opts.fit) {
+			if !scheduled {
+				fmt.Println("\nPress Enter to exit...")
+				fmt.Scanln()
+			}
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
 	// Check if input is a URL - handle before checking local paths
 	var imagePath string
-	var err error
 
-	// No arguments or "random" - fetch random wallpaper from slide.recipes
-	if len(os.Args) < 2 {
-		randomURL, err := fetchRandomWallpaperURL()
+	// Background download of the next random wallpaper, started once we know we will apply one
+	var prefetchDone <-chan error
+
+	if opts.command == commandRestore {
+		// Checked before elevation, like a local path
+		imagePath, err = restoreImagePath(opts.previous)
 		if err != nil {
-			fmt.Printf("Error fetching random wallpaper: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
-		imagePath, err = downloadImage(randomURL)
-		if err != nil {
-			fmt.Printf("Error downloading image: %v\n", err)
-			os.Exit(1)
+		fmt.Printf("Restoring: %s\n", imagePath)
+	} else if opts.command == commandRandom {
+		// No source - fetch random wallpaper from slide.recipes
+		// Nothing to validate before elevation, so only the elevated process downloads
+		if isAdmin() {
+			season := activeSeason()
+			if season != nil && season.Source != "" {
+				// Seasonal pack replaces slide.recipes for its date range
+				imagePath, err = fetchSeasonalWallpaper(season)
+				if err == errFetchDeferred {
+					fmt.Println("Keeping the current wallpaper until the next run.")
+					os.Exit(0)
+				}
+				if err != nil {
+					fmt.Printf("Error fetching seasonal wallpaper: %v\n", err)
+					os.Exit(1)
+				}
+			} else if sources := chainSources(); len(sources) > 0 {
+				// The configured chain replaces slide.recipes
+				imagePath, err = fetchFromChain(sources)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+			} else {
+				prefetched, ok := takePrefetchedWallpaper()
+				if ok {
+					imagePath = prefetched
+				} else if fetchDeferred() {
+					fmt.Println("Keeping the current wallpaper until the next run.")
+					os.Exit(0)
+				} else {
+					randomURL, err := fetchRandomWallpaperURL()
+					if err != nil {
+						fmt.Printf("Error fetching random wallpaper: %v\n", err)
+						os.Exit(1)
+					}
+					imagePath, err = downloadImage(randomURL)
+					if err != nil {
+						fmt.Printf("Error downloading image: %v\n", err)
+						os.Exit(1)
+					}
+				}
+				attribution.Remove(imagePath)
+
+				// Fetch the next one while this one is applied so the next switch is instant
+				if !fetchDeferred() {
+					prefetchDone = startPrefetch()
+				}
+			}
+
+			imagePath = applySeasonTint(imagePath, season)
 		}
 	} else {
-		input := os.Args[1]
-		if isURL(input) {
+		input := opts.source
+		if source, ok := remoteSources[strings.ToLower(input)]; ok {
+			// Bing, APOD or Unsplash - like random mode, only the elevated process downloads
+			if isAdmin() {
+				imagePath, err = fetchFromRemoteSource(source)
+				if err != nil {
+					fmt.Printf("Error fetching %s image: %v\n", input, err)
+					os.Exit(1)
+				}
+			}
+		} else if isLibrary(input) {
+			// Pick a random image from a configured S3/Azure/WebDAV library
+			imagePath, err = fetchFromLibrary(input[len(libraryPrefix):])
+			if err != nil {
+				fmt.Printf("Error fetching from library: %v\n", err)
+				os.Exit(1)
+			}
+			attribution.Remove(imagePath)
+		} else if isURL(input) {
 			// Download the image from URL first (before elevation to validate URL)
 			imagePath, err = downloadImage(input)
 			if err != nil {
 				fmt.Printf("Error downloading image: %v\n", err)
 				os.Exit(1)
 			}
+			attribution.Remove(imagePath)
 		} else {
 			// Check if path exists before attempting elevation
 			info, err := os.Stat(input)
@@ -821,6 +1029,11 @@ func main() {
 			if info.IsDir() {
 				// If it's a directory, get a random image
 				imagePath, err = getRandomImage(input)
+				if errors.Is(err, errs.ErrNoImage) {
+					fmt.Printf("Error: %v\n", err)
+					fmt.Println("Supported formats: JPG, PNG, BMP, WebP, GIF, HEIC, AVIF (subfolders are searched too)")
+					os.Exit(1)
+				}
 				if err != nil {
 					fmt.Printf("Error: %v\n", err)
 					os.Exit(1)
@@ -836,24 +1049,20 @@ func main() {
 	}
 
 	// Check for admin privileges and elevate if needed
-	if !isAdmin() {
-		fmt.Println("Administrator privileges required for lock/login screen changes.")
-		fmt.Println("Requesting elevation via UAC...")
+	ensureAdmin(scheduled)
 
-		err := runElevated()
-		if err != nil {
-			fmt.Printf("Failed to elevate privileges: %v\n", err)
-			fmt.Println("\nPlease run this application as administrator manually:")
-			fmt.Println("  Right-click the executable and select 'Run as administrator'")
-			os.Exit(1)
-		}
+	// Decide up front which methods can work here
+	detectCapabilities()
 
-		// Exit the non-elevated process - the elevated one will continue
-		fmt.Println("Elevated process launched. This window can be closed.")
-		os.Exit(0)
+	// Windows can't use WebP, GIF, HEIC or AVIF wallpapers; downloads are converted already
+	imagePath, err = convertForWindows(imagePath, getDataDir(), "wallpaper_converted")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	fmt.Println("Running with administrator privileges.")
+	// Draw the photo credit onto the image when configured
+	imagePath = applyAttribution(imagePath)
 
 	// Track results for summary
 	desktopSuccess := false
@@ -861,77 +1070,113 @@ func main() {
 	loginScreenSuccess := false
 
 	// Set as desktop wallpaper
-	fmt.Println("\n========== DESKTOP WALLPAPER ==========")
-	err = setDesktopWallpaper(imagePath)
-	if err != nil {
-		fmt.Printf("Failed to set desktop wallpaper: %v\n", err)
-	} else {
-		fmt.Println("Desktop wallpaper set successfully!")
-		desktopSuccess = true
+	if opts.desktop {
+		fmt.Println("\n========== DESKTOP WALLPAPER ==========")
+		if opts.fit != "" {
+			if err := setWallpaperFit(opts.fit); err != nil {
+				fmt.Printf("Note: could not set the fit to %s: %v\n", opts.fit, err)
+			}
+		}
+		crossfadeTo(imagePath)
+		err = setDesktopWallpaper(imagePath)
+		if err != nil {
+			fmt.Printf("Failed to set desktop wallpaper: %v\n", err)
+		} else {
+			fmt.Println("Desktop wallpaper set successfully!")
+			desktopSuccess = true
+		}
 	}
 
 	// Set as lock screen wallpaper
-	fmt.Println("\n========== LOCK SCREEN WALLPAPER ==========")
-	fmt.Println("Attempting to set lock screen wallpaper...")
-	err = setLockScreenWallpaper(imagePath)
-	if err != nil {
-		fmt.Printf("Failed to set lock screen wallpaper: %v\n", err)
-	} else {
-		fmt.Println("Lock screen wallpaper setup completed!")
-		lockScreenSuccess = true
+	if opts.lockScreen {
+		fmt.Println("\n========== LOCK SCREEN WALLPAPER ==========")
+		fmt.Println("Attempting to set lock screen wallpaper...")
+		err = setLockScreenWallpaper(imagePath)
+		if err != nil {
+			fmt.Printf("Failed to set lock screen wallpaper: %v\n", err)
+			if errors.Is(err, errs.ErrMethodUnsupported) || errors.Is(err, errs.ErrAccessDenied) {
+				printTroubleshooting(err)
+			}
+		} else {
+			fmt.Println("Lock screen wallpaper setup completed!")
+			lockScreenSuccess = true
+		}
+
+		// Keep Spotlight text and widgets from covering the image (if configured)
+		applyLockScreenOverlays()
 	}
 
 	// Set as login screen background (sign-in screen)
-	fmt.Println("\n========== LOGIN SCREEN BACKGROUND ==========")
-	fmt.Println("Attempting to set login screen background using modern Windows APIs...")
-	err = setLoginScreenBackground(imagePath)
-	if err != nil {
-		fmt.Printf("Failed to set login screen background: %v\n", err)
-		fmt.Println("\nTroubleshooting:")
-		fmt.Println("- Ensure the image file is accessible and not corrupted")
-		fmt.Println("- Try a different image format (JPG usually works best)")
-		fmt.Println("- Some Windows editions may have limited customization options")
-	} else {
-		fmt.Println("Login screen background setup completed!")
-		loginScreenSuccess = true
-
-		// Invalidate the BgStatusService backup so it uses this new image
-		// This ensures the status overlay uses the new wallpaper as its base
-		err = loginscreen.InvalidateBackup()
+	if opts.loginScreen {
+		fmt.Println("\n========== LOGIN SCREEN BACKGROUND ==========")
+		fmt.Println("Attempting to set login screen background using modern Windows APIs...")
+		err = setLoginScreenBackground(imagePath)
 		if err != nil {
-			fmt.Printf("Note: Could not invalidate status service backup: %v\n", err)
+			fmt.Printf("Failed to set login screen background: %v\n", err)
+			printTroubleshooting(err)
 		} else {
-			fmt.Println("BgStatusService backup invalidated (will use new image on next boot)")
+			fmt.Println("Login screen background setup completed!")
+			loginScreenSuccess = true
+
+			// Invalidate the BgStatusService backup so it uses this new image
+			// This ensures the status overlay uses the new wallpaper as its base
+			err = loginscreen.InvalidateBackup()
+			if err != nil {
+				fmt.Printf("Note: Could not invalidate status service backup: %v\n", err)
+			} else {
+				fmt.Println("BgStatusService backup invalidated (will use new image on next boot)")
+			}
 		}
 	}
 
-	// Summary
+	// Remember what was applied so "bgchanger verify" can detect drift
+	err = recordApplied(imagePath, desktopSuccess, lockScreenSuccess, loginScreenSuccess)
+	if err != nil {
+		fmt.Printf("Note: Could not record the applied image: %v\n", err)
+	}
+
+	// Summary of the surfaces this run changed
 	fmt.Println("\n========== SUMMARY ==========")
-	if desktopSuccess {
-		fmt.Println("[OK] Desktop wallpaper: SUCCESS")
-	} else {
-		fmt.Println("[X]  Desktop wallpaper: FAILED")
+	if opts.desktop {
+		if desktopSuccess {
+			fmt.Println("[OK] Desktop wallpaper: SUCCESS")
+		} else {
+			fmt.Println("[X]  Desktop wallpaper: FAILED")
+		}
 	}
 
-	if lockScreenSuccess {
-		fmt.Println("[OK] Lock screen wallpaper: SUCCESS")
-	} else {
-		fmt.Println("[X]  Lock screen wallpaper: FAILED")
+	if opts.lockScreen {
+		if lockScreenSuccess {
+			fmt.Println("[OK] Lock screen wallpaper: SUCCESS")
+		} else {
+			fmt.Println("[X]  Lock screen wallpaper: FAILED")
+		}
 	}
 
-	if loginScreenSuccess {
-		fmt.Println("[OK] Login screen background: SUCCESS")
-	} else {
-		fmt.Println("[X]  Login screen background: FAILED")
+	if opts.loginScreen {
+		if loginScreenSuccess {
+			fmt.Println("[OK] Login screen background: SUCCESS")
+		} else {
+			fmt.Println("[X]  Login screen background: FAILED")
+		}
 	}
 
 	fmt.Println("\nTo see all changes:")
-	fmt.Println("- Desktop: Changes should be visible immediately")
-	fmt.Println("- Lock screen: Press Win+L to lock and see changes")
-	fmt.Println("- Login screen: Sign out or restart to see changes")
+	if opts.desktop {
+		fmt.Println("- Desktop: Changes should be visible immediately")
+	}
+	if opts.lockScreen {
+		fmt.Println("- Lock screen: Press Win+L to lock and see changes")
+	}
+	if opts.loginScreen {
+		fmt.Println("- Login screen: Sign out or restart to see changes")
+	}
+
+	waitForPrefetch(prefetchDone)
 
 	// Keep window open if any failures occurred
-	if !desktopSuccess || !lockScreenSuccess || !loginScreenSuccess {
+	failed := (opts.desktop && !desktopSuccess) || (opts.lockScreen && !lockScreenSuccess) || (opts.loginScreen && !loginScreenSuccess)
+	if !scheduled && failed {
 		fmt.Println("\nPress Enter to exit...")
 		fmt.Scanln()
 	}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/attribution"
+)
+
+// monitorAssignment is one --monitor N SOURCE pair of set.
+type monitorAssignment struct {
+	number int
+	source string
+}
+
+// runMonitors lists the monitors and their wallpapers, as JSON with --json.
+func runMonitors(args []string) error {
+	asJSON := false
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+		}
+	}
+
+	var monitors []monitor
+	err := withDesktopWallpaper(func(d *desktopWallpaper) error {
+		var err error
+		monitors, err = d.monitors()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(monitors, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode monitors: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, m := range monitors {
+		if m.Attached {
+			fmt.Printf("Monitor %d: %dx%d at (%d, %d)\n", m.Number, m.Width, m.Height, m.X, m.Y)
+		} else {
+			fmt.Printf("Monitor %d: not attached\n", m.Number)
+		}
+		fmt.Printf("  ID:        %s\n", m.ID)
+		if m.Wallpaper != "" {
+			fmt.Printf("  Wallpaper: %s\n", m.Wallpaper)
+		}
+	}
+	fmt.Println("\nSet one with: bgchanger set --monitor <number> <image>")
+	return nil
+}
+
+// applyMonitorWallpapers resolves the source of each monitor and sets it as
+// that monitor's wallpaper, placed as fit if given. It returns whether every
+// monitor was set.
+func applyMonitorWallpapers(assignments []monitorAssignment, fit string) bool {
+	// Journaled like the single wallpaper, so undo-system-changes puts back the
+	// image Windows falls back to
+	recordRegistry(registry.CURRENT_USER, `Control Panel\Desktop`, "Wallpaper")
+
+	ok := true
+	err := withDesktopWallpaper(func(d *desktopWallpaper) error {
+		monitors, err := d.monitors()
+		if err != nil {
+			return err
+		}
+		// The fit applies to all monitors alike
+		if fit != "" {
+			recordRegistry(registry.CURRENT_USER, `Control Panel\Desktop`, "WallpaperStyle", "TileWallpaper")
+			if err := d.setPosition(wallpaperFits[fit].position); err != nil {
+				fmt.Printf("Note: could not set the fit to %s: %v\n", fit, err)
+			}
+		}
+		for _, a := range assignments {
+			fmt.Printf("\n========== MONITOR %d ==========\n", a.number)
+			if a.number > len(monitors) {
+				fmt.Printf("[X]  There is no monitor %d (%d found, see 'bgchanger monitors')\n", a.number, len(monitors))
+				ok = false
+				continue
+			}
+			m := monitors[a.number-1]
+			if !m.Attached {
+				fmt.Printf("Note: monitor %d is not attached; Windows uses the image when it is\n", a.number)
+			}
+
+			imagePath, err := resolveMonitorSource(a)
+			if err == nil {
+				err = d.setWallpaper(m.ID, imagePath)
+			}
+			if err != nil {
+				fmt.Printf("[X]  Monitor %d: FAILED - %v\n", a.number, err)
+				ok = false
+				continue
+			}
+			fmt.Printf("[OK] Monitor %d: %s\n", a.number, imagePath)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Failed to set per-monitor wallpapers: %v\n", err)
+		return false
+	}
+	return ok
+}
+
+// resolveMonitorSource turns a --monitor source into a local image. Downloaded
+// images are kept as monitor<N> in the data directory, so the monitors don't
+// overwrite each other's file. Photo credits are not drawn on per-monitor
+// images.
+func resolveMonitorSource(a monitorAssignment) (string, error) {
+	input := a.source
+	baseName := fmt.Sprintf("monitor%d", a.number)
+
+	var downloaded string
+	var err error
+	if source, ok := remoteSources[strings.ToLower(input)]; ok {
+		downloaded, err = fetchFromRemoteSource(source)
+	} else if isLibrary(input) {
+		downloaded, err = fetchFromLibrary(input[len(libraryPrefix):])
+	} else if isURL(input) {
+		downloaded, err = downloadImageTo(input, getDataDir(), baseName)
+		if err == nil {
+			attribution.Remove(downloaded)
+		}
+		return downloaded, err
+	} else {
+		info, err := os.Stat(input)
+		if err != nil {
+			return "", err
+		}
+		local := input
+		if info.IsDir() {
+			local, err = getRandomImage(input)
+			if err != nil {
+				return "", err
+			}
+		} else if !isImage(input) {
+			return "", fmt.Errorf("%s is not a supported image file", input)
+		}
+		local, err = filepath.Abs(local)
+		if err != nil {
+			return "", err
+		}
+		return convertForWindows(local, getDataDir(), baseName)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The remote and library fetchers reuse one file for every download
+	dest := filepath.Join(getDataDir(), baseName+filepath.Ext(downloaded))
+	data, err := os.ReadFile(downloaded)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	return dest, nil
+}
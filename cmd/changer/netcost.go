@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/netcost"
+)
+
+// errFetchDeferred is returned when a non-essential download is postponed
+var errFetchDeferred = errors.New("download deferred")
+
+// fetchDeferredReason caches the metered/battery decision for this run
+var fetchDeferredReason *string
+
+// fetchDeferred reports whether non-essential downloads should wait (metered
+// connection or low battery, per fetch_policy in the config)
+func fetchDeferred() bool {
+	if fetchDeferredReason == nil {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Printf("Note: %v\n", err)
+		}
+		_, reason := netcost.ShouldDefer(cfg.FetchPolicy)
+		fetchDeferredReason = &reason
+		if reason != "" {
+			fmt.Printf("Deferring non-essential downloads: %s\n", reason)
+		}
+	}
+	return *fetchDeferredReason != ""
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+)
+
+// applyLockScreenOverlays disables or restores the Spotlight/tips/widgets overlays
+// according to lock_screen.disable_overlays in the config
+func applyLockScreenOverlays() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Note: %v\n", err)
+	}
+
+	if !cfg.LockScreen.DisableOverlays {
+		// Put back settings disabled by an earlier run
+		if loginscreen.HasDisabledLockScreenOverlays() {
+			restored, err := loginscreen.RestoreLockScreenOverlays()
+			if err != nil {
+				fmt.Printf("Note: could not restore lock screen overlays: %v\n", err)
+			} else {
+				fmt.Printf("Restored %d lock screen overlay settings\n", len(restored))
+			}
+			return
+		}
+		if caps != nil && caps.Overlays.Any() {
+			fmt.Println("Note: Spotlight, tips or widgets may cover the lock screen image")
+			fmt.Println("      (set lock_screen.disable_overlays in the config to turn them off)")
+		}
+		return
+	}
+
+	if caps != nil && !caps.Overlays.Any() {
+		return
+	}
+
+	build := 0
+	if caps != nil {
+		build = caps.OS.Build
+	}
+	err = loginscreen.DisableLockScreenOverlays(build)
+	if err != nil {
+		fmt.Printf("Note: could not disable lock screen overlays: %v\n", err)
+		return
+	}
+	fmt.Println("Disabled Spotlight, tips and widgets on the lock screen")
+}
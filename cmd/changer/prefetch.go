@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Register decoders used to validate prefetched images
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+
+	"github.com/backgroundchanger/internal/loginscreen"
+)
+
+// Prefetched images live in a managed cache directory. The pointer file records the
+// image that the next random run should use instead of downloading on the spot.
+const (
+	cacheDirName       = "cache"
+	prefetchPointer    = "next.json"
+	prefetchWaitLimit  = 2 * time.Minute
+	prefetchMaxAge     = 7 * 24 * time.Hour
+	prefetchedBaseName = "next"
+)
+
+// prefetchedImage describes an image downloaded ahead of time
+type prefetchedImage struct {
+	Path      string    `json:"path"`
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// getCacheDir returns the managed cache directory for prefetched images
+func getCacheDir() string {
+	return filepath.Join(getDataDir(), cacheDirName)
+}
+
+// validateImage checks that a file decodes as a supported image within the
+// configured size and dimension limits
+func validateImage(path string) error {
+	return loginscreen.CheckImageFile(path, imageLimits())
+}
+
+// prefetchNextWallpaper downloads and validates the next random wallpaper into the
+// managed cache and records it for the next run. It is safe to run in a goroutine.
+func prefetchNextWallpaper() error {
+	imageURL, err := fetchRandomWallpaperURL()
+	if err != nil {
+		return err
+	}
+
+	// Download under a temporary name so a half-finished prefetch is never used
+	path, err := downloadImageTo(imageURL, getCacheDir(), fmt.Sprintf("%s_%d", prefetchedBaseName, time.Now().UnixNano()))
+	if err != nil {
+		return err
+	}
+
+	err = validateImage(path)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	data, err := json.MarshalIndent(prefetchedImage{Path: path, URL: imageURL, FetchedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replace any previously prefetched image
+	if previous, ok := readPrefetchPointer(); ok && previous.Path != path {
+		os.Remove(previous.Path)
+	}
+	return os.WriteFile(filepath.Join(getCacheDir(), prefetchPointer), data, 0644)
+}
+
+// readPrefetchPointer loads the pointer to the prefetched image, if any
+func readPrefetchPointer() (prefetchedImage, bool) {
+	var next prefetchedImage
+	data, err := os.ReadFile(filepath.Join(getCacheDir(), prefetchPointer))
+	if err != nil {
+		return next, false
+	}
+	if json.Unmarshal(data, &next) != nil || next.Path == "" {
+		return next, false
+	}
+	return next, true
+}
+
+// takePrefetchedWallpaper moves a previously prefetched image into place and returns its
+// path. Returns false if there is nothing usable in the cache.
+func takePrefetchedWallpaper() (string, bool) {
+	next, ok := readPrefetchPointer()
+	if !ok {
+		return "", false
+	}
+	os.Remove(filepath.Join(getCacheDir(), prefetchPointer))
+
+	if time.Since(next.FetchedAt) > prefetchMaxAge || validateImage(next.Path) != nil {
+		os.Remove(next.Path)
+		return "", false
+	}
+
+	// Move into the same stable location a direct download would use
+	dest := filepath.Join(getDataDir(), "wallpaper"+filepath.Ext(next.Path))
+	os.Remove(dest)
+	err := os.Rename(next.Path, dest)
+	if err != nil {
+		return "", false
+	}
+
+	fmt.Printf("Using prefetched wallpaper: %s\n", next.URL)
+	return dest, true
+}
+
+// startPrefetch begins downloading the next wallpaper in the background.
+// The returned channel receives the result once the prefetch finishes.
+func startPrefetch() <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- prefetchNextWallpaper()
+	}()
+	return done
+}
+
+// waitForPrefetch waits (bounded) for a background prefetch to finish before exit
+func waitForPrefetch(done <-chan error) {
+	if done == nil {
+		return
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			fmt.Printf("Note: could not prefetch next wallpaper: %v\n", err)
+		} else {
+			fmt.Println("Next wallpaper prefetched.")
+		}
+	case <-time.After(prefetchWaitLimit):
+		fmt.Println("Note: prefetch of next wallpaper timed out")
+	}
+}
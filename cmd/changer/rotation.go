@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/backgroundchanger/internal/installer"
+)
+
+// runInstallRotation creates the scheduled task that runs bgchanger at logon and
+// every interval: install-rotation [--every 1h] [source]
+func runInstallRotation(args []string) error {
+	interval := installer.DefaultRotationInterval
+	source := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--every" && i+1 < len(args) {
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid interval %q: %w", args[i+1], err)
+			}
+			interval = d
+			i++
+			continue
+		}
+		source = args[i]
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	err = installer.InstallRotationTask(exe, interval, source)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created scheduled task %s\n", installer.ScheduledTaskNameRotation)
+	fmt.Printf("- Runs %s at logon and every %v\n", installer.GetInstalledChangerPath(), interval)
+	if source != "" {
+		fmt.Printf("- Source: %s\n", source)
+	} else {
+		fmt.Println("- Source: random wallpapers (or the active season from the config)")
+	}
+	fmt.Println("Remove it with 'bgchanger uninstall-rotation'.")
+	return nil
+}
+
+// runUninstallRotation removes the rotation task
+func runUninstallRotation() error {
+	if !installer.RotationTaskExists() {
+		fmt.Println("The rotation task is not installed.")
+		return nil
+	}
+	installer.DeleteRotationTask()
+	if installer.RotationTaskExists() {
+		return fmt.Errorf("failed to remove scheduled task %s", installer.ScheduledTaskNameRotation)
+	}
+	fmt.Printf("Removed scheduled task %s\n", installer.ScheduledTaskNameRotation)
+	return nil
+}
+
+// hideConsole hides the console window when it belongs to this process alone,
+// so runs of the rotation task don't flash a window in front of the user. A
+// console shared with a shell is left alone.
+func hideConsole() {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+
+	var pids [2]uint32
+	count, _, _ := kernel32.NewProc("GetConsoleProcessList").Call(uintptr(unsafe.Pointer(&pids[0])), uintptr(len(pids)))
+	if count != 1 {
+		return
+	}
+
+	hwnd, _, _ := kernel32.NewProc("GetConsoleWindow").Call()
+	if hwnd == 0 {
+		return
+	}
+	syscall.NewLazyDLL("user32.dll").NewProc("ShowWindow").Call(hwnd, 0) // SW_HIDE
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/backgroundchanger/internal/safety"
+)
+
+var (
+	gate     *safety.Gate
+	gateOnce sync.Once
+)
+
+// safetyGate returns the content safety gate built from config and machine policy.
+// It is loaded once and shared by the foreground download and the prefetcher.
+func safetyGate() *safety.Gate {
+	gateOnce.Do(func() {
+		var err error
+		gate, err = safety.Load()
+		if err != nil {
+			fmt.Printf("Note: %v\n", err)
+		}
+	})
+	return gate
+}
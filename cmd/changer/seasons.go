@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/attribution"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+)
+
+// activeSeason returns the season configured for today, or nil
+func activeSeason() *config.SeasonConfig {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Note: %v\n", err)
+	}
+
+	season := cfg.ActiveSeason(time.Now())
+	if season != nil {
+		fmt.Printf("Active season: %s (%s to %s)\n", season.Name, season.From, season.To)
+	}
+	return season
+}
+
+// fetchSeasonalWallpaper picks an image from the season's source instead of slide.recipes
+func fetchSeasonalWallpaper(season *config.SeasonConfig) (string, error) {
+	imagePath, err := fetchSource(season.Source)
+	if err != nil && err != errFetchDeferred {
+		return "", fmt.Errorf("season %s source: %w", season.Name, err)
+	}
+	return imagePath, err
+}
+
+// fetchSource resolves a source as given on the command line (bing, apod,
+// unsplash, library:<name>, a URL, a folder or an image file) to a local image.
+// Downloads return errFetchDeferred on metered connections and low battery.
+func fetchSource(source string) (string, error) {
+	// Downloads wait on metered connections and low battery; local folders don't
+	isRemote := remoteSources[strings.ToLower(source)] != nil || isLibrary(source) || isURL(source)
+	if isRemote && fetchDeferred() {
+		return "", errFetchDeferred
+	}
+
+	if remote, ok := remoteSources[strings.ToLower(source)]; ok {
+		return fetchFromRemoteSource(remote)
+	}
+
+	if isLibrary(source) {
+		imagePath, err := fetchFromLibrary(source[len(libraryPrefix):])
+		if err == nil {
+			attribution.Remove(imagePath)
+		}
+		return imagePath, err
+	}
+
+	if isURL(source) {
+		imagePath, err := downloadImage(source)
+		if err == nil {
+			attribution.Remove(imagePath)
+		}
+		return imagePath, err
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return source, nil
+	}
+
+	imagePath, err := getRandomImage(source)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Selected image: %s\n", imagePath)
+	return imagePath, nil
+}
+
+// applySeasonTint blends the season's tint color over a copy of the image and
+// returns the path of the tinted copy (or the original path if no tint applies)
+func applySeasonTint(imagePath string, season *config.SeasonConfig) string {
+	if season == nil || season.Tint == "" {
+		return imagePath
+	}
+
+	tint, err := overlay.ParseHexColor(season.Tint)
+	if err != nil {
+		fmt.Printf("Note: season %s: %v\n", season.Name, err)
+		return imagePath
+	}
+
+	strength := season.TintStrength
+	if strength <= 0 {
+		strength = overlay.DefaultTintStrength
+	}
+
+	img, err := loginscreen.LoadImage(imagePath, imageLimits())
+	if err != nil {
+		fmt.Printf("Note: could not load image for tint: %v\n", err)
+		return imagePath
+	}
+
+	tintedPath := filepath.Join(getDataDir(), "wallpaper_tinted.jpg")
+	err = loginscreen.SaveImage(overlay.Tint(img, tint, strength), tintedPath)
+	if err != nil {
+		fmt.Printf("Note: could not save tinted image: %v\n", err)
+		return imagePath
+	}
+
+	// Carry the credit over so it can still be drawn on the tinted copy
+	if credit, err := attribution.Load(imagePath); err == nil {
+		attribution.Save(tintedPath, credit)
+	} else {
+		attribution.Remove(tintedPath)
+	}
+
+	fmt.Printf("Applied %s tint (%s)\n", season.Name, season.Tint)
+	return tintedPath
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/loginscreen"
+)
+
+// runSlideshow registers a folder as the current user's lock screen slideshow,
+// or with --off puts back the previous slideshow settings: slideshow <folder>|--off
+func runSlideshow(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: bgchanger slideshow <folder> | --off")
+	}
+
+	if args[0] == "--off" {
+		restored, err := loginscreen.RestoreLockScreenSlideshow()
+		if err != nil {
+			return fmt.Errorf("failed to restore slideshow settings: %w", err)
+		}
+		fmt.Printf("Restored %d lock screen slideshow settings\n", len(restored))
+		return nil
+	}
+
+	folder := args[0]
+	err := loginscreen.SetLockScreenSlideshow(folder)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Lock screen slideshow set to %s\n", folder)
+
+	// The build only matters for widgets
+	if capability.DetectLockScreenOverlays(0).Spotlight {
+		fmt.Println("Note: Windows Spotlight is on and takes precedence over the slideshow")
+		fmt.Println("      (set lock_screen.disable_overlays in the config to turn it off)")
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/attribution"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+)
+
+// remoteSource fetches the URL of an image from a public wallpaper service along
+// with its attribution metadata
+type remoteSource func(cfg *config.Config) (string, *attribution.Attribution, error)
+
+// remoteSources maps the CLI keyword to the service it fetches from
+var remoteSources = map[string]remoteSource{
+	"bing":     fetchBingImage,
+	"apod":     fetchAPODImage,
+	"unsplash": fetchUnsplashImage,
+}
+
+// Public API endpoints for the supported wallpaper services
+const (
+	bingArchiveURL = "https://www.bing.com/HPImageArchive.aspx?format=js&idx=0&n=1&mkt=en-US"
+	bingBaseURL    = "https://www.bing.com"
+	apodURL        = "https://api.nasa.gov/planetary/apod"
+	unsplashURL    = "https://api.unsplash.com/photos/random?orientation=landscape"
+)
+
+// getJSON performs a GET request and decodes the JSON response into v
+func getJSON(reqURL string, header http.Header, v interface{}) error {
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d from %s", resp.StatusCode, req.URL.Host)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// fetchBingImage returns today's Bing homepage image
+func fetchBingImage(cfg *config.Config) (string, *attribution.Attribution, error) {
+	fmt.Println("Fetching Bing image of the day...")
+
+	var archive struct {
+		Images []struct {
+			URL           string `json:"url"`
+			Title         string `json:"title"`
+			Copyright     string `json:"copyright"`
+			CopyrightLink string `json:"copyrightlink"`
+		} `json:"images"`
+	}
+	err := getJSON(bingArchiveURL, nil, &archive)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(archive.Images) == 0 {
+		return "", nil, fmt.Errorf("Bing returned no images")
+	}
+
+	img := archive.Images[0]
+
+	// Copyright looks like "Some place, Country (© Photographer/Agency)"
+	title, author := img.Copyright, ""
+	if i := strings.LastIndex(img.Copyright, "(©"); i >= 0 {
+		title = strings.TrimSpace(img.Copyright[:i])
+		author = strings.TrimSpace(strings.TrimSuffix(img.Copyright[i+len("(©"):], ")"))
+	}
+	if img.Title != "" && title == "" {
+		title = img.Title
+	}
+
+	return bingBaseURL + img.URL, &attribution.Attribution{
+		Title:   title,
+		Author:  author,
+		License: "© " + firstNonEmpty(author, "Bing"),
+		Source:  "Bing",
+		URL:     img.CopyrightLink,
+	}, nil
+}
+
+// fetchAPODImage returns NASA's Astronomy Picture of the Day
+func fetchAPODImage(cfg *config.Config) (string, *attribution.Attribution, error) {
+	fmt.Println("Fetching NASA Astronomy Picture of the Day...")
+
+	apiKey := firstNonEmpty(cfg.APODAPIKey, "DEMO_KEY")
+
+	var apod struct {
+		Title     string `json:"title"`
+		URL       string `json:"url"`
+		HDURL     string `json:"hdurl"`
+		MediaType string `json:"media_type"`
+		Copyright string `json:"copyright"`
+		Date      string `json:"date"`
+	}
+	err := getJSON(apodURL+"?api_key="+apiKey, nil, &apod)
+	if err != nil {
+		return "", nil, err
+	}
+	if apod.MediaType != "image" {
+		return "", nil, fmt.Errorf("today's APOD is a %s, not an image", apod.MediaType)
+	}
+
+	// Images without a copyright field are NASA works in the public domain
+	license := "Public domain (NASA)"
+	author := strings.TrimSpace(apod.Copyright)
+	if author != "" {
+		license = "© " + author
+	}
+
+	return firstNonEmpty(apod.HDURL, apod.URL), &attribution.Attribution{
+		Title:   apod.Title,
+		Author:  author,
+		License: license,
+		Source:  "NASA APOD",
+		URL:     "https://apod.nasa.gov/apod/ap" + strings.ReplaceAll(strings.TrimPrefix(apod.Date, "20"), "-", "") + ".html",
+	}, nil
+}
+
+// fetchUnsplashImage returns a random landscape photo from Unsplash
+func fetchUnsplashImage(cfg *config.Config) (string, *attribution.Attribution, error) {
+	if cfg.UnsplashAccessKey == "" {
+		return "", nil, fmt.Errorf("unsplash_access_key is not set in %s", config.Path())
+	}
+	fmt.Println("Fetching random photo from Unsplash...")
+
+	header := http.Header{}
+	header.Set("Authorization", "Client-ID "+cfg.UnsplashAccessKey)
+	header.Set("Accept-Version", "v1")
+
+	var photo struct {
+		Description    string `json:"description"`
+		AltDescription string `json:"alt_description"`
+		URLs           struct {
+			Full string `json:"full"`
+		} `json:"urls"`
+		Links struct {
+			HTML             string `json:"html"`
+			DownloadLocation string `json:"download_location"`
+		} `json:"links"`
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	err := getJSON(unsplashURL, header, &photo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Unsplash API guidelines require triggering the download endpoint when a photo is used
+	if photo.Links.DownloadLocation != "" {
+		var ignored map[string]interface{}
+		getJSON(photo.Links.DownloadLocation, header, &ignored)
+	}
+
+	return photo.URLs.Full, &attribution.Attribution{
+		Title:   firstNonEmpty(photo.Description, photo.AltDescription),
+		Author:  photo.User.Name,
+		License: "Unsplash License",
+		Source:  "Unsplash",
+		URL:     photo.Links.HTML,
+	}, nil
+}
+
+// firstNonEmpty returns the first value that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// fetchFromRemoteSource downloads an image from a public wallpaper service and
+// saves its attribution sidecar next to it
+func fetchFromRemoteSource(source remoteSource) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Note: %v\n", err)
+	}
+
+	imageURL, credit, err := source(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	imagePath, err := downloadImage(imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	if credit != nil {
+		fmt.Printf("Credit: %s\n", credit.Line())
+		err = attribution.Save(imagePath, credit)
+		if err != nil {
+			fmt.Printf("Note: could not save attribution: %v\n", err)
+		}
+	}
+	return imagePath, nil
+}
+
+// applyAttribution draws the image's credit line onto a copy of it when enabled in
+// config, and returns the path of the image that should be applied
+func applyAttribution(imagePath string) string {
+	cfg, err := config.Load()
+	if err != nil || !cfg.Attribution.Show {
+		return imagePath
+	}
+
+	credit, err := attribution.Load(imagePath)
+	if err != nil || credit.Line() == "" {
+		return imagePath
+	}
+
+	img, err := loginscreen.LoadImage(imagePath, cfg.ImageLimits)
+	if err != nil {
+		fmt.Printf("Note: could not load image for attribution: %v\n", err)
+		return imagePath
+	}
+
+	credited, err := overlay.RenderAttribution(img, credit.Line(), cfg.Attribution.Corner)
+	if err != nil {
+		fmt.Printf("Note: could not render attribution: %v\n", err)
+		return imagePath
+	}
+
+	creditedPath := filepath.Join(getDataDir(), "wallpaper_credited.jpg")
+	err = loginscreen.SaveImage(credited, creditedPath)
+	if err != nil {
+		fmt.Printf("Note: could not save credited image: %v\n", err)
+		return imagePath
+	}
+
+	// Keep the metadata next to the file Windows actually references
+	attribution.Save(creditedPath, credit)
+
+	fmt.Println("Attribution line added to wallpaper")
+	return creditedPath
+}
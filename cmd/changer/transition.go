@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// transitionDirName holds the blended frames while a crossfade runs.
+const transitionDirName = "transition"
+
+// crossfadeTo fades the desktop from the current wallpaper towards imagePath
+// when transition.crossfade is configured, by showing blended frames in quick
+// succession. The frames are set without SPIF_UPDATEINIFILE, so only the final
+// setDesktopWallpaper is persisted; the caller sets imagePath itself
+// afterwards. Any problem skips the fade.
+func crossfadeTo(imagePath string) {
+	cfg, err := config.Load()
+	if err != nil || !cfg.Transition.Crossfade {
+		return
+	}
+	t := cfg.Transition
+
+	from, err := currentDesktopWallpaper()
+	if err != nil {
+		return
+	}
+	// Windows paths are case-insensitive; fading an image into itself is skipped
+	if to, err := filepath.Abs(imagePath); err == nil && strings.EqualFold(filepath.Clean(to), filepath.Clean(from)) {
+		return
+	}
+
+	// Frames match how Windows places the final image, which only fill and
+	// stretch do without borders or tiling
+	stretch := false
+	switch desktopWallpaperStyle() {
+	case "10":
+	case "2":
+		stretch = true
+	default:
+		fmt.Println("Note: crossfade needs the fill or stretch fit; switching without it")
+		return
+	}
+
+	res := sysinfo.GetDisplayResolution()
+	frames, err := renderTransition(from, imagePath, res, t.FrameCount(), stretch, cfg.ImageLimits)
+	defer os.RemoveAll(filepath.Join(getDataDir(), transitionDirName))
+	if err != nil {
+		fmt.Printf("Note: could not prepare the crossfade: %v\n", err)
+		return
+	}
+
+	step := t.FadeDuration() / time.Duration(len(frames)+1)
+	for _, frame := range frames {
+		start := time.Now()
+		if err := winapi.SPI.SetString(SPI_SETDESKWALLPAPER, frame, SPIF_SENDCHANGE); err != nil {
+			return
+		}
+		// Applying a frame can take longer than a step on slow machines
+		if wait := step - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
+
+// desktopWallpaperStyle returns the WallpaperStyle value, "10" (fill) when it
+// is unset.
+func desktopWallpaperStyle() string {
+	key, err := winapi.Reg.OpenKey(registry.CURRENT_USER, `Control Panel\Desktop`, registry.QUERY_VALUE)
+	if err != nil {
+		return "10"
+	}
+	defer key.Close()
+	style, _, err := key.GetStringValue("WallpaperStyle")
+	if err != nil || style == "" {
+		return "10"
+	}
+	if tile, _, err := key.GetStringValue("TileWallpaper"); err == nil && tile == "1" {
+		return "tile"
+	}
+	return style
+}
+
+// renderTransition writes count frames blending from into to at the screen
+// resolution and returns their paths in order.
+func renderTransition(from, to string, res sysinfo.DisplayResolution, count int, stretch bool, limits config.ImageLimitsConfig) ([]string, error) {
+	start, err := screenImage(from, res, stretch, limits)
+	if err != nil {
+		return nil, err
+	}
+	end, err := screenImage(to, res, stretch, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(getDataDir(), transitionDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	frame := image.NewRGBA(start.Bounds())
+	paths := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		blend(frame, start, end, float64(i)/float64(count+1))
+		path := filepath.Join(dir, fmt.Sprintf("frame%02d.jpg", i))
+		if err := loginscreen.SaveImage(frame, path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// screenImage loads an image scaled to the screen as the fill fit (cropped to
+// the screen's aspect ratio) or stretch fit shows it.
+func screenImage(path string, res sysinfo.DisplayResolution, stretch bool, limits config.ImageLimitsConfig) (*image.RGBA, error) {
+	img, err := loginscreen.LoadImage(path, limits)
+	if err != nil {
+		return nil, err
+	}
+	if !stretch {
+		return loginscreen.ResizeCover(img, res.Width, res.Height), nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, res.Width, res.Height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst, nil
+}
+
+// blend sets dst to a mix of from and to, t being the share of to.
+func blend(dst, from, to *image.RGBA, t float64) {
+	weight := uint32(t * 256)
+	for i := range dst.Pix {
+		a, b := uint32(from.Pix[i]), uint32(to.Pix[i])
+		dst.Pix[i] = uint8((a*(256-weight) + b*weight) >> 8)
+	}
+}
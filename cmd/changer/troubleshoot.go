@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/backgroundchanger/internal/errs"
+)
+
+// printTroubleshooting prints hints for a failed lock or login screen change,
+// based on the kind of error.
+func printTroubleshooting(err error) {
+	fmt.Println("\nTroubleshooting:")
+	switch {
+	case errors.Is(err, errs.ErrMethodUnsupported):
+		fmt.Println("- None of the methods work on this Windows edition or build")
+		fmt.Println("- Run 'bgchanger capabilities' to see why each method was skipped")
+	case errors.Is(err, errs.ErrAccessDenied):
+		fmt.Println("- Windows denied access to a file or registry key")
+		fmt.Println("- Check that Group Policy or security software isn't locking the setting")
+		fmt.Println("- On managed devices the background may be set by your organization")
+	case errors.Is(err, errs.ErrElevationRequired):
+		fmt.Println("- Administrator privileges are required")
+		fmt.Println("- Right-click the executable and select 'Run as administrator'")
+	default:
+		fmt.Println("- Ensure the image file is accessible and not corrupted")
+		fmt.Println("- Try a different image format (JPG usually works best)")
+		fmt.Println("- Some Windows editions may have limited customization options")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/journal"
+)
+
+// recordRegistry journals the previous state of registry values before they are modified.
+// Failures only mean the change can't be undone later, so they don't stop the change.
+func recordRegistry(root registry.Key, path string, names ...string) {
+	err := journal.Record(root, path, names...)
+	if err != nil {
+		fmt.Printf("Note: could not journal registry change: %v\n", err)
+	}
+}
+
+// runUndoSystemChanges replays the registry journal to revert the machine
+func runUndoSystemChanges() error {
+	if !journal.Exists() {
+		fmt.Println("No recorded system changes to undo.")
+		return nil
+	}
+
+	// Most journaled values are under HKLM
+	if !isAdmin() {
+		fmt.Println("Administrator privileges required to undo system changes.")
+		fmt.Println("Requesting elevation via UAC...")
+		err := runElevated()
+		if err != nil {
+			return fmt.Errorf("failed to elevate privileges: %w", err)
+		}
+		fmt.Println("Elevated process launched. This window can be closed.")
+		os.Exit(0)
+	}
+
+	reverted, err := journal.Undo()
+	for _, value := range reverted {
+		fmt.Printf("Restored %s\n", value)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nReverted %d registry values.\n", len(reverted))
+	fmt.Println("Sign out or restart to see the original backgrounds.")
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/installer"
+)
+
+// version is the bgchanger release version, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// runUpdate implements `bgchanger update [--check-only] [--force]`: it compares our version
+// with the latest GitHub release and, unless --check-only is given, downloads, verifies and
+// swaps in the new executable before relaunching it. The download waits on metered
+// connections, low battery and a staged rollout that has not reached this machine unless
+// --force is given.
+func runUpdate(args []string) error {
+	checkOnly := false
+	force := false
+	for _, arg := range args {
+		switch arg {
+		case "--check-only", "-n":
+			checkOnly = true
+		case "--force", "-f":
+			force = true
+		default:
+			return fmt.Errorf("unknown update option: %s", arg)
+		}
+	}
+
+	fmt.Printf("Current version: %s\n", version)
+	fmt.Println("Checking for updates...")
+
+	release, err := installer.GetLatestRelease()
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	fmt.Printf("Latest version:  %s\n", release.TagName)
+
+	if installer.CompareVersions(version, release.TagName) >= 0 {
+		fmt.Println("bgchanger is up to date.")
+		return nil
+	}
+
+	// --force only skips the wait; a rollout.json that cannot be read or
+	// verified always holds the update back
+	waiting, err := rolloutWaiting(release)
+	if err != nil {
+		return err
+	}
+
+	if checkOnly {
+		fmt.Printf("Update available: %s -> %s\n", version, release.TagName)
+		if waiting {
+			fmt.Println("The rollout has not reached this machine yet.")
+		} else {
+			fmt.Println("Run 'bgchanger update' to install it.")
+		}
+		return nil
+	}
+
+	if waiting && !force {
+		fmt.Printf("Update available: %s -> %s\n", version, release.TagName)
+		fmt.Println("Run 'bgchanger update --force' to take it before the rollout reaches this machine.")
+		return nil
+	}
+
+	if !force && fetchDeferred() {
+		fmt.Printf("Update available: %s -> %s\n", version, release.TagName)
+		fmt.Println("Run 'bgchanger update --force' to download it anyway.")
+		return nil
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	// Download next to the current executable so the final swap is a plain rename
+	newPath := installer.UpdateTempPath(exePath)
+	fmt.Printf("Downloading %s...\n", release.TagName)
+	assetName := installer.ReleaseAssetName(release, installer.ChangerExeName)
+	sourceURL, err := installer.DownloadReleaseAsset(release, assetName, newPath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download update: %w", err)
+	}
+	fmt.Printf("Downloaded from: %s\n", sourceURL)
+
+	fmt.Println("Verifying download...")
+	err = installer.VerifyDownload(newPath, release, assetName)
+	if err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	fmt.Println("Installing update...")
+	err = installer.ReplaceExecutable(exePath, newPath)
+	if err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("%w\nIf bgchanger is installed in a protected folder, run the update as administrator", err)
+	}
+
+	fmt.Printf("Updated to %s.\n", release.TagName)
+
+	// Relaunch the new binary so the user sees the version that is now installed
+	cmd := exec.Command(exePath, "version")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Note: could not relaunch updated bgchanger: %v\n", err)
+	}
+
+	return nil
+}
+
+// rolloutWaiting reports whether the release is rolled out in stages and has
+// not reached this machine's position yet.
+func rolloutWaiting(release *installer.GitHubRelease) (bool, error) {
+	rollout, err := installer.GetRollout(release)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the rollout of %s: %w", release.TagName, err)
+	}
+	if rollout == nil {
+		return false, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Note: %v\n", err)
+	}
+	hostname, _ := os.Hostname()
+	position := installer.RolloutPosition(hostname, cfg.Update.RingMode())
+	if rollout.Paused {
+		fmt.Printf("Rollout: paused (this machine is at position %d)\n", position)
+	} else {
+		fmt.Printf("Rollout: %d%% (this machine is at position %d)\n", rollout.Percent, position)
+	}
+	return !rollout.Open(position), nil
+}
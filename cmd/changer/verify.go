@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// errDrift is returned by runVerify when a surface no longer shows the last
+// applied image. main turns it into exit code 2.
+var errDrift = errors.New("wallpaper drift detected")
+
+// Surface check results.
+const (
+	verifyOK         = "ok"
+	verifyDrift      = "drift"
+	verifyNotApplied = "not_applied"
+	verifyUnknown    = "unknown"
+)
+
+// surfaceCheck is the verification result for one surface.
+type surfaceCheck struct {
+	Surface string `json:"surface"`
+	Status  string `json:"status"`
+	// Path and SHA256 describe the image the system currently reports.
+	Path   string `json:"path,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// verifyReport is the output of "bgchanger verify --json".
+type verifyReport struct {
+	Applied   *appliedImage  `json:"applied"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Surfaces  []surfaceCheck `json:"surfaces"`
+	Drift     bool           `json:"drift"`
+}
+
+// runVerify compares the image last applied by bgchanger with what the desktop,
+// lock screen and login screen currently use, as JSON with --json
+func runVerify(args []string) error {
+	asJSON := false
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+		}
+	}
+
+	history, err := loadAppliedHistory()
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return fmt.Errorf("no applied image recorded in %s", appliedHistoryPath())
+	}
+	last := history[len(history)-1]
+
+	report := verifyReport{Applied: &last, CheckedAt: time.Now()}
+	report.Surfaces = []surfaceCheck{
+		checkSurface("desktop", last.Desktop, last.SHA256, desktopWallpaperPath),
+		checkSurface("lock_screen", last.LockScreen, last.SHA256, lockScreenImagePath),
+		checkLoginScreen(last),
+	}
+	for _, check := range report.Surfaces {
+		if check.Status == verifyDrift {
+			report.Drift = true
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printVerifyReport(report)
+	}
+
+	if report.Drift {
+		return errDrift
+	}
+	return nil
+}
+
+// printVerifyReport prints the verification result in the style of the apply summary
+func printVerifyReport(report verifyReport) {
+	fmt.Printf("Last applied: %s\n", report.Applied.Path)
+	fmt.Printf("  SHA-256:    %s\n", report.Applied.SHA256)
+	fmt.Printf("  Applied at: %s\n\n", report.Applied.AppliedAt.Format(time.RFC1123))
+
+	names := map[string]string{
+		"desktop":      "Desktop wallpaper",
+		"lock_screen":  "Lock screen wallpaper",
+		"login_screen": "Login screen background",
+	}
+	for _, check := range report.Surfaces {
+		name := names[check.Surface]
+		switch check.Status {
+		case verifyOK:
+			fmt.Printf("[OK] %s: matches\n", name)
+		case verifyDrift:
+			fmt.Printf("[X]  %s: DRIFT - %s\n", name, check.Detail)
+		case verifyNotApplied:
+			fmt.Printf("[-]  %s: not applied by bgchanger\n", name)
+		default:
+			fmt.Printf("[?]  %s: %s\n", name, check.Detail)
+		}
+		if check.Path != "" && check.Status != verifyOK {
+			fmt.Printf("     Current image: %s\n", check.Path)
+		}
+	}
+}
+
+// checkSurface hashes the image a surface currently uses and compares it with
+// the applied image.
+func checkSurface(surface string, applied bool, want string, current func() (string, error)) surfaceCheck {
+	check := surfaceCheck{Surface: surface}
+	if !applied {
+		check.Status = verifyNotApplied
+		return check
+	}
+
+	path, err := current()
+	if err != nil {
+		check.Status = verifyUnknown
+		check.Detail = err.Error()
+		return check
+	}
+	check.Path = path
+
+	hash, err := hashFile(path)
+	if err != nil {
+		check.Status = verifyDrift
+		check.Detail = fmt.Sprintf("current image cannot be read: %v", err)
+		return check
+	}
+	check.SHA256 = hash
+
+	if hash != want {
+		check.Status = verifyDrift
+		check.Detail = "current image differs from the applied image"
+		return check
+	}
+	check.Status = verifyOK
+	return check
+}
+
+// checkLoginScreen verifies the login screen. BgStatusService replaces the
+// applied image with a copy carrying the status overlay, so when the login
+// screen shows one of its renders the backup it renders from is checked instead.
+func checkLoginScreen(last appliedImage) surfaceCheck {
+	check := checkSurface("login_screen", last.LoginScreen, last.SHA256, loginscreen.GetCurrentLoginScreenImage)
+	if check.Status != verifyDrift || !isStatusOverlayRender(check.Path) {
+		return check
+	}
+
+	backup, err := loginscreen.GetBackupImage()
+	if err != nil {
+		check.Status = verifyUnknown
+		check.Detail = "login screen shows a BgStatusService render but its source image is missing"
+		return check
+	}
+	hash, err := hashFile(backup)
+	if err != nil || hash != last.SHA256 {
+		check.Detail = "BgStatusService renders over a different image than the applied one"
+		return check
+	}
+
+	check.Status = verifyOK
+	check.Detail = "shown with the BgStatusService overlay"
+	return check
+}
+
+// isStatusOverlayRender reports whether path is a login screen image rendered by BgStatusService
+func isStatusOverlayRender(path string) bool {
+	dir, name := filepath.Split(path)
+	return strings.EqualFold(filepath.Clean(dir), filepath.Clean(loginscreen.BackupDir)) &&
+		strings.HasPrefix(strings.ToLower(name), "loginscreen_")
+}
+
+// desktopWallpaperPath returns the wallpaper path of the current user
+func desktopWallpaperPath() (string, error) {
+	key, err := winapi.Reg.OpenKey(registry.CURRENT_USER, `Control Panel\Desktop`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", fmt.Errorf("failed to open desktop settings: %w", err)
+	}
+	defer key.Close()
+
+	path, _, err := key.GetStringValue("Wallpaper")
+	if err != nil || path == "" {
+		return "", fmt.Errorf("no desktop wallpaper is set")
+	}
+	return path, nil
+}
+
+// lockScreenImagePath returns the lock screen image, checking the machine-wide
+// PersonalizationCSP setting before the per-user one
+func lockScreenImagePath() (string, error) {
+	const cspPath = `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`
+	for _, root := range []registry.Key{registry.LOCAL_MACHINE, registry.CURRENT_USER} {
+		key, err := winapi.Reg.OpenKey(root, cspPath, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		path, _, err := key.GetStringValue("LockScreenImagePath")
+		key.Close()
+		if err != nil || path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no lock screen image is configured (Spotlight or a picture set in Settings)")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/installer"
+)
+
+// Orders in which watch goes through the images of a directory.
+const (
+	watchOrderShuffle    = "shuffle"
+	watchOrderSequential = "sequential"
+)
+
+// watchOptions is the parsed command line of watch.
+type watchOptions struct {
+	interval time.Duration
+	order    string
+	source   string
+	// setArgs are the flags passed on to set, e.g. --desktop-only or --fit.
+	setArgs []string
+}
+
+// parseWatchArgs parses:
+//
+//	bgchanger watch [--interval 30m] [--order shuffle|sequential] [set flags] [source]
+//
+// Without a source, each change is a random wallpaper like bgchanger with no
+// arguments.
+func parseWatchArgs(args []string) (*watchOptions, error) {
+	opts := &watchOptions{interval: installer.DefaultRotationInterval, order: watchOrderShuffle}
+
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.DurationVar(&opts.interval, "interval", opts.interval, "")
+	fs.StringVar(&opts.order, "order", opts.order, "")
+	for _, name := range []string{"desktop-only", "lockscreen-only", "login-only"} {
+		fs.BoolFunc(name, "", func(string) error {
+			opts.setArgs = append(opts.setArgs, "--"+name)
+			return nil
+		})
+	}
+	fs.Func("fit", "", func(value string) error {
+		fit, err := parseFit(value)
+		if err == nil {
+			opts.setArgs = append(opts.setArgs, "--fit", fit)
+		}
+		return err
+	})
+
+	// As in parseApplyArgs, parse again after each positional argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("watch: %v", err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) > 1 {
+		return nil, fmt.Errorf("watch takes one directory, URL or source, got %q and %q", positional[0], positional[1])
+	}
+	if len(positional) == 1 {
+		opts.source = positional[0]
+	}
+	if opts.interval < time.Minute {
+		return nil, fmt.Errorf("--interval must be at least 1m")
+	}
+	opts.order = strings.ToLower(opts.order)
+	if opts.order != watchOrderShuffle && opts.order != watchOrderSequential {
+		return nil, fmt.Errorf("unknown order %q (use %s or %s)", opts.order, watchOrderShuffle, watchOrderSequential)
+	}
+	return opts, nil
+}
+
+// runWatch keeps running and changes the wallpaper now and every interval
+// until interrupted. Each change runs "bgchanger set" in a child process, so
+// one failed download or image doesn't stop the rotation.
+func runWatch(opts *watchOptions) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var next func() (string, error)
+	if info, err := os.Stat(opts.source); err == nil && info.IsDir() {
+		next = directoryRotation(opts.source, opts.order)
+		fmt.Printf("Watching %s (%s), changing the wallpaper every %v. Press Ctrl+C to stop.\n", opts.source, opts.order, opts.interval)
+	} else {
+		// URLs, libraries and remote sources give a new image on every fetch
+		next = func() (string, error) { return opts.source, nil }
+		source := opts.source
+		if source == "" {
+			source = "random wallpapers"
+		}
+		fmt.Printf("Rotating %s every %v. Press Ctrl+C to stop.\n", source, opts.interval)
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		source, err := next()
+		if err != nil {
+			fmt.Printf("[%s] %v\n", time.Now().Format("15:04:05"), err)
+		} else {
+			changeWallpaper(ctx, exe, source, opts.setArgs)
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped watching.")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// directoryRotation returns the next image of a directory on every call. The
+// directory is listed again after each pass, so images added or removed in
+// the meantime are picked up. Shuffle shows every image once per pass in a new
+// random order.
+func directoryRotation(dir, order string) func() (string, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var queue []string
+	last := ""
+	return func() (string, error) {
+		if len(queue) == 0 {
+			images, err := listImages(dir)
+			if err != nil {
+				return "", err
+			}
+			if order == watchOrderShuffle {
+				r.Shuffle(len(images), func(i, j int) { images[i], images[j] = images[j], images[i] })
+				// Don't show the same image twice in a row across passes
+				if len(images) > 1 && images[0] == last {
+					images[0], images[1] = images[1], images[0]
+				}
+			}
+			queue = images
+		}
+		last, queue = queue[0], queue[1:]
+		return last, nil
+	}
+}
+
+// changeWallpaper runs "bgchanger set SOURCE" with the flags of watch. Without
+// a source it runs plain bgchanger, the random wallpaper.
+func changeWallpaper(ctx context.Context, exe, source string, setArgs []string) {
+	args := []string{installer.RotationArg}
+	if source != "" {
+		args = append(args, commandSet, source)
+	}
+	args = append(args, setArgs...)
+
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Stdout, cmd.Stderr = io.Discard, io.Discard
+	err := cmd.Run()
+	now := time.Now().Format("15:04:05")
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+	case errors.As(err, &exitErr):
+		fmt.Printf("[%s] Failed to change the wallpaper to %s (exit code %d); run 'bgchanger set' with it to see why\n", now, displaySource(source), exitErr.ExitCode())
+	case err != nil:
+		fmt.Printf("[%s] Failed to run bgchanger: %v\n", now, err)
+	default:
+		fmt.Printf("[%s] Changed the wallpaper to %s\n", now, displaySource(source))
+	}
+}
+
+// displaySource names a source in watch's log.
+func displaySource(source string) string {
+	if source == "" {
+		return "a random wallpaper"
+	}
+	return source
+}
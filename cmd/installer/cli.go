@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/backgroundchanger/cmd/installer/embed"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/installer"
+)
+
+// Exit codes of the command-line modes. They never change meaning, so package
+// managers (Chocolatey, winget, Intune) can wrap the installer.
+const (
+	exitOK = 0
+	// exitFailed is any failure not covered below.
+	exitFailed = 1
+	// exitUsage is an unknown argument or combination.
+	exitUsage = 2
+	// exitNotInstalled is --status when nothing is installed.
+	exitNotInstalled = 3
+	// exitPartial is --status when only part of an install is left.
+	exitPartial = 4
+	// exitAccessDenied is a run without administrator privileges, or files
+	// locked by security software or a running instance (ERROR_ACCESS_DENIED).
+	exitAccessDenied = 5
+)
+
+const cliUsage = `usage: bgStatusServiceSetup.exe [--unattended | --uninstall | --status]
+       bgStatusServiceSetup.exe --remote HOST1,HOST2 [--credential DOMAIN\user] [--uninstall]`
+
+// statusOutput is the JSON printed by --status.
+type statusOutput struct {
+	*installer.InstallStatus
+	// InstallerVersion is the version this installer would install.
+	InstallerVersion string `json:"installer_version"`
+}
+
+// runCommandLine runs the installer's command-line modes and returns their
+// exit code, or false when there are no arguments and the GUI should run.
+// Installing and uninstalling can be repeated: installing again replaces the
+// tasks and files, and uninstalling what is not installed succeeds.
+func runCommandLine(args []string) (int, bool) {
+	if len(args) == 0 {
+		return exitOK, false
+	}
+	attachConsole()
+
+	var status, unattended, uninstall bool
+	var remote, credential string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--status":
+			status = true
+		case "--unattended":
+			unattended = true
+		case "--uninstall":
+			uninstall = true
+		case "--remote", "--credential":
+			if i+1 >= len(args) {
+				return usageError("%s needs a value", args[i])
+			}
+			if args[i] == "--remote" {
+				remote = args[i+1]
+			} else {
+				credential = args[i+1]
+			}
+			i++
+		default:
+			return usageError("unknown argument %q", args[i])
+		}
+	}
+
+	switch {
+	case remote != "":
+		if status {
+			return usageError("--status cannot be combined with --remote")
+		}
+		return exitCode(runRemoteInstall(remote, credential, uninstall)), true
+	case credential != "":
+		return usageError("--credential needs --remote")
+	case status && (unattended || uninstall):
+		return usageError("--status cannot be combined with --unattended or --uninstall")
+	}
+
+	if !isAdmin() {
+		fmt.Println("Error: administrator privileges are required")
+		return exitAccessDenied, true
+	}
+	switch {
+	case status:
+		return runStatus(), true
+	case uninstall:
+		return exitCode(runUnattendedUninstall()), true
+	default:
+		return exitCode(runUnattendedInstall()), true
+	}
+}
+
+// runStatus prints the installation state as JSON.
+func runStatus() int {
+	status := installer.QueryStatus()
+	data, err := json.MarshalIndent(statusOutput{InstallStatus: status, InstallerVersion: embed.Version}, "", "  ")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitFailed
+	}
+	fmt.Println(string(data))
+
+	switch {
+	case status.Installed:
+		return exitOK
+	case status.Partial:
+		return exitPartial
+	default:
+		return exitNotInstalled
+	}
+}
+
+// exitCode prints an error and returns the exit code for it.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	fmt.Printf("Error: %v\n", err)
+	if errors.Is(err, errs.ErrAccessDenied) {
+		return exitAccessDenied
+	}
+	return exitFailed
+}
+
+func usageError(format string, args ...interface{}) (int, bool) {
+	fmt.Printf("Error: %s\n%s\n", fmt.Sprintf(format, args...), cliUsage)
+	return exitUsage, true
+}
@@ -1,28 +1,51 @@
-// Package embed contains the embedded bgStatusService.exe binary.
-// The binary must be copied to this directory before building the installer.
+// Package embed contains the embedded bgStatusService.exe binaries.
+// The binaries must be copied to this directory before building the installer:
+// bgStatusService.exe (x64, required) and optionally bgStatusService_arm64.exe.
 package embed
 
 import (
-	_ "embed"
+	"embed"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/backgroundchanger/internal/installer"
 )
 
-// ServiceExe contains the embedded bgStatusService.exe binary.
-// This file must exist in this directory at build time.
+// serviceExes contains the embedded bgStatusService executables, one per architecture.
+// bgStatusService.exe must exist in this directory at build time.
 //
-//go:embed bgStatusService.exe
-var ServiceExe []byte
+//go:embed bgStatusService*.exe
+var serviceExes embed.FS
 
 // Version is the version of the embedded service executable.
 // This should be updated when the embedded binary is updated.
 var Version = "v1.0.0"
 
+// ServiceExe returns the embedded service executable for this machine's native
+// architecture and the file name it was embedded under. When no native build is
+// embedded the x64 build is used, which ARM64 Windows runs under emulation.
+func ServiceExe() ([]byte, string, error) {
+	name := installer.ArchAssetName(installer.ServiceExeName, installer.NativeArch())
+	data, err := serviceExes.ReadFile(name)
+	if err != nil {
+		name = installer.ServiceExeName
+		data, err = serviceExes.ReadFile(name)
+		if err != nil {
+			return nil, "", fmt.Errorf("embedded service executable is missing - build may be corrupted")
+		}
+	}
+	return data, name, nil
+}
+
 // ExtractServiceExe extracts the embedded service executable to a temporary file
 // and returns the path to the extracted file.
 func ExtractServiceExe() (string, error) {
-	if len(ServiceExe) == 0 {
+	serviceExe, _, err := ServiceExe()
+	if err != nil {
+		return "", err
+	}
+	if len(serviceExe) == 0 {
 		return "", fmt.Errorf("embedded service executable is empty - build may be corrupted")
 	}
 
@@ -31,7 +54,7 @@ func ExtractServiceExe() (string, error) {
 	destPath := filepath.Join(tempDir, "bgStatusService.exe")
 
 	// Write the embedded binary to the temp file
-	err := os.WriteFile(destPath, ServiceExe, 0755)
+	err = os.WriteFile(destPath, serviceExe, 0755)
 	if err != nil {
 		return "", fmt.Errorf("failed to extract service executable: %w", err)
 	}
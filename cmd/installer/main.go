@@ -3,6 +3,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -14,7 +15,11 @@ import (
 	"golang.org/x/sys/windows"
 
 	"github.com/backgroundchanger/cmd/installer/embed"
+	"github.com/backgroundchanger/internal/compliance"
+	"github.com/backgroundchanger/internal/errs"
 	"github.com/backgroundchanger/internal/installer"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/loginscreen"
 )
 
 var (
@@ -23,6 +28,11 @@ var (
 )
 
 func main() {
+	// Command-line modes print their progress instead of showing windows
+	if code, ok := runCommandLine(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
 	// Check if running as administrator
 	if !isAdmin() {
 		// Re-launch with elevation
@@ -198,10 +208,16 @@ func runInstall() {
 		processMessagesWithDelay(pw, 200)
 
 		err = installer.InstallScheduledTasks(exePath)
+		if errors.Is(err, errs.ErrAccessDenied) {
+			pw.SetComplete(false, "Failed to install scheduled tasks:\n"+err.Error()+
+				"\n\nSecurity software or a previous install may be locking the files.")
+			return
+		}
 		if err != nil {
 			pw.SetComplete(false, "Failed to install scheduled tasks:\n"+err.Error())
 			return
 		}
+		_ = installer.RecordInstalledVersion(version)
 
 		// Step 4: Run the executable to generate initial image
 		pw.SetStatus("Generating login screen image...")
@@ -215,6 +231,12 @@ func runInstall() {
 			return
 		}
 
+		// Report-only mode collects the status but leaves the login screen alone
+		if installer.ReportOnly() {
+			pw.SetComplete(true, "Installed "+version+" in report-only mode (the login screen is not changed).")
+			return
+		}
+
 		// Step 5: Apply lock screen for current user
 		pw.SetStatus("Applying lock screen...")
 		pw.SetProgress(95)
@@ -254,7 +276,7 @@ func runUninstall() {
 	checkDone := make(chan bool, 1)
 	go func() {
 		serviceExists, _ = installer.ServiceExists()
-		taskExists = installer.ScheduledTaskExists()
+		taskExists = installer.ScheduledTaskExists() || installer.RotationTaskExists()
 		checkDone <- true
 	}()
 
@@ -295,6 +317,7 @@ func runUninstall() {
 		processMessagesWithDelay(pw, 300)
 
 		installer.DeleteScheduledTasks()
+		installer.DeleteRotationTask()
 
 		// Step 2: Remove old Windows service if present
 		if serviceExists {
@@ -311,6 +334,7 @@ func runUninstall() {
 		pw.SetProgress(40)
 		processMessagesWithDelay(pw, 200)
 		installer.RemoveEventLogSource()
+		compliance.Remove()
 
 		// Step 4: Remove files
 		pw.SetStatus("Removing installation files...")
@@ -319,19 +343,27 @@ func runUninstall() {
 
 		_ = installer.RemoveInstallation()
 
-		// Step 5: Remove data directory
-		pw.SetStatus("Removing data directory...")
-		pw.SetProgress(70)
+		// Step 5: Revert registry changes (the journal lives in the data directory,
+		// so this must happen before it is removed)
+		pw.SetStatus("Restoring original login screen...")
+		pw.SetProgress(65)
 		processMessagesWithDelay(pw, 200)
 
-		_ = installer.RemoveDataDirectory()
+		if journal.Exists() {
+			_, _ = journal.Undo()
+		} else {
+			// Installs from before the journal existed: remove the values we know about
+			restoreOriginalBackground()
+		}
+		// Put back the policy's image that lock_screen.managed_policy "coexist" replaced
+		_ = loginscreen.RestoreManagedImage()
 
-		// Step 6: Clean registry (restore original background)
-		pw.SetStatus("Restoring original login screen...")
+		// Step 6: Remove data directory
+		pw.SetStatus("Removing data directory...")
 		pw.SetProgress(85)
 		processMessagesWithDelay(pw, 200)
 
-		restoreOriginalBackground()
+		_ = installer.RemoveDataDirectory()
 
 		// Complete!
 		pw.SetProgress(100)
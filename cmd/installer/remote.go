@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32          = syscall.NewLazyDLL("kernel32.dll")
+	procAttachConsole = kernel32.NewProc("AttachConsole")
+)
+
+// remoteInstallScript copies the installer to each host's admin$ share and
+// runs it there with --unattended or --uninstall over WinRM, writing one JSON
+// result per host. The credential is asked for once by Get-Credential so the
+// password never appears on a command line.
+const remoteInstallScript = `
+$ErrorActionPreference = 'Stop'
+$source = $env:BGSTATUS_REMOTE_SOURCE
+$name = 'bgStatusServiceSetup-remote.exe'
+$cred = $null
+if ($env:BGSTATUS_REMOTE_USER) {
+    $cred = Get-Credential -UserName $env:BGSTATUS_REMOTE_USER -Message 'Administrator account on the remote computers'
+    if (-not $cred) { throw 'No credential entered' }
+}
+foreach ($computer in ($env:BGSTATUS_REMOTE_HOSTS -split ',')) {
+    $result = [ordered]@{ Host = $computer; ExitCode = -1; Output = ''; Error = '' }
+    $drive = $null
+    try {
+        $params = @{ Name = 'BgStatusRemote'; PSProvider = 'FileSystem'; Root = "\\$computer\admin$\Temp" }
+        if ($cred) { $params.Credential = $cred }
+        $drive = New-PSDrive @params
+        Copy-Item -LiteralPath $source -Destination "BgStatusRemote:\$name" -Force
+        $invoke = @{ ComputerName = $computer; ArgumentList = $name, $env:BGSTATUS_REMOTE_ARGUMENT; ScriptBlock = {
+            param($name, $argument)
+            $ErrorActionPreference = 'Stop'
+            $exe = Join-Path $env:SystemRoot "Temp\$name"
+            $log = "$exe.log"
+            try {
+                $p = Start-Process -FilePath $exe -ArgumentList $argument -Wait -PassThru -RedirectStandardOutput $log
+                @{ ExitCode = $p.ExitCode; Output = (Get-Content -LiteralPath $log -Raw -ErrorAction SilentlyContinue) }
+            } finally {
+                Remove-Item -LiteralPath $exe, $log -Force -ErrorAction SilentlyContinue
+            }
+        } }
+        if ($cred) { $invoke.Credential = $cred }
+        $remote = Invoke-Command @invoke
+        $result.ExitCode = $remote.ExitCode
+        $result.Output = [string]$remote.Output
+    } catch {
+        $result.Error = $_.Exception.Message
+    } finally {
+        if ($drive) { Remove-PSDrive -Name 'BgStatusRemote' -ErrorAction SilentlyContinue }
+    }
+    [pscustomobject]$result | ConvertTo-Json -Compress
+}
+`
+
+// remoteResult is the outcome of the install on one host.
+type remoteResult struct {
+	Host     string
+	ExitCode int
+	Output   string
+	Error    string
+}
+
+// message returns the error, or the last line the installer printed.
+func (r remoteResult) message() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	lines := strings.Split(strings.TrimSpace(r.Output), "\n")
+	message := strings.TrimSpace(lines[len(lines)-1])
+	if message == "" {
+		return fmt.Sprintf("exit code %d", r.ExitCode)
+	}
+	return message
+}
+
+// runRemoteInstall installs on (or with uninstall, removes from) other
+// computers without PsExec: --remote HOST1,HOST2 [--credential DOMAIN\user].
+// Without a credential the signed-in account is used. Each host needs the
+// admin$ share and WinRM reachable.
+func runRemoteInstall(hostList, user string, uninstall bool) error {
+	var hosts []string
+	for _, host := range strings.Split(hostList, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("--remote needs at least one host")
+	}
+	argument, action, done := "--unattended", "Installing on", "Installed on"
+	if uninstall {
+		argument, action, done = "--uninstall", "Uninstalling from", "Uninstalled from"
+	}
+
+	source, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the installer: %v", err)
+	}
+
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-ExecutionPolicy", "Bypass", "-Command", remoteInstallScript)
+	cmd.Env = append(os.Environ(),
+		"BGSTATUS_REMOTE_SOURCE="+source,
+		"BGSTATUS_REMOTE_HOSTS="+strings.Join(hosts, ","),
+		"BGSTATUS_REMOTE_USER="+user,
+		"BGSTATUS_REMOTE_ARGUMENT="+argument,
+	)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start PowerShell: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start PowerShell: %v", err)
+	}
+
+	width := 0
+	for _, host := range hosts {
+		if len(host) > width {
+			width = len(host)
+		}
+	}
+	fmt.Printf("%s %d host(s)...\n", action, len(hosts))
+	finished, failed := 0, 0
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var result remoteResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			continue
+		}
+		finished++
+		status := "OK"
+		if result.Error != "" || result.ExitCode != 0 {
+			status = "FAILED"
+			failed++
+		}
+		fmt.Printf("  %-*s  %-6s  %s\n", width, result.Host, status, result.message())
+	}
+	if err := cmd.Wait(); err != nil && finished == 0 {
+		return fmt.Errorf("PowerShell failed: %v", err)
+	}
+
+	// Hosts PowerShell never got to, e.g. after the credential prompt was cancelled
+	failed += len(hosts) - finished
+	if failed > 0 {
+		return fmt.Errorf("%d of %d host(s) failed", failed, len(hosts))
+	}
+	fmt.Printf("%s all %d host(s).\n", done, len(hosts))
+	return nil
+}
+
+// attachConsole connects output to the console the installer was started
+// from when it was built as a GUI program, which has none of its own.
+func attachConsole() {
+	if handle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err == nil && handle != 0 && handle != windows.InvalidHandle {
+		return
+	}
+	const attachParentProcess = ^uintptr(0)
+	if ret, _, _ := procAttachConsole.Call(attachParentProcess); ret == 0 {
+		return
+	}
+	if console, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = console
+		os.Stderr = console
+	}
+}
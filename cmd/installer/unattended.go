@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/backgroundchanger/cmd/installer/embed"
+	"github.com/backgroundchanger/internal/compliance"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/installer"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/loginscreen"
+)
+
+// runUnattendedInstall installs without any window, printing each step, for
+// --unattended and remote installs. The lock screen is not applied for the
+// current user since there may be none; it is set on the next boot.
+func runUnattendedInstall() error {
+	fmt.Println("Checking existing installation...")
+	serviceExists := false
+	serviceCheckDone := make(chan bool, 1)
+	go func() {
+		exists, _ := installer.ServiceExists()
+		serviceExists = exists
+		serviceCheckDone <- true
+	}()
+	select {
+	case <-serviceCheckDone:
+	case <-time.After(15 * time.Second):
+		fmt.Println("Warning: Service check timed out, continuing...")
+	}
+	if serviceExists {
+		fmt.Println("Removing old Windows service...")
+		_ = installer.StopService()
+		_ = installer.DeleteService()
+	}
+
+	taskExists := false
+	taskCheckDone := make(chan bool, 1)
+	go func() {
+		taskExists = installer.ScheduledTaskExists()
+		taskCheckDone <- true
+	}()
+	select {
+	case <-taskCheckDone:
+	case <-time.After(15 * time.Second):
+		fmt.Println("Warning: Task check timed out, continuing...")
+	}
+	if taskExists {
+		fmt.Println("Removing existing scheduled tasks...")
+		installer.DeleteScheduledTasks()
+	}
+
+	fmt.Println("Extracting service executable...")
+	exePath, err := embed.ExtractServiceExe()
+	if err != nil {
+		return fmt.Errorf("failed to extract service: %v", err)
+	}
+	defer os.Remove(exePath)
+
+	fmt.Println("Installing scheduled tasks...")
+	err = installer.InstallScheduledTasks(exePath)
+	if errors.Is(err, errs.ErrAccessDenied) {
+		return errs.Mark(errs.ErrAccessDenied, fmt.Errorf("failed to install scheduled tasks: %v (security software or a previous install may be locking the files)", err))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to install scheduled tasks: %v", err)
+	}
+	_ = installer.RecordInstalledVersion(embed.Version)
+
+	fmt.Println("Generating login screen image...")
+	if err := installer.RunExecutableDirectly(); err != nil {
+		fmt.Printf("Installed %s (login screen will update on next boot)\n", embed.Version)
+		return nil
+	}
+	if installer.ReportOnly() {
+		fmt.Printf("Installed %s in report-only mode\n", embed.Version)
+		return nil
+	}
+	fmt.Printf("Installed %s\n", embed.Version)
+	return nil
+}
+
+// runUnattendedUninstall uninstalls without any window, like the GUI. Nothing
+// installed is not an error, so it can be repeated.
+func runUnattendedUninstall() error {
+	fmt.Println("Checking existing installation...")
+	serviceExists, _ := installer.ServiceExists()
+	taskExists := installer.ScheduledTaskExists() || installer.RotationTaskExists()
+	_, dirErr := os.Stat(installer.GetInstallDir())
+	if !serviceExists && !taskExists && os.IsNotExist(dirErr) {
+		fmt.Println("Not installed")
+		return nil
+	}
+
+	fmt.Println("Removing scheduled tasks...")
+	installer.DeleteScheduledTasks()
+	installer.DeleteRotationTask()
+	if serviceExists {
+		fmt.Println("Removing old Windows service...")
+		_ = installer.StopService()
+		_ = installer.DeleteService()
+	}
+	installer.RemoveEventLogSource()
+	compliance.Remove()
+
+	fmt.Println("Removing installation files...")
+	if err := installer.RemoveInstallation(); err != nil {
+		return err
+	}
+
+	// The journal lives in the data directory, so replay it before removing that
+	fmt.Println("Restoring original login screen...")
+	if journal.Exists() {
+		_, _ = journal.Undo()
+	} else {
+		restoreOriginalBackground()
+	}
+	_ = loginscreen.RestoreManagedImage()
+	if err := installer.RemoveDataDirectory(); err != nil {
+		return err
+	}
+	fmt.Println("Uninstalled (the original login screen returns after a restart)")
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// addBanner draws the configured legal notice along the bottom of img and
+// returns the result with the banner's height. Without a banner, or when it
+// fails, img is returned as is with height 0.
+func addBanner(elog debug.Log, banner config.BannerConfig, img image.Image) (image.Image, float64) {
+	opts, ok, err := bannerOptions(banner)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Invalid banner: %v (skipping it)", err))
+		return img, 0
+	}
+	if !ok {
+		return img, 0
+	}
+	result, height, err := overlay.RenderBanner(img, opts)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to render banner: %v (continuing anyway)", err))
+		return img, 0
+	}
+	return result, height
+}
+
+// bannerOptions converts the banner config, falling back to the logon message
+// policy when asked to. ok is false when there is no text to show.
+func bannerOptions(banner config.BannerConfig) (overlay.BannerOptions, bool, error) {
+	opts := overlay.BannerOptions{Title: banner.Title, Text: banner.Text}
+	if banner.UseLegalNotice && opts.Title == "" && opts.Text == "" {
+		opts.Title, opts.Text = sysinfo.LegalNotice()
+	}
+	if opts.Title == "" && opts.Text == "" {
+		return opts, false, nil
+	}
+
+	if banner.Background != "" {
+		c, err := overlay.ParseHexColor(banner.Background)
+		if err != nil {
+			return opts, false, err
+		}
+		opts.Background = c
+	}
+	if banner.Foreground != "" {
+		c, err := overlay.ParseHexColor(banner.Foreground)
+		if err != nil {
+			return opts, false, err
+		}
+		opts.Foreground = c
+	}
+	return opts, true, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	rtdebug "runtime/debug"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/imageconv"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"github.com/backgroundchanger/internal/widgets"
+)
+
+// DefaultBenchIterations is the number of runs --bench does when no count is given.
+const DefaultBenchIterations = 5
+
+// Profile files written to the data directory by --bench --profile.
+const (
+	benchCPUProfile  = "bench_cpu.pprof"
+	benchHeapProfile = "bench_heap.pprof"
+)
+
+// benchStages are the pipeline stages timed by --bench, in order.
+var benchStages = []string{"load", "sysinfo", "services", "calendar", "widgets", "render", "encode"}
+
+// benchTimer collects the durations of each stage across iterations.
+type benchTimer struct {
+	samples map[string][]time.Duration
+}
+
+// time runs fn and records its duration under stage.
+func (b *benchTimer) time(stage string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	b.samples[stage] = append(b.samples[stage], time.Since(start))
+	return err
+}
+
+// parseBenchArgs reads "--bench [N] [--profile]" from the command line.
+func parseBenchArgs(args []string) (iterations int, profile bool) {
+	iterations = DefaultBenchIterations
+	for i, arg := range args {
+		switch arg {
+		case "--bench":
+			if i+1 < len(args) {
+				if n, err := strconv.Atoi(args[i+1]); err == nil && n > 0 {
+					iterations = n
+				}
+			}
+		case "--profile":
+			profile = true
+		}
+	}
+	return iterations, profile
+}
+
+// runBench runs the collect and render pipeline N times without touching the
+// login screen, and prints per-stage timings and peak memory. With --profile it
+// also writes CPU and heap profiles (go tool pprof) to the data directory.
+func runBench(args []string) error {
+	iterations, profile := parseBenchArgs(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Note: %v (using defaults)\n", err)
+	}
+	rtdebug.SetMemoryLimit(cfg.MemoryLimit())
+
+	sourcePath := loginscreen.GetBackupPath()
+	if !loginscreen.HasBackup() {
+		sourcePath, _ = loginscreen.GetCurrentLoginScreenImage()
+	}
+	displayRes := sysinfo.GetDisplayResolution()
+	ctx := context.Background()
+
+	if profile {
+		if err := os.MkdirAll(loginscreen.BackupDir, 0755); err != nil {
+			return fmt.Errorf("failed to create data directory: %v", err)
+		}
+		cpuFile, err := os.Create(filepath.Join(loginscreen.BackupDir, benchCPUProfile))
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %v", err)
+		}
+		defer cpuFile.Close()
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			return fmt.Errorf("failed to start CPU profile: %v", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	fmt.Printf("Benchmarking %d iterations (display %dx%d)\n", iterations, displayRes.Width, displayRes.Height)
+	if sourcePath != "" {
+		fmt.Printf("Background: %s\n", sourcePath)
+	} else {
+		fmt.Println("Background: generated default")
+	}
+
+	timer := &benchTimer{samples: make(map[string][]time.Duration)}
+	var peakHeap, peakSys uint64
+	total := time.Now()
+
+	for i := 0; i < iterations; i++ {
+		var source image.Image
+		var infoLines, serviceLines []string
+
+		err := timer.time("load", func() error {
+			if sourcePath == "" {
+				source = loginscreen.CreateDefaultBackground(displayRes.Width, displayRes.Height)
+				return nil
+			}
+			var err error
+			source, err = loginscreen.LoadImageScaled(sourcePath, displayRes.Width, displayRes.Height, cfg.ImageLimits)
+			if err != nil {
+				return err
+			}
+			source = overlay.Adjust(source, overlay.Adjustments(cfg.Adjust))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load background: %v", err)
+		}
+
+		err = timer.time("sysinfo", func() error {
+			info, err := sysinfo.Gather(ctx)
+			if err != nil {
+				return err
+			}
+			infoLines = info.FormatLines()
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to gather system info: %v", err)
+		}
+
+		timer.time("services", func() error {
+			services, err := sysinfo.GatherServices(ctx)
+			if services != nil {
+				serviceLines = services.FormatServiceLines()
+			}
+			return err
+		})
+
+		timer.time("calendar", func() error {
+			if len(cfg.Calendar.ICSURLs) == 0 {
+				return nil
+			}
+			calendar, err := sysinfo.GatherCalendar(ctx, cfg.Calendar.ICSURLs, cfg.Calendar.MaxEvents,
+				cfg.Calendar.DaysAhead, loginscreen.BackupDir)
+			if calendar != nil {
+				serviceLines = appendSection(serviceLines, calendar.FormatCalendarLines())
+			}
+			return err
+		})
+
+		timer.time("widgets", func() error {
+			layout, err := config.LoadLayout()
+			now := time.Now()
+			for _, w := range layout.Widgets {
+				lines, _ := widgets.FormatLines(ctx, w, now)
+				if w.Panel == config.PanelRight {
+					infoLines = appendSection(infoLines, lines)
+				} else {
+					serviceLines = appendSection(serviceLines, lines)
+				}
+			}
+			return err
+		})
+
+		var result image.Image
+		err = timer.time("render", func() error {
+			var err error
+			result, err = overlay.RenderDualPanelOverlay(source, serviceLines, infoLines, renderOptions(cfg, time.Now()))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to render overlay: %v", err)
+		}
+
+		err = timer.time("encode", func() error {
+			return imageconv.EncodeJPEG(io.Discard, result, &jpeg.Options{Quality: loginscreen.JPEGQuality})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode image: %v", err)
+		}
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		if mem.HeapInuse > peakHeap {
+			peakHeap = mem.HeapInuse
+		}
+		if mem.Sys > peakSys {
+			peakSys = mem.Sys
+		}
+		fmt.Printf("  iteration %d: %v\n", i+1, timer.lastTotal().Round(time.Millisecond))
+	}
+
+	printBenchSummary(timer, iterations, time.Since(total))
+	fmt.Printf("Peak heap in use: %.1f MB, peak memory from OS: %.1f MB\n",
+		float64(peakHeap)/(1<<20), float64(peakSys)/(1<<20))
+
+	if profile {
+		pprof.StopCPUProfile()
+		heapPath := filepath.Join(loginscreen.BackupDir, benchHeapProfile)
+		heapFile, err := os.Create(heapPath)
+		if err != nil {
+			return fmt.Errorf("failed to create heap profile: %v", err)
+		}
+		defer heapFile.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(heapFile); err != nil {
+			return fmt.Errorf("failed to write heap profile: %v", err)
+		}
+		fmt.Printf("\nProfiles written to %s and %s\n",
+			filepath.Join(loginscreen.BackupDir, benchCPUProfile), heapPath)
+	}
+
+	return nil
+}
+
+// lastTotal returns the summed duration of the most recent iteration.
+func (b *benchTimer) lastTotal() time.Duration {
+	var total time.Duration
+	for _, stage := range benchStages {
+		if s := b.samples[stage]; len(s) > 0 {
+			total += s[len(s)-1]
+		}
+	}
+	return total
+}
+
+// printBenchSummary prints min/avg/max per stage.
+func printBenchSummary(b *benchTimer, iterations int, elapsed time.Duration) {
+	fmt.Printf("\n%-10s %10s %10s %10s\n", "Stage", "Min", "Avg", "Max")
+	for _, stage := range benchStages {
+		samples := b.samples[stage]
+		if len(samples) == 0 {
+			continue
+		}
+		minD, maxD, sum := samples[0], samples[0], time.Duration(0)
+		for _, d := range samples {
+			if d < minD {
+				minD = d
+			}
+			if d > maxD {
+				maxD = d
+			}
+			sum += d
+		}
+		avg := sum / time.Duration(len(samples))
+		fmt.Printf("%-10s %10s %10s %10s\n", stage, formatBenchDuration(minD), formatBenchDuration(avg), formatBenchDuration(maxD))
+	}
+	fmt.Printf("\nTotal: %v for %d iterations (%v per iteration)\n",
+		elapsed.Round(time.Millisecond), iterations, (elapsed / time.Duration(iterations)).Round(time.Millisecond))
+}
+
+// formatBenchDuration shows durations in milliseconds with one decimal.
+func formatBenchDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/installer"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/publish"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"github.com/backgroundchanger/internal/thresholds"
+	"github.com/backgroundchanger/internal/widgets"
+)
+
+// placeholderPattern matches {name} placeholders in mirror URLs and commands.
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// runCheckConfig validates a config file, and the layout file next to it,
+// without running anything: --check-config [PATH], the installed config by
+// default. Every problem is printed with its line; any problem fails.
+func runCheckConfig(args []string) error {
+	path := config.Path()
+	for i, arg := range args {
+		if arg == "--check-config" && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			path = args[i+1]
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	cfg := config.Default()
+	positions, problems := config.CheckSyntax(data, cfg)
+	problems = append(problems, checkConfigValues(cfg, positions)...)
+	count := printProblems(path, problems)
+
+	layoutPath := filepath.Join(filepath.Dir(path), config.LayoutFileName)
+	if data, err := os.ReadFile(layoutPath); err == nil {
+		layout := &config.Layout{}
+		positions, problems := config.CheckSyntax(data, layout)
+		problems = append(problems, checkLayout(layout, positions)...)
+		count += printProblems(layoutPath, problems)
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%d problem(s) found", count)
+	}
+	fmt.Printf("%s: OK\n", path)
+	return nil
+}
+
+// printProblems prints the problems of a file in line order and returns how
+// many there were.
+func printProblems(path string, problems []config.Problem) int {
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", path, p)
+	}
+	return len(problems)
+}
+
+// configChecker collects the problems found in decoded values, placed on the
+// lines of their keys.
+type configChecker struct {
+	positions config.Positions
+	problems  []config.Problem
+}
+
+func (c *configChecker) add(path, format string, args ...interface{}) {
+	c.problems = append(c.problems, c.positions.Problem(path, format, args...))
+}
+
+func (c *configChecker) check(path string, err error) {
+	if err != nil {
+		c.add(path, "%v", err)
+	}
+}
+
+// oneOf checks an optional setting against its allowed values, ignoring case
+// like the accessors that read it.
+func (c *configChecker) oneOf(path, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	c.add(path, "unknown value %q (use %s)", value, strings.Join(allowed, ", "))
+}
+
+func (c *configChecker) duration(path, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		c.add(path, "invalid duration %q (e.g. 90s, 30m or 24h)", value)
+	}
+}
+
+func (c *configChecker) color(path, value string) {
+	if value == "" {
+		return
+	}
+	_, err := overlay.ParseHexColor(value)
+	c.check(path, err)
+}
+
+// httpURL checks an optional http(s) URL; webcal:// is accepted for calendars.
+func (c *configChecker) httpURL(path, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		c.add(path, "invalid url %q", value)
+		return
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https", "http", "webcal":
+	default:
+		c.add(path, "unsupported url scheme %q (use https://)", u.Scheme)
+	}
+}
+
+// placeholders checks that a template only uses the allowed {placeholders}
+// and has no stray braces.
+func (c *configChecker) placeholders(path, value string, allowed ...string) {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(value, -1) {
+		known := false
+		for _, a := range allowed {
+			known = known || m[1] == a
+		}
+		if !known {
+			c.add(path, "unknown placeholder {%s} (use {%s})", m[1], strings.Join(allowed, "}, {"))
+		}
+	}
+	if strings.ContainsAny(placeholderPattern.ReplaceAllString(value, ""), "{}") {
+		c.add(path, "unmatched brace in %q", value)
+	}
+}
+
+func (c *configChecker) theme(path string, theme config.ThemeConfig) {
+	c.color(path+".text", theme.Text)
+	c.color(path+".panel", theme.Panel)
+	c.color(path+".border", theme.Border)
+	if theme.PanelOpacity < 0 || theme.PanelOpacity > 1 {
+		c.add(path+".panel_opacity", "must be from 0 to 1")
+	}
+}
+
+// checkConfigValues checks the decoded settings that decoding alone accepts:
+// modes, durations, colors, URLs, templates and the collector and threshold
+// entries.
+func checkConfigValues(cfg *config.Config, positions config.Positions) []config.Problem {
+	c := &configChecker{positions: positions}
+
+	c.oneOf("mode", cfg.Mode, config.ModeReportOnly)
+	c.oneOf("services_display", cfg.ServicesDisplay,
+		config.ServicesDisplayFull, config.ServicesDisplaySummary, config.ServicesDisplayFailures)
+	c.oneOf("layout_direction", cfg.LayoutDirection,
+		config.LayoutDirectionAuto, config.LayoutDirectionLTR, config.LayoutDirectionRTL)
+	c.oneOf("server_core.output", cfg.ServerCore.Output,
+		config.ServerCoreLogonMessage, config.ServerCoreMOTD, config.ServerCoreBoth, config.ServerCoreNone)
+	c.oneOf("output.format", cfg.Output.Format, config.OutputFormatJPEG, config.OutputFormatPNG)
+	c.oneOf("output.naming", cfg.Output.Naming, config.OutputNamingUnix, config.OutputNamingDateTime)
+	c.oneOf("lock_screen.spotlight", cfg.LockScreen.Spotlight,
+		config.SpotlightReport, config.SpotlightReassert, config.SpotlightIgnore)
+	c.oneOf("lock_screen.managed_policy", cfg.LockScreen.ManagedPolicy,
+		config.ManagedPolicyReport, config.ManagedPolicyCoexist, config.ManagedPolicyIgnore)
+	c.oneOf("update.ring", cfg.Update.Ring, config.UpdateRingPilot, config.UpdateRingAuto, config.UpdateRingLast)
+	c.oneOf("vdi.mode", cfg.VDI.Mode, config.VDIModeAuto, config.VDIModePersistent, config.VDIModeNonPersistent)
+	c.oneOf("attribution.corner", cfg.Attribution.Corner, "top-left", "top-right", "bottom-left", "bottom-right")
+	redactions := []string{sysinfo.RedactShow, sysinfo.RedactMask, sysinfo.RedactHash, sysinfo.RedactOmit}
+	c.oneOf("redaction.hostname", cfg.Redaction.Hostname, redactions...)
+	c.oneOf("redaction.serial_number", cfg.Redaction.SerialNumber, redactions...)
+	c.oneOf("redaction.ip_addresses", cfg.Redaction.IPAddresses, redactions...)
+	c.oneOf("redaction.usernames", cfg.Redaction.Usernames, redactions...)
+	if cfg.TextScale < 0 {
+		c.add("text_scale", "must be positive")
+	}
+	if cfg.PanelTextScale.Services < 0 {
+		c.add("panel_text_scale.services", "must be positive")
+	}
+	if cfg.PanelTextScale.System < 0 {
+		c.add("panel_text_scale.system", "must be positive")
+	}
+
+	c.duration("min_interval", cfg.MinInterval)
+	c.duration("transition.duration", cfg.Transition.Duration)
+	if d, err := time.ParseDuration(cfg.Transition.Duration); err == nil && d > config.MaxTransitionTime {
+		c.add("transition.duration", "must be at most %v", config.MaxTransitionTime)
+	}
+	if f := cfg.Transition.Frames; f != 0 && (f < config.MinTransitionFrames || f > config.MaxTransitionFrames) {
+		c.add("transition.frames", "must be from %d to %d", config.MinTransitionFrames, config.MaxTransitionFrames)
+	}
+	c.duration("boot_wait", cfg.BootWait)
+	c.duration("resume_wait", cfg.ResumeWait)
+	c.duration("output.max_age", cfg.Output.MaxAge)
+	c.duration("lock_screen.burn_in.cycle", cfg.LockScreen.BurnIn.Cycle)
+	c.duration("collectors.backup_max_age", cfg.Collectors.BackupMaxAge)
+	c.duration("collectors.max_clock_drift", cfg.Collectors.MaxClockDrift)
+	c.check("tasks", installer.ValidateTasks(cfg.Tasks))
+
+	c.color("notice.background", cfg.Notice.Background)
+	c.color("notice.foreground", cfg.Notice.Foreground)
+	c.color("adjust.tint", cfg.Adjust.Tint)
+	if cfg.Adjust.Brightness < -100 || cfg.Adjust.Brightness > 100 {
+		c.add("adjust.brightness", "must be from -100 to 100")
+	}
+	if cfg.Adjust.Desaturate < 0 || cfg.Adjust.Desaturate > 100 {
+		c.add("adjust.desaturate", "must be from 0 to 100")
+	}
+	if cfg.Adjust.TintStrength < 0 || cfg.Adjust.TintStrength > 1 {
+		c.add("adjust.tint_strength", "must be from 0 to 1")
+	}
+	if cfg.Adjust.Vignette < 0 || cfg.Adjust.Vignette > 100 {
+		c.add("adjust.vignette", "must be from 0 to 100")
+	}
+	c.color("banner.background", cfg.Banner.Background)
+	c.color("banner.foreground", cfg.Banner.Foreground)
+	c.theme("theme", cfg.Theme)
+	for i, s := range cfg.Seasons {
+		c.color(fmt.Sprintf("seasons[%d].tint", i), s.Tint)
+	}
+	for i, p := range cfg.Profiles {
+		path := fmt.Sprintf("profiles[%d]", i)
+		if p.Name == "" {
+			c.add(path+".name", "name is required")
+		}
+		c.theme(path+".theme", p.Theme)
+		c.duration(path+".min_interval", p.MinInterval)
+	}
+
+	for i, mirror := range cfg.DownloadMirrors {
+		path := fmt.Sprintf("download_mirrors[%d]", i)
+		c.placeholders(path, mirror, "version", "file")
+		c.httpURL(path, placeholderPattern.ReplaceAllString(mirror, "x"))
+	}
+	c.placeholders("safety.classifier_command", cfg.Safety.ClassifierCommand, "file")
+	c.httpURL("safety.classifier_url", cfg.Safety.ClassifierURL)
+	for i, feed := range cfg.Calendar.ICSURLs {
+		c.httpURL(fmt.Sprintf("calendar.ics_urls[%d]", i), feed)
+	}
+	for i, lib := range cfg.Libraries {
+		path := fmt.Sprintf("libraries[%d]", i)
+		if lib.Name == "" {
+			c.add(path+".name", "name is required")
+		}
+		if lib.Type == "" {
+			c.add(path+".type", "type is required (s3, azure or webdav)")
+		}
+		c.oneOf(path+".type", lib.Type, "s3", "azure", "azureblob", "blob", "webdav")
+		c.httpURL(path+".url", lib.URL)
+		c.httpURL(path+".endpoint", lib.Endpoint)
+	}
+	if cfg.Publish.URL != "" {
+		c.check("publish.url", publish.ValidateURL(cfg.Publish.URL))
+	}
+	for i, output := range cfg.Outputs {
+		path := fmt.Sprintf("outputs[%d].url", i)
+		if output.URL == "" {
+			c.add(path, "url is required")
+			continue
+		}
+		c.check(path, publish.ValidateURL(output.URL))
+	}
+	if (cfg.Dashboard.TLSCertFile == "") != (cfg.Dashboard.TLSKeyFile == "") {
+		c.add("dashboard", "needs both tls_cert_file and tls_key_file for HTTPS")
+	}
+	if !cfg.Dashboard.Loopback() && !cfg.Dashboard.TLS() {
+		c.add("dashboard.listen", "serving beyond loopback needs tls_cert_file and tls_key_file")
+	}
+	if cfg.MQTT.Broker != "" {
+		c.check("mqtt.broker", publish.ValidateBroker(cfg.MQTT.Broker))
+	}
+	c.httpURL("thresholds.webhook_url", cfg.Thresholds.WebhookURL)
+	for i, rule := range cfg.Thresholds.Rules {
+		c.check(fmt.Sprintf("thresholds.rules[%d]", i), thresholds.ValidateRule(rule))
+	}
+
+	collectors := cfg.Collectors
+	if collectors.DHCPThreshold < 0 || collectors.DHCPThreshold > 100 {
+		c.add("collectors.dhcp_threshold", "must be a percentage from 0 to 100")
+	}
+	if collectors.DiskFullDays < 0 {
+		c.add("collectors.disk_full_days", "must be positive")
+	}
+	if collectors.RunHistory < 0 {
+		c.add("collectors.run_history", "must be positive")
+	}
+	for i, field := range collectors.AssetFields {
+		path := fmt.Sprintf("collectors.asset_fields[%d]", i)
+		if field.Label == "" {
+			c.add(path+".label", "label is required")
+		}
+		if field.Registry == "" && field.Env == "" {
+			c.add(path, "needs a registry value or an env variable")
+		}
+	}
+	for i, software := range collectors.RequiredSoftware {
+		path := fmt.Sprintf("collectors.required_software[%d]", i)
+		if software.Name == "" {
+			c.add(path+".name", "name is required")
+		}
+		if software.Service == "" && software.Path == "" {
+			c.add(path, "needs a service, a path or both")
+		}
+	}
+
+	return c.problems
+}
+
+// checkLayout checks every widget of the layout file.
+func checkLayout(layout *config.Layout, positions config.Positions) []config.Problem {
+	c := &configChecker{positions: positions}
+	for i, w := range layout.Widgets {
+		c.check(fmt.Sprintf("widgets[%d]", i), widgets.Validate(w))
+	}
+	return c.problems
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// collector is an optional section of the status panels, gathered on every
+// run when the config enables it.
+type collector struct {
+	// name identifies the section in the log, e.g. "Domain health".
+	name    string
+	enabled func(cfg *config.Config) bool
+	// right puts the section in the system information panel instead of the
+	// services panel.
+	right bool
+	// gather returns the section's lines and the problems to log as
+	// warnings. A section returned with an error is still drawn.
+	gather func(ctx context.Context, cfg *config.Config) (section, problems []string, err error)
+}
+
+// collectors are the optional sections, in the order they are drawn.
+var collectors = []collector{
+	{
+		name:    "Domain health",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.ADHealth },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			adHealth, err := sysinfo.GatherADHealth(ctx)
+			if adHealth == nil {
+				return nil, nil, err
+			}
+			return adHealth.FormatADHealthLines(), adHealth.Problems(), err
+		},
+	},
+	{
+		name:    "Local administrators",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.LocalAdmins },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			localAdmins, err := sysinfo.GatherLocalAdmins(ctx)
+			if localAdmins == nil {
+				return nil, nil, err
+			}
+			return localAdmins.FormatLocalAdminsLines(cfg.Redaction.Usernames), nil, err
+		},
+	},
+	{
+		name:    "Profile sizes",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.ProfileSizes },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			profileSizes, err := sysinfo.GatherProfileSizes(ctx, loginscreen.BackupDir)
+			if profileSizes == nil {
+				return nil, nil, err
+			}
+			return profileSizes.FormatProfileSizesLines(cfg.Redaction.Usernames), nil, err
+		},
+	},
+	{
+		name:    "Backup",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.Backup },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			backup, err := sysinfo.GatherBackup(ctx, cfg.Collectors.BackupMaxAgeDuration())
+			if backup == nil {
+				return nil, nil, err
+			}
+			return backup.FormatBackupLines(), backup.Problems(), err
+		},
+	},
+	{
+		name:    "Time sync",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.TimeSync },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			timeSync, err := sysinfo.GatherTimeSync(ctx, cfg.Collectors.MaxClockDriftDuration())
+			if timeSync == nil {
+				return nil, nil, err
+			}
+			return timeSync.FormatTimeSyncLines(), timeSync.Problems(), err
+		},
+	},
+	{
+		name:    "Remote access",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.RemoteAccess },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			remoteAccess, err := sysinfo.GatherRemoteAccess(ctx)
+			if remoteAccess == nil {
+				return nil, nil, err
+			}
+			return remoteAccess.FormatRemoteAccessLines(), remoteAccess.Problems(), err
+		},
+	},
+	{
+		name:    "DHCP",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.DHCP },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			dhcp, err := sysinfo.GatherDHCP(ctx, cfg.Collectors.DHCPThreshold)
+			if dhcp == nil {
+				return nil, nil, err
+			}
+			return dhcp.FormatDHCPLines(), dhcp.Problems(), err
+		},
+	},
+	{
+		name:    "Crashes",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.Crashes },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			crashes, err := sysinfo.GatherCrashes(ctx)
+			if crashes == nil {
+				return nil, nil, err
+			}
+			return crashes.FormatCrashLines(), nil, err
+		},
+	},
+	{
+		name:    "Asset",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.Asset },
+		right:   true,
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			asset, err := sysinfo.GatherAsset(ctx, cfg.Collectors.AssetRegistryKey, assetFields(cfg.Collectors.AssetFields))
+			if asset == nil {
+				return nil, nil, err
+			}
+			return asset.FormatAssetLines(), nil, err
+		},
+	},
+	{
+		name:    "Virtual machine",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.VM },
+		right:   true,
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			vm, err := sysinfo.GatherVM(ctx)
+			if vm == nil {
+				return nil, nil, err
+			}
+			return vm.FormatVMLines(), nil, err
+		},
+	},
+	{
+		name:    "Disk space",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.DiskTrend },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			diskTrend, err := sysinfo.GatherDiskTrend(ctx, stateDir(), cfg.Collectors.DiskFullDays)
+			if diskTrend == nil {
+				return nil, nil, err
+			}
+			return diskTrend.FormatDiskTrendLines(), diskTrend.Problems(), err
+		},
+	},
+	{
+		name:    "Processes",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.Processes },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			procs, err := sysinfo.GatherProcesses(ctx)
+			if procs == nil {
+				return nil, nil, err
+			}
+			return procs.FormatProcessLines(), nil, err
+		},
+	},
+	{
+		name:    "Logons",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.Logons },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			logons, err := sysinfo.GatherLogons(ctx)
+			if logons == nil {
+				return nil, nil, err
+			}
+			return logons.FormatLogonLines(redaction(cfg.Redaction)), nil, err
+		},
+	},
+	{
+		name:    "Required software",
+		enabled: func(cfg *config.Config) bool { return len(cfg.Collectors.RequiredSoftware) > 0 },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			software, err := sysinfo.GatherSoftware(ctx, softwareChecks(cfg.Collectors.RequiredSoftware))
+			if software == nil {
+				return nil, nil, err
+			}
+			return software.FormatSoftwareLines(), software.Problems(), err
+		},
+	},
+	{
+		name:    "VPN",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.VPN },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			vpn, err := sysinfo.GatherVPN(ctx)
+			if vpn == nil {
+				return nil, nil, err
+			}
+			return vpn.FormatVPNLines(), nil, err
+		},
+	},
+	{
+		name:    "BitLocker",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.BitLocker },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			bitLocker, err := sysinfo.GatherBitLocker(ctx)
+			if bitLocker == nil {
+				return nil, nil, err
+			}
+			return bitLocker.FormatBitLockerLines(), bitLocker.Problems(), err
+		},
+	},
+	{
+		name:    "Driver updates",
+		enabled: func(cfg *config.Config) bool { return cfg.Collectors.DriverUpdates },
+		gather: func(ctx context.Context, cfg *config.Config) ([]string, []string, error) {
+			driverUpdates, err := sysinfo.GatherDriverUpdates(ctx, loginscreen.BackupDir)
+			if driverUpdates == nil {
+				return nil, nil, err
+			}
+			return driverUpdates.FormatDriverUpdateLines(), nil, err
+		},
+	},
+}
+
+// runCollectors gathers the enabled sections and appends them to the panels.
+// Failures are logged; whatever the collector still returned is drawn, and a
+// collector that returned nothing leaves its section out.
+func runCollectors(ctx context.Context, elog debug.Log, cfg *config.Config, serviceLines, infoLines []string) ([]string, []string) {
+	for _, c := range collectors {
+		if !c.enabled(cfg) {
+			continue
+		}
+		elog.Info(1, fmt.Sprintf("%s: gathering...", c.name))
+		section, problems, err := c.gather(ctx, cfg)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("%s: %v (continuing anyway)", c.name, err))
+		}
+		if len(problems) > 0 {
+			elog.Warning(1, fmt.Sprintf("%s: %s", c.name, strings.Join(problems, "; ")))
+		}
+		if c.right {
+			infoLines = appendSection(infoLines, section)
+		} else {
+			serviceLines = appendSection(serviceLines, section)
+		}
+	}
+	return serviceLines, infoLines
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/compliance"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// version is the bgStatusService release version, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// recordCompliance writes the applied image to the compliance registry key.
+func recordCompliance(elog debug.Log, imagePath string, caps *capability.Report, managedPolicy string) {
+	err := compliance.Record(imagePath, serviceName, version, capability.Selected(caps.LoginScreen), managedPolicy)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to record compliance state: %v", err))
+	}
+}
+
+// runComplianceJSON prints the recorded compliance state, checked against the
+// image on disk, for an Intune custom compliance discovery script.
+func runComplianceJSON() error {
+	data, err := compliance.Check().JSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode compliance state: %v", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// dashboardPage is the status page. It refreshes itself every minute.
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>{{.Hostname}} - BgStatusService</title>
+<style>
+body { font-family: Segoe UI, sans-serif; background: #1a1a1a; color: #eee; margin: 2em; }
+a { color: #6cb6ff; }
+img { max-width: 100%; border: 1px solid #444; }
+table { border-collapse: collapse; margin-top: 1em; }
+td, th { padding: 0.25em 1em; text-align: left; border-bottom: 1px solid #333; }
+.fail { color: #ff6b6b; }
+</style>
+</head>
+<body>
+<h1>{{.Hostname}}</h1>
+{{if .Collected}}<p>Status collected {{.Collected}} - <a href="status.json">status.json</a> - <a href="runs.json">runs.json</a></p>{{else}}<p>No status collected yet.</p>{{end}}
+{{if .HasImage}}<p><a href="image"><img src="image" alt="Login screen"></a></p>{{end}}
+<h2>Recent runs</h2>
+<table>
+<tr><th>Started</th><th>Trigger</th><th>Duration</th><th>Result</th></tr>
+{{range .Runs}}<tr><td>{{.Start.Local.Format "Jan 2 15:04:05"}}</td><td>{{.Trigger}}</td><td>{{printf "%.1fs" .Duration}}</td><td>{{if .OK}}OK{{with .Warning}} ({{.}}){{end}}{{else}}<span class="fail">{{.Error}}</span>{{end}}</td></tr>
+{{else}}<tr><td colspan="4">No runs recorded yet.</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// loginPage asks a browser for the dashboard token once per session.
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sign in - BgStatusService</title>
+<style>
+body { font-family: Segoe UI, sans-serif; background: #1a1a1a; color: #eee; margin: 2em; }
+.fail { color: #ff6b6b; }
+</style>
+</head>
+<body>
+<h1>BgStatusService</h1>
+{{if .}}<p class="fail">{{.}}</p>{{end}}
+<form method="post" action="login">
+<label>Dashboard token <input type="password" name="token" autofocus></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// sessionCookie is the cookie the login page sets.
+const sessionCookie = "bgstatus_session"
+
+// runDashboard serves the status page until interrupted: --dashboard.
+func runDashboard() error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Warning: %v (using defaults)\n", err)
+	}
+	if cfg.Dashboard.Token == "" {
+		return fmt.Errorf("dashboard.token must be set in the config")
+	}
+	if !cfg.Dashboard.Loopback() && !cfg.Dashboard.TLS() {
+		return fmt.Errorf("dashboard.listen %s is not a loopback address; set dashboard.tls_cert_file and dashboard.tls_key_file to serve it to other machines",
+			cfg.Dashboard.ListenAddress())
+	}
+	session, err := newDashboardSession(cfg.Dashboard.Token, cfg.Dashboard.TLS())
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", session.login)
+	mux.HandleFunc("/", dashboardIndex)
+	mux.HandleFunc("/image", dashboardImage)
+	mux.HandleFunc("/status.json", dashboardFile(sysinfo.SnapshotFileName))
+	mux.HandleFunc("/runs.json", dashboardFile(runLogFileName))
+
+	server := &http.Server{
+		Addr:              cfg.Dashboard.ListenAddress(),
+		Handler:           session.require(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if cfg.Dashboard.TLS() {
+		fmt.Printf("Serving the status dashboard on https://%s/\n", server.Addr)
+		err = server.ListenAndServeTLS(cfg.Dashboard.TLSCertFile, cfg.Dashboard.TLSKeyFile)
+	} else {
+		fmt.Printf("Serving the status dashboard on http://%s/\n", server.Addr)
+		err = server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return fmt.Errorf("dashboard stopped: %v", err)
+}
+
+// dashboardSession authenticates dashboard requests: scripts send the token
+// as a bearer token, browsers sign in once and then send a session cookie.
+type dashboardSession struct {
+	token string
+	// secret is the session cookie's value. It is random for each run of the
+	// dashboard, so the token itself is never stored in a browser, and
+	// restarting the dashboard signs every browser out.
+	secret string
+	// secure marks the cookie HTTPS-only.
+	secure bool
+}
+
+// newDashboardSession returns the authentication for a dashboard run.
+func newDashboardSession(token string, secure bool) (*dashboardSession, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to create a session secret: %v", err)
+	}
+	return &dashboardSession{token: token, secret: hex.EncodeToString(secret), secure: secure}, nil
+}
+
+// authorized reports whether the request carries the bearer token or the
+// session cookie.
+func (s *dashboardSession) authorized(r *http.Request) bool {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return equalSecret(strings.TrimPrefix(auth, "Bearer "), s.token)
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	return err == nil && equalSecret(cookie.Value, s.secret)
+}
+
+// require rejects unauthorized requests, sending browsers that ask for the
+// page to the login page, and passes the rest on.
+func (s *dashboardSession) require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Path == "/login" || s.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/" && r.Header.Get("Authorization") == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// login shows the login form and, given the right token, sets the session
+// cookie.
+func (s *dashboardSession) login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	switch r.Method {
+	case http.MethodGet:
+		loginPage.Execute(w, "")
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !equalSecret(r.PostFormValue("token"), s.token) {
+		w.WriteHeader(http.StatusUnauthorized)
+		loginPage.Execute(w, "Wrong token.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.secret,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// equalSecret compares a given secret in constant time.
+func equalSecret(given, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(want)) == 1
+}
+
+// dashboardIndex renders the status page.
+func dashboardIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := struct {
+		Hostname  string
+		Collected string
+		HasImage  bool
+		Runs      []runRecord
+	}{
+		HasImage: latestRender() != "",
+		Runs:     loadRunLog(),
+	}
+	data.Hostname, _ = os.Hostname()
+	if snapshot, err := sysinfo.ReadSnapshot(filepath.Join(loginscreen.BackupDir, sysinfo.SnapshotFileName)); err == nil {
+		data.Collected = snapshot.CollectedAt.Local().Format("Jan 2, 2006 15:04:05")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	dashboardPage.Execute(w, data)
+}
+
+// dashboardImage serves the latest rendered login screen.
+func dashboardImage(w http.ResponseWriter, r *http.Request) {
+	path := latestRender()
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+// dashboardFile serves a JSON file from the data directory.
+func dashboardFile(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, filepath.Join(loginscreen.BackupDir, name))
+	}
+}
+
+// latestRender returns the newest render in the data directory, or "".
+func latestRender() string {
+	renders := renderFiles(loginscreen.BackupDir)
+	if len(renders) == 0 {
+		return ""
+	}
+	return renders[0]
+}
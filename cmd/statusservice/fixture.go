@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/backgroundchanger/internal/imageconv"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// fixtureSizes are the screen sizes rendered by --render-fixtures.
+var fixtureSizes = []sysinfo.DisplayResolution{
+	{Width: 1280, Height: 720},
+	{Width: 1920, Height: 1080},
+	{Width: 2560, Height: 1440},
+	{Width: 3840, Height: 2160},
+}
+
+// fixtureThemes are the plain backgrounds rendered, one per text color scheme.
+var fixtureThemes = []struct {
+	name       string
+	background color.Color
+}{
+	{"dark", color.Black},
+	{"light", color.RGBA{0xe8, 0xe8, 0xe8, 0xff}},
+}
+
+// runFixtures renders the overlays with the fixture data at every fixture size
+// and theme and writes them as PNGs to a directory. Nothing on the machine is
+// read (display resolution, system info, clock), so the output only changes when
+// the rendering code does. With --compare DIR the renders are checked against
+// reference PNGs of the same names instead, and any difference is an error.
+func runFixtures(args []string) error {
+	outDir, compareDir := "", ""
+	for i, arg := range args {
+		if arg == "--render-fixtures" && i+1 < len(args) {
+			outDir = args[i+1]
+		}
+		if arg == "--compare" && i+1 < len(args) {
+			compareDir = args[i+1]
+		}
+	}
+	if outDir == "" {
+		return fmt.Errorf("usage: --render-fixtures DIR [--compare REFERENCE_DIR]")
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	var mismatches int
+	for _, size := range fixtureSizes {
+		for _, theme := range fixtureThemes {
+			renders, err := renderFixture(size, theme.background)
+			if err != nil {
+				return fmt.Errorf("%dx%d %s: %v", size.Width, size.Height, theme.name, err)
+			}
+
+			for _, kind := range []string{"dual", "single"} {
+				name := fmt.Sprintf("fixture_%s_%dx%d_%s.png", kind, size.Width, size.Height, theme.name)
+				err = savePNG(renders[kind], filepath.Join(outDir, name))
+				if err != nil {
+					return err
+				}
+
+				if compareDir == "" {
+					fmt.Printf("Wrote %s\n", name)
+					continue
+				}
+				diff, err := comparePNG(renders[kind], filepath.Join(compareDir, name))
+				if err != nil {
+					fmt.Printf("[X]  %s: %v\n", name, err)
+					mismatches++
+				} else if diff > 0 {
+					fmt.Printf("[X]  %s: %d pixels differ\n", name, diff)
+					mismatches++
+				} else {
+					fmt.Printf("[OK] %s\n", name)
+				}
+			}
+		}
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d renders differ from the reference images in %s", mismatches, compareDir)
+	}
+	return nil
+}
+
+// renderFixture renders the dual-panel and single-panel overlays on a plain
+// background of the given size.
+func renderFixture(size sysinfo.DisplayResolution, background color.Color) (map[string]image.Image, error) {
+	newBackground := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, size.Width, size.Height))
+		draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+		return img
+	}
+
+	infoLines := sysinfo.FixtureSystemInfo().FormatLines()
+	serviceLines := sysinfo.FixtureServices().FormatServiceLines()
+
+	dual, err := overlay.RenderDualPanelOverlayForDisplay(newBackground(), serviceLines, infoLines, size, overlay.Options{})
+	if err != nil {
+		return nil, err
+	}
+	single, err := overlay.RenderOverlay(newBackground(), infoLines)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]image.Image{"dual": dual, "single": single}, nil
+}
+
+// savePNG writes an image as a PNG file.
+func savePNG(img image.Image, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if err := imageconv.EncodePNG(file, img); err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	return nil
+}
+
+// comparePNG returns the number of pixels that differ between img and the
+// reference PNG at path.
+func comparePNG(img image.Image, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("no reference image: %v", err)
+	}
+	defer file.Close()
+
+	ref, err := png.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode reference image: %v", err)
+	}
+	if ref.Bounds().Size() != img.Bounds().Size() {
+		return 0, fmt.Errorf("size %v, reference is %v", img.Bounds().Size(), ref.Bounds().Size())
+	}
+
+	var diff int
+	b, rb := img.Bounds(), ref.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r1, g1, b1, a1 := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := ref.At(rb.Min.X+x, rb.Min.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				diff++
+			}
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/backgroundchanger/internal/bginfo"
+	"github.com/backgroundchanger/internal/config"
+)
+
+// bgiConfigFileName is the converted settings, to merge into config.json by
+// hand since it only has the keys that were converted.
+const bgiConfigFileName = "config.bginfo.json"
+
+// runImportBGI converts a BGInfo configuration: --import-bgi FILE.bgi [--out
+// DIR]. It writes the converted settings and layout to DIR (the current
+// directory by default) without overwriting anything, and prints where each
+// field went.
+func runImportBGI(args []string) error {
+	bgiPath, outDir := "", "."
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "--import-bgi":
+			bgiPath = args[i+1]
+		case "--out":
+			outDir = args[i+1]
+		default:
+			continue
+		}
+		i++
+	}
+	if bgiPath == "" {
+		return fmt.Errorf("usage: --import-bgi FILE.bgi [--out DIR]")
+	}
+
+	data, err := os.ReadFile(bgiPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", bgiPath, err)
+	}
+	template, err := bginfo.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", bgiPath, err)
+	}
+	result := bginfo.Convert(template)
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", outDir, err)
+	}
+	var written []string
+	if len(result.Config) > 0 {
+		path := filepath.Join(outDir, bgiConfigFileName)
+		if err := writeNewJSON(path, result.Config); err != nil {
+			return err
+		}
+		written = append(written, path)
+	}
+	if result.Layout != nil {
+		path := filepath.Join(outDir, config.LayoutFileName)
+		if err := writeNewJSON(path, result.Layout); err != nil {
+			return err
+		}
+		written = append(written, path)
+	}
+
+	fmt.Printf("Imported %s:\n", bgiPath)
+	for _, note := range result.Notes {
+		fmt.Printf("  %s\n", note)
+	}
+	if len(written) == 0 {
+		fmt.Println("Nothing needs to change: the default panels already show every field.")
+		return nil
+	}
+	fmt.Println("\nWrote:")
+	for _, path := range written {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Printf("\nMerge the settings into %s and copy the layout next to it, then run --check-config.\n", config.Path())
+	return nil
+}
+
+// writeNewJSON writes v as indented JSON to a file that must not exist yet.
+func writeNewJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists; choose another directory with --out", path)
+		}
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -3,12 +3,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"log"
+	"math/rand"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	rtdebug "runtime/debug"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,13 +20,32 @@ import (
 	"golang.org/x/sys/windows/svc/debug"
 	"golang.org/x/sys/windows/svc/eventlog"
 
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/installer"
 	"github.com/backgroundchanger/internal/loginscreen"
 	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/publish"
 	"github.com/backgroundchanger/internal/sysinfo"
+	"github.com/backgroundchanger/internal/warranty"
+	"github.com/backgroundchanger/internal/widgets"
+	"github.com/backgroundchanger/internal/winapi"
 )
 
 const serviceName = "BgStatusService"
 
+// timeLimitMargin is how long before the task's execution time limit an update
+// gives up, so it can exit cleanly instead of being killed by Task Scheduler.
+const timeLimitMargin = 15 * time.Second
+
+// followUpDelay is how long after a boot run that rendered cached data the
+// follow-up run starts.
+const followUpDelay = 3 * time.Minute
+
+// stopGracePeriod is how long a stopping service waits for a cancelled update
+// to unwind (e.g. for a PowerShell call to be killed).
+const stopGracePeriod = 5 * time.Second
+
 // bgStatusService implements the Windows service interface.
 type bgStatusService struct {
 	elog debug.Log
@@ -35,26 +58,34 @@ func (s *bgStatusService) Execute(args []string, r <-chan svc.ChangeRequest, cha
 	changes <- svc.Status{State: svc.StartPending}
 	s.elog.Info(1, "Service starting...")
 
-	// Run the main task
-	err := runStatusUpdate(s.elog)
-	if err != nil {
-		s.elog.Error(1, fmt.Sprintf("Failed to update login screen: %v", err))
-	} else {
-		s.elog.Info(1, "Successfully updated login screen with system info")
-	}
+	// Run the main task in the background so a stop request can cancel it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- runStatusUpdate(ctx, s.elog)
+	}()
 
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 
-	// Wait for stop signal
+	// Wait for the update to finish and for the stop signal
 loop:
 	for {
 		select {
+		case err := <-done:
+			if err != nil {
+				s.elog.Error(1, fmt.Sprintf("Failed to update login screen: %v", err))
+			} else {
+				s.elog.Info(1, "Successfully updated login screen with system info")
+			}
+			done = nil
 		case c := <-r:
 			switch c.Cmd {
 			case svc.Interrogate:
 				changes <- c.CurrentStatus
 			case svc.Stop, svc.Shutdown:
 				s.elog.Info(1, "Service stopping...")
+				cancel()
 				break loop
 			default:
 				s.elog.Error(1, fmt.Sprintf("Unexpected control request #%d", c))
@@ -63,19 +94,89 @@ loop:
 	}
 
 	changes <- svc.Status{State: svc.StopPending}
+
+	// Let a cancelled update unwind before the process exits
+	if done != nil {
+		select {
+		case err := <-done:
+			if err != nil {
+				s.elog.Warning(1, fmt.Sprintf("Login screen update stopped: %v", err))
+			}
+		case <-time.After(stopGracePeriod):
+			s.elog.Warning(1, "Login screen update did not stop in time")
+		}
+	}
 	return
 }
 
-// runStatusUpdate performs the main task of updating the login screen.
-func runStatusUpdate(elog debug.Log) error {
+// runStatusUpdate performs the main task of updating the login screen. It stops
+// between steps, and aborts WMI queries, downloads and PowerShell calls, when ctx
+// is cancelled or the scheduled task's time limit is about to run out.
+func runStatusUpdate(ctx context.Context, elog debug.Log) (err error) {
 	elog.Info(1, "Starting login screen update...")
+	start := time.Now()
+	throttled := false
+	warnings := &warningLog{Log: elog}
+	elog = warnings
+	defer func() {
+		if !throttled {
+			recordRun(start, err, warnings.first)
+		}
+	}()
+
+	cfg, err := config.Load()
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to load config: %v (using defaults)", err))
+	}
+	if cfg.ActiveProfile != "" {
+		elog.Info(1, fmt.Sprintf("Using profile: %s", cfg.ActiveProfile))
+	}
+	// A config file deployed after the install would still be readable by Users
+	if err := config.Secure(); err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to restrict access to the config file: %v", err))
+	}
+
+	// Refresh runs within min_interval of the last update are skipped
+	if interval := cfg.MinIntervalDuration(); interval > 0 && runTrigger() == "refresh" {
+		if last := lastSuccessfulRun(); !last.IsZero() && time.Since(last) < interval {
+			elog.Info(1, fmt.Sprintf("Skipping update: the last one was %s ago (min_interval %s)",
+				time.Since(last).Round(time.Second), interval))
+			throttled = true
+			return nil
+		}
+	}
+
+	limit := installer.RunTimeLimit(cfg.Tasks, isBootMode)
+	if limit > 2*timeLimitMargin {
+		limit -= timeLimitMargin
+	}
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	// Keep peak memory low on thin clients
+	rtdebug.SetMemoryLimit(cfg.MemoryLimit())
+
+	// Report-only mode collects and reports but never touches the login screen;
+	// Server Core has none and gets the status as text
+	reportOnly := cfg.ReportOnly()
+	serverCore := !reportOnly && capability.Detect().OS.ServerCore
 
 	// Step 1: Determine the source image
 	var sourceImagePath string
 	var sourceImage image.Image
-	var err error
 
-	if loginscreen.HasBackup() {
+	if reportOnly {
+		elog.Info(1, "Report-only mode: the login screen is left unchanged")
+	} else if serverCore {
+		elog.Info(1, "Server Core: writing the status as text instead of a login screen image")
+	} else if cfg.Notice.Enabled {
+		// Kiosk notice mode ignores the wallpaper entirely
+		elog.Info(1, "Notice mode: rendering full-screen notice")
+		sourceImage, err = renderNotice(cfg.Notice, sysinfo.GetDisplayResolution(), cfg.ImageLimits)
+		if err != nil {
+			return fmt.Errorf("failed to render notice: %v", err)
+		}
+	} else if loginscreen.HasBackup() {
 		// Use the backed-up original image
 		sourceImagePath, err = loginscreen.GetBackupImage()
 		if err != nil {
@@ -86,9 +187,13 @@ func runStatusUpdate(elog debug.Log) error {
 		// Try to find the current login screen image
 		sourceImagePath, err = loginscreen.GetCurrentLoginScreenImage()
 		if err != nil {
-			elog.Info(1, "No existing login screen found, creating default background")
-			// Create a default dark background (1920x1080)
-			sourceImage = loginscreen.CreateDefaultBackground(1920, 1080)
+			if path, img, ok := localSourceImage(cfg.Sources, cfg.ImageLimits, elog); ok {
+				sourceImagePath, sourceImage = path, img
+			} else {
+				elog.Info(1, "No existing login screen found, creating default background")
+				// Create a default dark background (1920x1080)
+				sourceImage = loginscreen.CreateDefaultBackground(1920, 1080)
+			}
 		} else {
 			elog.Info(1, fmt.Sprintf("Found current login screen: %s", sourceImagePath))
 			// Backup the original image
@@ -101,27 +206,68 @@ func runStatusUpdate(elog debug.Log) error {
 		}
 	}
 
-	// Load the source image if we haven't created a default one
-	if sourceImage == nil {
-		sourceImage, err = loginscreen.LoadImage(sourceImagePath)
+	// Load the source image if we haven't created a default one, scaled down to
+	// the screen so 4K/8K wallpapers don't need several full-size buffers
+	if sourceImage == nil && !reportOnly && !serverCore {
+		displayRes := sysinfo.GetDisplayResolution()
+		sourceImage, err = loginscreen.LoadImageScaled(sourceImagePath, displayRes.Width, displayRes.Height, cfg.ImageLimits)
 		if err != nil {
 			return fmt.Errorf("failed to load source image: %v", err)
 		}
 	}
+	// Kiosk notices are generated legible already
+	if sourceImage != nil && !cfg.Notice.Enabled {
+		sourceImage = overlay.Adjust(sourceImage, overlay.Adjustments(cfg.Adjust))
+	}
 
 	// Step 2: Gather system information
+	if err := cancelled(ctx, "gathering system information"); err != nil {
+		return err
+	}
+	// At boot the WMI providers and DHCP can lag behind the task, which leaves
+	// the panels sparse; wait for them (bounded) before gathering
+	readiness := sysinfo.WaitForReady(ctx, 0)
+	if isBootMode && !readiness.Ready() {
+		elog.Info(1, fmt.Sprintf("Boot mode: %s, waiting up to %v...", readiness, cfg.BootWaitDuration()))
+		readiness = sysinfo.WaitForReady(ctx, cfg.BootWaitDuration())
+	}
+
 	elog.Info(1, "Gathering system information...")
-	sysInfo, err := sysinfo.Gather()
+	sysInfo, err := sysinfo.Gather(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to gather system info: %v", err)
 	}
 
-	infoLines := sysInfo.FormatLines()
+	if readiness.Ready() {
+		// The cache is only read at the next boot
+		if persistsAcrossReboot() {
+			if err := sysinfo.SaveCache(stateDir(), sysInfo); err != nil {
+				elog.Warning(1, fmt.Sprintf("Failed to cache system info: %v", err))
+			}
+		}
+	} else if isBootMode {
+		applyOfflineFallback(elog, sysInfo, readiness)
+	}
+
+	sysInfo.RebootAfterDays = cfg.RebootReminder.AfterDays
+	if sysInfo.RebootRecommended() {
+		elog.Warning(1, fmt.Sprintf("Uptime is %s, a reboot is recommended", sysInfo.Uptime))
+		if cfg.RebootReminder.Toast {
+			showToast(elog, "reboot", "Restart recommended",
+				fmt.Sprintf("This computer has been running for %s without a restart. Restart it soon so updates can finish installing.", sysInfo.Uptime))
+		}
+	}
+
+	infoLines := sysInfo.Redacted(redaction(cfg.Redaction)).FormatLines()
+	if vdi := detectVDI(); vdi.Kind != "" {
+		elog.Info(1, fmt.Sprintf("VDI: %s, non-persistent: %v, state in %s", vdi.Kind, vdi.NonPersistent, stateDir()))
+		infoLines = appendSection(infoLines, vdi.FormatVDILines())
+	}
 	elog.Info(1, fmt.Sprintf("System info: %d lines", len(infoLines)))
 
 	// Step 3: Gather services information
 	elog.Info(1, "Gathering services information...")
-	servicesInfo, err := sysinfo.GatherServices()
+	servicesInfo, err := sysinfo.GatherServices(ctx, cfg.WatchedServices...)
 	if err != nil {
 		elog.Warning(1, fmt.Sprintf("Failed to gather services info: %v (continuing anyway)", err))
 	}
@@ -133,17 +279,133 @@ func runStatusUpdate(elog debug.Log) error {
 			len(serviceLines), servicesInfo.RunningCount, len(servicesInfo.FailedServices)))
 	}
 
+	// Everything appended from here on is a calendar or widget section
+	baseServiceLines, baseInfoLines := len(serviceLines), len(infoLines)
+
+	// Step 3b: Gather optional panels enabled in the config file
+	if len(cfg.Calendar.ICSURLs) > 0 {
+		elog.Info(1, "Gathering calendar events...")
+		calendarInfo, err := sysinfo.GatherCalendar(ctx, cfg.Calendar.ICSURLs, cfg.Calendar.MaxEvents,
+			cfg.Calendar.DaysAhead, loginscreen.BackupDir)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to gather calendar: %v (continuing anyway)", err))
+		}
+		if calendarInfo != nil {
+			serviceLines = appendSection(serviceLines, calendarInfo.FormatCalendarLines())
+			elog.Info(1, fmt.Sprintf("Calendar: %d upcoming events", len(calendarInfo.Events)))
+		}
+	}
+
+	serviceLines, infoLines = runCollectors(ctx, elog, cfg, serviceLines, infoLines)
+
+	// The warranty is not a table collector: the threshold rules use its result too
+	var warrantyResult *warranty.Result
+	if cfg.Warranty.Enabled {
+		elog.Info(1, "Looking up warranty...")
+		result, err := warranty.Lookup(ctx, cfg.Warranty, sysinfo.GetManufacturer(ctx), sysInfo.SerialNumber,
+			sysinfo.GetProductNumber(ctx), loginscreen.BackupDir)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to look up warranty: %v (continuing anyway)", err))
+		}
+		if result != nil {
+			infoLines = appendSection(infoLines, []string{result.Line()})
+		}
+		warrantyResult = result
+	}
+
+	if cfg.Collectors.RunHistory > 0 {
+		serviceLines = appendSection(serviceLines, formatRunLines(loadRunLog(), cfg.Collectors.RunHistory, time.Now()))
+	}
+
+	// The security grade is drawn as its own badge rather than a panel section
+	var securityScore *sysinfo.SecurityScore
+	if cfg.Collectors.SecurityScore {
+		elog.Info(1, "Checking security posture...")
+		securityScore = sysinfo.GatherSecurityScore(ctx)
+	}
+
+	layout, err := config.LoadLayout()
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to load layout: %v (skipping widgets)", err))
+	}
+	now := time.Now()
+	for _, w := range layout.Widgets {
+		widgetLines, err := widgets.FormatLines(ctx, w, now)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Widget %q: %v", w.Type, err))
+		}
+		if w.Panel == config.PanelRight {
+			infoLines = appendSection(infoLines, widgetLines)
+		} else {
+			serviceLines = appendSection(serviceLines, widgetLines)
+		}
+	}
+
+	if len(cfg.Thresholds.Rules) > 0 {
+		elog.Info(1, "Evaluating thresholds...")
+		serviceLines = appendSection(serviceLines,
+			evaluateThresholds(ctx, elog, cfg.Thresholds, sysInfo.Hostname, servicesInfo, warrantyResult))
+	}
+
+	// Export the full status for other tools and --render-from
+	snapshot := sysinfo.NewSnapshot(sysInfo, servicesInfo)
+	snapshot.LeftSections = serviceLines[baseServiceLines:]
+	snapshot.RightSections = infoLines[baseInfoLines:]
+	snapshot.Security = securityScore
+	if err := snapshot.Save(loginscreen.BackupDir); err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to save status: %v", err))
+	}
+	// Healthy services may be shrunk to a summary line or hidden
+	serviceLines = snapshot.ServiceLines(cfg.ServicesDisplayMode())
+
+	var history *sysinfo.History
+	if cfg.HistoryGraph {
+		elog.Info(1, "Recording utilization sample...")
+		history, err = sysinfo.RecordSample(ctx, stateDir())
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to record sample: %v (continuing anyway)", err))
+		}
+	}
+
+	// A notice shows only the message unless the status panels were asked for
+	if cfg.Notice.Enabled && !cfg.Notice.ShowStatus {
+		serviceLines, infoLines, history, securityScore = nil, nil, nil, nil
+	}
+
+	if reportOnly {
+		reportStatus(ctx, elog, cfg, sysInfo.Hostname, servicesInfo, "")
+		elog.Info(1, "Status reported (report-only mode)")
+		return nil
+	}
+	if serverCore {
+		serviceLines = appendSection(serviceLines, securityLines(securityScore))
+		writeServerCoreStatus(elog, cfg.ServerCore, serviceLines, infoLines)
+		reportStatus(ctx, elog, cfg, sysInfo.Hostname, servicesInfo, "")
+		elog.Info(1, "Server Core status updated")
+		return nil
+	}
+
 	// Step 4: Render the dual-panel overlay
+	if err := cancelled(ctx, "rendering"); err != nil {
+		return err
+	}
 	elog.Info(1, "Rendering overlay...")
-	resultImage, err := overlay.RenderDualPanelOverlay(sourceImage, serviceLines, infoLines)
+	renderOpts := renderOptions(cfg, now)
+	resultImage, err := overlay.RenderDualPanelOverlay(sourceImage, serviceLines, infoLines, renderOpts)
 	if err != nil {
 		return fmt.Errorf("failed to render overlay: %v", err)
 	}
 
+	resultImage, bannerHeight := addBanner(elog, cfg.Banner, resultImage)
+	resultImage = addHistoryGraph(elog, resultImage, history, renderOpts, now, bannerHeight)
+	resultImage = addSecurityBadge(elog, resultImage, securityScore, renderOpts, bannerHeight)
+
 	// Step 5: Save the modified image to the permanent data directory
+	if err := cancelled(ctx, "saving the image"); err != nil {
+		return err
+	}
 	// Using a unique filename with timestamp to bypass Windows lock screen cache
-	timestamp := fmt.Sprintf("%d", time.Now().Unix())
-	outputPath := filepath.Join(loginscreen.BackupDir, "loginscreen_"+timestamp+".jpg")
+	outputPath := filepath.Join(loginscreen.BackupDir, cfg.Output.FileName(time.Now()))
 
 	err = loginscreen.SaveImage(resultImage, outputPath)
 	if err != nil {
@@ -151,22 +413,44 @@ func runStatusUpdate(elog debug.Log) error {
 	}
 	elog.Info(1, fmt.Sprintf("Saved modified image to: %s", outputPath))
 
-	// Clean up old loginscreen images (keep only the current one)
-	cleanupOldLoginScreenImages(loginscreen.BackupDir, outputPath)
+	// Clean up old loginscreen images beyond the configured retention
+	cleanupOldLoginScreenImages(loginscreen.BackupDir, outputPath, cfg.Output)
+
+	// Pre-render the other known resolutions so docking only swaps images
+	if cfg.DisplayVariants {
+		renderDisplayVariants(ctx, elog, cfg, sourceImagePath, serviceLines, infoLines, history, securityScore, now, outputPath)
+	}
 
 	// Step 6: Set the modified image as the login screen
 	elog.Info(1, "Setting login screen...")
-	err = loginscreen.SetLoginScreenImage(outputPath)
+	caps := capability.Detect()
+	elog.Info(1, fmt.Sprintf("Detected %s (%s), build %d; login screen methods: %s",
+		caps.OS.ProductName, caps.OS.EditionID, caps.OS.Build,
+		strings.Join(capability.Selected(caps.LoginScreen), ", ")))
+	managedPolicy, err := setLoginScreen(ctx, elog, cfg, outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to set login screen: %v", err)
+		return err
+	}
+	recordCompliance(elog, outputPath, caps, managedPolicy)
+
+	// Users who flipped the lock screen back to Spotlight no longer see our image
+	checkSpotlight(elog, cfg.LockScreen)
+
+	if cfg.LockScreen.Slideshow.Enabled {
+		updateSlideshow(elog, cfg.LockScreen.Slideshow, outputPath)
 	}
 
+	reportStatus(ctx, elog, cfg, sysInfo.Hostname, servicesInfo, outputPath)
+
 	// Step 7: Force restart LogonUI to display the new image (only at boot)
 	// This is necessary because LogonUI caches the background image at startup
 	// We only do this at boot (--boot flag) to avoid disrupting lock screen
 	if isBootMode {
+		if err := cancelled(ctx, "restarting LogonUI"); err != nil {
+			return err
+		}
 		elog.Info(1, "Boot mode: Restarting LogonUI to display new image...")
-		restartLogonUICleanly(elog)
+		restartLogonUICleanly(ctx, elog)
 	} else {
 		elog.Info(1, "Lock/manual mode: Skipping LogonUI restart")
 	}
@@ -175,11 +459,346 @@ func runStatusUpdate(elog debug.Log) error {
 	return nil
 }
 
+// reportStatus publishes the status, and the image when imagePath is set, to
+// the publish target and every output, and sends it to the MQTT broker, as
+// configured. Failures are logged.
+func reportStatus(ctx context.Context, elog debug.Log, cfg *config.Config, hostname string, services *sysinfo.ServicesSummary, imagePath string) {
+	files := publish.Files(hostname, imagePath, filepath.Join(loginscreen.BackupDir, sysinfo.SnapshotFileName))
+	for i, target := range append([]config.PublishConfig{cfg.Publish}, cfg.Outputs...) {
+		if target.URL == "" {
+			continue
+		}
+		name := "publish"
+		if i > 0 {
+			name = fmt.Sprintf("output %d", i)
+		}
+		if err := publish.Publish(ctx, target, files); err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to publish (%s): %v", name, err))
+		} else {
+			elog.Info(1, fmt.Sprintf("Published the run (%s)", name))
+		}
+	}
+
+	if cfg.MQTT.Broker != "" {
+		publishMQTT(ctx, elog, cfg.MQTT, hostname, services)
+	}
+}
+
+// renderNotice generates the full-screen kiosk notice at the given display resolution
+func renderNotice(notice config.NoticeConfig, res sysinfo.DisplayResolution, limits config.ImageLimitsConfig) (image.Image, error) {
+	opts := overlay.NoticeOptions{
+		Text:     notice.Text,
+		Subtitle: notice.Subtitle,
+	}
+
+	if notice.Background != "" {
+		c, err := overlay.ParseHexColor(notice.Background)
+		if err != nil {
+			return nil, err
+		}
+		opts.Background = c
+	}
+	if notice.Foreground != "" {
+		c, err := overlay.ParseHexColor(notice.Foreground)
+		if err != nil {
+			return nil, err
+		}
+		opts.Foreground = c
+	}
+	if notice.Logo != "" {
+		logo, err := loginscreen.LoadImage(notice.Logo, limits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load notice logo: %v", err)
+		}
+		opts.Logo = logo
+	}
+
+	return overlay.RenderNotice(res.Width, res.Height, opts)
+}
+
+// setNotice turns kiosk notice mode on (with the given text) or off in the config file
+func setNotice(enabled bool, text string) error {
+	cfg, err := config.LoadFrom(config.Path())
+	if err != nil {
+		return err
+	}
+
+	cfg.Notice.Enabled = enabled
+	if text != "" {
+		cfg.Notice.Text = text
+	}
+	if enabled && cfg.Notice.Text == "" {
+		return fmt.Errorf("no notice text given and none configured in %s", config.Path())
+	}
+
+	return config.Save(cfg)
+}
+
+// addHistoryGraph draws the 24-hour trend graph onto img when samples were
+// recorded, returning img unchanged if there are none or drawing fails
+func addHistoryGraph(elog debug.Log, img image.Image, history *sysinfo.History, opts overlay.Options, now time.Time, bottomInset float64) image.Image {
+	if history == nil || len(history.Samples) == 0 {
+		return img
+	}
+	graphImage, err := overlay.RenderHistoryGraph(img, "Last 24 hours",
+		historySeries(history, now), sysinfo.HistoryWindow, opts, now, bottomInset)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to render history graph: %v (continuing anyway)", err))
+		return img
+	}
+	return graphImage
+}
+
+// renderOptions converts the config settings the panels are drawn with for
+// overlay, for a render at now.
+func renderOptions(cfg *config.Config, now time.Time) overlay.Options {
+	opts := overlay.Options{
+		TextScale:         cfg.TextScaleFactor(),
+		ServicesTextScale: cfg.PanelTextScale.ServicesFactor(),
+		SystemTextScale:   cfg.PanelTextScale.SystemFactor(),
+		Theme:             overlay.Theme(cfg.Theme),
+		ImageLimits:       cfg.ImageLimits,
+	}
+	opts.Theme.PanelOpacity = cfg.Theme.Opacity()
+	switch cfg.LayoutDirectionMode() {
+	case config.LayoutDirectionRTL:
+		opts.RightToLeft = true
+	case config.LayoutDirectionAuto:
+		opts.RightToLeft = sysinfo.RightToLeftUILanguage()
+	}
+	// Seeded from the time, so the panels, graph and badge of one run, and its
+	// display variants, all move together
+	if burnIn := cfg.LockScreen.BurnIn; burnIn.Enabled {
+		opts.BurnIn = overlay.NewBurnIn(burnIn.Shift(), burnIn.CycleDuration(), now, rand.New(rand.NewSource(now.UnixNano())))
+	}
+	return opts
+}
+
+// redaction converts the redaction config for sysinfo.
+func redaction(r config.RedactionConfig) sysinfo.Redaction {
+	return sysinfo.Redaction{
+		Hostname:     r.Hostname,
+		SerialNumber: r.SerialNumber,
+		IPAddresses:  r.IPAddresses,
+		Usernames:    r.Usernames,
+	}
+}
+
+// assetFields converts the configured custom fields for the asset collector
+func assetFields(fields []config.AssetFieldConfig) []sysinfo.AssetField {
+	out := make([]sysinfo.AssetField, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, sysinfo.AssetField{Label: f.Label, Registry: f.Registry, Env: f.Env})
+	}
+	return out
+}
+
+// softwareChecks converts the configured required software into checks
+func softwareChecks(software []config.RequiredSoftwareConfig) []sysinfo.SoftwareCheck {
+	out := make([]sysinfo.SoftwareCheck, 0, len(software))
+	for _, s := range software {
+		out = append(out, sysinfo.SoftwareCheck{Name: s.Name, Service: s.Service, Path: s.Path})
+	}
+	return out
+}
+
+// historySeries converts the recorded samples into CPU, memory and network graph series
+func historySeries(history *sysinfo.History, now time.Time) []overlay.GraphSeries {
+	samples := history.Since(now.Add(-sysinfo.HistoryWindow))
+
+	cpu := overlay.GraphSeries{Max: 100, Color: overlay.GraphColorCPU}
+	memory := overlay.GraphSeries{Max: 100, Color: overlay.GraphColorMemory}
+	var cpuPeak, memPeak float64
+	for _, sample := range samples {
+		cpu.Points = append(cpu.Points, overlay.GraphPoint{Time: sample.Time, Value: sample.CPUPercent})
+		memory.Points = append(memory.Points, overlay.GraphPoint{Time: sample.Time, Value: sample.MemPercent})
+		if sample.CPUPercent > cpuPeak {
+			cpuPeak = sample.CPUPercent
+		}
+		if sample.MemPercent > memPeak {
+			memPeak = sample.MemPercent
+		}
+	}
+
+	var cpuNow, memNow float64
+	if len(samples) > 0 {
+		cpuNow = samples[len(samples)-1].CPUPercent
+		memNow = samples[len(samples)-1].MemPercent
+	}
+	cpu.Label = fmt.Sprintf("CPU  %.0f%% now, peak %.0f%%", cpuNow, cpuPeak)
+	memory.Label = fmt.Sprintf("RAM  %.0f%% now, peak %.0f%%", memNow, memPeak)
+
+	// Network has no natural maximum, so scale the chart to the busiest interval
+	network := overlay.GraphSeries{Color: overlay.GraphColorNetwork}
+	for _, rate := range history.NetworkRates(now.Add(-sysinfo.HistoryWindow)) {
+		network.Points = append(network.Points, overlay.GraphPoint{Time: rate.Time, Value: rate.Bytes})
+		if rate.Bytes > network.Max {
+			network.Max = rate.Bytes
+		}
+	}
+	network.Label = "Net  no data yet"
+	if len(network.Points) > 0 {
+		network.Label = fmt.Sprintf("Net  %s now, peak %s",
+			sysinfo.FormatRate(network.Points[len(network.Points)-1].Value), sysinfo.FormatRate(network.Max))
+	}
+
+	return []overlay.GraphSeries{cpu, memory, network}
+}
+
+// checkSpotlight finds signed-in users whose lock screen is set to Windows Spotlight
+// and, per lock_screen.spotlight, reports them or turns Spotlight off again
+func checkSpotlight(elog debug.Log, lockScreen config.LockScreenConfig) {
+	policy := lockScreen.SpotlightPolicy()
+	if policy == config.SpotlightIgnore {
+		return
+	}
+
+	for _, user := range capability.DetectSpotlightUsers() {
+		name := user.Name
+		if name == "" {
+			name = user.SID
+		}
+
+		if policy != config.SpotlightReassert {
+			elog.Warning(1, fmt.Sprintf("User %s switched the lock screen to Windows Spotlight (opted out of the status image)", name))
+			continue
+		}
+
+		err := loginscreen.ReassertOverSpotlight(user.SID)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to turn off Spotlight for %s: %v", name, err))
+		} else {
+			elog.Info(1, fmt.Sprintf("User %s had switched to Windows Spotlight - turned it off again", name))
+		}
+	}
+}
+
+// updateSlideshow adds the new render to the lock screen slideshow folder and
+// registers the folder as the slideshow of every signed-in user that doesn't
+// have it yet
+func updateSlideshow(elog debug.Log, slideshow config.SlideshowConfig, renderPath string) {
+	folder := slideshow.FolderPath()
+	if keep := slideshow.StatusImageCount(); keep > 0 {
+		err := loginscreen.AddSlideshowImage(renderPath, folder, keep)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to add the image to the slideshow: %v", err))
+			return
+		}
+	}
+
+	for _, sid := range capability.SignedInUsers() {
+		if loginscreen.SlideshowRegistered(sid, folder) {
+			continue
+		}
+		name := capability.AccountName(sid)
+		if name == "" {
+			name = sid
+		}
+
+		err := loginscreen.SetLockScreenSlideshowForUser(sid, folder)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to set the lock screen slideshow for %s: %v", name, err))
+		} else {
+			elog.Info(1, fmt.Sprintf("Set the lock screen slideshow for %s to %s", name, folder))
+		}
+	}
+}
+
+// runResume waits (bounded) for the network addresses to settle after resume
+// from sleep and runs an update only when they differ from the last status, so
+// the login screen doesn't keep showing the pre-sleep DHCP lease
+func runResume(ctx context.Context, elog debug.Log) error {
+	cfg, err := config.Load()
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to load config: %v (using defaults)", err))
+	}
+
+	addresses, settled := sysinfo.WaitForSettledAddresses(ctx, cfg.ResumeWaitDuration())
+	if !settled {
+		elog.Warning(1, fmt.Sprintf("Network did not settle within %v after resume", cfg.ResumeWaitDuration()))
+	}
+
+	snapshot, err := sysinfo.ReadSnapshot(filepath.Join(loginscreen.BackupDir, sysinfo.SnapshotFileName))
+	if err == nil && snapshot.SameAddresses(addresses) {
+		elog.Info(1, "Addresses unchanged after resume, skipping the update")
+		return nil
+	}
+	elog.Info(1, fmt.Sprintf("Addresses after resume: %s", strings.Join(addresses, ", ")))
+	return runStatusUpdate(ctx, elog)
+}
+
+// applyOfflineFallback fills what a boot run could not gather from the last
+// cached system info, marks the panel as offline at boot and schedules one
+// follow-up run to replace it with live data
+func applyOfflineFallback(elog debug.Log, sysInfo *sysinfo.SystemInfo, readiness sysinfo.Readiness) {
+	elog.Warning(1, fmt.Sprintf("Boot mode: %s, rendering cached data", readiness))
+
+	// Without DHCP the only addresses are APIPA ones
+	if !readiness.Network {
+		sysInfo.IPAddresses = nil
+	}
+	cached, savedAt, err := sysinfo.LoadCache(stateDir())
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("No cached system info: %v", err))
+		sysInfo.Status = "Offline at boot"
+	} else {
+		sysInfo.FillFromCache(cached, savedAt)
+	}
+
+	if isFollowUp {
+		return
+	}
+	err = installer.ScheduleFollowUpRun(followUpDelay)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to schedule a follow-up run: %v", err))
+	} else {
+		elog.Info(1, fmt.Sprintf("Scheduled a follow-up run in %v", followUpDelay))
+	}
+}
+
+// printCapabilities prints the capability report, as JSON when --json is also passed
+func printCapabilities() {
+	report := capability.Detect()
+	for _, arg := range os.Args[1:] {
+		if arg == "--json" {
+			data, err := report.JSON()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+	}
+	for _, line := range report.FormatLines() {
+		fmt.Println(line)
+	}
+}
+
+// cancelled returns an error naming the next step once ctx is done, so a stop
+// request or the time limit ends the update between steps.
+func cancelled(ctx context.Context, step string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("update cancelled before %s: %v", step, err)
+	}
+	return nil
+}
+
+// appendSection adds a block of lines to a panel, separated from the previous block by a blank line
+func appendSection(lines []string, section []string) []string {
+	if len(section) == 0 {
+		return lines
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return append(lines, section...)
+}
+
 // restartLogonUICleanly kills LogonUI and sends Escape to dismiss any password prompt
-func restartLogonUICleanly(elog debug.Log) {
+func restartLogonUICleanly(ctx context.Context, elog debug.Log) {
 	// Check if LogonUI is running (it won't be if a user is logged in without lock screen)
-	checkCmd := exec.Command("tasklist", "/fi", "imagename eq LogonUI.exe", "/fo", "csv", "/nh")
-	output, _ := checkCmd.Output()
+	output, _ := winapi.Commands.Run(ctx, "tasklist", "/fi", "imagename eq LogonUI.exe", "/fo", "csv", "/nh")
 	if !strings.Contains(string(output), "LogonUI.exe") {
 		elog.Info(1, "LogonUI not running (user may be logged in) - skipping restart")
 		return
@@ -187,12 +806,15 @@ func restartLogonUICleanly(elog debug.Log) {
 
 	// Kill LogonUI - Windows will automatically restart it
 	elog.Info(1, "Killing LogonUI.exe...")
-	killCmd := exec.Command("taskkill", "/f", "/im", "LogonUI.exe")
-	killCmd.Run()
+	winapi.Commands.Run(ctx, "taskkill", "/f", "/im", "LogonUI.exe")
 
 	// Wait for Windows to restart LogonUI
 	elog.Info(1, "Waiting for LogonUI to restart...")
-	time.Sleep(2 * time.Second)
+	select {
+	case <-time.After(2 * time.Second):
+	case <-ctx.Done():
+		return
+	}
 
 	// Send Escape key to dismiss password box and show clean lock screen
 	// Using PowerShell with low-level keybd_event API to work on secure desktop
@@ -217,8 +839,7 @@ public class KeySender {
 Start-Sleep -Milliseconds 500
 [KeySender]::SendEscape()
 `
-	escCmd := exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-Command", psScript)
-	if err := escCmd.Run(); err != nil {
+	if _, err := winapi.Commands.Run(ctx, "powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-Command", psScript); err != nil {
 		elog.Warning(1, fmt.Sprintf("Failed to send Escape key: %v", err))
 	} else {
 		elog.Info(1, "Escape key sent successfully")
@@ -234,7 +855,11 @@ func runInteractive() {
 	// Create a simple logger that outputs to stdout
 	logger := &consoleLog{}
 
-	err := runStatusUpdate(logger)
+	// Ctrl+C cancels the update like a service stop request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := runStatusUpdate(ctx, logger)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -260,42 +885,280 @@ func (l *consoleLog) Error(eid uint32, msg string) error {
 	return nil
 }
 
-// cleanupOldLoginScreenImages removes old loginscreen_*.jpg files except the current one
-func cleanupOldLoginScreenImages(dir, currentFile string) {
+// renderFiles returns the loginscreen_* renders in dir, newest first. Renders
+// are ordered by modification time, since the naming scheme can change.
+func renderFiles(dir string) []string {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return
+		return nil
 	}
 
+	type render struct {
+		path    string
+		modTime time.Time
+	}
+	var renders []render
 	for _, entry := range entries {
-		if entry.IsDir() {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if entry.IsDir() || !strings.HasPrefix(name, "loginscreen_") || (ext != ".jpg" && ext != ".png") {
 			continue
 		}
-		name := entry.Name()
-		// Only delete old loginscreen_*.jpg files
-		if strings.HasPrefix(name, "loginscreen_") && strings.HasSuffix(name, ".jpg") {
-			fullPath := filepath.Join(dir, name)
-			if fullPath != currentFile {
-				os.Remove(fullPath)
-			}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		renders = append(renders, render{filepath.Join(dir, name), info.ModTime()})
+	}
+	sort.Slice(renders, func(i, j int) bool { return renders[i].modTime.After(renders[j].modTime) })
+
+	paths := make([]string, len(renders))
+	for i, r := range renders {
+		paths[i] = r.path
+	}
+	return paths
+}
+
+// cleanupOldLoginScreenImages removes the renders beyond the newest
+// output.keep and those older than output.max_age, never the current one
+func cleanupOldLoginScreenImages(dir, currentFile string, output config.OutputConfig) {
+	maxAge := output.MaxAgeDuration()
+	kept := 1
+	for _, path := range renderFiles(dir) {
+		if path == currentFile {
+			continue
 		}
-		// Also delete legacy current_loginscreen.jpg
-		if name == "current_loginscreen.jpg" {
-			os.Remove(filepath.Join(dir, name))
+		if kept < output.KeepCount() {
+			info, err := os.Stat(path)
+			if err == nil && (maxAge == 0 || time.Since(info.ModTime()) <= maxAge) {
+				kept++
+				continue
+			}
 		}
+		os.Remove(path)
 	}
+
+	// Also delete legacy current_loginscreen.jpg
+	os.Remove(filepath.Join(dir, "current_loginscreen.jpg"))
 }
 
 // isBootMode checks if --boot flag was passed (used to trigger LogonUI restart)
 var isBootMode bool
 
+// isFollowUp is set for the run started by the follow-up task after a boot run
+// that rendered cached data
+var isFollowUp bool
+
+// isResume is set for the run started by the resume task
+var isResume bool
+
 func main() {
+	// --apply-lock-screen IMAGE is the session helper, started by the service in a user's session
+	for _, arg := range os.Args[1:] {
+		if arg == sessionHelperArg {
+			if err := runSessionHelper(os.Args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Check for --boot flag
 	for _, arg := range os.Args[1:] {
 		if arg == "--boot" {
 			isBootMode = true
-			break
 		}
+		if arg == installer.FollowUpArg {
+			isFollowUp = true
+		}
+		if arg == installer.ResumeArg {
+			isResume = true
+		}
+	}
+
+	// --notice "text" / --notice-off toggle kiosk notice mode, then refresh the login screen
+	for i, arg := range os.Args[1:] {
+		if arg != "--notice" && arg != "--notice-off" {
+			continue
+		}
+		text := ""
+		if arg == "--notice" && i+2 < len(os.Args) {
+			text = os.Args[i+2]
+		}
+		err := setNotice(arg == "--notice", text)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if arg == "--notice" {
+			fmt.Println("Notice mode enabled")
+		} else {
+			fmt.Println("Notice mode disabled")
+		}
+		break
+	}
+
+	// --bench [N] [--profile] times the collect and render stages without touching the login screen
+	for _, arg := range os.Args[1:] {
+		if arg == "--bench" {
+			err := runBench(os.Args[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --render-fixtures DIR [--compare REFERENCE_DIR] renders the overlays from fixed data for layout checks
+	for _, arg := range os.Args[1:] {
+		if arg == "--render-fixtures" {
+			err := runFixtures(os.Args[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --capabilities [--json] reports the detected edition, build, policies and chosen methods
+	for _, arg := range os.Args[1:] {
+		if arg == "--capabilities" {
+			printCapabilities()
+			return
+		}
+	}
+
+	// --support-bundle [PATH] zips the logs, config, tasks and registry state for a support ticket
+	for _, arg := range os.Args[1:] {
+		if arg == "--support-bundle" {
+			err := runSupportBundle(os.Args[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --compliance-json prints the last applied image for Intune discovery scripts
+	for _, arg := range os.Args[1:] {
+		if arg == "--compliance-json" {
+			err := runComplianceJSON()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --print-schema prints the config file's JSON Schema for editors and linters
+	for _, arg := range os.Args[1:] {
+		if arg == "--print-schema" {
+			os.Stdout.Write(config.JSONSchema)
+			return
+		}
+	}
+
+	// --import-bgi FILE.bgi [--out DIR] converts a BGInfo configuration
+	for _, arg := range os.Args[1:] {
+		if arg == "--import-bgi" {
+			err := runImportBGI(os.Args[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --check-config [PATH] validates a config file and its layout before it is deployed
+	for _, arg := range os.Args[1:] {
+		if arg == "--check-config" {
+			err := runCheckConfig(os.Args[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --render-from STATUS.json --out IMAGE renders the login screen for another machine's status
+	for _, arg := range os.Args[1:] {
+		if arg == "--render-from" {
+			err := runRenderFrom(os.Args[1:])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --dashboard serves the status page until stopped (run by the dashboard task)
+	for _, arg := range os.Args[1:] {
+		if arg == installer.DashboardArg {
+			err := runDashboard()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --snmp serves the status over SNMP until stopped (run by the SNMP task)
+	for _, arg := range os.Args[1:] {
+		if arg == installer.SNMPArg {
+			err := runSNMP()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --sample only records a utilization sample for the history graph (for a periodic task)
+	for _, arg := range os.Args[1:] {
+		if arg == "--sample" {
+			_, err := sysinfo.RecordSample(context.Background(), stateDir())
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --display-changed swaps in the image pre-rendered for the new resolution
+	for _, arg := range os.Args[1:] {
+		if arg == "--display-changed" {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err := runDisplayChanged(ctx, &consoleLog{})
+			stop()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --resume re-renders after resume from sleep once the network settles, if the addresses changed
+	if isResume {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err := runResume(ctx, &consoleLog{})
+		stop()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Check if we're running as a service
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/windows/svc/debug"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+)
+
+// setLoginScreen sets imagePath as the login screen. When Intune or Group
+// Policy pins the lock screen image, it reports the conflict or, with
+// lock_screen.managed_policy "coexist", writes the image into the file the
+// policy shows instead. It returns the policy and how it was handled for the
+// compliance state.
+func setLoginScreen(ctx context.Context, elog debug.Log, cfg *config.Config, imagePath string) (string, error) {
+	policy := loginscreen.DetectManagedPolicy()
+	mode := cfg.LockScreen.ManagedPolicyMode()
+
+	if policy != nil && mode == config.ManagedPolicyCoexist {
+		err := loginscreen.WriteManagedImage(policy, imagePath)
+		if err == nil {
+			elog.Info(1, fmt.Sprintf("Lock screen image is managed by %s: wrote the status image into %s", policy, policy.ImagePath))
+			return fmt.Sprintf("%s: %s", policy, mode), nil
+		}
+		elog.Warning(1, fmt.Sprintf("Lock screen image is managed by %s, and the status image could not be written into it (%v); setting it as usual", policy, err))
+		mode = config.ManagedPolicyReport
+	} else if policy != nil && mode == config.ManagedPolicyReport {
+		elog.Warning(1, fmt.Sprintf("Lock screen image is managed by your organization: %s. The policy replaces the status image whenever it is applied again; "+
+			"set lock_screen.managed_policy to \"coexist\" to write the status into the managed image instead", policy))
+	}
+
+	if err := setLoginScreenImage(ctx, elog, cfg, imagePath); err != nil {
+		return "", fmt.Errorf("failed to set login screen: %v", err)
+	}
+	if policy == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%s: %s", policy, mode), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/publish"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// publishMQTT sends status.json and the current metrics to the MQTT broker.
+// A failure is logged and doesn't fail the run.
+func publishMQTT(ctx context.Context, elog debug.Log, cfg config.MQTTConfig, hostname string, services *sysinfo.ServicesSummary) {
+	metrics, err := sysinfo.GatherMetrics(ctx)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to measure metrics for MQTT: %v (publishing the status only)", err))
+	}
+
+	statusPath := filepath.Join(loginscreen.BackupDir, sysinfo.SnapshotFileName)
+	if err := publish.PublishMQTT(ctx, cfg, hostname, statusPath, mqttMetrics(metrics, services)); err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to publish to MQTT: %v", err))
+		return
+	}
+	elog.Info(1, "Published the status to MQTT")
+}
+
+// mqttMetrics converts the measurements into MQTT metrics, e.g.
+// cpu_percent and disk_c_free_percent.
+func mqttMetrics(m *sysinfo.Metrics, services *sysinfo.ServicesSummary) []publish.Metric {
+	var metrics []publish.Metric
+	if m != nil {
+		metrics = append(metrics,
+			publish.Metric{Name: "cpu_percent", Value: fmt.Sprintf("%.1f", m.CPUPercent), Unit: "%"},
+			publish.Metric{Name: "memory_percent", Value: fmt.Sprintf("%.1f", m.MemPercent), Unit: "%"},
+			publish.Metric{Name: "uptime_seconds", Value: fmt.Sprintf("%d", m.UptimeSeconds), Unit: "s"},
+		)
+		volumes := make([]string, 0, len(m.DiskFreePercent))
+		for volume := range m.DiskFreePercent {
+			volumes = append(volumes, volume)
+		}
+		sort.Strings(volumes)
+		for _, volume := range volumes {
+			name := "disk_" + strings.ToLower(strings.TrimSuffix(volume, ":")) + "_free_percent"
+			metrics = append(metrics, publish.Metric{Name: name, Value: fmt.Sprintf("%.1f", m.DiskFreePercent[volume]), Unit: "%"})
+		}
+	}
+	if services != nil {
+		metrics = append(metrics, publish.Metric{Name: "failed_services", Value: fmt.Sprintf("%d", len(services.FailedServices))})
+	}
+	return metrics
+}
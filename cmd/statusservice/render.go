@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// defaultRenderSize is the screen size --render-from renders for without --size.
+var defaultRenderSize = sysinfo.DisplayResolution{Width: 1920, Height: 1080}
+
+// runRenderFrom renders the login screen for a status file exported by another
+// machine, without collecting anything locally, so a central server can render
+// images for thin clients:
+// --render-from STATUS.json --out IMAGE [--size WxH] [--background IMAGE]
+// The redaction and text_scale settings of this machine's config apply.
+func runRenderFrom(args []string) error {
+	statusPath, outPath, background := "", "", ""
+	size := defaultRenderSize
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "--render-from":
+			statusPath = args[i+1]
+		case "--out":
+			outPath = args[i+1]
+		case "--background":
+			background = args[i+1]
+		case "--size":
+			_, err := fmt.Sscanf(args[i+1], "%dx%d", &size.Width, &size.Height)
+			if err != nil || size.Width <= 0 || size.Height <= 0 {
+				return fmt.Errorf("invalid size %q, expected e.g. 1920x1080", args[i+1])
+			}
+		default:
+			continue
+		}
+		i++
+	}
+	if statusPath == "" || outPath == "" {
+		return fmt.Errorf("usage: --render-from STATUS.json --out IMAGE [--size WxH] [--background IMAGE]")
+	}
+
+	snapshot, err := sysinfo.ReadSnapshot(statusPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Warning: %v (using defaults)\n", err)
+	}
+
+	var source image.Image
+	if background != "" {
+		source, err = loginscreen.LoadImageScaled(background, size.Width, size.Height, cfg.ImageLimits)
+		if err != nil {
+			return fmt.Errorf("failed to load background: %v", err)
+		}
+	} else {
+		source = loginscreen.CreateDefaultBackground(size.Width, size.Height)
+	}
+	source = overlay.Adjust(source, overlay.Adjustments(cfg.Adjust))
+
+	snapshot.System.RebootAfterDays = cfg.RebootReminder.AfterDays
+	infoLines := snapshot.System.Redacted(redaction(cfg.Redaction)).FormatLines()
+	infoLines = append(infoLines, snapshot.RightSections...)
+	opts := renderOptions(cfg, snapshot.CollectedAt)
+	img, err := overlay.RenderDualPanelOverlayForDisplay(source, snapshot.ServiceLines(cfg.ServicesDisplayMode()), infoLines, size, opts)
+	if err != nil {
+		return fmt.Errorf("failed to render overlay: %v", err)
+	}
+	img = addSecurityBadge(&consoleLog{}, img, snapshot.Security, opts, 0)
+	if err := loginscreen.SaveImage(img, outPath); err != nil {
+		return fmt.Errorf("failed to save %s: %v", outPath, err)
+	}
+
+	fmt.Printf("Rendered %s (collected %s) to %s\n", snapshot.Hostname,
+		snapshot.CollectedAt.Local().Format("Jan 2, 3:04 PM"), outPath)
+	return nil
+}
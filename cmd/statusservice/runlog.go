@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// runLogFileName is the file in the data directory with the outcome of recent runs.
+const runLogFileName = "runs.json"
+
+// maxRunLogEntries is how many runs are kept, newest first.
+const maxRunLogEntries = 20
+
+// runRecord is the outcome of one login screen update.
+type runRecord struct {
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration_seconds"`
+	// Trigger is "boot", "follow-up", "resume" or "refresh" (lock, logon and
+	// manual runs).
+	Trigger string `json:"trigger"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	// Warning is the first warning logged by a run that still succeeded.
+	Warning string `json:"warning,omitempty"`
+}
+
+// maxRunMessage is how much of an error or warning the run panel shows.
+const maxRunMessage = 40
+
+// warningLog passes everything on to Log and remembers the first warning, so
+// a run that worked around a problem is not recorded as a plain success.
+type warningLog struct {
+	debug.Log
+	first string
+}
+
+func (l *warningLog) Warning(eid uint32, msg string) error {
+	if l.first == "" {
+		l.first = msg
+	}
+	return l.Log.Warning(eid, msg)
+}
+
+// runLogMu serializes read-modify-write of the run log within a process.
+var runLogMu sync.Mutex
+
+// runTrigger names what started this process's update.
+func runTrigger() string {
+	switch {
+	case isFollowUp:
+		return "follow-up"
+	case isBootMode:
+		return "boot"
+	case isResume:
+		return "resume"
+	}
+	return "refresh"
+}
+
+// loadRunLog returns the recorded runs, newest first. A missing or broken file
+// yields none.
+func loadRunLog() []runRecord {
+	var runs []runRecord
+	data, err := os.ReadFile(filepath.Join(stateDir(), runLogFileName))
+	if err == nil {
+		json.Unmarshal(data, &runs)
+	}
+	return runs
+}
+
+// lastSuccessfulRun returns when the newest successful run started, or the
+// zero time when none is recorded.
+func lastSuccessfulRun() time.Time {
+	for _, run := range loadRunLog() {
+		if run.OK {
+			return run.Start
+		}
+	}
+	return time.Time{}
+}
+
+// recordRun adds the outcome of an update that started at start to the run
+// log, with the first warning it logged.
+func recordRun(start time.Time, err error, warning string) {
+	runLogMu.Lock()
+	defer runLogMu.Unlock()
+
+	run := runRecord{
+		Start:    start,
+		Duration: time.Since(start).Seconds(),
+		Trigger:  runTrigger(),
+		OK:       err == nil,
+	}
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		run.Warning = warning
+	}
+
+	runs := append([]runRecord{run}, loadRunLog()...)
+	if len(runs) > maxRunLogEntries {
+		runs = runs[:maxRunLogEntries]
+	}
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return
+	}
+	os.MkdirAll(stateDir(), 0755)
+	os.WriteFile(filepath.Join(stateDir(), runLogFileName), data, 0644)
+}
+
+// formatRunLines returns the last count recorded runs for the panel, e.g.
+// "Today 7:14 AM boot WARNING: Failed to query WMI...". The current run is
+// not among them; it is recorded once it finishes.
+func formatRunLines(runs []runRecord, count int, now time.Time) []string {
+	if count <= 0 || len(runs) == 0 {
+		return nil
+	}
+	if len(runs) > count {
+		runs = runs[:count]
+	}
+
+	lines := []string{"Last Runs", ""}
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	for _, run := range runs {
+		start := run.Start.In(now.Location())
+		when := start.Format("Jan 2 3:04 PM")
+		if !start.Before(today) {
+			when = "Today " + start.Format("3:04 PM")
+		}
+
+		outcome := "OK"
+		switch {
+		case !run.OK:
+			outcome = "FAILED: " + shortRunMessage(run.Error)
+		case run.Warning != "":
+			outcome = "WARNING: " + shortRunMessage(run.Warning)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", when, run.Trigger, outcome))
+	}
+	return lines
+}
+
+// shortRunMessage cuts an error or warning down to its first line and
+// maxRunMessage characters.
+func shortRunMessage(msg string) string {
+	msg, _, _ = strings.Cut(msg, "\n")
+	runes := []rune(strings.TrimSpace(msg))
+	if len(runes) > maxRunMessage {
+		return string(runes[:maxRunMessage-3]) + "..."
+	}
+	return string(runes)
+}
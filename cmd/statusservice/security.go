@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// addSecurityBadge draws the security grade in the lower-left corner of img
+// when the score was collected, bottomInset pixels higher to clear the banner.
+func addSecurityBadge(elog debug.Log, img image.Image, score *sysinfo.SecurityScore, opts overlay.Options, bottomInset float64) image.Image {
+	if score == nil || len(score.Factors) == 0 {
+		return img
+	}
+	badge := overlay.BadgeOptions{
+		Grade:   score.Grade(),
+		Caption: fmt.Sprintf("Security %d/100", score.Score()),
+		Lines:   score.FormatLines(),
+	}
+	badgeImage, err := overlay.RenderBadge(img, badge, opts, bottomInset)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to render security badge: %v (continuing anyway)", err))
+		return img
+	}
+	return badgeImage
+}
+
+// securityLines returns the grade and factors as a text section, for Server
+// Core where there is no image to draw the badge on.
+func securityLines(score *sysinfo.SecurityScore) []string {
+	if score == nil || len(score.Factors) == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("Security: %s (%d/100)", score.Grade(), score.Score()), ""}
+	return append(lines, score.FormatLines()...)
+}
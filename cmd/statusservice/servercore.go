@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/servercore"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// writeServerCoreStatus writes the panels as text to the logon message and
+// the MOTD file, as configured. Failures are logged.
+func writeServerCoreStatus(elog debug.Log, cfg config.ServerCoreConfig, serviceLines, infoLines []string) {
+	lines := servercore.Text(infoLines, serviceLines)
+	// The (redacted) host name is the first info line
+	const title = "System status"
+
+	if cfg.LogonMessage() {
+		if err := servercore.WriteLogonMessage(title, lines); err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to write the logon message: %v", err))
+		} else {
+			elog.Info(1, "Wrote the status to the logon message")
+		}
+	}
+	if cfg.MOTD() {
+		if err := servercore.WriteMOTD(loginscreen.BackupDir, title, lines); err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to write the MOTD: %v", err))
+		} else {
+			elog.Info(1, "Wrote the status to the MOTD")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/debug"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/session"
+)
+
+// sessionHelperArg runs bgStatusService as the session helper: started by the
+// service in a signed-in user's session, with the user's token, it sets that
+// user's lock screen through WinRT and exits.
+const sessionHelperArg = "--apply-lock-screen"
+
+// sessionHelperTimeout is how long the service waits for the helper in one
+// session.
+const sessionHelperTimeout = time.Minute
+
+// runSessionHelper is the helper's side: --apply-lock-screen IMAGE.
+func runSessionHelper(args []string) error {
+	for i, arg := range args {
+		if arg == sessionHelperArg && i+1 < len(args) {
+			return loginscreen.SetUserLockScreenImage(context.Background(), args[i+1])
+		}
+	}
+	return fmt.Errorf("usage: %s IMAGE", sessionHelperArg)
+}
+
+// setLoginScreenImage sets the login screen. Running as SYSTEM with users
+// signed in, each user's lock screen is set by the session helper, and only the
+// methods that don't need the Group Policy or default image fallbacks run next
+// to it; those are kept for when no session can be reached, e.g. at boot
+// before anyone signs in, or lock_screen.disable_session_helper is set.
+func setLoginScreenImage(ctx context.Context, elog debug.Log, cfg *config.Config, imagePath string) error {
+	if cfg.LockScreen.DisableSessionHelper || !capability.Detect().Context.System {
+		return loginscreen.SetLoginScreenImage(ctx, imagePath, cfg.ImageLimits)
+	}
+
+	applied, err := applyInSessions(ctx, imagePath)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Session helper: %v; using the machine-wide methods", err))
+		return loginscreen.SetLoginScreenImage(ctx, imagePath, cfg.ImageLimits)
+	}
+	if applied == 0 {
+		return loginscreen.SetLoginScreenImage(ctx, imagePath, cfg.ImageLimits)
+	}
+	elog.Info(1, fmt.Sprintf("Set the lock screen in %d user session(s) through the session helper", applied))
+
+	err = loginscreen.SetLoginScreenImageExcept(ctx, imagePath, cfg.ImageLimits, capability.MethodGroupPolicy, capability.MethodDefaultImages)
+	if errors.Is(err, errs.ErrMethodUnsupported) {
+		// e.g. Home editions, where the helper is all there is
+		return nil
+	}
+	return err
+}
+
+// applyInSessions runs the session helper in every active session and returns
+// how many there were. It fails when the helper failed in any of them, so the
+// machine-wide fallbacks still cover that user.
+func applyInSessions(ctx context.Context, imagePath string) (int, error) {
+	sessions, err := session.Active()
+	if err != nil {
+		return 0, err
+	}
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find bgStatusService: %v", err)
+	}
+	command := windows.EscapeArg(exe) + " " + sessionHelperArg + " " + windows.EscapeArg(imagePath)
+
+	var problems []string
+	for _, id := range sessions {
+		runCtx, cancel := context.WithTimeout(ctx, sessionHelperTimeout)
+		code, err := session.Run(runCtx, id, command)
+		cancel()
+		if err == nil && code != 0 {
+			err = fmt.Errorf("exit code %d", code)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("session %d: %v", id, err))
+		}
+	}
+	if len(problems) > 0 {
+		return 0, fmt.Errorf("failed in %s", strings.Join(problems, "; "))
+	}
+	return len(sessions), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/snmp"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// runSNMP serves the last collected status over SNMP until interrupted: --snmp.
+func runSNMP() error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Warning: %v (using defaults)\n", err)
+	}
+	if cfg.SNMP.Community == "" {
+		return fmt.Errorf("snmp.community must be set in the config")
+	}
+	root, err := snmp.ParseOID(cfg.SNMP.RootOID())
+	if err != nil {
+		return fmt.Errorf("invalid snmp.base_oid: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", cfg.SNMP.ListenAddress())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", cfg.SNMP.ListenAddress(), err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	mib := &statusMIB{root: root, redaction: redaction(cfg.Redaction)}
+	fmt.Printf("Serving the status over SNMP on udp://%s/ under %s\n", conn.LocalAddr(), root)
+	return snmp.Serve(ctx, conn, cfg.SNMP.Community, mib.Variables)
+}
+
+// statusMIB maps the status file to SNMP variables under root, redacted like
+// the login screen:
+//
+//	root.1.1.0  hostname            root.2.1.0  running services
+//	root.1.2.0  collected at        root.2.2.0  stopped services
+//	root.1.3.0  status age (s)      root.2.3.0  total services
+//	root.1.4.0  OS                  root.2.4.0  failed services
+//	root.1.5.0  CPU                 root.3.1.N  failed service "name: state"
+//	root.1.6.0  RAM                 root.3.2.N  critical service "name: state"
+//	root.1.7.0  GPU                 root.4.N    IP address
+//	root.1.8.0  serial number       root.5.N    disk line
+//	root.1.9.0  uptime              root.6.N    left panel section line
+//	                                root.7.N    right panel section line
+type statusMIB struct {
+	root      snmp.OID
+	redaction sysinfo.Redaction
+
+	mu       sync.Mutex
+	modTime  time.Time
+	snapshot *sysinfo.Snapshot
+}
+
+// Variables returns the variables of the current status file, re-reading it
+// only when it changed.
+func (m *statusMIB) Variables() []snmp.Variable {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path := filepath.Join(loginscreen.BackupDir, sysinfo.SnapshotFileName)
+	if stat, err := os.Stat(path); err == nil && !stat.ModTime().Equal(m.modTime) {
+		if snapshot, err := sysinfo.ReadSnapshot(path); err == nil {
+			m.snapshot = snapshot
+			m.modTime = stat.ModTime()
+		}
+	}
+	if m.snapshot == nil {
+		return nil
+	}
+
+	s := m.snapshot
+	system := s.System.Redacted(m.redaction)
+	vars := []snmp.Variable{
+		{OID: m.root.Append(1, 1, 0), Value: system.Hostname},
+		{OID: m.root.Append(1, 2, 0), Value: s.CollectedAt.Format(time.RFC3339)},
+		{OID: m.root.Append(1, 3, 0), Value: snmp.Gauge(time.Since(s.CollectedAt) / time.Second)},
+		{OID: m.root.Append(1, 4, 0), Value: system.OS},
+		{OID: m.root.Append(1, 5, 0), Value: system.CPU},
+		{OID: m.root.Append(1, 6, 0), Value: system.RAM},
+		{OID: m.root.Append(1, 7, 0), Value: system.GPU},
+		{OID: m.root.Append(1, 8, 0), Value: system.SerialNumber},
+		{OID: m.root.Append(1, 9, 0), Value: system.Uptime},
+	}
+	if services := s.Services; services != nil {
+		vars = append(vars,
+			snmp.Variable{OID: m.root.Append(2, 1, 0), Value: snmp.Gauge(services.RunningCount)},
+			snmp.Variable{OID: m.root.Append(2, 2, 0), Value: snmp.Gauge(services.StoppedCount)},
+			snmp.Variable{OID: m.root.Append(2, 3, 0), Value: snmp.Gauge(services.TotalCount)},
+			snmp.Variable{OID: m.root.Append(2, 4, 0), Value: snmp.Gauge(len(services.FailedServices))},
+		)
+		for i, svc := range services.FailedServices {
+			vars = append(vars, snmp.Variable{OID: m.root.Append(3, 1, i+1), Value: svc.Name + ": " + svc.State})
+		}
+		for i, svc := range services.CriticalServices {
+			vars = append(vars, snmp.Variable{OID: m.root.Append(3, 2, i+1), Value: svc.Name + ": " + svc.State})
+		}
+	}
+	vars = appendLines(vars, m.root.Append(4), system.IPAddresses)
+	vars = appendLines(vars, m.root.Append(5), system.DiskInfo)
+	vars = appendLines(vars, m.root.Append(6), s.LeftSections)
+	vars = appendLines(vars, m.root.Append(7), s.RightSections)
+	return vars
+}
+
+// appendLines adds the non-blank lines as a column numbered from 1.
+func appendLines(vars []snmp.Variable, column snmp.OID, lines []string) []snmp.Variable {
+	n := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n++
+		vars = append(vars, snmp.Variable{OID: column.Append(n), Value: line})
+	}
+	return vars
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/imageconv"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// localSourceImage walks the configured source chain for a background when
+// there is no login screen image. Only local entries are used (image files,
+// folders and "color:#RRGGBB"): the service must not wait on downloads.
+// Exactly one of the returned path and image is set when ok.
+func localSourceImage(sources []string, limits config.ImageLimitsConfig, elog debug.Log) (string, image.Image, bool) {
+	for _, source := range sources {
+		if hex, ok := config.SourceColor(source); ok {
+			c, err := overlay.ParseHexColor(hex)
+			if err != nil {
+				elog.Warning(1, fmt.Sprintf("Source %s skipped: %v", source, err))
+				continue
+			}
+			res := sysinfo.GetDisplayResolution()
+			img := image.NewRGBA(image.Rect(0, 0, res.Width, res.Height))
+			draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+			elog.Info(1, fmt.Sprintf("Using source: %s", source))
+			return "", img, true
+		}
+
+		info, err := os.Stat(source)
+		if err != nil {
+			// Remote sources and "random" are for bgchanger only
+			continue
+		}
+		path := source
+		if info.IsDir() {
+			path, err = randomLocalImage(source)
+			if err != nil {
+				elog.Warning(1, fmt.Sprintf("Source %s skipped: %v", source, err))
+				continue
+			}
+		}
+		if err := loginscreen.CheckImageFile(path, limits); err != nil {
+			elog.Warning(1, fmt.Sprintf("Source %s skipped: %v", source, err))
+			continue
+		}
+		elog.Info(1, fmt.Sprintf("Using source: %s", path))
+		return path, nil, true
+	}
+	return "", nil, false
+}
+
+// randomLocalImage picks a random image file from a folder.
+func randomLocalImage(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var images []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		switch {
+		case ext == ".jpg", ext == ".jpeg", ext == ".png", ext == ".bmp", imageconv.Extensions[ext]:
+			images = append(images, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("no images in %s", dir)
+	}
+	return images[rand.Intn(len(images))], nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/installer"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// supportCommandTimeout bounds each command run while collecting the bundle.
+const supportCommandTimeout = 30 * time.Second
+
+// supportEventCount is how many of the newest BgStatusService events are exported.
+const supportEventCount = 500
+
+// redactedSecret replaces credentials in the bundled config.
+const redactedSecret = "<redacted>"
+
+// supportRegistryKeys are the keys read besides the ones in the registry journal.
+var supportRegistryKeys = []string{
+	`HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
+	`HKLM\SOFTWARE\Policies\Microsoft\Windows\Personalization`,
+	`HKLM\` + config.OverrideKeyPath,
+}
+
+// supportTaskNames are the scheduled tasks whose definitions are bundled.
+var supportTaskNames = []string{
+	installer.ScheduledTaskNameLock,
+	installer.ScheduledTaskNameBoot,
+	installer.ScheduledTaskNameResume,
+	installer.ScheduledTaskNameDisplay,
+	installer.ScheduledTaskNameFollowUp,
+	installer.ScheduledTaskNameDashboard,
+	installer.ScheduledTaskNameSNMP,
+	installer.ScheduledTaskNameMOTD,
+	installer.ScheduledTaskNameRotation,
+}
+
+// supportBundle writes the files of a support bundle into a zip.
+type supportBundle struct {
+	zw *zip.Writer
+	// notes lists what could not be collected, written last as errors.txt
+	notes []string
+}
+
+// add writes data to the bundle as name.
+func (b *supportBundle) add(name string, data []byte) {
+	w, err := b.zw.Create(name)
+	if err != nil {
+		b.notes = append(b.notes, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	w.Write(data)
+}
+
+// addFile copies a file into the bundle as name. A missing file is noted.
+func (b *supportBundle) addFile(name, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		b.notes = append(b.notes, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	defer f.Close()
+
+	w, err := b.zw.Create(name)
+	if err != nil {
+		b.notes = append(b.notes, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	io.Copy(w, f)
+}
+
+// addJSON writes v to the bundle as indented JSON.
+func (b *supportBundle) addJSON(name string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.notes = append(b.notes, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	b.add(name, data)
+}
+
+// addCommand runs a command and bundles its output; a failure is kept in the
+// output, since "not found" is useful too.
+func (b *supportBundle) addCommand(ctx context.Context, name string, command string, args ...string) {
+	ctx, cancel := context.WithTimeout(ctx, supportCommandTimeout)
+	defer cancel()
+
+	output, err := winapi.Commands.Run(ctx, command, args...)
+	if err != nil {
+		output = append(output, []byte(fmt.Sprintf("\n[%s %s: %v]\n", command, strings.Join(args, " "), err))...)
+	}
+	b.add(name, output)
+}
+
+// runSupportBundle collects what is needed to diagnose an installation into a
+// zip: --support-bundle [PATH]. Credentials in the config are redacted.
+func runSupportBundle(args []string) error {
+	path := ""
+	for i, arg := range args {
+		if arg == "--support-bundle" && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			path = args[i+1]
+		}
+	}
+	if path == "" {
+		host, _ := os.Hostname()
+		path = fmt.Sprintf("bgstatus-support-%s-%s.zip", host, time.Now().Format("20060102-150405"))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	b := &supportBundle{zw: zip.NewWriter(f)}
+	ctx := context.Background()
+
+	fmt.Println("Collecting version and capability information...")
+	b.add("version.txt", []byte(supportVersionInfo()))
+	if data, err := capability.Detect().JSON(); err == nil {
+		b.add("capabilities.json", data)
+	}
+
+	fmt.Println("Collecting configuration...")
+	if cfg, err := config.LoadFrom(config.Path()); err == nil {
+		b.addJSON("config/config.json", redactConfig(cfg))
+	} else if !os.IsNotExist(err) {
+		b.notes = append(b.notes, fmt.Sprintf("config/config.json: %v", err))
+	}
+	if cfg, err := config.Load(); err == nil {
+		b.addJSON("config/effective.json", redactConfig(cfg))
+	}
+	if layout, err := config.LoadLayout(); err == nil && len(layout.Widgets) > 0 {
+		for i := range layout.Widgets {
+			for k := range layout.Widgets[i].Headers {
+				layout.Widgets[i].Headers[k] = redactedSecret
+			}
+		}
+		b.addJSON("config/"+config.LayoutFileName, layout)
+	}
+
+	fmt.Println("Collecting logs...")
+	query := fmt.Sprintf("/q:*[System[Provider[@Name='%s']]]", serviceName)
+	b.addCommand(ctx, "logs/eventlog.txt", "wevtutil", "qe", "Application", query,
+		fmt.Sprintf("/c:%d", supportEventCount), "/rd:true", "/f:text")
+	crashLog := filepath.Join(os.TempDir(), "bgstatus_crash.log")
+	if _, err := os.Stat(crashLog); err == nil {
+		b.addFile("logs/bgstatus_crash.log", crashLog)
+	}
+
+	fmt.Println("Collecting the data directory...")
+	addDataDir(b)
+
+	fmt.Println("Collecting registry state...")
+	b.addFile("registry/"+journal.FileName, journal.Path())
+	fileName := strings.NewReplacer(`\`, "_", " ", "_")
+	for _, key := range supportRegistryKeysToQuery() {
+		b.addCommand(ctx, "registry/"+fileName.Replace(key)+".txt", "reg", "query", key, "/s")
+	}
+
+	fmt.Println("Collecting scheduled tasks...")
+	for _, name := range supportTaskNames {
+		b.addCommand(ctx, "tasks/"+name+".xml", "schtasks", "/query", "/tn", name, "/xml")
+	}
+
+	if len(b.notes) > 0 {
+		b.add("errors.txt", []byte(strings.Join(b.notes, "\r\n")+"\r\n"))
+	}
+	if err := b.zw.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	abs, _ := filepath.Abs(path)
+	fmt.Printf("Support bundle written to %s\n", abs)
+	return nil
+}
+
+// supportVersionInfo describes the installed executables.
+func supportVersionInfo() string {
+	var sb strings.Builder
+	exe, _ := os.Executable()
+	fmt.Fprintf(&sb, "Collected: %s\r\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Running: %s\r\n", exe)
+	for _, path := range []string{installer.GetInstalledExePath(), installer.GetInstalledChangerPath()} {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&sb, "%s: not installed\r\n", path)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %d bytes, modified %s\r\n", path, info.Size(), info.ModTime().Format(time.RFC3339))
+	}
+	return sb.String()
+}
+
+// addDataDir bundles the state files of the data directory and the newest
+// rendered image. Older renders and backups are only listed.
+func addDataDir(b *supportBundle) {
+	entries, err := os.ReadDir(loginscreen.BackupDir)
+	if err != nil {
+		b.notes = append(b.notes, fmt.Sprintf("data: %v", err))
+		return
+	}
+
+	var listing strings.Builder
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&listing, "%s  %10d  %s\r\n", info.ModTime().Format(time.RFC3339), info.Size(), entry.Name())
+
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+		case strings.HasPrefix(name, "loginscreen_"):
+		case strings.HasSuffix(name, ".json") && name != config.FileName && name != config.LayoutFileName && name != journal.FileName:
+			b.addFile("data/"+name, filepath.Join(loginscreen.BackupDir, name))
+		}
+	}
+	b.add("data/listing.txt", []byte(listing.String()))
+
+	if renders := renderFiles(loginscreen.BackupDir); len(renders) > 0 {
+		b.addFile("data/"+filepath.Base(renders[0]), renders[0])
+	}
+}
+
+// supportRegistryKeysToQuery returns the fixed keys and every key in the
+// registry journal, without duplicates.
+func supportRegistryKeysToQuery() []string {
+	keys := append([]string{}, supportRegistryKeys...)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[strings.ToLower(key)] = true
+	}
+
+	data, err := os.ReadFile(journal.Path())
+	if err != nil {
+		return keys
+	}
+	var j journal.Journal
+	if json.Unmarshal(data, &j) != nil {
+		return keys
+	}
+	for _, e := range j.Entries {
+		key := e.Root + `\` + e.Path
+		if !seen[strings.ToLower(key)] {
+			seen[strings.ToLower(key)] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// redactConfig blanks the credentials in a config and strips the paths and
+// queries of calendar feed URLs, which often embed a private token.
+func redactConfig(cfg *config.Config) *config.Config {
+	out := *cfg
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redactedSecret
+		}
+	}
+	redact(&out.UnsplashAccessKey)
+	redact(&out.APODAPIKey)
+
+	redact(&out.Dashboard.Token)
+	redact(&out.SNMP.Community)
+	redact(&out.Warranty.DellClientSecret)
+	redact(&out.Warranty.LenovoClientID)
+	redact(&out.Warranty.HPAPISecret)
+	redact(&out.Publish.Password)
+	redact(&out.MQTT.Password)
+	redact(&out.Thresholds.WebhookURL)
+	if len(cfg.Thresholds.WebhookHeaders) > 0 {
+		out.Thresholds.WebhookHeaders = map[string]string{}
+		for k := range cfg.Thresholds.WebhookHeaders {
+			out.Thresholds.WebhookHeaders[k] = redactedSecret
+		}
+	}
+	if len(cfg.Publish.Headers) > 0 {
+		out.Publish.Headers = map[string]string{}
+		for k := range cfg.Publish.Headers {
+			out.Publish.Headers[k] = redactedSecret
+		}
+	}
+	out.Outputs = append([]config.PublishConfig{}, cfg.Outputs...)
+	for i := range out.Outputs {
+		redact(&out.Outputs[i].Password)
+		if len(cfg.Outputs[i].Headers) > 0 {
+			out.Outputs[i].Headers = map[string]string{}
+			for k := range cfg.Outputs[i].Headers {
+				out.Outputs[i].Headers[k] = redactedSecret
+			}
+		}
+	}
+
+	out.Libraries = append([]config.LibraryConfig{}, cfg.Libraries...)
+	for i := range out.Libraries {
+		redact(&out.Libraries[i].SecretAccessKey)
+		redact(&out.Libraries[i].SessionToken)
+		redact(&out.Libraries[i].SASToken)
+		redact(&out.Libraries[i].Password)
+	}
+
+	out.Calendar.ICSURLs = nil
+	for _, raw := range cfg.Calendar.ICSURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			out.Calendar.ICSURLs = append(out.Calendar.ICSURLs, redactedSecret)
+			continue
+		}
+		out.Calendar.ICSURLs = append(out.Calendar.ICSURLs, u.Scheme+"://"+u.Host+"/"+redactedSecret)
+	}
+	return &out
+}
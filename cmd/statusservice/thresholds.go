@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/sysinfo"
+	"github.com/backgroundchanger/internal/thresholds"
+	"github.com/backgroundchanger/internal/warranty"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// evaluateThresholds checks the threshold rules, logs each breached rule with
+// its severity's event ID, tells the webhook about changes, toasts critical
+// findings when asked to and returns the panel section of breached rules.
+func evaluateThresholds(ctx context.Context, elog debug.Log, cfg config.ThresholdsConfig, hostname string, services *sysinfo.ServicesSummary, warrantyResult *warranty.Result) []string {
+	values := thresholdValues(ctx, elog, thresholds.Metrics(cfg.Rules), services, warrantyResult)
+	results, problems := thresholds.Evaluate(cfg.Rules, values)
+	if len(problems) > 0 {
+		elog.Warning(1, fmt.Sprintf("Thresholds: %s", strings.Join(problems, "; ")))
+	}
+
+	for _, r := range thresholds.Breached(results) {
+		message := fmt.Sprintf("Threshold %s: %s", r.Severity, r.Line())
+		if r.Severity == thresholds.Critical {
+			elog.Error(r.Severity.EventID(), message)
+			if cfg.Toast {
+				showToast(elog, "critical:"+strings.ToLower(r.Rule.Metric), "Critical: "+r.Label(), criticalToastText(r))
+			}
+		} else {
+			elog.Warning(r.Severity.EventID(), message)
+		}
+	}
+
+	posted, err := thresholds.Notify(ctx, cfg, stateDir(), hostname, results)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to post threshold change: %v", err))
+	} else if posted {
+		elog.Info(1, fmt.Sprintf("Posted threshold change (%s) to the webhook", thresholds.Worst(results)))
+	}
+	return thresholds.FormatLines(results)
+}
+
+// criticalToastText explains a critical finding to the signed-in user.
+func criticalToastText(r thresholds.Result) string {
+	return fmt.Sprintf("%s. Please save your work and contact IT support.", r.Line())
+}
+
+// thresholdValues collects the values the rules use. Slow sources, the
+// certificate store and Windows Update, are only read when a rule needs them.
+func thresholdValues(ctx context.Context, elog debug.Log, used map[string]bool, services *sysinfo.ServicesSummary, warrantyResult *warranty.Result) map[string]float64 {
+	values := make(map[string]float64)
+
+	metrics, err := sysinfo.GatherMetrics(ctx)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to measure metrics for thresholds: %v", err))
+	}
+	if metrics != nil {
+		values["cpu_percent"] = metrics.CPUPercent
+		values["memory_percent"] = metrics.MemPercent
+		values["uptime_days"] = float64(metrics.UptimeSeconds / 86400)
+		first := true
+		for volume, free := range metrics.DiskFreePercent {
+			drive := strings.ToLower(strings.TrimSuffix(volume, ":"))
+			values["disk_"+drive+"_free_percent"] = free
+			values["disk_"+drive+"_used_percent"] = 100 - free
+			if first || free < values["disk_free_percent"] {
+				values["disk_free_percent"] = free
+				values["disk_used_percent"] = 100 - free
+			}
+			first = false
+		}
+	}
+	if services != nil {
+		values["failed_services"] = float64(len(services.FailedServices))
+	}
+	if warrantyResult != nil {
+		values["warranty_days"] = float64(int(time.Until(warrantyResult.EndDate).Hours() / 24))
+	}
+
+	if used["cert_days"] {
+		if days, err := sysinfo.CertificateDaysLeft(ctx); err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to read certificate expiry: %v", err))
+		} else {
+			values["cert_days"] = days
+		}
+	}
+	if used["antivirus_enabled"] {
+		enabled, product, err := sysinfo.AntivirusEnabled(ctx)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to read antivirus status: %v", err))
+		} else {
+			values["antivirus_enabled"] = 0
+			if enabled {
+				values["antivirus_enabled"] = 1
+			} else {
+				elog.Warning(1, fmt.Sprintf("Antivirus real-time protection is off (%s)", product))
+			}
+		}
+	}
+	if used["pending_updates"] {
+		if count, err := sysinfo.PendingUpdates(ctx); err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to count pending updates: %v", err))
+		} else {
+			values["pending_updates"] = count
+		}
+	}
+	return values
+}
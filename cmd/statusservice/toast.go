@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/backgroundchanger/internal/notify"
+	"golang.org/x/sys/windows/svc/debug"
+)
+
+// toastInterval is how often a toast of the same kind is shown at most.
+const toastInterval = 24 * time.Hour
+
+// showToast shows a toast to the signed-in users unless one of the same kind
+// was shown within toastInterval. Failures are logged.
+func showToast(elog debug.Log, kind, title, text string) {
+	now := time.Now()
+	if !notify.Due(stateDir(), kind, toastInterval, now) {
+		return
+	}
+	shown, err := notify.Toast(title, text)
+	if err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to show %s toast: %v", kind, err))
+		return
+	}
+	if shown == 0 {
+		return
+	}
+	elog.Info(1, fmt.Sprintf("Showed %s toast in %d session(s)", kind, shown))
+	if err := notify.Record(stateDir(), kind, now); err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to record %s toast: %v", kind, err))
+	}
+}
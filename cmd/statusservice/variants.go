@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc/debug"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/overlay"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// variantsFileName is the file in the data directory listing the display
+// resolutions seen and the rendered image for each.
+const variantsFileName = "display_variants.json"
+
+// maxKnownResolutions is how many recently seen resolutions get a variant.
+const maxKnownResolutions = 4
+
+// displayVariant is a display resolution and the login screen rendered for it.
+// The pixel density last seen with it is kept so the variant's text is scaled
+// for that monitor.
+type displayVariant struct {
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	DPI            int       `json:"dpi,omitempty"`
+	DiagonalInches float64   `json:"diagonal_inches,omitempty"`
+	LastSeen       time.Time `json:"last_seen"`
+	Image          string    `json:"image,omitempty"`
+}
+
+// resolution returns the display the variant is rendered for.
+func (r displayVariant) resolution() sysinfo.DisplayResolution {
+	return sysinfo.DisplayResolution{Width: r.Width, Height: r.Height, DPI: r.DPI, DiagonalInches: r.DiagonalInches}
+}
+
+// displayVariants is the set of known resolutions, most recently seen first.
+type displayVariants struct {
+	Resolutions []displayVariant `json:"resolutions"`
+}
+
+// loadDisplayVariants reads the known resolutions; a missing or broken file
+// yields an empty set.
+func loadDisplayVariants() *displayVariants {
+	v := &displayVariants{}
+	data, err := os.ReadFile(filepath.Join(loginscreen.BackupDir, variantsFileName))
+	if err == nil {
+		json.Unmarshal(data, v)
+	}
+	return v
+}
+
+// save writes the known resolutions to the data directory.
+func (v *displayVariants) save() error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode display variants: %v", err)
+	}
+	return os.WriteFile(filepath.Join(loginscreen.BackupDir, variantsFileName), data, 0644)
+}
+
+// seen moves res to the front of the list (adding it if new) and drops the
+// least recently seen resolutions beyond maxKnownResolutions, deleting their images.
+func (v *displayVariants) seen(res sysinfo.DisplayResolution) *displayVariant {
+	entry := displayVariant{Width: res.Width, Height: res.Height}
+	rest := v.Resolutions[:0]
+	for _, r := range v.Resolutions {
+		if r.Width == res.Width && r.Height == res.Height {
+			entry = r
+		} else {
+			rest = append(rest, r)
+		}
+	}
+	entry.DPI = res.DPI
+	entry.DiagonalInches = res.DiagonalInches
+	entry.LastSeen = time.Now()
+	v.Resolutions = append([]displayVariant{entry}, rest...)
+
+	for len(v.Resolutions) > maxKnownResolutions {
+		removeVariantImage(v.Resolutions[len(v.Resolutions)-1].Image)
+		v.Resolutions = v.Resolutions[:len(v.Resolutions)-1]
+	}
+	return &v.Resolutions[0]
+}
+
+// find returns the entry for res, or nil if it is not known.
+func (v *displayVariants) find(res sysinfo.DisplayResolution) *displayVariant {
+	for i := range v.Resolutions {
+		if v.Resolutions[i].Width == res.Width && v.Resolutions[i].Height == res.Height {
+			return &v.Resolutions[i]
+		}
+	}
+	return nil
+}
+
+// removeVariantImage deletes an image rendered for another resolution. The main
+// loginscreen_* output is left to cleanupOldLoginScreenImages.
+func removeVariantImage(path string) {
+	if path != "" && strings.HasPrefix(filepath.Base(path), "variant_") {
+		os.Remove(path)
+	}
+}
+
+// variantSource returns the background for a resolution: the wallpaper scaled
+// to it, the kiosk notice rendered at it, or the default background, with
+// adjust applied to the wallpaper.
+func variantSource(cfg *config.Config, sourceImagePath string, res sysinfo.DisplayResolution) (image.Image, error) {
+	if cfg.Notice.Enabled {
+		return renderNotice(cfg.Notice, res, cfg.ImageLimits)
+	}
+	if sourceImagePath == "" {
+		return overlay.Adjust(loginscreen.CreateDefaultBackground(res.Width, res.Height), overlay.Adjustments(cfg.Adjust)), nil
+	}
+	source, err := loginscreen.LoadImageScaledCopy(sourceImagePath, res.Width, res.Height, cfg.ImageLimits)
+	if err != nil {
+		return nil, err
+	}
+	return overlay.Adjust(source, overlay.Adjustments(cfg.Adjust)), nil
+}
+
+// renderDisplayVariants records the current resolution with its output and
+// renders the same panels for the other recently seen resolutions, so a
+// display change only has to swap images (see runDisplayChanged).
+func renderDisplayVariants(ctx context.Context, elog debug.Log, cfg *config.Config, sourceImagePath string,
+	serviceLines, infoLines []string, history *sysinfo.History, security *sysinfo.SecurityScore, now time.Time, outputPath string) {
+	current := sysinfo.GetDisplayResolution()
+	variants := loadDisplayVariants()
+	variants.seen(current).Image = outputPath
+	opts := renderOptions(cfg, now)
+
+	for i := range variants.Resolutions {
+		r := &variants.Resolutions[i]
+		res := r.resolution()
+		if res.Width == current.Width && res.Height == current.Height {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		source, err := variantSource(cfg, sourceImagePath, res)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to load background for %dx%d: %v", res.Width, res.Height, err))
+			continue
+		}
+		img, err := overlay.RenderDualPanelOverlayForDisplay(source, serviceLines, infoLines, res, opts)
+		if err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to render %dx%d variant: %v", res.Width, res.Height, err))
+			continue
+		}
+		img, bannerHeight := addBanner(elog, cfg.Banner, img)
+		img = addHistoryGraph(elog, img, history, opts, now, bannerHeight)
+		img = addSecurityBadge(elog, img, security, opts, bannerHeight)
+
+		// A new name each time, like the main output, to bypass the lock screen cache
+		path := filepath.Join(loginscreen.BackupDir, fmt.Sprintf("variant_%dx%d_%d.jpg", res.Width, res.Height, now.Unix()))
+		if err := loginscreen.SaveImage(img, path); err != nil {
+			elog.Warning(1, fmt.Sprintf("Failed to save %dx%d variant: %v", res.Width, res.Height, err))
+			continue
+		}
+		if r.Image != path {
+			removeVariantImage(r.Image)
+		}
+		r.Image = path
+		elog.Info(1, fmt.Sprintf("Rendered %dx%d variant", res.Width, res.Height))
+	}
+
+	if err := variants.save(); err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to save display variants: %v", err))
+	}
+}
+
+// runDisplayChanged swaps in the variant rendered for the current resolution
+// without collecting the system info again. Without one it runs a full update.
+func runDisplayChanged(ctx context.Context, elog debug.Log) error {
+	cfg, _ := config.Load()
+	if cfg.ReportOnly() {
+		elog.Info(1, "Report-only mode: not swapping the login screen")
+		return nil
+	}
+
+	current := sysinfo.GetDisplayResolution()
+	variants := loadDisplayVariants()
+
+	r := variants.find(current)
+	if r == nil || r.Image == "" {
+		elog.Info(1, fmt.Sprintf("No variant for %dx%d yet, running a full update", current.Width, current.Height))
+		return runStatusUpdate(ctx, elog)
+	}
+	if _, err := os.Stat(r.Image); err != nil {
+		elog.Info(1, fmt.Sprintf("Variant for %dx%d is gone, running a full update", current.Width, current.Height))
+		return runStatusUpdate(ctx, elog)
+	}
+
+	elog.Info(1, fmt.Sprintf("Display is %dx%d, using %s", current.Width, current.Height, r.Image))
+	managedPolicy, err := setLoginScreen(ctx, elog, cfg, r.Image)
+	if err != nil {
+		return err
+	}
+	recordCompliance(elog, r.Image, capability.Detect(), managedPolicy)
+
+	variants.seen(current)
+	if err := variants.save(); err != nil {
+		elog.Warning(1, fmt.Sprintf("Failed to save display variants: %v", err))
+	}
+	return nil
+}
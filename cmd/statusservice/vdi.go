@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"sync"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+var (
+	vdiOnce          sync.Once
+	vdiInfo          sysinfo.VDIInfo
+	vdiPersistentDir string
+)
+
+// detectVDI detects the virtual desktop environment once per process, with
+// vdi.mode applied.
+func detectVDI() sysinfo.VDIInfo {
+	vdiOnce.Do(func() {
+		// A broken config still yields the defaults
+		cfg, _ := config.Load()
+		vdiInfo = sysinfo.DetectVDI(cfg.VDI.ImageVersionValue)
+		switch cfg.VDI.DetectionMode() {
+		case config.VDIModePersistent:
+			vdiInfo.NonPersistent = false
+		case config.VDIModeNonPersistent:
+			vdiInfo.NonPersistent = true
+		}
+		vdiPersistentDir = cfg.VDI.PersistentDir
+		if vdiInfo.NonPersistent && vdiPersistentDir != "" {
+			os.MkdirAll(vdiPersistentDir, 0755)
+		}
+	})
+	return vdiInfo
+}
+
+// stateDir returns the directory for the state kept between runs (run log,
+// history, offline cache, toast and alert times). On a non-persistent machine
+// with vdi.persistent_dir set that is the persistent disk, otherwise the data
+// directory next to the rendered images.
+func stateDir() string {
+	if detectVDI().NonPersistent && vdiPersistentDir != "" {
+		return vdiPersistentDir
+	}
+	return loginscreen.BackupDir
+}
+
+// persistsAcrossReboot reports whether state written to stateDir survives a
+// reboot. Writes that are only read after one are skipped when it doesn't.
+func persistsAcrossReboot() bool {
+	return !detectVDI().NonPersistent || vdiPersistentDir != ""
+}
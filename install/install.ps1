@@ -50,6 +50,18 @@ Write-Host "BgStatusService Installer" -ForegroundColor Cyan
 Write-Host "================================" -ForegroundColor Cyan
 Write-Host ""
 
+# On ARM64 prefer the native build when it sits next to the x64 one
+$NativeArch = if ($env:PROCESSOR_ARCHITEW6432) { $env:PROCESSOR_ARCHITEW6432 } else { $env:PROCESSOR_ARCHITECTURE }
+if ($ExePath -eq ".\bgStatusService.exe" -and $NativeArch -eq "ARM64") {
+    foreach ($Dir in @(".", (Split-Path $PSScriptRoot -Parent))) {
+        $Arm64ExePath = Join-Path $Dir "bgStatusService_arm64.exe"
+        if (Test-Path $Arm64ExePath) {
+            $ExePath = $Arm64ExePath
+            break
+        }
+    }
+}
+
 # Find the executable
 if (-not (Test-Path $ExePath)) {
     # Try looking in the parent directory
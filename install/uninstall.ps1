@@ -74,8 +74,46 @@ else {
     }
 }
 
+# Revert journaled registry changes (previous values recorded before each modification)
+$JournalFile = Join-Path $DataDir "registry_journal.json"
+$journalReplayed = $false
+if (Test-Path $JournalFile) {
+    Write-Host "Reverting registry changes from journal..." -ForegroundColor Cyan
+    $entries = @((Get-Content $JournalFile -Raw | ConvertFrom-Json).entries)
+    [array]::Reverse($entries)
+    $types = @{ 1 = "String"; 2 = "ExpandString"; 3 = "Binary"; 4 = "DWord"; 7 = "MultiString"; 11 = "QWord" }
+    foreach ($entry in $entries) {
+        $regPath = "$($entry.root):\$($entry.path)"
+        if ($entry.root -eq "HKU") { $regPath = "Registry::HKEY_USERS\$($entry.path)" }
+        try {
+            if ($entry.existed) {
+                $kind = $types[[int]$entry.type]
+                if (-not $kind) { $kind = "Binary" }
+                $value = switch ($kind) {
+                    "String" { $entry.string }
+                    "ExpandString" { $entry.string }
+                    "MultiString" { [string[]]$entry.strings }
+                    "DWord" { [int][uint32]$entry.integer }
+                    "QWord" { [long]$entry.integer }
+                    default { [Convert]::FromBase64String($entry.binary) }
+                }
+                if (-not (Test-Path $regPath)) { New-Item -Path $regPath -Force | Out-Null }
+                New-ItemProperty -Path $regPath -Name $entry.name -Value $value -PropertyType $kind -Force -ErrorAction Stop | Out-Null
+            }
+            elseif (Test-Path $regPath) {
+                Remove-ItemProperty -Path $regPath -Name $entry.name -ErrorAction SilentlyContinue
+            }
+            Write-Host "  Restored $($entry.root)\$($entry.path)\$($entry.name)" -ForegroundColor Green
+        }
+        catch {
+            Write-Host "  Could not restore $($entry.root)\$($entry.path)\$($entry.name): $_" -ForegroundColor Yellow
+        }
+    }
+    $journalReplayed = $true
+}
+
 # Restore original login screen from backup
-if (Test-Path $BackupFile) {
+if (-not $journalReplayed -and (Test-Path $BackupFile)) {
     Write-Host ""
     Write-Host "Found original login screen backup." -ForegroundColor Cyan
     
@@ -112,6 +150,23 @@ if (Test-Path $BackupFile) {
     }
 }
 
+# Put back the image of an Intune or Group Policy lock screen policy that
+# lock_screen.managed_policy "coexist" wrote the status image into
+$ManagedImageState = Join-Path $DataDir "managed_image.json"
+if (Test-Path $ManagedImageState) {
+    try {
+        $managed = Get-Content $ManagedImageState -Raw | ConvertFrom-Json
+        Copy-Item -Path $managed.backup -Destination $managed.path -Force -ErrorAction Stop
+        Write-Host "Restored the managed lock screen image $($managed.path)." -ForegroundColor Green
+    }
+    catch {
+        Write-Host "Note: Could not restore the managed lock screen image: $_" -ForegroundColor Yellow
+    }
+}
+
+# Remove the compliance state read by Intune discovery scripts
+Remove-Item -Path "HKLM:\SOFTWARE\BgStatusService\Compliance" -Recurse -Force -ErrorAction SilentlyContinue
+
 # Remove event log source
 Write-Host "Removing event log source..." -ForegroundColor Cyan
 try {
@@ -0,0 +1,83 @@
+// Package attribution stores author/title/license metadata for downloaded
+// wallpapers in a JSON sidecar file next to the image.
+package attribution
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Attribution describes where an image came from and how it may be used.
+type Attribution struct {
+	Title   string `json:"title,omitempty"`
+	Author  string `json:"author,omitempty"`
+	License string `json:"license,omitempty"`
+	// Source is the service the image came from, e.g. "Unsplash" or "Bing".
+	Source string `json:"source,omitempty"`
+	// URL links to the image's page at the source, for license compliance.
+	URL string `json:"url,omitempty"`
+}
+
+// SidecarPath returns the path of the sidecar file for an image
+// (e.g. wallpaper.jpg -> wallpaper.json).
+func SidecarPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".json"
+}
+
+// Save writes the attribution sidecar for an image.
+func Save(imagePath string, a *Attribution) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode attribution: %w", err)
+	}
+	if err := os.WriteFile(SidecarPath(imagePath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write attribution: %w", err)
+	}
+	return nil
+}
+
+// Load reads the attribution sidecar for an image.
+func Load(imagePath string) (*Attribution, error) {
+	data, err := os.ReadFile(SidecarPath(imagePath))
+	if err != nil {
+		return nil, err
+	}
+
+	var a Attribution
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to parse attribution: %w", err)
+	}
+	return &a, nil
+}
+
+// Remove deletes the attribution sidecar for an image, if any, so a stale
+// credit is never shown for a different image.
+func Remove(imagePath string) {
+	os.Remove(SidecarPath(imagePath))
+}
+
+// Line formats the attribution as a single short line, e.g.
+// "Mountain Lake by Jane Doe · Unsplash License · Unsplash".
+func (a *Attribution) Line() string {
+	var parts []string
+
+	switch {
+	case a.Title != "" && a.Author != "":
+		parts = append(parts, fmt.Sprintf("%s by %s", a.Title, a.Author))
+	case a.Title != "":
+		parts = append(parts, a.Title)
+	case a.Author != "":
+		parts = append(parts, "Photo by "+a.Author)
+	}
+	if a.License != "" {
+		parts = append(parts, a.License)
+	}
+	if a.Source != "" && a.Source != a.License {
+		parts = append(parts, a.Source)
+	}
+
+	return strings.Join(parts, " · ")
+}
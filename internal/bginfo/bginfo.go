@@ -0,0 +1,351 @@
+// Package bginfo reads the template of a Sysinternals BGInfo configuration
+// (.bgi) and converts it into collector settings and a layout, to ease the
+// migration from BGInfo.
+//
+// A .bgi file is binary; the part that matters, the text with its <Field>
+// placeholders, color and font, is an embedded RTF document. Only that
+// document is read, so positions, backgrounds and the definitions of custom
+// fields are not imported.
+package bginfo
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Template is the text BGInfo draws, one entry per line.
+type Template struct {
+	Lines []Line
+	// TextColor is the color of the first colored text as "#RRGGBB", or empty
+	// when the template uses the default.
+	TextColor string
+	Font      string
+	// FontSize is in points, 0 when not set.
+	FontSize float64
+}
+
+// Line is a line of the template, e.g. "Host Name:\t<Host Name>".
+type Line struct {
+	Text string
+	// Fields are the placeholder names on the line, without the brackets.
+	Fields []string
+}
+
+// Label returns the text before the first field, e.g. "Host Name", or the
+// whole line when it has no fields.
+func (l Line) Label() string {
+	label := l.Text
+	if i := strings.Index(label, "<"); i >= 0 && len(l.Fields) > 0 {
+		label = label[:i]
+	}
+	return strings.TrimRight(strings.TrimSpace(label), ":")
+}
+
+// fieldPattern matches a <Field Name> placeholder.
+var fieldPattern = regexp.MustCompile(`<([^<>\r\n]+)>`)
+
+// Parse finds the RTF template in a .bgi file and reads its lines, text color
+// and font.
+func Parse(data []byte) (*Template, error) {
+	start := bytes.Index(data, []byte(`{\rtf`))
+	if start < 0 {
+		return nil, fmt.Errorf("no RTF template found; is this a BGInfo .bgi file?")
+	}
+	end := groupEnd(data, start)
+	if end < 0 {
+		return nil, fmt.Errorf("the RTF template is truncated")
+	}
+
+	r := &rtfReader{data: data[start:end], fontSize: -1}
+	r.read()
+
+	t := &Template{Font: r.font(), TextColor: r.textColor()}
+	if r.fontSize > 0 {
+		t.FontSize = float64(r.fontSize) / 2
+	}
+	for _, text := range strings.Split(r.text.String(), "\n") {
+		text = strings.TrimRight(text, " \t\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		line := Line{Text: text}
+		for _, m := range fieldPattern.FindAllStringSubmatch(text, -1) {
+			line.Fields = append(line.Fields, strings.TrimSpace(m[1]))
+		}
+		t.Lines = append(t.Lines, line)
+	}
+	if len(t.Lines) == 0 {
+		return nil, fmt.Errorf("the template has no text")
+	}
+	return t, nil
+}
+
+// groupEnd returns the offset just past the RTF group starting at start, or
+// -1 when it is not closed.
+func groupEnd(data []byte, start int) int {
+	depth := 0
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++ // an escaped brace doesn't count
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// rtfReader extracts the plain text of an RTF document with its font and
+// color tables. It understands just enough RTF for BGInfo's templates.
+type rtfReader struct {
+	data []byte
+	pos  int
+	text strings.Builder
+
+	fonts    map[int]string
+	colors   []string
+	fontNum  int
+	colorNum int
+	fontSize int
+}
+
+// rtfDestinations are groups whose content is not text.
+var rtfDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "header": true, "footer": true, "generator": true,
+}
+
+func (r *rtfReader) read() {
+	r.fonts = map[int]string{}
+	r.fontNum, r.colorNum = -1, -1
+	r.group()
+}
+
+// group reads the text up to the end of the current group.
+func (r *rtfReader) group() {
+	first := true
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		switch c {
+		case '{':
+			r.pos++
+			r.group()
+		case '}':
+			r.pos++
+			return
+		case '\\':
+			word, param, hasParam := r.controlWord()
+			if first && rtfDestinations[word] {
+				r.destination(word)
+				return
+			}
+			if word == "*" {
+				r.skipGroup()
+				return
+			}
+			r.control(word, param, hasParam)
+		case '\r', '\n':
+			r.pos++
+		default:
+			r.pos++
+			r.text.WriteByte(c)
+		}
+		first = false
+	}
+}
+
+// destination reads a font or color table, or skips any other destination.
+func (r *rtfReader) destination(word string) {
+	switch word {
+	case "fonttbl":
+		r.fontTable()
+	case "colortbl":
+		r.colorTable()
+	default:
+		r.skipGroup()
+	}
+}
+
+// skipGroup moves past the end of the current group.
+func (r *rtfReader) skipGroup() {
+	depth := 1
+	for r.pos < len(r.data) && depth > 0 {
+		switch r.data[r.pos] {
+		case '\\':
+			r.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		r.pos++
+	}
+}
+
+// fontTable reads entries like {\f0\fswiss\fcharset0 Arial;}.
+func (r *rtfReader) fontTable() {
+	num := -1
+	var name strings.Builder
+	depth := 1
+	for r.pos < len(r.data) && depth > 0 {
+		c := r.data[r.pos]
+		switch c {
+		case '{':
+			depth++
+			r.pos++
+		case '}':
+			depth--
+			r.pos++
+		case '\\':
+			word, param, hasParam := r.controlWord()
+			if word == "f" && hasParam {
+				num = param
+				name.Reset()
+			}
+		case ';':
+			r.pos++
+			if num >= 0 {
+				r.fonts[num] = strings.TrimSpace(name.String())
+			}
+		default:
+			r.pos++
+			name.WriteByte(c)
+		}
+	}
+}
+
+// colorTable reads entries like \red255\green255\blue255; the first, empty
+// entry is the default color.
+func (r *rtfReader) colorTable() {
+	red, green, blue := 0, 0, 0
+	empty := true
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		switch c {
+		case '}':
+			r.pos++
+			return
+		case '\\':
+			word, param, _ := r.controlWord()
+			switch word {
+			case "red":
+				red, empty = param, false
+			case "green":
+				green, empty = param, false
+			case "blue":
+				blue, empty = param, false
+			}
+		case ';':
+			r.pos++
+			if empty {
+				r.colors = append(r.colors, "")
+			} else {
+				r.colors = append(r.colors, fmt.Sprintf("#%02X%02X%02X", red, green, blue))
+			}
+			red, green, blue, empty = 0, 0, 0, true
+		default:
+			r.pos++
+		}
+	}
+}
+
+// control applies a control word in the text: line breaks, tabs, escaped
+// characters and the first font, size and color used.
+func (r *rtfReader) control(word string, param int, hasParam bool) {
+	switch word {
+	case "par", "line":
+		r.text.WriteByte('\n')
+	case "tab":
+		r.text.WriteByte('\t')
+	case "'":
+		// A code page byte; Latin-1 covers the accented letters of labels
+		r.text.WriteRune(rune(param))
+	case "\\", "{", "}":
+		r.text.WriteString(word)
+	case "f":
+		if r.fontNum < 0 && hasParam {
+			r.fontNum = param
+		}
+	case "fs":
+		if r.fontSize < 0 && hasParam {
+			r.fontSize = param
+		}
+	case "cf":
+		if r.colorNum < 0 && hasParam && param > 0 {
+			r.colorNum = param
+		}
+	}
+}
+
+// controlWord reads a control word or symbol at the backslash at r.pos and
+// returns it with its numeric parameter. \'hh is returned as "'" with the
+// byte as parameter.
+func (r *rtfReader) controlWord() (string, int, bool) {
+	r.pos++ // the backslash
+	if r.pos >= len(r.data) {
+		return "", 0, false
+	}
+	c := r.data[r.pos]
+	if c == '\'' && r.pos+2 < len(r.data) {
+		v, err := strconv.ParseUint(string(r.data[r.pos+1:r.pos+3]), 16, 8)
+		r.pos += 3
+		if err != nil {
+			return "", 0, false
+		}
+		return "'", int(v), true
+	}
+	if !isLetter(c) {
+		r.pos++
+		return string(c), 0, false
+	}
+
+	start := r.pos
+	for r.pos < len(r.data) && isLetter(r.data[r.pos]) {
+		r.pos++
+	}
+	word := string(r.data[start:r.pos])
+
+	numStart := r.pos
+	if r.pos < len(r.data) && r.data[r.pos] == '-' {
+		r.pos++
+	}
+	for r.pos < len(r.data) && r.data[r.pos] >= '0' && r.data[r.pos] <= '9' {
+		r.pos++
+	}
+	param, err := strconv.Atoi(string(r.data[numStart:r.pos]))
+	hasParam := err == nil
+
+	// A single space ends the control word and is not text
+	if r.pos < len(r.data) && r.data[r.pos] == ' ' {
+		r.pos++
+	}
+	return word, param, hasParam
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// font returns the name of the first font used.
+func (r *rtfReader) font() string {
+	if r.fontNum < 0 {
+		return ""
+	}
+	return r.fonts[r.fontNum]
+}
+
+// textColor returns the first color used, if any.
+func (r *rtfReader) textColor() string {
+	if r.colorNum < 0 || r.colorNum >= len(r.colors) {
+		return ""
+	}
+	return r.colors[r.colorNum]
+}
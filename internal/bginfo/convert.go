@@ -0,0 +1,141 @@
+package bginfo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// fieldKind is how a BGInfo field carries over.
+type fieldKind int
+
+const (
+	// fieldBuiltIn is shown in the system information panel without settings.
+	fieldBuiltIn fieldKind = iota
+	// fieldCollector needs a collector turned on.
+	fieldCollector
+	// fieldDropped has no equivalent.
+	fieldDropped
+)
+
+// fieldMapping is where a BGInfo field's information is shown instead.
+type fieldMapping struct {
+	kind fieldKind
+	// collector is the JSON key under "collectors" for fieldCollector.
+	collector string
+	note      string
+}
+
+// builtInFields maps BGInfo's built-in fields, by lower-case name.
+var builtInFields = map[string]fieldMapping{
+	"host name":       {kind: fieldBuiltIn, note: "host name line"},
+	"os version":      {kind: fieldBuiltIn, note: "OS line"},
+	"service pack":    {kind: fieldBuiltIn, note: "OS line (build number)"},
+	"system type":     {kind: fieldBuiltIn, note: "OS line"},
+	"cpu":             {kind: fieldBuiltIn, note: "CPU line"},
+	"memory":          {kind: fieldBuiltIn, note: "RAM line"},
+	"ip address":      {kind: fieldBuiltIn, note: "IP address lines"},
+	"free space":      {kind: fieldBuiltIn, note: "disk lines"},
+	"volumes":         {kind: fieldBuiltIn, note: "disk lines"},
+	"boot time":       {kind: fieldBuiltIn, note: "uptime line"},
+	"snapshot time":   {kind: fieldBuiltIn, note: "update time line"},
+	"serial number":   {kind: fieldBuiltIn, note: "SN line"},
+	"logon domain":    {kind: fieldCollector, collector: "ad_health", note: "domain health section"},
+	"logon server":    {kind: fieldCollector, collector: "ad_health", note: "domain health section (the DC of the secure channel)"},
+	"machine domain":  {kind: fieldCollector, collector: "ad_health", note: "domain health section"},
+	"user name":       {kind: fieldCollector, collector: "logons", note: "recent logons section"},
+	"ie version":      {kind: fieldDropped, note: "Internet Explorer is retired"},
+	"mac address":     {kind: fieldDropped},
+	"default gateway": {kind: fieldDropped},
+	"dhcp server":     {kind: fieldDropped},
+	"dns server":      {kind: fieldDropped},
+	"subnet mask":     {kind: fieldDropped},
+	"network card":    {kind: fieldDropped},
+	"network speed":   {kind: fieldDropped},
+	"network type":    {kind: fieldDropped},
+}
+
+// Result is a converted BGInfo configuration.
+type Result struct {
+	// Config holds the settings to merge into config.json: "theme" and
+	// "collectors" only, so nothing else is reset.
+	Config map[string]interface{}
+	// Layout holds the template's fixed text as a table widget, or nil.
+	Layout *config.Layout
+	// Notes says where each field went, or why it could not be converted.
+	Notes []string
+}
+
+// Convert maps the template's fields to the built-in panel and collectors,
+// its fixed text lines (e.g. "Helpdesk: x4357") to a table widget and its
+// text color to the theme.
+func Convert(t *Template) *Result {
+	result := &Result{Config: map[string]interface{}{}}
+	collectors := map[string]bool{}
+	seen := map[string]bool{}
+	var rows []config.WidgetRow
+
+	for _, line := range t.Lines {
+		if len(line.Fields) == 0 {
+			key, value := splitRow(line.Text)
+			rows = append(rows, config.WidgetRow{Key: key, Value: value})
+			continue
+		}
+		for _, field := range line.Fields {
+			if seen[strings.ToLower(field)] {
+				continue
+			}
+			seen[strings.ToLower(field)] = true
+
+			mapping, ok := builtInFields[strings.ToLower(field)]
+			switch {
+			case !ok:
+				result.Notes = append(result.Notes, fmt.Sprintf(
+					"<%s>: custom field; add it to collectors.asset_fields with its registry value or environment variable, e.g. {\"label\": %q, \"registry\": \"HKLM\\\\...\"}",
+					field, line.Label()))
+			case mapping.kind == fieldBuiltIn:
+				result.Notes = append(result.Notes, fmt.Sprintf("<%s>: shown by default (%s)", field, mapping.note))
+			case mapping.kind == fieldCollector:
+				collectors[mapping.collector] = true
+				result.Notes = append(result.Notes, fmt.Sprintf("<%s>: collectors.%s turned on (%s)", field, mapping.collector, mapping.note))
+			default:
+				note := fmt.Sprintf("<%s>: no equivalent, dropped", field)
+				if mapping.note != "" {
+					note += " (" + mapping.note + ")"
+				}
+				result.Notes = append(result.Notes, note)
+			}
+		}
+	}
+
+	if len(collectors) > 0 {
+		result.Config["collectors"] = collectors
+	}
+	if t.TextColor != "" {
+		result.Config["theme"] = map[string]string{"text": t.TextColor}
+		result.Notes = append(result.Notes, fmt.Sprintf("Text color %s: theme.text", t.TextColor))
+	}
+	if t.Font != "" || t.FontSize > 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("Font %s %gpt: not converted; the panels use their own font, scaled with text_scale",
+			t.Font, t.FontSize))
+	}
+	if len(rows) > 0 {
+		result.Layout = &config.Layout{Widgets: []config.WidgetConfig{{
+			Type:  config.WidgetTable,
+			Panel: config.PanelRight,
+			Rows:  rows,
+		}}}
+		result.Notes = append(result.Notes, fmt.Sprintf("%d line(s) of fixed text: a table widget in the right panel", len(rows)))
+	}
+	return result
+}
+
+// splitRow splits fixed text into a table row at the first tab or colon, e.g.
+// "Helpdesk:\tx4357".
+func splitRow(text string) (string, string) {
+	if i := strings.IndexAny(text, "\t:"); i > 0 {
+		return strings.TrimSpace(strings.TrimRight(text[:i], ":")), strings.TrimSpace(strings.TrimLeft(text[i:], ":\t "))
+	}
+	return strings.TrimSpace(text), ""
+}
@@ -0,0 +1,407 @@
+// Package capability probes the Windows edition, build, process context and
+// policy state up front, and decides which lock/login screen methods can work
+// on this machine, so unsupported ones are skipped instead of failing noisily.
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Login screen methods, in order of preference.
+const (
+	MethodPersonalizationCSP = "personalization_csp"
+	MethodGroupPolicy        = "group_policy"
+	MethodDefaultImages      = "default_images"
+	MethodOOBE               = "oobe"
+	MethodWinRT              = "winrt"
+)
+
+// Lock screen methods used by bgchanger for the current user.
+const (
+	MethodLockRegistryHKCU = "lock_registry_hkcu"
+	MethodLockAssets       = "lock_assets"
+	MethodLockSystemData   = "lock_system_data"
+	MethodLockRegistryHKLM = "lock_registry_hklm"
+)
+
+// Windows builds that changed which methods work.
+const (
+	BuildWindows8  = 9200
+	BuildWindows10 = 10240
+	BuildWindows11 = 22000
+)
+
+// OSInfo describes the running Windows installation.
+type OSInfo struct {
+	ProductName    string `json:"product_name"`
+	EditionID      string `json:"edition_id"`
+	DisplayVersion string `json:"display_version,omitempty"`
+	Build          int    `json:"build"`
+	Server         bool   `json:"server"`
+	// ServerCore is a Server Core installation, which has no lock screen
+	// image; BgStatusService writes a text status instead.
+	ServerCore bool `json:"server_core,omitempty"`
+}
+
+// Context describes the process the methods will run in.
+type Context struct {
+	Admin  bool `json:"admin"`
+	System bool `json:"system"`
+}
+
+// Policies is the policy state that overrides or blocks the methods.
+type Policies struct {
+	// LockScreenImage is the Group Policy lock screen image already configured, if any.
+	LockScreenImage string `json:"lock_screen_image,omitempty"`
+	// CSPImagePath is the PersonalizationCSP image already configured, if any.
+	CSPImagePath string `json:"csp_image_path,omitempty"`
+	// NoChangingLockScreen prevents users from changing the lock screen image.
+	NoChangingLockScreen bool `json:"no_changing_lock_screen"`
+	// NoLockScreen hides the lock screen entirely.
+	NoLockScreen bool `json:"no_lock_screen"`
+	// DisableLogonBackgroundImage replaces the sign-in background with a solid color.
+	DisableLogonBackgroundImage bool `json:"disable_logon_background_image"`
+}
+
+// Method is the decision for one method.
+type Method struct {
+	Name      string `json:"name"`
+	Supported bool   `json:"supported"`
+	Reason    string `json:"reason"`
+}
+
+// Report is the result of Detect.
+type Report struct {
+	OS          OSInfo   `json:"os"`
+	Context     Context  `json:"context"`
+	Policies    Policies `json:"policies"`
+	LoginScreen []Method `json:"login_screen"`
+	LockScreen  []Method `json:"lock_screen"`
+	// Overlays are the settings that can cover the lock screen image (current user).
+	Overlays LockScreenOverlays `json:"lock_screen_overlays"`
+	// SpotlightUsers are signed-in users who switched their lock screen to Spotlight.
+	SpotlightUsers []SpotlightUser `json:"spotlight_users,omitempty"`
+}
+
+// Detect probes the system and decides which methods to use.
+func Detect() *Report {
+	r := &Report{
+		OS:       detectOS(),
+		Context:  detectContext(),
+		Policies: detectPolicies(),
+	}
+	r.LoginScreen = r.loginMethods()
+	r.LockScreen = r.lockMethods()
+	r.Overlays = DetectLockScreenOverlays(r.OS.Build)
+	if r.Context.Admin || r.Context.System {
+		r.SpotlightUsers = DetectSpotlightUsers()
+	}
+	return r
+}
+
+// detectOS reads the edition and build from the CurrentVersion key.
+func detectOS() OSInfo {
+	info := OSInfo{}
+
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE)
+	if err != nil {
+		return info
+	}
+	defer key.Close()
+
+	info.ProductName, _, _ = key.GetStringValue("ProductName")
+	info.EditionID, _, _ = key.GetStringValue("EditionID")
+	info.DisplayVersion, _, _ = key.GetStringValue("DisplayVersion")
+	if build, _, err := key.GetStringValue("CurrentBuildNumber"); err == nil {
+		info.Build, _ = strconv.Atoi(build)
+	}
+	if installType, _, err := key.GetStringValue("InstallationType"); err == nil {
+		info.Server = strings.HasPrefix(installType, "Server")
+		info.ServerCore = strings.EqualFold(installType, "Server Core")
+	}
+
+	// Windows 11 still reports "Windows 10" in ProductName
+	if info.Build >= BuildWindows11 {
+		info.ProductName = strings.Replace(info.ProductName, "Windows 10", "Windows 11", 1)
+	}
+
+	return info
+}
+
+// detectContext checks whether the process is elevated and whether it runs as SYSTEM.
+func detectContext() Context {
+	ctx := Context{}
+
+	ctx.Admin = winapi.Token.Elevated()
+	ctx.System = winapi.Token.LocalSystem()
+
+	return ctx
+}
+
+// detectPolicies reads the policies that affect the lock and login screen.
+func detectPolicies() Policies {
+	p := Policies{}
+
+	if key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Policies\Microsoft\Windows\Personalization`, registry.QUERY_VALUE); err == nil {
+		p.LockScreenImage, _, _ = key.GetStringValue("LockScreenImage")
+		p.NoChangingLockScreen = dwordSet(key, "NoChangingLockScreen")
+		p.NoLockScreen = dwordSet(key, "NoLockScreen")
+		key.Close()
+	}
+
+	if key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Policies\Microsoft\Windows\System`, registry.QUERY_VALUE); err == nil {
+		p.DisableLogonBackgroundImage = dwordSet(key, "DisableLogonBackgroundImage")
+		key.Close()
+	}
+
+	if key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`, registry.QUERY_VALUE); err == nil {
+		p.CSPImagePath, _, _ = key.GetStringValue("LockScreenImagePath")
+		key.Close()
+	}
+
+	return p
+}
+
+// dwordSet reports whether a DWORD value exists and is non-zero.
+func dwordSet(key winapi.RegistryKey, name string) bool {
+	v, _, err := key.GetIntegerValue(name)
+	return err == nil && v != 0
+}
+
+// isEnterprise reports whether the edition honors the lock screen image policy
+// (Enterprise, Education and Server SKUs).
+func (r *Report) isEnterprise() bool {
+	if r.OS.Server {
+		return true
+	}
+	edition := strings.ToLower(r.OS.EditionID)
+	return strings.Contains(edition, "enterprise") || strings.Contains(edition, "education")
+}
+
+// isHome reports whether this is a Home (Core) edition.
+func (r *Report) isHome() bool {
+	return strings.HasPrefix(strings.ToLower(r.OS.EditionID), "core")
+}
+
+// loginMethods decides the login screen methods in order of preference.
+func (r *Report) loginMethods() []Method {
+	build := r.OS.Build
+	var methods []Method
+
+	if r.OS.ServerCore {
+		for _, name := range []string{MethodPersonalizationCSP, MethodGroupPolicy, MethodDefaultImages, MethodOOBE, MethodWinRT} {
+			methods = append(methods, Method{name, false, "Server Core has no lock screen image"})
+		}
+		return methods
+	}
+
+	switch {
+	case build > 0 && build < BuildWindows10:
+		methods = append(methods, Method{MethodPersonalizationCSP, false, "requires Windows 10 or later"})
+	case r.isHome():
+		methods = append(methods, Method{MethodPersonalizationCSP, false, "not honored on Home editions"})
+	case !r.Context.Admin:
+		methods = append(methods, Method{MethodPersonalizationCSP, false, "requires administrator privileges"})
+	default:
+		methods = append(methods, Method{MethodPersonalizationCSP, true, "Windows 10+ " + r.OS.EditionID})
+	}
+
+	switch {
+	case !r.isEnterprise():
+		methods = append(methods, Method{MethodGroupPolicy, false, "policy only applies to Enterprise, Education and Server"})
+	case !r.Context.Admin:
+		methods = append(methods, Method{MethodGroupPolicy, false, "requires administrator privileges"})
+	default:
+		methods = append(methods, Method{MethodGroupPolicy, true, r.OS.EditionID + " honors the lock screen image policy"})
+	}
+
+	switch {
+	case build > 0 && build < BuildWindows8:
+		methods = append(methods, Method{MethodDefaultImages, false, "requires Windows 8 or later"})
+	case !r.Context.Admin:
+		methods = append(methods, Method{MethodDefaultImages, false, "requires administrator privileges"})
+	default:
+		methods = append(methods, Method{MethodDefaultImages, true, "default images in Web\\Screen"})
+	}
+
+	switch {
+	case build >= BuildWindows8:
+		methods = append(methods, Method{MethodOOBE, false, "OOBE backgrounds are only used before Windows 8"})
+	case !r.Context.Admin:
+		methods = append(methods, Method{MethodOOBE, false, "requires administrator privileges"})
+	default:
+		methods = append(methods, Method{MethodOOBE, true, "OEM background on Windows 7"})
+	}
+
+	switch {
+	case build > 0 && build < BuildWindows8:
+		methods = append(methods, Method{MethodWinRT, false, "requires Windows 8 or later"})
+	case r.Context.System:
+		methods = append(methods, Method{MethodWinRT, false, "not available when running as SYSTEM"})
+	case r.Policies.NoChangingLockScreen:
+		methods = append(methods, Method{MethodWinRT, false, "blocked by NoChangingLockScreen policy"})
+	default:
+		methods = append(methods, Method{MethodWinRT, true, "user context"})
+	}
+
+	return methods
+}
+
+// lockMethods decides the per-user lock screen methods.
+func (r *Report) lockMethods() []Method {
+	build := r.OS.Build
+	var methods []Method
+
+	switch {
+	case r.Context.System:
+		methods = append(methods, Method{MethodLockRegistryHKCU, false, "not available when running as SYSTEM"})
+	case build > 0 && build < BuildWindows10:
+		methods = append(methods, Method{MethodLockRegistryHKCU, false, "requires Windows 10 or later"})
+	default:
+		methods = append(methods, Method{MethodLockRegistryHKCU, true, "user context"})
+	}
+
+	switch {
+	case r.Context.System:
+		methods = append(methods, Method{MethodLockAssets, false, "not available when running as SYSTEM"})
+	case build > 0 && build < BuildWindows10:
+		methods = append(methods, Method{MethodLockAssets, false, "requires Windows 10 or later"})
+	default:
+		methods = append(methods, Method{MethodLockAssets, true, "user context"})
+	}
+
+	switch {
+	case build >= BuildWindows10:
+		methods = append(methods, Method{MethodLockSystemData, false, "SystemData is protected on Windows 10 and later"})
+	default:
+		methods = append(methods, Method{MethodLockSystemData, true, "Windows 8"})
+	}
+
+	switch {
+	case !r.Context.Admin:
+		methods = append(methods, Method{MethodLockRegistryHKLM, false, "requires administrator privileges"})
+	case r.isHome():
+		methods = append(methods, Method{MethodLockRegistryHKLM, false, "not honored on Home editions"})
+	default:
+		methods = append(methods, Method{MethodLockRegistryHKLM, true, "administrator context"})
+	}
+
+	return methods
+}
+
+// Supports reports whether the named method was selected. Unknown methods are
+// allowed so new methods are tried until they are added to the detection.
+func (r *Report) Supports(name string) bool {
+	for _, m := range append(r.LoginScreen, r.LockScreen...) {
+		if m.Name == name {
+			return m.Supported
+		}
+	}
+	return true
+}
+
+// Reason returns why the named method was selected or skipped.
+func (r *Report) Reason(name string) string {
+	for _, m := range append(r.LoginScreen, r.LockScreen...) {
+		if m.Name == name {
+			return m.Reason
+		}
+	}
+	return ""
+}
+
+// Selected returns the names of the supported methods in a list.
+func Selected(methods []Method) []string {
+	var names []string
+	for _, m := range methods {
+		if m.Supported {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// JSON encodes the report for --json output.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// FormatLines renders the report as human-readable lines.
+func (r *Report) FormatLines() []string {
+	var lines []string
+
+	name := r.OS.ProductName
+	if name == "" {
+		name = "Windows"
+	}
+	lines = append(lines, fmt.Sprintf("OS: %s (%s), build %d", name, r.OS.EditionID, r.OS.Build))
+
+	context := "user"
+	if r.Context.System {
+		context = "SYSTEM"
+	} else if r.Context.Admin {
+		context = "administrator"
+	}
+	lines = append(lines, fmt.Sprintf("Context: %s", context))
+
+	if r.Policies.LockScreenImage != "" {
+		lines = append(lines, fmt.Sprintf("Policy: LockScreenImage = %s", r.Policies.LockScreenImage))
+	}
+	if r.Policies.NoChangingLockScreen {
+		lines = append(lines, "Policy: NoChangingLockScreen is set")
+	}
+	if r.Policies.NoLockScreen {
+		lines = append(lines, "Policy: NoLockScreen is set (lock screen is hidden)")
+	}
+	if r.Policies.DisableLogonBackgroundImage {
+		lines = append(lines, "Policy: DisableLogonBackgroundImage is set")
+	}
+
+	if r.Overlays.Spotlight {
+		lines = append(lines, "Overlay: Windows Spotlight replaces the lock screen image")
+	}
+	if r.Overlays.FunFacts {
+		lines = append(lines, "Overlay: fun facts and tips are drawn over the lock screen")
+	}
+	if r.Overlays.Widgets {
+		lines = append(lines, "Overlay: lock screen widgets are enabled")
+	}
+	for _, user := range r.SpotlightUsers {
+		name := user.Name
+		if name == "" {
+			name = user.SID
+		}
+		lines = append(lines, fmt.Sprintf("Spotlight: %s has switched the lock screen to Windows Spotlight", name))
+	}
+
+	lines = append(lines, "", "Login screen methods:")
+	lines = append(lines, formatMethods(r.LoginScreen)...)
+	lines = append(lines, "", "Lock screen methods:")
+	lines = append(lines, formatMethods(r.LockScreen)...)
+
+	return lines
+}
+
+// formatMethods renders one line per method.
+func formatMethods(methods []Method) []string {
+	var lines []string
+	for _, m := range methods {
+		mark := "[X] "
+		if m.Supported {
+			mark = "[OK]"
+		}
+		lines = append(lines, fmt.Sprintf("  %s %-20s %s", mark, m.Name, m.Reason))
+	}
+	return lines
+}
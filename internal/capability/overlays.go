@@ -0,0 +1,140 @@
+package capability
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Registry locations of the lock screen overlay settings.
+const (
+	// ContentDeliveryManagerKey (HKCU) holds the per-user Spotlight and tips settings.
+	ContentDeliveryManagerKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\ContentDeliveryManager`
+	// WidgetsPolicyKey (HKLM) holds the policy that turns off Windows 11 widgets,
+	// including the lock screen weather/markets widgets.
+	WidgetsPolicyKey = `SOFTWARE\Policies\Microsoft\Dsh`
+)
+
+// Overlay setting value names.
+const (
+	ValueRotatingLockScreen        = "RotatingLockScreenEnabled"
+	ValueRotatingLockScreenOverlay = "RotatingLockScreenOverlayEnabled"
+	ValueLockScreenTips            = "SubscribedContent-338387Enabled"
+	ValueAllowNewsAndInterests     = "AllowNewsAndInterests"
+)
+
+// LockScreenOverlays is the state of the settings that can cover the lock screen image.
+type LockScreenOverlays struct {
+	// Spotlight replaces the image with a rotating Windows Spotlight picture.
+	Spotlight bool `json:"spotlight"`
+	// FunFacts draws the "fun facts, tips, tricks and more" text over the image.
+	FunFacts bool `json:"fun_facts"`
+	// Widgets shows the Windows 11 lock screen widgets (weather, markets, ...).
+	Widgets bool `json:"widgets"`
+}
+
+// Any reports whether any overlay is active.
+func (o LockScreenOverlays) Any() bool {
+	return o.Spotlight || o.FunFacts || o.Widgets
+}
+
+// DetectLockScreenOverlays reads the overlay settings for the current user.
+// Windows treats a missing value as enabled.
+func DetectLockScreenOverlays(build int) LockScreenOverlays {
+	o := LockScreenOverlays{Spotlight: true, FunFacts: true}
+
+	if key, err := winapi.Reg.OpenKey(registry.CURRENT_USER, ContentDeliveryManagerKey, registry.QUERY_VALUE); err == nil {
+		o.Spotlight = dwordDefault(key, ValueRotatingLockScreen, true)
+		o.FunFacts = dwordDefault(key, ValueRotatingLockScreenOverlay, true) &&
+			dwordDefault(key, ValueLockScreenTips, true)
+		key.Close()
+	}
+
+	if build >= BuildWindows11 {
+		o.Widgets = true
+		if key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, WidgetsPolicyKey, registry.QUERY_VALUE); err == nil {
+			o.Widgets = dwordDefault(key, ValueAllowNewsAndInterests, true)
+			key.Close()
+		}
+	}
+
+	return o
+}
+
+// dwordDefault reads a DWORD value as a flag, returning def when it doesn't exist.
+func dwordDefault(key winapi.RegistryKey, name string, def bool) bool {
+	v, _, err := key.GetIntegerValue(name)
+	if err != nil {
+		return def
+	}
+	return v != 0
+}
+
+// SpotlightUser is a user whose lock screen is set to Windows Spotlight.
+type SpotlightUser struct {
+	SID  string `json:"sid"`
+	Name string `json:"name,omitempty"`
+}
+
+// DetectSpotlightUsers checks every loaded user hive (signed-in users) for
+// Windows Spotlight, which silently replaces our lock screen image when a user
+// picks it in Settings. Requires administrator or SYSTEM to read other users' hives.
+func DetectSpotlightUsers() []SpotlightUser {
+	var found []SpotlightUser
+	for _, sid := range SignedInUsers() {
+		key, err := winapi.Reg.OpenKey(registry.USERS, sid+`\`+ContentDeliveryManagerKey, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		enabled := dwordSet(key, ValueRotatingLockScreen)
+		key.Close()
+
+		if enabled {
+			found = append(found, SpotlightUser{SID: sid, Name: AccountName(sid)})
+		}
+	}
+	return found
+}
+
+// SignedInUsers returns the SIDs of the user accounts whose hives are loaded
+// under HKEY_USERS (signed-in users), skipping service accounts and the
+// _Classes hives. Requires administrator or SYSTEM.
+func SignedInUsers() []string {
+	users, err := winapi.Reg.OpenKey(registry.USERS, "", registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil
+	}
+	defer users.Close()
+
+	sids, err := users.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil
+	}
+
+	var found []string
+	for _, sid := range sids {
+		if strings.HasPrefix(sid, "S-1-5-21-") && !strings.HasSuffix(sid, "_Classes") {
+			found = append(found, sid)
+		}
+	}
+	return found
+}
+
+// AccountName resolves a SID to DOMAIN\user, or "" if it can't be resolved.
+func AccountName(sid string) string {
+	s, err := windows.StringToSid(sid)
+	if err != nil {
+		return ""
+	}
+	account, domain, _, err := s.LookupAccount("")
+	if err != nil {
+		return ""
+	}
+	if domain == "" {
+		return account
+	}
+	return domain + `\` + account
+}
@@ -0,0 +1,181 @@
+// Package compliance records the last applied lock screen image in the
+// registry, where Intune custom compliance discovery scripts and configuration
+// baselines can read it.
+package compliance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// KeyPath is the HKLM key holding the compliance values. It sits below the
+// override key, whose values (not subkeys) are read as config overrides.
+const KeyPath = config.OverrideKeyPath + `\Compliance`
+
+// Registry value names under KeyPath. All are REG_SZ except SchemaVersion.
+const (
+	ValueSchemaVersion = "SchemaVersion"
+	ValueImagePath     = "LastImagePath"
+	ValueImageHash     = "LastImageSHA256"
+	ValueAppliedAt     = "LastAppliedUTC"
+	ValueVersion       = "Version"
+	ValueTool          = "Tool"
+	ValuePolicySource  = "PolicySource"
+	ValueMethods       = "Methods"
+	ValueManagedPolicy = "ManagedPolicy"
+)
+
+// SchemaVersion is incremented when values are renamed or change meaning.
+const SchemaVersion = 1
+
+// State is the last applied image as recorded under KeyPath.
+type State struct {
+	SchemaVersion int `json:"schema_version"`
+	// ImagePath is the rendered image that was applied.
+	ImagePath string `json:"image_path"`
+	// ImageHash is the SHA-256 of ImagePath, in lower-case hex.
+	ImageHash string    `json:"image_sha256"`
+	AppliedAt time.Time `json:"applied_utc"`
+	// Version is the version of the tool that applied the image.
+	Version string `json:"version"`
+	Tool    string `json:"tool"`
+	// PolicySource lists where the configuration came from, comma separated:
+	// default, file, registry (e.g. Intune or Group Policy) and environment.
+	PolicySource string `json:"policy_source"`
+	// Methods are the login screen methods selected for this system.
+	Methods string `json:"methods"`
+	// ManagedPolicy is the Intune or Group Policy setting that pins the lock
+	// screen image and how it was handled, e.g. "MDM (https://...): report";
+	// empty when there is none.
+	ManagedPolicy string `json:"managed_policy"`
+}
+
+// Report is the output of --compliance-json for a discovery script.
+type Report struct {
+	State
+	// Recorded is false when no image has been applied yet.
+	Recorded bool `json:"recorded"`
+	// ImagePresent is true when the recorded image still exists and still
+	// matches the recorded hash.
+	ImagePresent bool `json:"image_present"`
+	// AgeHours is how long ago the image was applied.
+	AgeHours float64 `json:"age_hours"`
+}
+
+// FileHash returns the SHA-256 of a file in lower-case hex.
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Record writes the state for an image that was just applied. Requires
+// administrator or SYSTEM.
+func Record(imagePath, tool, version string, methods []string, managedPolicy string) error {
+	hash, err := FileHash(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", imagePath, err)
+	}
+
+	key, _, err := winapi.Reg.CreateKey(registry.LOCAL_MACHINE, KeyPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to create HKLM\\%s: %w", KeyPath, err)
+	}
+	defer key.Close()
+
+	values := map[string]string{
+		ValueImagePath:     imagePath,
+		ValueImageHash:     hash,
+		ValueAppliedAt:     time.Now().UTC().Format(time.RFC3339),
+		ValueVersion:       version,
+		ValueTool:          tool,
+		ValuePolicySource:  strings.Join(config.PolicySources(), ","),
+		ValueMethods:       strings.Join(methods, ","),
+		ValueManagedPolicy: managedPolicy,
+	}
+	for name, v := range values {
+		if err := key.SetStringValue(name, v); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	if err := key.SetDWordValue(ValueSchemaVersion, SchemaVersion); err != nil {
+		return fmt.Errorf("failed to set %s: %w", ValueSchemaVersion, err)
+	}
+	return nil
+}
+
+// Read returns the recorded state, or ok false when nothing was recorded.
+func Read() (State, bool) {
+	var s State
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, KeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return s, false
+	}
+	defer key.Close()
+
+	str := func(name string) string {
+		v, _, _ := key.GetStringValue(name)
+		return v
+	}
+	if v, _, err := key.GetIntegerValue(ValueSchemaVersion); err == nil {
+		s.SchemaVersion = int(v)
+	}
+	s.ImagePath = str(ValueImagePath)
+	s.ImageHash = str(ValueImageHash)
+	s.AppliedAt, _ = time.Parse(time.RFC3339, str(ValueAppliedAt))
+	s.Version = str(ValueVersion)
+	s.Tool = str(ValueTool)
+	s.PolicySource = str(ValuePolicySource)
+	s.Methods = str(ValueMethods)
+	s.ManagedPolicy = str(ValueManagedPolicy)
+	return s, s.ImageHash != ""
+}
+
+// Check reads the recorded state and verifies the image against it.
+func Check() Report {
+	s, ok := Read()
+	r := Report{State: s, Recorded: ok}
+	if !ok {
+		return r
+	}
+	if hash, err := FileHash(s.ImagePath); err == nil && strings.EqualFold(hash, s.ImageHash) {
+		r.ImagePresent = true
+	}
+	if !s.AppliedAt.IsZero() {
+		r.AgeHours = time.Since(s.AppliedAt).Hours()
+	}
+	return r
+}
+
+// JSON returns the report as indented JSON.
+func (r Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Remove deletes the compliance key, on uninstall.
+func Remove() error {
+	err := winapi.Reg.DeleteKey(registry.LOCAL_MACHINE, KeyPath)
+	if err != nil && err != registry.ErrNotExist {
+		return err
+	}
+	return nil
+}
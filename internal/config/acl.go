@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// dirSDDL gives SYSTEM and Administrators full control of the data directory
+// and Users read access. It does not inherit the ProgramData ACL, which lets
+// Users create files, so a standard user cannot plant the config file or the
+// registry journal that elevated runs trust.
+const dirSDDL = "O:BAD:P(A;OICI;FA;;;SY)(A;OICI;FA;;;BA)(A;OICI;GRGX;;;BU)"
+
+// configSDDL keeps the config file, which holds publish passwords and tokens,
+// away from Users.
+const configSDDL = "O:BAD:P(A;;FA;;;SY)(A;;FA;;;BA)"
+
+// fileSDDL is dirSDDL for a single file, e.g. the registry journal.
+const fileSDDL = "O:BAD:P(A;;FA;;;SY)(A;;FA;;;BA)(A;;GRGX;;;BU)"
+
+// Secure creates the data directory and restricts its ACL and the config
+// file's, when there is one. It needs administrator privileges.
+func Secure() error {
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := setSecurity(Dir(), dirSDDL); err != nil {
+		return fmt.Errorf("failed to restrict access to %s: %w", Dir(), err)
+	}
+	if _, err := os.Stat(Path()); err == nil {
+		if err := setSecurity(Path(), configSDDL); err != nil {
+			return fmt.Errorf("failed to restrict access to %s: %w", Path(), err)
+		}
+	}
+	return nil
+}
+
+// SecureFile hands a file in the data directory to the Administrators group
+// and restricts its ACL like the directory's, so CheckOwner accepts it
+// whatever the default owner of the elevated user is.
+func SecureFile(path string) error {
+	if err := setSecurity(path, fileSDDL); err != nil {
+		return fmt.Errorf("failed to restrict access to %s: %w", path, err)
+	}
+	return nil
+}
+
+// setSecurity replaces the owner and DACL of a file or directory with those
+// of an SDDL string.
+func setSecurity(path, sddl string) error {
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return err
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.OWNER_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		owner, nil, dacl, nil)
+}
+
+// CheckOwner returns an error unless the file is owned by SYSTEM or the
+// Administrators group, i.e. it was written by an elevated run.
+func CheckOwner(path string) error {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("failed to read the owner of %s: %w", path, err)
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return fmt.Errorf("failed to read the owner of %s: %w", path, err)
+	}
+	if owner.IsWellKnown(windows.WinLocalSystemSid) || owner.IsWellKnown(windows.WinBuiltinAdministratorsSid) {
+		return nil
+	}
+	return fmt.Errorf("%s is owned by %s, not SYSTEM or Administrators", path, owner)
+}
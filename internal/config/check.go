@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Problem is a mistake found in a config or layout file.
+type Problem struct {
+	// Line is 1-based, or 0 when the mistake is not tied to a line.
+	Line int
+	// Path is the JSON path of the value, e.g. "outputs[1].url".
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", p.Line)
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// Positions maps the JSON path of each key and array element in a file, e.g.
+// "outputs[1].url", to the line it is on.
+type Positions map[string]int
+
+// Problem returns a problem with the value at path. A value that is not in the
+// file, e.g. a missing required key, gets the line of its closest parent.
+func (p Positions) Problem(path, format string, args ...interface{}) Problem {
+	problem := Problem{Path: path, Message: fmt.Sprintf(format, args...)}
+	for parent := path; parent != ""; parent = parentPath(parent) {
+		if line, ok := p[parent]; ok {
+			problem.Line = line
+			break
+		}
+	}
+	return problem
+}
+
+// parentPath strips the last key or index from a JSON path.
+func parentPath(path string) string {
+	i := strings.LastIndexAny(path, ".[")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+// CheckSyntax decodes data into v like LoadFrom does and reports, with their
+// lines, syntax errors, values of the wrong type and keys v has no field for
+// (usually typos, which decoding silently ignores). The positions are only
+// complete when the syntax is valid.
+func CheckSyntax(data []byte, v interface{}) (Positions, []Problem) {
+	positions := Positions{}
+	var problems []Problem
+
+	if err := json.Unmarshal(data, v); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return positions, []Problem{{Line: lineAt(data, syntaxErr.Offset), Message: syntaxErr.Error()}}
+		case errors.As(err, &typeErr):
+			problems = append(problems, Problem{
+				Line:    lineAt(data, typeErr.Offset),
+				Path:    typeErr.Field,
+				Message: fmt.Sprintf("expected %s, found %s", typeName(typeErr.Type), typeErr.Value),
+			})
+		default:
+			return positions, []Problem{{Message: err.Error()}}
+		}
+	}
+
+	w := &syntaxWalker{dec: json.NewDecoder(bytes.NewReader(data)), data: data, positions: positions}
+	if err := w.value("", reflect.TypeOf(v)); err != nil {
+		problems = append(problems, Problem{Line: w.line(), Message: err.Error()})
+	}
+	return positions, append(problems, w.problems...)
+}
+
+// syntaxWalker reads a JSON document token by token alongside the Go type it
+// decodes into, recording the line of every key and flagging unknown ones.
+type syntaxWalker struct {
+	dec       *json.Decoder
+	data      []byte
+	positions Positions
+	problems  []Problem
+}
+
+// value walks the next value, which decodes into t; t is nil for values that
+// are skipped or decode into an interface.
+func (w *syntaxWalker) value(path string, t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for w.dec.More() {
+			key, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			childPath := joinPath(path, name)
+			w.positions[childPath] = w.line()
+
+			var child reflect.Type
+			if t != nil {
+				switch t.Kind() {
+				case reflect.Struct:
+					field, ok := jsonField(t, name)
+					if !ok {
+						w.problems = append(w.problems, Problem{Line: w.line(), Path: childPath, Message: fmt.Sprintf("unknown key %q", name)})
+					}
+					child = field
+				case reflect.Map:
+					child = t.Elem()
+				}
+			}
+			if err := w.value(childPath, child); err != nil {
+				return err
+			}
+		}
+		_, err = w.dec.Token()
+		return err
+	case json.Delim('['):
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := 0; w.dec.More(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			w.positions[elemPath] = w.line()
+			if err := w.value(elemPath, elem); err != nil {
+				return err
+			}
+		}
+		_, err = w.dec.Token()
+		return err
+	}
+	return nil
+}
+
+// line returns the line of the next token.
+func (w *syntaxWalker) line() int {
+	return lineAt(w.data, w.dec.InputOffset())
+}
+
+// lineAt returns the line of the first token at or after offset.
+func lineAt(data []byte, offset int64) int {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// jsonField returns the type of the struct field name decodes into, matching
+// the JSON key case-insensitively like encoding/json.
+func jsonField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// typeName describes a Go type in JSON terms for error messages.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	}
+	return t.String()
+}
@@ -0,0 +1,1393 @@
+// Package config loads the optional JSON configuration file shared by bgchanger,
+// BgStatusService and the installer.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the configuration file inside the data directory.
+const FileName = "config.json"
+
+// Config holds all user-configurable settings. Every field is optional; a
+// missing config file behaves the same as an empty one.
+type Config struct {
+	// Schema is the path or URL of the config's JSON Schema, for editors
+	// (see --print-schema). It is ignored.
+	Schema string `json:"$schema,omitempty"`
+
+	// Mode "report-only" runs the collectors and reporters (status.json,
+	// publish, MQTT, thresholds, event log) without changing the login screen
+	// image or its registry settings. Empty is the normal mode.
+	Mode string `json:"mode,omitempty"`
+
+	// DownloadMirrors is an ordered list of fallback URLs tried when the GitHub
+	// release asset cannot be downloaded (e.g. github.com is blocked).
+	// Each entry may contain {version} and {file} placeholders, for example
+	// "https://cdn.example.com/bgstatus/{version}/{file}".
+	DownloadMirrors []string `json:"download_mirrors,omitempty"`
+
+	// Libraries are centrally managed wallpaper libraries that bgchanger can pick
+	// images from with "bgchanger library:<name>".
+	Libraries []LibraryConfig `json:"libraries,omitempty"`
+
+	// Safety configures the optional content gate applied to images pulled from
+	// public sources (random wallpapers and URLs).
+	Safety SafetyConfig `json:"safety,omitempty"`
+
+	// UnsplashAccessKey is the Unsplash API access key used by "bgchanger unsplash".
+	UnsplashAccessKey string `json:"unsplash_access_key,omitempty"`
+	// APODAPIKey is the api.nasa.gov key used by "bgchanger apod". NASA's shared
+	// DEMO_KEY is used when empty.
+	APODAPIKey string `json:"apod_api_key,omitempty"`
+
+	// Attribution controls the credit line drawn on images that carry author or
+	// license metadata (Unsplash, Bing, APOD).
+	Attribution AttributionConfig `json:"attribution,omitempty"`
+
+	// Transition fades the desktop from the old wallpaper to the new one when
+	// bgchanger changes it, instead of an abrupt switch.
+	Transition TransitionConfig `json:"transition,omitempty"`
+
+	// Calendar shows the next upcoming events from ICS feeds on the login screen.
+	Calendar CalendarConfig `json:"calendar,omitempty"`
+
+	// Collectors turns on optional health checks shown as extra sections on
+	// the login screen.
+	Collectors CollectorsConfig `json:"collectors,omitempty"`
+
+	// HistoryGraph records CPU, memory and network samples on every run (and on
+	// "bgStatusService.exe --sample") and draws a 24-hour trend graph.
+	HistoryGraph bool `json:"history_graph,omitempty"`
+
+	// Output controls the format, names and retention of the rendered login
+	// screen images in the data directory.
+	Output OutputConfig `json:"output,omitempty"`
+
+	// Sources is the fallback chain used by the rotation (bgchanger with no
+	// arguments) when no season is active: each entry is tried in order until
+	// one yields a valid image. Entries are anything bgchanger accepts as an
+	// argument, "random" (slide.recipes) or "color:#RRGGBB". BgStatusService
+	// uses the local entries (files, folders, colors) when there is no login
+	// screen image to draw on.
+	Sources []string `json:"sources,omitempty"`
+
+	// Seasons map date ranges to wallpaper sources and tints used by the rotation
+	// (bgchanger with no arguments). The first matching season wins.
+	Seasons []SeasonConfig `json:"seasons,omitempty"`
+
+	// Notice replaces the login screen with a generated full-screen message
+	// (kiosk notice mode).
+	Notice NoticeConfig `json:"notice,omitempty"`
+
+	// Banner draws a legal notice along the bottom of the login screen, in
+	// every mode including notices.
+	Banner BannerConfig `json:"banner,omitempty"`
+
+	// LockScreen controls the Windows lock screen overlays (Spotlight "fun facts
+	// and tips" and Windows 11 widgets) that can cover the image.
+	LockScreen LockScreenConfig `json:"lock_screen,omitempty"`
+
+	// FetchPolicy defers non-essential downloads (the wallpaper rotation,
+	// prefetching and updates) on metered connections and low battery.
+	FetchPolicy FetchPolicyConfig `json:"fetch_policy,omitempty"`
+
+	// Update controls when "bgchanger update" takes a release that is rolled
+	// out in stages.
+	Update UpdateConfig `json:"update,omitempty"`
+
+	// VDI adjusts the service on non-persistent virtual desktops (Citrix PVS,
+	// pooled FSLogix hosts, Windows Sandbox), where changes are lost at reboot.
+	VDI VDIConfig `json:"vdi,omitempty"`
+
+	// Tasks configures the scheduled task that refreshes the login screen. It is
+	// read by the installer when the tasks are created, so reinstall to apply changes.
+	Tasks TasksConfig `json:"tasks,omitempty"`
+
+	// Dashboard serves a small status page (image, status.json and recent runs)
+	// from "bgStatusService.exe --dashboard". The installer adds a boot task
+	// that runs it when enabled.
+	Dashboard DashboardConfig `json:"dashboard,omitempty"`
+
+	// SNMP serves the collected status to SNMP pollers from
+	// "bgStatusService.exe --snmp". The installer adds a boot task that runs
+	// it when enabled.
+	SNMP SNMPConfig `json:"snmp,omitempty"`
+
+	// Warranty looks up the warranty end date with the vendor's API (Dell,
+	// Lenovo and HP) and shows it with the system information.
+	Warranty WarrantyConfig `json:"warranty,omitempty"`
+
+	// Publish uploads the rendered image and status.json to a central location
+	// after every run, e.g. for a NOC wall dashboard.
+	Publish PublishConfig `json:"publish,omitempty"`
+
+	// Outputs are further destinations for the rendered image and status,
+	// each configured like Publish, e.g. a signage share besides the wall
+	// dashboard's web server.
+	Outputs []PublishConfig `json:"outputs,omitempty"`
+
+	// MQTT publishes status.json and a few metrics to an MQTT broker after
+	// every run, e.g. for Home Assistant.
+	MQTT MQTTConfig `json:"mqtt,omitempty"`
+
+	// Thresholds turns collected values into warning and critical severities,
+	// shown in the alert colors, logged with their own event IDs and posted
+	// to a webhook when they change.
+	Thresholds ThresholdsConfig `json:"thresholds,omitempty"`
+
+	// ServerCore is what is shown on Server Core, which has no lock screen
+	// image: the status as text in the logon message and/or a console MOTD.
+	ServerCore ServerCoreConfig `json:"server_core,omitempty"`
+
+	// Redaction masks sensitive values on the login screen for machines in
+	// public places. The cached system info and status.json keep the full values.
+	Redaction RedactionConfig `json:"redaction,omitempty"`
+
+	// Theme replaces the automatic panel colors and adds a logo.
+	Theme ThemeConfig `json:"theme,omitempty"`
+
+	// Adjust darkens, desaturates, tints or vignettes the wallpaper before the
+	// panels are drawn, so a bright, busy image doesn't fight with the text.
+	Adjust AdjustConfig `json:"adjust,omitempty"`
+
+	// WatchedServices are services shown on the login screen besides the
+	// built-in critical services, by service (key) name, e.g. "VeeamBackupSvc".
+	WatchedServices []string `json:"watched_services,omitempty"`
+
+	// ServicesDisplay is how much of the services panel is shown while every
+	// service is healthy: "full" (default), "summary" (a single OK line) or
+	// "failures" (nothing). Problems are always shown in full.
+	ServicesDisplay string `json:"services_display,omitempty"`
+
+	// RebootReminder flags machines that have not been restarted for too
+	// long, so they don't quietly miss patches.
+	RebootReminder RebootReminderConfig `json:"reboot_reminder,omitempty"`
+
+	// MinInterval skips refresh runs (lock, logon, manual) within this long
+	// of the last successful update, e.g. "30m". Boot, resume and follow-up
+	// runs always update. Empty means no limit.
+	MinInterval string `json:"min_interval,omitempty"`
+
+	// Profiles replace the theme, watched services, collectors and minimum
+	// interval per tenant or network location: the first profile whose
+	// hostname patterns, AD OUs, DNS suffixes or gateway MACs match this
+	// computer wins, or else the first fallback profile.
+	Profiles []ProfileConfig `json:"profiles,omitempty"`
+
+	// ActiveProfile is the name of the profile applied by Load, if any.
+	ActiveProfile string `json:"-"`
+
+	// TextScale multiplies the size of the panel text after it has been scaled
+	// for the display's resolution and DPI, e.g. 1.5 for wall displays read from
+	// across a room (default 1, allowed 0.5 to 3).
+	TextScale float64 `json:"text_scale,omitempty"`
+
+	// LayoutDirection is "auto" (default) to lay the panels out right to left
+	// when the Windows display language is written right to left (Hebrew,
+	// Arabic, Persian...), or "ltr" or "rtl" to choose.
+	LayoutDirection string `json:"layout_direction,omitempty"`
+
+	// PanelTextScale multiplies the text of one panel on top of TextScale, e.g.
+	// {"services": 0.8} for a long services list on a dense server panel.
+	PanelTextScale PanelTextScaleConfig `json:"panel_text_scale,omitempty"`
+
+	// DisplayVariants pre-renders the login screen for every recently seen
+	// display resolution, so docking and undocking only swap images. The
+	// installer adds a task that swaps them on unlock, reconnect and resume.
+	DisplayVariants bool `json:"display_variants,omitempty"`
+
+	// BootWait is how long the boot run waits for the WMI service and a network
+	// address before rendering cached data, e.g. "90s" (default). "0s" disables it.
+	BootWait string `json:"boot_wait,omitempty"`
+
+	// ResumeWait is how long the run after resume from sleep waits for the
+	// network addresses to settle before comparing them, e.g. "60s" (default).
+	ResumeWait string `json:"resume_wait,omitempty"`
+
+	// MemoryLimitMB is the soft memory limit for BgStatusService while rendering
+	// (default 192). Raise it if very large non-JPEG wallpapers fail to render.
+	MemoryLimitMB int `json:"memory_limit_mb,omitempty"`
+
+	// ImageLimits bounds the images that are decoded. Wallpapers come from the
+	// internet and are decoded as administrator or SYSTEM, so oversized files
+	// and decompression bombs are rejected before decoding.
+	ImageLimits ImageLimitsConfig `json:"image_limits,omitempty"`
+}
+
+// ImageLimitsConfig bounds the file size and dimensions of decoded images.
+type ImageLimitsConfig struct {
+	// MaxFileMB is the largest image file accepted (default 64).
+	MaxFileMB int `json:"max_file_mb,omitempty"`
+	// MaxDimension is the largest width or height in pixels (default 16384).
+	MaxDimension int `json:"max_dimension,omitempty"`
+	// MaxMegapixels is the largest width x height, in millions of pixels
+	// (default 100, enough for 8K with room to spare).
+	MaxMegapixels int `json:"max_megapixels,omitempty"`
+}
+
+// Defaults for ImageLimitsConfig.
+const (
+	DefaultMaxImageFileMB     = 64
+	DefaultMaxImageDimension  = 16384
+	DefaultMaxImageMegapixels = 100
+)
+
+// FileBytes returns the largest accepted image file size in bytes.
+func (l ImageLimitsConfig) FileBytes() int64 {
+	if l.MaxFileMB <= 0 {
+		return DefaultMaxImageFileMB << 20
+	}
+	return int64(l.MaxFileMB) << 20
+}
+
+// Dimension returns the largest accepted width or height.
+func (l ImageLimitsConfig) Dimension() int {
+	if l.MaxDimension <= 0 {
+		return DefaultMaxImageDimension
+	}
+	return l.MaxDimension
+}
+
+// Pixels returns the largest accepted width x height.
+func (l ImageLimitsConfig) Pixels() int64 {
+	if l.MaxMegapixels <= 0 {
+		return DefaultMaxImageMegapixels * 1000000
+	}
+	return int64(l.MaxMegapixels) * 1000000
+}
+
+// Output formats and naming schemes for OutputConfig.
+const (
+	OutputFormatJPEG     = "jpg"
+	OutputFormatPNG      = "png"
+	OutputNamingUnix     = "unix"
+	OutputNamingDateTime = "datetime"
+)
+
+// OutputConfig controls the rendered login screen images.
+type OutputConfig struct {
+	// Format is "jpg" (default) or "png".
+	Format string `json:"format,omitempty"`
+	// Naming is "unix" (default, loginscreen_<Unix seconds>) or "datetime"
+	// (loginscreen_YYYYMMDD-HHMMSS in local time).
+	Naming string `json:"naming,omitempty"`
+	// Keep is how many renders are kept, newest first (default 1: only the
+	// current one).
+	Keep int `json:"keep,omitempty"`
+	// MaxAge removes older renders even within Keep, e.g. "168h". The current
+	// render is always kept.
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+// Extension returns the file extension of the configured format.
+func (o OutputConfig) Extension() string {
+	if strings.EqualFold(o.Format, OutputFormatPNG) {
+		return "." + OutputFormatPNG
+	}
+	return "." + OutputFormatJPEG
+}
+
+// FileName returns the name of a render made at t.
+func (o OutputConfig) FileName(t time.Time) string {
+	if strings.EqualFold(o.Naming, OutputNamingDateTime) {
+		return "loginscreen_" + t.Format("20060102-150405") + o.Extension()
+	}
+	return fmt.Sprintf("loginscreen_%d%s", t.Unix(), o.Extension())
+}
+
+// KeepCount returns how many renders to keep.
+func (o OutputConfig) KeepCount() int {
+	if o.Keep < 1 {
+		return 1
+	}
+	return o.Keep
+}
+
+// MaxAgeDuration returns the age after which renders are removed, or 0 for no
+// limit. An invalid value means no limit.
+func (o OutputConfig) MaxAgeDuration() time.Duration {
+	d, err := time.ParseDuration(o.MaxAge)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// DefaultDashboardListen is the dashboard's default address: this machine only.
+const DefaultDashboardListen = "127.0.0.1:8089"
+
+// DashboardConfig configures the status page.
+type DashboardConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Listen is the address to serve on (default 127.0.0.1:8089). Use e.g.
+	// "0.0.0.0:8089" to reach it from other machines, which needs TLS.
+	Listen string `json:"listen,omitempty"`
+	// Token authenticates every request, as "Authorization: Bearer TOKEN" or
+	// once on the login page, which sets a session cookie. Required.
+	Token string `json:"token,omitempty"`
+	// TLSCertFile is the PEM certificate to serve HTTPS with. It and
+	// TLSKeyFile are required to listen beyond loopback.
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	// TLSKeyFile is the PEM private key of TLSCertFile.
+	TLSKeyFile string `json:"tls_key_file,omitempty"`
+}
+
+// ListenAddress returns the address to serve on.
+func (d DashboardConfig) ListenAddress() string {
+	if d.Listen == "" {
+		return DefaultDashboardListen
+	}
+	return d.Listen
+}
+
+// TLS reports whether the dashboard is served over HTTPS.
+func (d DashboardConfig) TLS() bool {
+	return d.TLSCertFile != "" && d.TLSKeyFile != ""
+}
+
+// Loopback reports whether the listen address only accepts connections from
+// this machine. An empty host listens on every interface.
+func (d DashboardConfig) Loopback() bool {
+	host, _, err := net.SplitHostPort(d.ListenAddress())
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// DefaultSNMPListen is the SNMP agent's default address: the standard port,
+// this machine only, since v2c has nothing but the community to protect it.
+const DefaultSNMPListen = "127.0.0.1:161"
+
+// DefaultSNMPBaseOID is the default root of the served variables, in
+// NET-SNMP's experimental "playpen" subtree meant for local use.
+const DefaultSNMPBaseOID = "1.3.6.1.4.1.8072.9999.9999"
+
+// SNMPConfig configures the read-only SNMP agent.
+type SNMPConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Listen is the UDP address to serve on (default 127.0.0.1:161). Set the
+	// address of the interface pollers reach, or "0.0.0.0:161", to serve
+	// them, and another port when the Windows SNMP service is installed.
+	Listen string `json:"listen,omitempty"`
+	// Community is the v1/v2c community string pollers must send. Required.
+	Community string `json:"community,omitempty"`
+	// BaseOID is the root of the served variables, e.g. under your own
+	// enterprise number (default 1.3.6.1.4.1.8072.9999.9999).
+	BaseOID string `json:"base_oid,omitempty"`
+}
+
+// ListenAddress returns the address to serve on.
+func (s SNMPConfig) ListenAddress() string {
+	if s.Listen == "" {
+		return DefaultSNMPListen
+	}
+	return s.Listen
+}
+
+// RootOID returns the root of the served variables.
+func (s SNMPConfig) RootOID() string {
+	if s.BaseOID == "" {
+		return DefaultSNMPBaseOID
+	}
+	return s.BaseOID
+}
+
+// WarrantyConfig holds the warranty API keys. A vendor without keys is not
+// looked up.
+type WarrantyConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// DellClientID and DellClientSecret are a Dell TechDirect warranty API key.
+	DellClientID     string `json:"dell_client_id,omitempty"`
+	DellClientSecret string `json:"dell_client_secret,omitempty"`
+	// LenovoClientID is a Lenovo support API client ID.
+	LenovoClientID string `json:"lenovo_client_id,omitempty"`
+	// HPAPIKey and HPAPISecret are an HP Warranty API key from the HP
+	// Developer portal.
+	HPAPIKey    string `json:"hp_api_key,omitempty"`
+	HPAPISecret string `json:"hp_api_secret,omitempty"`
+}
+
+// PublishConfig is where and how the image and status are uploaded.
+type PublishConfig struct {
+	// URL is the destination folder: https://host/path/ (files are PUT there),
+	// sftp://user@host[:port]/path, or a UNC or local folder such as
+	// \\server\share\signage (or file://server/share/signage). Empty disables
+	// publishing.
+	URL string `json:"url,omitempty"`
+	// ImageOnly publishes the rendered image without status.json, e.g. for
+	// digital signage that shows whatever image is in a folder.
+	ImageOnly bool `json:"image_only,omitempty"`
+	// Username and Password are HTTP basic authentication; the password may
+	// also come from BGSTATUS_PUBLISH_PASSWORD.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Headers are added to every HTTP upload, e.g. an Authorization token.
+	Headers map[string]string `json:"headers,omitempty"`
+	// IdentityFile is the private key for SFTP; KnownHostsFile the host keys
+	// to trust (SYSTEM has no known_hosts of its own).
+	IdentityFile   string `json:"identity_file,omitempty"`
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+}
+
+// DefaultMQTTTopicPrefix is the first level of the published topics.
+const DefaultMQTTTopicPrefix = "bgstatus"
+
+// MQTTConfig configures publishing to an MQTT broker.
+type MQTTConfig struct {
+	// Broker is mqtt://host[:1883] or, with TLS, mqtts://host[:8883]. Empty
+	// disables MQTT.
+	Broker string `json:"broker,omitempty"`
+	// TopicPrefix is the first topic level (default "bgstatus"); messages go
+	// to PREFIX/HOST/....
+	TopicPrefix string `json:"topic_prefix,omitempty"`
+	// Username and Password authenticate with the broker; the password may
+	// also come from BGSTATUS_MQTT_PASSWORD.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// ClientID defaults to "bgstatus-HOST".
+	ClientID string `json:"client_id,omitempty"`
+	// CAFile is a PEM file of CAs to trust for mqtts:// besides the system
+	// store, e.g. a home lab's own CA.
+	CAFile string `json:"ca_file,omitempty"`
+	// QoS is 0 (default) or 1.
+	QoS int `json:"qos,omitempty"`
+	// HomeAssistant also publishes Home Assistant MQTT discovery messages so
+	// the metrics appear as sensors of a device named after the host.
+	HomeAssistant bool `json:"home_assistant,omitempty"`
+}
+
+// Prefix returns the first topic level.
+func (m MQTTConfig) Prefix() string {
+	prefix := strings.Trim(m.TopicPrefix, "/")
+	if prefix == "" {
+		return DefaultMQTTTopicPrefix
+	}
+	return prefix
+}
+
+// RebootReminderConfig recommends a reboot once the uptime reaches AfterDays.
+type RebootReminderConfig struct {
+	// AfterDays adds "reboot recommended" to the uptime line from this many
+	// days of uptime (0 disables the reminder).
+	AfterDays int `json:"after_days,omitempty"`
+	// Toast also shows the signed-in users a toast, at most once a day.
+	Toast bool `json:"toast,omitempty"`
+}
+
+// ThresholdsConfig lists the threshold rules and where severity changes go.
+type ThresholdsConfig struct {
+	Rules []ThresholdRule `json:"rules,omitempty"`
+	// WebhookURL receives a JSON POST whenever the worst severity or the set
+	// of breached rules changes, e.g. a Teams or Slack workflow URL.
+	WebhookURL     string            `json:"webhook_url,omitempty"`
+	WebhookHeaders map[string]string `json:"webhook_headers,omitempty"`
+	// Toast shows critical findings to the signed-in users as a Windows
+	// toast, each at most once a day.
+	Toast bool `json:"toast,omitempty"`
+}
+
+// ThresholdRule compares one collected value against a warning and a
+// critical limit. Either limit may be left out.
+type ThresholdRule struct {
+	// Metric is the value compared, e.g. "disk_c_free_percent",
+	// "disk_free_percent" (the fullest volume), "uptime_days", "cert_days",
+	// "pending_updates" or "antivirus_enabled" (1 or 0).
+	Metric string `json:"metric"`
+	// Comparator is ">" (default), ">=", "<" or "<=": the rule is breached
+	// when "value comparator limit" holds.
+	Comparator string   `json:"comparator,omitempty"`
+	Warn       *float64 `json:"warn,omitempty"`
+	Crit       *float64 `json:"crit,omitempty"`
+	// Label names the value on the login screen (default: the metric).
+	Label string `json:"label,omitempty"`
+}
+
+// Server Core outputs for ServerCoreConfig.Output.
+const (
+	ServerCoreLogonMessage = "logon_message"
+	ServerCoreMOTD         = "motd"
+	ServerCoreBoth         = "both"
+	ServerCoreNone         = "none"
+)
+
+// ServerCoreConfig controls the text status written on Server Core.
+type ServerCoreConfig struct {
+	// Output is "logon_message" (default): the interactive logon message shown
+	// before sign-in, "motd": a console window at sign-in printing the
+	// status, "both" or "none".
+	Output string `json:"output,omitempty"`
+}
+
+// LogonMessage reports whether the status goes into the logon message.
+func (s ServerCoreConfig) LogonMessage() bool {
+	switch strings.ToLower(s.Output) {
+	case ServerCoreMOTD, ServerCoreNone:
+		return false
+	}
+	return true
+}
+
+// MOTD reports whether the status is printed at sign-in.
+func (s ServerCoreConfig) MOTD() bool {
+	switch strings.ToLower(s.Output) {
+	case ServerCoreMOTD, ServerCoreBoth:
+		return true
+	}
+	return false
+}
+
+// RedactionConfig is the redaction mode of each sensitive field: "show"
+// (default), "mask" (the last four characters, or the last octet of an IP
+// address), "hash" (the start of its SHA-256) or "omit".
+type RedactionConfig struct {
+	Hostname     string `json:"hostname,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	IPAddresses  string `json:"ip_addresses,omitempty"`
+	// Usernames applies to account names shown by the panels.
+	Usernames string `json:"usernames,omitempty"`
+}
+
+// Limits for Config.TextScale.
+const (
+	MinTextScale = 0.5
+	MaxTextScale = 3.0
+)
+
+// TextScaleFactor returns the text scale multiplier, clamped to
+// [MinTextScale, MaxTextScale]. Unset means 1.
+func (c *Config) TextScaleFactor() float64 {
+	if c.TextScale == 0 {
+		return 1
+	}
+	return math.Min(math.Max(c.TextScale, MinTextScale), MaxTextScale)
+}
+
+// Layout directions for Config.LayoutDirection.
+const (
+	LayoutDirectionAuto = "auto"
+	LayoutDirectionLTR  = "ltr"
+	LayoutDirectionRTL  = "rtl"
+)
+
+// LayoutDirectionMode returns the configured layout direction, defaulting to
+// "auto".
+func (c *Config) LayoutDirectionMode() string {
+	switch strings.ToLower(c.LayoutDirection) {
+	case LayoutDirectionLTR:
+		return LayoutDirectionLTR
+	case LayoutDirectionRTL:
+		return LayoutDirectionRTL
+	}
+	return LayoutDirectionAuto
+}
+
+// PanelTextScaleConfig holds the text size multiplier of each panel.
+type PanelTextScaleConfig struct {
+	// Services is the left panel: services, calendar and the collectors.
+	Services float64 `json:"services,omitempty"`
+	// System is the right panel: system information and widgets.
+	System float64 `json:"system,omitempty"`
+}
+
+// ServicesFactor returns the services panel multiplier, clamped like TextScale.
+// Unset means 1.
+func (p PanelTextScaleConfig) ServicesFactor() float64 {
+	return panelTextScaleFactor(p.Services)
+}
+
+// SystemFactor returns the system panel multiplier, clamped like TextScale.
+// Unset means 1.
+func (p PanelTextScaleConfig) SystemFactor() float64 {
+	return panelTextScaleFactor(p.System)
+}
+
+func panelTextScaleFactor(scale float64) float64 {
+	if scale == 0 {
+		return 1
+	}
+	return math.Min(math.Max(scale, MinTextScale), MaxTextScale)
+}
+
+// DefaultResumeWait is how long the resume run waits for the network to settle.
+const DefaultResumeWait = 60 * time.Second
+
+// ResumeWaitDuration returns the configured resume wait. An invalid value falls
+// back to the default.
+func (c *Config) ResumeWaitDuration() time.Duration {
+	d, err := time.ParseDuration(c.ResumeWait)
+	if err != nil || d < 0 {
+		return DefaultResumeWait
+	}
+	return d
+}
+
+// SourceColorPrefix starts a solid color entry in Config.Sources.
+const SourceColorPrefix = "color:"
+
+// SourceColor returns the hex color of a "color:#RRGGBB" source.
+func SourceColor(source string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(source), SourceColorPrefix) {
+		return "", false
+	}
+	return source[len(SourceColorPrefix):], true
+}
+
+// DefaultBootWait is how long the boot run waits for WMI and the network.
+const DefaultBootWait = 90 * time.Second
+
+// BootWaitDuration returns the configured boot wait. An invalid value falls back
+// to the default.
+func (c *Config) BootWaitDuration() time.Duration {
+	if c.BootWait == "" {
+		return DefaultBootWait
+	}
+	d, err := time.ParseDuration(c.BootWait)
+	if err != nil || d < 0 {
+		return DefaultBootWait
+	}
+	return d
+}
+
+// DefaultMemoryLimitMB is the default soft memory limit for rendering.
+const DefaultMemoryLimitMB = 192
+
+// MemoryLimit returns the soft memory limit in bytes.
+func (c *Config) MemoryLimit() int64 {
+	limit := c.MemoryLimitMB
+	if limit <= 0 {
+		limit = DefaultMemoryLimitMB
+	}
+	return int64(limit) << 20
+}
+
+// Refresh task trigger types for TriggerConfig.Type.
+const (
+	TriggerLock       = "lock"
+	TriggerUnlock     = "unlock"
+	TriggerDisconnect = "disconnect"
+	TriggerLogon      = "logon"
+	TriggerDaily      = "daily"
+	TriggerEvent      = "event"
+)
+
+// TasksConfig controls the triggers and settings of the refresh task. The boot
+// task always runs at startup.
+type TasksConfig struct {
+	// Triggers replace the default lock and console-disconnect triggers.
+	Triggers []TriggerConfig `json:"triggers,omitempty"`
+	// Priority is the Task Scheduler priority from 0 (highest) to 10 (default 7).
+	Priority *int `json:"priority,omitempty"`
+	// TimeLimit stops a run that takes longer, e.g. "10m" (default).
+	TimeLimit string `json:"time_limit,omitempty"`
+	// RandomDelay spreads daily triggers across machines, e.g. "15m".
+	RandomDelay string `json:"random_delay,omitempty"`
+	// DisallowOnBattery skips runs while unplugged; StopOnBattery stops a run
+	// when the device is unplugged. Both also apply to the boot task.
+	DisallowOnBattery bool `json:"disallow_on_battery,omitempty"`
+	StopOnBattery     bool `json:"stop_on_battery,omitempty"`
+}
+
+// TriggerConfig is one refresh task trigger.
+type TriggerConfig struct {
+	// Type is lock, unlock, disconnect, logon, daily or event.
+	Type string `json:"type"`
+	// Time is the "HH:MM" time of a daily trigger.
+	Time string `json:"time,omitempty"`
+	// Log and EventID select the event of an event trigger, e.g. "System" and 1074.
+	Log     string `json:"log,omitempty"`
+	EventID int    `json:"event_id,omitempty"`
+	// Query is a raw XPath event query used instead of Log/EventID.
+	Query string `json:"query,omitempty"`
+	// Delay postpones the run after the trigger fires, e.g. "30s".
+	Delay string `json:"delay,omitempty"`
+}
+
+// DefaultMinBatteryPercent is the battery level below which downloads wait
+// while the device is unplugged.
+const DefaultMinBatteryPercent = 20
+
+// FetchPolicyConfig controls when non-essential downloads are deferred.
+type FetchPolicyConfig struct {
+	// AllowMetered downloads even when the connection is metered.
+	AllowMetered bool `json:"allow_metered,omitempty"`
+	// AllowOnBattery ignores the battery level.
+	AllowOnBattery bool `json:"allow_on_battery,omitempty"`
+	// MinBatteryPercent is the battery level below which downloads wait while
+	// unplugged (default 20).
+	MinBatteryPercent int `json:"min_battery_percent,omitempty"`
+}
+
+// MinBattery returns the configured battery threshold or the default.
+func (f FetchPolicyConfig) MinBattery() int {
+	if f.MinBatteryPercent <= 0 {
+		return DefaultMinBatteryPercent
+	}
+	return f.MinBatteryPercent
+}
+
+// Update rings for UpdateConfig.Ring.
+const (
+	UpdateRingPilot = "pilot"
+	UpdateRingAuto  = "auto"
+	UpdateRingLast  = "last"
+)
+
+// UpdateConfig controls the machine's place in staged rollouts.
+type UpdateConfig struct {
+	// Ring is when the machine takes a staged release: "pilot" as soon as it is
+	// published, "auto" (default) once the rollout reaches the position its
+	// name hashes to, "last" only once the rollout reaches every machine.
+	Ring string `json:"ring,omitempty"`
+}
+
+// RingMode returns the configured update ring, defaulting to "auto".
+func (u UpdateConfig) RingMode() string {
+	switch strings.ToLower(u.Ring) {
+	case UpdateRingPilot:
+		return UpdateRingPilot
+	case UpdateRingLast:
+		return UpdateRingLast
+	}
+	return UpdateRingAuto
+}
+
+// VDI modes for VDIConfig.Mode.
+const (
+	VDIModeAuto          = "auto"
+	VDIModePersistent    = "persistent"
+	VDIModeNonPersistent = "non_persistent"
+)
+
+// VDIConfig controls how the service behaves on non-persistent virtual
+// desktops.
+type VDIConfig struct {
+	// Mode is "auto" (default) to detect non-persistent machines, or
+	// "persistent" or "non_persistent" to override the detection.
+	Mode string `json:"mode,omitempty"`
+
+	// PersistentDir is a directory on the persistent disk (e.g. the PVS
+	// personal vDisk or a write cache drive). On a non-persistent machine the
+	// state kept between runs, such as the offline cache, history and run log,
+	// is written there; without it the writes that only matter after a reboot
+	// are skipped.
+	PersistentDir string `json:"persistent_dir,omitempty"`
+
+	// ImageVersionValue is a registry value holding the golden image's version,
+	// given as its full path, e.g. `HKLM\SOFTWARE\Contoso\Image\Version`. It is
+	// shown with the image name on the panel.
+	ImageVersionValue string `json:"image_version_value,omitempty"`
+}
+
+// DetectionMode returns the configured VDI mode, defaulting to "auto".
+func (v VDIConfig) DetectionMode() string {
+	switch strings.ToLower(v.Mode) {
+	case VDIModePersistent:
+		return VDIModePersistent
+	case VDIModeNonPersistent:
+		return VDIModeNonPersistent
+	}
+	return VDIModeAuto
+}
+
+// LockScreenConfig controls the lock screen overlays.
+type LockScreenConfig struct {
+	// DisableOverlays turns off Spotlight, the "fun facts, tips and tricks" text and
+	// lock screen widgets. The previous settings are journaled and put back when
+	// this is turned off again.
+	DisableOverlays bool `json:"disable_overlays,omitempty"`
+
+	// Spotlight is what BgStatusService does when a signed-in user switches the
+	// lock screen back to Windows Spotlight: "report" (default) logs it,
+	// "reassert" turns Spotlight off again for that user, "ignore" does nothing.
+	Spotlight string `json:"spotlight,omitempty"`
+
+	// ManagedPolicy is what BgStatusService does when an Intune (MDM) or Group
+	// Policy setting already pins the lock screen image, which then wins over
+	// the status image: "report" (default) logs the conflict and keeps setting
+	// the image, "coexist" writes the status image into the file the policy
+	// shows instead of changing any setting, "ignore" keeps setting the image
+	// without logging.
+	ManagedPolicy string `json:"managed_policy,omitempty"`
+
+	// DisableSessionHelper makes BgStatusService set the lock screen only with
+	// the machine-wide methods. By default, when users are signed in, it sets
+	// each user's lock screen from a helper started in their session and skips
+	// the Group Policy and default image fallbacks.
+	DisableSessionHelper bool `json:"disable_session_helper,omitempty"`
+
+	// Slideshow shows a folder of images as the Windows lock screen slideshow
+	// instead of a single image.
+	Slideshow SlideshowConfig `json:"slideshow,omitempty"`
+
+	// BurnIn varies the panels between renders so they don't burn into OLED and
+	// plasma displays that show the lock screen around the clock.
+	BurnIn BurnInConfig `json:"burn_in,omitempty"`
+}
+
+// Defaults for BurnInConfig.
+const (
+	DefaultBurnInShiftPixels = 8
+	DefaultBurnInCycle       = 4 * time.Hour
+)
+
+// BurnInConfig controls the anti-burn-in variations of the overlay panels.
+type BurnInConfig struct {
+	// Enabled moves the panels by a few pixels on every render and cycles their
+	// colors between normal, inverted and softened.
+	Enabled bool `json:"enabled,omitempty"`
+	// ShiftPixels is the furthest the panels move from their normal position in
+	// each direction (default 8).
+	ShiftPixels int `json:"shift_pixels,omitempty"`
+	// Cycle is how long each color phase lasts, e.g. "4h" (default).
+	Cycle string `json:"cycle,omitempty"`
+}
+
+// Shift returns the largest panel offset in pixels.
+func (b BurnInConfig) Shift() int {
+	if b.ShiftPixels <= 0 {
+		return DefaultBurnInShiftPixels
+	}
+	return b.ShiftPixels
+}
+
+// CycleDuration returns how long each color phase lasts. An invalid value falls
+// back to the default.
+func (b BurnInConfig) CycleDuration() time.Duration {
+	d, err := time.ParseDuration(b.Cycle)
+	if err != nil || d <= 0 {
+		return DefaultBurnInCycle
+	}
+	return d
+}
+
+// DefaultSlideshowStatusImages is the number of status renders kept in the
+// slideshow folder.
+const DefaultSlideshowStatusImages = 5
+
+// SlideshowConfig controls the lock screen slideshow registered by BgStatusService.
+type SlideshowConfig struct {
+	// Enabled registers Folder as the lock screen slideshow for every signed-in user.
+	Enabled bool `json:"enabled,omitempty"`
+	// Folder holds the slideshow images (default %ProgramData%\BgStatusService\slideshow).
+	// It may also contain images you put there yourself.
+	Folder string `json:"folder,omitempty"`
+	// StatusImages is the number of status renders BgStatusService keeps in the
+	// folder, newest first (default 5). -1 adds none, for a folder of your own images.
+	StatusImages int `json:"status_images,omitempty"`
+}
+
+// FolderPath returns the slideshow folder or the default.
+func (s SlideshowConfig) FolderPath() string {
+	if s.Folder == "" {
+		return filepath.Join(Dir(), "slideshow")
+	}
+	return s.Folder
+}
+
+// StatusImageCount returns the number of status renders to keep in the folder.
+func (s SlideshowConfig) StatusImageCount() int {
+	if s.StatusImages < 0 {
+		return 0
+	}
+	if s.StatusImages == 0 {
+		return DefaultSlideshowStatusImages
+	}
+	return s.StatusImages
+}
+
+// Spotlight policies for LockScreenConfig.Spotlight.
+const (
+	SpotlightReport   = "report"
+	SpotlightReassert = "reassert"
+	SpotlightIgnore   = "ignore"
+)
+
+// SpotlightPolicy returns the configured Spotlight policy, defaulting to "report".
+func (l LockScreenConfig) SpotlightPolicy() string {
+	switch strings.ToLower(l.Spotlight) {
+	case SpotlightReassert:
+		return SpotlightReassert
+	case SpotlightIgnore:
+		return SpotlightIgnore
+	}
+	return SpotlightReport
+}
+
+// Managed policy modes for LockScreenConfig.ManagedPolicy.
+const (
+	ManagedPolicyReport  = "report"
+	ManagedPolicyCoexist = "coexist"
+	ManagedPolicyIgnore  = "ignore"
+)
+
+// ManagedPolicyMode returns the configured managed policy mode, defaulting to
+// "report".
+func (l LockScreenConfig) ManagedPolicyMode() string {
+	switch strings.ToLower(l.ManagedPolicy) {
+	case ManagedPolicyCoexist:
+		return ManagedPolicyCoexist
+	case ManagedPolicyIgnore:
+		return ManagedPolicyIgnore
+	}
+	return ManagedPolicyReport
+}
+
+// NoticeConfig describes the full-screen kiosk notice.
+type NoticeConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Text is the main message, e.g. "This system is reserved for Building Security".
+	Text     string `json:"text,omitempty"`
+	Subtitle string `json:"subtitle,omitempty"`
+	// Background and Foreground are "#RRGGBB" colors.
+	Background string `json:"background,omitempty"`
+	Foreground string `json:"foreground,omitempty"`
+	// Logo is an optional path to an image drawn above the message.
+	Logo string `json:"logo,omitempty"`
+	// ShowStatus keeps the system info and services panels on top of the notice.
+	ShowStatus bool `json:"show_status,omitempty"`
+}
+
+// AdjustConfig is applied to the wallpaper before the panels are drawn, in
+// the order of the fields.
+type AdjustConfig struct {
+	// Brightness darkens (negative) or brightens (positive) the image by a
+	// percentage, from -100 (black) to 100 (white).
+	Brightness float64 `json:"brightness,omitempty"`
+	// Desaturate removes a percentage of the color, 100 leaving grayscale.
+	Desaturate float64 `json:"desaturate,omitempty"`
+	// Tint is an optional "#RRGGBB" color blended over the image.
+	Tint string `json:"tint,omitempty"`
+	// TintStrength is the tint opacity from 0 to 1 (default 0.2).
+	TintStrength float64 `json:"tint_strength,omitempty"`
+	// Vignette darkens the edges by up to a percentage, 100 making the
+	// corners black.
+	Vignette float64 `json:"vignette,omitempty"`
+}
+
+// Enabled reports whether any adjustment is configured.
+func (a AdjustConfig) Enabled() bool {
+	return a.Brightness != 0 || a.Desaturate != 0 || a.Tint != "" || a.Vignette != 0
+}
+
+// BannerConfig is a legal notice drawn as a full-width strip along the bottom
+// of the login screen.
+type BannerConfig struct {
+	// Title is an optional heading, e.g. "Authorized use only".
+	Title string `json:"title,omitempty"`
+	// Text is the notice; "\n" starts a new line and long lines wrap.
+	Text string `json:"text,omitempty"`
+	// UseLegalNotice takes the title and text from the interactive logon
+	// message policy (legalnoticecaption/legalnoticetext) when they are unset.
+	UseLegalNotice bool `json:"use_legal_notice,omitempty"`
+	// Background and Foreground are "#RRGGBB" colors.
+	Background string `json:"background,omitempty"`
+	Foreground string `json:"foreground,omitempty"`
+}
+
+// SeasonConfig is a date range with its own wallpaper source and/or tint.
+type SeasonConfig struct {
+	Name string `json:"name"`
+	// From and To are inclusive "MM-DD" dates; a range may wrap the new year (e.g. 12-01 to 01-06).
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Source replaces slide.recipes during the season: a folder, an image URL,
+	// "library:<name>", "bing", "apod" or "unsplash".
+	Source string `json:"source,omitempty"`
+	// Tint is an optional "#RRGGBB" color blended over the wallpaper.
+	Tint string `json:"tint,omitempty"`
+	// TintStrength is the tint opacity from 0 to 1 (default 0.2).
+	TintStrength float64 `json:"tint_strength,omitempty"`
+}
+
+// ActiveSeason returns the first season whose date range contains t, or nil.
+func (c *Config) ActiveSeason(t time.Time) *SeasonConfig {
+	day := t.Format("01-02")
+	for i := range c.Seasons {
+		from, to := c.Seasons[i].From, c.Seasons[i].To
+		if from == "" || to == "" {
+			continue
+		}
+		// MM-DD strings compare in calendar order
+		if from <= to {
+			if day >= from && day <= to {
+				return &c.Seasons[i]
+			}
+		} else if day >= from || day <= to {
+			return &c.Seasons[i]
+		}
+	}
+	return nil
+}
+
+// CollectorsConfig turns on the optional health checks.
+type CollectorsConfig struct {
+	// ADHealth checks the domain secure channel, the machine account password
+	// age and the clock skew against the DC, flagging a broken trust
+	// relationship before a user hits it at logon.
+	ADHealth bool `json:"ad_health,omitempty"`
+	// LocalAdmins lists the members of the local Administrators group, with
+	// the built-in and domain administrator accounts collapsed into one line.
+	// Names follow redaction.usernames.
+	LocalAdmins bool `json:"local_admins,omitempty"`
+	// ProfileSizes shows the largest user profiles on the system drive. The
+	// scan is reused for six hours. Names follow redaction.usernames.
+	ProfileSizes bool `json:"profile_sizes,omitempty"`
+	// Backup shows the last job of Windows Server Backup, Veeam Agent and
+	// Macrium Reflect, flagging failed jobs and ones older than BackupMaxAge.
+	Backup bool `json:"backup,omitempty"`
+	// BackupMaxAge is how old the last backup may be, e.g. "26h" (default).
+	BackupMaxAge string `json:"backup_max_age,omitempty"`
+	// TimeSync shows the Windows Time source, stratum and last sync, and flags
+	// a clock that is unsynchronized or off its source by more than MaxClockDrift.
+	TimeSync bool `json:"time_sync,omitempty"`
+	// MaxClockDrift is the largest accepted offset, e.g. "30s" (default).
+	MaxClockDrift string `json:"max_clock_drift,omitempty"`
+	// RemoteAccess shows whether Remote Desktop is enabled, its port and
+	// Network Level Authentication, and the remote access tools installed
+	// (TeamViewer, AnyDesk, ScreenConnect and others).
+	RemoteAccess bool `json:"remote_access,omitempty"`
+	// DHCP warns about DHCP Server scopes above DHCPThreshold percent in use
+	// and about IP address conflicts logged in the last week.
+	DHCP bool `json:"dhcp,omitempty"`
+	// DHCPThreshold is the scope utilization in percent that is flagged
+	// (default 90).
+	DHCPThreshold int `json:"dhcp_threshold,omitempty"`
+	// Crashes counts the bluescreens (with their stop codes) and display
+	// driver timeouts (TDRs) of the last week.
+	Crashes bool `json:"crashes,omitempty"`
+	// Asset adds the SMBIOS asset tag and chassis type to the system
+	// information, followed by the values of AssetRegistryKey and AssetFields.
+	Asset bool `json:"asset,omitempty"`
+	// AssetRegistryKey is an HKLM key whose values are all shown as
+	// "Name: value" lines, e.g. written by the imaging process.
+	AssetRegistryKey string `json:"asset_registry_key,omitempty"`
+	// AssetFields are extra lines read from a registry value or an
+	// environment variable.
+	AssetFields []AssetFieldConfig `json:"asset_fields,omitempty"`
+	// DiskTrend samples the free space of every volume hourly and warns when
+	// the last week's trend fills one within DiskFullDays.
+	DiskTrend bool `json:"disk_trend,omitempty"`
+	// DiskFullDays is how soon a volume must be projected to fill up before
+	// it is shown (default 14).
+	DiskFullDays int `json:"disk_full_days,omitempty"`
+	// Processes lists the top CPU and memory consuming processes at render
+	// time.
+	Processes bool `json:"processes,omitempty"`
+	// Logons lists the last few console and Remote Desktop logons from the
+	// Security log.
+	Logons bool `json:"logons,omitempty"`
+	// VPN shows the connected VPN adapters, e.g. for an off-site profile.
+	VPN bool `json:"vpn,omitempty"`
+	// BitLocker shows whether a recovery password of each encrypted volume is
+	// escrowed to AD or Azure AD. The keys themselves are never read.
+	BitLocker bool `json:"bitlocker,omitempty"`
+	// VM shows the cloud instance (Azure, AWS, Google Cloud: ID, size and
+	// region from the instance metadata service), the Hyper-V host or the
+	// VMware Tools version when running in a virtual machine.
+	VM bool `json:"vm,omitempty"`
+	// DriverUpdates shows the driver and firmware updates pending in Windows
+	// Update and, when installed, Dell Command Update. The scan is reused
+	// for a day.
+	DriverUpdates bool `json:"driver_updates,omitempty"`
+	// SecurityScore grades the antivirus, firewall, BitLocker, Windows
+	// Update, Secure Boot and Remote Desktop NLA state and draws the grade
+	// as a badge in the lower-left corner with the factors beneath.
+	SecurityScore bool `json:"security_score,omitempty"`
+	// RunHistory lists this many of the last runs with their outcome, so a
+	// stale or unhealthy agent shows on the image (e.g. 5; 0 hides them).
+	RunHistory int `json:"run_history,omitempty"`
+	// RequiredSoftware is a checklist of programs that must be installed and
+	// running, such as security and management agents.
+	RequiredSoftware []RequiredSoftwareConfig `json:"required_software,omitempty"`
+}
+
+// AssetFieldConfig is a custom system information line.
+type AssetFieldConfig struct {
+	Label string `json:"label"`
+	// Registry is the full path of a value, e.g. `HKLM\SOFTWARE\Contoso\Asset\Owner`.
+	Registry string `json:"registry,omitempty"`
+	// Env is an environment variable, used when Registry is unset or empty.
+	// Scheduled tasks see machine-wide variables only.
+	Env string `json:"env,omitempty"`
+}
+
+// RequiredSoftwareConfig is a program on the required software checklist. It
+// is found by its service, its executable or both.
+type RequiredSoftwareConfig struct {
+	Name    string `json:"name"`
+	Service string `json:"service,omitempty"`
+	// Path is the executable; environment variables such as %ProgramFiles%
+	// are expanded.
+	Path string `json:"path,omitempty"`
+}
+
+// MinIntervalDuration returns the minimum time between refresh runs, or 0 for
+// no limit. An invalid value counts as no limit.
+func (c *Config) MinIntervalDuration() time.Duration {
+	d, err := time.ParseDuration(c.MinInterval)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// ModeReportOnly is the Config.Mode that leaves the login screen alone.
+const ModeReportOnly = "report-only"
+
+// ReportOnly reports whether BgStatusService should only collect and report.
+func (c *Config) ReportOnly() bool {
+	return strings.EqualFold(strings.TrimSpace(c.Mode), ModeReportOnly)
+}
+
+// Services panel modes for Config.ServicesDisplay.
+const (
+	ServicesDisplayFull     = "full"
+	ServicesDisplaySummary  = "summary"
+	ServicesDisplayFailures = "failures"
+)
+
+// ServicesDisplayMode returns the configured services panel mode, defaulting
+// to "full".
+func (c *Config) ServicesDisplayMode() string {
+	switch strings.ToLower(c.ServicesDisplay) {
+	case ServicesDisplaySummary:
+		return ServicesDisplaySummary
+	case ServicesDisplayFailures:
+		return ServicesDisplayFailures
+	}
+	return ServicesDisplayFull
+}
+
+// BackupMaxAgeDuration returns how old the last backup may be, or 0 for the
+// collector's default. An invalid value falls back to the default.
+func (c CollectorsConfig) BackupMaxAgeDuration() time.Duration {
+	d, err := time.ParseDuration(c.BackupMaxAge)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// MaxClockDriftDuration returns the largest accepted clock offset, or 0 for
+// the collector's default. An invalid value falls back to the default.
+func (c CollectorsConfig) MaxClockDriftDuration() time.Duration {
+	d, err := time.ParseDuration(c.MaxClockDrift)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// CalendarConfig lists the ICS feeds shown by BgStatusService.
+type CalendarConfig struct {
+	// ICSURLs are http(s):// or webcal:// iCalendar feeds, e.g. a shared room calendar.
+	ICSURLs []string `json:"ics_urls,omitempty"`
+	// MaxEvents is the number of events shown (default 5).
+	MaxEvents int `json:"max_events,omitempty"`
+	// DaysAhead limits events to those starting within this many days (default 7).
+	DaysAhead int `json:"days_ahead,omitempty"`
+}
+
+// AttributionConfig controls rendering of image credits.
+type AttributionConfig struct {
+	// Show draws the credit line onto the applied wallpaper.
+	Show bool `json:"show,omitempty"`
+	// Corner is one of "top-left", "top-right", "bottom-left" or "bottom-right" (default).
+	Corner string `json:"corner,omitempty"`
+}
+
+// Crossfade frame counts and the longest fade.
+const (
+	DefaultTransitionFrames = 8
+	MinTransitionFrames     = 2
+	MaxTransitionFrames     = 30
+	DefaultTransitionTime   = time.Second
+	MaxTransitionTime       = 5 * time.Second
+)
+
+// TransitionConfig controls the crossfade between desktop wallpapers.
+type TransitionConfig struct {
+	// Crossfade blends the old wallpaper into the new one.
+	Crossfade bool `json:"crossfade,omitempty"`
+	// Duration of the fade, e.g. "1s" (default) up to "5s".
+	Duration string `json:"duration,omitempty"`
+	// Frames is the number of blended images shown in between (default 8, 2
+	// to 30). Each one is a full wallpaper change, so more frames are smoother
+	// but take longer to prepare.
+	Frames int `json:"frames,omitempty"`
+}
+
+// FadeDuration returns the length of the fade. An invalid value falls back to
+// the default; longer ones are capped.
+func (t TransitionConfig) FadeDuration() time.Duration {
+	d, err := time.ParseDuration(t.Duration)
+	if err != nil || d <= 0 {
+		return DefaultTransitionTime
+	}
+	if d > MaxTransitionTime {
+		return MaxTransitionTime
+	}
+	return d
+}
+
+// FrameCount returns the number of blended frames, clamped to the allowed
+// range.
+func (t TransitionConfig) FrameCount() int {
+	switch {
+	case t.Frames == 0:
+		return DefaultTransitionFrames
+	case t.Frames < MinTransitionFrames:
+		return MinTransitionFrames
+	case t.Frames > MaxTransitionFrames:
+		return MaxTransitionFrames
+	}
+	return t.Frames
+}
+
+// SafetyConfig controls which domains images may be downloaded from and how
+// downloaded images are classified before they are applied.
+type SafetyConfig struct {
+	// AllowedDomains lists domains images may come from. A domain also matches its
+	// subdomains. When empty, every domain not in DeniedDomains is allowed.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	// DeniedDomains lists domains that are always rejected.
+	DeniedDomains []string `json:"denied_domains,omitempty"`
+	// AllowlistOnly rejects any source that is not in AllowedDomains, including
+	// when AllowedDomains is empty.
+	AllowlistOnly bool `json:"allowlist_only,omitempty"`
+
+	// ClassifierCommand is an external program run for every downloaded image.
+	// {file} is replaced with the image path (appended if missing). Exit code 0
+	// means the image is acceptable; anything else rejects it.
+	ClassifierCommand string `json:"classifier_command,omitempty"`
+	// ClassifierURL is an HTTP endpoint that receives the image bytes via POST and
+	// answers with JSON {"safe": bool} or {"score": 0.0-1.0} (probability unsafe).
+	ClassifierURL string `json:"classifier_url,omitempty"`
+	// ClassifierThreshold is the score at or above which an image is rejected.
+	// Defaults to 0.5.
+	ClassifierThreshold float64 `json:"classifier_threshold,omitempty"`
+}
+
+// LibraryConfig describes a wallpaper library stored in S3, Azure Blob Storage or WebDAV.
+// Secrets may be left empty here and supplied through the environment instead
+// (AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN, AZURE_STORAGE_SAS_TOKEN,
+// WEBDAV_USERNAME/WEBDAV_PASSWORD).
+type LibraryConfig struct {
+	Name string `json:"name"`
+	// Type is one of "s3", "azure" or "webdav".
+	Type string `json:"type"`
+	// Prefix limits the listing to keys/paths under this prefix.
+	Prefix string `json:"prefix,omitempty"`
+
+	// S3 (and S3-compatible services such as MinIO)
+	Bucket          string `json:"bucket,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty"`
+
+	// Azure Blob Storage
+	Account   string `json:"account,omitempty"`
+	Container string `json:"container,omitempty"`
+	SASToken  string `json:"sas_token,omitempty"`
+
+	// WebDAV
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Library returns the library with the given name, or nil if none is configured.
+func (c *Config) Library(name string) *LibraryConfig {
+	for i := range c.Libraries {
+		if strings.EqualFold(c.Libraries[i].Name, name) {
+			return &c.Libraries[i]
+		}
+	}
+	return nil
+}
+
+// Dir returns the directory holding the config file and other shared state.
+// Uses PROGRAMDATA environment variable to support non-standard Windows installations.
+func Dir() string {
+	programData := os.Getenv("PROGRAMDATA")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	return filepath.Join(programData, "BgStatusService")
+}
+
+// Path returns the full path to the config file.
+func Path() string {
+	return filepath.Join(Dir(), FileName)
+}
+
+// Default returns a config with default values applied.
+func Default() *Config {
+	return &Config{}
+}
+
+// Load reads the config file from the default location and applies the
+// HKLM\SOFTWARE\BgStatusService and BGSTATUS_* overrides on top of it, then
+// the first tenant profile matching this computer. A missing file is not an error and yields the default config. Callers that
+// modify and Save the config should use LoadFrom(Path()) instead, so the
+// overrides are not written into the file.
+func Load() (*Config, error) {
+	cfg, err := LoadFrom(Path())
+	if overrideErr := applyOverrides(cfg); overrideErr != nil && err == nil {
+		err = overrideErr
+	}
+	applyProfile(cfg)
+	return cfg, err
+}
+
+// Save writes the config file to the default location.
+func Save(cfg *Config) error {
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := os.WriteFile(Path(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return Secure()
+}
+
+// LoadFrom reads the config file at the given path.
+// A missing file is not an error and yields the default config.
+func LoadFrom(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return Default(), fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
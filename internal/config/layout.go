@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LayoutFileName is the name of the layout file inside the data directory.
+const LayoutFileName = "layout.json"
+
+// Widget types supported in the layout file.
+const (
+	WidgetCountdown = "countdown"
+	WidgetOnCall    = "oncall"
+	WidgetTable     = "table"
+)
+
+// Panel names a widget can be placed in.
+const (
+	PanelLeft  = "left"
+	PanelRight = "right"
+)
+
+// Layout describes what BgStatusService draws on the login screen in addition
+// to the built-in system information, so admins can compose informational lock
+// screens without code.
+type Layout struct {
+	Widgets []WidgetConfig `json:"widgets,omitempty"`
+}
+
+// WidgetConfig is a single widget. Which fields apply depends on Type.
+type WidgetConfig struct {
+	// Type is one of "countdown", "oncall" or "table".
+	Type string `json:"type"`
+	// Title is an optional header line drawn above the widget.
+	Title string `json:"title,omitempty"`
+	// Panel is "left" (default) or "right".
+	Panel string `json:"panel,omitempty"`
+
+	// Countdown: Date is "2006-01-02" or RFC 3339, Label follows the day count
+	// (e.g. "until migration cutover"), DoneText is shown once the date has passed.
+	Date     string `json:"date,omitempty"`
+	Label    string `json:"label,omitempty"`
+	DoneText string `json:"done_text,omitempty"`
+
+	// On-call: URL returns a JSON object; Format is the line to show with {field}
+	// placeholders filled from it (dotted paths such as {primary.name} are allowed).
+	URL     string            `json:"url,omitempty"`
+	Format  string            `json:"format,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Table: rows of key/value pairs, shown in order with aligned values.
+	Rows []WidgetRow `json:"rows,omitempty"`
+}
+
+// WidgetRow is one key/value row of a table widget.
+type WidgetRow struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// LayoutPath returns the full path to the layout file.
+func LayoutPath() string {
+	return filepath.Join(Dir(), LayoutFileName)
+}
+
+// LoadLayout reads the layout file from the default location.
+// A missing file is not an error and yields an empty layout.
+func LoadLayout() (*Layout, error) {
+	return LoadLayoutFrom(LayoutPath())
+}
+
+// LoadLayoutFrom reads the layout file at the given path.
+// A missing file is not an error and yields an empty layout.
+func LoadLayoutFrom(path string) (*Layout, error) {
+	layout := &Layout{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return layout, nil
+		}
+		return layout, fmt.Errorf("failed to read layout: %w", err)
+	}
+
+	if err := json.Unmarshal(data, layout); err != nil {
+		return &Layout{}, fmt.Errorf("failed to parse layout %s: %w", path, err)
+	}
+
+	return layout, nil
+}
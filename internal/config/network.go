@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procSendARP = syscall.NewLazyDLL("iphlpapi.dll").NewProc("SendARP")
+
+// NetworkLocation identifies the network the machine is on.
+type NetworkLocation struct {
+	// DNSSuffixes are the connection-specific DNS suffixes of the connected
+	// adapters, e.g. "corp.example.com".
+	DNSSuffixes []string
+	// GatewayMACs are the MAC addresses of the IPv4 default gateways, as
+	// "aa-bb-cc-dd-ee-ff".
+	GatewayMACs []string
+}
+
+// CurrentNetwork returns the DNS suffixes and gateway MAC addresses of the
+// connected adapters.
+func CurrentNetwork() (NetworkLocation, error) {
+	var location NetworkLocation
+
+	size := uint32(15000)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC,
+			windows.GAA_FLAG_INCLUDE_GATEWAYS|windows.GAA_FLAG_SKIP_ANYCAST|windows.GAA_FLAG_SKIP_MULTICAST,
+			0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			return location, fmt.Errorf("failed to list network adapters: %w", err)
+		}
+	}
+
+	for a := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); a != nil; a = a.Next {
+		if a.OperStatus != windows.IfOperStatusUp || a.IfType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+			continue
+		}
+		if suffix := windows.UTF16PtrToString(a.DnsSuffix); suffix != "" {
+			location.DNSSuffixes = append(location.DNSSuffixes, strings.ToLower(suffix))
+		}
+		for g := a.FirstGatewayAddress; g != nil; g = g.Next {
+			ip := g.Address.IP().To4()
+			if ip == nil || ip.IsUnspecified() {
+				continue
+			}
+			if mac := gatewayMAC(ip); mac != "" {
+				location.GatewayMACs = append(location.GatewayMACs, mac)
+			}
+		}
+	}
+	return location, nil
+}
+
+// gatewayMAC resolves an IPv4 address on the local network to its MAC address
+// with SendARP, which answers from the ARP cache when it can.
+func gatewayMAC(ip net.IP) string {
+	dest := uint32(ip[0]) | uint32(ip[1])<<8 | uint32(ip[2])<<16 | uint32(ip[3])<<24
+	var mac [8]byte
+	length := uint32(len(mac))
+	ret, _, _ := procSendARP.Call(uintptr(dest), 0, uintptr(unsafe.Pointer(&mac[0])), uintptr(unsafe.Pointer(&length)))
+	if ret != 0 || length != 6 {
+		return ""
+	}
+	return normalizeMAC(net.HardwareAddr(mac[:6]).String())
+}
+
+// normalizeMAC writes a MAC address as lower case "aa-bb-cc-dd-ee-ff",
+// whichever separator it was given with.
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "-", ".", "-").Replace(strings.TrimSpace(mac)))
+}
+
+// matchesNetwork reports whether the profile's network matchers match the
+// location.
+func (p ProfileConfig) matchesNetwork(location NetworkLocation) bool {
+	for _, pattern := range p.DNSSuffixes {
+		pattern = strings.ToLower(strings.Trim(pattern, ". "))
+		for _, suffix := range location.DNSSuffixes {
+			if ok, _ := filepath.Match(pattern, suffix); ok {
+				return true
+			}
+		}
+	}
+	for _, want := range p.GatewayMACs {
+		for _, mac := range location.GatewayMACs {
+			if normalizeMAC(want) == mac {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasNetworkMatchers reports whether the profile is picked by network location.
+func (p ProfileConfig) hasNetworkMatchers() bool {
+	return len(p.DNSSuffixes) > 0 || len(p.GatewayMACs) > 0
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// EnvPrefix starts the environment variables that override config keys. The
+// rest of the name is the key path in upper case with "__" between levels, for
+// example BGSTATUS_MEMORY_LIMIT_MB or BGSTATUS_FETCH_POLICY__ALLOW_METERED.
+const EnvPrefix = "BGSTATUS_"
+
+// OverrideKeyPath is the HKLM key whose values override config keys. Value names
+// are key paths with "." between levels, for example "fetch_policy.allow_metered".
+const OverrideKeyPath = `SOFTWARE\BgStatusService`
+
+// overrideKey is a config key that can be overridden, with the Go type of its field.
+type overrideKey struct {
+	path []string
+	typ  reflect.Type
+}
+
+// EnvName returns the environment variable that overrides the key.
+func (k overrideKey) EnvName() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(k.path, "__"))
+}
+
+// RegistryName returns the registry value name that overrides the key.
+func (k overrideKey) RegistryName() string {
+	return strings.Join(k.path, ".")
+}
+
+// overrideKeys lists every key of t (and of the objects nested in it), using
+// the JSON names. Lists and nested objects can also be overridden as a whole.
+func overrideKeys(t reflect.Type, parent []string) []overrideKey {
+	var keys []overrideKey
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || strings.HasPrefix(name, "$") {
+			continue
+		}
+		path := append(append([]string{}, parent...), name)
+		keys = append(keys, overrideKey{path: path, typ: field.Type})
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, overrideKeys(field.Type, path)...)
+		}
+	}
+	return keys
+}
+
+// applyOverrides applies the registry overrides and then the environment
+// overrides to cfg, so an environment variable wins over the registry and both
+// win over the config file. Invalid values are skipped and reported in the
+// returned error; the valid ones are still applied.
+func applyOverrides(cfg *Config) error {
+	values := registryOverrides()
+
+	var problems []error
+	for _, key := range overrideKeys(reflect.TypeOf(Config{}), nil) {
+		source, raw, ok := "", "", false
+		if v, found := values[strings.ToLower(key.RegistryName())]; found {
+			source, raw, ok = `HKLM\`+OverrideKeyPath+` `+key.RegistryName(), v, true
+		}
+		if v, found := os.LookupEnv(key.EnvName()); found {
+			source, raw, ok = key.EnvName(), v, true
+		}
+		if !ok {
+			continue
+		}
+
+		if err := setOverride(cfg, key, raw); err != nil {
+			problems = append(problems, fmt.Errorf("invalid override %s: %w", source, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// setOverride converts raw to the type of the key and stores it in cfg.
+func setOverride(cfg *Config, key overrideKey, raw string) error {
+	value, err := overrideValue(key.typ, raw)
+	if err != nil {
+		return err
+	}
+
+	// Build {"a":{"b":value}} and decode it over cfg, which leaves the other
+	// keys of nested objects alone.
+	var doc interface{} = value
+	for i := len(key.path) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{key.path[i]: doc}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cfg)
+}
+
+// overrideValue converts a string override into a value that encodes to the
+// JSON the key expects. Strings are used as is; booleans accept 1/0 and
+// true/false; string lists accept a JSON array or comma-separated values;
+// anything else must be JSON.
+func overrideValue(t reflect.Type, raw string) (interface{}, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	raw = strings.TrimSpace(raw)
+
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return strconv.Atoi(raw)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String && !strings.HasPrefix(raw, "[") {
+			var list []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, nil
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, fmt.Errorf("expected JSON: %w", err)
+	}
+	return value, nil
+}
+
+// registryOverrides reads the values under HKLM\SOFTWARE\BgStatusService, keyed
+// by lower-case value name. DWORD and QWORD values become numbers and
+// REG_MULTI_SZ values become JSON arrays. A missing key yields no overrides.
+func registryOverrides() map[string]string {
+	values := make(map[string]string)
+
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, OverrideKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return values
+	}
+	defer key.Close()
+
+	names, err := key.ReadValueNames(0)
+	if err != nil {
+		return values
+	}
+	for _, name := range names {
+		if v, _, err := key.GetStringValue(name); err == nil {
+			values[strings.ToLower(name)] = v
+		} else if v, _, err := key.GetIntegerValue(name); err == nil {
+			values[strings.ToLower(name)] = strconv.FormatUint(v, 10)
+		} else if v, _, err := key.GetStringsValue(name); err == nil {
+			data, _ := json.Marshal(v)
+			values[strings.ToLower(name)] = string(data)
+		}
+	}
+	return values
+}
+
+// Policy sources reported by PolicySources.
+const (
+	PolicySourceDefault     = "default"
+	PolicySourceFile        = "file"
+	PolicySourceRegistry    = "registry"
+	PolicySourceEnvironment = "environment"
+)
+
+// PolicySources lists where the effective configuration comes from: the
+// config file, registry overrides (e.g. pushed by Intune or Group Policy) and
+// environment overrides, in order of precedence from lowest. With none of
+// them only the defaults apply.
+func PolicySources() []string {
+	var sources []string
+	if _, err := os.Stat(Path()); err == nil {
+		sources = append(sources, PolicySourceFile)
+	}
+	if len(registryOverrides()) > 0 {
+		sources = append(sources, PolicySourceRegistry)
+	}
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(strings.ToUpper(env), EnvPrefix) {
+			sources = append(sources, PolicySourceEnvironment)
+			break
+		}
+	}
+	if len(sources) == 0 {
+		sources = append(sources, PolicySourceDefault)
+	}
+	return sources
+}
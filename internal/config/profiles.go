@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// groupPolicyMachineKey (HKLM) records the computer's distinguished name after
+// every Group Policy refresh on domain-joined machines.
+const groupPolicyMachineKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Group Policy\State\Machine`
+
+// ThemeConfig brands the login screen panels.
+type ThemeConfig struct {
+	// Text, Panel and Border are "#RRGGBB" colors that replace the automatic
+	// light or dark panel colors.
+	Text   string `json:"text,omitempty"`
+	Panel  string `json:"panel,omitempty"`
+	Border string `json:"border,omitempty"`
+	// PanelOpacity is the panel background opacity from 0 to 1 (default 0.63).
+	PanelOpacity float64 `json:"panel_opacity,omitempty"`
+	// Logo is a PNG or JPEG drawn below the left panel, four text lines tall.
+	Logo string `json:"logo,omitempty"`
+}
+
+// DefaultPanelOpacity matches the automatic panel background.
+const DefaultPanelOpacity = 160.0 / 255
+
+// Opacity returns the panel background opacity.
+func (t ThemeConfig) Opacity() float64 {
+	if t.PanelOpacity <= 0 || t.PanelOpacity > 1 {
+		return DefaultPanelOpacity
+	}
+	return t.PanelOpacity
+}
+
+// ProfileConfig is a set of settings for the machines of one tenant or for
+// one network location, picked at runtime by hostname, Active Directory OU or
+// the network the machine is on.
+type ProfileConfig struct {
+	Name string `json:"name"`
+	// Hostnames are case-insensitive wildcard patterns, e.g. "ACME-*".
+	Hostnames []string `json:"hostnames,omitempty"`
+	// OUs are distinguished names of organizational units; a computer anywhere
+	// below one matches, e.g. "OU=Acme,OU=Customers,DC=msp,DC=local".
+	OUs []string `json:"ous,omitempty"`
+	// DNSSuffixes are connection-specific DNS suffixes (wildcards allowed,
+	// e.g. "*.corp.example.com"); the profile applies while any connected
+	// adapter has one of them.
+	DNSSuffixes []string `json:"dns_suffixes,omitempty"`
+	// GatewayMACs are MAC addresses of default gateways, e.g. the office
+	// router's "00-11-22-33-44-55".
+	GatewayMACs []string `json:"gateway_macs,omitempty"`
+	// Fallback applies the profile when no earlier one matched, e.g. an
+	// "off-site" profile listed after the office ones.
+	Fallback bool `json:"fallback,omitempty"`
+
+	// Theme replaces the top-level theme when set.
+	Theme ThemeConfig `json:"theme,omitempty"`
+	// WatchedServices replace the top-level watched_services when set.
+	WatchedServices []string `json:"watched_services,omitempty"`
+	// Collectors replace the top-level collectors when set.
+	Collectors *CollectorsConfig `json:"collectors,omitempty"`
+	// MinInterval replaces the top-level min_interval when set.
+	MinInterval string `json:"min_interval,omitempty"`
+}
+
+// Matches reports whether the profile applies to a computer with the given
+// hostname and distinguished name (empty when not domain-joined).
+func (p ProfileConfig) Matches(hostname, dn string) bool {
+	for _, pattern := range p.Hostnames {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(hostname)); ok {
+			return true
+		}
+	}
+	dn = strings.ToLower(dn)
+	for _, ou := range p.OUs {
+		if ou != "" && strings.HasSuffix(dn, ","+strings.ToLower(strings.TrimSpace(ou))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ComputerDN returns the computer's Active Directory distinguished name as
+// recorded by the last Group Policy refresh, or "" when not domain-joined.
+func ComputerDN() string {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, groupPolicyMachineKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+
+	dn, _, err := key.GetStringValue("Distinguished-Name")
+	if err != nil {
+		return ""
+	}
+	return dn
+}
+
+// applyProfile applies the first profile matching this computer or its
+// current network and records its name in ActiveProfile.
+func applyProfile(cfg *Config) {
+	if len(cfg.Profiles) == 0 {
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	dn := ComputerDN()
+	// Only look at the network when a profile asks for it
+	var location NetworkLocation
+	for _, p := range cfg.Profiles {
+		if p.hasNetworkMatchers() {
+			location, _ = CurrentNetwork()
+			break
+		}
+	}
+	for _, p := range cfg.Profiles {
+		if !p.Matches(hostname, dn) && !p.matchesNetwork(location) && !p.Fallback {
+			continue
+		}
+		cfg.ActiveProfile = p.Name
+		if p.Theme != (ThemeConfig{}) {
+			cfg.Theme = p.Theme
+		}
+		if len(p.WatchedServices) > 0 {
+			cfg.WatchedServices = p.WatchedServices
+		}
+		if p.Collectors != nil {
+			cfg.Collectors = *p.Collectors
+		}
+		if p.MinInterval != "" {
+			cfg.MinInterval = p.MinInterval
+		}
+		return
+	}
+}
@@ -0,0 +1,12 @@
+package config
+
+import _ "embed"
+
+//go:generate go run ./schemagen
+
+// JSONSchema is the JSON Schema of the config file, generated from Config and
+// the doc comments of its fields, for editor completion and for linting
+// config files before they are deployed (bgStatusService --print-schema).
+//
+//go:embed schema.json
+var JSONSchema []byte
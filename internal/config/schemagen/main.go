@@ -0,0 +1,217 @@
+// Command schemagen writes schema.json, the JSON Schema of the config file,
+// from the Config struct and the doc comments of its fields. It is run by
+// go generate in internal/config after the config changes.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// outputFile is written to the package directory.
+const outputFile = "schema.json"
+
+// enums lists the allowed values of string settings by "Type.Field". The
+// config accepts them in any case; the schema only suggests these.
+var enums = map[string][]string{
+	"Config.Mode":                    {"report-only"},
+	"Config.ServicesDisplay":         {"full", "summary", "failures"},
+	"Config.LayoutDirection":         {"auto", "ltr", "rtl"},
+	"ServerCoreConfig.Output":        {"logon_message", "motd", "both", "none"},
+	"OutputConfig.Format":            {"jpg", "png"},
+	"OutputConfig.Naming":            {"unix", "datetime"},
+	"LockScreenConfig.Spotlight":     {"report", "reassert", "ignore"},
+	"LockScreenConfig.ManagedPolicy": {"report", "coexist", "ignore"},
+	"UpdateConfig.Ring":              {"pilot", "auto", "last"},
+	"VDIConfig.Mode":                 {"auto", "persistent", "non_persistent"},
+	"AttributionConfig.Corner":       {"top-left", "top-right", "bottom-left", "bottom-right"},
+	"RedactionConfig.Hostname":       {"show", "mask", "hash", "omit"},
+	"RedactionConfig.SerialNumber":   {"show", "mask", "hash", "omit"},
+	"RedactionConfig.IPAddresses":    {"show", "mask", "hash", "omit"},
+	"RedactionConfig.Usernames":      {"show", "mask", "hash", "omit"},
+	"TriggerConfig.Type":             {"lock", "unlock", "disconnect", "logon", "daily", "event"},
+	"LibraryConfig.Type":             {"s3", "azure", "azureblob", "blob", "webdav"},
+	"ThresholdRule.Comparator":       {">", ">=", "<", "<="},
+}
+
+// schema is a JSON Schema node; only the keywords used here are included.
+type schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Definitions          map[string]*schema `json:"definitions,omitempty"`
+}
+
+// generator turns the package's struct types into schema definitions.
+type generator struct {
+	structs     map[string]*ast.TypeSpec
+	docs        map[string]string
+	definitions map[string]*schema
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "schemagen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	g := &generator{structs: map[string]*ast.TypeSpec{}, docs: map[string]string{}, definitions: map[string]*schema{}}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if _, ok := ts.Type.(*ast.StructType); !ok {
+						continue
+					}
+					g.structs[ts.Name.Name] = ts
+					doc := ts.Doc
+					if doc == nil {
+						doc = gen.Doc
+					}
+					g.docs[ts.Name.Name] = commentText(doc)
+				}
+			}
+		}
+	}
+	if g.structs["Config"] == nil {
+		return fmt.Errorf("type Config not found")
+	}
+
+	root := g.structSchema("Config")
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.Title = "BgStatusService config"
+	delete(g.definitions, "Config")
+	root.Definitions = g.definitions
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, append(data, '\n'), 0644)
+}
+
+// structSchema returns the object schema of a struct type of the package.
+func (g *generator) structSchema(name string) *schema {
+	st := g.structs[name].Type.(*ast.StructType)
+	s := &schema{
+		Description:          g.docs[name],
+		Type:                 "object",
+		Properties:           map[string]*schema{},
+		AdditionalProperties: false,
+	}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 || !field.Names[0].IsExported() {
+			continue
+		}
+		key, omitempty := jsonKey(field)
+		if key == "-" {
+			continue
+		}
+		prop := g.typeSchema(field.Type)
+		desc := commentText(field.Doc)
+		if desc == "" {
+			desc = commentText(field.Comment)
+		}
+		if prop.Ref == "" {
+			prop.Description = desc
+		} else if desc != "" {
+			// Keywords next to $ref are ignored in draft 7, so wrap it
+			prop = &schema{Description: desc, AllOf: []*schema{prop}}
+		}
+		prop.Enum = enums[name+"."+field.Names[0].Name]
+		s.Properties[key] = prop
+		if !omitempty {
+			s.Required = append(s.Required, key)
+		}
+	}
+	return s
+}
+
+// typeSchema returns the schema of a field type: basic types, slices, maps,
+// pointers and the package's structs (as references to their definitions).
+func (g *generator) typeSchema(expr ast.Expr) *schema {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return g.typeSchema(t.X)
+	case *ast.ArrayType:
+		return &schema{Type: "array", Items: g.typeSchema(t.Elt)}
+	case *ast.MapType:
+		return &schema{Type: "object", AdditionalProperties: g.typeSchema(t.Value)}
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return &schema{Type: "string"}
+		case "bool":
+			return &schema{Type: "boolean"}
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			return &schema{Type: "integer"}
+		case "float32", "float64":
+			return &schema{Type: "number"}
+		}
+		if _, ok := g.structs[t.Name]; ok {
+			if _, done := g.definitions[t.Name]; !done {
+				g.definitions[t.Name] = nil // placeholder against recursion
+				g.definitions[t.Name] = g.structSchema(t.Name)
+			}
+			return &schema{Ref: "#/definitions/" + t.Name}
+		}
+	}
+	// Anything else (e.g. json.RawMessage) accepts any value
+	return &schema{}
+}
+
+// jsonKey returns a field's JSON key and whether it is omitempty.
+func jsonKey(field *ast.Field) (string, bool) {
+	key := field.Names[0].Name
+	if field.Tag == nil {
+		return key, false
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return key, false
+	}
+	name, opts, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+	if name != "" {
+		key = name
+	}
+	return key, strings.Contains(opts, "omitempty")
+}
+
+// commentText joins a comment into one line.
+func commentText(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(group.Text()), " ")
+}
@@ -0,0 +1,53 @@
+// Package errs defines the error kinds shared by the login screen, installer and
+// changer code, so callers can branch with errors.Is instead of matching message
+// text. Errors keep their original message; the kind is attached with Mark.
+package errs
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	// ErrAccessDenied means the OS refused access to a file, registry key or service.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrMethodUnsupported means no method for the operation works on this edition,
+	// build or context.
+	ErrMethodUnsupported = errors.New("method not supported")
+	// ErrNoImage means there is no image to work with (no backup, empty folder or list).
+	ErrNoImage = errors.New("no image found")
+	// ErrElevationRequired means the operation needs administrator privileges and
+	// they could not be obtained.
+	ErrElevationRequired = errors.New("elevation required")
+	// ErrImageRejected means an image file is too large, has too many pixels or
+	// could not be decoded safely.
+	ErrImageRejected = errors.New("image rejected")
+)
+
+// marked attaches an error kind to an error without changing its message.
+type marked struct {
+	err  error
+	kind error
+}
+
+func (m *marked) Error() string   { return m.err.Error() }
+func (m *marked) Unwrap() []error { return []error{m.err, m.kind} }
+
+// Mark returns err with kind attached, so errors.Is(err, kind) reports true.
+// Returns nil if err is nil.
+func Mark(kind, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &marked{err: err, kind: kind}
+}
+
+// Classify attaches ErrAccessDenied to permission errors from the OS (including
+// ERROR_ACCESS_DENIED from the registry and service manager). Other errors are
+// returned unchanged.
+func Classify(err error) error {
+	if err != nil && errors.Is(err, os.ErrPermission) {
+		return Mark(ErrAccessDenied, err)
+	}
+	return err
+}
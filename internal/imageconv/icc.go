@@ -0,0 +1,317 @@
+package imageconv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// maxProfileSize bounds the ICC profile read from an image; real display
+// profiles are a few KB.
+const maxProfileSize = 4 << 20
+
+// Profile is an RGB ICC profile of the matrix/TRC kind, which is what cameras,
+// phones and image editors tag Display P3, Adobe RGB and ProPhoto images with.
+type Profile struct {
+	// toXYZ maps linear RGB to D50 XYZ, row by row.
+	toXYZ [9]float64
+	trc   [3]curve
+}
+
+// curve is a tone reproduction curve, mapping an encoded value in [0, 1] to
+// linear light.
+type curve func(float64) float64
+
+// ReadProfile returns the ICC profile embedded in a JPEG or PNG, or nil when
+// there is none, the image is tagged sRGB or the format is not one of those.
+// r is positioned back at the start afterwards.
+func ReadProfile(r io.ReadSeeker) (*Profile, error) {
+	defer r.Seek(0, io.SeekStart)
+
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(8)
+	if err != nil {
+		return nil, nil
+	}
+
+	var data []byte
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		data, err = jpegProfile(br)
+	case string(magic) == "\x89PNG\r\n\x1a\n":
+		data, err = pngProfile(br)
+	}
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	p, err := ParseProfile(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsSRGB() {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// jpegProfile reassembles the ICC profile from a JPEG's APP2 segments, which
+// carry it in numbered chunks of up to 64 KB.
+func jpegProfile(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(2); err != nil {
+		return nil, err
+	}
+
+	chunks := map[int][]byte{}
+	total := 0
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:2]); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xFF {
+			return nil, fmt.Errorf("malformed JPEG marker")
+		}
+		// Fill bytes before a marker
+		for marker[1] == 0xFF {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			marker[1] = b
+		}
+		// The profile comes before the image data
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			break
+		}
+		if marker[1] >= 0xD0 && marker[1] <= 0xD7 || marker[1] == 0x01 {
+			continue
+		}
+
+		if _, err := io.ReadFull(r, marker[2:]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if length < 0 {
+			return nil, fmt.Errorf("malformed JPEG segment")
+		}
+		if marker[1] != 0xE2 {
+			if _, err := r.Discard(length); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, err
+		}
+		const tag = "ICC_PROFILE\x00"
+		if len(segment) < len(tag)+2 || string(segment[:len(tag)]) != tag {
+			continue
+		}
+		total += len(segment)
+		if total > maxProfileSize {
+			return nil, fmt.Errorf("ICC profile is larger than %d MB", maxProfileSize>>20)
+		}
+		chunks[int(segment[len(tag)])] = segment[len(tag)+2:]
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	seqs := make([]int, 0, len(chunks))
+	for seq := range chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	var data []byte
+	for _, seq := range seqs {
+		data = append(data, chunks[seq]...)
+	}
+	return data, nil
+}
+
+// pngProfile returns the decompressed iCCP chunk of a PNG. An sRGB chunk
+// means sRGB whatever else is there.
+func pngProfile(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(8); err != nil {
+		return nil, err
+	}
+
+	var profile []byte
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint32(header[:4]))
+		switch string(header[4:]) {
+		case "IDAT", "IEND":
+			return profile, nil
+		case "sRGB":
+			return nil, nil
+		case "iCCP":
+			if length > maxProfileSize {
+				return nil, fmt.Errorf("ICC profile is larger than %d MB", maxProfileSize>>20)
+			}
+			chunk := make([]byte, length)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, err
+			}
+			// Profile name, NUL, compression method, zlib data
+			name := bytes.IndexByte(chunk, 0)
+			if name < 0 || name+2 > len(chunk) {
+				return nil, fmt.Errorf("malformed iCCP chunk")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[name+2:]))
+			if err != nil {
+				return nil, fmt.Errorf("malformed iCCP chunk: %w", err)
+			}
+			profile, err = io.ReadAll(io.LimitReader(zr, maxProfileSize))
+			zr.Close()
+			if err != nil {
+				return nil, fmt.Errorf("malformed iCCP chunk: %w", err)
+			}
+			// Skip the CRC
+			length = 0
+		}
+		if _, err := r.Discard(length + 4); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ParseProfile parses an ICC profile. Profiles that are not RGB matrix/TRC
+// profiles (CMYK, grayscale, or lookup-table based) are an error.
+func ParseProfile(data []byte) (*Profile, error) {
+	if len(data) < 132 {
+		return nil, errors.New("ICC profile is too short")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("unsupported ICC profile (%q to %q)", data[16:20], data[20:24])
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < count; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(data) {
+			return nil, errors.New("ICC tag table is truncated")
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		size := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) || offset+size < offset {
+			return nil, errors.New("ICC tag is out of range")
+		}
+		tags[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+
+	p := &Profile{}
+	for i, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		tag := tags[name]
+		if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+			return nil, fmt.Errorf("ICC profile has no %s matrix column", name)
+		}
+		for row := 0; row < 3; row++ {
+			p.toXYZ[row*3+i] = s15Fixed16(tag[8+row*4:])
+		}
+	}
+	for i, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		c, err := parseCurve(tags[name])
+		if err != nil {
+			return nil, fmt.Errorf("ICC %s: %w", name, err)
+		}
+		p.trc[i] = c
+	}
+	return p, nil
+}
+
+// parseCurve parses a curv or para tag.
+func parseCurve(tag []byte) (curve, error) {
+	if len(tag) < 12 {
+		return nil, errors.New("missing curve")
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+n*2 {
+			return nil, errors.New("truncated curve")
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+i*2:])) / 65535
+		}
+		return func(x float64) float64 {
+			pos := x * float64(n-1)
+			i := int(pos)
+			if i >= n-1 {
+				return table[n-1]
+			}
+			frac := pos - float64(i)
+			return table[i]*(1-frac) + table[i+1]*frac
+		}, nil
+
+	case "para":
+		counts := []int{1, 3, 4, 5, 7}
+		kind := int(binary.BigEndian.Uint16(tag[8:]))
+		if kind >= len(counts) || len(tag) < 12+counts[kind]*4 {
+			return nil, errors.New("unsupported parametric curve")
+		}
+		var v [7]float64
+		for i := 0; i < counts[kind]; i++ {
+			v[i] = s15Fixed16(tag[12+i*4:])
+		}
+		g, a, b, c, d, e, f := v[0], v[1], v[2], v[3], v[4], v[5], v[6]
+		switch kind {
+		case 0:
+			return func(x float64) float64 { return math.Pow(x, g) }, nil
+		case 1:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return math.Pow(a*x+b, g)
+				}
+				return 0
+			}, nil
+		case 2:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return math.Pow(a*x+b, g) + c
+				}
+				return c
+			}, nil
+		case 3:
+			return func(x float64) float64 {
+				if x >= d {
+					return math.Pow(a*x+b, g)
+				}
+				return c * x
+			}, nil
+		default:
+			return func(x float64) float64 {
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported curve type %q", tag[:4])
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
@@ -0,0 +1,162 @@
+package imageconv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadProfile(t *testing.T) {
+	tests := []struct {
+		file        string
+		wantProfile bool
+	}{
+		{"p3.png", true},
+		{"p3.jpg", true},
+		{"adobergb.png", true},
+		{"adobergb.jpg", true},
+		{"untagged.png", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			p, err := ReadProfile(r)
+			if err != nil {
+				t.Fatalf("ReadProfile: %v", err)
+			}
+			if (p != nil) != tt.wantProfile {
+				t.Errorf("profile = %v, want one: %v", p != nil, tt.wantProfile)
+			}
+			// The image must still decode from the same reader
+			if _, _, err := image.Decode(r); err != nil {
+				t.Errorf("decode after ReadProfile: %v", err)
+			}
+		})
+	}
+}
+
+// readTestProfile returns testdata/p3.icc, a Display P3 profile with seven
+// tags.
+func readTestProfile(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "p3.icc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseProfile(data); err != nil {
+		t.Fatalf("testdata/p3.icc: %v", err)
+	}
+	return data
+}
+
+func TestParseProfileMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+		wantErr string
+	}{
+		{"too short", func(d []byte) []byte { return d[:100] }, "too short"},
+		{"truncated tag table", func(d []byte) []byte { return d[:132+12*3+6] }, "ICC tag"},
+		{"no tag table", func(d []byte) []byte { return d[:132] }, "tag table is truncated"},
+		// Reading stops at the end of the data, whatever the count says
+		{"oversized tag count", func(d []byte) []byte {
+			binary.BigEndian.PutUint32(d[128:], 0xFFFFFFFF)
+			return d
+		}, "ICC tag"},
+		{"tag past the end", func(d []byte) []byte {
+			binary.BigEndian.PutUint32(d[132+8:], uint32(len(d)))
+			return d
+		}, "out of range"},
+		{"tag offset overflow", func(d []byte) []byte {
+			binary.BigEndian.PutUint32(d[132+4:], 0xFFFFFFF0)
+			binary.BigEndian.PutUint32(d[132+8:], 0x20)
+			return d
+		}, "out of range"},
+		{"CMYK", func(d []byte) []byte {
+			copy(d[16:], "CMYK")
+			return d
+		}, "unsupported ICC profile"},
+		{"missing curve", func(d []byte) []byte {
+			return bytes.Replace(d, []byte("gTRC"), []byte("xTRC"), 1)
+		}, "gTRC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.corrupt(readTestProfile(t))
+			_, err := ParseProfile(data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadProfileOversized(t *testing.T) {
+	// A JPEG whose APP2 chunks add up to more than maxProfileSize
+	jpeg := []byte{0xFF, 0xD8}
+	chunk := make([]byte, 65000)
+	for i := 0; i*len(chunk) <= maxProfileSize; i++ {
+		jpeg = append(jpeg, 0xFF, 0xE2)
+		jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(2+14+len(chunk)))
+		jpeg = append(jpeg, "ICC_PROFILE\x00"...)
+		jpeg = append(jpeg, byte(i+1), 0)
+		jpeg = append(jpeg, chunk...)
+	}
+	jpeg = append(jpeg, 0xFF, 0xD9)
+	if _, err := ReadProfile(bytes.NewReader(jpeg)); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("JPEG: err = %v, want the size limit", err)
+	}
+
+	// A PNG whose iCCP chunk declares more than maxProfileSize
+	png := []byte("\x89PNG\r\n\x1a\n")
+	png = binary.BigEndian.AppendUint32(png, maxProfileSize+1)
+	png = append(png, "iCCP"...)
+	if _, err := ReadProfile(bytes.NewReader(png)); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("PNG: err = %v, want the size limit", err)
+	}
+}
+
+func TestParseCurve(t *testing.T) {
+	para := []byte("para\x00\x00\x00\x00\x00\x00\x00\x00")
+	para = binary.BigEndian.AppendUint32(para, 2<<16) // gamma 2
+	table := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x03\x00\x00\x40\x00\xff\xff")
+
+	tests := []struct {
+		name string
+		tag  []byte
+		x    float64
+		want float64
+	}{
+		{"identity", []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00"), 0.3, 0.3},
+		{"gamma", []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x00"), 0.5, 0.25},
+		{"table", table, 0.25, 0x2000 / 65535.0},
+		{"parametric", para, 0.5, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCurve(tt.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c(tt.x); got < tt.want-0.001 || got > tt.want+0.001 {
+				t.Errorf("curve(%v) = %v, want %v", tt.x, got, tt.want)
+			}
+		})
+	}
+
+	for _, tag := range [][]byte{nil, []byte("curv\x00\x00\x00\x00\x00\x00\x00\x09\x00"), []byte("mft2\x00\x00\x00\x00\x00\x00\x00\x00")} {
+		if _, err := parseCurve(tag); err == nil {
+			t.Errorf("parseCurve(%q) succeeded", tag)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package imageconv decodes the HEIC and AVIF images Go has no decoder for
+// (photos from iPhones, and what many CDNs serve) through the Windows Imaging
+// Component. Importing it registers both formats with the image package, so
+// image.Decode and image.DecodeConfig read them like JPEG or PNG and the
+// callers convert them to JPEG before Windows sees them.
+//
+// WIC decodes HEIC with the HEIF Image Extensions and AVIF with the AV1 Video
+// Extension from the Microsoft Store. Without them decoding fails with
+// ErrCodecMissing.
+//
+// It also handles color: ReadProfile finds the ICC profile of a wide-gamut
+// JPEG or PNG so its pixels can be converted to sRGB, and EncodeJPEG and
+// EncodePNG tag what is written as sRGB.
+package imageconv
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+)
+
+// Extensions are the file extensions of the formats this package decodes.
+var Extensions = map[string]bool{
+	".heic": true,
+	".heif": true,
+	".avif": true,
+}
+
+// ErrCodecMissing means Windows has no codec installed for the image's format.
+var ErrCodecMissing = errors.New("no Windows codec for this image format; install the HEIF Image Extensions (HEIC) or AV1 Video Extension (AVIF) from the Microsoft Store")
+
+// brands are the ISO base media file brands that follow "ftyp" at offset 4,
+// by the format they are registered as.
+var brands = map[string][]string{
+	"heif": {"heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1"},
+	"avif": {"avif", "avis"},
+}
+
+func init() {
+	for name, list := range brands {
+		for _, brand := range list {
+			image.RegisterFormat(name, "????ftyp"+brand, decode, decodeConfig)
+		}
+	}
+}
+
+// decode reads the whole image, which the callers have already checked
+// against the file size limit, and decodes its first frame.
+func decode(r io.Reader) (image.Image, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	_, img, err := decodeWIC(buf.Bytes(), true)
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// decodeConfig reads the dimensions of the first frame without decoding its
+// pixels.
+func decodeConfig(r io.Reader) (image.Config, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return image.Config{}, err
+	}
+	cfg, _, err := decodeWIC(buf.Bytes(), false)
+	return cfg, err
+}
@@ -0,0 +1,266 @@
+package imageconv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+)
+
+// srgbToXYZ is the sRGB matrix adapted to D50 (Bradford), as in sRGB ICC
+// profiles; xyzToSRGB is its inverse.
+var (
+	srgbToXYZ = [9]float64{
+		0.4360747, 0.3850649, 0.1430804,
+		0.2225045, 0.7168786, 0.0606169,
+		0.0139322, 0.0971045, 0.7141733,
+	}
+	xyzToSRGB = [9]float64{
+		3.1338561, -1.6168667, -0.4906146,
+		-0.9787684, 1.9161415, 0.0334540,
+		0.0719453, -0.2289914, 1.4052427,
+	}
+)
+
+// srgbDecode and srgbEncode are the sRGB transfer function and its inverse.
+func srgbDecode(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func srgbEncode(x float64) float64 {
+	if x <= 0.0031308 {
+		return x * 12.92
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+// IsSRGB reports whether the profile is sRGB in all but name, so converting
+// would change nothing visible.
+func (p *Profile) IsSRGB() bool {
+	for i := range p.toXYZ {
+		if math.Abs(p.toXYZ[i]-srgbToXYZ[i]) > 0.005 {
+			return false
+		}
+	}
+	for _, c := range p.trc {
+		for _, x := range []float64{0.1, 0.25, 0.5, 0.75} {
+			if math.Abs(c(x)-srgbDecode(x)) > 0.005 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// linearSteps is the size of the table that encodes linear light back to 8-bit
+// sRGB; finer than 8 bits so dark tones don't band.
+const linearSteps = 4096
+
+var (
+	encodeTable     [linearSteps + 1]uint8
+	encodeTableOnce sync.Once
+)
+
+// ConvertToSRGB converts img in place from the profile's colors to sRGB.
+// Colors outside sRGB are clipped.
+func (p *Profile) ConvertToSRGB(img *image.RGBA) {
+	encodeTableOnce.Do(func() {
+		for i := range encodeTable {
+			encodeTable[i] = uint8(math.Round(srgbEncode(float64(i)/linearSteps) * 255))
+		}
+	})
+
+	var decode [3][256]float64
+	for c := range decode {
+		for v := range decode[c] {
+			decode[c][v] = p.trc[c](float64(v) / 255)
+		}
+	}
+	var m [9]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			for k := 0; k < 3; k++ {
+				m[row*3+col] += xyzToSRGB[row*3+k] * p.toXYZ[k*3+col]
+			}
+		}
+	}
+	encode := func(x float64) uint8 {
+		switch {
+		case x <= 0:
+			return 0
+		case x >= 1:
+			return 255
+		}
+		return encodeTable[int(x*linearSteps+0.5)]
+	}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		for i := 0; i+3 < len(row); i += 4 {
+			a := row[i+3]
+			if a == 0 {
+				continue
+			}
+			r, g, bl := row[i], row[i+1], row[i+2]
+			if a != 255 {
+				// Pixels are premultiplied; convert the color itself
+				r, g, bl = uint8(uint16(r)*255/uint16(a)), uint8(uint16(g)*255/uint16(a)), uint8(uint16(bl)*255/uint16(a))
+			}
+			lr, lg, lb := decode[0][r], decode[1][g], decode[2][bl]
+			r = encode(m[0]*lr + m[1]*lg + m[2]*lb)
+			g = encode(m[3]*lr + m[4]*lg + m[5]*lb)
+			bl = encode(m[6]*lr + m[7]*lg + m[8]*lb)
+			if a != 255 {
+				r, g, bl = uint8(uint16(r)*uint16(a)/255), uint8(uint16(g)*uint16(a)/255), uint8(uint16(bl)*uint16(a)/255)
+			}
+			row[i], row[i+1], row[i+2] = r, g, bl
+		}
+	}
+}
+
+// ToSRGB returns img converted to sRGB, or img itself when p is nil.
+func (p *Profile) ToSRGB(img image.Image) image.Image {
+	if p == nil {
+		return img
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	p.ConvertToSRGB(rgba)
+	return rgba
+}
+
+// EncodeJPEG encodes img as JPEG tagged with an sRGB profile, so color-managed
+// viewers and wide-gamut displays don't stretch its colors.
+func EncodeJPEG(w io.Writer, img image.Image, o *jpeg.Options) error {
+	// The profile goes in an APP2 segment right after the start of image
+	profile := srgbProfile()
+	segment := make([]byte, 0, 4+14+len(profile))
+	segment = append(segment, 0xFF, 0xE2)
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+14+len(profile)))
+	segment = append(segment, "ICC_PROFILE\x00"...)
+	segment = append(segment, 1, 1)
+	segment = append(segment, profile...)
+	return jpeg.Encode(&insertWriter{w: w, at: 2, insert: segment}, img, o)
+}
+
+// EncodePNG encodes img as PNG with an sRGB chunk.
+func EncodePNG(w io.Writer, img image.Image) error {
+	// The chunk goes after the signature and IHDR
+	chunk := []byte{0, 0, 0, 1, 's', 'R', 'G', 'B', 0}
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	return png.Encode(&insertWriter{w: w, at: 8 + 25, insert: chunk}, img)
+}
+
+// insertWriter writes insert into the stream after the first at bytes.
+type insertWriter struct {
+	w       io.Writer
+	at      int
+	insert  []byte
+	written int
+}
+
+func (iw *insertWriter) Write(p []byte) (int, error) {
+	n := 0
+	if iw.written < iw.at && iw.written+len(p) >= iw.at {
+		head := iw.at - iw.written
+		m, err := iw.w.Write(p[:head])
+		n += m
+		iw.written += m
+		if err != nil {
+			return n, err
+		}
+		if _, err := iw.w.Write(iw.insert); err != nil {
+			return n, err
+		}
+		p = p[head:]
+	}
+	m, err := iw.w.Write(p)
+	iw.written += m
+	return n + m, err
+}
+
+var (
+	srgbProfileData []byte
+	srgbProfileOnce sync.Once
+)
+
+// srgbProfile builds a minimal ICC v2 sRGB display profile.
+func srgbProfile() []byte {
+	srgbProfileOnce.Do(func() {
+		xyz := func(x, y, z float64) []byte {
+			tag := []byte("XYZ \x00\x00\x00\x00")
+			for _, v := range []float64{x, y, z} {
+				tag = binary.BigEndian.AppendUint32(tag, uint32(int32(math.Round(v*65536))))
+			}
+			return tag
+		}
+		trc := []byte("curv\x00\x00\x00\x00")
+		const points = 1024
+		trc = binary.BigEndian.AppendUint32(trc, points)
+		for i := 0; i < points; i++ {
+			trc = binary.BigEndian.AppendUint16(trc, uint16(math.Round(srgbDecode(float64(i)/(points-1))*65535)))
+		}
+		desc := []byte("desc\x00\x00\x00\x00")
+		desc = binary.BigEndian.AppendUint32(desc, uint32(len("sRGB")+1))
+		desc = append(desc, "sRGB\x00"...)
+		// Empty Unicode and ScriptCode descriptions
+		desc = append(desc, make([]byte, 4+4+2+1+67)...)
+		cprt := []byte("text\x00\x00\x00\x00No copyright, use freely\x00")
+
+		tags := []struct {
+			sig  string
+			data []byte
+		}{
+			{"desc", desc},
+			{"cprt", cprt},
+			{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+			{"rXYZ", xyz(srgbToXYZ[0], srgbToXYZ[3], srgbToXYZ[6])},
+			{"gXYZ", xyz(srgbToXYZ[1], srgbToXYZ[4], srgbToXYZ[7])},
+			{"bXYZ", xyz(srgbToXYZ[2], srgbToXYZ[5], srgbToXYZ[8])},
+			{"rTRC", trc},
+			{"gTRC", nil},
+			{"bTRC", nil},
+		}
+
+		var table, body bytes.Buffer
+		binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+		offset := 128 + 4 + 12*len(tags)
+		var trcOffset, trcSize int
+		for _, tag := range tags {
+			// The three channels share one curve
+			if tag.data == nil {
+				binary.Write(&table, binary.BigEndian, [3]uint32{binary.BigEndian.Uint32([]byte(tag.sig)), uint32(trcOffset), uint32(trcSize)})
+				continue
+			}
+			if tag.sig == "rTRC" {
+				trcOffset, trcSize = offset+body.Len(), len(tag.data)
+			}
+			binary.Write(&table, binary.BigEndian, [3]uint32{binary.BigEndian.Uint32([]byte(tag.sig)), uint32(offset + body.Len()), uint32(len(tag.data))})
+			body.Write(tag.data)
+			for body.Len()%4 != 0 {
+				body.WriteByte(0)
+			}
+		}
+
+		header := make([]byte, 128)
+		binary.BigEndian.PutUint32(header[0:], uint32(128+table.Len()+body.Len()))
+		binary.BigEndian.PutUint32(header[8:], 0x02100000)
+		copy(header[12:], "mntrRGB XYZ ")
+		copy(header[36:], "acspMSFT")
+		// Rendering intent perceptual, D50 illuminant
+		copy(header[68:], xyz(0.9642, 1.0, 0.8249)[8:])
+
+		srgbProfileData = append(append(header, table.Bytes()...), body.Bytes()...)
+	})
+	return srgbProfileData
+}
@@ -0,0 +1,129 @@
+package imageconv
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertToSRGB(t *testing.T) {
+	// Each swatch is white, mid gray, then sRGB red and green expressed in
+	// the file's color space, rounded to 8 bits; the rounding is amplified
+	// near black, where the sRGB curve is steepest
+	want := []color.RGBA{{255, 255, 255, 255}, {128, 128, 128, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}}
+	const tolerance = 5
+
+	for _, file := range []string{"p3.png", "adobergb.png"} {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			p, err := ReadProfile(r)
+			if err != nil || p == nil {
+				t.Fatalf("ReadProfile = %v, %v", p, err)
+			}
+			img, _, err := image.Decode(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			converted := p.ToSRGB(img)
+			for i, w := range want {
+				got := color.RGBAModel.Convert(converted.At(i, 0)).(color.RGBA)
+				if !near(got.R, w.R, tolerance) || !near(got.G, w.G, tolerance) || !near(got.B, w.B, tolerance) {
+					t.Errorf("pixel %d = %v, want %v", i, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertToSRGBPremultiplied(t *testing.T) {
+	p := readProfile(t, "p3.png")
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{})
+	// Half-transparent sRGB red in P3, premultiplied
+	img.Set(1, 0, color.NRGBA{234, 51, 35, 128})
+	p.ConvertToSRGB(img)
+
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{}) {
+		t.Errorf("transparent pixel changed to %v", got)
+	}
+	got := color.NRGBAModel.Convert(img.At(1, 0)).(color.NRGBA)
+	if !near(got.R, 255, 4) || !near(got.G, 0, 4) || !near(got.B, 0, 4) || got.A != 128 {
+		t.Errorf("half-transparent pixel = %v, want red at alpha 128", got)
+	}
+}
+
+func TestIsSRGB(t *testing.T) {
+	srgb, err := ParseProfile(srgbProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srgb.IsSRGB() {
+		t.Error("the built-in sRGB profile is not sRGB")
+	}
+	for _, file := range []string{"p3.png", "adobergb.png"} {
+		if readProfile(t, file).IsSRGB() {
+			t.Errorf("%s is taken for sRGB", file)
+		}
+	}
+}
+
+func TestEncodeTagsSRGB(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{200, 100, 50, 255}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name   string
+		encode func(*bytes.Buffer) error
+		tag    string
+	}{
+		{"JPEG", func(b *bytes.Buffer) error { return EncodeJPEG(b, img, nil) }, "ICC_PROFILE\x00"},
+		{"PNG", func(b *bytes.Buffer) error { return EncodePNG(b, img) }, "sRGB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.encode(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(buf.Bytes(), []byte(tt.tag)) {
+				t.Errorf("no %q tag", tt.tag)
+			}
+			r := bytes.NewReader(buf.Bytes())
+			// Tagged sRGB reads back as no profile to convert
+			if p, err := ReadProfile(r); p != nil || err != nil {
+				t.Errorf("ReadProfile = %v, %v, want nil, nil", p, err)
+			}
+			if _, _, err := image.Decode(r); err != nil {
+				t.Errorf("tagged image does not decode: %v", err)
+			}
+		})
+	}
+}
+
+// readProfile returns the profile of a file in testdata.
+func readProfile(t *testing.T, file string) *Profile {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadProfile(bytes.NewReader(data))
+	if err != nil || p == nil {
+		t.Fatalf("ReadProfile(%s) = %v, %v", file, p, err)
+	}
+	return p
+}
+
+func near(a, b uint8, tolerance int) bool {
+	d := int(a) - int(b)
+	return d >= -tolerance && d <= tolerance
+}
@@ -0,0 +1,153 @@
+package imageconv
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	clsidWICImagingFactory      = windows.GUID{Data1: 0xCACAF262, Data2: 0x9370, Data3: 0x4615, Data4: [8]byte{0xA1, 0x3B, 0x9F, 0x55, 0x39, 0xDA, 0x4C, 0x0A}}
+	iidWICImagingFactory        = windows.GUID{Data1: 0xEC5EC8A9, Data2: 0xC395, Data3: 0x4314, Data4: [8]byte{0x9C, 0x77, 0x54, 0xD7, 0xA9, 0x35, 0xFF, 0x70}}
+	guidWICPixelFormat32bppRGBA = windows.GUID{Data1: 0xF5C7AD2D, Data2: 0x6A8D, Data3: 0x43DD, Data4: [8]byte{0xA7, 0xA8, 0xA2, 0x99, 0x35, 0x26, 0x1A, 0xE9}}
+
+	procCoCreateInstance       = windows.NewLazySystemDLL("ole32.dll").NewProc("CoCreateInstance")
+	procWICConvertBitmapSource = windows.NewLazySystemDLL("windowscodecs.dll").NewProc("WICConvertBitmapSource")
+)
+
+// Vtable slots, counting the three IUnknown methods.
+const (
+	slotRelease = 2
+
+	// IWICImagingFactory
+	slotCreateDecoderFromStream = 4
+	slotCreateStream            = 14
+
+	// IWICStream, after the IStream methods
+	slotInitializeFromMemory = 16
+
+	// IWICBitmapDecoder
+	slotGetFrame = 13
+
+	// IWICBitmapSource, which frames implement
+	slotGetSize    = 3
+	slotCopyPixels = 7
+)
+
+const (
+	clsctxInprocServer             = 0x1
+	wicDecodeMetadataCacheOnDemand = 0
+	// wincodecErrComponentNotFound is returned when no decoder takes the data.
+	wincodecErrComponentNotFound = 0x88982F50
+)
+
+// comObject is any COM interface pointer.
+type comObject struct {
+	vtbl *[18]uintptr
+}
+
+// call invokes a method and returns its HRESULT as an error on failure.
+func (o *comObject) call(slot int, args ...uintptr) error {
+	hr, _, _ := syscall.SyscallN(o.vtbl[slot], append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)...)
+	if int32(hr) < 0 {
+		return hresultError(hr)
+	}
+	return nil
+}
+
+func (o *comObject) release() {
+	syscall.SyscallN(o.vtbl[slotRelease], uintptr(unsafe.Pointer(o)))
+}
+
+func hresultError(hr uintptr) error {
+	if uint32(hr) == wincodecErrComponentNotFound {
+		return ErrCodecMissing
+	}
+	return fmt.Errorf("HRESULT 0x%08X", uint32(hr))
+}
+
+// decodeWIC decodes the first frame of an image in memory with WIC. It only
+// reads the dimensions unless pixels is set.
+func decodeWIC(data []byte, pixels bool) (image.Config, image.Image, error) {
+	if len(data) == 0 {
+		return image.Config{}, nil, fmt.Errorf("empty image")
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := windows.CoInitializeEx(0, windows.COINIT_APARTMENTTHREADED); err == nil {
+		defer windows.CoUninitialize()
+	}
+
+	var factory *comObject
+	hr, _, _ := procCoCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidWICImagingFactory)),
+		0,
+		clsctxInprocServer,
+		uintptr(unsafe.Pointer(&iidWICImagingFactory)),
+		uintptr(unsafe.Pointer(&factory)),
+	)
+	if int32(hr) < 0 {
+		return image.Config{}, nil, fmt.Errorf("WIC is not available: %w", hresultError(hr))
+	}
+	defer factory.release()
+
+	var stream *comObject
+	if err := factory.call(slotCreateStream, uintptr(unsafe.Pointer(&stream))); err != nil {
+		return image.Config{}, nil, fmt.Errorf("failed to create WIC stream: %w", err)
+	}
+	defer stream.release()
+	if err := stream.call(slotInitializeFromMemory, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data))); err != nil {
+		return image.Config{}, nil, fmt.Errorf("failed to create WIC stream: %w", err)
+	}
+	// The stream reads data in place until it is released
+	defer runtime.KeepAlive(data)
+
+	var decoder *comObject
+	if err := factory.call(slotCreateDecoderFromStream, uintptr(unsafe.Pointer(stream)), 0, wicDecodeMetadataCacheOnDemand, uintptr(unsafe.Pointer(&decoder))); err != nil {
+		return image.Config{}, nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	defer decoder.release()
+
+	var frame *comObject
+	if err := decoder.call(slotGetFrame, 0, uintptr(unsafe.Pointer(&frame))); err != nil {
+		return image.Config{}, nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	defer frame.release()
+
+	var width, height uint32
+	if err := frame.call(slotGetSize, uintptr(unsafe.Pointer(&width)), uintptr(unsafe.Pointer(&height))); err != nil {
+		return image.Config{}, nil, fmt.Errorf("failed to read image size: %w", err)
+	}
+	cfg := image.Config{ColorModel: color.NRGBAModel, Width: int(width), Height: int(height)}
+	if !pixels {
+		return cfg, nil, nil
+	}
+
+	// Let WIC convert whatever the codec produces (often 10-bit YUV) to 8-bit RGBA
+	var converted *comObject
+	hr, _, _ = procWICConvertBitmapSource.Call(
+		uintptr(unsafe.Pointer(&guidWICPixelFormat32bppRGBA)),
+		uintptr(unsafe.Pointer(frame)),
+		uintptr(unsafe.Pointer(&converted)),
+	)
+	if int32(hr) < 0 {
+		return image.Config{}, nil, fmt.Errorf("failed to convert image pixels: %w", hresultError(hr))
+	}
+	defer converted.release()
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	if len(img.Pix) == 0 {
+		return image.Config{}, nil, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+	if err := converted.call(slotCopyPixels, 0, uintptr(img.Stride), uintptr(len(img.Pix)), uintptr(unsafe.Pointer(&img.Pix[0]))); err != nil {
+		return image.Config{}, nil, fmt.Errorf("failed to copy image pixels: %w", err)
+	}
+	return cfg, img, nil
+}
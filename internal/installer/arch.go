@@ -0,0 +1,64 @@
+package installer
+
+import (
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// Machine types reported by IsWow64Process2.
+const (
+	imageFileMachineI386  = 0x014c
+	imageFileMachineAMD64 = 0x8664
+	imageFileMachineARM64 = 0xaa64
+)
+
+// NativeArch returns the machine's native architecture as a GOARCH name
+// ("amd64", "arm64" or "386"). Unlike runtime.GOARCH it sees through emulation,
+// so an x64 build running on an ARM64 laptop reports "arm64".
+func NativeArch() string {
+	var processMachine, nativeMachine uint16
+	err := windows.IsWow64Process2(windows.CurrentProcess(), &processMachine, &nativeMachine)
+	if err != nil {
+		// IsWow64Process2 needs Windows 10 1709; older systems have no ARM64 emulation
+		return runtime.GOARCH
+	}
+
+	switch nativeMachine {
+	case imageFileMachineARM64:
+		return "arm64"
+	case imageFileMachineAMD64:
+		return "amd64"
+	case imageFileMachineI386:
+		return "386"
+	}
+	return runtime.GOARCH
+}
+
+// ArchAssetName returns the file name of an executable built for arch. The x64
+// build keeps the plain name ("bgchanger.exe"); other architectures get a suffix
+// ("bgchanger_arm64.exe").
+func ArchAssetName(name, arch string) string {
+	if arch == "amd64" || arch == "386" {
+		return name
+	}
+	ext := ".exe"
+	if !strings.HasSuffix(strings.ToLower(name), ext) {
+		return name + "_" + arch
+	}
+	return name[:len(name)-len(ext)] + "_" + arch + ext
+}
+
+// ReleaseAssetName returns the asset of a release to install on this machine:
+// the native build when the release has one, otherwise the x64 build (which
+// ARM64 Windows runs under emulation).
+func ReleaseAssetName(release *GitHubRelease, name string) string {
+	native := ArchAssetName(name, NativeArch())
+	if native != name {
+		if _, err := FindAsset(release, native); err == nil {
+			return native
+		}
+	}
+	return name
+}
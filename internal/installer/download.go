@@ -10,6 +10,9 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/signing"
 )
 
 // Default timeouts for network operations
@@ -99,14 +102,10 @@ func GetLatestReleaseWithContext(ctx context.Context) (*GitHubRelease, error) {
 	return &release, nil
 }
 
-// FindServiceAsset finds the bgStatusService.exe asset in a release
+// FindServiceAsset finds the bgStatusService.exe asset for this machine's
+// architecture in a release
 func FindServiceAsset(release *GitHubRelease) (*GitHubAsset, error) {
-	for _, asset := range release.Assets {
-		if strings.EqualFold(asset.Name, ServiceExeName) {
-			return &asset, nil
-		}
-	}
-	return nil, fmt.Errorf("could not find %s in release %s", ServiceExeName, release.TagName)
+	return FindAsset(release, ReleaseAssetName(release, ServiceExeName))
 }
 
 // DownloadProgress is a callback function for download progress updates
@@ -226,16 +225,22 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 // DownloadLatestService downloads the latest bgStatusService.exe to a temporary location
 // and returns the path to the downloaded file along with version info
 func DownloadLatestService() (filePath string, version string, err error) {
-	// Get latest release info
+	// Get latest release info; if GitHub is unreachable, fall back to mirrors only
+	version = LatestVersion
+	var asset *GitHubAsset
 	release, err := GetLatestRelease()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get release info: %w", err)
-	}
+		if len(loadMirrors()) == 0 {
+			return "", "", fmt.Errorf("failed to get release info: %w", err)
+		}
+	} else {
+		version = release.TagName
 
-	// Find the service executable asset
-	asset, err := FindServiceAsset(release)
-	if err != nil {
-		return "", "", err
+		// Find the service executable asset
+		asset, err = FindServiceAsset(release)
+		if err != nil && len(loadMirrors()) == 0 {
+			return "", "", err
+		}
 	}
 
 	// Download to temp directory
@@ -243,12 +248,30 @@ func DownloadLatestService() (filePath string, version string, err error) {
 	destPath := filepath.Join(tempDir, ServiceExeName)
 
 	// Show a simple progress message (we can't do a real progress bar with MessageBox)
-	err = DownloadFile(asset.BrowserDownloadURL, destPath, nil)
+	sourceURL, err := DownloadWithFallback(downloadSources(asset, version), destPath, nil)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to download: %w", err)
 	}
+	if err := verifySignedDownload(destPath, release, asset); err != nil {
+		os.Remove(destPath)
+		return "", "", err
+	}
+	_ = RecordDownloadSource(sourceURL, version, asset)
+
+	return destPath, version, nil
+}
 
-	return destPath, release.TagName, nil
+// verifySignedDownload checks a downloaded service against the release's signed
+// checksum when this build pins a signing key. Without the GitHub release
+// (mirrors only) there is no checksum to trust, so the download is refused.
+func verifySignedDownload(path string, release *GitHubRelease, asset *GitHubAsset) error {
+	if !signing.Pinned() {
+		return nil
+	}
+	if release == nil || asset == nil {
+		return fmt.Errorf("signed release information from GitHub is required to verify %s", ServiceExeName)
+	}
+	return VerifyDownload(path, release, asset.Name)
 }
 
 // DownloadStatusCallback is called with status updates during download
@@ -259,8 +282,15 @@ func DownloadLatestServiceWithProgress(statusCallback DownloadStatusCallback) (f
 	// Get latest release info
 	statusCallback("Connecting to GitHub...\nFetching release information", 30)
 	
+	mirrors := loadMirrors()
+	version = LatestVersion
+	var asset *GitHubAsset
+
 	release, err := GetLatestRelease()
-	if err != nil {
+	if err != nil && len(mirrors) > 0 {
+		// GitHub is unreachable or blocked - continue with the configured mirrors
+		statusCallback("GitHub unavailable, trying download mirrors...", 35)
+	} else if err != nil {
 		// Provide more helpful error messages
 		errStr := err.Error()
 		if strings.Contains(errStr, "timeout") || strings.Contains(errStr, "timed out") {
@@ -276,12 +306,18 @@ func DownloadLatestServiceWithProgress(statusCallback DownloadStatusCallback) (f
 			return "", "", fmt.Errorf("GitHub rate limit exceeded\n\nPlease wait a few minutes and try again")
 		}
 		return "", "", fmt.Errorf("failed to get release info:\n%w", err)
-	}
+	} else {
+		version = release.TagName
 
-	// Find the service executable asset
-	asset, err := FindServiceAsset(release)
-	if err != nil {
-		return "", "", err
+		// Find the service executable asset
+		asset, err = FindServiceAsset(release)
+		if err != nil && len(mirrors) == 0 {
+			return "", "", err
+		}
+	}
+	sources := downloadSources(asset, version)
+	if len(sources) == 0 {
+		return "", "", fmt.Errorf("no download sources available\n\nCheck download_mirrors in %s", config.Path())
 	}
 
 	// Download to temp directory
@@ -289,7 +325,7 @@ func DownloadLatestServiceWithProgress(statusCallback DownloadStatusCallback) (f
 	destPath := filepath.Join(tempDir, ServiceExeName)
 
 	// Format the download URL for display (shorten it)
-	shortURL := asset.BrowserDownloadURL
+	shortURL := sources[0]
 	if len(shortURL) > 60 {
 		shortURL = shortURL[:57] + "..."
 	}
@@ -339,7 +375,7 @@ func DownloadLatestServiceWithProgress(statusCallback DownloadStatusCallback) (f
 
 	statusCallback(fmt.Sprintf("Downloading from:\n%s", shortURL), 40)
 	
-	err = DownloadFile(asset.BrowserDownloadURL, destPath, progressCallback)
+	sourceURL, err := DownloadWithFallback(sources, destPath, progressCallback)
 	if err != nil {
 		// Provide more helpful error messages
 		errStr := err.Error()
@@ -351,9 +387,15 @@ func DownloadLatestServiceWithProgress(statusCallback DownloadStatusCallback) (f
 		}
 		return "", "", fmt.Errorf("download failed:\n%w", err)
 	}
+	if err := verifySignedDownload(destPath, release, asset); err != nil {
+		os.Remove(destPath)
+		return "", "", fmt.Errorf("download failed verification:\n%w", err)
+	}
+
+	_ = RecordDownloadSource(sourceURL, version, asset)
 
 	statusCallback("Download complete, verifying...", 65)
-	return destPath, release.TagName, nil
+	return destPath, version, nil
 }
 
 
@@ -0,0 +1,30 @@
+package installer
+
+import (
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/eventlog"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// eventSourceKey registers the service as a source of the Application event log.
+const eventSourceKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + ServiceName
+
+// installEventSource registers the event log source with the messages of
+// EventCreate.exe, as eventlog.InstallAsEventCreate does, replacing an earlier
+// registration.
+func installEventSource() error {
+	key, _, err := winapi.Reg.CreateKey(registry.LOCAL_MACHINE, eventSourceKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	if err := key.SetDWordValue("CustomSource", 1); err != nil {
+		return err
+	}
+	if err := key.SetExpandStringValue("EventMessageFile", `%SystemRoot%\System32\EventCreate.exe`); err != nil {
+		return err
+	}
+	return key.SetDWordValue("TypesSupported", eventlog.Error|eventlog.Warning|eventlog.Info)
+}
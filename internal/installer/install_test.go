@@ -0,0 +1,126 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/winapi"
+	"github.com/backgroundchanger/internal/winapi/winapitest"
+)
+
+// useFakes runs the installer on the fake elevated Enterprise machine, with
+// an in-memory registry and task scheduler.
+func useFakes(t *testing.T) (*winapi.FakeRegistry, *winapi.FakeTasks) {
+	t.Helper()
+
+	fakes := winapitest.Use(t)
+	return fakes.Reg, fakes.Tasks
+}
+
+// fakeExe writes a stand-in for the installer's executable.
+func fakeExe(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "BgStatusService.exe")
+	if err := os.WriteFile(path, []byte("MZ"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstallUninstall(t *testing.T) {
+	reg, tasks := useFakes(t)
+
+	if ScheduledTaskExists() {
+		t.Fatal("ScheduledTaskExists() before install = true")
+	}
+	if err := InstallScheduledTasks(fakeExe(t)); err != nil {
+		t.Fatalf("InstallScheduledTasks: %v", err)
+	}
+
+	for _, name := range requiredTasks {
+		if _, ok := tasks.Tasks[name]; !ok {
+			t.Errorf("task %s not created", name)
+		}
+		if state := TaskState(name); state == TaskMissing {
+			t.Errorf("TaskState(%s) = %s", name, state)
+		}
+	}
+	for _, name := range []string{ScheduledTaskNameDisplay, ScheduledTaskNameDashboard, ScheduledTaskNameSNMP, ScheduledTaskNameMOTD} {
+		if _, ok := tasks.Tasks[name]; ok {
+			t.Errorf("task %s created without being configured", name)
+		}
+	}
+	if !ScheduledTaskExists() {
+		t.Error("ScheduledTaskExists() after install = false")
+	}
+	if _, err := os.Stat(GetInstalledExePath()); err != nil {
+		t.Errorf("installed executable: %v", err)
+	}
+	if v, ok := reg.Value(registry.LOCAL_MACHINE, eventSourceKey, "EventMessageFile"); !ok || v.Type != registry.EXPAND_SZ {
+		t.Errorf("event source EventMessageFile = %+v, %v", v, ok)
+	}
+
+	DeleteScheduledTasks()
+	if err := RemoveInstallation(); err != nil {
+		t.Fatalf("RemoveInstallation: %v", err)
+	}
+	if err := RemoveDataDirectory(); err != nil {
+		t.Fatalf("RemoveDataDirectory: %v", err)
+	}
+	if err := RemoveEventLogSource(); err != nil {
+		t.Fatalf("RemoveEventLogSource: %v", err)
+	}
+
+	if len(tasks.Tasks) != 0 {
+		t.Errorf("tasks left after uninstall: %v", tasks.Tasks)
+	}
+	if ScheduledTaskExists() {
+		t.Error("ScheduledTaskExists() after uninstall = true")
+	}
+	for _, dir := range []string{GetInstallDir(), GetDataDir()} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s left after uninstall: %v", dir, err)
+		}
+	}
+	if reg.KeyExists(registry.LOCAL_MACHINE, eventSourceKey) {
+		t.Error("event source key left after uninstall")
+	}
+}
+
+func TestInstallStartsServers(t *testing.T) {
+	_, tasks := useFakes(t)
+
+	cfg := config.Default()
+	cfg.Dashboard.Enabled = true
+	cfg.SNMP.Enabled = true
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InstallScheduledTasks(fakeExe(t)); err != nil {
+		t.Fatalf("InstallScheduledTasks: %v", err)
+	}
+	want := []string{ScheduledTaskNameDashboard, ScheduledTaskNameSNMP}
+	if len(tasks.Runs) != len(want) || tasks.Runs[0] != want[0] || tasks.Runs[1] != want[1] {
+		t.Errorf("tasks started = %v, want %v", tasks.Runs, want)
+	}
+
+	DeleteScheduledTasks()
+	if len(tasks.Tasks) != 0 {
+		t.Errorf("tasks left after DeleteScheduledTasks: %v", tasks.Tasks)
+	}
+}
+
+func TestInstallTaskError(t *testing.T) {
+	_, tasks := useFakes(t)
+	tasks.Err = os.ErrPermission
+
+	if err := InstallScheduledTasks(fakeExe(t)); err == nil {
+		t.Fatal("InstallScheduledTasks succeeded although the task could not be created")
+	}
+}
@@ -0,0 +1,122 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// DownloadSourceFileName is the file in the data directory that records which
+// download source last succeeded.
+const DownloadSourceFileName = "download_source.json"
+
+// LatestVersion is substituted for {version} in mirror URLs when the GitHub API
+// could not be reached to find the real release tag.
+const LatestVersion = "latest"
+
+// DownloadSource records where a successful download came from.
+type DownloadSource struct {
+	URL     string    `json:"url"`
+	Version string    `json:"version"`
+	Mirror  bool      `json:"mirror"`
+	Time    time.Time `json:"time"`
+}
+
+// loadMirrors returns the configured download mirrors, ignoring config errors
+// so a broken config file never blocks the GitHub download.
+func loadMirrors() []string {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	return cfg.DownloadMirrors
+}
+
+// MirrorURLs expands mirror URL templates for the given version and file name.
+// Templates without a {file} placeholder get the file name appended as a path segment.
+func MirrorURLs(mirrors []string, version, file string) []string {
+	var urls []string
+	for _, mirror := range mirrors {
+		mirror = strings.TrimSpace(mirror)
+		if mirror == "" {
+			continue
+		}
+		if !strings.Contains(mirror, "{file}") {
+			mirror = strings.TrimSuffix(mirror, "/") + "/{file}"
+		}
+		url := strings.ReplaceAll(mirror, "{version}", version)
+		url = strings.ReplaceAll(url, "{file}", file)
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// DownloadWithFallback tries each URL in order until one succeeds and returns
+// the URL that worked. The error from every failed attempt is included if all fail.
+func DownloadWithFallback(urls []string, destPath string, progress DownloadProgress) (string, error) {
+	if len(urls) == 0 {
+		return "", fmt.Errorf("no download sources available")
+	}
+
+	var failures []string
+	for _, url := range urls {
+		err := DownloadFile(url, destPath, progress)
+		if err == nil {
+			return url, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", url, err))
+	}
+
+	return "", fmt.Errorf("all download sources failed:\n%s", strings.Join(failures, "\n"))
+}
+
+// downloadSources returns the GitHub asset URL (if known) followed by the configured mirrors.
+func downloadSources(asset *GitHubAsset, version string) []string {
+	var urls []string
+	if asset != nil && asset.BrowserDownloadURL != "" {
+		urls = append(urls, asset.BrowserDownloadURL)
+	}
+	return append(urls, MirrorURLs(loadMirrors(), version, ServiceExeName)...)
+}
+
+// RecordDownloadSource saves which source a download succeeded from so admins
+// can see whether machines are falling back to mirrors.
+func RecordDownloadSource(url, version string, asset *GitHubAsset) error {
+	source := DownloadSource{
+		URL:     url,
+		Version: version,
+		Mirror:  asset == nil || url != asset.BrowserDownloadURL,
+		Time:    time.Now(),
+	}
+
+	data, err := json.MarshalIndent(source, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode download source: %w", err)
+	}
+
+	dataDir := GetDataDir()
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(dataDir, DownloadSourceFileName), data, 0644)
+}
+
+// LastDownloadSource returns the most recently recorded download source.
+func LastDownloadSource() (*DownloadSource, error) {
+	data, err := os.ReadFile(filepath.Join(GetDataDir(), DownloadSourceFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var source DownloadSource
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, fmt.Errorf("failed to parse download source: %w", err)
+	}
+	return &source, nil
+}
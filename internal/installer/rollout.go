@@ -0,0 +1,154 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/signing"
+)
+
+// RolloutAssetName is the optional release asset that stages a release across
+// the fleet. Releases without it are taken by every machine at once.
+const RolloutAssetName = "rollout.json"
+
+// RolloutSequenceFileName is the file in the data directory that records the
+// highest signature sequence number accepted for each release's rollout.json.
+const RolloutSequenceFileName = "rollout_sequence.json"
+
+// Rollout is the content of a release's rollout.json, e.g. {"percent": 25}.
+// The asset is edited as the rollout widens (GitHub allows replacing it), so
+// every machine reads it on each update check.
+type Rollout struct {
+	// Percent is how far the rollout has reached, 0-100: machines at a
+	// rollout position up to it take the release. 0 opens it to pilot
+	// machines only.
+	Percent int `json:"percent"`
+	// Paused holds the release back from every machine, e.g. after a bad
+	// report from the pilot ring.
+	Paused bool `json:"paused,omitempty"`
+}
+
+// Open reports whether a machine at the rollout position may take the release.
+func (r *Rollout) Open(position int) bool {
+	return !r.Paused && position <= r.Percent
+}
+
+// RolloutPosition returns the machine's place in staged rollouts: 0 for the
+// pilot ring, 100 for the last ring, and otherwise 1-100 hashed from the
+// machine name, so the same machines always go first.
+func RolloutPosition(hostname, ring string) int {
+	switch ring {
+	case config.UpdateRingPilot:
+		return 0
+	case config.UpdateRingLast:
+		return 100
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToUpper(hostname)))
+	return int(h.Sum32()%100) + 1
+}
+
+// GetRollout reads the rollout of a release, or nil when it is not staged. It
+// tries the GitHub asset, then the configured mirrors; when the release has
+// the asset but none of them can be read, the error holds the update back.
+// Builds with a pinned signing key only accept a rollout.json with a valid,
+// unexpired rollout.json.sig for this release from the same source, whose
+// sequence number is not below the highest one accepted before, so an older
+// signed rollout (e.g. from before a pause) cannot be replayed.
+func GetRollout(release *GitHubRelease) (*Rollout, error) {
+	asset, err := FindAsset(release, RolloutAssetName)
+	if err != nil {
+		return nil, nil
+	}
+
+	sigName := RolloutAssetName + signing.SignatureSuffix
+	urls := append([]string{asset.BrowserDownloadURL}, MirrorURLs(loadMirrors(), release.TagName, RolloutAssetName)...)
+	sigURLs := []string{""}
+	if sigAsset, err := FindAsset(release, sigName); err == nil {
+		sigURLs[0] = sigAsset.BrowserDownloadURL
+	}
+	sigURLs = append(sigURLs, MirrorURLs(loadMirrors(), release.TagName, sigName)...)
+
+	var failures []string
+	for i, url := range urls {
+		rollout, err := fetchRollout(url, sigURLs[i], release.TagName)
+		if err == nil {
+			return rollout, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", url, err))
+	}
+	return nil, fmt.Errorf("failed to read %s:\n%s", RolloutAssetName, strings.Join(failures, "\n"))
+}
+
+// fetchRollout downloads, verifies and parses the rollout.json of the release tag.
+func fetchRollout(url, sigURL, tag string) (*Rollout, error) {
+	data, err := fetchSmallFile(url)
+	if err != nil {
+		return nil, err
+	}
+	var sequence int64
+	if signing.Pinned() {
+		if sigURL == "" {
+			return nil, fmt.Errorf("not signed")
+		}
+		sig, err := fetchSmallFile(sigURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the signature: %w", err)
+		}
+		manifest, err := signing.Verify(data, sig, RolloutAssetName, tag, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		sequence = manifest.Sequence
+		if sequence <= 0 {
+			return nil, fmt.Errorf("signature has no sequence number")
+		}
+		if last := loadRolloutSequences()[tag]; sequence < last {
+			return nil, fmt.Errorf("signature sequence %d is older than the %d already seen", sequence, last)
+		}
+	}
+
+	var rollout Rollout
+	if err := json.Unmarshal(data, &rollout); err != nil {
+		return nil, fmt.Errorf("malformed: %w", err)
+	}
+	if rollout.Percent < 0 || rollout.Percent > 100 {
+		return nil, fmt.Errorf("percent %d is not from 0 to 100", rollout.Percent)
+	}
+	if sequence > 0 {
+		saveRolloutSequence(tag, sequence)
+	}
+	return &rollout, nil
+}
+
+// loadRolloutSequences returns the highest accepted sequence number per
+// release tag.
+func loadRolloutSequences() map[string]int64 {
+	sequences := map[string]int64{}
+	if data, err := os.ReadFile(filepath.Join(GetDataDir(), RolloutSequenceFileName)); err == nil {
+		json.Unmarshal(data, &sequences)
+	}
+	return sequences
+}
+
+// saveRolloutSequence records sequence for the tag when it is the highest yet.
+// A run that cannot write the data directory (not elevated) leaves the record
+// to the next elevated check.
+func saveRolloutSequence(tag string, sequence int64) {
+	sequences := loadRolloutSequences()
+	if sequence <= sequences[tag] {
+		return
+	}
+	sequences[tag] = sequence
+	data, err := json.MarshalIndent(sequences, "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(GetDataDir(), RolloutSequenceFileName), data, 0644)
+}
@@ -0,0 +1,147 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// ScheduledTaskNameRotation is the task that runs bgchanger to rotate the wallpaper.
+const ScheduledTaskNameRotation = "BgChangerRotation"
+
+// RotationArg is passed to bgchanger by the rotation task, so it runs without a
+// visible console and never waits for input.
+const RotationArg = "--scheduled"
+
+// DefaultRotationInterval is how often the rotation task runs when no interval is given.
+const DefaultRotationInterval = time.Hour
+
+// GetInstalledChangerPath returns the path bgchanger is copied to for the rotation task
+func GetInstalledChangerPath() string {
+	return filepath.Join(GetInstallDir(), "bgchanger.exe")
+}
+
+// currentUserSID returns the SID of the user running the process, which the
+// rotation task runs as so the wallpaper is set for that user.
+func currentUserSID() (string, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", fmt.Errorf("failed to read current user: %w", err)
+	}
+	return user.User.Sid.String(), nil
+}
+
+// RotationTaskExists checks if the rotation task is installed
+func RotationTaskExists() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	_, err := winapi.Tasks.Query(ctx, ScheduledTaskNameRotation)
+	return err == nil
+}
+
+// InstallRotationTask copies bgchanger to the install directory and creates a
+// task that runs it for the current user at logon and then every interval.
+// source is passed to bgchanger (a folder, URL, "bing", "library:<name>", ...);
+// when empty bgchanger uses its default rotation and the seasons from the config.
+// The task runs with the user's highest privileges, so the user must be an
+// administrator for the lock and login screen to change without a UAC prompt.
+func InstallRotationTask(changerPath string, interval time.Duration, source string) error {
+	if interval < time.Minute {
+		return fmt.Errorf("invalid rotation interval %v: must be at least 1m", interval)
+	}
+
+	sid, err := currentUserSID()
+	if err != nil {
+		return err
+	}
+
+	installDir := GetInstallDir()
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		return errs.Classify(fmt.Errorf("failed to create install directory: %w", err))
+	}
+
+	// Copy bgchanger unless the task is being installed from the installed copy
+	destPath := GetInstalledChangerPath()
+	srcAbs, _ := filepath.Abs(changerPath)
+	if !strings.EqualFold(srcAbs, destPath) {
+		if err := copyFile(changerPath, destPath); err != nil {
+			return errs.Classify(fmt.Errorf("failed to copy bgchanger: %w", err))
+		}
+	}
+
+	args := RotationArg
+	if source != "" {
+		args += ` "` + source + `"`
+	}
+
+	taskXML := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Rotates the desktop, lock screen and login screen wallpaper with bgchanger</Description>
+    <URI>\%s</URI>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <UserId>%s</UserId>
+      <LogonType>InteractiveToken</LogonType>
+      <RunLevel>HighestAvailable</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <Enabled>true</Enabled>
+    <ExecutionTimeLimit>PT%dS</ExecutionTimeLimit>
+    <Priority>%d</Priority>
+  </Settings>
+  <Triggers>
+    <LogonTrigger>
+      <Enabled>true</Enabled>
+      <UserId>%s</UserId>
+    </LogonTrigger>
+    <TimeTrigger>
+      <StartBoundary>%s</StartBoundary>
+      <Enabled>true</Enabled>
+      <Repetition>
+        <Interval>PT%dS</Interval>
+      </Repetition>
+    </TimeTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>"%s"</Command>
+      <Arguments>%s</Arguments>
+    </Exec>
+  </Actions>
+</Task>`, ScheduledTaskNameRotation, sid, int(DefaultRefreshTimeLimit.Seconds()), DefaultRefreshPriority, sid,
+		time.Now().Add(interval).Format("2006-01-02T15:04:05"), int(interval.Seconds()),
+		escapeXML(destPath), escapeXML(args))
+
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	if err := winapi.Tasks.Create(ctx, ScheduledTaskNameRotation, taskXML); err != nil {
+		return errs.Classify(fmt.Errorf("failed to create rotation task: %w", err))
+	}
+	return nil
+}
+
+// DeleteRotationTask removes the rotation task
+func DeleteRotationTask() {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameRotation)
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"golang.org/x/sys/windows/registry"
 	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/eventlog"
 	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/servercore"
+	"github.com/backgroundchanger/internal/winapi"
 )
 
 // Command execution timeout constants
@@ -115,7 +119,7 @@ func IsServiceRunning() (bool, error) {
 func StopService() error {
 	m, err := connectToServiceManager()
 	if err != nil {
-		return fmt.Errorf("failed to connect to service manager: %w", err)
+		return errs.Classify(fmt.Errorf("failed to connect to service manager: %w", err))
 	}
 	defer m.Disconnect()
 
@@ -161,7 +165,7 @@ func StopService() error {
 func DeleteService() error {
 	m, err := connectToServiceManager()
 	if err != nil {
-		return fmt.Errorf("failed to connect to service manager: %w", err)
+		return errs.Classify(fmt.Errorf("failed to connect to service manager: %w", err))
 	}
 	defer m.Disconnect()
 
@@ -174,7 +178,7 @@ func DeleteService() error {
 
 	err = s.Delete()
 	if err != nil {
-		return fmt.Errorf("failed to delete service: %w", err)
+		return errs.Classify(fmt.Errorf("failed to delete service: %w", err))
 	}
 
 	// Give Windows time to clean up
@@ -186,33 +190,33 @@ func DeleteService() error {
 func InstallService(exePath string) error {
 	m, err := connectToServiceManager()
 	if err != nil {
-		return fmt.Errorf("failed to connect to service manager: %w", err)
+		return errs.Classify(fmt.Errorf("failed to connect to service manager: %w", err))
 	}
 	defer m.Disconnect()
 
 	// Create installation directory
 	installDir := GetInstallDir()
 	if err := os.MkdirAll(installDir, 0755); err != nil {
-		return fmt.Errorf("failed to create install directory: %w", err)
+		return errs.Classify(fmt.Errorf("failed to create install directory: %w", err))
 	}
 
 	// Copy executable to installation directory
 	destPath := filepath.Join(installDir, "bgStatusService.exe")
 	if err := copyFile(exePath, destPath); err != nil {
-		return fmt.Errorf("failed to copy executable: %w", err)
+		return errs.Classify(fmt.Errorf("failed to copy executable: %w", err))
 	}
 
 	// Create the service
-	config := mgr.Config{
+	serviceConfig := mgr.Config{
 		DisplayName:  ServiceDisplayName,
 		Description:  ServiceDescription,
 		StartType:    mgr.StartAutomatic,
 		ErrorControl: mgr.ErrorNormal,
 	}
 
-	s, err := m.CreateService(ServiceName, destPath, config)
+	s, err := m.CreateService(ServiceName, destPath, serviceConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create service: %w", err)
+		return errs.Classify(fmt.Errorf("failed to create service: %w", err))
 	}
 	defer s.Close()
 
@@ -220,13 +224,12 @@ func InstallService(exePath string) error {
 	// This is optional and can be done via sc.exe if needed
 
 	// Create data directory
-	dataDir := GetDataDir()
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
+	if err := config.Secure(); err != nil {
+		return errs.Classify(fmt.Errorf("failed to create data directory: %w", err))
 	}
 
 	// Register event log source
-	err = eventlog.InstallAsEventCreate(ServiceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	err = installEventSource()
 	if err != nil {
 		// Non-critical, just log it
 		// The service will still work without event logging
@@ -239,7 +242,7 @@ func InstallService(exePath string) error {
 func StartService() error {
 	m, err := connectToServiceManager()
 	if err != nil {
-		return fmt.Errorf("failed to connect to service manager: %w", err)
+		return errs.Classify(fmt.Errorf("failed to connect to service manager: %w", err))
 	}
 	defer m.Disconnect()
 
@@ -277,7 +280,7 @@ func RemoveInstallation() error {
 
 	// Try to remove the installation directory
 	if err := os.RemoveAll(installDir); err != nil {
-		return fmt.Errorf("failed to remove install directory: %w", err)
+		return errs.Classify(fmt.Errorf("failed to remove install directory: %w", err))
 	}
 
 	return nil
@@ -289,7 +292,7 @@ func RemoveDataDirectory() error {
 
 	// Try to remove the data directory
 	if err := os.RemoveAll(dataDir); err != nil {
-		return fmt.Errorf("failed to remove data directory: %w", err)
+		return errs.Classify(fmt.Errorf("failed to remove data directory: %w", err))
 	}
 
 	return nil
@@ -297,7 +300,7 @@ func RemoveDataDirectory() error {
 
 // RemoveEventLogSource removes the event log registration
 func RemoveEventLogSource() error {
-	return eventlog.Remove(ServiceName)
+	return winapi.Reg.DeleteKey(registry.LOCAL_MACHINE, eventSourceKey)
 }
 
 // copyFile copies a file from src to dst
@@ -335,8 +338,7 @@ func runCommandWithTimeout(ctx context.Context, name string, args ...string) ([]
 		defer cancel()
 	}
 
-	cmd := exec.CommandContext(ctx, name, args...)
-	output, err := cmd.CombinedOutput()
+	output, err := winapi.Commands.Run(ctx, name, args...)
 	if ctx.Err() == context.DeadlineExceeded {
 		return output, fmt.Errorf("command timed out after %v", CommandTimeout)
 	}
@@ -350,18 +352,48 @@ const (
 	ScheduledTaskNameLock = "BgStatusServiceLock"
 	// ScheduledTaskNameBoot is the task that runs at boot with LogonUI restart
 	ScheduledTaskNameBoot = "BgStatusServiceBoot"
+	// ScheduledTaskNameResume is the task that re-renders after resume from sleep
+	ScheduledTaskNameResume = "BgStatusServiceResume"
+	// ScheduledTaskNameDisplay is the task that swaps in the image pre-rendered
+	// for the current display resolution (when display_variants is enabled)
+	ScheduledTaskNameDisplay = "BgStatusServiceDisplay"
+	// ScheduledTaskNameDashboard is the task that serves the status dashboard
+	// (when dashboard.enabled is set)
+	ScheduledTaskNameDashboard = "BgStatusServiceDashboard"
+	// ScheduledTaskNameSNMP is the task that runs the SNMP agent (when
+	// snmp.enabled is set)
+	ScheduledTaskNameSNMP = "BgStatusServiceSNMP"
+	// ScheduledTaskNameMOTD is the task that prints the status at sign-in on
+	// Server Core (when server_core.output includes "motd")
+	ScheduledTaskNameMOTD = "BgStatusServiceMOTD"
+	// ScheduledTaskNameFollowUp is the one-time task a boot run schedules when it
+	// had to render before the machine was ready
+	ScheduledTaskNameFollowUp = "BgStatusServiceFollowUp"
 )
 
+// ResumeArg marks a run started by the resume task, which waits for the network
+// to settle and only renders when the addresses changed.
+const ResumeArg = "--resume"
+
+// DashboardArg runs the status dashboard instead of an update.
+const DashboardArg = "--dashboard"
+
+// SNMPArg runs the SNMP agent instead of an update.
+const SNMPArg = "--snmp"
+
+// FollowUpArg marks a run started by the follow-up task, which never schedules another.
+const FollowUpArg = "--follow-up"
+
 // ScheduledTaskExists checks if either scheduled task is installed
 func ScheduledTaskExists() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
 	defer cancel()
 
-	_, err := runCommandWithTimeout(ctx, "schtasks", "/query", "/tn", ScheduledTaskNameBoot)
+	_, err := winapi.Tasks.Query(ctx, ScheduledTaskNameBoot)
 	if err == nil {
 		return true
 	}
-	_, err = runCommandWithTimeout(ctx, "schtasks", "/query", "/tn", ScheduledTaskNameLock)
+	_, err = winapi.Tasks.Query(ctx, ScheduledTaskNameLock)
 	if err == nil {
 		return true
 	}
@@ -373,20 +405,32 @@ func InstallScheduledTasks(exePath string) error {
 	// Create installation directory
 	installDir := GetInstallDir()
 	if err := os.MkdirAll(installDir, 0755); err != nil {
-		return fmt.Errorf("failed to create install directory: %w", err)
+		return errs.Classify(fmt.Errorf("failed to create install directory: %w", err))
 	}
 
 	// Copy executable to installation directory
 	destPath := filepath.Join(installDir, "bgStatusService.exe")
 	if err := copyFile(exePath, destPath); err != nil {
-		return fmt.Errorf("failed to copy executable: %w", err)
+		return errs.Classify(fmt.Errorf("failed to copy executable: %w", err))
 	}
 
 	// Create data directory
-	dataDir := GetDataDir()
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
+	if err := config.Secure(); err != nil {
+		return errs.Classify(fmt.Errorf("failed to create data directory: %w", err))
+	}
+
+	// Triggers, priority, time limit and battery conditions come from the config
+	tasks := loadTasksConfig()
+	lockTriggers, err := refreshTriggersXML(tasks)
+	if err != nil {
+		return fmt.Errorf("invalid task configuration: %w", err)
+	}
+	lockPriority, lockTimeLimit, err := refreshSettings(tasks)
+	if err != nil {
+		return fmt.Errorf("invalid task configuration: %w", err)
 	}
+	battery := batterySettingsXML(tasks)
+	bootTimeLimit := fmt.Sprintf("PT%dS", int(BootTimeLimit.Seconds()))
 
 	// Delete existing tasks
 	DeleteScheduledTasks()
@@ -405,13 +449,12 @@ func InstallScheduledTasks(exePath string) error {
     </Principal>
   </Principals>
   <Settings>
-    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
-    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+%s
     <AllowStartOnDemand>true</AllowStartOnDemand>
     <StartWhenAvailable>true</StartWhenAvailable>
     <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
     <Enabled>true</Enabled>
-    <ExecutionTimeLimit>PT5M</ExecutionTimeLimit>
+    <ExecutionTimeLimit>%s</ExecutionTimeLimit>
     <Priority>1</Priority>
   </Settings>
   <Triggers>
@@ -425,9 +468,9 @@ func InstallScheduledTasks(exePath string) error {
       <Arguments>--boot</Arguments>
     </Exec>
   </Actions>
-</Task>`, ScheduledTaskNameBoot, destPath)
+</Task>`, ScheduledTaskNameBoot, battery, bootTimeLimit, destPath)
 
-	// Create lock task XML (runs on lock/logoff without restarting LogonUI)
+	// Create lock task XML (runs on lock/logoff without restarting LogonUI, or on the configured triggers)
 	lockTaskXML := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
 <Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
   <RegistrationInfo>
@@ -441,62 +484,69 @@ func InstallScheduledTasks(exePath string) error {
     </Principal>
   </Principals>
   <Settings>
-    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
-    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+%s
     <AllowStartOnDemand>true</AllowStartOnDemand>
     <StartWhenAvailable>true</StartWhenAvailable>
     <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
     <Enabled>true</Enabled>
-    <ExecutionTimeLimit>PT10M</ExecutionTimeLimit>
-    <Priority>7</Priority>
+    <ExecutionTimeLimit>%s</ExecutionTimeLimit>
+    <Priority>%d</Priority>
   </Settings>
-  <Triggers>
-    <SessionStateChangeTrigger>
-      <Enabled>true</Enabled>
-      <StateChange>SessionLock</StateChange>
-    </SessionStateChangeTrigger>
-    <SessionStateChangeTrigger>
-      <Enabled>true</Enabled>
-      <StateChange>ConsoleDisconnect</StateChange>
-    </SessionStateChangeTrigger>
+  <Triggers>%s
   </Triggers>
   <Actions Context="Author">
     <Exec>
       <Command>"%s"</Command>
     </Exec>
   </Actions>
-</Task>`, ScheduledTaskNameLock, destPath)
-
-	// Write and import boot task
-	tempDir := os.TempDir()
-	bootXMLPath := filepath.Join(tempDir, "bgstatus_boot.xml")
-	if err := os.WriteFile(bootXMLPath, []byte(bootTaskXML), 0644); err != nil {
-		return fmt.Errorf("failed to write boot task XML: %w", err)
-	}
-	defer os.Remove(bootXMLPath)
+</Task>`, ScheduledTaskNameLock, battery, lockTimeLimit, lockPriority, lockTriggers, destPath)
 
 	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
 	defer cancel()
 
-	output, err := runCommandWithTimeout(ctx, "schtasks", "/create", "/tn", ScheduledTaskNameBoot, "/xml", bootXMLPath, "/f")
-	if err != nil {
-		return fmt.Errorf("failed to create boot task: %w - %s", err, string(output))
+	if err := winapi.Tasks.Create(ctx, ScheduledTaskNameBoot, bootTaskXML); err != nil {
+		return fmt.Errorf("failed to create boot task: %w", err)
+	}
+	if err := winapi.Tasks.Create(ctx, ScheduledTaskNameLock, lockTaskXML); err != nil {
+		return fmt.Errorf("failed to create lock task: %w", err)
+	}
+	if err := winapi.Tasks.Create(ctx, ScheduledTaskNameResume, resumeTaskXML(destPath, battery, lockPriority, lockTimeLimit)); err != nil {
+		return fmt.Errorf("failed to create resume task: %w", err)
 	}
 
-	// Write and import lock task
-	lockXMLPath := filepath.Join(tempDir, "bgstatus_lock.xml")
-	if err := os.WriteFile(lockXMLPath, []byte(lockTaskXML), 0644); err != nil {
-		return fmt.Errorf("failed to write lock task XML: %w", err)
+	// Display task (only with display_variants)
+	if displayVariantsEnabled() {
+		if err := winapi.Tasks.Create(ctx, ScheduledTaskNameDisplay, displayTaskXML(destPath, battery)); err != nil {
+			return fmt.Errorf("failed to create display task: %w", err)
+		}
 	}
-	defer os.Remove(lockXMLPath)
 
-	output, err = runCommandWithTimeout(ctx, "schtasks", "/create", "/tn", ScheduledTaskNameLock, "/xml", lockXMLPath, "/f")
-	if err != nil {
-		return fmt.Errorf("failed to create lock task: %w - %s", err, string(output))
+	// Dashboard task, started right away (only with dashboard.enabled)
+	if dashboardEnabled() {
+		if err := winapi.Tasks.Create(ctx, ScheduledTaskNameDashboard, dashboardTaskXML(destPath)); err != nil {
+			return fmt.Errorf("failed to create dashboard task: %w", err)
+		}
+		winapi.Tasks.Run(ctx, ScheduledTaskNameDashboard)
+	}
+
+	// SNMP agent task, started right away (only with snmp.enabled)
+	if snmpEnabled() {
+		if err := winapi.Tasks.Create(ctx, ScheduledTaskNameSNMP, snmpTaskXML(destPath)); err != nil {
+			return fmt.Errorf("failed to create SNMP task: %w", err)
+		}
+		winapi.Tasks.Run(ctx, ScheduledTaskNameSNMP)
+	}
+
+	// Sign-in status task (only on Server Core with a MOTD)
+	if motdEnabled() {
+		motdXML := motdTaskXML(filepath.Join(GetDataDir(), servercore.MOTDFileName))
+		if err := winapi.Tasks.Create(ctx, ScheduledTaskNameMOTD, motdXML); err != nil {
+			return fmt.Errorf("failed to create MOTD task: %w", err)
+		}
 	}
 
 	// Register event log source
-	_ = eventlog.InstallAsEventCreate(ServiceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	_ = installEventSource()
 
 	return nil
 }
@@ -506,8 +556,65 @@ func DeleteScheduledTasks() {
 	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
 	defer cancel()
 
-	runCommandWithTimeout(ctx, "schtasks", "/delete", "/tn", ScheduledTaskNameBoot, "/f")
-	runCommandWithTimeout(ctx, "schtasks", "/delete", "/tn", ScheduledTaskNameLock, "/f")
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameBoot)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameLock)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameResume)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameDisplay)
+	// A running dashboard or SNMP agent keeps the executable locked
+	winapi.Tasks.End(ctx, ScheduledTaskNameDashboard)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameDashboard)
+	winapi.Tasks.End(ctx, ScheduledTaskNameSNMP)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameSNMP)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameMOTD)
+	winapi.Tasks.Delete(ctx, ScheduledTaskNameFollowUp)
+}
+
+// ScheduleFollowUpRun creates a one-time task that runs the installed executable
+// in boot mode again after delay. It replaces an earlier follow-up task that has
+// not run yet.
+func ScheduleFollowUpRun(delay time.Duration) error {
+	taskXML := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Updates login screen again once the network is up after a boot run that showed cached data</Description>
+    <URI>\%s</URI>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <UserId>S-1-5-18</UserId>
+      <RunLevel>HighestAvailable</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <Enabled>true</Enabled>
+    <ExecutionTimeLimit>PT%dS</ExecutionTimeLimit>
+    <Priority>1</Priority>
+  </Settings>
+  <Triggers>
+    <TimeTrigger>
+      <StartBoundary>%s</StartBoundary>
+      <Enabled>true</Enabled>
+    </TimeTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>"%s"</Command>
+      <Arguments>--boot %s</Arguments>
+    </Exec>
+  </Actions>
+</Task>`, ScheduledTaskNameFollowUp, int(BootTimeLimit.Seconds()),
+		time.Now().Add(delay).Format("2006-01-02T15:04:05"), GetInstalledExePath(), FollowUpArg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	if err := winapi.Tasks.Create(ctx, ScheduledTaskNameFollowUp, taskXML); err != nil {
+		return fmt.Errorf("failed to create follow-up task: %w", err)
+	}
+	return nil
 }
 
 // RunScheduledTask runs the boot task to generate the initial image
@@ -515,9 +622,8 @@ func RunScheduledTask() error {
 	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
 	defer cancel()
 
-	output, err := runCommandWithTimeout(ctx, "schtasks", "/run", "/tn", ScheduledTaskNameBoot)
-	if err != nil {
-		return fmt.Errorf("failed to run task: %w - %s", err, string(output))
+	if err := winapi.Tasks.Run(ctx, ScheduledTaskNameBoot); err != nil {
+		return fmt.Errorf("failed to run task: %w", err)
 	}
 	return nil
 }
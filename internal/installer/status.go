@@ -0,0 +1,113 @@
+package installer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// versionFileName records the installed version in the install directory, so
+// it goes away with the installation.
+const versionFileName = "version.txt"
+
+// TaskMissing is the state of a scheduled task that does not exist.
+const TaskMissing = "missing"
+
+// requiredTasks are created by every install; the others depend on the config.
+var requiredTasks = []string{ScheduledTaskNameBoot, ScheduledTaskNameLock, ScheduledTaskNameResume}
+
+// optionalTasks are only created when their feature is enabled.
+var optionalTasks = []string{
+	ScheduledTaskNameDisplay, ScheduledTaskNameDashboard, ScheduledTaskNameSNMP,
+	ScheduledTaskNameMOTD, ScheduledTaskNameFollowUp, ScheduledTaskNameRotation,
+}
+
+// InstallStatus is the installation state reported by the installer's --status.
+type InstallStatus struct {
+	// Installed is true when the executable and every required task exist.
+	Installed bool `json:"installed"`
+	// Partial is true when some, but not all, of them exist; reinstalling or
+	// uninstalling repairs it.
+	Partial bool `json:"partial"`
+	// Version is the installed version, empty for installs from before it was
+	// recorded.
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path"`
+	// Tasks maps the required tasks, and the optional ones that exist, to
+	// their state as schtasks reports it (e.g. "Ready", "Running",
+	// "Disabled") or "missing".
+	Tasks map[string]string `json:"tasks"`
+	// LegacyService is true when the Windows service of old versions is still
+	// installed.
+	LegacyService bool `json:"legacy_service"`
+}
+
+// RecordInstalledVersion writes the version that was just installed.
+func RecordInstalledVersion(version string) error {
+	path := filepath.Join(GetInstallDir(), versionFileName)
+	if err := os.WriteFile(path, []byte(version+"\r\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record installed version: %w", err)
+	}
+	return nil
+}
+
+// InstalledVersion returns the recorded installed version, or "" if unknown.
+func InstalledVersion() string {
+	data, err := os.ReadFile(filepath.Join(GetInstallDir(), versionFileName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// TaskState returns a scheduled task's state as schtasks reports it, or
+// TaskMissing when it does not exist.
+func TaskState(name string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	output, err := winapi.Tasks.Query(ctx, name)
+	if err != nil {
+		return TaskMissing
+	}
+	// "\BgStatusServiceBoot","N/A","Ready"; the status is the last column
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil || len(records) == 0 || len(records[0]) == 0 {
+		return "unknown"
+	}
+	return strings.TrimSpace(records[0][len(records[0])-1])
+}
+
+// QueryStatus reports what is installed, without changing anything.
+func QueryStatus() *InstallStatus {
+	status := &InstallStatus{
+		Path:    GetInstalledExePath(),
+		Version: InstalledVersion(),
+		Tasks:   map[string]string{},
+	}
+	_, err := os.Stat(status.Path)
+	exeExists := err == nil
+	status.LegacyService, _ = ServiceExists()
+
+	complete, found := exeExists, exeExists
+	for _, name := range requiredTasks {
+		state := TaskState(name)
+		status.Tasks[name] = state
+		complete = complete && state != TaskMissing
+		found = found || state != TaskMissing
+	}
+	for _, name := range optionalTasks {
+		if state := TaskState(name); state != TaskMissing {
+			status.Tasks[name] = state
+		}
+	}
+
+	status.Installed = complete
+	status.Partial = !complete && (found || status.LegacyService)
+	return status
+}
@@ -0,0 +1,421 @@
+package installer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+)
+
+// Defaults for the refresh (lock) task, used when the config doesn't set them.
+const (
+	DefaultRefreshPriority  = 7
+	DefaultRefreshTimeLimit = 10 * time.Minute
+)
+
+// BootTimeLimit is the execution time limit of the boot task.
+const BootTimeLimit = 5 * time.Minute
+
+// defaultRefreshTriggers are the triggers used when the config doesn't list any.
+var defaultRefreshTriggers = []config.TriggerConfig{
+	{Type: config.TriggerLock},
+	{Type: config.TriggerDisconnect},
+}
+
+// loadTasksConfig returns the task settings from the config file. A broken config
+// file falls back to the defaults so it never blocks the install.
+func loadTasksConfig() config.TasksConfig {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.TasksConfig{}
+	}
+	return cfg.Tasks
+}
+
+// ReportOnly reports whether the config puts BgStatusService in report-only
+// mode, in which the installer must not apply a login screen either. A broken
+// config file counts as the normal mode.
+func ReportOnly() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.ReportOnly()
+}
+
+// motdEnabled reports whether this is Server Core and the config asks for
+// the status to be printed at sign-in. A broken config file counts as off.
+func motdEnabled() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.ServerCore.MOTD() && !cfg.ReportOnly() && capability.Detect().OS.ServerCore
+}
+
+// motdTaskXML returns the task that prints the status file in a console
+// window when any user signs in on Server Core.
+func motdTaskXML(motdPath string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Shows the BgStatusService status at sign-in on Server Core</Description>
+    <URI>\%s</URI>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <GroupId>S-1-5-32-545</GroupId>
+      <RunLevel>LeastPrivilege</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <MultipleInstancesPolicy>Parallel</MultipleInstancesPolicy>
+    <Enabled>true</Enabled>
+    <ExecutionTimeLimit>PT1H</ExecutionTimeLimit>
+  </Settings>
+  <Triggers>
+    <LogonTrigger>
+      <Enabled>true</Enabled>
+    </LogonTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>%%SystemRoot%%\System32\cmd.exe</Command>
+      <Arguments>%s</Arguments>
+    </Exec>
+  </Actions>
+</Task>`, ScheduledTaskNameMOTD, escapeXML(`/c type "`+motdPath+`" & pause`))
+}
+
+// displayVariantsEnabled reports whether the config asks for per-resolution
+// variants, which report-only mode never renders. A broken config file counts
+// as off.
+func displayVariantsEnabled() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.DisplayVariants && !cfg.ReportOnly()
+}
+
+// resumeEventQuery matches the System log event written on resume from sleep
+// or hibernation (Power-Troubleshooter event 1).
+const resumeEventQuery = `<QueryList><Query Id="0" Path="System"><Select Path="System">` +
+	`*[System[Provider[@Name='Microsoft-Windows-Power-Troubleshooter'] and EventID=1]]</Select></Query></QueryList>`
+
+// resumeTaskXML returns the task that runs "--resume" after the machine wakes,
+// so the addresses on the login screen don't stay those of the old DHCP lease.
+func resumeTaskXML(exePath, battery string, priority int, timeLimit string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Updates the login screen after resume from sleep if the network addresses changed</Description>
+    <URI>\%s</URI>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <UserId>S-1-5-18</UserId>
+      <RunLevel>HighestAvailable</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+%s
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <Enabled>true</Enabled>
+    <ExecutionTimeLimit>%s</ExecutionTimeLimit>
+    <Priority>%d</Priority>
+  </Settings>
+  <Triggers>
+    <EventTrigger>
+      <Enabled>true</Enabled>
+      <Subscription>%s</Subscription>
+    </EventTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>"%s"</Command>
+      <Arguments>%s</Arguments>
+    </Exec>
+  </Actions>
+</Task>`, ScheduledTaskNameResume, battery, timeLimit, priority, escapeXML(resumeEventQuery), exePath, ResumeArg)
+}
+
+// dashboardEnabled reports whether the config turns on the status dashboard.
+func dashboardEnabled() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.Dashboard.Enabled
+}
+
+// dashboardTaskXML returns the task that starts the status dashboard at boot.
+func dashboardTaskXML(exePath string) string {
+	return serverTaskXML(ScheduledTaskNameDashboard, "Serves the BgStatusService status dashboard", exePath, DashboardArg)
+}
+
+// snmpEnabled reports whether the config turns on the SNMP agent.
+func snmpEnabled() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.SNMP.Enabled
+}
+
+// snmpTaskXML returns the task that starts the SNMP agent at boot.
+func snmpTaskXML(exePath string) string {
+	return serverTaskXML(ScheduledTaskNameSNMP, "Serves the BgStatusService status over SNMP", exePath, SNMPArg)
+}
+
+// serverTaskXML returns a task that starts a long-running mode at boot and
+// keeps it running: no time limit, on battery too, restarted if it exits.
+func serverTaskXML(name, description, exePath, arg string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>%s</Description>
+    <URI>\%s</URI>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <UserId>S-1-5-18</UserId>
+      <RunLevel>HighestAvailable</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <Enabled>true</Enabled>
+    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>
+    <RestartOnFailure>
+      <Interval>PT1M</Interval>
+      <Count>3</Count>
+    </RestartOnFailure>
+    <Priority>%d</Priority>
+  </Settings>
+  <Triggers>
+    <BootTrigger>
+      <Enabled>true</Enabled>
+    </BootTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>"%s"</Command>
+      <Arguments>%s</Arguments>
+    </Exec>
+  </Actions>
+</Task>`, description, name, DefaultRefreshPriority, exePath, arg)
+}
+
+// displayTaskXML returns the task that runs "--display-changed" when the display
+// setup is likely to have changed: on unlock, on console or remote reconnect
+// and on resume from sleep (Power-Troubleshooter event 1).
+func displayTaskXML(exePath, battery string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Swaps the login screen to the image rendered for the current display resolution</Description>
+    <URI>\%s</URI>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <UserId>S-1-5-18</UserId>
+      <RunLevel>HighestAvailable</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+%s
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <Enabled>true</Enabled>
+    <ExecutionTimeLimit>PT%dS</ExecutionTimeLimit>
+    <Priority>%d</Priority>
+  </Settings>
+  <Triggers>
+    <SessionStateChangeTrigger>
+      <Enabled>true</Enabled>
+      <StateChange>SessionUnlock</StateChange>
+    </SessionStateChangeTrigger>
+    <SessionStateChangeTrigger>
+      <Enabled>true</Enabled>
+      <StateChange>ConsoleConnect</StateChange>
+    </SessionStateChangeTrigger>
+    <SessionStateChangeTrigger>
+      <Enabled>true</Enabled>
+      <StateChange>RemoteConnect</StateChange>
+    </SessionStateChangeTrigger>
+    <EventTrigger>
+      <Enabled>true</Enabled>
+      <Subscription>%s</Subscription>
+    </EventTrigger>
+  </Triggers>
+  <Actions Context="Author">
+    <Exec>
+      <Command>"%s"</Command>
+      <Arguments>--display-changed</Arguments>
+    </Exec>
+  </Actions>
+</Task>`, ScheduledTaskNameDisplay, battery, int(DefaultRefreshTimeLimit.Seconds()), DefaultRefreshPriority,
+		escapeXML(resumeEventQuery), exePath)
+}
+
+// taskDuration converts a Go duration string ("10m") to the ISO 8601 form Task
+// Scheduler expects ("PT600S").
+func taskDuration(value string) (string, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	if d <= 0 {
+		return "", fmt.Errorf("invalid duration %q: must be positive", value)
+	}
+	return fmt.Sprintf("PT%dS", int(d.Seconds())), nil
+}
+
+// escapeXML escapes text for use inside an XML element.
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// batterySettingsXML returns the battery conditions for a task.
+func batterySettingsXML(tasks config.TasksConfig) string {
+	return fmt.Sprintf(`    <DisallowStartIfOnBatteries>%t</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>%t</StopIfGoingOnBatteries>`, tasks.DisallowOnBattery, tasks.StopOnBattery)
+}
+
+// refreshSettings returns the priority and time limit of the refresh task.
+func refreshSettings(tasks config.TasksConfig) (priority int, timeLimit string, err error) {
+	priority = DefaultRefreshPriority
+	if tasks.Priority != nil {
+		priority = *tasks.Priority
+		if priority < 0 || priority > 10 {
+			return 0, "", fmt.Errorf("invalid task priority %d: must be 0-10", priority)
+		}
+	}
+
+	timeLimit = fmt.Sprintf("PT%dS", int(DefaultRefreshTimeLimit.Seconds()))
+	if tasks.TimeLimit != "" {
+		timeLimit, err = taskDuration(tasks.TimeLimit)
+		if err != nil {
+			return 0, "", fmt.Errorf("task time_limit: %w", err)
+		}
+	}
+
+	return priority, timeLimit, nil
+}
+
+// ValidateTasks checks the priority, time limits and triggers of the tasks
+// config the way Install would, without touching Task Scheduler.
+func ValidateTasks(tasks config.TasksConfig) error {
+	if _, _, err := refreshSettings(tasks); err != nil {
+		return err
+	}
+	_, err := refreshTriggersXML(tasks)
+	return err
+}
+
+// RunTimeLimit returns the execution time limit Task Scheduler applies to a run
+// of the boot task or the refresh task, so the run can stop cleanly on its own
+// before it is killed. An invalid time_limit falls back to the default, as the
+// installer would have refused it.
+func RunTimeLimit(tasks config.TasksConfig, boot bool) time.Duration {
+	if boot {
+		return BootTimeLimit
+	}
+	if tasks.TimeLimit != "" {
+		d, err := time.ParseDuration(tasks.TimeLimit)
+		if err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultRefreshTimeLimit
+}
+
+// refreshTriggersXML builds the <Triggers> content of the refresh task.
+func refreshTriggersXML(tasks config.TasksConfig) (string, error) {
+	triggers := tasks.Triggers
+	if len(triggers) == 0 {
+		triggers = defaultRefreshTriggers
+	}
+
+	randomDelay := ""
+	if tasks.RandomDelay != "" {
+		d, err := taskDuration(tasks.RandomDelay)
+		if err != nil {
+			return "", fmt.Errorf("task random_delay: %w", err)
+		}
+		randomDelay = fmt.Sprintf("\n      <RandomDelay>%s</RandomDelay>", d)
+	}
+
+	var b strings.Builder
+	for i, t := range triggers {
+		delay := ""
+		if t.Delay != "" {
+			d, err := taskDuration(t.Delay)
+			if err != nil {
+				return "", fmt.Errorf("trigger %d delay: %w", i+1, err)
+			}
+			delay = fmt.Sprintf("\n      <Delay>%s</Delay>", d)
+		}
+
+		switch strings.ToLower(t.Type) {
+		case config.TriggerLock, config.TriggerUnlock, config.TriggerDisconnect:
+			state := map[string]string{
+				config.TriggerLock:       "SessionLock",
+				config.TriggerUnlock:     "SessionUnlock",
+				config.TriggerDisconnect: "ConsoleDisconnect",
+			}[strings.ToLower(t.Type)]
+			fmt.Fprintf(&b, `
+    <SessionStateChangeTrigger>
+      <Enabled>true</Enabled>%s
+      <StateChange>%s</StateChange>
+    </SessionStateChangeTrigger>`, delay, state)
+
+		case config.TriggerLogon:
+			fmt.Fprintf(&b, `
+    <LogonTrigger>
+      <Enabled>true</Enabled>%s
+    </LogonTrigger>`, delay)
+
+		case config.TriggerDaily:
+			at, err := time.Parse("15:04", t.Time)
+			if err != nil {
+				return "", fmt.Errorf("trigger %d: invalid daily time %q (expected HH:MM)", i+1, t.Time)
+			}
+			fmt.Fprintf(&b, `
+    <CalendarTrigger>
+      <StartBoundary>2000-01-01T%s:00</StartBoundary>
+      <Enabled>true</Enabled>%s
+      <ScheduleByDay>
+        <DaysInterval>1</DaysInterval>
+      </ScheduleByDay>
+    </CalendarTrigger>`, at.Format("15:04"), randomDelay)
+
+		case config.TriggerEvent:
+			query := t.Query
+			if query == "" {
+				if t.Log == "" || t.EventID <= 0 {
+					return "", fmt.Errorf("trigger %d: event triggers need log and event_id, or query", i+1)
+				}
+				query = fmt.Sprintf("*[System[EventID=%d]]", t.EventID)
+			}
+			log := t.Log
+			if log == "" {
+				log = "System"
+			}
+			subscription := fmt.Sprintf(`<QueryList><Query Id="0" Path="%s"><Select Path="%s">%s</Select></Query></QueryList>`,
+				escapeXML(log), escapeXML(log), escapeXML(query))
+			fmt.Fprintf(&b, `
+    <EventTrigger>
+      <Enabled>true</Enabled>%s
+      <Subscription>%s</Subscription>
+    </EventTrigger>`, delay, escapeXML(subscription))
+
+		default:
+			return "", fmt.Errorf("trigger %d: unknown type %q (expected lock, unlock, disconnect, logon, daily or event)", i+1, t.Type)
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,259 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/signing"
+)
+
+// ChangerExeName is the name of the bgchanger executable in GitHub releases
+const ChangerExeName = "bgchanger.exe"
+
+// ChecksumSuffix is appended to an asset name to find its published SHA-256 checksum
+const ChecksumSuffix = ".sha256"
+
+// FindAsset finds an asset by name in a release
+func FindAsset(release *GitHubRelease, name string) (*GitHubAsset, error) {
+	for _, asset := range release.Assets {
+		if strings.EqualFold(asset.Name, name) {
+			return &asset, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find %s in release %s", name, release.TagName)
+}
+
+// CompareVersions compares two version strings like "v1.2.3".
+// Returns -1 if a < b, 0 if equal, and 1 if a > b.
+// Non-numeric versions (e.g. "dev") sort before any release.
+func CompareVersions(a, b string) int {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		switch {
+		case okA == okB:
+			return strings.Compare(a, b)
+		case okA:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if pa[i] < pb[i] {
+			return -1
+		}
+		if pa[i] > pb[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseVersion parses "v1.2.3" (with optional "-suffix") into its numeric parts
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// DownloadReleaseAsset downloads the named asset from a release, falling back to the
+// configured mirrors, and returns the URL that succeeded.
+func DownloadReleaseAsset(release *GitHubRelease, name, destPath string, progress DownloadProgress) (string, error) {
+	var urls []string
+	asset, err := FindAsset(release, name)
+	if err == nil {
+		urls = append(urls, asset.BrowserDownloadURL)
+	}
+	urls = append(urls, MirrorURLs(loadMirrors(), release.TagName, name)...)
+
+	if len(urls) == 0 {
+		return "", err
+	}
+
+	sourceURL, err := DownloadWithFallback(urls, destPath, progress)
+	if err != nil {
+		return "", err
+	}
+	_ = RecordDownloadSource(sourceURL, release.TagName, asset)
+	return sourceURL, nil
+}
+
+// VerifyDownload checks a downloaded executable against the release metadata.
+// It checks the PE header, the published asset size, and the SHA-256 checksum
+// when the release includes a "<name>.sha256" asset. Builds with a pinned
+// signing key require the checksum and its signature.
+func VerifyDownload(path string, release *GitHubRelease, name string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("downloaded file missing: %w", err)
+	}
+
+	// Every Windows executable starts with the "MZ" DOS header
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open download: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != "MZ" {
+		return fmt.Errorf("downloaded file is not a Windows executable")
+	}
+
+	if asset, err := FindAsset(release, name); err == nil && asset.Size > 0 && asset.Size != info.Size() {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d", asset.Size, info.Size())
+	}
+
+	checksumAsset, err := FindAsset(release, name+ChecksumSuffix)
+	if err != nil {
+		if signing.Pinned() {
+			return fmt.Errorf("release %s has no signed checksum for %s", release.TagName, name)
+		}
+		// No published checksum - size and header checks are all we can do
+		return nil
+	}
+
+	expected, err := fetchChecksum(release, checksumAsset)
+	if err != nil {
+		return fmt.Errorf("failed to fetch checksum: %w", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("failed to hash download: %w", err)
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// fetchChecksum downloads a .sha256 file and returns the hex digest it contains.
+// Accepts both a bare digest and the "digest  filename" format of sha256sum.
+// Builds with a pinned signing key verify the file's signature first.
+func fetchChecksum(release *GitHubRelease, asset *GitHubAsset) (string, error) {
+	data, err := fetchSmallFile(asset.BrowserDownloadURL)
+	if err != nil {
+		return "", err
+	}
+	if signing.Pinned() {
+		sigAsset, err := FindAsset(release, asset.Name+signing.SignatureSuffix)
+		if err != nil {
+			return "", fmt.Errorf("%s is not signed", asset.Name)
+		}
+		sig, err := fetchSmallFile(sigAsset.BrowserDownloadURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch the signature of %s: %w", asset.Name, err)
+		}
+		if _, err := signing.Verify(data, sig, asset.Name, release.TagName, time.Now()); err != nil {
+			return "", fmt.Errorf("%s: %w", asset.Name, err)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && len(fields[0]) == sha256.Size*2 {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("checksum file is malformed")
+}
+
+// fetchSmallFile downloads a metadata file of at most 4 KB, such as a checksum,
+// signature or rollout file.
+func fetchSmallFile(url string) ([]byte, error) {
+	client := &http.Client{Timeout: HTTPAPITimeout}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "BgStatusService-Installer")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4096))
+}
+
+// OldExecutablePath returns where ReplaceExecutable moves the previous binary.
+func OldExecutablePath(exePath string) string {
+	return exePath + ".old"
+}
+
+// ReplaceExecutable swaps a running executable for a new one.
+// Windows does not allow overwriting a running exe, but it does allow renaming it,
+// so the current binary is moved aside to "<exe>.old" and the new one moved into place.
+// The old file can be deleted by the next process via CleanupOldExecutable.
+func ReplaceExecutable(exePath, newPath string) error {
+	oldPath := OldExecutablePath(exePath)
+	os.Remove(oldPath)
+
+	if err := os.Rename(exePath, oldPath); err != nil {
+		return errs.Classify(fmt.Errorf("failed to move current executable aside: %w", err))
+	}
+
+	if err := os.Rename(newPath, exePath); err != nil {
+		// Rename can fail across volumes (e.g. temp dir on another drive) - fall back to a copy
+		if copyErr := copyFile(newPath, exePath); copyErr != nil {
+			// Restore the original so we don't leave the user without a binary
+			os.Rename(oldPath, exePath)
+			return errs.Classify(fmt.Errorf("failed to install new executable: %w", copyErr))
+		}
+		os.Remove(newPath)
+	}
+
+	return nil
+}
+
+// CleanupOldExecutable removes the "<exe>.old" file left behind by a previous update.
+func CleanupOldExecutable(exePath string) {
+	oldPath := OldExecutablePath(exePath)
+	if _, err := os.Stat(oldPath); err == nil {
+		os.Remove(oldPath)
+	}
+}
+
+// UpdateTempPath returns a download location for an update on the same volume as the
+// target executable, so the final rename is atomic.
+func UpdateTempPath(exePath string) string {
+	return filepath.Join(filepath.Dir(exePath), filepath.Base(exePath)+".new")
+}
@@ -0,0 +1,387 @@
+// Package journal records the previous state of every registry value the tools
+// modify, so the changes can be reverted exactly ("bgchanger undo-system-changes"
+// and the uninstaller).
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// FileName is the name of the journal file inside the data directory.
+const FileName = "registry_journal.json"
+
+// Root hive names stored in the journal.
+const (
+	RootHKLM = "HKLM"
+	RootHKCU = "HKCU"
+	// RootHKU entries have the user's SID as the first path element.
+	RootHKU = "HKU"
+)
+
+// Entry is the state of one registry value before it was first modified.
+type Entry struct {
+	Root string `json:"root"`
+	Path string `json:"path"`
+	Name string `json:"name"`
+	// Existed is false when the value did not exist and should be deleted on undo.
+	Existed bool `json:"existed"`
+	// KeyCreated is true when the key itself did not exist and may be removed on undo.
+	KeyCreated bool `json:"key_created,omitempty"`
+
+	Type    uint32   `json:"type,omitempty"`
+	String  string   `json:"string,omitempty"`
+	Strings []string `json:"strings,omitempty"`
+	Integer uint64   `json:"integer,omitempty"`
+	Binary  []byte   `json:"binary,omitempty"`
+
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Journal is the set of recorded entries, in the order they were first modified.
+type Journal struct {
+	Entries []Entry `json:"entries"`
+}
+
+// mu serializes read-modify-write of the journal file within a process.
+var mu sync.Mutex
+
+// Path returns the full path to the journal file.
+func Path() string {
+	return filepath.Join(config.Dir(), FileName)
+}
+
+// Exists reports whether a journal with entries is present.
+func Exists() bool {
+	j, err := load()
+	return err == nil && len(j.Entries) > 0
+}
+
+// load reads the journal; a missing file yields an empty journal. A journal
+// not written by an elevated run is refused, since undo replays it as
+// administrator.
+func load() (*Journal, error) {
+	j := &Journal{}
+	data, err := os.ReadFile(Path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return j, fmt.Errorf("failed to read registry journal: %w", err)
+	}
+	if err := config.CheckOwner(Path()); err != nil {
+		return j, fmt.Errorf("refusing to use registry journal: %w", err)
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return j, fmt.Errorf("failed to parse registry journal: %w", err)
+	}
+	return j, nil
+}
+
+// save writes the journal, or removes the file when it has no entries.
+func (j *Journal) save() error {
+	if len(j.Entries) == 0 {
+		err := os.Remove(Path())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	// Only elevated runs can lock down the directory, so standard users
+	// cannot journal
+	if err := config.Secure(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode registry journal: %w", err)
+	}
+	if err := os.WriteFile(Path(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write registry journal: %w", err)
+	}
+	return config.SecureFile(Path())
+}
+
+// rootName maps a predefined key to its journal name.
+func rootName(root registry.Key) (string, error) {
+	switch root {
+	case registry.LOCAL_MACHINE:
+		return RootHKLM, nil
+	case registry.CURRENT_USER:
+		return RootHKCU, nil
+	case registry.USERS:
+		return RootHKU, nil
+	}
+	return "", fmt.Errorf("unsupported registry root")
+}
+
+// rootKey maps a journal root name back to its predefined key.
+func rootKey(name string) (registry.Key, error) {
+	switch name {
+	case RootHKLM:
+		return registry.LOCAL_MACHINE, nil
+	case RootHKCU:
+		return registry.CURRENT_USER, nil
+	case RootHKU:
+		return registry.USERS, nil
+	}
+	return 0, fmt.Errorf("unsupported registry root %q", name)
+}
+
+// Location describes the entry, e.g. HKLM\SOFTWARE\...\LockScreenImage.
+func (e Entry) Location() string {
+	return fmt.Sprintf(`%s\%s\%s`, e.Root, e.Path, e.Name)
+}
+
+// has reports whether the value is already journaled. Only the first state is
+// kept, since that is the one to return to.
+func (j *Journal) has(root, path, name string) bool {
+	for _, e := range j.Entries {
+		if e.Root == root && e.Path == path && e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Record captures the current state of the named values under root\path before
+// they are modified. Values already in the journal are left untouched.
+func Record(root registry.Key, path string, names ...string) error {
+	rootStr, err := rootName(root)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	j, err := load()
+	if err != nil {
+		return err
+	}
+
+	// Only a missing key means the tools create it; any other failure would
+	// record a wrong previous state
+	key, keyErr := winapi.Reg.OpenKey(root, path, registry.QUERY_VALUE)
+	if keyErr != nil && keyErr != registry.ErrNotExist {
+		return fmt.Errorf(`failed to open %s\%s: %w`, rootStr, path, keyErr)
+	}
+	if keyErr == nil {
+		defer key.Close()
+	}
+
+	changed := false
+	for _, name := range names {
+		if j.has(rootStr, path, name) {
+			continue
+		}
+
+		entry := Entry{Root: rootStr, Path: path, Name: name, RecordedAt: time.Now()}
+		if keyErr != nil {
+			entry.KeyCreated = true
+		} else {
+			entry.Existed, err = readValue(key, &entry)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", entry.Location(), err)
+			}
+		}
+
+		j.Entries = append(j.Entries, entry)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return j.save()
+}
+
+// readValue stores the value's type and data in the entry. Returns false if the value doesn't exist.
+func readValue(key winapi.RegistryKey, entry *Entry) (bool, error) {
+	_, valType, err := key.GetValue(entry.Name, nil)
+	if err == registry.ErrNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	entry.Type = valType
+	switch valType {
+	case registry.SZ, registry.EXPAND_SZ:
+		entry.String, _, err = key.GetStringValue(entry.Name)
+	case registry.MULTI_SZ:
+		entry.Strings, _, err = key.GetStringsValue(entry.Name)
+	case registry.DWORD, registry.QWORD:
+		entry.Integer, _, err = key.GetIntegerValue(entry.Name)
+	default:
+		entry.Binary, _, err = key.GetBinaryValue(entry.Name)
+	}
+	return true, err
+}
+
+// writeValue restores a previously recorded value.
+func writeValue(key winapi.RegistryKey, e Entry) error {
+	switch e.Type {
+	case registry.SZ:
+		return key.SetStringValue(e.Name, e.String)
+	case registry.EXPAND_SZ:
+		return key.SetExpandStringValue(e.Name, e.String)
+	case registry.MULTI_SZ:
+		return key.SetStringsValue(e.Name, e.Strings)
+	case registry.DWORD:
+		return key.SetDWordValue(e.Name, uint32(e.Integer))
+	case registry.QWORD:
+		return key.SetQWordValue(e.Name, e.Integer)
+	default:
+		return key.SetBinaryValue(e.Name, e.Binary)
+	}
+}
+
+// undoEntry returns one value to its recorded state.
+func undoEntry(e Entry) error {
+	root, err := rootKey(e.Root)
+	if err != nil {
+		return err
+	}
+
+	key, err := winapi.Reg.OpenKey(root, e.Path, registry.SET_VALUE)
+	if err != nil {
+		if err == registry.ErrNotExist && !e.Existed {
+			// Already gone - nothing to undo
+			return nil
+		}
+		return err
+	}
+	defer key.Close()
+
+	if !e.Existed {
+		err = key.DeleteValue(e.Name)
+		if err == registry.ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	return writeValue(key, e)
+}
+
+// removeIfEmpty deletes a key that was created by the tools once it holds no values or subkeys.
+func removeIfEmpty(e Entry) {
+	root, err := rootKey(e.Root)
+	if err != nil {
+		return
+	}
+
+	key, err := winapi.Reg.OpenKey(root, e.Path, registry.QUERY_VALUE|registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return
+	}
+	info, err := key.Stat()
+	key.Close()
+	if err != nil || info.ValueCount > 0 || info.SubKeyCount > 0 {
+		return
+	}
+
+	winapi.Reg.DeleteKey(root, e.Path)
+}
+
+// Undo replays the journal in reverse, restoring every value to the state it
+// had before the tools first touched it. Entries that were reverted are removed
+// from the journal; failures are kept so the undo can be retried.
+// HKCU entries are restored for the user running the undo.
+func Undo() (reverted []string, err error) {
+	return Revert(nil)
+}
+
+// Has reports whether any journaled entry matches.
+func Has(match func(Entry) bool) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	j, err := load()
+	if err != nil {
+		return false
+	}
+	for _, e := range j.Entries {
+		if match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the recorded previous state of a value, if it was journaled.
+func Lookup(root registry.Key, path, name string) (Entry, bool) {
+	rootStr, err := rootName(root)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	j, err := load()
+	if err != nil {
+		return Entry{}, false
+	}
+	for _, e := range j.Entries {
+		if e.Root == rootStr && e.Path == path && e.Name == name {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Revert works like Undo but only for the entries match selects (all of them
+// when match is nil); the others stay in the journal.
+func Revert(match func(Entry) bool) (reverted []string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	j, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	var remaining []Entry
+	var lastErr error
+	undone := make([]bool, len(j.Entries))
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		e := j.Entries[i]
+		if match != nil && !match(e) {
+			remaining = append([]Entry{e}, remaining...)
+			continue
+		}
+		if undoErr := undoEntry(e); undoErr != nil {
+			lastErr = fmt.Errorf("failed to restore %s: %w", e.Location(), undoErr)
+			remaining = append([]Entry{e}, remaining...)
+			continue
+		}
+		undone[i] = true
+		reverted = append(reverted, e.Location())
+	}
+
+	// Remove keys the tools created, deepest first so parents can become
+	// empty. Keys of entries that failed stay for the retry
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		if e := j.Entries[i]; e.KeyCreated && undone[i] {
+			removeIfEmpty(e)
+		}
+	}
+
+	j.Entries = remaining
+	if saveErr := j.save(); saveErr != nil && lastErr == nil {
+		lastErr = saveErr
+	}
+	return reverted, lastErr
+}
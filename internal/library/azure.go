@@ -0,0 +1,138 @@
+package library
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// azureAPIVersion is the Blob service REST API version we request.
+const azureAPIVersion = "2021-08-06"
+
+// azureBackend reads from an Azure Blob Storage container authorized with a
+// shared access signature (or anonymously for public containers).
+type azureBackend struct {
+	account   string
+	container string
+	endpoint  string
+	prefix    string
+	sasToken  string
+}
+
+func newAzureBackend(cfg *config.LibraryConfig) (*azureBackend, error) {
+	if cfg.Account == "" || cfg.Container == "" {
+		return nil, fmt.Errorf("library %q: account and container are required for azure", cfg.Name)
+	}
+
+	b := &azureBackend{
+		account:   cfg.Account,
+		container: cfg.Container,
+		endpoint:  strings.TrimSuffix(cfg.Endpoint, "/"),
+		prefix:    cfg.Prefix,
+		sasToken:  strings.TrimPrefix(envOr(cfg.SASToken, "AZURE_STORAGE_SAS_TOKEN"), "?"),
+	}
+	if b.endpoint == "" {
+		b.endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", b.account)
+	}
+	return b, nil
+}
+
+func (b *azureBackend) Name() string {
+	return fmt.Sprintf("azure://%s/%s/%s", b.account, b.container, b.prefix)
+}
+
+// azureListResult is the subset of the List Blobs response we use.
+type azureListResult struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (b *azureBackend) List() ([]string, error) {
+	var names []string
+	marker := ""
+
+	for {
+		query := url.Values{}
+		query.Set("restype", "container")
+		query.Set("comp", "list")
+		if b.prefix != "" {
+			query.Set("prefix", b.prefix)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+
+		resp, err := b.get("/"+b.container, query)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse(resp, "list "+b.Name()); err != nil {
+			return nil, err
+		}
+
+		var result azureListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse blob listing: %w", err)
+		}
+
+		for _, blob := range result.Blobs.Blob {
+			names = append(names, blob.Name)
+		}
+		if result.NextMarker == "" {
+			break
+		}
+		marker = result.NextMarker
+	}
+
+	return names, nil
+}
+
+func (b *azureBackend) Open(key string) (io.ReadCloser, error) {
+	resp, err := b.get("/"+b.container+"/"+(&url.URL{Path: key}).EscapedPath(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, "fetch "+key); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// get performs a GET request with the SAS token appended to the query string.
+func (b *azureBackend) get(path string, query url.Values) (*http.Response, error) {
+	rawQuery := query.Encode()
+	if b.sasToken != "" {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += b.sasToken
+	}
+
+	reqURL := b.endpoint + path
+	if rawQuery != "" {
+		reqURL += "?" + rawQuery
+	}
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", b.endpoint, err)
+	}
+	return resp, nil
+}
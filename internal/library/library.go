@@ -0,0 +1,79 @@
+// Package library lists and fetches wallpapers from centrally managed storage
+// (Amazon S3, Azure Blob Storage and WebDAV shares).
+package library
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// HTTPTimeout bounds every request made to a storage backend.
+const HTTPTimeout = 2 * time.Minute
+
+// Backend lists and fetches objects from a wallpaper library.
+type Backend interface {
+	// Name returns a short description used in log output.
+	Name() string
+	// List returns the keys of all objects under the configured prefix.
+	List() ([]string, error)
+	// Open returns the content of the object with the given key.
+	// The caller must close the returned reader.
+	Open(key string) (io.ReadCloser, error)
+}
+
+// New creates the backend described by cfg.
+func New(cfg *config.LibraryConfig) (Backend, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "s3":
+		return newS3Backend(cfg)
+	case "azure", "azureblob", "blob":
+		return newAzureBackend(cfg)
+	case "webdav":
+		return newWebDAVBackend(cfg)
+	default:
+		return nil, fmt.Errorf("library %q: unknown type %q (expected s3, azure or webdav)", cfg.Name, cfg.Type)
+	}
+}
+
+// httpClient is shared by all backends.
+var httpClient = &http.Client{Timeout: HTTPTimeout}
+
+// firstNonEmpty returns the first value that isn't empty, used to prefer config
+// values over environment variables.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// envOr returns value if set, otherwise the named environment variable.
+func envOr(value, envName string) string {
+	return firstNonEmpty(value, os.Getenv(envName))
+}
+
+// checkResponse turns a non-2xx response into an error, closing the body.
+func checkResponse(resp *http.Response, what string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := strings.TrimSpace(string(body))
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%s: access denied (HTTP %d) - check the library credentials: %s", what, resp.StatusCode, msg)
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: not found (HTTP 404)", what)
+	}
+	return fmt.Errorf("%s: HTTP %d: %s", what, resp.StatusCode, msg)
+}
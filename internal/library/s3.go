@@ -0,0 +1,243 @@
+package library
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body, used for GET requests.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Backend reads from an S3 bucket (or an S3-compatible service) using
+// path-style requests signed with AWS Signature Version 4.
+type s3Backend struct {
+	bucket       string
+	region       string
+	endpoint     string
+	prefix       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func newS3Backend(cfg *config.LibraryConfig) (*s3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("library %q: bucket is required for s3", cfg.Name)
+	}
+
+	b := &s3Backend{
+		bucket:       cfg.Bucket,
+		region:       firstNonEmpty(cfg.Region, "us-east-1"),
+		endpoint:     strings.TrimSuffix(cfg.Endpoint, "/"),
+		prefix:       cfg.Prefix,
+		accessKey:    envOr(cfg.AccessKeyID, "AWS_ACCESS_KEY_ID"),
+		secretKey:    envOr(cfg.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+		sessionToken: envOr(cfg.SessionToken, "AWS_SESSION_TOKEN"),
+	}
+	if b.endpoint == "" {
+		b.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", b.region)
+	}
+	return b, nil
+}
+
+func (b *s3Backend) Name() string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, b.prefix)
+}
+
+// s3ListResult is the subset of the ListObjectsV2 response we use.
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b *s3Backend) List() ([]string, error) {
+	var keys []string
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if b.prefix != "" {
+			query.Set("prefix", b.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := b.do("/"+b.bucket, query)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse(resp, "list "+b.Name()); err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse S3 listing: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	return keys, nil
+}
+
+func (b *s3Backend) Open(key string) (io.ReadCloser, error) {
+	resp, err := b.do("/"+b.bucket+"/"+s3EscapePath(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, "fetch "+key); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do performs a signed GET request for the given path and query.
+func (b *s3Backend) do(path string, query url.Values) (*http.Response, error) {
+	rawQuery := s3CanonicalQuery(query)
+	reqURL := b.endpoint + path
+	if rawQuery != "" {
+		reqURL += "?" + rawQuery
+	}
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Anonymous access works for public buckets; sign whenever we have keys
+	if b.accessKey != "" && b.secretKey != "" {
+		b.sign(req, path, rawQuery, time.Now().UTC())
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", b.endpoint, err)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to a GET request.
+func (b *s3Backend) sign(req *http.Request, path, rawQuery string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if b.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", b.sessionToken)
+	}
+
+	// Canonical headers must be lowercase and sorted
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": emptyPayloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if b.sessionToken != "" {
+		headers["x-amz-security-token"] = b.sessionToken
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		path,
+		rawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.secretKey), date)
+	signingKey = hmacSHA256(signingKey, b.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encodes query parameters sorted by key with SigV4 escaping.
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything except the SigV4 unreserved characters.
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// s3EscapePath escapes each segment of an object key but keeps the slashes.
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,143 @@
+package library
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// webdavBackend reads from a WebDAV share using PROPFIND listings and
+// optional HTTP basic authentication.
+type webdavBackend struct {
+	base     *url.URL
+	username string
+	password string
+}
+
+func newWebDAVBackend(cfg *config.LibraryConfig) (*webdavBackend, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("library %q: url is required for webdav", cfg.Name)
+	}
+
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("library %q: invalid url: %w", cfg.Name, err)
+	}
+	if cfg.Prefix != "" {
+		base.Path = path.Join(base.Path, cfg.Prefix)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	return &webdavBackend{
+		base:     base,
+		username: envOr(cfg.Username, "WEBDAV_USERNAME"),
+		password: envOr(cfg.Password, "WEBDAV_PASSWORD"),
+	}, nil
+}
+
+func (b *webdavBackend) Name() string {
+	return b.base.Redacted()
+}
+
+// webdavMultistatus is the subset of a PROPFIND response we use.
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+
+// List returns the files directly inside the configured folder.
+// Keys are paths relative to the folder.
+func (b *webdavBackend) List() ([]string, error) {
+	req, err := http.NewRequest("PROPFIND", b.base.String(), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	b.authorize(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", b.Name(), err)
+	}
+	if err := checkResponse(resp, "list "+b.Name()); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse WebDAV listing: %w", err)
+	}
+
+	var keys []string
+	for _, r := range result.Responses {
+		isCollection := false
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType.Collection != nil {
+				isCollection = true
+			}
+		}
+		if isCollection {
+			continue
+		}
+
+		// Hrefs may be absolute URLs or absolute paths; make them relative to the folder
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimPrefix(href.Path, b.base.Path)
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		keys = append(keys, name)
+	}
+
+	return keys, nil
+}
+
+func (b *webdavBackend) Open(key string) (io.ReadCloser, error) {
+	target := *b.base
+	target.Path = b.base.Path + key
+
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	b.authorize(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", b.Name(), err)
+	}
+	if err := checkResponse(resp, "fetch "+key); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (b *webdavBackend) authorize(req *http.Request) {
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+}
@@ -0,0 +1,92 @@
+package loginscreen
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
+	"github.com/backgroundchanger/internal/winapi/winapitest"
+)
+
+// systemKey holds DisableLogonBackgroundImage, set by the Group Policy method.
+const systemKey = `SOFTWARE\Policies\Microsoft\Windows\System`
+
+// useFakes runs the test on the fake elevated Enterprise machine, with the
+// backups in a temporary directory.
+func useFakes(t *testing.T) *winapi.FakeRegistry {
+	t.Helper()
+
+	fakes := winapitest.Use(t)
+	oldBackupDir := BackupDir
+	BackupDir = t.TempDir()
+	t.Cleanup(func() { BackupDir = oldBackupDir })
+	return fakes.Reg
+}
+
+// renderBackground writes a plain background the way the service does before
+// applying it.
+func renderBackground(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "background.jpg")
+	if err := SaveImage(CreateDefaultBackground(320, 200), path); err != nil {
+		t.Fatalf("SaveImage: %v", err)
+	}
+	return path
+}
+
+func TestApplyUndo(t *testing.T) {
+	reg := useFakes(t)
+	const original = `C:\Windows\Web\Screen\img100.jpg`
+	reg.Set(registry.LOCAL_MACHINE, gpoKey, "LockScreenImage", winapi.RegistryValue{Type: registry.SZ, String: original})
+
+	path := renderBackground(t)
+	if err := SetLoginScreenImage(context.Background(), path, config.ImageLimitsConfig{}); err != nil {
+		t.Fatalf("SetLoginScreenImage: %v", err)
+	}
+
+	// Both registry methods apply on an elevated Enterprise machine
+	if v, _ := reg.Value(registry.LOCAL_MACHINE, cspKey, "LockScreenImagePath"); v.String != path {
+		t.Errorf("LockScreenImagePath = %q, want %q", v.String, path)
+	}
+	if v, _ := reg.Value(registry.LOCAL_MACHINE, cspKey, "LockScreenImageStatus"); v.Type != registry.DWORD || v.Integer != 1 {
+		t.Errorf("LockScreenImageStatus = %+v, want DWORD 1", v)
+	}
+	if v, _ := reg.Value(registry.LOCAL_MACHINE, gpoKey, "LockScreenImage"); v.String != path {
+		t.Errorf("LockScreenImage = %q, want %q", v.String, path)
+	}
+	if v, ok := reg.Value(registry.LOCAL_MACHINE, systemKey, "DisableLogonBackgroundImage"); !ok || v.Integer != 0 {
+		t.Errorf("DisableLogonBackgroundImage = %+v, %v, want 0", v, ok)
+	}
+
+	if _, err := journal.Undo(); err != nil {
+		t.Fatalf("journal.Undo: %v", err)
+	}
+	if v, _ := reg.Value(registry.LOCAL_MACHINE, gpoKey, "LockScreenImage"); v.String != original {
+		t.Errorf("LockScreenImage after undo = %q, want %q", v.String, original)
+	}
+	for _, name := range []string{"LockScreenImagePath", "LockScreenImageUrl", "LockScreenImageStatus"} {
+		if _, ok := reg.Value(registry.LOCAL_MACHINE, cspKey, name); ok {
+			t.Errorf("%s left after undo", name)
+		}
+	}
+	if _, ok := reg.Value(registry.LOCAL_MACHINE, systemKey, "DisableLogonBackgroundImage"); ok {
+		t.Error("DisableLogonBackgroundImage left after undo")
+	}
+	if journal.Exists() {
+		t.Error("journal not empty after undo")
+	}
+}
+
+func TestApplyMissingImage(t *testing.T) {
+	useFakes(t)
+
+	if err := SetLoginScreenImage(context.Background(), filepath.Join(t.TempDir(), "missing.jpg"), config.ImageLimitsConfig{}); err == nil {
+		t.Fatal("SetLoginScreenImage succeeded for a missing image")
+	}
+}
@@ -0,0 +1,137 @@
+package loginscreen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackgroundCacheFilePattern names the files holding the decoded, scaled
+// background as raw RGBA pixels, one per render size, so a re-render with
+// unchanged background (every lock) only has to draw the panels and encode, not
+// decode and scale a 4K/8K source again. Keeping one file per size stops the
+// renders for other resolutions (see the service's display variants) from
+// overwriting each other's cache.
+const BackgroundCacheFilePattern = "background_cache_%dx%d.rgba"
+
+// backgroundCacheMagic identifies the cache file format. Version 2 holds
+// backgrounds converted to sRGB.
+const backgroundCacheMagic = "BGRC2\n"
+
+// backgroundCacheKey identifies the source a cached background was made from.
+type backgroundCacheKey struct {
+	Source  string    `json:"source"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+}
+
+// BackgroundCachePath returns the path of the background cache file for a
+// render size.
+func BackgroundCachePath(width, height int) string {
+	return filepath.Join(BackupDir, fmt.Sprintf(BackgroundCacheFilePattern, width, height))
+}
+
+// sourceCacheKey builds the cache key for a source image; Width and Height are
+// filled in once the render size is known.
+func sourceCacheKey(imagePath string) (backgroundCacheKey, error) {
+	absPath, err := filepath.Abs(imagePath)
+	if err != nil {
+		return backgroundCacheKey{}, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return backgroundCacheKey{}, err
+	}
+	return backgroundCacheKey{Source: absPath, Size: info.Size(), ModTime: info.ModTime().UTC()}, nil
+}
+
+// loadCachedBackground reads the cached background into a buffer from
+// newBuffer if it was made from the same source at the same size.
+func loadCachedBackground(key backgroundCacheKey, newBuffer func(width, height int) *image.RGBA) (*image.RGBA, bool) {
+	file, err := os.Open(BackgroundCachePath(key.Width, key.Height))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(backgroundCacheMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != backgroundCacheMagic {
+		return nil, false
+	}
+	header, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, false
+	}
+
+	var cached backgroundCacheKey
+	if json.Unmarshal(header, &cached) != nil || !cached.ModTime.Equal(key.ModTime) {
+		return nil, false
+	}
+	cached.ModTime = key.ModTime
+	if cached != key {
+		return nil, false
+	}
+
+	dst := newBuffer(key.Width, key.Height)
+	if _, err := io.ReadFull(reader, dst.Pix); err != nil {
+		return nil, false
+	}
+	return dst, true
+}
+
+// saveCachedBackground writes the pristine background (before any panels are
+// drawn) for the next run. Failures only cost speed, so they are ignored.
+func saveCachedBackground(key backgroundCacheKey, img *image.RGBA) {
+	header, err := json.Marshal(key)
+	if err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(backgroundCacheMagic)
+	buf.Write(header)
+	buf.WriteByte('\n')
+
+	path := BackgroundCachePath(key.Width, key.Height)
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return
+	}
+	_, err = file.Write(buf.Bytes())
+	if err == nil {
+		_, err = file.Write(img.Pix)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	os.Rename(tmpPath, path)
+}
+
+// RemoveBackgroundCache deletes the cached backgrounds of every size, and the
+// single background_cache.rgba of earlier versions.
+func RemoveBackgroundCache() error {
+	paths, err := filepath.Glob(filepath.Join(BackupDir, "background_cache*.rgba"))
+	if err != nil {
+		return fmt.Errorf("failed to remove background cache: %w", err)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove background cache: %w", err)
+		}
+	}
+	return nil
+}
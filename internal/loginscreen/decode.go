@@ -0,0 +1,103 @@
+package loginscreen
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/imageconv"
+)
+
+// checkImageConfig rejects an image whose header declares more pixels than the
+// limits allow, before any pixel memory is allocated.
+func checkImageConfig(cfg image.Config, format string, limits config.ImageLimitsConfig) error {
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return errs.Mark(errs.ErrImageRejected, fmt.Errorf("invalid %s dimensions %dx%d", format, cfg.Width, cfg.Height))
+	}
+	if cfg.Width > limits.Dimension() || cfg.Height > limits.Dimension() {
+		return errs.Mark(errs.ErrImageRejected, fmt.Errorf("%s is %dx%d, larger than the %d pixel limit",
+			format, cfg.Width, cfg.Height, limits.Dimension()))
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > limits.Pixels() {
+		return errs.Mark(errs.ErrImageRejected, fmt.Errorf("%s is %dx%d, more than the %d megapixel limit",
+			format, cfg.Width, cfg.Height, limits.Pixels()/1000000))
+	}
+	return nil
+}
+
+// decodeConfig reads an image header, turning a decoder panic on malformed
+// input into an error.
+func decodeConfig(r io.Reader) (cfg image.Config, format string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errs.Mark(errs.ErrImageRejected, fmt.Errorf("image header could not be decoded: %v", p))
+		}
+	}()
+	return image.DecodeConfig(r)
+}
+
+// decodeImage decodes an image, turning a decoder panic on malformed input
+// into an error. It also returns the image's color profile when it is not
+// sRGB (e.g. Display P3 from a phone), for the caller to convert the pixels
+// with; a profile that can't be read is treated as sRGB.
+func decodeImage(r io.ReadSeeker) (img image.Image, profile *imageconv.Profile, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			img, profile = nil, nil
+			err = errs.Mark(errs.ErrImageRejected, fmt.Errorf("image could not be decoded: %v", p))
+		}
+	}()
+	profile, _ = imageconv.ReadProfile(r)
+	img, _, err = image.Decode(r)
+	return img, profile, err
+}
+
+// openImage opens an image file and checks its size and header against
+// limits. The returned file is positioned at the start.
+func openImage(imagePath string, limits config.ImageLimitsConfig) (*os.File, image.Config, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, image.Config{}, fmt.Errorf("failed to open image: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, image.Config{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if info.Size() > limits.FileBytes() {
+		file.Close()
+		return nil, image.Config{}, errs.Mark(errs.ErrImageRejected, fmt.Errorf("image file is %d MB, larger than the %d MB limit",
+			info.Size()>>20, limits.FileBytes()>>20))
+	}
+
+	cfg, format, err := decodeConfig(file)
+	if err != nil {
+		file.Close()
+		return nil, image.Config{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkImageConfig(cfg, format, limits); err != nil {
+		file.Close()
+		return nil, image.Config{}, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, image.Config{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	return file, cfg, nil
+}
+
+// CheckImageFile verifies that a file is a decodable image within the size and
+// dimension limits, without decoding the pixels. Use it on downloaded
+// files before they are applied.
+func CheckImageFile(imagePath string, limits config.ImageLimitsConfig) error {
+	file, _, err := openImage(imagePath, limits)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,103 @@
+package loginscreen
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/errs"
+)
+
+// fuzzLimits keep the fuzzers from spending their time allocating huge
+// images that the default limits would let through.
+var fuzzLimits = config.ImageLimitsConfig{MaxDimension: 1024, MaxMegapixels: 1}
+
+// addSeedCorpus adds the truncated and malformed images in testdata/decode.
+func addSeedCorpus(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "decode", "*"))
+	if err != nil || len(paths) == 0 {
+		f.Fatalf("no seed corpus in testdata/decode: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+func FuzzDecodeConfig(f *testing.F) {
+	addSeedCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg, format, err := decodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if err := checkImageConfig(cfg, format, fuzzLimits); err != nil && !errors.Is(err, errs.ErrImageRejected) {
+			t.Errorf("limit error is not marked ErrImageRejected: %v", err)
+		}
+	})
+}
+
+func FuzzDecodeImage(f *testing.F) {
+	addSeedCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Only images whose header passes the limits are decoded, as in
+		// openImage
+		cfg, format, err := decodeConfig(bytes.NewReader(data))
+		if err != nil || checkImageConfig(cfg, format, fuzzLimits) != nil {
+			return
+		}
+		img, _, err := decodeImage(bytes.NewReader(data))
+		if err == nil && img == nil {
+			t.Fatal("no image and no error")
+		}
+	})
+}
+
+// TestDecodeSeedCorpus checks the outcome for each seed: the valid images
+// decode, the oversized and zero-sized headers are rejected before decoding,
+// and the rest fail without a panic.
+func TestDecodeSeedCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "decode", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, format, err := decodeConfig(bytes.NewReader(data))
+			if err == nil {
+				err = checkImageConfig(cfg, format, config.ImageLimitsConfig{})
+			}
+			if strings.Contains(name, "_dimensions") {
+				if !errors.Is(err, errs.ErrImageRejected) {
+					t.Fatalf("header not rejected: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				_, _, err = decodeImage(bytes.NewReader(data))
+			}
+			if strings.HasPrefix(name, "valid") && err != nil {
+				t.Errorf("failed to decode: %v", err)
+			}
+			if !strings.HasPrefix(name, "valid") && err == nil {
+				t.Error("malformed image decoded without an error")
+			}
+		})
+	}
+}
@@ -2,17 +2,24 @@
 package loginscreen
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/jpeg"
-	"image/png"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/imageconv"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
 )
 
 var (
@@ -38,7 +45,7 @@ func HasBackup() bool {
 func GetBackupImage() (string, error) {
 	backupPath := GetBackupPath()
 	if _, err := os.Stat(backupPath); err != nil {
-		return "", fmt.Errorf("backup does not exist: %v", err)
+		return "", errs.Mark(errs.ErrNoImage, fmt.Errorf("backup does not exist: %w", err))
 	}
 	return backupPath, nil
 }
@@ -48,13 +55,13 @@ func BackupOriginalImage(imagePath string) error {
 	// Create backup directory if it doesn't exist
 	err := os.MkdirAll(BackupDir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create backup directory: %v", err)
+		return fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
 	// Open source file
 	src, err := os.Open(imagePath)
 	if err != nil {
-		return fmt.Errorf("failed to open source image: %v", err)
+		return fmt.Errorf("failed to open source image: %w", err)
 	}
 	defer src.Close()
 
@@ -62,14 +69,14 @@ func BackupOriginalImage(imagePath string) error {
 	backupPath := GetBackupPath()
 	dst, err := os.Create(backupPath)
 	if err != nil {
-		return fmt.Errorf("failed to create backup file: %v", err)
+		return fmt.Errorf("failed to create backup file: %w", err)
 	}
 	defer dst.Close()
 
 	// Copy the file
 	_, err = io.Copy(dst, src)
 	if err != nil {
-		return fmt.Errorf("failed to copy image to backup: %v", err)
+		return fmt.Errorf("failed to copy image to backup: %w", err)
 	}
 
 	return nil
@@ -77,6 +84,9 @@ func BackupOriginalImage(imagePath string) error {
 
 // InvalidateBackup removes the backup file so a new one will be created.
 func InvalidateBackup() error {
+	// The cached background was made from the old backup
+	RemoveBackgroundCache()
+
 	backupPath := GetBackupPath()
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
 		// Already doesn't exist, nothing to do
@@ -89,7 +99,7 @@ func InvalidateBackup() error {
 // It checks multiple locations in priority order.
 func GetCurrentLoginScreenImage() (string, error) {
 	// Priority 1: Check Group Policy registry for LockScreenImage
-	key, err := registry.OpenKey(
+	key, err := winapi.Reg.OpenKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Policies\Microsoft\Windows\Personalization`,
 		registry.QUERY_VALUE,
@@ -113,7 +123,7 @@ func GetCurrentLoginScreenImage() (string, error) {
 	}
 
 	// Priority 3: Check PersonalizationCSP registry
-	cspKey, err := registry.OpenKey(
+	cspKey, err := winapi.Reg.OpenKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
 		registry.QUERY_VALUE,
@@ -159,76 +169,75 @@ func GetCurrentLoginScreenImage() (string, error) {
 	}
 
 	// No existing login screen found
-	return "", fmt.Errorf("no existing login screen image found")
+	return "", errs.Mark(errs.ErrNoImage, fmt.Errorf("no existing login screen image found"))
 }
 
 // SetLoginScreenImage sets the given image as the Windows login screen background.
-func SetLoginScreenImage(imagePath string) error {
+// Cancelling ctx stops before the next method and kills a running PowerShell call.
+func SetLoginScreenImage(ctx context.Context, imagePath string, limits config.ImageLimitsConfig) error {
+	return SetLoginScreenImageExcept(ctx, imagePath, limits)
+}
+
+// SetLoginScreenImageExcept is SetLoginScreenImage without the named
+// capability methods, e.g. the machine-wide fallbacks once the lock screen of
+// every signed-in user was set in their own session.
+func SetLoginScreenImageExcept(ctx context.Context, imagePath string, limits config.ImageLimitsConfig, skip ...string) error {
 	// Convert to absolute path
 	absPath, err := filepath.Abs(imagePath)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
 	// Ensure the image exists
 	if _, err := os.Stat(absPath); err != nil {
-		return fmt.Errorf("image file does not exist: %v", err)
+		return fmt.Errorf("image file does not exist: %w", err)
+	}
+
+	// Only try the methods this edition, build and context can use
+	caps := capability.Detect()
+	methods := []struct {
+		name string
+		fn   func(string) error
+	}{
+		// PersonalizationCSP (MDM-style, works as SYSTEM for sign-in screen)
+		{capability.MethodPersonalizationCSP, setLoginScreenViaPersonalizationCSP},
+		// Group Policy Registry (enterprise method for sign-in screen)
+		{capability.MethodGroupPolicy, setLoginScreenViaGroupPolicy},
+		// Replace Windows default screen images (most aggressive)
+		{capability.MethodDefaultImages, func(path string) error { return setLoginScreenViaDefaultImages(path, limits) }},
+		// OOBE background folder (older Windows versions)
+		{capability.MethodOOBE, func(path string) error { return setLoginScreenViaOOBE(path, limits) }},
+		// WinRT API (only works in user context, not as SYSTEM)
+		{capability.MethodWinRT, func(path string) error { return setLoginScreenViaWinRT(ctx, path) }},
 	}
 
-	// Try multiple methods
 	var anySuccess bool
 	var lastError error
-
-	// Method 1: PersonalizationCSP (MDM-style, works as SYSTEM for sign-in screen)
-	err = setLoginScreenViaPersonalizationCSP(absPath)
-	if err != nil {
-		lastError = err
-	} else {
-		anySuccess = true
-	}
-
-	// Method 2: Group Policy Registry (enterprise method for sign-in screen)
-	err = setLoginScreenViaGroupPolicy(absPath)
-	if err != nil {
-		if lastError == nil {
-			lastError = err
+	var attempted int
+	for _, method := range methods {
+		if !caps.Supports(method.name) || slices.Contains(skip, method.name) {
+			continue
 		}
-	} else {
-		anySuccess = true
-	}
-
-	// Method 3: Replace Windows default screen images (most aggressive)
-	err = setLoginScreenViaDefaultImages(absPath)
-	if err != nil {
-		if lastError == nil {
-			lastError = err
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-	} else {
-		anySuccess = true
-	}
-
-	// Method 4: OOBE background folder (older Windows versions)
-	err = setLoginScreenViaOOBE(absPath)
-	if err != nil {
-		if lastError == nil {
-			lastError = err
+		attempted++
+		err = method.fn(absPath)
+		if err != nil {
+			if lastError == nil {
+				lastError = fmt.Errorf("%s: %w", method.name, err)
+			}
+		} else {
+			anySuccess = true
 		}
-	} else {
-		anySuccess = true
 	}
 
-	// Method 5: WinRT API (only works in user context, not as SYSTEM)
-	err = setLoginScreenViaWinRT(absPath)
-	if err != nil {
-		if lastError == nil {
-			lastError = err
-		}
-	} else {
-		anySuccess = true
+	if attempted == 0 {
+		return errs.Mark(errs.ErrMethodUnsupported, fmt.Errorf("no login screen method is supported on this system (%s, build %d)",
+			caps.OS.EditionID, caps.OS.Build))
 	}
-
 	if !anySuccess {
-		return fmt.Errorf("all login screen methods failed, last error: %v", lastError)
+		return errs.Classify(fmt.Errorf("all login screen methods failed, last error: %w", lastError))
 	}
 
 	return nil
@@ -237,32 +246,36 @@ func SetLoginScreenImage(imagePath string) error {
 // setLoginScreenViaPersonalizationCSP uses the MDM/Intune registry method.
 // This is designed for enterprise deployment and works well from SYSTEM context.
 func setLoginScreenViaPersonalizationCSP(absPath string) error {
-	key, _, err := registry.CreateKey(
+	// Journal the previous values so they can be restored (best effort)
+	journal.Record(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
+		"LockScreenImagePath", "LockScreenImageUrl", "LockScreenImageStatus")
+
+	key, _, err := winapi.Reg.CreateKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`,
 		registry.ALL_ACCESS,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create PersonalizationCSP key: %v", err)
+		return fmt.Errorf("failed to create PersonalizationCSP key: %w", err)
 	}
 	defer key.Close()
 
 	// Set the lock screen image path
 	err = key.SetStringValue("LockScreenImagePath", absPath)
 	if err != nil {
-		return fmt.Errorf("failed to set LockScreenImagePath: %v", err)
+		return fmt.Errorf("failed to set LockScreenImagePath: %w", err)
 	}
 
 	// Set the URL (same as path for local files)
 	err = key.SetStringValue("LockScreenImageUrl", absPath)
 	if err != nil {
-		return fmt.Errorf("failed to set LockScreenImageUrl: %v", err)
+		return fmt.Errorf("failed to set LockScreenImageUrl: %w", err)
 	}
 
 	// Set status to 1 (enabled)
 	err = key.SetDWordValue("LockScreenImageStatus", 1)
 	if err != nil {
-		return fmt.Errorf("failed to set LockScreenImageStatus: %v", err)
+		return fmt.Errorf("failed to set LockScreenImageStatus: %w", err)
 	}
 
 	return nil
@@ -270,16 +283,16 @@ func setLoginScreenViaPersonalizationCSP(absPath string) error {
 
 // setLoginScreenViaDefaultImages replaces the Windows default lock screen images.
 // This is the most aggressive method - directly overwrites system default images.
-func setLoginScreenViaDefaultImages(absPath string) error {
+func setLoginScreenViaDefaultImages(absPath string, limits config.ImageLimitsConfig) error {
 	systemRoot := os.Getenv("SystemRoot")
 	if systemRoot == "" {
 		systemRoot = `C:\Windows`
 	}
 
 	// Load the source image
-	srcImg, err := LoadImage(absPath)
+	srcImg, err := LoadImage(absPath, limits)
 	if err != nil {
-		return fmt.Errorf("failed to load source image: %v", err)
+		return fmt.Errorf("failed to load source image: %w", err)
 	}
 
 	// Windows default lock screen images location
@@ -306,116 +319,121 @@ func setLoginScreenViaDefaultImages(absPath string) error {
 // takeOwnership attempts to take ownership of a file (for replacing system files)
 func takeOwnership(filePath string) {
 	// Use takeown and icacls to get write access to protected system files
-	exec.Command("takeown", "/f", filePath).Run()
-	exec.Command("icacls", filePath, "/grant", "Administrators:F").Run()
+	ctx := context.Background()
+	winapi.Commands.Run(ctx, "takeown", "/f", filePath)
+	winapi.Commands.Run(ctx, "icacls", filePath, "/grant", "Administrators:F")
 }
 
 // setLoginScreenViaGroupPolicy sets the login screen using Group Policy registry keys.
 func setLoginScreenViaGroupPolicy(absPath string) error {
+	// Journal the previous values so they can be restored (best effort)
+	journal.Record(registry.LOCAL_MACHINE, `SOFTWARE\Policies\Microsoft\Windows\Personalization`, "LockScreenImage")
+	journal.Record(registry.LOCAL_MACHINE, `SOFTWARE\Policies\Microsoft\Windows\System`, "DisableLogonBackgroundImage")
+
 	// Open or create the Personalization policy key
-	key, _, err := registry.CreateKey(
+	key, _, err := winapi.Reg.CreateKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Policies\Microsoft\Windows\Personalization`,
 		registry.ALL_ACCESS,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to open Personalization policy key: %v", err)
+		return fmt.Errorf("failed to open Personalization policy key: %w", err)
 	}
 	defer key.Close()
 
 	// Set LockScreenImage to the image path
 	err = key.SetStringValue("LockScreenImage", absPath)
 	if err != nil {
-		return fmt.Errorf("failed to set LockScreenImage: %v", err)
+		return fmt.Errorf("failed to set LockScreenImage: %w", err)
 	}
 
 	// Also need to ensure DisableLogonBackgroundImage is set to 0 in the System key
-	sysKey, _, err := registry.CreateKey(
+	sysKey, _, err := winapi.Reg.CreateKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Policies\Microsoft\Windows\System`,
 		registry.ALL_ACCESS,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to open System policy key: %v", err)
+		return fmt.Errorf("failed to open System policy key: %w", err)
 	}
 	defer sysKey.Close()
 
 	// Set DisableLogonBackgroundImage to 0 (enable custom background)
 	err = sysKey.SetDWordValue("DisableLogonBackgroundImage", 0)
 	if err != nil {
-		return fmt.Errorf("failed to set DisableLogonBackgroundImage: %v", err)
+		return fmt.Errorf("failed to set DisableLogonBackgroundImage: %w", err)
 	}
 
 	return nil
 }
 
-// setLoginScreenViaOOBE copies the image to the OOBE backgrounds folder.
-func setLoginScreenViaOOBE(absPath string) error {
+// setLoginScreenViaOOBE writes the image to the OOBE backgrounds folder in the
+// sizes and file names older Windows builds require.
+func setLoginScreenViaOOBE(absPath string, limits config.ImageLimitsConfig) error {
 	// Create the backgrounds directory if it doesn't exist
 	systemRoot := os.Getenv("SystemRoot")
 	backgroundsDir := filepath.Join(systemRoot, "System32", "oobe", "info", "backgrounds")
 	err := os.MkdirAll(backgroundsDir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create backgrounds directory: %v", err)
+		return fmt.Errorf("failed to create backgrounds directory: %w", err)
 	}
 
 	// Load the source image
-	srcFile, err := os.Open(absPath)
-	if err != nil {
-		return fmt.Errorf("failed to open source image: %v", err)
-	}
-	defer srcFile.Close()
-
-	// Decode the image to ensure it's valid and can be re-encoded as JPEG
-	img, format, err := image.Decode(srcFile)
+	img, err := LoadImage(absPath, limits)
 	if err != nil {
-		return fmt.Errorf("failed to decode image: %v", err)
+		return err
 	}
 
-	// The target file must be named backgroundDefault.jpg
-	targetPath := filepath.Join(backgroundsDir, "backgroundDefault.jpg")
-
-	// Create the target file
-	dstFile, err := os.Create(targetPath)
+	// Older builds ignore files over 256 KB and look for resolution-specific
+	// names, so write every variant re-encoded to fit
+	err = writeOOBEBackgrounds(img, backgroundsDir)
 	if err != nil {
-		return fmt.Errorf("failed to create target file: %v", err)
-	}
-	defer dstFile.Close()
-
-	// Encode as JPEG
-	if format == "jpeg" || format == "jpg" {
-		// Re-open and copy directly for JPEG
-		srcFile.Seek(0, 0)
-		_, err = io.Copy(dstFile, srcFile)
-	} else {
-		// Convert to JPEG
-		err = jpeg.Encode(dstFile, img, &jpeg.Options{Quality: 90})
-	}
-	if err != nil {
-		return fmt.Errorf("failed to save image: %v", err)
+		return err
 	}
 
 	// Enable OEM background in registry
-	key, _, err := registry.CreateKey(
+	journal.Record(registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Windows\CurrentVersion\Authentication\LogonUI\Background`, "OEMBackground")
+	key, _, err := winapi.Reg.CreateKey(
 		registry.LOCAL_MACHINE,
 		`SOFTWARE\Microsoft\Windows\CurrentVersion\Authentication\LogonUI\Background`,
 		registry.ALL_ACCESS,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to open LogonUI Background key: %v", err)
+		return fmt.Errorf("failed to open LogonUI Background key: %w", err)
 	}
 	defer key.Close()
 
 	err = key.SetDWordValue("OEMBackground", 1)
 	if err != nil {
-		return fmt.Errorf("failed to set OEMBackground: %v", err)
+		return fmt.Errorf("failed to set OEMBackground: %w", err)
 	}
 
 	return nil
 }
 
-// setLoginScreenViaWinRT uses PowerShell and WinRT API to set the lock screen.
-func setLoginScreenViaWinRT(absPath string) error {
+// SetUserLockScreenImage sets the lock screen of the user the process runs as
+// through the WinRT LockScreen API. It is what the session helper runs in each
+// signed-in user's session, since the API does not work as SYSTEM.
+func SetUserLockScreenImage(ctx context.Context, imagePath string) error {
+	absPath, err := filepath.Abs(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return fmt.Errorf("image file does not exist: %w", err)
+	}
+	return setLoginScreenViaWinRT(ctx, absPath)
+}
+
+// setLoginScreenViaWinRT sets the lock screen with the WinRT API, calling it
+// directly and falling back to PowerShell when that fails.
+func setLoginScreenViaWinRT(ctx context.Context, absPath string) error {
+	nativeErr := setLockScreenViaNativeWinRT(absPath)
+	if nativeErr == nil {
+		return nil
+	}
+
 	psScript := fmt.Sprintf(`
 $ErrorActionPreference = "Stop"
 
@@ -444,51 +462,54 @@ $file = Await ([Windows.Storage.StorageFile]::GetFileFromPathAsync($imagePath))
 AwaitAction ([Windows.System.UserProfile.LockScreen]::SetImageFileAsync($file))
 `, absPath)
 
-	cmd := exec.Command("powershell.exe",
+	output, err := winapi.Commands.Run(ctx, "powershell.exe",
 		"-NoProfile",
 		"-ExecutionPolicy", "Bypass",
 		"-Command", psScript,
 	)
-
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("PowerShell WinRT failed: %v\nOutput: %s", err, string(output))
+		return fmt.Errorf("WinRT failed: %v; PowerShell WinRT failed: %w\nOutput: %s", nativeErr, err, string(output))
 	}
 
 	return nil
 }
 
-// LoadImage loads an image from the given path.
-func LoadImage(imagePath string) (image.Image, error) {
-	file, err := os.Open(imagePath)
+// LoadImage loads an image from the given path. Files over the size or
+// dimension limits are rejected before decoding.
+func LoadImage(imagePath string, limits config.ImageLimitsConfig) (image.Image, error) {
+	file, _, err := openImage(imagePath, limits)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open image: %v", err)
+		return nil, err
 	}
 	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	img, profile, err := decodeImage(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	return img, nil
+	return profile.ToSRGB(img), nil
 }
 
-// SaveImage saves an image to the given path as JPEG.
+// JPEGQuality is the quality SaveImage encodes JPEGs with.
+const JPEGQuality = 95
+
+// SaveImage saves an image to the given path as JPEG, or PNG for a .png path,
+// tagged as sRGB.
 func SaveImage(img image.Image, imagePath string) error {
 	file, err := os.Create(imagePath)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %v", err)
+		return fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
 	ext := strings.ToLower(filepath.Ext(imagePath))
 	if ext == ".png" {
-		return png.Encode(file, img)
+		return imageconv.EncodePNG(file, img)
 	}
 
 	// Default to JPEG
-	return jpeg.Encode(file, img, &jpeg.Options{Quality: 95})
+	return imageconv.EncodeJPEG(file, img, &jpeg.Options{Quality: JPEGQuality})
 }
 
 // CreateDefaultBackground creates a solid dark background image.
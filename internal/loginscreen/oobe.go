@@ -0,0 +1,123 @@
+package loginscreen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+
+	"github.com/backgroundchanger/internal/imageconv"
+)
+
+// OOBEMaxFileSize is the largest background file LogonUI on older Windows builds
+// (Windows 7) will load. Larger files are silently ignored.
+const OOBEMaxFileSize = 256 * 1024
+
+// OOBEDefaultMaxWidth caps backgroundDefault.jpg, which is used when no
+// resolution-specific file matches the screen.
+const OOBEDefaultMaxWidth = 1920
+
+// oobeResolutions are the resolution-specific file names LogonUI looks for
+// (background<width>x<height>.jpg), picked by the screen's aspect ratio.
+var oobeResolutions = []struct{ Width, Height int }{
+	{768, 1280},
+	{900, 1440},
+	{960, 1280},
+	{1024, 1280},
+	{1280, 1024},
+	{1024, 768},
+	{1280, 960},
+	{1600, 1200},
+	{1440, 900},
+	{1920, 1200},
+	{1280, 768},
+	{1360, 768},
+	{1920, 1080},
+}
+
+// ResizeCover scales img to fill width x height exactly, cropping the overflow
+// around the center so the aspect ratio is preserved.
+func ResizeCover(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+
+	// Pick the source rectangle with the target aspect ratio
+	crop := b
+	if srcW*height > srcH*width {
+		w := srcH * width / height
+		x := b.Min.X + (srcW-w)/2
+		crop = image.Rect(x, b.Min.Y, x+w, b.Max.Y)
+	} else {
+		h := srcW * height / width
+		y := b.Min.Y + (srcH-h)/2
+		crop = image.Rect(b.Min.X, y, b.Max.X, y+h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+// encodeJPEGUnder encodes img as JPEG no larger than maxSize, lowering the quality
+// first and then the resolution. Returns the encoded bytes.
+func encodeJPEGUnder(img image.Image, maxSize int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	current := img
+	for attempt := 0; attempt < 6; attempt++ {
+		for quality := 90; quality >= 40; quality -= 10 {
+			buf.Reset()
+			err := imageconv.EncodeJPEG(&buf, current, &jpeg.Options{Quality: quality})
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode image: %w", err)
+			}
+			if buf.Len() <= maxSize {
+				return buf.Bytes(), nil
+			}
+		}
+
+		// Still too large at low quality - shrink and try again
+		b := current.Bounds()
+		current = ResizeCover(current, b.Dx()*85/100, b.Dy()*85/100)
+	}
+
+	return nil, fmt.Errorf("could not encode image under %d KB", maxSize/1024)
+}
+
+// writeOOBEBackgrounds writes backgroundDefault.jpg and every resolution-specific
+// background<W>x<H>.jpg into dir, each re-encoded to meet the legacy size limit.
+func writeOOBEBackgrounds(img image.Image, dir string) error {
+	// backgroundDefault keeps the source aspect ratio, capped in width
+	defaultImg := img
+	if b := img.Bounds(); b.Dx() > OOBEDefaultMaxWidth {
+		defaultImg = ResizeCover(img, OOBEDefaultMaxWidth, b.Dy()*OOBEDefaultMaxWidth/b.Dx())
+	}
+
+	data, err := encodeJPEGUnder(defaultImg, OOBEMaxFileSize)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(dir, "backgroundDefault.jpg"), data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write backgroundDefault.jpg: %w", err)
+	}
+
+	var lastErr error
+	for _, res := range oobeResolutions {
+		name := fmt.Sprintf("background%dx%d.jpg", res.Width, res.Height)
+
+		data, err := encodeJPEGUnder(ResizeCover(img, res.Width, res.Height), OOBEMaxFileSize)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, name), data, 0644)
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	return lastErr
+}
@@ -0,0 +1,104 @@
+package loginscreen
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/capability"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// DisableLockScreenOverlays turns off Windows Spotlight, the "fun facts, tips and
+// tricks" text and (on Windows 11) the lock screen widgets, so the rendered image
+// isn't replaced or covered. The previous settings are journaled first so
+// RestoreLockScreenOverlays can put them back. Spotlight and tips are per-user
+// settings and apply to the user running this.
+func DisableLockScreenOverlays(build int) error {
+	journal.Record(registry.CURRENT_USER, capability.ContentDeliveryManagerKey,
+		capability.ValueRotatingLockScreen, capability.ValueRotatingLockScreenOverlay, capability.ValueLockScreenTips)
+
+	key, _, err := winapi.Reg.CreateKey(registry.CURRENT_USER, capability.ContentDeliveryManagerKey, registry.ALL_ACCESS)
+	if err != nil {
+		return fmt.Errorf("failed to open ContentDeliveryManager key: %w", err)
+	}
+	defer key.Close()
+
+	for _, name := range []string{
+		capability.ValueRotatingLockScreen,
+		capability.ValueRotatingLockScreenOverlay,
+		capability.ValueLockScreenTips,
+	} {
+		err = key.SetDWordValue(name, 0)
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	if build < capability.BuildWindows11 {
+		return nil
+	}
+
+	// Widgets are turned off machine-wide through policy
+	journal.Record(registry.LOCAL_MACHINE, capability.WidgetsPolicyKey, capability.ValueAllowNewsAndInterests)
+
+	policyKey, _, err := winapi.Reg.CreateKey(registry.LOCAL_MACHINE, capability.WidgetsPolicyKey, registry.ALL_ACCESS)
+	if err != nil {
+		return fmt.Errorf("failed to open widgets policy key: %w", err)
+	}
+	defer policyKey.Close()
+
+	err = policyKey.SetDWordValue(capability.ValueAllowNewsAndInterests, 0)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", capability.ValueAllowNewsAndInterests, err)
+	}
+
+	return nil
+}
+
+// isOverlayEntry reports whether a journal entry belongs to the overlay settings.
+func isOverlayEntry(e journal.Entry) bool {
+	return (e.Root == journal.RootHKCU && e.Path == capability.ContentDeliveryManagerKey) ||
+		(e.Root == journal.RootHKLM && e.Path == capability.WidgetsPolicyKey)
+}
+
+// HasDisabledLockScreenOverlays reports whether the overlays were disabled by
+// DisableLockScreenOverlays and not restored yet.
+func HasDisabledLockScreenOverlays() bool {
+	return journal.Has(isOverlayEntry)
+}
+
+// RestoreLockScreenOverlays puts back the overlay settings recorded by
+// DisableLockScreenOverlays. Returns the values that were restored.
+func RestoreLockScreenOverlays() ([]string, error) {
+	return journal.Revert(isOverlayEntry)
+}
+
+// ReassertOverSpotlight turns Windows Spotlight off again for a signed-in user
+// (a loaded hive under HKEY_USERS) who switched back to it in Settings, so the
+// lock screen image we set is shown. The previous settings are journaled so the
+// uninstaller can restore them.
+func ReassertOverSpotlight(sid string) error {
+	root := registry.USERS
+	path := sid + `\` + capability.ContentDeliveryManagerKey
+
+	journal.Record(root, path, capability.ValueRotatingLockScreen, capability.ValueRotatingLockScreenOverlay)
+
+	key, err := winapi.Reg.OpenKey(root, path, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open ContentDeliveryManager key: %w", err)
+	}
+	defer key.Close()
+
+	err = key.SetDWordValue(capability.ValueRotatingLockScreen, 0)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", capability.ValueRotatingLockScreen, err)
+	}
+	err = key.SetDWordValue(capability.ValueRotatingLockScreenOverlay, 0)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", capability.ValueRotatingLockScreenOverlay, err)
+	}
+
+	return nil
+}
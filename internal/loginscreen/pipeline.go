@@ -0,0 +1,136 @@
+package loginscreen
+
+import (
+	"fmt"
+	"image"
+	"runtime/debug"
+	"sync"
+
+	"golang.org/x/image/draw"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// MinRenderWidth and MinRenderHeight are the smallest size LoadImageScaled scales
+// to, so the image still looks sharp if the screen resolution changes later.
+const (
+	MinRenderWidth  = 1920
+	MinRenderHeight = 1080
+)
+
+// renderBuffer is reused across runs in the same process (the service renders on
+// every session change) so a new full-size buffer isn't allocated each time.
+var (
+	renderBufferMu sync.Mutex
+	renderBuffer   *image.RGBA
+)
+
+// acquireRenderBuffer returns an RGBA image of the given size, reusing the
+// previous buffer when it is large enough.
+func acquireRenderBuffer(width, height int) *image.RGBA {
+	renderBufferMu.Lock()
+	defer renderBufferMu.Unlock()
+
+	size := width * height * 4
+	if renderBuffer != nil && cap(renderBuffer.Pix) >= size {
+		renderBuffer = &image.RGBA{
+			Pix:    renderBuffer.Pix[:size],
+			Stride: width * 4,
+			Rect:   image.Rect(0, 0, width, height),
+		}
+		return renderBuffer
+	}
+
+	renderBuffer = image.NewRGBA(image.Rect(0, 0, width, height))
+	return renderBuffer
+}
+
+// renderSize returns the size to render a width x height source at for a screen
+// of screenWidth x screenHeight: scaled down (never up) to just cover the screen
+// while keeping the aspect ratio.
+func renderSize(width, height, screenWidth, screenHeight int) (int, int) {
+	if screenWidth < MinRenderWidth {
+		screenWidth = MinRenderWidth
+	}
+	if screenHeight < MinRenderHeight {
+		screenHeight = MinRenderHeight
+	}
+
+	scale := float64(screenWidth) / float64(width)
+	if s := float64(screenHeight) / float64(height); s > scale {
+		scale = s
+	}
+	if scale >= 1 {
+		return width, height
+	}
+
+	return int(float64(width)*scale + 0.5), int(float64(height)*scale + 0.5)
+}
+
+// LoadImageScaled loads an image for rendering on a screen of the given size.
+// Sources larger than the screen (4K/8K wallpapers) are scaled down right after
+// decoding and the full-size decode is released before anything is drawn, so
+// only one screen-sized RGBA buffer is kept. The returned buffer is reused by the
+// next call and overlay functions draw on it in place, touching only the panels.
+//
+// The scaled result is cached on disk; while the source file and screen size are
+// unchanged later runs load the raw pixels instead of decoding and scaling again.
+func LoadImageScaled(imagePath string, screenWidth, screenHeight int, limits config.ImageLimitsConfig) (*image.RGBA, error) {
+	return loadImageScaled(imagePath, screenWidth, screenHeight, limits, acquireRenderBuffer)
+}
+
+// LoadImageScaledCopy is LoadImageScaled into a newly allocated buffer, for
+// extra renders (other screen resolutions) that must not overwrite the shared
+// render buffer the main render was drawn on.
+func LoadImageScaledCopy(imagePath string, screenWidth, screenHeight int, limits config.ImageLimitsConfig) (*image.RGBA, error) {
+	return loadImageScaled(imagePath, screenWidth, screenHeight, limits, func(width, height int) *image.RGBA {
+		return image.NewRGBA(image.Rect(0, 0, width, height))
+	})
+}
+
+func loadImageScaled(imagePath string, screenWidth, screenHeight int, limits config.ImageLimitsConfig,
+	newBuffer func(width, height int) *image.RGBA) (*image.RGBA, error) {
+	key, keyErr := sourceCacheKey(imagePath)
+
+	// Read only the header first to check the limits and size the output buffer
+	file, cfg, err := openImage(imagePath, limits)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	width, height := renderSize(cfg.Width, cfg.Height, screenWidth, screenHeight)
+	key.Width, key.Height = width, height
+	if keyErr == nil {
+		if cached, ok := loadCachedBackground(key, newBuffer); ok {
+			return cached, nil
+		}
+	}
+
+	// JPEGs decode to YCbCr (1.5 bytes per pixel) - cheaper to hold than RGBA
+	src, profile, err := decodeImage(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	dst := newBuffer(width, height)
+	if width == cfg.Width && height == cfg.Height {
+		draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	}
+
+	// Give the full-size decode back to the OS before rendering starts
+	src = nil
+	debug.FreeOSMemory()
+
+	// Wide-gamut sources are converted after scaling, on the smaller buffer
+	if profile != nil {
+		profile.ConvertToSRGB(dst)
+	}
+
+	if keyErr == nil {
+		saveCachedBackground(key, dst)
+	}
+	return dst, nil
+}
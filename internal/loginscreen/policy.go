@@ -0,0 +1,203 @@
+package loginscreen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/errs"
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Registry keys a lock screen policy is found in. The Personalization CSP
+// records the policy Intune sent under mdmPolicyKey, which the tools never
+// write; the values under cspKey and gpoKey are written by the tools too.
+const (
+	mdmPolicyKey = `SOFTWARE\Microsoft\PolicyManager\current\device\Personalization`
+	cspKey       = `SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`
+	gpoKey       = `SOFTWARE\Policies\Microsoft\Windows\Personalization`
+)
+
+// Sources of a managed lock screen policy.
+const (
+	PolicySourceMDM         = "MDM"
+	PolicySourceGroupPolicy = "Group Policy"
+)
+
+// ManagedImageStateFile records the file coexistence overwrote and where its
+// original content was saved, so uninstalling can put it back.
+const ManagedImageStateFile = "managed_image.json"
+
+// ManagedPolicy is a lock screen image pinned by Intune (MDM) or Group Policy,
+// shown as "Lock screen image is managed by your organization" in Settings.
+type ManagedPolicy struct {
+	Source string
+	// Setting is the image the policy asks for: the URL or path given to
+	// Intune, or the Group Policy path.
+	Setting string
+	// ImagePath is the local file Windows shows for the policy. It is empty
+	// when unknown or not on this machine (e.g. a share other machines show).
+	ImagePath string
+}
+
+func (p *ManagedPolicy) String() string {
+	return fmt.Sprintf("%s (%s)", p.Source, p.Setting)
+}
+
+// managedImageState is the content of ManagedImageStateFile.
+type managedImageState struct {
+	Path   string `json:"path"`
+	Backup string `json:"backup"`
+}
+
+// DetectManagedPolicy returns the policy that pins the lock screen image, or
+// nil. A Group Policy value pointing at one of the tools' own images is the
+// tools' own write, not a policy; a domain policy that writes it back again is
+// found on the next run after the refresh.
+func DetectManagedPolicy() *ManagedPolicy {
+	if url := readPolicyString(mdmPolicyKey, "LockScreenImageUrl"); url != "" {
+		p := &ManagedPolicy{Source: PolicySourceMDM, Setting: url}
+		// Windows shows the CSP's downloaded copy; once the tools have replaced
+		// the value, the copy's path is in the journal
+		path := readPolicyString(cspKey, "LockScreenImagePath")
+		if isOwnImage(path) {
+			path = ""
+			if e, ok := journal.Lookup(registry.LOCAL_MACHINE, cspKey, "LockScreenImagePath"); ok && e.Existed {
+				path = e.String
+			}
+		}
+		p.ImagePath = localImage(path)
+		return p
+	}
+	if path := readPolicyString(gpoKey, "LockScreenImage"); path != "" && !isOwnImage(path) {
+		return &ManagedPolicy{Source: PolicySourceGroupPolicy, Setting: path, ImagePath: localImage(path)}
+	}
+	return nil
+}
+
+// WriteManagedImage copies imagePath over the file the policy shows, so the
+// status image appears without changing any setting the policy owns. The
+// file's original content is saved the first time.
+func WriteManagedImage(policy *ManagedPolicy, imagePath string) error {
+	if policy.ImagePath == "" {
+		return fmt.Errorf("the %s image is not a local file", policy.Source)
+	}
+	if err := backupManagedImage(policy.ImagePath); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", imagePath, err)
+	}
+	err = os.WriteFile(policy.ImagePath, data, 0644)
+	if errors.Is(err, os.ErrPermission) {
+		takeOwnership(policy.ImagePath)
+		err = os.WriteFile(policy.ImagePath, data, 0644)
+	}
+	if err != nil {
+		return errs.Classify(fmt.Errorf("failed to write %s: %w", policy.ImagePath, err))
+	}
+	return nil
+}
+
+// RestoreManagedImage puts back the original content of a file
+// WriteManagedImage overwrote. It does nothing when there is none.
+func RestoreManagedImage() error {
+	statePath := filepath.Join(BackupDir, ManagedImageStateFile)
+	state, err := readManagedImageState(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(state.Backup)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", state.Backup, err)
+	}
+	if err := os.WriteFile(state.Path, data, 0644); err != nil {
+		return errs.Classify(fmt.Errorf("failed to restore %s: %w", state.Path, err))
+	}
+	os.Remove(state.Backup)
+	return os.Remove(statePath)
+}
+
+// backupManagedImage saves the original content of path, unless it already
+// was. A policy that moved to another file gets a new backup.
+func backupManagedImage(path string) error {
+	statePath := filepath.Join(BackupDir, ManagedImageStateFile)
+	if state, err := readManagedImageState(statePath); err == nil && strings.EqualFold(state.Path, path) {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	state := managedImageState{Path: path, Backup: filepath.Join(BackupDir, "managed_image_original"+filepath.Ext(path))}
+	if err := os.MkdirAll(BackupDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", BackupDir, err)
+	}
+	if err := os.WriteFile(state.Backup, data, 0644); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	encoded, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, encoded, 0644)
+}
+
+func readManagedImageState(path string) (*managedImageState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := &managedImageState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// readPolicyString reads a REG_SZ value under HKLM, "" when missing.
+func readPolicyString(path, name string) string {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	value, _, err := key.GetStringValue(name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// isOwnImage reports whether path is one of the tools' rendered images.
+func isOwnImage(path string) bool {
+	if path == "" {
+		return false
+	}
+	rel, err := filepath.Rel(strings.ToLower(BackupDir), strings.ToLower(path))
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// localImage returns path when it is an existing file on a local drive, and ""
+// for URLs, shares and missing files.
+func localImage(path string) string {
+	if len(filepath.VolumeName(path)) != 2 {
+		return ""
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,188 @@
+package loginscreen
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// SlideshowKey (HKCU) holds the per-user lock screen slideshow settings that
+// Settings > Personalization > Lock screen > Slideshow writes.
+const SlideshowKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Lock Screen`
+
+// Slideshow registry values.
+const (
+	ValueSlideshowEnabled        = "SlideshowEnabled"
+	ValueSlideshowSourcesSet     = "SlideshowSourceDirectoriesSet"
+	ValueSlideshowDirectoryPath1 = "SlideshowDirectoryPath1"
+)
+
+// StatusSlideshowPrefix starts the names of the status renders BgStatusService
+// copies into the slideshow folder, so it only ever prunes its own files.
+const StatusSlideshowPrefix = "status_"
+
+var (
+	procSHParseDisplayName = syscall.NewLazyDLL("shell32.dll").NewProc("SHParseDisplayName")
+	procILGetSize          = syscall.NewLazyDLL("shell32.dll").NewProc("ILGetSize")
+)
+
+// encodeFolderIDList returns the folder's shell item ID list encoded the way
+// the slideshow settings store it (base64).
+func encodeFolderIDList(folder string) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := windows.CoInitializeEx(0, windows.COINIT_APARTMENTTHREADED); err == nil {
+		defer windows.CoUninitialize()
+	}
+
+	name, err := syscall.UTF16PtrFromString(folder)
+	if err != nil {
+		return "", err
+	}
+	var pidl unsafe.Pointer
+	hr, _, _ := procSHParseDisplayName.Call(uintptr(unsafe.Pointer(name)), 0, uintptr(unsafe.Pointer(&pidl)), 0, 0)
+	if hr != 0 || pidl == nil {
+		return "", fmt.Errorf("failed to resolve %s (HRESULT 0x%08x)", folder, uint32(hr))
+	}
+	defer windows.CoTaskMemFree(pidl)
+
+	size, _, _ := procILGetSize.Call(uintptr(pidl))
+	data := unsafe.Slice((*byte)(pidl), int(size))
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// setSlideshow registers folder as the lock screen slideshow under root\prefix
+// (HKCU, or a user hive under HKEY_USERS). Windows Spotlight takes precedence
+// over the slideshow; it is left to lock_screen.disable_overlays and
+// lock_screen.spotlight. The previous values are journaled first.
+func setSlideshow(root registry.Key, prefix, folder string) error {
+	absFolder, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(absFolder); err != nil || !info.IsDir() {
+		return fmt.Errorf("slideshow folder %s does not exist", absFolder)
+	}
+	encoded, err := encodeFolderIDList(absFolder)
+	if err != nil {
+		return err
+	}
+
+	slideshowPath := prefix + SlideshowKey
+	journal.Record(root, slideshowPath, ValueSlideshowEnabled, ValueSlideshowSourcesSet, ValueSlideshowDirectoryPath1)
+
+	key, _, err := winapi.Reg.CreateKey(root, slideshowPath, registry.ALL_ACCESS)
+	if err != nil {
+		return fmt.Errorf("failed to open Lock Screen key: %w", err)
+	}
+	defer key.Close()
+
+	err = key.SetStringValue(ValueSlideshowDirectoryPath1, encoded)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", ValueSlideshowDirectoryPath1, err)
+	}
+	for _, name := range []string{ValueSlideshowSourcesSet, ValueSlideshowEnabled} {
+		err = key.SetDWordValue(name, 1)
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SetLockScreenSlideshow shows the images in folder as the lock screen slideshow
+// of the user running this.
+func SetLockScreenSlideshow(folder string) error {
+	return setSlideshow(registry.CURRENT_USER, "", folder)
+}
+
+// SetLockScreenSlideshowForUser shows the images in folder as the lock screen
+// slideshow of a signed-in user (a loaded hive under HKEY_USERS).
+func SetLockScreenSlideshowForUser(sid, folder string) error {
+	return setSlideshow(registry.USERS, sid+`\`, folder)
+}
+
+// SlideshowRegistered reports whether a signed-in user's slideshow is enabled and
+// points at folder, so the service only rewrites the settings when needed.
+func SlideshowRegistered(sid, folder string) bool {
+	key, err := winapi.Reg.OpenKey(registry.USERS, sid+`\`+SlideshowKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+
+	enabled, _, err := key.GetIntegerValue(ValueSlideshowEnabled)
+	if err != nil || enabled == 0 {
+		return false
+	}
+	current, _, err := key.GetStringValue(ValueSlideshowDirectoryPath1)
+	if err != nil {
+		return false
+	}
+	absFolder, err := filepath.Abs(folder)
+	if err != nil {
+		return false
+	}
+	encoded, err := encodeFolderIDList(absFolder)
+	return err == nil && current == encoded
+}
+
+// isSlideshowEntry reports whether a journal entry belongs to the slideshow settings.
+func isSlideshowEntry(e journal.Entry) bool {
+	return strings.HasSuffix(e.Path, SlideshowKey)
+}
+
+// RestoreLockScreenSlideshow puts back the slideshow settings recorded by
+// SetLockScreenSlideshow and SetLockScreenSlideshowForUser. Returns the values
+// that were restored.
+func RestoreLockScreenSlideshow() ([]string, error) {
+	return journal.Revert(isSlideshowEntry)
+}
+
+// AddSlideshowImage copies a rendered image into the slideshow folder and removes
+// the oldest status renders beyond keep. Other images in the folder are left alone.
+func AddSlideshowImage(imagePath, folder string, keep int) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return fmt.Errorf("failed to create slideshow folder: %w", err)
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	dest := filepath.Join(folder, StatusSlideshowPrefix+filepath.Base(imagePath))
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to copy image to slideshow folder: %w", err)
+	}
+
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil
+	}
+	var renders []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), StatusSlideshowPrefix) {
+			renders = append(renders, entry.Name())
+		}
+	}
+	// Names carry the Unix timestamp of the render, so they sort by age
+	sort.Strings(renders)
+	for len(renders) > keep {
+		os.Remove(filepath.Join(folder, renders[0]))
+		renders = renders[1:]
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package loginscreen
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The WinRT lock screen API, called directly through its ABI instead of
+// through PowerShell. It sets the lock screen of the user the process runs as,
+// so it only works in a user's session, not as SYSTEM.
+var (
+	combase                    = windows.NewLazySystemDLL("combase.dll")
+	procRoInitialize           = combase.NewProc("RoInitialize")
+	procRoUninitialize         = combase.NewProc("RoUninitialize")
+	procRoGetActivationFactory = combase.NewProc("RoGetActivationFactory")
+	procWindowsCreateString    = combase.NewProc("WindowsCreateString")
+	procWindowsDeleteString    = combase.NewProc("WindowsDeleteString")
+)
+
+var (
+	// Windows.Storage.IStorageFileStatics
+	iidStorageFileStatics = windows.GUID{Data1: 0x5984C710, Data2: 0xDAF2, Data3: 0x43C8, Data4: [8]byte{0x8B, 0xB4, 0xA4, 0xD3, 0xEA, 0xCF, 0xD0, 0x3F}}
+	// Windows.System.UserProfile.ILockScreenStatics
+	iidLockScreenStatics = windows.GUID{Data1: 0x3EE9D3AD, Data2: 0xB607, Data3: 0x40AE, Data4: [8]byte{0xB4, 0x26, 0x76, 0x31, 0xD9, 0x82, 0x12, 0x69}}
+	// IAsyncInfo
+	iidAsyncInfo = windows.GUID{Data1: 0x00000036, Data4: [8]byte{0xC0, 0, 0, 0, 0, 0, 0, 0x46}}
+)
+
+// Vtable slots. Every WinRT interface starts with the three IUnknown and three
+// IInspectable methods.
+const (
+	slotQueryInterface       = 0
+	slotRelease              = 2
+	slotGetFileFromPathAsync = 6 // IStorageFileStatics
+	slotSetImageFileAsync    = 8 // ILockScreenStatics
+	slotAsyncGetResults      = 8 // IAsyncOperation<T> and IAsyncAction
+	slotAsyncInfoStatus      = 7 // IAsyncInfo
+	slotAsyncInfoErrorCode   = 8
+	slotAsyncInfoCancel      = 9
+)
+
+const (
+	roInitMultithreaded = 1
+	// rpcEChangedMode means the thread is already initialized as an STA, in
+	// which the calls work too.
+	rpcEChangedMode = 0x80010106
+
+	winrtOperationTimeout      = 30 * time.Second
+	winrtOperationPollInterval = 20 * time.Millisecond
+)
+
+// Async operation states reported by IAsyncInfo.
+const (
+	asyncStarted   = 0
+	asyncCompleted = 1
+	asyncCanceled  = 2
+)
+
+// inspectable is a WinRT object: a pointer to its vtable.
+type inspectable struct {
+	vtbl *[16]uintptr
+}
+
+// call invokes a method that returns an HRESULT.
+func (o *inspectable) call(slot int, args ...uintptr) error {
+	hr, _, _ := syscall.SyscallN(o.vtbl[slot], append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)...)
+	if int32(hr) < 0 {
+		return fmt.Errorf("HRESULT 0x%08X", uint32(hr))
+	}
+	return nil
+}
+
+func (o *inspectable) release() {
+	syscall.SyscallN(o.vtbl[slotRelease], uintptr(unsafe.Pointer(o)))
+}
+
+// setLockScreenViaNativeWinRT sets the lock screen image with
+// StorageFile.GetFileFromPathAsync and LockScreen.SetImageFileAsync.
+func setLockScreenViaNativeWinRT(absPath string) error {
+	// WinRT initialization is per thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	hr, _, _ := procRoInitialize.Call(roInitMultithreaded)
+	if int32(hr) >= 0 {
+		defer procRoUninitialize.Call()
+	} else if uint32(hr) != rpcEChangedMode {
+		return fmt.Errorf("RoInitialize failed: HRESULT 0x%08X", uint32(hr))
+	}
+
+	files, err := activationFactory("Windows.Storage.StorageFile", &iidStorageFileStatics)
+	if err != nil {
+		return err
+	}
+	defer files.release()
+
+	path, err := newHString(absPath)
+	if err != nil {
+		return err
+	}
+	defer procWindowsDeleteString.Call(path)
+
+	var getFile *inspectable
+	if err := files.call(slotGetFileFromPathAsync, path, uintptr(unsafe.Pointer(&getFile))); err != nil {
+		return fmt.Errorf("GetFileFromPathAsync failed: %w", err)
+	}
+	defer getFile.release()
+	if err := await(getFile); err != nil {
+		return fmt.Errorf("failed to open %s: %w", absPath, err)
+	}
+	var file *inspectable
+	if err := getFile.call(slotAsyncGetResults, uintptr(unsafe.Pointer(&file))); err != nil {
+		return fmt.Errorf("failed to open %s: %w", absPath, err)
+	}
+	defer file.release()
+
+	lockScreen, err := activationFactory("Windows.System.UserProfile.LockScreen", &iidLockScreenStatics)
+	if err != nil {
+		return err
+	}
+	defer lockScreen.release()
+
+	var setImage *inspectable
+	if err := lockScreen.call(slotSetImageFileAsync, uintptr(unsafe.Pointer(file)), uintptr(unsafe.Pointer(&setImage))); err != nil {
+		return fmt.Errorf("SetImageFileAsync failed: %w", err)
+	}
+	defer setImage.release()
+	if err := await(setImage); err != nil {
+		return fmt.Errorf("SetImageFileAsync failed: %w", err)
+	}
+	return setImage.call(slotAsyncGetResults)
+}
+
+// activationFactory returns the statics interface iid of a runtime class.
+func activationFactory(class string, iid *windows.GUID) (*inspectable, error) {
+	name, err := newHString(class)
+	if err != nil {
+		return nil, err
+	}
+	defer procWindowsDeleteString.Call(name)
+
+	var factory *inspectable
+	hr, _, _ := procRoGetActivationFactory.Call(name, uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&factory)))
+	if int32(hr) < 0 {
+		return nil, fmt.Errorf("failed to activate %s: HRESULT 0x%08X", class, uint32(hr))
+	}
+	return factory, nil
+}
+
+// newHString creates an HSTRING, which the caller deletes.
+func newHString(s string) (uintptr, error) {
+	utf16, err := windows.UTF16FromString(s)
+	if err != nil {
+		return 0, err
+	}
+	var h uintptr
+	hr, _, _ := procWindowsCreateString.Call(uintptr(unsafe.Pointer(&utf16[0])), uintptr(len(utf16)-1), uintptr(unsafe.Pointer(&h)))
+	if int32(hr) < 0 {
+		return 0, fmt.Errorf("WindowsCreateString failed: HRESULT 0x%08X", uint32(hr))
+	}
+	return h, nil
+}
+
+// await polls an async operation or action until it finishes. It cancels it
+// after winrtOperationTimeout.
+func await(op *inspectable) error {
+	var info *inspectable
+	if err := op.call(slotQueryInterface, uintptr(unsafe.Pointer(&iidAsyncInfo)), uintptr(unsafe.Pointer(&info))); err != nil {
+		return fmt.Errorf("not an async operation: %w", err)
+	}
+	defer info.release()
+
+	deadline := time.Now().Add(winrtOperationTimeout)
+	for {
+		var status int32
+		if err := info.call(slotAsyncInfoStatus, uintptr(unsafe.Pointer(&status))); err != nil {
+			return err
+		}
+		switch status {
+		case asyncStarted:
+			if time.Now().After(deadline) {
+				info.call(slotAsyncInfoCancel)
+				return fmt.Errorf("timed out after %v", winrtOperationTimeout)
+			}
+			time.Sleep(winrtOperationPollInterval)
+			continue
+		case asyncCompleted:
+			return nil
+		case asyncCanceled:
+			return fmt.Errorf("canceled")
+		}
+		var code int32
+		if err := info.call(slotAsyncInfoErrorCode, uintptr(unsafe.Pointer(&code))); err != nil {
+			return err
+		}
+		return fmt.Errorf("HRESULT 0x%08X", uint32(code))
+	}
+}
@@ -0,0 +1,105 @@
+// Package netcost decides whether non-essential downloads (random wallpapers,
+// prefetching, updates) should be deferred because the connection is metered or
+// the device is running low on battery.
+package netcost
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// meteredScript asks the Windows Connection Manager (WinRT NetworkInformation)
+// for the cost of the internet connection profile.
+const meteredScript = `
+$ErrorActionPreference = "Stop"
+$profile = [Windows.Networking.Connectivity.NetworkInformation,Windows.Networking.Connectivity,ContentType=WindowsRuntime]::GetInternetConnectionProfile()
+if ($profile -eq $null) { Write-Output "None False False"; exit }
+$cost = $profile.GetConnectionCost()
+Write-Output "$($cost.NetworkCostType) $($cost.Roaming) $($cost.OverDataLimit)"
+`
+
+// Metered reports whether the active internet connection is metered (fixed or
+// variable cost), roaming, or over its data limit.
+func Metered() (bool, error) {
+	output, err := winapi.Commands.Run(context.Background(), "powershell.exe",
+		"-NoProfile",
+		"-ExecutionPolicy", "Bypass",
+		"-Command", meteredScript,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to query connection cost: %v", err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) < 3 {
+		return false, fmt.Errorf("unexpected connection cost output: %s", strings.TrimSpace(string(output)))
+	}
+
+	costType := strings.ToLower(fields[0])
+	roaming := strings.EqualFold(fields[1], "True")
+	overLimit := strings.EqualFold(fields[2], "True")
+
+	return costType == "fixed" || costType == "variable" || roaming || overLimit, nil
+}
+
+// systemPowerStatus mirrors the Win32 SYSTEM_POWER_STATUS structure.
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// Battery returns whether the device is running on battery and the remaining
+// charge in percent (-1 if unknown). Desktops without a battery report false.
+func Battery() (onBattery bool, percent int, err error) {
+	var status systemPowerStatus
+	ret, _, callErr := syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus").Call(
+		uintptr(unsafe.Pointer(&status)),
+	)
+	if ret == 0 {
+		return false, -1, fmt.Errorf("GetSystemPowerStatus failed: %v", callErr)
+	}
+
+	// BatteryFlag 128 = no system battery, 255 = unknown
+	if status.BatteryFlag == 128 || status.BatteryFlag == 255 {
+		return false, -1, nil
+	}
+
+	percent = int(status.BatteryLifePercent)
+	if percent == 255 {
+		percent = -1
+	}
+
+	// ACLineStatus 0 = offline (on battery)
+	return status.ACLineStatus == 0, percent, nil
+}
+
+// ShouldDefer reports whether non-essential downloads should wait, and why.
+// Detection errors never block a download.
+func ShouldDefer(policy config.FetchPolicyConfig) (bool, string) {
+	if !policy.AllowMetered {
+		metered, err := Metered()
+		if err == nil && metered {
+			return true, "the connection is metered"
+		}
+	}
+
+	if !policy.AllowOnBattery {
+		onBattery, percent, err := Battery()
+		minPercent := policy.MinBattery()
+		if err == nil && onBattery && percent >= 0 && percent < minPercent {
+			return true, fmt.Sprintf("on battery at %d%% (below %d%%)", percent, minPercent)
+		}
+	}
+
+	return false, ""
+}
@@ -0,0 +1,116 @@
+// Package notify shows Windows toast notifications to the signed-in users.
+// BgStatusService runs as SYSTEM in session 0, which has no desktop, so each
+// toast is raised by a hidden PowerShell started in the user's session with
+// the user's token (see package session), using the WinRT ToastNotification
+// API.
+package notify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/backgroundchanger/internal/session"
+)
+
+// StateFileName records when each kind of toast was last shown.
+const StateFileName = "notifications.json"
+
+// appID is the PowerShell AppUserModelID, which is registered on every
+// Windows install, so toasts need no shortcut of their own.
+const appID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript shows the toast XML in %s through the WinRT API.
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('%s')
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('%s').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+
+// Toast shows a toast with a title and text to the user of every active
+// session and returns how many sessions it was shown in. It fails only when
+// no session could be reached.
+func Toast(title, text string) (int, error) {
+	sessions, err := session.Active()
+	if err != nil {
+		return 0, err
+	}
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	command := "powershell.exe -NoProfile -NonInteractive -WindowStyle Hidden -ExecutionPolicy Bypass -EncodedCommand " +
+		encodeCommand(fmt.Sprintf(toastScript, psQuote(toastXML(title, text)), psQuote(appID)))
+	shown := 0
+	var problems []string
+	for _, id := range sessions {
+		if err := session.Start(id, command); err != nil {
+			problems = append(problems, fmt.Sprintf("session %d: %v", id, err))
+			continue
+		}
+		shown++
+	}
+	if shown == 0 && len(problems) > 0 {
+		return 0, fmt.Errorf("failed to show toast: %s", strings.Join(problems, "; "))
+	}
+	return shown, nil
+}
+
+// Due reports whether a toast of this kind was last shown more than interval
+// ago, according to the state in dir.
+func Due(dir, kind string, interval time.Duration, now time.Time) bool {
+	last, ok := loadState(dir)[kind]
+	return !ok || now.Sub(last) >= interval
+}
+
+// Record notes that a toast of this kind was shown at now.
+func Record(dir, kind string, now time.Time) error {
+	state := loadState(dir)
+	state[kind] = now
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, StateFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to save notification state: %w", err)
+	}
+	return nil
+}
+
+// loadState returns when each kind of toast was last shown.
+func loadState(dir string) map[string]time.Time {
+	state := make(map[string]time.Time)
+	if data, err := os.ReadFile(filepath.Join(dir, StateFileName)); err == nil {
+		_ = json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+// toastXML returns the toast content: the title in bold and the text below.
+func toastXML(title, text string) string {
+	return `<toast><visual><binding template="ToastGeneric"><text>` + html.EscapeString(title) +
+		`</text><text>` + html.EscapeString(text) + `</text></binding></visual></toast>`
+}
+
+// psQuote escapes s for a single-quoted PowerShell string.
+func psQuote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// encodeCommand encodes a script for powershell -EncodedCommand (base64 of
+// UTF-16LE), which avoids quoting it on the command line.
+func encodeCommand(script string) string {
+	units := utf16.Encode([]rune(script))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		b[2*i] = byte(u)
+		b[2*i+1] = byte(u >> 8)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,112 @@
+package overlay
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// vignetteStart is how far from the center (0) to the corners (1) the
+// vignette begins to darken the image.
+const vignetteStart = 0.4
+
+// Adjustments are applied to the wallpaper before the panels are drawn, in
+// the order of the fields. They mirror the adjust section of the
+// configuration, which converts to them directly.
+type Adjustments struct {
+	// Brightness darkens (negative) or brightens (positive) the image by a
+	// percentage, from -100 (black) to 100 (white).
+	Brightness float64
+	// Desaturate removes a percentage of the color, 100 leaving grayscale.
+	Desaturate float64
+	// Tint is an optional "#RRGGBB" color blended over the image.
+	Tint string
+	// TintStrength is the tint opacity from 0 to 1 (default 0.2).
+	TintStrength float64
+	// Vignette darkens the edges by up to a percentage, 100 making the
+	// corners black.
+	Vignette float64
+}
+
+// Enabled reports whether any adjustment is set.
+func (a Adjustments) Enabled() bool {
+	return a.Brightness != 0 || a.Desaturate != 0 || a.Tint != "" || a.Vignette != 0
+}
+
+// Adjust applies the brightness, desaturation, tint and vignette to a copy of
+// the wallpaper. It returns img unchanged when nothing is set; an invalid
+// tint color is ignored (see --check-config).
+func Adjust(img image.Image, adjust Adjustments) image.Image {
+	if !adjust.Enabled() {
+		return img
+	}
+
+	bounds := img.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(result, result.Bounds(), img, bounds.Min, draw.Src)
+
+	brightness := clampUnit(adjust.Brightness/100, -1)
+	desaturate := clampUnit(adjust.Desaturate/100, 0)
+	vignette := clampUnit(adjust.Vignette/100, 0)
+	var tint [3]float64
+	tintStrength := 0.0
+	if c, err := ParseHexColor(adjust.Tint); err == nil {
+		tint = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+		tintStrength = adjust.TintStrength
+		if tintStrength == 0 {
+			tintStrength = DefaultTintStrength
+		}
+		tintStrength = clampUnit(tintStrength, 0)
+	}
+
+	w, h := result.Bounds().Dx(), result.Bounds().Dy()
+	cx, cy := float64(w)/2, float64(h)/2
+	for y := 0; y < h; y++ {
+		row := result.Pix[y*result.Stride : y*result.Stride+w*4]
+		dy := (float64(y) + 0.5 - cy) / cy
+		for x := 0; x < w; x++ {
+			px := row[x*4 : x*4+4]
+			r, g, b := float64(px[0]), float64(px[1]), float64(px[2])
+
+			if brightness < 0 {
+				r, g, b = r*(1+brightness), g*(1+brightness), b*(1+brightness)
+			} else if brightness > 0 {
+				r, g, b = r+(255-r)*brightness, g+(255-g)*brightness, b+(255-b)*brightness
+			}
+			if desaturate > 0 {
+				gray := 0.299*r + 0.587*g + 0.114*b
+				r, g, b = r+(gray-r)*desaturate, g+(gray-g)*desaturate, b+(gray-b)*desaturate
+			}
+			if tintStrength > 0 {
+				r = r + (tint[0]-r)*tintStrength
+				g = g + (tint[1]-g)*tintStrength
+				b = b + (tint[2]-b)*tintStrength
+			}
+			if vignette > 0 {
+				dx := (float64(x) + 0.5 - cx) / cx
+				// 0 at the center, 1 in the corners
+				d := math.Sqrt((dx*dx + dy*dy) / 2)
+				if d > vignetteStart {
+					fall := (d - vignetteStart) / (1 - vignetteStart)
+					factor := 1 - vignette*fall*fall
+					r, g, b = r*factor, g*factor, b*factor
+				}
+			}
+
+			// Premultiplied, so no channel may exceed alpha
+			alpha := float64(px[3])
+			px[0], px[1], px[2] = clampChannel(r, alpha), clampChannel(g, alpha), clampChannel(b, alpha)
+		}
+	}
+	return result
+}
+
+// clampUnit limits v to the range from min to 1.
+func clampUnit(v, min float64) float64 {
+	return math.Max(min, math.Min(1, v))
+}
+
+// clampChannel rounds v to a color channel no larger than alpha.
+func clampChannel(v, alpha float64) uint8 {
+	return uint8(math.Max(0, math.Min(alpha, math.Round(v))))
+}
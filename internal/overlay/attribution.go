@@ -0,0 +1,72 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+)
+
+// Corner names used to position small single-line overlays.
+const (
+	CornerTopLeft     = "top-left"
+	CornerTopRight    = "top-right"
+	CornerBottomLeft  = "bottom-left"
+	CornerBottomRight = "bottom-right"
+)
+
+// AttributionFontScale is the attribution text size relative to the panel font size.
+const AttributionFontScale = 0.7
+
+// RenderAttribution draws a single small line of text (e.g. a photo credit) in a
+// corner of the image. Unknown corners default to bottom-right.
+func RenderAttribution(img image.Image, text string, corner string) (image.Image, error) {
+	bounds := img.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+	height := bounds.Max.Y - bounds.Min.Y
+
+	dims := CalculateScaledDimensions(width, height)
+	fontSize := dims.FontSize * AttributionFontScale
+	if fontSize < MinFontSize {
+		fontSize = MinFontSize
+	}
+	padding := dims.Padding / 2
+
+	dc := newContext(img)
+
+	err := setFontFace(dc, fontSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+
+	textWidth, _ := dc.MeasureString(text)
+	boxWidth := textWidth + padding*2
+	boxHeight := fontSize + padding*2
+
+	// Keep clear of the screen edge by the same margin as the panels
+	var boxX, boxY float64
+	switch corner {
+	case CornerTopLeft:
+		boxX, boxY = dims.MarginLeft, dims.MarginLeft
+	case CornerTopRight:
+		boxX, boxY = float64(width)-boxWidth-dims.MarginRight, dims.MarginRight
+	case CornerBottomLeft:
+		boxX, boxY = dims.MarginLeft, float64(height)-boxHeight-dims.MarginLeft
+	default:
+		boxX, boxY = float64(width)-boxWidth-dims.MarginRight, float64(height)-boxHeight-dims.MarginRight
+	}
+
+	colors := LightOnDark()
+	if AnalyzeRegionBrightness(img, int(boxX), int(boxY), int(boxWidth), int(boxHeight)) {
+		colors = DarkOnLight()
+	}
+
+	r, g, b, a := colors.Background.RGBA()
+	dc.SetRGBA(float64(r)/65535, float64(g)/65535, float64(b)/65535, float64(a)/65535)
+	dc.DrawRoundedRectangle(boxX, boxY, boxWidth, boxHeight, dims.CornerRadius/2)
+	dc.Fill()
+
+	r, g, b, a = colors.Text.RGBA()
+	dc.SetRGBA(float64(r)/65535, float64(g)/65535, float64(b)/65535, float64(a)/65535)
+	dc.DrawString(text, boxX+padding, boxY+padding+fontSize*0.85)
+
+	return dc.Image(), nil
+}
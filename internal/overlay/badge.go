@@ -0,0 +1,112 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// BadgeGradeScale is the size of the grade letter relative to the panel font.
+const BadgeGradeScale = 3.0
+
+// Grade colors, chosen like the graph colors to stay readable on both panel
+// backgrounds.
+var (
+	BadgeColorGood = color.RGBA{46, 180, 90, 255}
+	BadgeColorFair = color.RGBA{255, 165, 0, 255}
+	BadgeColorPoor = color.RGBA{230, 60, 60, 255}
+)
+
+// GradeColor returns the color of a letter grade: green for A and B, orange
+// for C and red for anything lower.
+func GradeColor(grade string) color.Color {
+	switch grade {
+	case "A", "B":
+		return BadgeColorGood
+	case "C":
+		return BadgeColorFair
+	}
+	return BadgeColorPoor
+}
+
+// BadgeOptions describes the score badge.
+type BadgeOptions struct {
+	// Grade is the large letter, e.g. "B".
+	Grade string
+	// Caption is drawn next to the grade, e.g. "Security 83/100".
+	Caption string
+	// Lines are the contributing factors listed beneath, alerts in the alert
+	// color like the text panels.
+	Lines []string
+}
+
+// RenderBadge draws a panel with a large, colored grade in the lower-left
+// corner of the image, bottomInset pixels higher to clear the banner.
+func RenderBadge(img image.Image, badge BadgeOptions, opts Options, bottomInset float64) (image.Image, error) {
+	bounds := img.Bounds()
+	height := bounds.Max.Y - bounds.Min.Y
+
+	dims := CalculateScaledDimensionsForDisplay(opts)
+	gradeSize := dims.FontSize * BadgeGradeScale
+	lineHeight := dims.FontSize + dims.LineSpacing
+
+	dc := newContext(img)
+
+	if err := setFontFace(dc, gradeSize); err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+	gradeWidth, _ := dc.MeasureString(badge.Grade)
+
+	if err := setFontFace(dc, dims.FontSize); err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+	captionWidth, _ := dc.MeasureString(badge.Caption)
+	contentWidth := gradeWidth + dims.Padding + captionWidth
+	for _, line := range badge.Lines {
+		if w, _ := dc.MeasureString(line); w > contentWidth {
+			contentWidth = w
+		}
+	}
+
+	boxWidth := contentWidth + dims.Padding*2
+	boxHeight := gradeSize + float64(len(badge.Lines))*lineHeight + dims.Padding*2
+	wear := opts.BurnIn
+	boxX := dims.MarginLeft + wear.DX
+	// Mirrored like the panels in a right-to-left layout
+	if opts.RightToLeft {
+		boxX = float64(bounds.Dx()) - boxWidth - dims.MarginRight + wear.DX
+	}
+	boxY := float64(height) - boxHeight - dims.MarginTop - bottomInset + wear.DY
+
+	colors := LightOnDark()
+	if AnalyzeRegionBrightness(img, int(boxX), int(boxY), int(boxWidth), int(boxHeight)) {
+		colors = DarkOnLight()
+	}
+	colors = wear.colors(colors)
+
+	drawPanel(dc, boxX, boxY, boxWidth, boxHeight, dims, colors, nil, false)
+
+	// The caption sits level with the middle of the grade
+	gradeMiddle := boxY + dims.Padding + gradeSize/2
+	setColor(dc, colors.Text)
+	dc.DrawStringAnchored(badge.Caption, boxX+dims.Padding+gradeWidth+dims.Padding, gradeMiddle, 0, 0.35)
+
+	y := boxY + dims.Padding + gradeSize
+	for _, line := range badge.Lines {
+		c := colors.Text
+		if isAlert(line) && colors.Alert != nil {
+			c = colors.Alert
+		}
+		setColor(dc, c)
+		dc.DrawString(line, boxX+dims.Padding, y+dims.FontSize)
+		y += lineHeight
+	}
+
+	if err := setFontFace(dc, gradeSize); err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+	setColor(dc, GradeColor(badge.Grade))
+	dc.DrawStringAnchored(badge.Grade, boxX+dims.Padding, gradeMiddle, 0, 0.35)
+
+	return dc.Image(), nil
+}
@@ -0,0 +1,102 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Banner text sizes relative to the panel font size.
+const (
+	BannerTitleScale = 1.5
+	BannerTextScale  = 1.2
+)
+
+// BannerOptions describes the legal notice strip.
+type BannerOptions struct {
+	// Title is an optional heading, e.g. "Authorized use only".
+	Title string
+	// Text is the notice; "\n" starts a new line and long lines are wrapped.
+	Text       string
+	Background color.Color
+	Foreground color.Color
+}
+
+// DefaultBannerColors returns the colors used when the banner doesn't set its
+// own: white on a nearly opaque black strip.
+func DefaultBannerColors() (background, foreground color.Color) {
+	return color.RGBA{0, 0, 0, 220}, color.RGBA{255, 255, 255, 255}
+}
+
+// RenderBanner draws a full-width strip along the bottom of the image with the
+// centered title and text, and returns its height so other bottom overlays
+// can sit above it.
+func RenderBanner(img image.Image, opts BannerOptions) (image.Image, float64, error) {
+	background, foreground := DefaultBannerColors()
+	if opts.Background != nil {
+		background = opts.Background
+	}
+	if opts.Foreground != nil {
+		foreground = opts.Foreground
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+	height := bounds.Max.Y - bounds.Min.Y
+
+	dims := CalculateScaledDimensions(width, height)
+	titleSize := dims.FontSize * BannerTitleScale
+	textSize := dims.FontSize * BannerTextScale
+	maxTextWidth := float64(width) - dims.MarginLeft - dims.MarginRight
+
+	dc := newContext(img)
+
+	// Measure everything first to size the strip
+	var titleLines, textLines []string
+	if opts.Title != "" {
+		if err := setFontFace(dc, titleSize); err != nil {
+			return nil, 0, fmt.Errorf("failed to load font: %v", err)
+		}
+		for _, paragraph := range strings.Split(opts.Title, "\n") {
+			titleLines = append(titleLines, dc.WordWrap(paragraph, maxTextWidth)...)
+		}
+	}
+	if err := setFontFace(dc, textSize); err != nil {
+		return nil, 0, fmt.Errorf("failed to load font: %v", err)
+	}
+	for _, paragraph := range strings.Split(opts.Text, "\n") {
+		textLines = append(textLines, dc.WordWrap(paragraph, maxTextWidth)...)
+	}
+	titleLineHeight := titleSize * 1.3
+	textLineHeight := textSize * 1.3
+
+	stripHeight := float64(len(titleLines))*titleLineHeight + float64(len(textLines))*textLineHeight + dims.Padding*2
+	stripY := float64(height) - stripHeight
+	centerX := float64(width) / 2
+
+	setColor(dc, background)
+	dc.DrawRectangle(0, stripY, float64(width), stripHeight)
+	dc.Fill()
+
+	setColor(dc, foreground)
+	y := stripY + dims.Padding
+	if len(titleLines) > 0 {
+		if err := setFontFace(dc, titleSize); err != nil {
+			return nil, 0, fmt.Errorf("failed to load font: %v", err)
+		}
+		for _, line := range titleLines {
+			dc.DrawStringAnchored(visualLine(line, false), centerX, y+titleLineHeight/2, 0.5, 0.35)
+			y += titleLineHeight
+		}
+		if err := setFontFace(dc, textSize); err != nil {
+			return nil, 0, fmt.Errorf("failed to load font: %v", err)
+		}
+	}
+	for _, line := range textLines {
+		dc.DrawStringAnchored(visualLine(line, false), centerX, y+textLineHeight/2, 0.5, 0.35)
+		y += textLineHeight
+	}
+
+	return dc.Image(), stripHeight, nil
+}
@@ -0,0 +1,388 @@
+package overlay
+
+import (
+	"unicode"
+)
+
+// A simplified Unicode Bidirectional Algorithm (UAX #9) for single lines
+// without explicit embeddings, which is all the panels draw: enough to show
+// Hebrew and Arabic service names, host names and notices next to English
+// text and numbers in the right order.
+
+// bidiClass is the bidirectional character type of a rune.
+type bidiClass int
+
+const (
+	bidiL   bidiClass = iota // left-to-right letter
+	bidiR                    // Hebrew, or Arabic after W3
+	bidiAL                   // Arabic letter
+	bidiEN                   // European digit
+	bidiAN                   // Arabic-Indic digit
+	bidiES                   // plus and minus
+	bidiCS                   // number separators
+	bidiET                   // number terminators such as % and currency
+	bidiNSM                  // combining mark
+	bidiN                    // whitespace and other neutrals
+)
+
+// classify returns the bidi class of r.
+func classify(r rune) bidiClass {
+	switch {
+	case r >= '0' && r <= '9':
+		return bidiEN
+	case r >= 0x0660 && r <= 0x0669, r >= 0x06F0 && r <= 0x06F9:
+		return bidiAN
+	case r >= 0x0591 && r <= 0x05C7 && r != 0x05BE && r != 0x05C0 && r != 0x05C3 && r != 0x05C6,
+		r >= 0x064B && r <= 0x065F, r == 0x0670:
+		return bidiNSM
+	case r >= 0x0590 && r <= 0x05FF, r >= 0xFB1D && r <= 0xFB4F:
+		return bidiR
+	case r >= 0x0600 && r <= 0x08FF, r >= 0xFB50 && r <= 0xFDFF, r >= 0xFE70 && r <= 0xFEFF:
+		return bidiAL
+	case r == '+' || r == '-':
+		return bidiES
+	case r == ',' || r == '.' || r == ':' || r == '/' || r == 0x00A0:
+		return bidiCS
+	case r == '#' || r == '%' || r == '$' || r == 0x00B0 || unicode.Is(unicode.Sc, r):
+		return bidiET
+	case unicode.IsLetter(r):
+		return bidiL
+	}
+	return bidiN
+}
+
+// hasRTL reports whether s contains Hebrew or Arabic.
+func hasRTL(s string) bool {
+	for _, r := range s {
+		if c := classify(r); c == bidiR || c == bidiAL {
+			return true
+		}
+	}
+	return false
+}
+
+// visualLine returns a line in the order its characters are drawn from left
+// to right, with Arabic letters in their joined forms. The line's direction
+// is that of its first letter; lines without one (e.g. only numbers) take
+// rtl. Lines without Hebrew or Arabic are returned unchanged, so English keeps
+// reading left to right even in a right-to-left layout.
+func visualLine(line string, rtl bool) string {
+	if !hasRTL(line) {
+		return line
+	}
+	runes := shapeArabic([]rune(line))
+
+	classes := make([]bidiClass, len(runes))
+	for i, r := range runes {
+		classes[i] = classify(r)
+	}
+
+	// P2, P3: the paragraph level from the first strong character
+	baseLevel := 0
+	if rtl {
+		baseLevel = 1
+	}
+	for _, c := range classes {
+		if c == bidiL {
+			baseLevel = 0
+			break
+		}
+		if c == bidiR || c == bidiAL {
+			baseLevel = 1
+			break
+		}
+	}
+	sos := bidiL
+	if baseLevel == 1 {
+		sos = bidiR
+	}
+
+	resolveWeak(classes, sos)
+	resolveNeutral(classes, sos)
+
+	// I1, I2: levels from the resolved types
+	levels := make([]int, len(runes))
+	maxLevel := baseLevel
+	for i, c := range classes {
+		level := baseLevel
+		switch {
+		case baseLevel == 0 && c == bidiR:
+			level = 1
+		case baseLevel == 0 && (c == bidiEN || c == bidiAN):
+			level = 2
+		case baseLevel == 1 && (c == bidiL || c == bidiEN || c == bidiAN):
+			level = 2
+		}
+		levels[i] = level
+		if level > maxLevel {
+			maxLevel = level
+		}
+	}
+
+	// L4: mirrored characters in right-to-left runs
+	for i, r := range runes {
+		if levels[i]%2 == 1 {
+			if m, ok := mirrored[r]; ok {
+				runes[i] = m
+			}
+		}
+	}
+
+	// L2: reverse every run at each level down to the lowest odd one
+	for level := maxLevel; level >= 1; level-- {
+		for i := 0; i < len(runes); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runes) && levels[j] >= level {
+				j++
+			}
+			reverseRunes(runes[i:j])
+			reverseInts(levels[i:j])
+			i = j
+		}
+	}
+	return string(runes)
+}
+
+// visualLines returns visualLine of every line.
+func visualLines(lines []string, rtl bool) []string {
+	visual := make([]string, len(lines))
+	for i, line := range lines {
+		visual[i] = visualLine(line, rtl)
+	}
+	return visual
+}
+
+// resolveWeak applies the weak type rules W1-W7 in place.
+func resolveWeak(classes []bidiClass, sos bidiClass) {
+	// W1: combining marks take the type of the character they are on
+	prev := sos
+	for i, c := range classes {
+		if c == bidiNSM {
+			classes[i] = prev
+		} else {
+			prev = c
+		}
+	}
+
+	// W2: European digits after an Arabic letter are Arabic digits
+	strong := sos
+	for i, c := range classes {
+		switch c {
+		case bidiL, bidiR, bidiAL:
+			strong = c
+		case bidiEN:
+			if strong == bidiAL {
+				classes[i] = bidiAN
+			}
+		}
+	}
+
+	// W3: Arabic letters are right-to-left
+	for i, c := range classes {
+		if c == bidiAL {
+			classes[i] = bidiR
+		}
+	}
+
+	// W4: a single separator between two numbers of one kind joins them
+	for i := 1; i+1 < len(classes); i++ {
+		before, after := classes[i-1], classes[i+1]
+		switch {
+		case classes[i] == bidiES && before == bidiEN && after == bidiEN:
+			classes[i] = bidiEN
+		case classes[i] == bidiCS && before == after && (before == bidiEN || before == bidiAN):
+			classes[i] = before
+		}
+	}
+
+	// W5: terminators next to European digits belong to the number
+	for i := 0; i < len(classes); i++ {
+		if classes[i] != bidiET {
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == bidiET {
+			j++
+		}
+		if (i > 0 && classes[i-1] == bidiEN) || (j < len(classes) && classes[j] == bidiEN) {
+			for k := i; k < j; k++ {
+				classes[k] = bidiEN
+			}
+		}
+		i = j
+	}
+
+	// W6: remaining separators and terminators are neutral
+	for i, c := range classes {
+		if c == bidiES || c == bidiCS || c == bidiET {
+			classes[i] = bidiN
+		}
+	}
+
+	// W7: European digits in left-to-right text are left-to-right
+	strong = sos
+	for i, c := range classes {
+		switch c {
+		case bidiL, bidiR:
+			strong = c
+		case bidiEN:
+			if strong == bidiL {
+				classes[i] = bidiL
+			}
+		}
+	}
+}
+
+// resolveNeutral applies N1 and N2 in place: neutrals between two characters
+// of one direction take it (numbers count as right-to-left), others take the
+// paragraph direction sos.
+func resolveNeutral(classes []bidiClass, sos bidiClass) {
+	direction := func(c bidiClass) bidiClass {
+		if c == bidiEN || c == bidiAN {
+			return bidiR
+		}
+		return c
+	}
+
+	for i := 0; i < len(classes); i++ {
+		if classes[i] != bidiN {
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == bidiN {
+			j++
+		}
+		before, after := sos, sos
+		if i > 0 {
+			before = direction(classes[i-1])
+		}
+		if j < len(classes) {
+			after = direction(classes[j])
+		}
+		resolved := sos
+		if before == after {
+			resolved = before
+		}
+		for k := i; k < j; k++ {
+			classes[k] = resolved
+		}
+		i = j
+	}
+}
+
+// mirrored are the characters drawn mirrored in right-to-left text.
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+}
+
+func reverseRunes(s []rune) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func reverseInts(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// arabicForms maps each Arabic letter to its isolated presentation form and
+// the number of forms it has: 1 (does not join), 2 (isolated and final, joins
+// only the letter before it) or 4 (isolated, final, initial and medial).
+var arabicForms = map[rune]struct {
+	isolated rune
+	forms    int
+}{
+	0x0621: {0xFE80, 1}, 0x0622: {0xFE81, 2}, 0x0623: {0xFE83, 2}, 0x0624: {0xFE85, 2},
+	0x0625: {0xFE87, 2}, 0x0626: {0xFE89, 4}, 0x0627: {0xFE8D, 2}, 0x0628: {0xFE8F, 4},
+	0x0629: {0xFE93, 2}, 0x062A: {0xFE95, 4}, 0x062B: {0xFE99, 4}, 0x062C: {0xFE9D, 4},
+	0x062D: {0xFEA1, 4}, 0x062E: {0xFEA5, 4}, 0x062F: {0xFEA9, 2}, 0x0630: {0xFEAB, 2},
+	0x0631: {0xFEAD, 2}, 0x0632: {0xFEAF, 2}, 0x0633: {0xFEB1, 4}, 0x0634: {0xFEB5, 4},
+	0x0635: {0xFEB9, 4}, 0x0636: {0xFEBD, 4}, 0x0637: {0xFEC1, 4}, 0x0638: {0xFEC5, 4},
+	0x0639: {0xFEC9, 4}, 0x063A: {0xFECD, 4}, 0x0641: {0xFED1, 4}, 0x0642: {0xFED5, 4},
+	0x0643: {0xFED9, 4}, 0x0644: {0xFEDD, 4}, 0x0645: {0xFEE1, 4}, 0x0646: {0xFEE5, 4},
+	0x0647: {0xFEE9, 4}, 0x0648: {0xFEED, 2}, 0x0649: {0xFEEF, 2}, 0x064A: {0xFEF1, 4},
+}
+
+// lamAlef maps the alefs that form a ligature with a preceding lam to the
+// isolated form of the ligature; the final form follows it.
+var lamAlef = map[rune]rune{
+	0x0622: 0xFEF5, 0x0623: 0xFEF7, 0x0625: 0xFEF9, 0x0627: 0xFEFB,
+}
+
+const (
+	arabicLam     = 0x0644
+	arabicTatweel = 0x0640
+)
+
+// joinsBefore reports whether r connects to the letter before it.
+func joinsBefore(r rune) bool {
+	f, ok := arabicForms[r]
+	return r == arabicTatweel || (ok && f.forms > 1)
+}
+
+// joinsAfter reports whether r connects to the letter after it.
+func joinsAfter(r rune) bool {
+	f, ok := arabicForms[r]
+	return r == arabicTatweel || (ok && f.forms == 4)
+}
+
+// shapeArabic replaces Arabic letters with the presentation form for their
+// position in the word, which the font draws joined, since the renderer has no
+// shaping of its own. Combining marks don't break a join.
+func shapeArabic(runes []rune) []rune {
+	transparent := func(r rune) bool { return classify(r) == bidiNSM }
+	// neighbor returns the nearest non-mark rune from i in direction step
+	neighbor := func(i, step int) rune {
+		for i += step; i >= 0 && i < len(runes); i += step {
+			if !transparent(runes[i]) {
+				return runes[i]
+			}
+		}
+		return 0
+	}
+
+	shaped := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		f, ok := arabicForms[r]
+		if !ok {
+			shaped = append(shaped, r)
+			continue
+		}
+		joinPrev := joinsAfter(neighbor(i, -1)) && f.forms > 1
+
+		if r == arabicLam && i+1 < len(runes) {
+			if ligature, ok := lamAlef[runes[i+1]]; ok {
+				if joinPrev {
+					ligature++
+				}
+				shaped = append(shaped, ligature)
+				i++
+				continue
+			}
+		}
+
+		joinNext := f.forms == 4 && joinsBefore(neighbor(i, 1))
+		form := f.isolated
+		switch {
+		case joinPrev && joinNext:
+			form += 3
+		case joinNext:
+			form += 2
+		case joinPrev:
+			form++
+		}
+		shaped = append(shaped, form)
+	}
+	return shaped
+}
@@ -0,0 +1,80 @@
+package overlay
+
+import (
+	"image/color"
+	"math/rand"
+	"time"
+)
+
+// Color phases of the burn-in protection, each lasting one cycle.
+const (
+	BurnInNormal = iota
+	BurnInInverted
+	BurnInSoftened
+	burnInPhases
+)
+
+// BurnIn is how one render varies the panels to spread the wear on always-on
+// displays (lock_screen.burn_in). The zero value varies nothing.
+type BurnIn struct {
+	// DX and DY move every panel by that many pixels.
+	DX, DY float64
+	// Phase is the color phase, BurnInNormal, BurnInInverted or
+	// BurnInSoftened.
+	Phase int
+}
+
+// NewBurnIn returns the variation for a render at now: an offset of up to
+// shift pixels each way picked with rnd, and the color phase of the cycle now
+// falls in.
+func NewBurnIn(shift int, cycle time.Duration, now time.Time, rnd *rand.Rand) BurnIn {
+	phase := 0
+	if seconds := int64(cycle.Seconds()); seconds > 0 {
+		phase = int(now.Unix()/seconds) % burnInPhases
+	}
+	return BurnIn{
+		DX:    float64(rnd.Intn(2*shift+1) - shift),
+		DY:    float64(rnd.Intn(2*shift+1) - shift),
+		Phase: phase,
+	}
+}
+
+// colors applies the color phase to a panel's color scheme. Inverted swaps the
+// light and dark schemes; softened halves the background and dims the text, so
+// no pixel shows the same bright value for days.
+func (b BurnIn) colors(c TextColor) TextColor {
+	switch b.Phase {
+	case BurnInInverted:
+		if c == DarkOnLight() {
+			return LightOnDark()
+		}
+		return DarkOnLight()
+	case BurnInSoftened:
+		return TextColor{
+			Text:       scaleAlpha(c.Text, 0.7),
+			Background: scaleAlpha(c.Background, 0.5),
+			Border:     color.RGBA{},
+			Alert:      scaleAlpha(c.Alert, 0.7),
+			Warning:    scaleAlpha(c.Warning, 0.7),
+		}
+	}
+	return c
+}
+
+// scaleAlpha returns c with its opacity multiplied by factor.
+func scaleAlpha(c color.Color, factor float64) color.Color {
+	if c == nil {
+		return nil
+	}
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	// RGBA() is premultiplied; NRGBA wants the plain color
+	return color.NRGBA{
+		R: uint8((r * 0xffff / a) >> 8),
+		G: uint8((g * 0xffff / a) >> 8),
+		B: uint8((b * 0xffff / a) >> 8),
+		A: uint8(float64(a>>8) * factor),
+	}
+}
@@ -0,0 +1,108 @@
+package overlay
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// fallbackFonts are Windows fonts with the Hebrew and Arabic (including the
+// Arabic presentation forms) the embedded font lacks, tried in order.
+var fallbackFonts = []string{"tahoma.ttf", "arial.ttf", "segoeui.ttf"}
+
+var (
+	parsedFallbackFont    *truetype.Font
+	parseFallbackFontOnce sync.Once
+)
+
+// loadFallbackFont parses the first available fallback font from the Windows
+// fonts folder, once per process. It returns nil when there is none.
+func loadFallbackFont() *truetype.Font {
+	parseFallbackFontOnce.Do(func() {
+		windir := os.Getenv("WINDIR")
+		if windir == "" {
+			windir = `C:\Windows`
+		}
+		for _, name := range fallbackFonts {
+			data, err := os.ReadFile(filepath.Join(windir, "Fonts", name))
+			if err != nil {
+				continue
+			}
+			if f, err := truetype.Parse(data); err == nil {
+				parsedFallbackFont = f
+				return
+			}
+		}
+	})
+	return parsedFallbackFont
+}
+
+// fallbackFace draws the glyphs the primary font has with it and the others
+// with the fallback font, which is only loaded once such a glyph is drawn.
+type fallbackFace struct {
+	font.Face
+	primary *truetype.Font
+	points  float64
+
+	fallback      font.Face
+	fallbackFont  *truetype.Font
+	fallbackTried bool
+}
+
+func newFallbackFace(primary *truetype.Font, points float64) *fallbackFace {
+	return &fallbackFace{
+		Face:    truetype.NewFace(primary, &truetype.Options{Size: points}),
+		primary: primary,
+		points:  points,
+	}
+}
+
+// faceFor returns the face that has a glyph for r.
+func (f *fallbackFace) faceFor(r rune) font.Face {
+	if r < 0x0590 || f.primary.Index(r) != 0 {
+		return f.Face
+	}
+	if !f.fallbackTried {
+		f.fallbackTried = true
+		if f.fallbackFont = loadFallbackFont(); f.fallbackFont != nil {
+			f.fallback = truetype.NewFace(f.fallbackFont, &truetype.Options{Size: f.points})
+		}
+	}
+	if f.fallback != nil && f.fallbackFont.Index(r) != 0 {
+		return f.fallback
+	}
+	return f.Face
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.faceFor(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.faceFor(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.faceFor(r).GlyphAdvance(r)
+}
+
+// Kern only kerns pairs from the same font.
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	face := f.faceFor(r0)
+	if face != f.faceFor(r1) {
+		return 0
+	}
+	return face.Kern(r0, r1)
+}
+
+func (f *fallbackFace) Close() error {
+	if f.fallback != nil {
+		f.fallback.Close()
+	}
+	return f.Face.Close()
+}
@@ -0,0 +1,184 @@
+package overlay
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/backgroundchanger/internal/sysinfo"
+)
+
+// update rewrites the golden images instead of comparing against them:
+// go test ./internal/overlay -update
+var update = flag.Bool("update", false, "rewrite the golden PNGs in testdata")
+
+// Renders differ slightly between CPUs (fused multiply-add in the
+// rasterizer), so a channel may be off by a few levels and a few antialiased
+// edge pixels may differ.
+const (
+	goldenChannelTolerance = 8
+	goldenMaxDiffRatio     = 0.001
+)
+
+// goldenBackgrounds are plain backgrounds, one per automatic color scheme.
+var goldenBackgrounds = []struct {
+	name  string
+	color color.Color
+}{
+	{"dark", color.Black},
+	{"light", color.RGBA{0xe8, 0xe8, 0xe8, 0xff}},
+}
+
+func TestRenderDualPanelOverlayGolden(t *testing.T) {
+	infoLines := sysinfo.FixtureSystemInfo().FormatLines()
+	serviceLines := sysinfo.FixtureServices().FormatServiceLines()
+
+	for _, size := range []sysinfo.DisplayResolution{{Width: 1280, Height: 720}, {Width: 1920, Height: 1080}} {
+		for _, bg := range goldenBackgrounds {
+			name := fmt.Sprintf("dual_%dx%d_%s", size.Width, size.Height, bg.name)
+			t.Run(name, func(t *testing.T) {
+				img, err := RenderDualPanelOverlayForDisplay(plainBackground(size, bg.color), serviceLines, infoLines, size, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGolden(t, name, img)
+			})
+		}
+	}
+}
+
+// The right-to-left layout uses the English fixture lines, so the render
+// doesn't depend on the Windows fonts Hebrew and Arabic fall back to.
+func TestRenderDualPanelOverlayRightToLeftGolden(t *testing.T) {
+	infoLines := sysinfo.FixtureSystemInfo().FormatLines()
+	serviceLines := sysinfo.FixtureServices().FormatServiceLines()
+	size := sysinfo.DisplayResolution{Width: 1280, Height: 720}
+
+	name := fmt.Sprintf("dual_rtl_%dx%d_dark", size.Width, size.Height)
+	img, err := RenderDualPanelOverlayForDisplay(plainBackground(size, color.Black), serviceLines, infoLines, size, Options{RightToLeft: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, name, img)
+}
+
+// The themed render replaces the panel colors and draws a logo, written to a
+// temporary PNG, below the services panel.
+func TestRenderDualPanelOverlayThemeGolden(t *testing.T) {
+	infoLines := sysinfo.FixtureSystemInfo().FormatLines()
+	serviceLines := sysinfo.FixtureServices().FormatServiceLines()
+	size := sysinfo.DisplayResolution{Width: 1280, Height: 720}
+
+	logo := image.NewRGBA(image.Rect(0, 0, 160, 40))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{0x00, 0x5a, 0x9c, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(logo, image.Rect(10, 10, 150, 30), image.NewUniform(color.RGBA{0xff, 0xc8, 0x00, 0xff}), image.Point{}, draw.Src)
+	logoPath := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, logo); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	theme := Theme{Text: "#FFE9A8", Panel: "#102A43", Border: "#F0B429", PanelOpacity: 0.85, Logo: logoPath}
+	name := fmt.Sprintf("dual_theme_%dx%d_dark", size.Width, size.Height)
+	img, err := RenderDualPanelOverlayForDisplay(plainBackground(size, color.Black), serviceLines, infoLines, size, Options{Theme: theme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, name, img)
+}
+
+func TestRenderOverlayGolden(t *testing.T) {
+	infoLines := sysinfo.FixtureSystemInfo().FormatLines()
+	size := sysinfo.DisplayResolution{Width: 1920, Height: 1080}
+
+	for _, bg := range goldenBackgrounds {
+		name := fmt.Sprintf("single_%dx%d_%s", size.Width, size.Height, bg.name)
+		t.Run(name, func(t *testing.T) {
+			img, err := RenderOverlay(plainBackground(size, bg.color), infoLines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, name, img)
+		})
+	}
+}
+
+// plainBackground returns an image of the given size filled with c.
+func plainBackground(size sysinfo.DisplayResolution, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size.Width, size.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// checkGolden compares img against testdata/name.png, or writes it there with
+// -update.
+func checkGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+
+	if *update {
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if err := png.Encode(file, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	defer file.Close()
+	want, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", path, err)
+	}
+
+	if img.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("render is %v, %s is %v", img.Bounds().Size(), path, want.Bounds().Size())
+	}
+	diff := diffPixels(img, want)
+	total := img.Bounds().Dx() * img.Bounds().Dy()
+	if float64(diff) > float64(total)*goldenMaxDiffRatio {
+		t.Errorf("%d of %d pixels differ from %s", diff, total, path)
+	}
+}
+
+// diffPixels counts the pixels of a and b, which have the same size, that
+// differ by more than goldenChannelTolerance in any channel.
+func diffPixels(a, b image.Image) int {
+	ab, bb := a.Bounds(), b.Bounds()
+	diff := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			c1 := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			c2 := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			if channelDiff(c1.R, c2.R) > goldenChannelTolerance || channelDiff(c1.G, c2.G) > goldenChannelTolerance ||
+				channelDiff(c1.B, c2.B) > goldenChannelTolerance || channelDiff(c1.A, c2.A) > goldenChannelTolerance {
+				diff++
+			}
+		}
+	}
+	return diff
+}
+
+func channelDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
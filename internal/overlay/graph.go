@@ -0,0 +1,173 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// Baseline graph dimensions (for 1920x1080), scaled like the text panels.
+const (
+	BaseGraphWidth  = 360
+	BaseGraphHeight = 44
+)
+
+// GraphPoint is a single value at a point in time.
+type GraphPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// GraphSeries is one line of the trend graph.
+type GraphSeries struct {
+	// Label is drawn above the chart, e.g. "CPU  12% now, peak 98%".
+	Label  string
+	Points []GraphPoint
+	// Max is the value at the top of the chart (e.g. 100 for percentages).
+	Max   float64
+	Color color.Color
+}
+
+// Series colors chosen to stay readable on both the dark and light panel backgrounds.
+var (
+	GraphColorCPU     = color.RGBA{255, 140, 0, 255}
+	GraphColorMemory  = color.RGBA{30, 144, 255, 255}
+	GraphColorNetwork = color.RGBA{46, 180, 90, 255}
+)
+
+// RenderHistoryGraph draws a panel of small trend charts in the lower-right
+// corner of the image, bottomInset pixels higher to clear the banner. Each
+// series gets its own row covering [now-window, now].
+func RenderHistoryGraph(img image.Image, title string, series []GraphSeries, window time.Duration, opts Options, now time.Time, bottomInset float64) (image.Image, error) {
+	bounds := img.Bounds()
+	width := bounds.Max.X - bounds.Min.X
+	height := bounds.Max.Y - bounds.Min.Y
+
+	dims := CalculateScaledDimensionsForDisplay(opts)
+	chartWidth := BaseGraphWidth * dims.ScaleFactor
+	chartHeight := BaseGraphHeight * dims.ScaleFactor
+	lineHeight := dims.FontSize + dims.LineSpacing
+
+	dc := newContext(img)
+
+	err := setFontFace(dc, dims.FontSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+
+	// Size the panel to fit the widest label or the chart, whichever is larger
+	contentWidth := chartWidth
+	if w, _ := dc.MeasureString(title); w > contentWidth {
+		contentWidth = w
+	}
+	for _, s := range series {
+		if w, _ := dc.MeasureString(s.Label); w > contentWidth {
+			contentWidth = w
+		}
+	}
+	chartWidth = contentWidth
+
+	rowHeight := lineHeight + chartHeight + dims.LineSpacing
+	boxWidth := contentWidth + dims.Padding*2
+	boxHeight := lineHeight + float64(len(series))*rowHeight + dims.Padding*2 - dims.LineSpacing
+	wear := opts.BurnIn
+	boxX := float64(width) - boxWidth - dims.MarginRight + wear.DX
+	// Mirrored like the panels in a right-to-left layout
+	if opts.RightToLeft {
+		boxX = dims.MarginLeft + wear.DX
+	}
+	boxY := float64(height) - boxHeight - dims.MarginTop - bottomInset + wear.DY
+
+	colors := LightOnDark()
+	if AnalyzeRegionBrightness(img, int(boxX), int(boxY), int(boxWidth), int(boxHeight)) {
+		colors = DarkOnLight()
+	}
+	colors = wear.colors(colors)
+
+	drawPanel(dc, boxX, boxY, boxWidth, boxHeight, dims, colors, []string{title}, false)
+
+	start := now.Add(-window)
+	y := boxY + dims.Padding + lineHeight
+	for _, s := range series {
+		// Label
+		setColor(dc, colors.Text)
+		dc.DrawString(s.Label, boxX+dims.Padding, y+dims.FontSize)
+		y += lineHeight
+
+		// Chart frame
+		chartX := boxX + dims.Padding
+		setColor(dc, colors.Border)
+		dc.SetLineWidth(1)
+		dc.DrawRectangle(chartX, y, chartWidth, chartHeight)
+		dc.Stroke()
+
+		drawSeries(dc, s, chartX, y, chartWidth, chartHeight, start, window)
+		y += chartHeight + dims.LineSpacing
+	}
+
+	return dc.Image(), nil
+}
+
+// drawSeries plots the points as a filled area with a line on top.
+func drawSeries(dc *gg.Context, s GraphSeries, x, y, w, h float64, start time.Time, window time.Duration) {
+	max := s.Max
+	if max <= 0 {
+		max = 1
+	}
+
+	var xs, ys []float64
+	for _, p := range s.Points {
+		if p.Time.Before(start) {
+			continue
+		}
+		v := p.Value / max
+		if v > 1 {
+			v = 1
+		}
+		if v < 0 {
+			v = 0
+		}
+		xs = append(xs, x+w*float64(p.Time.Sub(start))/float64(window))
+		ys = append(ys, y+h-h*v)
+	}
+	if len(xs) == 0 {
+		return
+	}
+
+	r, g, b, _ := s.Color.RGBA()
+	fr, fg, fb := float64(r)/65535, float64(g)/65535, float64(b)/65535
+
+	// A single sample is drawn as a dot so it's still visible
+	if len(xs) == 1 {
+		dc.SetRGBA(fr, fg, fb, 1)
+		dc.DrawCircle(xs[0], ys[0], 2)
+		dc.Fill()
+		return
+	}
+
+	dc.MoveTo(xs[0], y+h)
+	for i := range xs {
+		dc.LineTo(xs[i], ys[i])
+	}
+	dc.LineTo(xs[len(xs)-1], y+h)
+	dc.ClosePath()
+	dc.SetRGBA(fr, fg, fb, 0.35)
+	dc.Fill()
+
+	dc.MoveTo(xs[0], ys[0])
+	for i := 1; i < len(xs); i++ {
+		dc.LineTo(xs[i], ys[i])
+	}
+	dc.SetRGBA(fr, fg, fb, 1)
+	dc.SetLineWidth(1.5)
+	dc.Stroke()
+}
+
+// setColor sets the drawing color from a color.Color.
+func setColor(dc *gg.Context, c color.Color) {
+	r, g, b, a := c.RGBA()
+	dc.SetRGBA(float64(r)/65535, float64(g)/65535, float64(b)/65535, float64(a)/65535)
+}
@@ -0,0 +1,132 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Notice text sizes relative to the panel font size.
+const (
+	NoticeTitleScale    = 3.0
+	NoticeSubtitleScale = 1.5
+)
+
+// NoticeOptions describes a full-screen notice.
+type NoticeOptions struct {
+	// Text is the main message; "\n" starts a new line and long lines are wrapped.
+	Text string
+	// Subtitle is an optional smaller line below the message.
+	Subtitle   string
+	Background color.Color
+	Foreground color.Color
+	// Logo is an optional image drawn centered above the message.
+	Logo image.Image
+}
+
+// DefaultNoticeColors returns the colors used when a notice doesn't set its own.
+func DefaultNoticeColors() (background, foreground color.Color) {
+	return color.RGBA{20, 24, 32, 255}, color.RGBA{255, 255, 255, 255}
+}
+
+// RenderNotice generates a full-screen image with large centered text, ignoring
+// any wallpaper. Used for kiosk notices such as "This system is reserved for
+// Building Security".
+func RenderNotice(width, height int, opts NoticeOptions) (image.Image, error) {
+	background, foreground := DefaultNoticeColors()
+	if opts.Background != nil {
+		background = opts.Background
+	}
+	if opts.Foreground != nil {
+		foreground = opts.Foreground
+	}
+
+	dims := CalculateScaledDimensions(width, height)
+	titleSize := dims.FontSize * NoticeTitleScale
+	subtitleSize := dims.FontSize * NoticeSubtitleScale
+	maxTextWidth := float64(width) * 0.8
+
+	dc := gg.NewContext(width, height)
+	setColor(dc, background)
+	dc.Clear()
+
+	// Measure everything first so the whole block can be centered vertically
+	err := setFontFace(dc, titleSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+	var titleLines []string
+	for _, paragraph := range strings.Split(opts.Text, "\n") {
+		titleLines = append(titleLines, dc.WordWrap(paragraph, maxTextWidth)...)
+	}
+	titleLineHeight := titleSize * 1.3
+
+	var subtitleLines []string
+	subtitleLineHeight := subtitleSize * 1.3
+	if opts.Subtitle != "" {
+		err = setFontFace(dc, subtitleSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load font: %v", err)
+		}
+		for _, paragraph := range strings.Split(opts.Subtitle, "\n") {
+			subtitleLines = append(subtitleLines, dc.WordWrap(paragraph, maxTextWidth)...)
+		}
+	}
+
+	// Logo is limited to a quarter of the screen height and 60% of its width
+	var logoWidth, logoHeight, logoScale float64
+	if opts.Logo != nil {
+		lb := opts.Logo.Bounds()
+		logoScale = 1.0
+		if h := float64(height) / 4; float64(lb.Dy()) > h {
+			logoScale = h / float64(lb.Dy())
+		}
+		if w := float64(width) * 0.6; float64(lb.Dx())*logoScale > w {
+			logoScale = w / float64(lb.Dx())
+		}
+		logoWidth = float64(lb.Dx()) * logoScale
+		logoHeight = float64(lb.Dy()) * logoScale
+	}
+
+	gap := dims.Padding * 2
+	blockHeight := float64(len(titleLines)) * titleLineHeight
+	if logoHeight > 0 {
+		blockHeight += logoHeight + gap
+	}
+	if len(subtitleLines) > 0 {
+		blockHeight += gap + float64(len(subtitleLines))*subtitleLineHeight
+	}
+
+	y := (float64(height) - blockHeight) / 2
+	centerX := float64(width) / 2
+
+	if opts.Logo != nil {
+		dc.Push()
+		dc.Translate(centerX-logoWidth/2, y)
+		dc.Scale(logoScale, logoScale)
+		dc.DrawImage(opts.Logo, 0, 0)
+		dc.Pop()
+		y += logoHeight + gap
+	}
+
+	setColor(dc, foreground)
+	setFontFace(dc, titleSize)
+	for _, line := range titleLines {
+		dc.DrawStringAnchored(visualLine(line, false), centerX, y+titleLineHeight/2, 0.5, 0.35)
+		y += titleLineHeight
+	}
+
+	if len(subtitleLines) > 0 {
+		y += gap
+		setFontFace(dc, subtitleSize)
+		for _, line := range subtitleLines {
+			dc.DrawStringAnchored(visualLine(line, false), centerX, y+subtitleLineHeight/2, 0.5, 0.35)
+			y += subtitleLineHeight
+		}
+	}
+
+	return dc.Image(), nil
+}
@@ -0,0 +1,35 @@
+package overlay
+
+import "github.com/backgroundchanger/internal/config"
+
+// Options are the settings a render takes from the configuration. The caller
+// resolves them once per run; the zero value renders with the defaults.
+type Options struct {
+	// TextScale multiplies the text size on top of the display scaling
+	// (text_scale). 0 means 1.
+	TextScale float64
+	// ServicesTextScale and SystemTextScale multiply the text of the services
+	// and system info panels on top of TextScale (panel_text_scale). 0 means
+	// 1.
+	ServicesTextScale float64
+	SystemTextScale   float64
+	// Theme replaces the automatic panel colors and adds a logo.
+	Theme Theme
+	// ImageLimits bounds the theme logo before it is decoded (image_limits).
+	ImageLimits config.ImageLimitsConfig
+	// RightToLeft mirrors the layout for right-to-left languages: services on
+	// the right, system info on the left, text aligned to the right, and the
+	// graph and badge swapped (layout_direction).
+	RightToLeft bool
+	// BurnIn moves and recolors the panels for this render; the zero value
+	// leaves them in place.
+	BurnIn BurnIn
+}
+
+// factor returns a scale option, 1 when it is unset.
+func factor(scale float64) float64 {
+	if scale == 0 {
+		return 1
+	}
+	return scale
+}
@@ -1,4 +1,6 @@
 // Package overlay provides functionality for rendering text overlays on images.
+// All rendering functions are safe for concurrent use as long as each call gets
+// its own image: an *image.RGBA passed in is drawn on in place.
 package overlay
 
 import (
@@ -6,56 +8,54 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"os"
-	"path/filepath"
+	"math"
+	"strings"
 	"sync"
 
 	"github.com/backgroundchanger/internal/sysinfo"
 	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
 )
 
 //go:embed fonts/JetBrainsMono-Regular.ttf
 var fontData embed.FS
 
 var (
-	cachedFontPath string
-	fontPathOnce   sync.Once
-	fontPathErr    error
+	parsedFont    *truetype.Font
+	parseFontOnce sync.Once
+	parseFontErr  error
 )
 
-// getFontPath extracts the embedded font to a temp file and returns its path.
-// The font is only extracted once and cached.
-func getFontPath() (string, error) {
-	fontPathOnce.Do(func() {
-		// Read the embedded font
+// loadFont parses the embedded font straight from memory, once per process.
+// Nothing is written to disk, so it works for every user and survives temp
+// directory cleanup. The parsed font is read-only and shared by all renders.
+func loadFont() (*truetype.Font, error) {
+	parseFontOnce.Do(func() {
 		fontBytes, err := fontData.ReadFile("fonts/JetBrainsMono-Regular.ttf")
 		if err != nil {
-			fontPathErr = fmt.Errorf("failed to read embedded font: %v", err)
+			parseFontErr = fmt.Errorf("failed to read embedded font: %v", err)
 			return
 		}
 
-		// Create temp directory for the font
-		tempDir := filepath.Join(os.TempDir(), "bgstatusservice")
-		err = os.MkdirAll(tempDir, 0755)
+		parsedFont, err = truetype.Parse(fontBytes)
 		if err != nil {
-			fontPathErr = fmt.Errorf("failed to create temp dir: %v", err)
-			return
-		}
-
-		// Write font to temp file
-		cachedFontPath = filepath.Join(tempDir, "JetBrainsMono-Regular.ttf")
-		err = os.WriteFile(cachedFontPath, fontBytes, 0644)
-		if err != nil {
-			fontPathErr = fmt.Errorf("failed to write font file: %v", err)
-			return
+			parseFontErr = fmt.Errorf("failed to parse embedded font: %v", err)
 		}
 	})
 
-	if fontPathErr != nil {
-		return "", fontPathErr
-	}
+	return parsedFont, parseFontErr
+}
 
-	return cachedFontPath, nil
+// setFontFace sets the embedded font at the given size (in points) on dc,
+// falling back to a Windows font for Hebrew and Arabic. Each context gets its
+// own face, since faces cache glyphs and are not safe for concurrent use.
+func setFontFace(dc *gg.Context, points float64) error {
+	f, err := loadFont()
+	if err != nil {
+		return err
+	}
+	dc.SetFontFace(newFallbackFace(f, points))
+	return nil
 }
 
 // Baseline dimensions (designed for 1920x1080)
@@ -85,6 +85,11 @@ const (
 	MaxScaleFactor = 1.0
 	// MinFontSize is the minimum font size for readability.
 	MinFontSize = 12
+
+	// BaseDPI is the DPI of a monitor at 100% display scaling.
+	BaseDPI = 96
+	// MaxDensityFactor caps the extra scaling for dense (high-DPI) monitors.
+	MaxDensityFactor = 3.0
 )
 
 // Legacy constants for backward compatibility
@@ -118,10 +123,48 @@ func CalculateScaledDimensions(width, height int) ScaledDimensions {
 // CalculateScaledDimensionsForDisplay calculates scaled dimensions based on the actual
 // display resolution, which may differ from the image resolution.
 // This ensures text is readable regardless of the image size.
-func CalculateScaledDimensionsForDisplay() ScaledDimensions {
+func CalculateScaledDimensionsForDisplay(opts Options) ScaledDimensions {
 	// Query the actual display resolution
-	displayRes := sysinfo.GetDisplayResolution()
-	return calculateScaledDimensionsForResolution(displayRes.Width, displayRes.Height)
+	return calculateScaledDimensionsForMonitor(sysinfo.GetDisplayResolution(), opts)
+}
+
+// calculateScaledDimensionsForMonitor scales for the display's resolution, then
+// for its pixel density and the text scale, so text has about the same
+// physical size on a 13" 4K laptop as on a 43" 4K TV.
+func calculateScaledDimensionsForMonitor(display sysinfo.DisplayResolution, opts Options) ScaledDimensions {
+	dims := calculateScaledDimensionsForResolution(display.Width, display.Height)
+	return scaleDimensions(dims, densityFactor(display)*factor(opts.TextScale))
+}
+
+// densityFactor is how much larger text must be drawn on the display than on
+// a 96 DPI monitor. The Windows display scaling is used when it is above 100%,
+// since it was picked for the monitor's size and viewing distance; otherwise
+// the physical pixel density from the EDID size. Never below 1.
+func densityFactor(display sysinfo.DisplayResolution) float64 {
+	factor := 1.0
+	if display.DPI > BaseDPI {
+		factor = float64(display.DPI) / BaseDPI
+	} else if display.DiagonalInches > 0 {
+		ppi := math.Hypot(float64(display.Width), float64(display.Height)) / display.DiagonalInches
+		factor = ppi / BaseDPI
+	}
+	return math.Min(math.Max(factor, 1), MaxDensityFactor)
+}
+
+// scaleDimensions multiplies every dimension by factor.
+func scaleDimensions(dims ScaledDimensions, factor float64) ScaledDimensions {
+	if factor == 1 {
+		return dims
+	}
+	dims.FontSize *= factor
+	dims.Padding *= factor
+	dims.LineSpacing *= factor
+	dims.CornerRadius *= factor
+	dims.MarginRight *= factor
+	dims.MarginLeft *= factor
+	dims.MarginTop *= factor
+	dims.ScaleFactor *= factor
+	return dims
 }
 
 // calculateScaledDimensionsForResolution is the internal implementation that calculates
@@ -170,6 +213,40 @@ type TextColor struct {
 	Text       color.Color
 	Background color.Color
 	Border     color.Color
+	// Alert is the color of lines with an alert word (see AlertWords); Text
+	// is used when it is nil.
+	Alert color.Color
+	// Warning is the color of lines with a warning word (see WarningWords);
+	// Text is used when it is nil.
+	Warning color.Color
+}
+
+// AlertWords mark a line as an alert, e.g. "Veeam Agent: FAILED, 6h ago",
+// drawn in the alert color.
+var AlertWords = []string{"FAILED", "OVERDUE", "MISSING", "NOT RUNNING", "NOT escrowed", "CRITICAL"}
+
+// WarningWords mark a line as a warning, e.g. "uptime_days: 35 WARNING (> 30)",
+// drawn in the warning color unless it is also an alert.
+var WarningWords = []string{"WARNING", "reboot recommended"}
+
+// isAlert reports whether a line contains an alert word.
+func isAlert(line string) bool {
+	for _, word := range AlertWords {
+		if strings.Contains(line, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWarning reports whether a line contains a warning word.
+func isWarning(line string) bool {
+	for _, word := range WarningWords {
+		if strings.Contains(line, word) {
+			return true
+		}
+	}
+	return false
 }
 
 // LightOnDark returns a color scheme for dark backgrounds (white text).
@@ -178,6 +255,8 @@ func LightOnDark() TextColor {
 		Text:       color.RGBA{255, 255, 255, 255},
 		Background: color.RGBA{0, 0, 0, 160},
 		Border:     color.RGBA{255, 255, 255, 80},
+		Alert:      color.RGBA{255, 107, 107, 255},
+		Warning:    color.RGBA{255, 196, 87, 255},
 	}
 }
 
@@ -187,6 +266,8 @@ func DarkOnLight() TextColor {
 		Text:       color.RGBA{0, 0, 0, 255},
 		Background: color.RGBA{255, 255, 255, 180},
 		Border:     color.RGBA{0, 0, 0, 80},
+		Alert:      color.RGBA{192, 0, 0, 255},
+		Warning:    color.RGBA{166, 100, 0, 255},
 	}
 }
 
@@ -268,21 +349,10 @@ func ChooseTextColor(img image.Image, boxWidth, boxHeight int) TextColor {
 func RenderOverlay(img image.Image, lines []string) (image.Image, error) {
 	bounds := img.Bounds()
 	width := bounds.Max.X - bounds.Min.X
-	height := bounds.Max.Y - bounds.Min.Y
 
-	// Create a new drawing context
-	dc := gg.NewContext(width, height)
+	dc := newContext(img)
 
-	// Draw the original image
-	dc.DrawImage(img, 0, 0)
-
-	// Load the font
-	fontFile, err := getFontPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get font path: %v", err)
-	}
-
-	err = dc.LoadFontFace(fontFile, FontSize)
+	err := setFontFace(dc, FontSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load font: %v", err)
 	}
@@ -341,21 +411,10 @@ func RenderOverlay(img image.Image, lines []string) (image.Image, error) {
 func RenderOverlayWithColors(img image.Image, lines []string, colors TextColor) (image.Image, error) {
 	bounds := img.Bounds()
 	width := bounds.Max.X - bounds.Min.X
-	height := bounds.Max.Y - bounds.Min.Y
 
-	// Create a new drawing context
-	dc := gg.NewContext(width, height)
+	dc := newContext(img)
 
-	// Draw the original image
-	dc.DrawImage(img, 0, 0)
-
-	// Load the font
-	fontFile, err := getFontPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get font path: %v", err)
-	}
-
-	err = dc.LoadFontFace(fontFile, FontSize)
+	err := setFontFace(dc, FontSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load font: %v", err)
 	}
@@ -410,17 +469,21 @@ func RenderOverlayWithColors(img image.Image, lines []string, colors TextColor)
 // RenderDualPanelOverlay renders two panels on an image - services on the left, system info on the right.
 // This function uses resolution-aware scaling to ensure readability at different resolutions.
 // It queries the actual display resolution to determine proper text scaling.
-func RenderDualPanelOverlay(img image.Image, leftLines []string, rightLines []string) (image.Image, error) {
+func RenderDualPanelOverlay(img image.Image, leftLines []string, rightLines []string, opts Options) (image.Image, error) {
+	return RenderDualPanelOverlayForDisplay(img, leftLines, rightLines, sysinfo.GetDisplayResolution(), opts)
+}
+
+// RenderDualPanelOverlayForDisplay is RenderDualPanelOverlay for a given display
+// resolution instead of the detected one, so the output doesn't depend on the
+// machine (used by the fixture renders).
+func RenderDualPanelOverlayForDisplay(img image.Image, leftLines []string, rightLines []string, displayRes sysinfo.DisplayResolution, opts Options) (image.Image, error) {
 	bounds := img.Bounds()
 	width := bounds.Max.X - bounds.Min.X
 	height := bounds.Max.Y - bounds.Min.Y
 
-	// Get the actual display resolution for proper scaling
-	displayRes := sysinfo.GetDisplayResolution()
-
 	// Calculate scaled dimensions based on display resolution (for text readability)
 	// but we also need to account for the image dimensions for positioning
-	dims := CalculateScaledDimensionsForDisplay()
+	dims := calculateScaledDimensionsForMonitor(displayRes, opts)
 
 	// If the image dimensions differ significantly from the display resolution,
 	// we need to adjust margins proportionally to the image size
@@ -433,52 +496,40 @@ func RenderDualPanelOverlay(img image.Image, leftLines []string, rightLines []st
 	dims.MarginRight = dims.MarginRight * imageScaleX
 	dims.MarginTop = dims.MarginTop * imageScaleY
 
-	// Create a new drawing context
-	dc := gg.NewContext(width, height)
+	dc := newContext(img)
 
-	// Draw the original image
-	dc.DrawImage(img, 0, 0)
+	// Each panel's text can be made larger or smaller than the other's
+	leftDims := panelDimensions(dims, factor(opts.ServicesTextScale))
+	rightDims := panelDimensions(dims, factor(opts.SystemTextScale))
 
-	// Load the font
-	fontFile, err := getFontPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get font path: %v", err)
+	// Hebrew and Arabic are drawn in visual order. A right-to-left layout
+	// mirrors the panels: services on the right, system info on the left,
+	// text aligned to the right
+	rtl := opts.RightToLeft
+	leftLines, rightLines = visualLines(leftLines, rtl), visualLines(rightLines, rtl)
+	if rtl {
+		leftLines, rightLines = rightLines, leftLines
+		leftDims, rightDims = rightDims, leftDims
 	}
 
-	err = dc.LoadFontFace(fontFile, dims.FontSize)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load font: %v", err)
-	}
-
-	lineHeight := dims.FontSize + dims.LineSpacing
-
 	// Calculate dimensions for left panel (services)
-	var leftMaxWidth float64
-	for _, line := range leftLines {
-		w, _ := dc.MeasureString(line)
-		if w > leftMaxWidth {
-			leftMaxWidth = w
-		}
+	leftBoxWidth, leftBoxHeight, err := measurePanel(dc, leftDims, leftLines)
+	if err != nil {
+		return nil, err
 	}
-	leftTextHeight := lineHeight * float64(len(leftLines))
-	leftBoxWidth := leftMaxWidth + (dims.Padding * 2)
-	leftBoxHeight := leftTextHeight + (dims.Padding * 2) - dims.LineSpacing
 
 	// Calculate dimensions for right panel (system info)
-	var rightMaxWidth float64
-	for _, line := range rightLines {
-		w, _ := dc.MeasureString(line)
-		if w > rightMaxWidth {
-			rightMaxWidth = w
-		}
+	rightBoxWidth, rightBoxHeight, err := measurePanel(dc, rightDims, rightLines)
+	if err != nil {
+		return nil, err
 	}
-	rightTextHeight := lineHeight * float64(len(rightLines))
-	rightBoxWidth := rightMaxWidth + (dims.Padding * 2)
-	rightBoxHeight := rightTextHeight + (dims.Padding * 2) - dims.LineSpacing
+
+	// Move the panels a little on every render when burn-in protection is on
+	wear := opts.BurnIn
 
 	// Choose colors based on left region brightness
-	leftBoxX := dims.MarginLeft
-	leftBoxY := dims.MarginTop
+	leftBoxX := dims.MarginLeft + wear.DX
+	leftBoxY := dims.MarginTop + wear.DY
 	leftIsLight := AnalyzeRegionBrightness(img, int(leftBoxX), int(leftBoxY), int(leftBoxWidth), int(leftBoxHeight))
 	var leftColors TextColor
 	if leftIsLight {
@@ -486,10 +537,11 @@ func RenderDualPanelOverlay(img image.Image, leftLines []string, rightLines []st
 	} else {
 		leftColors = LightOnDark()
 	}
+	leftColors = wear.colors(themeColors(opts.Theme, leftColors))
 
 	// Choose colors based on right region brightness
-	rightBoxX := float64(width) - rightBoxWidth - dims.MarginRight
-	rightBoxY := dims.MarginTop
+	rightBoxX := float64(width) - rightBoxWidth - dims.MarginRight + wear.DX
+	rightBoxY := dims.MarginTop + wear.DY
 	rightIsLight := AnalyzeRegionBrightness(img, int(rightBoxX), int(rightBoxY), int(rightBoxWidth), int(rightBoxHeight))
 	var rightColors TextColor
 	if rightIsLight {
@@ -497,22 +549,84 @@ func RenderDualPanelOverlay(img image.Image, leftLines []string, rightLines []st
 	} else {
 		rightColors = LightOnDark()
 	}
+	rightColors = wear.colors(themeColors(opts.Theme, rightColors))
 
 	// Draw left panel (services)
 	if len(leftLines) > 0 {
-		drawPanel(dc, leftBoxX, leftBoxY, leftBoxWidth, leftBoxHeight, dims, leftColors, leftLines)
+		if err := setFontFace(dc, leftDims.FontSize); err != nil {
+			return nil, fmt.Errorf("failed to load font: %v", err)
+		}
+		drawPanel(dc, leftBoxX, leftBoxY, leftBoxWidth, leftBoxHeight, leftDims, leftColors, leftLines, rtl)
 	}
 
 	// Draw right panel (system info)
 	if len(rightLines) > 0 {
-		drawPanel(dc, rightBoxX, rightBoxY, rightBoxWidth, rightBoxHeight, dims, rightColors, rightLines)
+		if err := setFontFace(dc, rightDims.FontSize); err != nil {
+			return nil, fmt.Errorf("failed to load font: %v", err)
+		}
+		drawPanel(dc, rightBoxX, rightBoxY, rightBoxWidth, rightBoxHeight, rightDims, rightColors, rightLines, rtl)
+	}
+
+	// Draw the theme logo below the services panel
+	if rtl {
+		logoY := rightBoxY
+		if len(rightLines) > 0 {
+			logoY += rightBoxHeight + rightDims.Padding
+		}
+		drawLogo(dc, opts.Theme.Logo, opts.ImageLimits, rightBoxX+rightBoxWidth, logoY, (rightDims.FontSize+rightDims.LineSpacing)*LogoLines, true)
+	} else {
+		logoY := leftBoxY
+		if len(leftLines) > 0 {
+			logoY += leftBoxHeight + leftDims.Padding
+		}
+		drawLogo(dc, opts.Theme.Logo, opts.ImageLimits, leftBoxX, logoY, (leftDims.FontSize+leftDims.LineSpacing)*LogoLines, false)
 	}
 
 	return dc.Image(), nil
 }
 
-// drawPanel draws a single panel with background, border, and text.
-func drawPanel(dc *gg.Context, boxX, boxY, boxWidth, boxHeight float64, dims ScaledDimensions, colors TextColor, lines []string) {
+// panelDimensions scales the text, padding and corners of one panel by its
+// text scale. The margins stay, so the panels keep their place.
+func panelDimensions(dims ScaledDimensions, factor float64) ScaledDimensions {
+	scaled := scaleDimensions(dims, factor)
+	scaled.MarginLeft, scaled.MarginRight, scaled.MarginTop = dims.MarginLeft, dims.MarginRight, dims.MarginTop
+	return scaled
+}
+
+// measurePanel returns the size of the panel drawn for lines.
+func measurePanel(dc *gg.Context, dims ScaledDimensions, lines []string) (width, height float64, err error) {
+	if err := setFontFace(dc, dims.FontSize); err != nil {
+		return 0, 0, fmt.Errorf("failed to load font: %v", err)
+	}
+
+	var maxWidth float64
+	for _, line := range lines {
+		w, _ := dc.MeasureString(line)
+		if w > maxWidth {
+			maxWidth = w
+		}
+	}
+	textHeight := (dims.FontSize + dims.LineSpacing) * float64(len(lines))
+	return maxWidth + (dims.Padding * 2), textHeight + (dims.Padding * 2) - dims.LineSpacing, nil
+}
+
+// newContext returns a drawing context holding img. An *image.RGBA at the origin
+// (such as the buffer from loginscreen.LoadImageScaled, or the result of another
+// render) is drawn on in place; other images are copied into a new buffer.
+func newContext(img image.Image) *gg.Context {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return gg.NewContextForRGBA(rgba)
+	}
+
+	bounds := img.Bounds()
+	dc := gg.NewContext(bounds.Dx(), bounds.Dy())
+	dc.DrawImage(img, -bounds.Min.X, -bounds.Min.Y)
+	return dc
+}
+
+// drawPanel draws a single panel with background, border, and text. With rtl
+// the lines are aligned to the right.
+func drawPanel(dc *gg.Context, boxX, boxY, boxWidth, boxHeight float64, dims ScaledDimensions, colors TextColor, lines []string, rtl bool) {
 	// Draw semi-transparent background with rounded corners
 	r, g, b, a := colors.Background.RGBA()
 	dc.SetRGBA(float64(r)/65535, float64(g)/65535, float64(b)/65535, float64(a)/65535)
@@ -526,16 +640,35 @@ func drawPanel(dc *gg.Context, boxX, boxY, boxWidth, boxHeight float64, dims Sca
 	dc.DrawRoundedRectangle(boxX, boxY, boxWidth, boxHeight, dims.CornerRadius)
 	dc.Stroke()
 
-	// Draw text
-	r, g, b, a = colors.Text.RGBA()
-	dc.SetRGBA(float64(r)/65535, float64(g)/65535, float64(b)/65535, float64(a)/65535)
+	// Draw text, alerts and warnings in their colors
+	alert := colors.Alert
+	if alert == nil {
+		alert = colors.Text
+	}
+	warning := colors.Warning
+	if warning == nil {
+		warning = colors.Text
+	}
 
 	lineHeight := dims.FontSize + dims.LineSpacing
 	textX := boxX + dims.Padding
 	textY := boxY + dims.Padding + dims.FontSize
 
 	for _, line := range lines {
-		dc.DrawString(line, textX, textY)
+		c := colors.Text
+		if isAlert(line) {
+			c = alert
+		} else if isWarning(line) {
+			c = warning
+		}
+		r, g, b, a = c.RGBA()
+		dc.SetRGBA(float64(r)/65535, float64(g)/65535, float64(b)/65535, float64(a)/65535)
+		if rtl {
+			w, _ := dc.MeasureString(line)
+			dc.DrawString(line, boxX+boxWidth-dims.Padding-w, textY)
+		} else {
+			dc.DrawString(line, textX, textY)
+		}
 		textY += lineHeight
 	}
 }
@@ -0,0 +1,68 @@
+package overlay
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/loginscreen"
+	"github.com/fogleman/gg"
+	"golang.org/x/image/draw"
+)
+
+// LogoLines is the height of the theme logo in text lines.
+const LogoLines = 4
+
+// Theme brands the panels (theme in the configuration).
+type Theme struct {
+	// Text, Panel and Border are "#RRGGBB" colors that replace the automatic
+	// light or dark panel colors.
+	Text   string
+	Panel  string
+	Border string
+	// PanelOpacity is the opacity of the Panel color, from 0 to 1.
+	PanelOpacity float64
+	// Logo is a PNG or JPEG drawn below the services panel, LogoLines text
+	// lines tall.
+	Logo string
+}
+
+// themeColors replaces the automatic panel colors with the theme colors.
+// Colors that are not set, or invalid, keep the automatic ones.
+func themeColors(theme Theme, c TextColor) TextColor {
+	if v, err := ParseHexColor(theme.Text); err == nil {
+		c.Text = v
+	}
+	if v, err := ParseHexColor(theme.Panel); err == nil {
+		v.A = uint8(theme.PanelOpacity * 255)
+		c.Background = color.NRGBA(v)
+	}
+	if v, err := ParseHexColor(theme.Border); err == nil {
+		c.Border = v
+	}
+	return c
+}
+
+// drawLogo draws the theme logo at x, y scaled to height, keeping its aspect
+// ratio. A logo that can't be loaded within limits is skipped.
+func drawLogo(dc *gg.Context, path string, limits config.ImageLimitsConfig, x, y, height float64, rightAligned bool) {
+	if path == "" || height < 1 {
+		return
+	}
+	logo, err := loginscreen.LoadImage(path, limits)
+	if err != nil {
+		return
+	}
+
+	b := logo.Bounds()
+	if b.Dy() == 0 {
+		return
+	}
+	width := height * float64(b.Dx()) / float64(b.Dy())
+	scaled := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), logo, b, draw.Src, nil)
+	if rightAligned {
+		x -= width
+	}
+	dc.DrawImage(scaled, int(x), int(y))
+}
@@ -0,0 +1,53 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+// DefaultTintStrength is the tint opacity used when none is configured.
+const DefaultTintStrength = 0.2
+
+// ParseHexColor parses "#RRGGBB" or "RRGGBB".
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (expected #RRGGBB)", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (expected #RRGGBB)", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// Tint blends a solid color over the whole image. strength is the color's
+// opacity from 0 (no change) to 1 (solid color).
+func Tint(img image.Image, c color.Color, strength float64) image.Image {
+	if strength <= 0 {
+		return img
+	}
+	if strength > 1 {
+		strength = 1
+	}
+
+	bounds := img.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(result, result.Bounds(), img, bounds.Min, draw.Src)
+
+	r, g, b, _ := c.RGBA()
+	alpha := uint32(strength * 0xffff)
+	overlay := color.RGBA64{
+		R: uint16(r * alpha / 0xffff),
+		G: uint16(g * alpha / 0xffff),
+		B: uint16(b * alpha / 0xffff),
+		A: uint16(alpha),
+	}
+	draw.Draw(result, result.Bounds(), image.NewUniform(overlay), image.Point{}, draw.Over)
+
+	return result
+}
@@ -0,0 +1,321 @@
+package publish
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// MQTT control packet types (first byte, flags cleared).
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttPubAck     = 0x40
+	mqttDisconnect = 0xE0
+)
+
+// mqttKeepAlive is the keep alive sent with CONNECT. The connection only lives
+// for one publish run.
+const mqttKeepAlive = 60
+
+// connAckErrors are the MQTT 3.1.1 CONNACK return codes.
+var connAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Metric is a value published to its own topic, PREFIX/HOST/Name.
+type Metric struct {
+	Name  string
+	Value string
+	// Unit is shown by Home Assistant, e.g. "%".
+	Unit string
+}
+
+// PublishMQTT sends the status file and the metrics to the broker as retained
+// messages: PREFIX/HOST/status (the JSON) and PREFIX/HOST/METRIC. With
+// home_assistant set, each metric is also announced for MQTT discovery.
+func PublishMQTT(ctx context.Context, cfg config.MQTTConfig, hostname, statusPath string, metrics []Metric) error {
+	status, err := os.ReadFile(statusPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", statusPath, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	conn, err := dialMQTT(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	clientID := firstNonEmpty(cfg.ClientID, "bgstatus-"+hostname)
+	password := firstNonEmpty(cfg.Password, os.Getenv("BGSTATUS_MQTT_PASSWORD"))
+	if err := mqttHandshake(conn, clientID, cfg.Username, password); err != nil {
+		return err
+	}
+
+	qos := byte(0)
+	if cfg.QoS > 0 {
+		qos = 1
+	}
+	base := cfg.Prefix() + "/" + mqttTopicLevel(hostname)
+	p := &mqttPublisher{conn: conn, qos: qos}
+	if err := p.publish(base+"/status", status); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		if err := p.publish(base+"/"+m.Name, []byte(m.Value)); err != nil {
+			return err
+		}
+	}
+	if cfg.HomeAssistant {
+		for _, m := range metrics {
+			topic, payload := homeAssistantDiscovery(hostname, base, m)
+			if err := p.publish(topic, payload); err != nil {
+				return err
+			}
+		}
+	}
+
+	conn.Write([]byte{mqttDisconnect, 0})
+	return nil
+}
+
+// ValidateBroker checks that broker is a URL dialMQTT can connect to.
+func ValidateBroker(broker string) error {
+	_, _, err := parseBroker(broker)
+	return err
+}
+
+// parseBroker parses the broker URL and reports whether it uses TLS.
+func parseBroker(broker string) (*url.URL, bool, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid mqtt broker: %w", err)
+	}
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		return u, false, nil
+	case "mqtts", "ssl":
+		return u, true, nil
+	}
+	return nil, false, fmt.Errorf("unsupported mqtt broker scheme %q (use mqtt:// or mqtts://)", u.Scheme)
+}
+
+// dialMQTT connects to the broker, with TLS for mqtts:// and ssl://.
+func dialMQTT(ctx context.Context, cfg config.MQTTConfig) (net.Conn, error) {
+	broker, useTLS, err := parseBroker(cfg.Broker)
+	if err != nil {
+		return nil, err
+	}
+
+	port := "1883"
+	if useTLS {
+		port = "8883"
+	}
+	if broker.Port() != "" {
+		port = broker.Port()
+	}
+	address := net.JoinHostPort(broker.Hostname(), port)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if !useTLS {
+		return conn, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: broker.Hostname(), MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to read mqtt ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			conn.Close()
+			return nil, fmt.Errorf("mqtt ca_file %s has no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+	}
+	return tlsConn, nil
+}
+
+// mqttHandshake sends CONNECT (MQTT 3.1.1, clean session) and waits for the
+// broker to accept it.
+func mqttHandshake(conn net.Conn, clientID, username, password string) error {
+	flags := byte(0x02)
+	payload := mqttString(clientID)
+	if username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(username)...)
+		if password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(password)...)
+		}
+	}
+	header := append(mqttString("MQTT"), 4, flags, byte(mqttKeepAlive>>8), byte(mqttKeepAlive&0xFF))
+	if _, err := conn.Write(mqttPacket(mqttConnect, append(header, payload...))); err != nil {
+		return fmt.Errorf("failed to send mqtt connect: %w", err)
+	}
+
+	packetType, body, err := readMQTTPacket(conn)
+	if err != nil {
+		return fmt.Errorf("no mqtt connect acknowledgement: %w", err)
+	}
+	if packetType != mqttConnAck || len(body) != 2 {
+		return fmt.Errorf("unexpected mqtt packet 0x%02x instead of the connect acknowledgement", packetType)
+	}
+	if code := body[1]; code != 0 {
+		reason, ok := connAckErrors[code]
+		if !ok {
+			reason = fmt.Sprintf("code %d", code)
+		}
+		return fmt.Errorf("mqtt broker refused the connection: %s", reason)
+	}
+	return nil
+}
+
+// mqttPublisher sends retained PUBLISH packets on a connection.
+type mqttPublisher struct {
+	conn     net.Conn
+	qos      byte
+	packetID uint16
+}
+
+// publish sends a retained message and, at QoS 1, waits for its
+// acknowledgement.
+func (p *mqttPublisher) publish(topic string, payload []byte) error {
+	body := mqttString(topic)
+	if p.qos > 0 {
+		p.packetID++
+		body = append(body, byte(p.packetID>>8), byte(p.packetID))
+	}
+	body = append(body, payload...)
+	if _, err := p.conn.Write(mqttPacket(mqttPublish|p.qos<<1|0x01, body)); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", topic, err)
+	}
+	if p.qos == 0 {
+		return nil
+	}
+
+	packetType, ack, err := readMQTTPacket(p.conn)
+	if err != nil {
+		return fmt.Errorf("no acknowledgement for %s: %w", topic, err)
+	}
+	if packetType != mqttPubAck || len(ack) != 2 || uint16(ack[0])<<8|uint16(ack[1]) != p.packetID {
+		return fmt.Errorf("unexpected mqtt packet 0x%02x instead of the acknowledgement for %s", packetType, topic)
+	}
+	return nil
+}
+
+// homeAssistantDiscovery returns the Home Assistant discovery message that
+// declares a metric as a sensor of the host's device.
+func homeAssistantDiscovery(hostname, base string, m Metric) (string, []byte) {
+	id := strings.ToLower(mqttTopicLevel(hostname))
+	objectID := id + "_" + strings.ReplaceAll(m.Name, "/", "_")
+	sensor := map[string]interface{}{
+		"name":        strings.ReplaceAll(m.Name, "_", " "),
+		"state_topic": base + "/" + m.Name,
+		"unique_id":   "bgstatus_" + objectID,
+		"device": map[string]interface{}{
+			"identifiers":  []string{"bgstatus_" + id},
+			"name":         hostname,
+			"manufacturer": "BgStatusService",
+		},
+	}
+	if m.Unit != "" {
+		sensor["unit_of_measurement"] = m.Unit
+		sensor["state_class"] = "measurement"
+	}
+	payload, _ := json.Marshal(sensor)
+	return "homeassistant/sensor/" + objectID + "/config", payload
+}
+
+// mqttTopicLevel makes a host name safe as one topic level: no separators or
+// wildcards.
+func mqttTopicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_").Replace(s)
+}
+
+// mqttString encodes a length-prefixed UTF-8 string.
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// mqttPacket prefixes a packet body with its fixed header.
+func mqttPacket(first byte, body []byte) []byte {
+	out := []byte{first}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+// readMQTTPacket reads one packet, returning its type (flags cleared) and
+// body.
+func readMQTTPacket(r io.Reader) (byte, []byte, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed mqtt packet length")
+		}
+		var b [1]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length += int(b[0]&0x7F) * multiplier
+		multiplier *= 128
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return first[0] & 0xF0, body, nil
+}
@@ -0,0 +1,261 @@
+// Package publish uploads the rendered login screen and the status file to a
+// central location after each run, so a wall dashboard can show every machine's
+// current lock screen. HTTPS uploads use PUT; SFTP uploads use the OpenSSH
+// client that ships with Windows 10 1809 and later; folders (UNC shares for
+// digital signage) are written directly.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Timeout bounds one publish run (all files).
+const Timeout = 2 * time.Minute
+
+// File is a local file and the name it is published under.
+type File struct {
+	Path string
+	Name string
+}
+
+// Files returns the files published for a run: the image and the status file,
+// named after the host so each machine has a stable pair of names
+// (HOST.jpg and HOST.json). Without an image only the status is published.
+func Files(hostname, imagePath, statusPath string) []File {
+	status := File{Path: statusPath, Name: hostname + ".json"}
+	if imagePath == "" {
+		return []File{status}
+	}
+	return []File{
+		{Path: imagePath, Name: hostname + strings.ToLower(filepath.Ext(imagePath))},
+		status,
+	}
+}
+
+// Publish uploads the files to the configured URL, only the images when the
+// target is image only.
+func Publish(ctx context.Context, cfg config.PublishConfig, files []File) error {
+	if cfg.ImageOnly {
+		var images []File
+		for _, f := range files {
+			if !strings.EqualFold(filepath.Ext(f.Name), ".json") {
+				images = append(images, f)
+			}
+		}
+		files = images
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	if dir, ok := folderTarget(cfg.URL); ok {
+		return publishFolder(dir, files)
+	}
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid publish url: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	switch target.Scheme {
+	case "https", "http":
+		return publishHTTP(ctx, cfg, target, files)
+	case "sftp":
+		return publishSFTP(ctx, cfg, target, files)
+	}
+	return fmt.Errorf("unsupported publish url scheme %q (use https://, sftp:// or a folder path)", target.Scheme)
+}
+
+// ValidateURL checks that target is a URL or folder Publish can write to,
+// without connecting to it.
+func ValidateURL(target string) error {
+	if _, ok := folderTarget(target); ok {
+		return nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid publish url: %w", err)
+	}
+	switch u.Scheme {
+	case "https", "http", "sftp":
+		if u.Host == "" {
+			return fmt.Errorf("publish url %q has no host", target)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported publish url scheme %q (use https://, sftp:// or a folder path)", u.Scheme)
+}
+
+// folderTarget returns the folder of a UNC path (\\server\share\dir), a local
+// path (D:\dir) or a file:// URL (file://server/share/dir, file:///D:/dir).
+func folderTarget(target string) (string, bool) {
+	if rest, ok := strings.CutPrefix(target, "file://"); ok {
+		if strings.HasPrefix(rest, "/") {
+			return filepath.FromSlash(strings.TrimPrefix(rest, "/")), true
+		}
+		return `\\` + filepath.FromSlash(rest), true
+	}
+	if strings.HasPrefix(target, `\\`) || filepath.VolumeName(target) != "" {
+		return target, true
+	}
+	return "", false
+}
+
+// publishFolder copies the files into a folder, e.g. a share read by digital
+// signage. Each file is written under a temporary name and renamed over the
+// old one, so a reader never sees a half-written image. Shares are accessed
+// as the computer account (DOMAIN\HOST$), which needs write access.
+func publishFolder(dir string, files []File) error {
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Path, err)
+		}
+		dest := filepath.Join(dir, f.Name)
+		if err := os.WriteFile(dest+".part", data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+		if err := os.Rename(dest+".part", dest); err != nil {
+			os.Remove(dest + ".part")
+			return fmt.Errorf("failed to replace %s: %w", dest, err)
+		}
+	}
+	return nil
+}
+
+// publishHTTP PUTs each file to the URL's folder, with basic authentication
+// when a username is set and the configured extra headers.
+func publishHTTP(ctx context.Context, cfg config.PublishConfig, target *url.URL, files []File) error {
+	password := firstNonEmpty(cfg.Password, os.Getenv("BGSTATUS_PUBLISH_PASSWORD"))
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Path, err)
+		}
+
+		u := *target
+		u.Path = path.Join(target.Path, f.Name)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType(f.Name))
+		for k, v := range cfg.Headers {
+			req.Header.Set(k, v)
+		}
+		if cfg.Username != "" {
+			req.SetBasicAuth(cfg.Username, password)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s to %s: %w", f.Name, target.Redacted(), err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to upload %s to %s: %s", f.Name, target.Redacted(), resp.Status)
+		}
+	}
+	return nil
+}
+
+// publishSFTP uploads the files with sftp.exe in batch mode. Each file is put
+// under a temporary name and renamed over the old one, so a dashboard never
+// reads a half-written image. Authentication is by key only (identity_file).
+func publishSFTP(ctx context.Context, cfg config.PublishConfig, target *url.URL, files []File) error {
+	if target.User == nil || target.User.Username() == "" {
+		return fmt.Errorf("sftp publish url needs a user, e.g. sftp://user@host/path")
+	}
+	// sftp would take a leading "-" as an ssh option such as ProxyCommand
+	if strings.HasPrefix(target.User.Username(), "-") || strings.HasPrefix(target.Hostname(), "-") {
+		return fmt.Errorf("sftp publish url has a user or host starting with \"-\"")
+	}
+	dir := strings.TrimSuffix(target.Path, "/")
+	if dir == "" {
+		dir = "."
+	}
+
+	var batch strings.Builder
+	for _, f := range files {
+		remote := dir + "/" + f.Name
+		fmt.Fprintf(&batch, "put %s %s\n", quote(f.Path), quote(remote+".part"))
+		// "-" ignores the error when there is no previous file
+		fmt.Fprintf(&batch, "-rm %s\n", quote(remote))
+		fmt.Fprintf(&batch, "rename %s %s\n", quote(remote+".part"), quote(remote))
+	}
+	batchFile, err := os.CreateTemp("", "bgstatus_sftp_*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create sftp batch file: %w", err)
+	}
+	defer os.Remove(batchFile.Name())
+	batchFile.WriteString(batch.String())
+	batchFile.Close()
+
+	args := []string{"-b", batchFile.Name(), "-o", "BatchMode=yes"}
+	if cfg.IdentityFile != "" {
+		args = append(args, "-i", cfg.IdentityFile)
+	}
+	if cfg.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+cfg.KnownHostsFile)
+	}
+	if port := target.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	args = append(args, "--", target.User.Username()+"@"+target.Hostname())
+
+	output, err := winapi.Commands.Run(ctx, sftpPath(), args...)
+	if err != nil {
+		return fmt.Errorf("sftp to %s failed: %w - %s", target.Hostname(), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// sftpPath returns the Windows OpenSSH client, which is not on the PATH of
+// SYSTEM in every configuration.
+func sftpPath() string {
+	builtIn := filepath.Join(os.Getenv("SystemRoot"), "System32", "OpenSSH", "sftp.exe")
+	if _, err := os.Stat(builtIn); err == nil {
+		return builtIn
+	}
+	return "sftp.exe"
+}
+
+// quote quotes a path for an sftp batch file.
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// contentType returns the MIME type of a published file.
+func contentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return "application/json"
+	case ".png":
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// firstNonEmpty returns the first value that isn't empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
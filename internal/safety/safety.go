@@ -0,0 +1,218 @@
+// Package safety gates images pulled from public sources: domain allow/deny lists,
+// an optional external or HTTP classifier, and an administrator policy that
+// restricts managed machines to allowlisted sources.
+package safety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/config"
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// PolicyKeyPath is the HKLM key administrators (or Group Policy) use to enforce
+// the safety settings. Values here override the user-editable config file.
+const PolicyKeyPath = `SOFTWARE\Policies\BgStatusService`
+
+// ClassifierTimeout bounds a single classification (command or API call).
+const ClassifierTimeout = 60 * time.Second
+
+// DefaultThreshold is the classifier score at or above which an image is rejected.
+const DefaultThreshold = 0.5
+
+// Gate applies the safety settings to URLs and downloaded images.
+type Gate struct {
+	cfg config.SafetyConfig
+}
+
+// New creates a gate from the config, merged with the machine policy from the registry.
+func New(cfg config.SafetyConfig) *Gate {
+	applyPolicy(&cfg)
+	return &Gate{cfg: cfg}
+}
+
+// Load creates a gate from the config file and machine policy.
+// A config load error is returned alongside a gate built from the policy alone,
+// so a broken config file never disables an enforced policy.
+func Load() (*Gate, error) {
+	cfg, err := config.Load()
+	return New(cfg.Safety), err
+}
+
+// applyPolicy merges HKLM\SOFTWARE\Policies\BgStatusService into cfg.
+// AllowlistOnly (DWORD) forces allowlist mode; AllowedDomains and DeniedDomains
+// (REG_MULTI_SZ) replace the config lists when present.
+func applyPolicy(cfg *config.SafetyConfig) {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, PolicyKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+
+	if v, _, err := key.GetIntegerValue("AllowlistOnly"); err == nil && v != 0 {
+		cfg.AllowlistOnly = true
+	}
+	if v, _, err := key.GetStringsValue("AllowedDomains"); err == nil && len(v) > 0 {
+		cfg.AllowedDomains = v
+	}
+	if v, _, err := key.GetStringsValue("DeniedDomains"); err == nil && len(v) > 0 {
+		cfg.DeniedDomains = v
+	}
+	if v, _, err := key.GetStringValue("ClassifierCommand"); err == nil && v != "" {
+		cfg.ClassifierCommand = v
+	}
+	if v, _, err := key.GetStringValue("ClassifierURL"); err == nil && v != "" {
+		cfg.ClassifierURL = v
+	}
+}
+
+// Enabled reports whether any safety check is configured.
+func (g *Gate) Enabled() bool {
+	return g.cfg.AllowlistOnly || len(g.cfg.AllowedDomains) > 0 || len(g.cfg.DeniedDomains) > 0 ||
+		g.cfg.ClassifierCommand != "" || g.cfg.ClassifierURL != ""
+}
+
+// CheckURL verifies that an image or catalog URL comes from an allowed domain.
+func (g *Gate) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	host := strings.ToLower(u.Hostname())
+
+	for _, denied := range g.cfg.DeniedDomains {
+		if matchDomain(host, denied) {
+			return fmt.Errorf("source %s is blocked by the deny list (%s)", host, denied)
+		}
+	}
+
+	if len(g.cfg.AllowedDomains) == 0 {
+		if g.cfg.AllowlistOnly {
+			return fmt.Errorf("source %s rejected: policy only allows allowlisted sources and none are configured", host)
+		}
+		return nil
+	}
+
+	for _, allowed := range g.cfg.AllowedDomains {
+		if matchDomain(host, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source %s is not in the allowed domain list", host)
+}
+
+// matchDomain reports whether host equals domain or is a subdomain of it.
+// A leading "*." on the pattern is accepted and ignored.
+func matchDomain(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "*."))
+	if domain == "" {
+		return false
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// CheckImage runs the configured classifiers against a downloaded image.
+// Returns nil when no classifier is configured.
+func (g *Gate) CheckImage(path string) error {
+	if g.cfg.ClassifierCommand != "" {
+		if err := g.runCommand(path); err != nil {
+			return err
+		}
+	}
+	if g.cfg.ClassifierURL != "" {
+		if err := g.callAPI(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCommand runs the external classifier; a non-zero exit rejects the image.
+func (g *Gate) runCommand(path string) error {
+	command := g.cfg.ClassifierCommand
+	if strings.Contains(command, "{file}") {
+		command = strings.ReplaceAll(command, "{file}", `"`+path+`"`)
+	} else {
+		command += ` "` + path + `"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ClassifierTimeout)
+	defer cancel()
+
+	// Run through cmd.exe so admins can use quoting and interpreters naturally
+	cmd := exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("image classifier timed out after %v", ClassifierTimeout)
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("image rejected by classifier: %s", strings.TrimSpace(string(output)))
+		}
+		return fmt.Errorf("failed to run image classifier: %w", err)
+	}
+	return nil
+}
+
+// classifierResponse is the JSON returned by a classifier API.
+type classifierResponse struct {
+	Safe  *bool    `json:"safe"`
+	Score *float64 `json:"score"`
+}
+
+// callAPI posts the image to the classifier endpoint and checks its verdict.
+func (g *Gate) callAPI(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read image for classification: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", g.cfg.ClassifierURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create classifier request: %w", err)
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(data))
+
+	client := &http.Client{Timeout: ClassifierTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("image classifier unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("image classifier returned HTTP %d", resp.StatusCode)
+	}
+
+	var result classifierResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse classifier response: %w", err)
+	}
+
+	threshold := g.cfg.ClassifierThreshold
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	switch {
+	case result.Safe != nil && !*result.Safe:
+		return fmt.Errorf("image rejected by classifier")
+	case result.Score != nil && *result.Score >= threshold:
+		return fmt.Errorf("image rejected by classifier (score %.2f >= %.2f)", *result.Score, threshold)
+	case result.Safe == nil && result.Score == nil:
+		return fmt.Errorf("classifier response has neither \"safe\" nor \"score\"")
+	}
+	return nil
+}
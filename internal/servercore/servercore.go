@@ -0,0 +1,86 @@
+// Package servercore shows the status on Windows Server Core (see
+// capability.OSInfo.ServerCore), which has no lock screen image to draw on:
+// as the interactive logon message shown before sign-in
+// (legalnoticecaption/legalnoticetext) and as a text file the MOTD task
+// prints in a console window at sign-in.
+package servercore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backgroundchanger/internal/journal"
+	"github.com/backgroundchanger/internal/winapi"
+	"golang.org/x/sys/windows/registry"
+)
+
+// MOTDFileName is the status text printed at sign-in, in the data directory.
+const MOTDFileName = "motd.txt"
+
+// logonMessageKey (HKLM) holds the interactive logon message policy.
+const logonMessageKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System`
+
+// maxLogonMessageLines keeps the logon message dialog on screen.
+const maxLogonMessageLines = 40
+
+// Text returns the status as plain text: the panels one after the other,
+// with single blank lines between sections.
+func Text(panels ...[]string) []string {
+	var lines []string
+	for _, panel := range panels {
+		for _, line := range panel {
+			if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) > 0 && lines[len(lines)-1] != "" {
+			lines = append(lines, "")
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// WriteLogonMessage sets the logon message to the status. The previous
+// message is journaled, so uninstalling puts it back. Group Policy replaces
+// the message on its next refresh if it manages it.
+func WriteLogonMessage(caption string, lines []string) error {
+	// Journal the previous values so they can be restored (best effort)
+	journal.Record(registry.LOCAL_MACHINE, logonMessageKey, "legalnoticecaption", "legalnoticetext")
+
+	key, _, err := winapi.Reg.CreateKey(registry.LOCAL_MACHINE, logonMessageKey, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open logon message policy: %v", err)
+	}
+	defer key.Close()
+
+	if len(lines) > maxLogonMessageLines {
+		lines = append(lines[:maxLogonMessageLines-1], fmt.Sprintf("... and %d more lines", len(lines)-maxLogonMessageLines+1))
+	}
+	if err := key.SetStringValue("legalnoticecaption", caption); err != nil {
+		return fmt.Errorf("failed to set logon message caption: %v", err)
+	}
+	if err := key.SetStringValue("legalnoticetext", strings.Join(lines, "\r\n")); err != nil {
+		return fmt.Errorf("failed to set logon message text: %v", err)
+	}
+	return nil
+}
+
+// WriteMOTD writes the status to the MOTD file in dir, with Windows line
+// endings for "type".
+func WriteMOTD(dir, title string, lines []string) error {
+	text := title + "\r\n" + strings.Repeat("=", len(title)) + "\r\n\r\n" + strings.Join(lines, "\r\n") + "\r\n"
+	path := filepath.Join(dir, MOTDFileName)
+	if err := os.WriteFile(path+".part", []byte(text), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(path+".part", path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	return nil
+}
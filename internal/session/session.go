@@ -0,0 +1,108 @@
+// Package session starts processes in the signed-in users' sessions from
+// BgStatusService, which runs as SYSTEM in session 0. A process started with
+// the user's token on the user's desktop can use the per-user APIs (toasts,
+// the WinRT lock screen) that do not work as SYSTEM.
+package session
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Active returns the IDs of the sessions with a signed-in user at the console
+// or connected over RDP.
+func Active() ([]uint32, error) {
+	var info *windows.WTS_SESSION_INFO
+	var count uint32
+	if err := windows.WTSEnumerateSessions(0, 0, 1, &info, &count); err != nil {
+		return nil, fmt.Errorf("failed to enumerate sessions: %v", err)
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(info)))
+
+	var sessions []uint32
+	for _, s := range unsafe.Slice(info, count) {
+		if s.State == windows.WTSActive && s.SessionID != 0 {
+			sessions = append(sessions, s.SessionID)
+		}
+	}
+	return sessions, nil
+}
+
+// Start starts command as the session's user on its default desktop without
+// waiting for it.
+func Start(session uint32, command string) error {
+	pi, err := start(session, command)
+	if err != nil {
+		return err
+	}
+	windows.CloseHandle(pi.Thread)
+	windows.CloseHandle(pi.Process)
+	return nil
+}
+
+// Run starts command like Start and waits for it, returning its exit code.
+// Cancelling ctx terminates the process.
+func Run(ctx context.Context, session uint32, command string) (uint32, error) {
+	pi, err := start(session, command)
+	if err != nil {
+		return 0, err
+	}
+	windows.CloseHandle(pi.Thread)
+	defer windows.CloseHandle(pi.Process)
+
+	for {
+		event, err := windows.WaitForSingleObject(pi.Process, 250)
+		if err != nil {
+			return 0, fmt.Errorf("failed to wait for the process: %v", err)
+		}
+		if event == windows.WAIT_OBJECT_0 {
+			break
+		}
+		if ctx.Err() != nil {
+			windows.TerminateProcess(pi.Process, 1)
+			return 0, ctx.Err()
+		}
+	}
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(pi.Process, &code); err != nil {
+		return 0, fmt.Errorf("failed to read the exit code: %v", err)
+	}
+	return code, nil
+}
+
+// start creates the process hidden, with the user's token and environment.
+func start(session uint32, command string) (*windows.ProcessInformation, error) {
+	var token windows.Token
+	if err := windows.WTSQueryUserToken(session, &token); err != nil {
+		return nil, fmt.Errorf("no user token: %v", err)
+	}
+	defer token.Close()
+
+	var env *uint16
+	if err := windows.CreateEnvironmentBlock(&env, token, false); err != nil {
+		return nil, fmt.Errorf("failed to create environment: %v", err)
+	}
+	defer windows.DestroyEnvironmentBlock(env)
+
+	cmdLine, err := windows.UTF16PtrFromString(command)
+	if err != nil {
+		return nil, err
+	}
+	desktop, _ := windows.UTF16PtrFromString(`winsta0\default`)
+	si := &windows.StartupInfo{
+		Cb:         uint32(unsafe.Sizeof(windows.StartupInfo{})),
+		Desktop:    desktop,
+		Flags:      windows.STARTF_USESHOWWINDOW,
+		ShowWindow: windows.SW_HIDE,
+	}
+	pi := &windows.ProcessInformation{}
+	if err := windows.CreateProcessAsUser(token, nil, cmdLine, nil, nil, false,
+		windows.CREATE_NO_WINDOW|windows.CREATE_UNICODE_ENVIRONMENT, env, nil, si, pi); err != nil {
+		return nil, fmt.Errorf("failed to start process: %v", err)
+	}
+	return pi, nil
+}
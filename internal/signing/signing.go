@@ -0,0 +1,155 @@
+// Package signing verifies the Ed25519 signatures of downloaded update metadata
+// (checksums and rollout files) against a public key pinned at build time, so
+// a compromised web server or mirror cannot push binaries to the fleet.
+//
+// A signature is a detached file next to the signed one, named with
+// SignatureSuffix. It holds a Manifest naming the file, its SHA-256, the
+// release tag, a sequence number and an expiry, and the Ed25519 signature of
+// the manifest's exact bytes, so a file signed for one release cannot be
+// replayed against another. Files that change within a release, such as a
+// rollout, are also checked against the highest sequence seen (see
+// installer.GetRollout), so an outdated one cannot be replayed either. signtool (go run ./internal/signing/signtool)
+// creates keys and signatures.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PublicKey is the pinned Ed25519 public key in base64, set at build time with
+// -ldflags "-X github.com/backgroundchanger/internal/signing.PublicKey=...".
+// Builds without one (development builds) do not require signatures.
+var PublicKey = ""
+
+// SignatureSuffix is appended to a file name to find its signature.
+const SignatureSuffix = ".sig"
+
+// ManifestVersion is the manifest format this build signs and accepts.
+const ManifestVersion = 1
+
+// Manifest is the signed statement about one file of one release.
+type Manifest struct {
+	// Version is the manifest format, ManifestVersion.
+	Version int `json:"version"`
+	// Tag is the release the file belongs to, e.g. "v1.2.3".
+	Tag string `json:"tag"`
+	// File is the signed file's name, e.g. "rollout.json".
+	File string `json:"file"`
+	// SHA256 is the hex digest of the file's exact bytes.
+	SHA256 string `json:"sha256"`
+	// Sequence increases each time a file is signed again, e.g. the signing
+	// time in Unix seconds.
+	Sequence int64 `json:"sequence,omitempty"`
+	// Expires is when the signature stops being accepted.
+	Expires time.Time `json:"expires"`
+}
+
+// envelope is the content of a signature file.
+type envelope struct {
+	// Manifest is the base64 of the manifest's JSON, kept as bytes so the
+	// signature does not depend on how JSON is re-encoded.
+	Manifest string `json:"manifest"`
+	// Signature is the base64 of the Ed25519 signature of those bytes.
+	Signature string `json:"signature"`
+}
+
+// Pinned reports whether this build has a public key, and so requires
+// signatures.
+func Pinned() bool {
+	return strings.TrimSpace(PublicKey) != ""
+}
+
+// Sign returns the signature file for data, the content of the file name in
+// the release tag, with the sequence number and valid until expires.
+func Sign(key ed25519.PrivateKey, data []byte, name, tag string, sequence int64, expires time.Time) ([]byte, error) {
+	manifest, err := json.Marshal(Manifest{
+		Version:  ManifestVersion,
+		Tag:      tag,
+		File:     name,
+		SHA256:   digest(data),
+		Sequence: sequence,
+		Expires:  expires.UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sig, err := json.Marshal(envelope{
+		Manifest:  base64.StdEncoding.EncodeToString(manifest),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(sig, '\n'), nil
+}
+
+// Verify checks a signature file's content against data, which must be the
+// file name of the release tag, at the time now, and returns the verified
+// manifest.
+func Verify(data, signature []byte, name, tag string, now time.Time) (*Manifest, error) {
+	key, err := publicKey()
+	if err != nil {
+		return nil, err
+	}
+	return verify(key, data, signature, name, tag, now)
+}
+
+// verify checks a signature file against key.
+func verify(key ed25519.PublicKey, data, signature []byte, name, tag string, now time.Time) (*Manifest, error) {
+	var env envelope
+	if err := json.Unmarshal(signature, &env); err != nil {
+		return nil, fmt.Errorf("signature is malformed")
+	}
+	manifest, err := base64.StdEncoding.DecodeString(env.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("signature is malformed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(env.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("signature is malformed")
+	}
+	if !ed25519.Verify(key, manifest, sig) {
+		return nil, fmt.Errorf("signature does not match the pinned key")
+	}
+
+	// Only trust the manifest's content once its signature is known to be good
+	var m Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("signed manifest is malformed: %w", err)
+	}
+	switch {
+	case m.Version != ManifestVersion:
+		return nil, fmt.Errorf("signed manifest version %d is not supported", m.Version)
+	case m.Tag != tag:
+		return nil, fmt.Errorf("signature is for release %q, not %q", m.Tag, tag)
+	case m.File != name:
+		return nil, fmt.Errorf("signature is for %q, not %q", m.File, name)
+	case !strings.EqualFold(m.SHA256, digest(data)):
+		return nil, fmt.Errorf("signature is for different content")
+	case !now.Before(m.Expires):
+		return nil, fmt.Errorf("signature expired on %s", m.Expires.Format(time.RFC3339))
+	}
+	return &m, nil
+}
+
+// digest returns the hex SHA-256 of data.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// publicKey decodes PublicKey.
+func publicKey() (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("the pinned public key is malformed")
+	}
+	return ed25519.PublicKey(key), nil
+}
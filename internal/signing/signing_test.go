@@ -0,0 +1,94 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	data := []byte(`{"percent": 25}`)
+	sign := func(key ed25519.PrivateKey, data []byte, name, tag string, expires time.Time) []byte {
+		sig, err := Sign(key, data, name, tag, 42, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	valid := sign(private, data, "rollout.json", "v1.2.3", now.Add(time.Hour))
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature []byte
+		file      string
+		tag       string
+		want      string
+	}{
+		{"valid", data, valid, "rollout.json", "v1.2.3", ""},
+		{"other release", data, valid, "rollout.json", "v1.2.4", "release"},
+		{"other file", data, valid, "bgchanger.exe.sha256", "v1.2.3", "not \"bgchanger.exe.sha256\""},
+		{"other content", []byte(`{"percent": 100}`), valid, "rollout.json", "v1.2.3", "different content"},
+		{"expired", data, sign(private, data, "rollout.json", "v1.2.3", now), "rollout.json", "v1.2.3", "expired"},
+		{"other key", data, sign(other, data, "rollout.json", "v1.2.3", now.Add(time.Hour)), "rollout.json", "v1.2.3", "does not match"},
+		{"bare signature", data, []byte("c2lnbmF0dXJl\n"), "rollout.json", "v1.2.3", "malformed"},
+		{"relabelled manifest", data, relabel(t, valid, "v1.2.4"), "rollout.json", "v1.2.4", "does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := verify(public, tt.data, tt.signature, tt.file, tt.tag, now)
+			switch {
+			case tt.want == "":
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				if m.Sequence != 42 {
+					t.Errorf("sequence = %d, want 42", m.Sequence)
+				}
+			case err == nil || !strings.Contains(err.Error(), tt.want):
+				t.Fatalf("verify = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// relabel rewrites the tag in a signature file's manifest, keeping the
+// original Ed25519 signature.
+func relabel(t *testing.T, signature []byte, tag string) []byte {
+	var env envelope
+	if err := json.Unmarshal(signature, &env); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := base64.StdEncoding.DecodeString(env.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		t.Fatal(err)
+	}
+	m.Tag = tag
+	if manifest, err = json.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+	env.Manifest = base64.StdEncoding.EncodeToString(manifest)
+	out, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
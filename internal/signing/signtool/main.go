@@ -0,0 +1,110 @@
+// Command signtool creates the Ed25519 key pair and the detached signatures
+// that builds with a pinned public key require on update metadata:
+//
+//	go run ./internal/signing/signtool keygen KEYFILE
+//	go run ./internal/signing/signtool sign [-expires 720h] [-sequence N] KEYFILE TAG FILE...
+//
+// keygen writes the private key to KEYFILE, which must not exist, and prints
+// the public key to pin. sign writes FILE.sig next to each file, valid for the
+// release TAG only and until it expires (30 days by default). The sequence
+// number defaults to the current time in Unix seconds, so each signature of a
+// file is higher than the last and clients refuse an older rollout.json once
+// they have seen a newer one. Re-sign a rollout.json each time it changes, and
+// before its signature expires.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/signing"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "signtool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "keygen":
+		return keygen(args[1])
+	case len(args) >= 1 && args[0] == "sign":
+		flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+		expires := flags.Duration("expires", 30*24*time.Hour, "how long the signatures are valid")
+		sequence := flags.Int64("sequence", 0, "the sequence number to sign (default the current Unix time)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *sequence == 0 {
+			*sequence = time.Now().Unix()
+		}
+		if flags.NArg() >= 3 && *expires > 0 && *sequence > 0 {
+			return sign(flags.Arg(0), flags.Arg(1), *sequence, time.Now().Add(*expires), flags.Args()[2:])
+		}
+	}
+	return fmt.Errorf("usage: signtool keygen KEYFILE | signtool sign [-expires DURATION] [-sequence N] KEYFILE TAG FILE...")
+}
+
+// keygen writes a new private key (the base64 seed) and prints the public key.
+func keygen(keyFile string) error {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, base64.StdEncoding.EncodeToString(private.Seed())); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Private key written to %s; keep it offline.\n", keyFile)
+	fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(public))
+	fmt.Printf("Pin it with: -ldflags \"-X github.com/backgroundchanger/internal/signing.PublicKey=%s\"\n",
+		base64.StdEncoding.EncodeToString(public))
+	return nil
+}
+
+// sign writes a detached signature for each file of the release tag.
+func sign(keyFile, tag string, sequence int64, expires time.Time, files []string) error {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("%s is not a signtool private key", keyFile)
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sig, err := signing.Sign(private, content, filepath.Base(file), tag, sequence, expires)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file+signing.SignatureSuffix, sig, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Signed %s for %s as sequence %d until %s\n", file, tag, sequence, expires.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BER tags used by SNMP messages.
+const (
+	tagInteger        = 0x02
+	tagOctetString    = 0x04
+	tagNull           = 0x05
+	tagOID            = 0x06
+	tagSequence       = 0x30
+	tagGauge32        = 0x42
+	tagTimeTicks      = 0x43
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+)
+
+var errTruncated = errors.New("truncated message")
+
+// readTLV splits the first BER element off data, returning its tag, its
+// contents and what follows it.
+func readTLV(data []byte) (tag byte, value, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errTruncated
+	}
+	tag = data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7F
+		if n == 0 || n > 3 || len(data) < 2+n {
+			return 0, nil, nil, fmt.Errorf("unsupported length encoding")
+		}
+		length = 0
+		for _, b := range data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, errTruncated
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// readExpected reads an element that must have the given tag.
+func readExpected(data []byte, want byte) (value, rest []byte, err error) {
+	tag, value, rest, err := readTLV(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tag != want {
+		return nil, nil, fmt.Errorf("unexpected tag 0x%02x (want 0x%02x)", tag, want)
+	}
+	return value, rest, nil
+}
+
+// readInteger reads an INTEGER.
+func readInteger(data []byte) (int64, []byte, error) {
+	value, rest, err := readExpected(data, tagInteger)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(value) == 0 || len(value) > 8 {
+		return 0, nil, fmt.Errorf("invalid integer length %d", len(value))
+	}
+	n := int64(int8(value[0]))
+	for _, b := range value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, rest, nil
+}
+
+// parseOID decodes the contents of an OBJECT IDENTIFIER.
+func parseOID(value []byte) (OID, error) {
+	if len(value) == 0 {
+		return nil, errors.New("empty object identifier")
+	}
+	oid := OID{int(value[0]) / 40, int(value[0]) % 40}
+	n := 0
+	for i, b := range value[1:] {
+		n = n<<7 | int(b&0x7F)
+		if n > 1<<31 {
+			return nil, errors.New("object identifier component too large")
+		}
+		if b&0x80 == 0 {
+			oid = append(oid, n)
+			n = 0
+		} else if i == len(value)-2 {
+			return nil, errTruncated
+		}
+	}
+	return oid, nil
+}
+
+// encodeTLV encodes one BER element.
+func encodeTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	default:
+		// Long form: the number of length bytes, then the length big-endian
+		var length []byte
+		for ; n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, value...)
+}
+
+// encodeInteger encodes a signed INTEGER (or an application type sharing its
+// encoding) in as few bytes as possible.
+func encodeInteger(tag byte, n int64) []byte {
+	var value []byte
+	for {
+		value = append([]byte{byte(n)}, value...)
+		if (n < 128 && n >= -128) && (n >= 0) == (value[0]&0x80 == 0) {
+			break
+		}
+		n >>= 8
+	}
+	return encodeTLV(tag, value)
+}
+
+// encodeUnsigned encodes a Gauge32, Counter32 or TimeTicks value, which are
+// unsigned and need a leading zero byte when the top bit is set.
+func encodeUnsigned(tag byte, n uint32) []byte {
+	return encodeInteger(tag, int64(n))
+}
+
+// encodeOID encodes an OBJECT IDENTIFIER.
+func encodeOID(oid OID) []byte {
+	if len(oid) < 2 {
+		return encodeTLV(tagOID, []byte{0})
+	}
+	value := []byte{byte(oid[0]*40 + oid[1])}
+	for _, n := range oid[2:] {
+		var chunk []byte
+		chunk = append(chunk, byte(n&0x7F))
+		for n >>= 7; n > 0; n >>= 7 {
+			chunk = append([]byte{byte(n&0x7F) | 0x80}, chunk...)
+		}
+		value = append(value, chunk...)
+	}
+	return encodeTLV(tagOID, value)
+}
+
+// concat joins encoded elements, e.g. the contents of a SEQUENCE.
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package snmp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTLVRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 0x7F, 0x80, 0xFF, 0x100, 0xFFFF, 0x10000, 0xFFFFFF} {
+		value := bytes.Repeat([]byte{0xAB}, n)
+		encoded := encodeTLV(tagOctetString, value)
+
+		tag, got, rest, err := readTLV(append(encoded, 0xFF))
+		if err != nil {
+			t.Fatalf("length %d: readTLV: %v", n, err)
+		}
+		if tag != tagOctetString || !bytes.Equal(got, value) || !bytes.Equal(rest, []byte{0xFF}) {
+			t.Errorf("length %d: got tag 0x%02x, %d bytes, rest %x", n, tag, len(got), rest)
+		}
+	}
+}
+
+func TestTLVLengthEncoding(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0x7F, []byte{0x04, 0x7F}},
+		{0x80, []byte{0x04, 0x81, 0x80}},
+		{0x100, []byte{0x04, 0x82, 0x01, 0x00}},
+		{0x10000, []byte{0x04, 0x83, 0x01, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		got := encodeTLV(tagOctetString, make([]byte, tt.n))
+		if !bytes.Equal(got[:len(tt.want)], tt.want) {
+			t.Errorf("length %d: header %x, want %x", tt.n, got[:len(tt.want)], tt.want)
+		}
+	}
+}
+
+func TestIntegerRoundTrip(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, -129, 255, 256, 1 << 31, -(1 << 31), 1<<63 - 1, -1 << 63} {
+		got, rest, err := readInteger(encodeInteger(tagInteger, n))
+		if err != nil {
+			t.Fatalf("%d: readInteger: %v", n, err)
+		}
+		if got != n || len(rest) != 0 {
+			t.Errorf("%d: got %d, rest %x", n, got, rest)
+		}
+	}
+}
+
+func TestUnsignedEncoding(t *testing.T) {
+	// The top bit set needs a leading zero byte to stay positive
+	got := encodeUnsigned(tagGauge32, 0xFFFFFFFF)
+	want := []byte{tagGauge32, 0x05, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeUnsigned(0xFFFFFFFF) = %x, want %x", got, want)
+	}
+}
+
+func TestOIDRoundTrip(t *testing.T) {
+	for _, s := range []string{"1.3", "1.3.6.1.2.1.1.1.0", "1.3.6.1.4.1.8072.127.16383.16384.2097152", "2.39.4294967"} {
+		oid, err := ParseOID(s)
+		if err != nil {
+			t.Fatalf("ParseOID(%q): %v", s, err)
+		}
+		value, _, err := readExpected(encodeOID(oid), tagOID)
+		if err != nil {
+			t.Fatalf("%s: readExpected: %v", s, err)
+		}
+		got, err := parseOID(value)
+		if err != nil {
+			t.Fatalf("%s: parseOID: %v", s, err)
+		}
+		if got.String() != s {
+			t.Errorf("round trip of %s = %s", s, got)
+		}
+	}
+}
+
+func TestReadTLVMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{0x30},
+		{0x30, 0x05, 0x01},
+		{0x30, 0x80},
+		{0x30, 0x84, 0x00, 0x00, 0x00, 0x01, 0x00},
+		{0x30, 0x82, 0x01},
+	} {
+		if _, _, _, err := readTLV(data); err == nil {
+			t.Errorf("readTLV(%x) succeeded", data)
+		}
+	}
+}
+
+func FuzzParseRequest(f *testing.F) {
+	f.Add(encodeRequest(version2c, "public", pduGet, 0, 0, "1.3.6.1.2.1.1.1.0"))
+	f.Add(encodeRequest(version1, "public", pduGetNext, 0, 0, "1.3.6.1"))
+	f.Add(encodeRequest(version2c, "public", pduGetBulk, 1, 10, "1.3.6.1", "1.3.6.1.4.1"))
+	f.Add([]byte{0x30, 0x83, 0xFF, 0xFF, 0xFF})
+	mib := func() []Variable { return testMIB }
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		req, err := parseRequest(packet)
+		if err != nil {
+			return
+		}
+		for _, oid := range req.oids {
+			if len(oid) < 2 {
+				t.Fatalf("parsed OID %v has fewer than two components", oid)
+			}
+		}
+		response, _ := handle(packet, "public", mib)
+		if len(response) > maxResponseSize+len(packet)+64 {
+			t.Fatalf("%d-byte request answered with %d bytes", len(packet), len(response))
+		}
+	})
+}
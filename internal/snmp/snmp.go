@@ -0,0 +1,386 @@
+// Package snmp is a minimal read-only SNMP agent (v1 and v2c, Get, GetNext and
+// GetBulk) so network management systems that only speak SNMP can poll the
+// status a machine shows on its login screen.
+package snmp
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SNMP versions as sent in messages.
+const (
+	version1  = 0
+	version2c = 1
+)
+
+// PDU types.
+const (
+	pduGet      = 0xA0
+	pduGetNext  = 0xA1
+	pduResponse = 0xA2
+	pduSet      = 0xA3
+	pduGetBulk  = 0xA5
+)
+
+// Error statuses.
+const (
+	errTooBig     = 1
+	errNoSuchName = 2
+	errReadOnly   = 4
+	errNoAccess   = 6
+)
+
+// maxBulkVariables bounds the variables in a GetBulk response so it fits a
+// single unfragmented datagram in practice.
+const maxBulkVariables = 40
+
+// maxResponseSize bounds the variable bindings of a response, so a small
+// request cannot be answered with a much larger datagram. Bigger bulk
+// responses are cut; Get and GetNext answer tooBig.
+const maxResponseSize = 1400
+
+// OID is an object identifier such as 1.3.6.1.2.1.1.1.0.
+type OID []int
+
+// ParseOID parses a dotted object identifier such as "1.3.6.1.4.1.8072".
+func ParseOID(s string) (OID, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), ".")
+	var oid OID
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid object identifier %q", s)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid object identifier %q", s)
+	}
+	return oid, nil
+}
+
+// String returns the dotted form of the identifier.
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, n := range o {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Append returns a new identifier with the components added.
+func (o OID) Append(components ...int) OID {
+	out := make(OID, 0, len(o)+len(components))
+	out = append(out, o...)
+	return append(out, components...)
+}
+
+// compare orders identifiers lexicographically, as GetNext walks them.
+func (o OID) compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] != other[i] {
+			if o[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(o) - len(other)
+}
+
+// Gauge is a Gauge32 value, e.g. a count that can go up and down.
+type Gauge uint32
+
+// TimeTicks is a duration in hundredths of a second.
+type TimeTicks uint32
+
+// Variable is a value served by the agent. Value is a string, an int, a
+// Gauge or a TimeTicks.
+type Variable struct {
+	OID   OID
+	Value interface{}
+}
+
+// MIB returns the variables to serve. It is called for every request, so it
+// should cache what it reads.
+type MIB func() []Variable
+
+// Serve answers requests on conn with the given community until ctx is
+// cancelled. Requests with another community are dropped without an answer.
+func Serve(ctx context.Context, conn net.PacketConn, community string, mib MIB) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read request: %v", err)
+		}
+		response, err := handle(buf[:n], community, mib)
+		if err != nil || response == nil {
+			continue
+		}
+		conn.WriteTo(response, addr)
+	}
+}
+
+// request is a decoded SNMP message.
+type request struct {
+	version   int64
+	community []byte
+	pduType   byte
+	requestID int64
+	// nonRepeaters and maxRepetitions are the error status and index fields,
+	// which GetBulk reuses.
+	nonRepeaters   int64
+	maxRepetitions int64
+	oids           []OID
+}
+
+// handle decodes a request and returns the encoded response, or nil when it
+// must be dropped.
+func handle(packet []byte, community string, mib MIB) ([]byte, error) {
+	req, err := parseRequest(packet)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(req.community, []byte(community)) != 1 {
+		return nil, nil
+	}
+
+	vars := mib()
+	sort.Slice(vars, func(i, j int) bool { return vars[i].OID.compare(vars[j].OID) < 0 })
+
+	switch req.pduType {
+	case pduGet:
+		return getResponse(req, vars, false), nil
+	case pduGetNext:
+		return getResponse(req, vars, true), nil
+	case pduGetBulk:
+		if req.version == version1 {
+			return nil, nil
+		}
+		return bulkResponse(req, vars), nil
+	case pduSet:
+		status := errNoAccess
+		if req.version == version1 {
+			status = errReadOnly
+		}
+		return encodeResponse(req, status, 1, requestedNulls(req)), nil
+	}
+	return nil, fmt.Errorf("unsupported PDU type 0x%02x", req.pduType)
+}
+
+// parseRequest decodes a v1 or v2c message.
+func parseRequest(packet []byte) (*request, error) {
+	message, _, err := readExpected(packet, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	req := &request{}
+	if req.version, message, err = readInteger(message); err != nil {
+		return nil, err
+	}
+	if req.version != version1 && req.version != version2c {
+		return nil, fmt.Errorf("unsupported SNMP version %d", req.version)
+	}
+	if req.community, message, err = readExpected(message, tagOctetString); err != nil {
+		return nil, err
+	}
+
+	var pdu []byte
+	if req.pduType, pdu, _, err = readTLV(message); err != nil {
+		return nil, err
+	}
+	if req.requestID, pdu, err = readInteger(pdu); err != nil {
+		return nil, err
+	}
+	if req.nonRepeaters, pdu, err = readInteger(pdu); err != nil {
+		return nil, err
+	}
+	if req.maxRepetitions, pdu, err = readInteger(pdu); err != nil {
+		return nil, err
+	}
+	bindings, _, err := readExpected(pdu, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	for len(bindings) > 0 {
+		var binding, value []byte
+		if binding, bindings, err = readExpected(bindings, tagSequence); err != nil {
+			return nil, err
+		}
+		if value, _, err = readExpected(binding, tagOID); err != nil {
+			return nil, err
+		}
+		oid, err := parseOID(value)
+		if err != nil {
+			return nil, err
+		}
+		req.oids = append(req.oids, oid)
+	}
+	return req, nil
+}
+
+// lookup returns the variable with the identifier, or the first one after it
+// when next is set. ok is false when there is none.
+func lookup(vars []Variable, oid OID, next bool) (Variable, bool) {
+	i := sort.Search(len(vars), func(i int) bool { return vars[i].OID.compare(oid) >= 0 })
+	if next && i < len(vars) && vars[i].OID.compare(oid) == 0 {
+		i++
+	}
+	if i == len(vars) || (!next && vars[i].OID.compare(oid) != 0) {
+		return Variable{}, false
+	}
+	return vars[i], true
+}
+
+// getResponse answers a Get or GetNext. Missing variables are exceptions in
+// v2c and a noSuchName error in v1, and a response over maxResponseSize is a
+// tooBig error without bindings.
+func getResponse(req *request, vars []Variable, next bool) []byte {
+	var bindings [][]byte
+	size := 0
+	for i, oid := range req.oids {
+		if size > maxResponseSize {
+			break
+		}
+		v, ok := lookup(vars, oid, next)
+		if ok {
+			b := encodeBinding(v.OID, encodeValue(v.Value))
+			bindings = append(bindings, b)
+			size += len(b)
+			continue
+		}
+		if req.version == version1 {
+			return encodeResponse(req, errNoSuchName, i+1, requestedNulls(req))
+		}
+		exception := tagNoSuchObject
+		if next {
+			exception = tagEndOfMibView
+		}
+		b := encodeBinding(oid, encodeTLV(byte(exception), nil))
+		bindings = append(bindings, b)
+		size += len(b)
+	}
+	if size > maxResponseSize {
+		return encodeResponse(req, errTooBig, 0, nil)
+	}
+	return encodeResponse(req, 0, 0, bindings)
+}
+
+// bulkResponse answers a GetBulk: one GetNext for each of the first
+// non-repeaters identifiers, then up to max-repetitions for the others.
+func bulkResponse(req *request, vars []Variable) []byte {
+	nonRepeaters := int(req.nonRepeaters)
+	if nonRepeaters < 0 {
+		nonRepeaters = 0
+	}
+	if nonRepeaters > len(req.oids) {
+		nonRepeaters = len(req.oids)
+	}
+	repetitions := int(req.maxRepetitions)
+	if repetitions < 0 {
+		repetitions = 0
+	}
+
+	var bindings [][]byte
+	size := 0
+	add := func(b []byte) bool {
+		if len(bindings) == maxBulkVariables || size+len(b) > maxResponseSize {
+			return false
+		}
+		bindings = append(bindings, b)
+		size += len(b)
+		return true
+	}
+	next := func(oid OID) (OID, []byte, bool) {
+		if v, ok := lookup(vars, oid, true); ok {
+			return v.OID, encodeBinding(v.OID, encodeValue(v.Value)), true
+		}
+		return oid, encodeBinding(oid, encodeTLV(tagEndOfMibView, nil)), false
+	}
+
+	for _, oid := range req.oids[:nonRepeaters] {
+		_, b, _ := next(oid)
+		if !add(b) {
+			return encodeResponse(req, 0, 0, bindings)
+		}
+	}
+	cursors := append([]OID(nil), req.oids[nonRepeaters:]...)
+	for r := 0; r < repetitions && len(cursors) > 0; r++ {
+		more := false
+		for i, oid := range cursors {
+			var b []byte
+			var found bool
+			cursors[i], b, found = next(oid)
+			if !add(b) {
+				return encodeResponse(req, 0, 0, bindings)
+			}
+			more = more || found
+		}
+		// Every column is past the end of the MIB
+		if !more {
+			break
+		}
+	}
+	return encodeResponse(req, 0, 0, bindings)
+}
+
+// requestedNulls returns the requested identifiers with null values, as error
+// responses echo them.
+func requestedNulls(req *request) [][]byte {
+	var bindings [][]byte
+	for _, oid := range req.oids {
+		bindings = append(bindings, encodeBinding(oid, encodeTLV(tagNull, nil)))
+	}
+	return bindings
+}
+
+// encodeBinding encodes a variable binding.
+func encodeBinding(oid OID, value []byte) []byte {
+	return encodeTLV(tagSequence, concat(encodeOID(oid), value))
+}
+
+// encodeValue encodes a variable's value.
+func encodeValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return encodeTLV(tagOctetString, []byte(v))
+	case int:
+		return encodeInteger(tagInteger, int64(v))
+	case Gauge:
+		return encodeUnsigned(tagGauge32, uint32(v))
+	case TimeTicks:
+		return encodeUnsigned(tagTimeTicks, uint32(v))
+	}
+	return encodeTLV(tagNull, nil)
+}
+
+// encodeResponse encodes a Response PDU in a message of the request's version
+// and community.
+func encodeResponse(req *request, errorStatus, errorIndex int, bindings [][]byte) []byte {
+	pdu := encodeTLV(pduResponse, concat(
+		encodeInteger(tagInteger, req.requestID),
+		encodeInteger(tagInteger, int64(errorStatus)),
+		encodeInteger(tagInteger, int64(errorIndex)),
+		encodeTLV(tagSequence, concat(bindings...)),
+	))
+	return encodeTLV(tagSequence, concat(
+		encodeInteger(tagInteger, req.version),
+		encodeTLV(tagOctetString, req.community),
+		pdu,
+	))
+}
@@ -0,0 +1,156 @@
+package snmp
+
+import (
+	"strings"
+	"testing"
+)
+
+// testMIB is served by the tests, in no particular order.
+var testMIB = []Variable{
+	{OID: OID{1, 3, 6, 1, 2, 1, 1, 5, 0}, Value: "PC-01"},
+	{OID: OID{1, 3, 6, 1, 2, 1, 1, 1, 0}, Value: "Windows 11 Enterprise"},
+	{OID: OID{1, 3, 6, 1, 2, 1, 1, 3, 0}, Value: TimeTicks(12345)},
+	{OID: OID{1, 3, 6, 1, 4, 1, 8072, 1}, Value: Gauge(42)},
+	{OID: OID{1, 3, 6, 1, 4, 1, 8072, 2}, Value: -7},
+}
+
+// encodeRequest encodes a request with null values for the identifiers.
+func encodeRequest(version int64, community string, pduType byte, nonRepeaters, maxRepetitions int64, oids ...string) []byte {
+	var bindings [][]byte
+	for _, s := range oids {
+		oid, err := ParseOID(s)
+		if err != nil {
+			panic(err)
+		}
+		bindings = append(bindings, encodeBinding(oid, encodeTLV(tagNull, nil)))
+	}
+	pdu := encodeTLV(pduType, concat(
+		encodeInteger(tagInteger, 1234),
+		encodeInteger(tagInteger, nonRepeaters),
+		encodeInteger(tagInteger, maxRepetitions),
+		encodeTLV(tagSequence, concat(bindings...)),
+	))
+	return encodeTLV(tagSequence, concat(
+		encodeInteger(tagInteger, version),
+		encodeTLV(tagOctetString, []byte(community)),
+		pdu,
+	))
+}
+
+// response is a decoded Response PDU.
+type response struct {
+	errorStatus, errorIndex int64
+	oids                    []string
+	tags                    []byte
+}
+
+// query sends a request to handle and decodes the response.
+func query(t *testing.T, packet []byte) *response {
+	t.Helper()
+
+	out, err := handle(packet, "public", func() []Variable { return testMIB })
+	if err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if out == nil {
+		t.Fatal("request was dropped")
+	}
+	req, err := parseRequest(out)
+	if err != nil {
+		t.Fatalf("parsing the response: %v", err)
+	}
+	if req.pduType != pduResponse || req.requestID != 1234 {
+		t.Fatalf("response PDU 0x%02x, request ID %d", req.pduType, req.requestID)
+	}
+
+	r := &response{errorStatus: req.nonRepeaters, errorIndex: req.maxRepetitions}
+	message, _, _ := readExpected(out, tagSequence)
+	_, message, _ = readInteger(message)
+	_, message, _ = readExpected(message, tagOctetString)
+	_, pdu, _, _ := readTLV(message)
+	for i := 0; i < 3; i++ {
+		_, pdu, _ = readInteger(pdu)
+	}
+	bindings, _, _ := readExpected(pdu, tagSequence)
+	for len(bindings) > 0 {
+		var binding []byte
+		binding, bindings, _ = readExpected(bindings, tagSequence)
+		value, rest, _ := readExpected(binding, tagOID)
+		oid, _ := parseOID(value)
+		tag, _, _, _ := readTLV(rest)
+		r.oids = append(r.oids, oid.String())
+		r.tags = append(r.tags, tag)
+	}
+	return r
+}
+
+func TestGet(t *testing.T) {
+	r := query(t, encodeRequest(version2c, "public", pduGet, 0, 0, "1.3.6.1.2.1.1.5.0", "1.3.6.1.2.1.1.9.0"))
+	if r.errorStatus != 0 || strings.Join(r.oids, " ") != "1.3.6.1.2.1.1.5.0 1.3.6.1.2.1.1.9.0" {
+		t.Fatalf("response = %+v", r)
+	}
+	if r.tags[0] != tagOctetString || r.tags[1] != tagNoSuchObject {
+		t.Errorf("value tags = %x, want octet string and noSuchObject", r.tags)
+	}
+}
+
+func TestGetV1Missing(t *testing.T) {
+	r := query(t, encodeRequest(version1, "public", pduGet, 0, 0, "1.3.6.1.2.1.1.5.0", "1.3.6.1.2.1.1.9.0"))
+	if r.errorStatus != errNoSuchName || r.errorIndex != 2 {
+		t.Errorf("error status %d index %d, want noSuchName at 2", r.errorStatus, r.errorIndex)
+	}
+}
+
+func TestGetNext(t *testing.T) {
+	r := query(t, encodeRequest(version2c, "public", pduGetNext, 0, 0, "1.3.6.1.2.1.1.1.0", "1.3.6.1.4.1.8072.2"))
+	if strings.Join(r.oids, " ") != "1.3.6.1.2.1.1.3.0 1.3.6.1.4.1.8072.2" {
+		t.Fatalf("oids = %v", r.oids)
+	}
+	if r.tags[0] != tagTimeTicks || r.tags[1] != tagEndOfMibView {
+		t.Errorf("value tags = %x, want TimeTicks and endOfMibView", r.tags)
+	}
+}
+
+func TestGetTooBig(t *testing.T) {
+	var oids []string
+	for i := 0; i < 200; i++ {
+		oids = append(oids, "1.3.6.1.2.1.1.1.0")
+	}
+	r := query(t, encodeRequest(version2c, "public", pduGet, 0, 0, oids...))
+	if r.errorStatus != errTooBig || len(r.oids) != 0 {
+		t.Errorf("error status %d with %d bindings, want tooBig without bindings", r.errorStatus, len(r.oids))
+	}
+}
+
+func TestGetBulk(t *testing.T) {
+	r := query(t, encodeRequest(version2c, "public", pduGetBulk, 1, 3, "1.3.6.1.2.1.1.1.0", "1.3.6.1.4.1.8072"))
+	want := "1.3.6.1.2.1.1.3.0 1.3.6.1.4.1.8072.1 1.3.6.1.4.1.8072.2 1.3.6.1.4.1.8072.2"
+	if got := strings.Join(r.oids, " "); got != want {
+		t.Errorf("oids = %s, want %s", got, want)
+	}
+	if r.tags[3] != tagEndOfMibView {
+		t.Errorf("last tag = 0x%02x, want endOfMibView", r.tags[3])
+	}
+}
+
+func TestGetBulkCut(t *testing.T) {
+	r := query(t, encodeRequest(version2c, "public", pduGetBulk, 0, 1000, "1.3", "1.3", "1.3", "1.3"))
+	if r.errorStatus != 0 || len(r.oids) == 0 || len(r.oids) > maxBulkVariables {
+		t.Errorf("error status %d with %d bindings", r.errorStatus, len(r.oids))
+	}
+}
+
+func TestSetRefused(t *testing.T) {
+	r := query(t, encodeRequest(version2c, "public", pduSet, 0, 0, "1.3.6.1.2.1.1.5.0"))
+	if r.errorStatus != errNoAccess || r.errorIndex != 1 {
+		t.Errorf("error status %d index %d, want noAccess at 1", r.errorStatus, r.errorIndex)
+	}
+}
+
+func TestWrongCommunity(t *testing.T) {
+	out, err := handle(encodeRequest(version2c, "private", pduGet, 0, 0, "1.3.6.1.2.1.1.5.0"), "public",
+		func() []Variable { return testMIB })
+	if err != nil || out != nil {
+		t.Errorf("handle = %x, %v; want the request dropped", out, err)
+	}
+}
@@ -0,0 +1,257 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Thresholds above which the domain health section flags a problem.
+const (
+	// MaxTimeSkew is Kerberos' default tolerance; beyond it logons fail.
+	MaxTimeSkew = 5 * time.Minute
+	// MaxMachinePasswordAge is twice the default 30-day machine password
+	// rotation, so an older password means rotation is failing.
+	MaxMachinePasswordAge = 60 * 24 * time.Hour
+)
+
+// netlogonControlTCQuery is NETLOGON_CONTROL_TC_QUERY, what "nltest /sc_query" uses.
+const netlogonControlTCQuery = 6
+
+// machineAccountKey (HKLM) holds the machine account password; CupdTime is when
+// it was last changed. Only SYSTEM can read it.
+const machineAccountKey = `SECURITY\Policy\Secrets\$MACHINE.ACC\CupdTime`
+
+var (
+	procINetLogonControl2 = syscall.NewLazyDLL("netapi32.dll").NewProc("I_NetLogonControl2")
+	procNetRemoteTOD      = syscall.NewLazyDLL("netapi32.dll").NewProc("NetRemoteTOD")
+)
+
+// netlogonInfo2 is NETLOGON_INFO_2.
+type netlogonInfo2 struct {
+	Flags               uint32
+	PDCConnectionStatus uint32
+	TrustedDCName       *uint16
+	TCConnectionStatus  uint32
+}
+
+// timeOfDayInfo is TIME_OF_DAY_INFO.
+type timeOfDayInfo struct {
+	Elapsed   uint32
+	Msecs     uint32
+	Hours     uint32
+	Mins      uint32
+	Secs      uint32
+	Hunds     uint32
+	Timezone  int32
+	Tinterval uint32
+	Day       uint32
+	Month     uint32
+	Year      uint32
+	Weekday   uint32
+}
+
+// ADHealthInfo is the health of this computer's membership in its domain.
+type ADHealthInfo struct {
+	Domain string
+	// DC is the domain controller the secure channel is established with.
+	DC string
+	// SecureChannelOK is false when the trust relationship is broken.
+	SecureChannelOK     bool
+	SecureChannelStatus string
+	// PasswordAge is the age of the machine account password, 0 if unknown.
+	PasswordAge time.Duration
+	// TimeSkew is the DC's clock minus ours; SkewKnown is false when the DC
+	// could not be asked.
+	TimeSkew  time.Duration
+	SkewKnown bool
+}
+
+// GatherADHealth checks the secure channel to the domain (like
+// "nltest /sc_query"), the machine account password age and the clock skew
+// against the DC. It returns nil when the computer is not domain-joined.
+// Requires administrator or SYSTEM.
+func GatherADHealth(ctx context.Context) (*ADHealthInfo, error) {
+	domain := joinedDomain()
+	if domain == "" {
+		return nil, nil
+	}
+	info := &ADHealthInfo{Domain: domain}
+
+	err := withContext(ctx, func() error {
+		dc, err := querySecureChannel(domain)
+		info.DC = dc
+		if err != nil {
+			info.SecureChannelStatus = err.Error()
+			return nil
+		}
+		info.SecureChannelOK = true
+		info.SecureChannelStatus = "OK"
+		return nil
+	})
+	if err != nil {
+		return info, err
+	}
+
+	info.PasswordAge = machinePasswordAge()
+
+	if info.DC != "" {
+		err = withContext(ctx, func() error {
+			dcTime, err := remoteTime(info.DC)
+			if err != nil {
+				return err
+			}
+			info.TimeSkew = dcTime.Sub(time.Now()).Round(time.Second)
+			info.SkewKnown = true
+			return nil
+		})
+		if err != nil && ctx.Err() != nil {
+			return info, err
+		}
+	}
+
+	return info, nil
+}
+
+// joinedDomain returns the NetBIOS name of the domain this computer is joined
+// to, or "" for a workgroup computer.
+func joinedDomain() string {
+	var name *uint16
+	var status uint32
+	if err := windows.NetGetJoinInformation(nil, &name, &status); err != nil {
+		return ""
+	}
+	defer windows.NetApiBufferFree((*byte)(unsafe.Pointer(name)))
+
+	if status != windows.NetSetupDomainName {
+		return ""
+	}
+	return windows.UTF16PtrToString(name)
+}
+
+// querySecureChannel asks Netlogon for the state of the secure channel to
+// domain and returns the DC it is established with.
+func querySecureChannel(domain string) (string, error) {
+	domainPtr, err := syscall.UTF16PtrFromString(domain)
+	if err != nil {
+		return "", err
+	}
+
+	var info *netlogonInfo2
+	ret, _, _ := procINetLogonControl2.Call(
+		0,
+		netlogonControlTCQuery,
+		2,
+		uintptr(unsafe.Pointer(&domainPtr)),
+		uintptr(unsafe.Pointer(&info)),
+	)
+	if ret != 0 {
+		return "", fmt.Errorf("secure channel query failed: %v", syscall.Errno(ret))
+	}
+	defer windows.NetApiBufferFree((*byte)(unsafe.Pointer(info)))
+
+	dc := strings.TrimPrefix(windows.UTF16PtrToString(info.TrustedDCName), `\\`)
+	if info.TCConnectionStatus != 0 {
+		return dc, fmt.Errorf("%v", syscall.Errno(info.TCConnectionStatus))
+	}
+	return dc, nil
+}
+
+// machinePasswordAge returns how long ago the machine account password was
+// changed, or 0 when it can't be read (not running as SYSTEM).
+func machinePasswordAge() time.Duration {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, machineAccountKey, registry.QUERY_VALUE)
+	if err != nil {
+		return 0
+	}
+	defer key.Close()
+
+	buf := make([]byte, 8)
+	n, _, err := key.GetValue("", buf)
+	if err != nil || n < 8 {
+		return 0
+	}
+	ft := windows.Filetime{
+		LowDateTime:  binary.LittleEndian.Uint32(buf[0:4]),
+		HighDateTime: binary.LittleEndian.Uint32(buf[4:8]),
+	}
+	return time.Since(time.Unix(0, ft.Nanoseconds()))
+}
+
+// remoteTime returns the current time on a server, as "net time \\server" does.
+func remoteTime(server string) (time.Time, error) {
+	serverPtr, err := syscall.UTF16PtrFromString(`\\` + server)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var tod *timeOfDayInfo
+	ret, _, _ := procNetRemoteTOD.Call(uintptr(unsafe.Pointer(serverPtr)), uintptr(unsafe.Pointer(&tod)))
+	if ret != 0 {
+		return time.Time{}, fmt.Errorf("failed to read the time of %s: %v", server, syscall.Errno(ret))
+	}
+	defer windows.NetApiBufferFree((*byte)(unsafe.Pointer(tod)))
+
+	return time.Unix(int64(tod.Elapsed), int64(tod.Hunds)*int64(10*time.Millisecond)), nil
+}
+
+// Problems lists what needs attention, most serious first.
+func (a *ADHealthInfo) Problems() []string {
+	var problems []string
+	if !a.SecureChannelOK {
+		problems = append(problems, "Trust relationship broken")
+	}
+	if a.SkewKnown && (a.TimeSkew > MaxTimeSkew || a.TimeSkew < -MaxTimeSkew) {
+		problems = append(problems, "Clock skew breaks Kerberos")
+	}
+	if a.PasswordAge > MaxMachinePasswordAge {
+		problems = append(problems, "Machine password not rotating")
+	}
+	return problems
+}
+
+// FormatADHealthLines returns the domain health as lines for display.
+func (a *ADHealthInfo) FormatADHealthLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "Domain Health")
+	lines = append(lines, "")
+
+	lines = append(lines, fmt.Sprintf("Domain: %s", a.Domain))
+	if a.DC != "" {
+		lines = append(lines, fmt.Sprintf("DC: %s", a.DC))
+	}
+	lines = append(lines, fmt.Sprintf("Secure channel: %s", a.SecureChannelStatus))
+	if a.PasswordAge > 0 {
+		lines = append(lines, fmt.Sprintf("Machine password: %d days old", int(a.PasswordAge.Hours()/24)))
+	}
+	if a.SkewKnown {
+		lines = append(lines, fmt.Sprintf("Time skew: %s", formatSkew(a.TimeSkew)))
+	}
+
+	if problems := a.Problems(); len(problems) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, "Problems:")
+		for _, p := range problems {
+			lines = append(lines, "  "+p)
+		}
+	}
+	return lines
+}
+
+// formatSkew formats a clock difference with its sign, e.g. "+3s" or "-2m10s".
+func formatSkew(d time.Duration) string {
+	if d < 0 {
+		return "-" + (-d).String()
+	}
+	return "+" + d.String()
+}
@@ -0,0 +1,55 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// AntiVirusProduct is used for the Security Center query of the registered
+// antivirus products (workstations only).
+type AntiVirusProduct struct {
+	DisplayName  string
+	ProductState uint32
+}
+
+// MSFT_MpComputerStatus is used for the Defender status query, the fallback
+// on servers, which have no Security Center.
+type MSFT_MpComputerStatus struct {
+	AntivirusEnabled          bool
+	RealTimeProtectionEnabled bool
+}
+
+// productStateEnabled is the real-time protection bit of a Security Center
+// product state.
+const productStateEnabled = 0x1000
+
+// AntivirusEnabled reports whether any antivirus product has real-time
+// protection on, and names the one found.
+func AntivirusEnabled(ctx context.Context) (bool, string, error) {
+	var products []AntiVirusProduct
+	err := withContext(ctx, func() error {
+		return winapi.WMI.QueryNamespace("SELECT DisplayName, ProductState FROM AntiVirusProduct", &products, `root\SecurityCenter2`)
+	})
+	if err == nil && len(products) > 0 {
+		for _, p := range products {
+			if p.ProductState&productStateEnabled != 0 {
+				return true, p.DisplayName, nil
+			}
+		}
+		return false, products[0].DisplayName, nil
+	}
+
+	var status []MSFT_MpComputerStatus
+	err = withContext(ctx, func() error {
+		return winapi.WMI.QueryNamespace("SELECT AntivirusEnabled, RealTimeProtectionEnabled FROM MSFT_MpComputerStatus", &status, `root\Microsoft\Windows\Defender`)
+	})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to read antivirus status: %v", err)
+	}
+	if len(status) == 0 {
+		return false, "", fmt.Errorf("no antivirus product found")
+	}
+	return status[0].AntivirusEnabled && status[0].RealTimeProtectionEnabled, "Microsoft Defender", nil
+}
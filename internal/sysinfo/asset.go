@@ -0,0 +1,200 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Win32_SystemEnclosure is used for WMI query to get the asset tag and chassis.
+type Win32_SystemEnclosure struct {
+	SMBIOSAssetTag string
+	ChassisTypes   []uint16
+}
+
+// chassisTypes names the SMBIOS chassis types.
+var chassisTypes = map[uint16]string{
+	3: "Desktop", 4: "Low Profile Desktop", 5: "Pizza Box", 6: "Mini Tower", 7: "Tower",
+	8: "Portable", 9: "Laptop", 10: "Notebook", 11: "Hand Held", 12: "Docking Station",
+	13: "All in One", 14: "Sub Notebook", 15: "Space-Saving", 16: "Lunch Box",
+	17: "Main Server Chassis", 23: "Rack Mount Chassis", 24: "Sealed-Case PC",
+	30: "Tablet", 31: "Convertible", 32: "Detachable", 33: "IoT Gateway",
+	34: "Embedded PC", 35: "Mini PC", 36: "Stick PC",
+}
+
+// placeholderAssetTags are what vendors put in the asset tag when none was set.
+var placeholderAssetTags = []string{"", "No Asset Tag", "No Asset Information", "Default string", "To Be Filled By O.E.M.", "Asset-1234567890", "None"}
+
+// AssetField is a custom line: a label and where its value is read from.
+type AssetField struct {
+	Label string
+	// Registry is a value path such as `HKLM\SOFTWARE\Contoso\Asset\Owner`.
+	Registry string
+	// Env is an environment variable name.
+	Env string
+}
+
+// AssetInfo is the SMBIOS asset tag and chassis type and the custom fields.
+type AssetInfo struct {
+	AssetTag string
+	Chassis  string
+	// Fields are "Label: value" lines, in the configured order.
+	Fields []string
+}
+
+// GatherAsset reads the SMBIOS asset tag and chassis type, every string value
+// of registryKey (an HKLM path, may be empty) and the custom fields.
+func GatherAsset(ctx context.Context, registryKey string, fields []AssetField) (*AssetInfo, error) {
+	info := &AssetInfo{}
+
+	var enclosures []Win32_SystemEnclosure
+	err := queryWMI(ctx, "SELECT SMBIOSAssetTag, ChassisTypes FROM Win32_SystemEnclosure", &enclosures)
+	if err == nil && len(enclosures) > 0 {
+		info.AssetTag = strings.TrimSpace(enclosures[0].SMBIOSAssetTag)
+		for _, placeholder := range placeholderAssetTags {
+			if strings.EqualFold(info.AssetTag, placeholder) {
+				info.AssetTag = ""
+			}
+		}
+		if len(enclosures[0].ChassisTypes) > 0 {
+			info.Chassis = chassisTypes[enclosures[0].ChassisTypes[0]]
+		}
+	}
+
+	if registryKey != "" {
+		info.Fields = append(info.Fields, registryKeyLines(registryKey)...)
+	}
+	for _, field := range fields {
+		value := ""
+		if field.Registry != "" {
+			value = registryPathValue(field.Registry)
+		}
+		if value == "" && field.Env != "" {
+			value = os.Getenv(field.Env)
+		}
+		if value != "" {
+			info.Fields = append(info.Fields, fmt.Sprintf("%s: %s", field.Label, value))
+		}
+	}
+
+	if err != nil {
+		return info, fmt.Errorf("failed to query the system enclosure: %v", err)
+	}
+	return info, nil
+}
+
+// registryKeyLines returns the string and number values of an HKLM key as
+// "Name: value" lines sorted by name.
+func registryKeyLines(path string) []string {
+	root, path := splitRegistryRoot(path)
+	key, err := winapi.Reg.OpenKey(root, path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+
+	names, err := key.ReadValueNames(0)
+	if err != nil {
+		return nil
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if v := readRegistryValue(key, name); v != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", name, v))
+		}
+	}
+	return lines
+}
+
+// registryPathValue reads a value given as its full path, the last element
+// being the value name, e.g. `HKLM\SOFTWARE\Contoso\Asset\Owner`.
+func registryPathValue(path string) string {
+	root, path := splitRegistryRoot(path)
+	i := strings.LastIndex(path, `\`)
+	if i < 0 {
+		return ""
+	}
+	key, err := winapi.Reg.OpenKey(root, path[:i], registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	return readRegistryValue(key, path[i+1:])
+}
+
+// readRegistryValue reads a string or number value as text.
+func readRegistryValue(key winapi.RegistryKey, name string) string {
+	if v, _, err := key.GetStringValue(name); err == nil {
+		return strings.TrimSpace(v)
+	}
+	if v, _, err := key.GetIntegerValue(name); err == nil {
+		return fmt.Sprintf("%d", v)
+	}
+	return ""
+}
+
+// splitRegistryRoot splits off an HKLM\ or HKEY_LOCAL_MACHINE\ prefix. Paths
+// without one are under HKLM, the only hive SYSTEM reliably sees.
+func splitRegistryRoot(path string) (registry.Key, string) {
+	for _, prefix := range []string{`HKLM\`, `HKEY_LOCAL_MACHINE\`} {
+		if len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+			return registry.LOCAL_MACHINE, path[len(prefix):]
+		}
+	}
+	return registry.LOCAL_MACHINE, path
+}
+
+// FormatAssetLines returns the asset information as lines for display.
+func (a *AssetInfo) FormatAssetLines() []string {
+	lines := []string{}
+	if a.AssetTag != "" {
+		lines = append(lines, fmt.Sprintf("Asset Tag: %s", a.AssetTag))
+	}
+	if a.Chassis != "" {
+		lines = append(lines, fmt.Sprintf("Chassis: %s", a.Chassis))
+	}
+	return append(lines, a.Fields...)
+}
+
+// Win32_ComputerSystemManufacturer is used for WMI query to get the vendor.
+type Win32_ComputerSystemManufacturer struct {
+	Manufacturer string
+}
+
+// Win32_ComputerSystemSKU is used for WMI query to get the product number.
+type Win32_ComputerSystemSKU struct {
+	SystemSKUNumber string
+}
+
+// GetProductNumber returns the computer's SKU, which HP fills with the product
+// number (e.g. "5MS32UT#ABA"), or "" if it can't be read.
+func GetProductNumber(ctx context.Context) string {
+	var systems []Win32_ComputerSystemSKU
+	err := queryWMI(ctx, "SELECT SystemSKUNumber FROM Win32_ComputerSystem", &systems)
+	if err != nil || len(systems) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(systems[0].SystemSKUNumber)
+}
+
+// GetManufacturer returns the computer's manufacturer, e.g. "Dell Inc.", or ""
+// if it can't be read.
+func GetManufacturer(ctx context.Context) string {
+	var systems []Win32_ComputerSystemManufacturer
+	err := queryWMI(ctx, "SELECT Manufacturer FROM Win32_ComputerSystem", &systems)
+	if err != nil || len(systems) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(systems[0].Manufacturer)
+}
@@ -0,0 +1,171 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultBackupMaxAge is how old the last successful backup may be before it
+// is flagged as overdue: a daily job plus some slack.
+const DefaultBackupMaxAge = 26 * time.Hour
+
+// backupAgent describes how a backup product logs its job results.
+type backupAgent struct {
+	Name      string
+	Log       string
+	Providers []string
+	// Success and Failure are the event IDs of finished jobs. When Finished
+	// is set instead, the result is read from the event's message.
+	Success  []int
+	Failure  []int
+	Finished []int
+}
+
+// backupAgents are the backup products that are detected, by the events their
+// jobs leave behind.
+var backupAgents = []backupAgent{
+	{
+		Name:      "Windows Server Backup",
+		Log:       "Microsoft-Windows-Backup",
+		Providers: []string{"Microsoft-Windows-Backup"},
+		Success:   []int{4},
+		Failure:   []int{5, 8, 9, 17, 22, 49, 50, 52, 100, 517, 518, 521, 527, 528, 544, 545, 546, 561, 564, 612},
+	},
+	{
+		// "Veeam Agent 'Job' finished with Success/Warning/Failed."
+		Name:     "Veeam Agent",
+		Log:      "Veeam Agent",
+		Finished: []int{190},
+	},
+	{
+		// Macrium logs finished jobs at information level and failed ones as errors
+		Name:      "Macrium Reflect",
+		Log:       "Application",
+		Providers: []string{"MacriumService", "Macrium Reflect"},
+	},
+}
+
+// BackupJob is the last finished job of a backup product.
+type BackupJob struct {
+	Agent   string
+	Time    time.Time
+	OK      bool
+	Warning bool
+}
+
+// BackupInfo holds the last job of every backup product found.
+type BackupInfo struct {
+	Jobs   []BackupJob
+	MaxAge time.Duration
+}
+
+// GatherBackup finds the last finished job of each known backup product. It
+// returns nil when none of them has logged a job.
+func GatherBackup(ctx context.Context, maxAge time.Duration) (*BackupInfo, error) {
+	if maxAge <= 0 {
+		maxAge = DefaultBackupMaxAge
+	}
+
+	info := &BackupInfo{MaxAge: maxAge}
+	for _, agent := range backupAgents {
+		if ctx.Err() != nil {
+			return info, ctx.Err()
+		}
+		job, ok := lastBackupJob(ctx, agent)
+		if ok {
+			info.Jobs = append(info.Jobs, job)
+		}
+	}
+	if len(info.Jobs) == 0 {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// lastBackupJob reads the newest finished job of an agent. A missing log means
+// the agent is not installed.
+func lastBackupJob(ctx context.Context, agent backupAgent) (BackupJob, bool) {
+	ids := append(append(append([]int{}, agent.Success...), agent.Failure...), agent.Finished...)
+	events, err := queryEvents(ctx, agent.Log, eventIDQuery(agent.Providers, ids), 1)
+	if err != nil || len(events) == 0 {
+		return BackupJob{}, false
+	}
+
+	e := events[0]
+	job := BackupJob{Agent: agent.Name, Time: e.Time()}
+	switch {
+	case containsInt(agent.Success, e.System.EventID):
+		job.OK = true
+	case containsInt(agent.Failure, e.System.EventID):
+		job.OK = false
+	case containsInt(agent.Finished, e.System.EventID):
+		message := strings.ToLower(e.RenderingInfo.Message)
+		job.OK = !strings.Contains(message, "failed")
+		job.Warning = strings.Contains(message, "warning")
+	default:
+		job.OK = e.System.Level != eventLevelError && e.System.Level != eventLevelCritical
+		job.Warning = e.System.Level == eventLevelWarning
+	}
+	return job, true
+}
+
+// containsInt reports whether v is in list.
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Problems lists the products whose last job failed or is overdue.
+func (b *BackupInfo) Problems() []string {
+	var problems []string
+	for _, job := range b.Jobs {
+		if !job.OK {
+			problems = append(problems, job.Agent+" failed")
+		} else if time.Since(job.Time) > b.MaxAge {
+			problems = append(problems, job.Agent+" overdue")
+		}
+	}
+	return problems
+}
+
+// FormatBackupLines returns the last job of each product as lines for display,
+// e.g. "Veeam Agent: OK, 6h ago" or "Windows Server Backup: FAILED, 3 days ago".
+func (b *BackupInfo) FormatBackupLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "Backup")
+	lines = append(lines, "")
+
+	now := time.Now()
+	for _, job := range b.Jobs {
+		status := "OK"
+		switch {
+		case !job.OK:
+			status = "FAILED"
+		case now.Sub(job.Time) > b.MaxAge:
+			status = "OVERDUE"
+		case job.Warning:
+			status = "Warning"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s, %s", job.Agent, status, formatAge(now.Sub(job.Time))))
+	}
+	return lines
+}
+
+// formatAge formats how long ago something happened, e.g. "45m ago", "6h ago"
+// or "3 days ago".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+}
@@ -0,0 +1,190 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// bitLockerManagementLog is the event log BitLocker writes key backups to.
+const bitLockerManagementLog = "Microsoft-Windows-BitLocker/BitLocker Management"
+
+// bitLockerAzureBackupEvent is logged when a recovery password was backed up
+// to Azure AD (Entra ID), by Intune or BackupToAAD-BitLockerKeyProtector.
+const bitLockerAzureBackupEvent = 845
+
+// maxBitLockerEvents bounds how many backup events are read.
+const maxBitLockerEvents = 50
+
+// bitLockerScript prints the encrypted volumes with the IDs of their recovery
+// password protectors and, on domain-joined machines, the recovery password
+// IDs stored below the computer object in AD (msFVE-RecoveryInformation; the
+// passwords themselves are not readable and not read).
+const bitLockerScript = `$ErrorActionPreference = 'Stop'
+$volumes = @(Get-BitLockerVolume | Where-Object { $_.VolumeStatus -ne 'FullyDecrypted' } | ForEach-Object {
+  [pscustomobject]@{
+    MountPoint = $_.MountPoint
+    Protection = $_.ProtectionStatus.ToString()
+    RecoveryIds = @($_.KeyProtector | Where-Object { $_.KeyProtectorType -eq 'RecoveryPassword' } | ForEach-Object { $_.KeyProtectorId })
+  }
+})
+$ad = $null
+if ((Get-CimInstance Win32_ComputerSystem).PartOfDomain) {
+  try {
+    $computer = ([adsisearcher]"(&(objectCategory=computer)(sAMAccountName=$env:COMPUTERNAME$))").FindOne()
+    $searcher = New-Object DirectoryServices.DirectorySearcher($computer.GetDirectoryEntry(), '(objectClass=msFVE-RecoveryInformation)', @('msFVE-RecoveryGuid'))
+    $ad = @($searcher.FindAll() | ForEach-Object { ([guid][byte[]]$_.Properties['msfve-recoveryguid'][0]).ToString('B') })
+  } catch { $ad = $null }
+}
+ConvertTo-Json -Compress -Depth 3 -InputObject ([pscustomobject]@{ Volumes = $volumes; AD = $ad })`
+
+// bitLockerOutput is the JSON printed by bitLockerScript.
+type bitLockerOutput struct {
+	Volumes []struct {
+		MountPoint  string
+		Protection  string
+		RecoveryIds []string
+	}
+	// AD is null when the machine is not domain-joined or the directory
+	// could not be queried.
+	AD []string
+}
+
+// BitLockerVolume is the recovery key escrow state of an encrypted volume.
+type BitLockerVolume struct {
+	MountPoint string
+	// Protected is false while protection is suspended or off.
+	Protected bool
+	// HasRecoveryPassword is false when the volume has no recovery password
+	// protector, so there is nothing to escrow.
+	HasRecoveryPassword bool
+	EscrowedAD          bool
+	EscrowedAzureAD     bool
+}
+
+// Escrowed reports whether a recovery password is stored anywhere.
+func (v BitLockerVolume) Escrowed() bool {
+	return v.EscrowedAD || v.EscrowedAzureAD
+}
+
+// BitLockerInfo is the escrow state of the encrypted volumes.
+type BitLockerInfo struct {
+	Volumes []BitLockerVolume
+}
+
+// GatherBitLocker reads the encrypted volumes and whether a recovery password
+// of each is escrowed: to AD when its ID is found below the computer object,
+// to Azure AD when BitLocker logged a successful backup (event 845) naming
+// the protector or, when the event names none, the volume.
+func GatherBitLocker(ctx context.Context) (*BitLockerInfo, error) {
+	output, err := winapi.Commands.Run(ctx, "powershell.exe",
+		"-NoProfile",
+		"-ExecutionPolicy", "Bypass",
+		"-Command", bitLockerScript,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BitLocker volumes: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	var out bitLockerOutput
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse BitLocker volumes: %v", err)
+	}
+
+	info := &BitLockerInfo{}
+	adIDs := make(map[string]bool)
+	for _, id := range out.AD {
+		adIDs[strings.ToUpper(id)] = true
+	}
+
+	var problems []string
+	backups, err := queryEvents(ctx, bitLockerManagementLog, eventIDQuery(nil, []int{bitLockerAzureBackupEvent}), maxBitLockerEvents)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for _, v := range out.Volumes {
+		volume := BitLockerVolume{
+			MountPoint:          v.MountPoint,
+			Protected:           v.Protection == "On",
+			HasRecoveryPassword: len(v.RecoveryIds) > 0,
+		}
+		for _, id := range v.RecoveryIds {
+			id = strings.ToUpper(id)
+			if adIDs[id] {
+				volume.EscrowedAD = true
+			}
+			for _, e := range backups {
+				if azureBackupCovers(e.RenderingInfo.Message, id, v.MountPoint) {
+					volume.EscrowedAzureAD = true
+				}
+			}
+		}
+		info.Volumes = append(info.Volumes, volume)
+	}
+
+	if len(problems) > 0 {
+		return info, errors.New(strings.Join(problems, "; "))
+	}
+	return info, nil
+}
+
+// azureBackupCovers reports whether a backup event's message is about the
+// protector, or about the volume when it names no protector.
+func azureBackupCovers(message, protectorID, mountPoint string) bool {
+	message = strings.ToUpper(message)
+	if strings.Contains(message, protectorID) {
+		return true
+	}
+	return !strings.Contains(message, "{") && strings.Contains(message, "VOLUME "+strings.ToUpper(mountPoint))
+}
+
+// FormatBitLockerLines returns the escrow state of each encrypted volume as
+// lines for display, e.g. "C: escrowed to AD, Azure AD" or "D: NOT escrowed".
+func (b *BitLockerInfo) FormatBitLockerLines() []string {
+	lines := []string{}
+	lines = append(lines, "BitLocker Recovery Keys")
+	lines = append(lines, "")
+
+	if len(b.Volumes) == 0 {
+		lines = append(lines, "No encrypted volumes")
+		return lines
+	}
+	for _, v := range b.Volumes {
+		var status string
+		switch {
+		case !v.HasRecoveryPassword:
+			status = "NOT escrowed (no recovery password)"
+		case v.Escrowed():
+			var where []string
+			if v.EscrowedAD {
+				where = append(where, "AD")
+			}
+			if v.EscrowedAzureAD {
+				where = append(where, "Azure AD")
+			}
+			status = "escrowed to " + strings.Join(where, ", ")
+		default:
+			status = "NOT escrowed"
+		}
+		if !v.Protected {
+			status += ", protection off"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", v.MountPoint, status))
+	}
+	return lines
+}
+
+// Problems lists the volumes whose recovery key is not escrowed.
+func (b *BitLockerInfo) Problems() []string {
+	var problems []string
+	for _, v := range b.Volumes {
+		if !v.Escrowed() {
+			problems = append(problems, v.MountPoint+" recovery key not escrowed")
+		}
+	}
+	return problems
+}
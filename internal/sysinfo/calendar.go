@@ -0,0 +1,537 @@
+package sysinfo
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar defaults used when the config leaves them unset.
+const (
+	DefaultCalendarEvents    = 5
+	DefaultCalendarDaysAhead = 7
+)
+
+// calendarFetchTimeout bounds a single ICS download so an unreachable feed
+// doesn't delay the login screen update.
+const calendarFetchTimeout = 15 * time.Second
+
+// maxRecurrences caps how many occurrences are expanded per recurring event.
+const maxRecurrences = 1000
+
+// CalendarEvent is a single upcoming event from an ICS feed.
+type CalendarEvent struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+}
+
+// CalendarInfo contains the upcoming events merged from all configured feeds.
+type CalendarInfo struct {
+	Events      []CalendarEvent
+	FailedFeeds int
+	TotalFeeds  int
+}
+
+// GatherCalendar downloads the ICS feeds and returns the next maxEvents events
+// starting within daysAhead days. Each successfully downloaded feed is cached
+// in cacheDir so the last known events are still shown when the network is down.
+func GatherCalendar(ctx context.Context, feeds []string, maxEvents, daysAhead int, cacheDir string) (*CalendarInfo, error) {
+	if maxEvents <= 0 {
+		maxEvents = DefaultCalendarEvents
+	}
+	if daysAhead <= 0 {
+		daysAhead = DefaultCalendarDaysAhead
+	}
+
+	info := &CalendarInfo{TotalFeeds: len(feeds)}
+	now := time.Now()
+	horizon := now.AddDate(0, 0, daysAhead)
+
+	var lastErr error
+	for _, feed := range feeds {
+		if err := ctx.Err(); err != nil {
+			return info, err
+		}
+		data, err := fetchICS(ctx, feed, cacheDir)
+		if err != nil {
+			info.FailedFeeds++
+			lastErr = err
+			continue
+		}
+
+		info.Events = append(info.Events, parseICS(data, now, horizon)...)
+	}
+
+	sort.Slice(info.Events, func(i, j int) bool {
+		return info.Events[i].Start.Before(info.Events[j].Start)
+	})
+	if len(info.Events) > maxEvents {
+		info.Events = info.Events[:maxEvents]
+	}
+
+	if info.FailedFeeds == info.TotalFeeds && lastErr != nil {
+		return info, fmt.Errorf("failed to load calendar: %v", lastErr)
+	}
+	return info, nil
+}
+
+// fetchICS downloads a feed, falling back to the cached copy on failure.
+func fetchICS(ctx context.Context, feed, cacheDir string) ([]byte, error) {
+	// webcal:// is just HTTP(S) with a calendar-app hint
+	feedURL := feed
+	if strings.HasPrefix(strings.ToLower(feedURL), "webcal://") {
+		feedURL = "https://" + feedURL[len("webcal://"):]
+	}
+
+	sum := sha1.Sum([]byte(feed))
+	cachePath := filepath.Join(cacheDir, "calendar_"+hex.EncodeToString(sum[:8])+".ics")
+
+	client := &http.Client{Timeout: calendarFetchTimeout}
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err == nil {
+		resp, err = client.Do(req)
+	}
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			data, readErr := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+			if readErr == nil && strings.Contains(string(data), "BEGIN:VCALENDAR") {
+				if cacheDir != "" {
+					os.MkdirAll(cacheDir, 0755)
+					os.WriteFile(cachePath, data, 0644)
+				}
+				return data, nil
+			}
+			err = fmt.Errorf("response is not an iCalendar file")
+		} else {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+	}
+
+	cached, cacheErr := os.ReadFile(cachePath)
+	if cacheErr == nil {
+		return cached, nil
+	}
+	return nil, err
+}
+
+// icsProperty is a single unfolded content line, e.g. DTSTART;TZID=Europe/Paris:20240102T090000.
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// parseICSLine splits a content line into name, parameters and value.
+func parseICSLine(line string) icsProperty {
+	prop := icsProperty{Params: map[string]string{}}
+
+	// The value starts at the first colon that isn't inside a quoted parameter
+	inQuote := false
+	split := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ':' && !inQuote {
+			split = i
+			break
+		}
+	}
+	if split < 0 {
+		prop.Name = strings.ToUpper(line)
+		return prop
+	}
+
+	prop.Value = line[split+1:]
+	parts := strings.Split(line[:split], ";")
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			prop.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return prop
+}
+
+// unfoldICS joins folded lines (continuations start with a space or tab).
+func unfoldICS(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// unescapeICSText reverses iCalendar TEXT escaping.
+func unescapeICSText(s string) string {
+	r := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return strings.TrimSpace(r.Replace(s))
+}
+
+// parseICSTime parses a DATE or DATE-TIME value. Floating times and TZIDs that
+// can't be resolved (e.g. Windows zone names) are treated as local time.
+func parseICSTime(prop icsProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.Value)
+
+	if prop.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t.Local(), false, err
+	}
+
+	loc := time.Local
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t.Local(), false, err
+}
+
+// icsEvent accumulates the properties of a VEVENT while parsing.
+type icsEvent struct {
+	CalendarEvent
+	UID          string
+	RecurrenceID time.Time
+	RRule        string
+	ExDates      map[int64]bool
+	Status       string
+}
+
+// parseICS extracts the events that overlap [from, to], expanding simple recurrences.
+func parseICS(data []byte, from, to time.Time) []CalendarEvent {
+	var parsed []*icsEvent
+	var current *icsEvent
+	depth := 0
+
+	for _, line := range unfoldICS(data) {
+		prop := parseICSLine(line)
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VEVENT"):
+			current = &icsEvent{ExDates: map[int64]bool{}}
+			depth = 0
+			continue
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VEVENT"):
+			if current != nil && !current.Start.IsZero() {
+				parsed = append(parsed, current)
+			}
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		// Skip properties of nested components such as VALARM
+		if prop.Name == "BEGIN" {
+			depth++
+			continue
+		}
+		if prop.Name == "END" {
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+
+		switch prop.Name {
+		case "SUMMARY":
+			current.Summary = unescapeICSText(prop.Value)
+		case "LOCATION":
+			current.Location = unescapeICSText(prop.Value)
+		case "STATUS":
+			current.Status = prop.Value
+		case "UID":
+			current.UID = prop.Value
+		case "RRULE":
+			current.RRule = prop.Value
+		case "RECURRENCE-ID":
+			if t, _, err := parseICSTime(prop); err == nil {
+				current.RecurrenceID = t
+			}
+		case "DTSTART":
+			if t, allDay, err := parseICSTime(prop); err == nil {
+				current.Start = t
+				current.AllDay = allDay
+			}
+		case "DTEND":
+			if t, _, err := parseICSTime(prop); err == nil {
+				current.End = t
+			}
+		case "EXDATE":
+			for _, v := range strings.Split(prop.Value, ",") {
+				p := prop
+				p.Value = v
+				if t, _, err := parseICSTime(p); err == nil {
+					current.ExDates[t.Unix()] = true
+				}
+			}
+		}
+	}
+
+	// Moved or cancelled instances of a series are separate VEVENTs with a
+	// RECURRENCE-ID; they replace the original occurrence
+	for _, ev := range parsed {
+		if ev.RecurrenceID.IsZero() {
+			continue
+		}
+		for _, series := range parsed {
+			if series.UID == ev.UID && series.RRule != "" {
+				series.ExDates[ev.RecurrenceID.Unix()] = true
+			}
+		}
+	}
+
+	var events []CalendarEvent
+	for _, ev := range parsed {
+		if strings.EqualFold(ev.Status, "CANCELLED") {
+			continue
+		}
+		events = append(events, expandEvent(ev, from, to)...)
+	}
+	return events
+}
+
+// expandEvent returns the occurrences of an event that overlap [from, to].
+// Supports RRULE FREQ (DAILY, WEEKLY, MONTHLY, YEARLY) with INTERVAL, COUNT,
+// UNTIL and BYDAY for weekly rules, which covers typical meeting series.
+func expandEvent(ev *icsEvent, from, to time.Time) []CalendarEvent {
+	duration := ev.End.Sub(ev.Start)
+	if ev.End.IsZero() || duration < 0 {
+		duration = 0
+		if ev.AllDay {
+			duration = 24 * time.Hour
+		}
+	}
+
+	occurs := func(start time.Time) bool {
+		end := start.Add(duration)
+		return !ev.ExDates[start.Unix()] && end.After(from) && start.Before(to)
+	}
+	occurrence := func(start time.Time) CalendarEvent {
+		e := ev.CalendarEvent
+		e.Start = start
+		e.End = start.Add(duration)
+		return e
+	}
+
+	if ev.RRule == "" {
+		if occurs(ev.Start) {
+			return []CalendarEvent{occurrence(ev.Start)}
+		}
+		return nil
+	}
+
+	rule := map[string]string{}
+	for _, part := range strings.Split(ev.RRule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			rule[strings.ToUpper(kv[0])] = strings.ToUpper(kv[1])
+		}
+	}
+
+	interval, _ := strconv.Atoi(rule["INTERVAL"])
+	if interval <= 0 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(rule["COUNT"])
+	until := to
+	if v := rule["UNTIL"]; v != "" {
+		if t, _, err := parseICSTime(icsProperty{Value: v, Params: map[string]string{}}); err == nil && t.Before(until) {
+			// UNTIL is inclusive; a date-only UNTIL covers the whole day
+			until = t
+			if len(v) == 8 {
+				until = t.AddDate(0, 0, 1)
+			}
+		}
+	}
+
+	// Weekly rules may list several days; otherwise the series repeats on DTSTART's day
+	var weekdays []time.Weekday
+	if rule["FREQ"] == "WEEKLY" && rule["BYDAY"] != "" {
+		days := map[string]time.Weekday{"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday,
+			"WE": time.Wednesday, "TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday}
+		for _, d := range strings.Split(rule["BYDAY"], ",") {
+			if wd, ok := days[d]; ok {
+				weekdays = append(weekdays, wd)
+			}
+		}
+	}
+
+	var result []CalendarEvent
+	generated := 0
+	emit := func(start time.Time) bool {
+		if start.Before(ev.Start) {
+			return true
+		}
+		if start.After(until) || (count > 0 && generated >= count) {
+			return false
+		}
+		generated++
+		if occurs(start) {
+			result = append(result, occurrence(start))
+		}
+		return true
+	}
+
+	// Without COUNT, earlier occurrences don't matter, so jump close to the window
+	// instead of walking a long-running series from its first occurrence
+	first := 0
+	if count == 0 && from.After(ev.Start) {
+		elapsed := from.Sub(ev.Start)
+		switch rule["FREQ"] {
+		case "DAILY":
+			first = int(elapsed/(24*time.Hour))/interval - 1
+		case "WEEKLY":
+			first = int(elapsed/(7*24*time.Hour))/interval - 1
+		case "MONTHLY":
+			first = int(elapsed/(28*24*time.Hour))/interval - 2
+		}
+		if first < 0 {
+			first = 0
+		}
+	}
+
+	for i := first; i < first+maxRecurrences; i++ {
+		var base time.Time
+		switch rule["FREQ"] {
+		case "DAILY":
+			base = ev.Start.AddDate(0, 0, i*interval)
+		case "WEEKLY":
+			base = ev.Start.AddDate(0, 0, 7*i*interval)
+		case "MONTHLY":
+			base = ev.Start.AddDate(0, i*interval, 0)
+		case "YEARLY":
+			base = ev.Start.AddDate(i*interval, 0, 0)
+		default:
+			// Unsupported frequency - show the first occurrence only
+			if occurs(ev.Start) {
+				return []CalendarEvent{occurrence(ev.Start)}
+			}
+			return nil
+		}
+
+		if len(weekdays) == 0 {
+			if !emit(base) {
+				break
+			}
+			continue
+		}
+
+		// Expand BYDAY within the week that contains base (weeks start on Monday)
+		offset := (int(base.Weekday()) + 6) % 7
+		weekStart := base.AddDate(0, 0, -offset)
+		sort.Slice(weekdays, func(a, b int) bool {
+			return (int(weekdays[a])+6)%7 < (int(weekdays[b])+6)%7
+		})
+		more := true
+		for _, wd := range weekdays {
+			if !emit(weekStart.AddDate(0, 0, (int(wd)+6)%7)) {
+				more = false
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+
+	return result
+}
+
+// FormatCalendarLines returns the upcoming events as a slice of strings for display.
+func (c *CalendarInfo) FormatCalendarLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "Upcoming Events")
+	lines = append(lines, "")
+
+	if len(c.Events) == 0 {
+		if c.TotalFeeds > 0 && c.FailedFeeds == c.TotalFeeds {
+			lines = append(lines, "Calendar unavailable")
+		} else {
+			lines = append(lines, "No upcoming events")
+		}
+		return lines
+	}
+
+	now := time.Now()
+	for _, event := range c.Events {
+		when := formatEventTime(event, now)
+		title := event.Summary
+		if title == "" {
+			title = "(busy)"
+		}
+		if event.Location != "" {
+			title = fmt.Sprintf("%s (%s)", title, event.Location)
+		}
+		// Keep long titles from stretching the panel across the screen
+		if len(title) > 40 {
+			title = title[:37] + "..."
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s", when, title))
+	}
+
+	if c.FailedFeeds > 0 {
+		lines = append(lines, "")
+		lines = append(lines, fmt.Sprintf("%d of %d calendars unavailable", c.FailedFeeds, c.TotalFeeds))
+	}
+
+	return lines
+}
+
+// formatEventTime formats an event start relative to today, e.g. "Now", "Today 2:00 PM" or "Tue 9:30 AM".
+func formatEventTime(event CalendarEvent, now time.Time) string {
+	if !event.Start.After(now) && !event.AllDay {
+		return "Now"
+	}
+
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	days := int(event.Start.Sub(today).Hours() / 24)
+
+	var dayLabel string
+	switch {
+	case days <= 0:
+		dayLabel = "Today"
+	case days == 1:
+		dayLabel = "Tomorrow"
+	default:
+		dayLabel = event.Start.Format("Mon Jan 2")
+	}
+
+	if event.AllDay {
+		return dayLabel + " (all day)"
+	}
+	return dayLabel + " " + event.Start.Format("3:04 PM")
+}
@@ -0,0 +1,37 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// certificateDaysScript prints the whole days until the soonest expiring
+// machine certificate with a private key expires (negative once expired).
+const certificateDaysScript = `$c = Get-ChildItem Cert:\LocalMachine\My | Where-Object { $_.HasPrivateKey } | Sort-Object NotAfter | Select-Object -First 1
+if ($c) { [math]::Floor(($c.NotAfter - (Get-Date)).TotalDays) }`
+
+// CertificateDaysLeft returns the days until the first machine certificate
+// with a private key (LocalMachine\My) expires.
+func CertificateDaysLeft(ctx context.Context) (float64, error) {
+	output, err := winapi.Commands.Run(ctx, "powershell.exe",
+		"-NoProfile",
+		"-ExecutionPolicy", "Bypass",
+		"-Command", certificateDaysScript,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read machine certificates: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	text := strings.TrimSpace(string(output))
+	if text == "" {
+		return 0, fmt.Errorf("no machine certificates with a private key")
+	}
+	days, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse certificate expiry %q: %v", text, err)
+	}
+	return days, nil
+}
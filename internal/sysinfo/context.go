@@ -0,0 +1,48 @@
+package sysinfo
+
+import (
+	"context"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// queryWMI runs a WMI query but gives up when ctx is done. WMI has no way to
+// cancel a query, so a hung query keeps running in the background; the caller
+// stops waiting for it, which is what lets a service stop request through.
+func queryWMI(ctx context.Context, query string, dst interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- winapi.WMI.Query(query, dst)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withContext runs a blocking call, such as a network API, but gives up when
+// ctx is done, the same way as queryWMI.
+func withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
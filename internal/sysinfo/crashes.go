@@ -0,0 +1,126 @@
+package sysinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// crashWindow is how far back crashes are counted.
+const crashWindow = 7 * 24 * time.Hour
+
+// maxCrashEvents bounds how many events of each kind are read.
+const maxCrashEvents = 50
+
+// bugcheckCode finds the stop code in "The computer has rebooted from a
+// bugcheck. The bugcheck was: 0x00000133 (...)".
+var bugcheckCode = regexp.MustCompile(`0x[0-9A-Fa-f]+`)
+
+// CrashInfo counts the display driver timeouts and bluescreens of the last week.
+type CrashInfo struct {
+	// TDRs are display driver timeouts the driver recovered from (Display 4101).
+	TDRs int
+	// BSODs are bugchecks (WER-SystemErrorReporting 1001).
+	BSODs int
+	// BugcheckCodes are the distinct stop codes, newest first, e.g. "0x133".
+	BugcheckCodes []string
+	LastCrash     time.Time
+}
+
+// GatherCrashes counts the display driver timeouts and bluescreens logged in
+// the last week.
+func GatherCrashes(ctx context.Context) (*CrashInfo, error) {
+	info := &CrashInfo{}
+
+	var problems []string
+	tdrs, err := queryEvents(ctx, "System", eventIDQuery([]string{"Display"}, []int{4101}), maxCrashEvents)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, e := range tdrs {
+		if time.Since(e.Time()) <= crashWindow {
+			info.TDRs++
+			info.noteCrash(e.Time())
+		}
+	}
+
+	bugchecks, err := queryEvents(ctx, "System", eventIDQuery([]string{"Microsoft-Windows-WER-SystemErrorReporting"}, []int{1001}), maxCrashEvents)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	seen := make(map[string]bool)
+	for _, e := range bugchecks {
+		if time.Since(e.Time()) > crashWindow {
+			continue
+		}
+		info.BSODs++
+		info.noteCrash(e.Time())
+		if code := shortBugcheckCode(bugcheckCode.FindString(e.RenderingInfo.Message)); code != "" && !seen[code] {
+			seen[code] = true
+			info.BugcheckCodes = append(info.BugcheckCodes, code)
+		}
+	}
+
+	if len(problems) > 0 {
+		return info, errors.New(strings.Join(problems, "; "))
+	}
+	return info, nil
+}
+
+// noteCrash keeps the time of the newest crash.
+func (c *CrashInfo) noteCrash(t time.Time) {
+	if t.After(c.LastCrash) {
+		c.LastCrash = t
+	}
+}
+
+// shortBugcheckCode drops the leading zeros of a stop code: 0x00000133 -> 0x133.
+func shortBugcheckCode(code string) string {
+	if code == "" {
+		return ""
+	}
+	v, err := strconv.ParseUint(code[2:], 16, 64)
+	if err != nil {
+		return code
+	}
+	return fmt.Sprintf("0x%X", v)
+}
+
+// FormatCrashLines returns the crash counts as lines for display, e.g.
+// "Crashes: 2 BSODs this week (0x133)".
+func (c *CrashInfo) FormatCrashLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "Stability")
+	lines = append(lines, "")
+
+	if c.BSODs == 0 && c.TDRs == 0 {
+		lines = append(lines, "Crashes: None this week")
+		return lines
+	}
+
+	if c.BSODs > 0 {
+		line := fmt.Sprintf("Crashes: %d %s this week", c.BSODs, plural(c.BSODs, "BSOD", "BSODs"))
+		if len(c.BugcheckCodes) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(c.BugcheckCodes, ", "))
+		}
+		lines = append(lines, line)
+	}
+	if c.TDRs > 0 {
+		lines = append(lines, fmt.Sprintf("GPU driver resets: %d this week", c.TDRs))
+	}
+	lines = append(lines, fmt.Sprintf("Last: %s", formatAge(time.Since(c.LastCrash))))
+	return lines
+}
+
+// plural picks the singular or plural form for n.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,152 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// DefaultDHCPThreshold is the scope utilization, in percent, above which a
+// scope is flagged.
+const DefaultDHCPThreshold = 90
+
+// ipConflictWindow is how far back address conflict events are shown.
+const ipConflictWindow = 7 * 24 * time.Hour
+
+// dhcpScopesScript prints the IPv4 scope statistics of the local DHCP server
+// as a JSON array.
+const dhcpScopesScript = `$ErrorActionPreference = 'Stop'
+$stats = Get-DhcpServerv4ScopeStatistics | Select-Object @{n='ScopeId';e={$_.ScopeId.ToString()}},Free,InUse,PercentageInUse
+ConvertTo-Json -Compress -InputObject @($stats)`
+
+// conflictAddress finds the address in the text of an address conflict event.
+var conflictAddress = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+
+// DHCPScope is the utilization of one IPv4 scope.
+type DHCPScope struct {
+	ScopeID         string  `json:"ScopeId"`
+	Free            int     `json:"Free"`
+	InUse           int     `json:"InUse"`
+	PercentageInUse float64 `json:"PercentageInUse"`
+}
+
+// IPConflict is an address conflict reported by TCP/IP.
+type IPConflict struct {
+	Time    time.Time
+	Address string
+}
+
+// DHCPInfo is the scope utilization of a DHCP server and the recent address
+// conflicts.
+type DHCPInfo struct {
+	// Server is false on machines without the DHCP Server role, which only
+	// report address conflicts.
+	Server    bool
+	Scopes    []DHCPScope
+	Conflicts []IPConflict
+	Threshold int
+}
+
+// GatherDHCP reads the scope statistics when the DHCP Server role is installed
+// and the address conflicts of the last week. It returns nil when there is
+// nothing to report.
+func GatherDHCP(ctx context.Context, threshold int) (*DHCPInfo, error) {
+	if threshold <= 0 || threshold > 100 {
+		threshold = DefaultDHCPThreshold
+	}
+	info := &DHCPInfo{Threshold: threshold}
+
+	var problems []string
+	var services []Win32_Service
+	if err := queryWMI(ctx, "SELECT Name, State, StartMode FROM Win32_Service WHERE Name = 'DHCPServer'", &services); err == nil && len(services) > 0 {
+		info.Server = true
+		output, err := winapi.Commands.Run(ctx, "powershell.exe",
+			"-NoProfile",
+			"-ExecutionPolicy", "Bypass",
+			"-Command", dhcpScopesScript,
+		)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to read scope statistics: %v: %s", err, strings.TrimSpace(string(output))))
+		} else if err := json.Unmarshal(output, &info.Scopes); err != nil {
+			problems = append(problems, fmt.Sprintf("failed to parse scope statistics: %v", err))
+		}
+	}
+
+	events, err := queryEvents(ctx, "System", eventIDQuery([]string{"Tcpip"}, []int{4199}), 20)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, e := range events {
+		if time.Since(e.Time()) > ipConflictWindow {
+			continue
+		}
+		info.Conflicts = append(info.Conflicts, IPConflict{
+			Time:    e.Time(),
+			Address: conflictAddress.FindString(e.RenderingInfo.Message),
+		})
+	}
+
+	var result error
+	if len(problems) > 0 {
+		result = errors.New(strings.Join(problems, "; "))
+	}
+	if !info.Server && len(info.Conflicts) == 0 {
+		return nil, result
+	}
+	return info, result
+}
+
+// Problems lists the scopes above the threshold and the address conflicts.
+func (d *DHCPInfo) Problems() []string {
+	var problems []string
+	for _, scope := range d.Scopes {
+		if scope.PercentageInUse >= float64(d.Threshold) {
+			problems = append(problems, fmt.Sprintf("Scope %s %.0f%% full", scope.ScopeID, scope.PercentageInUse))
+		}
+	}
+	if len(d.Conflicts) > 0 {
+		problems = append(problems, fmt.Sprintf("%d IP conflicts this week", len(d.Conflicts)))
+	}
+	return problems
+}
+
+// FormatDHCPLines returns the scope utilization and conflicts as lines for
+// display. Only the scopes above the threshold are listed by name.
+func (d *DHCPInfo) FormatDHCPLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "DHCP")
+	lines = append(lines, "")
+
+	if d.Server {
+		full := 0
+		for _, scope := range d.Scopes {
+			if scope.PercentageInUse >= float64(d.Threshold) {
+				full++
+				lines = append(lines, fmt.Sprintf("  %s: %.0f%% used, %d free", scope.ScopeID, scope.PercentageInUse, scope.Free))
+			}
+		}
+		if full == 0 {
+			lines = append(lines, fmt.Sprintf("Scopes: %d, all below %d%%", len(d.Scopes), d.Threshold))
+		} else {
+			lines = append(lines, fmt.Sprintf("%d of %d scopes above %d%%", full, len(d.Scopes), d.Threshold))
+		}
+	}
+
+	if len(d.Conflicts) > 0 {
+		last := d.Conflicts[0]
+		address := last.Address
+		if address == "" {
+			address = "an address"
+		}
+		lines = append(lines, fmt.Sprintf("IP conflicts (7 days): %d", len(d.Conflicts)))
+		lines = append(lines, fmt.Sprintf("  Last: %s, %s", address, formatAge(time.Since(last.Time))))
+	}
+	return lines
+}
@@ -0,0 +1,200 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DiskHistoryFileName is the file in the data directory holding the free
+// space samples.
+const DiskHistoryFileName = "disk_history.json"
+
+// DefaultDiskFullDays is how soon a volume must be projected to fill up before
+// it is flagged.
+const DefaultDiskFullDays = 14
+
+// Disk trend sampling: at most one sample per volume an hour, kept for 30
+// days; the trend is fitted to the last week once it spans a day.
+const (
+	diskSampleInterval = time.Hour
+	diskHistoryWindow  = 30 * 24 * time.Hour
+	diskTrendWindow    = 7 * 24 * time.Hour
+	diskTrendMinSpan   = 24 * time.Hour
+)
+
+// DiskSample is the free space of a volume at a point in time.
+type DiskSample struct {
+	Time time.Time `json:"t"`
+	Free uint64    `json:"free"`
+}
+
+// DiskHistory holds the free space samples of every volume, keyed by mount
+// point such as "C:".
+type DiskHistory struct {
+	Volumes map[string][]DiskSample `json:"volumes"`
+}
+
+// DiskForecast is a volume projected to fill up.
+type DiskForecast struct {
+	Volume   string
+	Free     uint64
+	DaysLeft float64
+}
+
+// DiskTrendInfo lists the volumes projected to fill up within the threshold.
+type DiskTrendInfo struct {
+	Forecasts []DiskForecast
+}
+
+// GatherDiskTrend records the free space of the local volumes in the history
+// in dir and returns the volumes that will be full within fullDays at the
+// rate seen over the last week.
+func GatherDiskTrend(ctx context.Context, dir string, fullDays int) (*DiskTrendInfo, error) {
+	if fullDays <= 0 {
+		fullDays = DefaultDiskFullDays
+	}
+
+	h := &DiskHistory{}
+	if data, err := os.ReadFile(filepath.Join(dir, DiskHistoryFileName)); err == nil {
+		json.Unmarshal(data, h)
+	}
+	if h.Volumes == nil {
+		h.Volumes = make(map[string][]DiskSample)
+	}
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %v", err)
+	}
+	now := time.Now()
+	free := make(map[string]uint64)
+	for _, partition := range partitions {
+		if partition.Fstype == "" {
+			continue
+		}
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+		if err != nil {
+			continue
+		}
+		volume := strings.TrimSuffix(partition.Mountpoint, `\`)
+		free[volume] = usage.Free
+		h.add(volume, DiskSample{Time: now, Free: usage.Free})
+	}
+
+	var saveErr error
+	if data, err := json.Marshal(h); err == nil {
+		os.MkdirAll(dir, 0755)
+		path := filepath.Join(dir, DiskHistoryFileName)
+		if err := os.WriteFile(path+".tmp", data, 0644); err == nil {
+			saveErr = os.Rename(path+".tmp", path)
+		} else {
+			saveErr = err
+		}
+	}
+
+	info := &DiskTrendInfo{}
+	for volume, current := range free {
+		days, ok := daysUntilFull(h.Volumes[volume], current, now)
+		if ok && days <= float64(fullDays) {
+			info.Forecasts = append(info.Forecasts, DiskForecast{Volume: volume, Free: current, DaysLeft: days})
+		}
+	}
+	sort.Slice(info.Forecasts, func(i, j int) bool { return info.Forecasts[i].DaysLeft < info.Forecasts[j].DaysLeft })
+
+	if saveErr != nil {
+		return info, fmt.Errorf("failed to save disk history: %v", saveErr)
+	}
+	return info, nil
+}
+
+// add appends a sample unless the volume was sampled within the last hour,
+// and drops samples that fell out of the window.
+func (h *DiskHistory) add(volume string, s DiskSample) {
+	samples := h.Volumes[volume]
+	if n := len(samples); n > 0 && s.Time.Sub(samples[n-1].Time) < diskSampleInterval {
+		return
+	}
+	samples = append(samples, s)
+
+	cutoff := s.Time.Add(-diskHistoryWindow)
+	start := 0
+	for start < len(samples) && samples[start].Time.Before(cutoff) {
+		start++
+	}
+	h.Volumes[volume] = samples[start:]
+}
+
+// daysUntilFull fits a line to the free space over the last week and returns
+// when it reaches zero. ok is false without a day of samples or when free
+// space isn't shrinking.
+func daysUntilFull(samples []DiskSample, current uint64, now time.Time) (float64, bool) {
+	var recent []DiskSample
+	for _, s := range samples {
+		if now.Sub(s.Time) <= diskTrendWindow {
+			recent = append(recent, s)
+		}
+	}
+	if len(recent) < 3 || recent[len(recent)-1].Time.Sub(recent[0].Time) < diskTrendMinSpan {
+		return 0, false
+	}
+
+	// Least squares slope of free bytes over days
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range recent {
+		x := s.Time.Sub(recent[0].Time).Hours() / 24
+		y := float64(s.Free)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(recent))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope >= 0 || math.IsNaN(slope) {
+		return 0, false
+	}
+	return float64(current) / -slope, true
+}
+
+// FormatDiskTrendLines returns the volumes filling up as lines for display,
+// e.g. "C: full in ~9 days at current rate". Empty when none are.
+func (d *DiskTrendInfo) FormatDiskTrendLines() []string {
+	if len(d.Forecasts) == 0 {
+		return nil
+	}
+
+	lines := []string{}
+	lines = append(lines, "Disk Space")
+	lines = append(lines, "")
+	for _, f := range d.Forecasts {
+		days := int(math.Round(f.DaysLeft))
+		if days < 1 {
+			lines = append(lines, fmt.Sprintf("%s full within a day (%s free)", f.Volume, formatSize(int64(f.Free))))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s full in ~%d %s at current rate", f.Volume, days, plural(days, "day", "days")))
+	}
+	return lines
+}
+
+// Problems lists the volumes filling up, for the event log.
+func (d *DiskTrendInfo) Problems() []string {
+	var problems []string
+	for _, f := range d.Forecasts {
+		problems = append(problems, fmt.Sprintf("%s full in ~%.0f days", f.Volume, f.DaysLeft))
+	}
+	return problems
+}
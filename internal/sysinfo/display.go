@@ -0,0 +1,73 @@
+package sysinfo
+
+import (
+	"math"
+	"syscall"
+	"unsafe"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+var (
+	procMonitorFromPoint             = syscall.NewLazyDLL("user32.dll").NewProc("MonitorFromPoint")
+	procSetThreadDpiAwarenessContext = syscall.NewLazyDLL("user32.dll").NewProc("SetThreadDpiAwarenessContext")
+	procGetDpiForMonitor             = syscall.NewLazyDLL("shcore.dll").NewProc("GetDpiForMonitor")
+)
+
+const (
+	monitorDefaultToPrimary = 1
+	mdtEffectiveDPI         = 0
+	// dpiAwarenessPerMonitorV2 is DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 (-4).
+	dpiAwarenessPerMonitorV2 = ^uintptr(3)
+)
+
+// primaryMonitorDPI returns the effective DPI of the primary monitor, which
+// reflects the display scaling chosen for it (144 at 150%). Returns 0 when it
+// cannot be read, e.g. on Windows 7 or without a console session.
+func primaryMonitorDPI() int {
+	if procGetDpiForMonitor.Find() != nil || procMonitorFromPoint.Find() != nil {
+		return 0
+	}
+
+	// Without per-monitor awareness Windows reports the system DPI for every monitor
+	if procSetThreadDpiAwarenessContext.Find() == nil {
+		previous, _, _ := procSetThreadDpiAwarenessContext.Call(dpiAwarenessPerMonitorV2)
+		if previous != 0 {
+			defer procSetThreadDpiAwarenessContext.Call(previous)
+		}
+	}
+
+	monitor, _, _ := procMonitorFromPoint.Call(0, monitorDefaultToPrimary)
+	if monitor == 0 {
+		return 0
+	}
+	var dpiX, dpiY uint32
+	hr, _, _ := procGetDpiForMonitor.Call(monitor, mdtEffectiveDPI,
+		uintptr(unsafe.Pointer(&dpiX)), uintptr(unsafe.Pointer(&dpiY)))
+	if hr != 0 {
+		return 0
+	}
+	return int(dpiX)
+}
+
+// primaryMonitorDiagonal returns the physical diagonal of the first active
+// monitor in inches from its EDID, or 0 when it reports no size (projectors,
+// many TVs and virtual displays).
+func primaryMonitorDiagonal() float64 {
+	var monitors []struct {
+		MaxHorizontalImageSize uint8
+		MaxVerticalImageSize   uint8
+	}
+	err := winapi.WMI.QueryNamespace("SELECT MaxHorizontalImageSize, MaxVerticalImageSize FROM WmiMonitorBasicDisplayParams WHERE Active = TRUE", &monitors, `root\wmi`)
+	if err != nil {
+		return 0
+	}
+	for _, m := range monitors {
+		if m.MaxHorizontalImageSize > 0 && m.MaxVerticalImageSize > 0 {
+			// EDID sizes are in centimeters
+			cm := math.Hypot(float64(m.MaxHorizontalImageSize), float64(m.MaxVerticalImageSize))
+			return cm / 2.54
+		}
+	}
+	return 0
+}
@@ -0,0 +1,257 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// DriverUpdatesFileName caches the last driver update scan in the data directory.
+const DriverUpdatesFileName = "driver_updates.json"
+
+// DriverScanInterval is how long a driver update scan is reused: a Dell
+// Command Update scan takes minutes and contacts Dell.
+const DriverScanInterval = 24 * time.Hour
+
+// driverScanTimeout bounds the vendor tool scan.
+const driverScanTimeout = 3 * time.Minute
+
+// maxDriverUpdateTitles is how many pending updates are listed by name.
+const maxDriverUpdateTitles = 4
+
+// driverUpdatesScript lists the driver updates Windows Update found on its
+// last scan (offline, from the agent's cache), with their driver class;
+// firmware updates have the class "Firmware".
+const driverUpdatesScript = `$searcher = (New-Object -ComObject Microsoft.Update.Session).CreateUpdateSearcher()
+$searcher.Online = $false
+$updates = @($searcher.Search("IsInstalled=0 and IsHidden=0 and Type='Driver'").Updates | ForEach-Object {
+  [pscustomobject]@{ Title = $_.Title; DriverClass = $_.DriverClass }
+})
+ConvertTo-Json -Compress -InputObject $updates`
+
+// Vendor update tools.
+var (
+	dellCommandUpdatePaths = []string{
+		`%ProgramFiles%\Dell\CommandUpdate\dcu-cli.exe`,
+		`%ProgramFiles(x86)%\Dell\CommandUpdate\dcu-cli.exe`,
+	}
+	lenovoTools = []struct{ Name, Path string }{
+		{"Lenovo System Update", `%ProgramFiles(x86)%\Lenovo\System Update\tvsu.exe`},
+		{"Lenovo Commercial Vantage", `%ProgramFiles(x86)%\Lenovo\VantageService`},
+		{"Lenovo Vantage", `%ProgramData%\Lenovo\Vantage`},
+	}
+)
+
+// DriverUpdate is one pending driver or firmware update.
+type DriverUpdate struct {
+	Title    string `json:"title"`
+	Firmware bool   `json:"firmware,omitempty"`
+}
+
+// DriverUpdatesInfo is what Windows Update and the vendor tool report as
+// pending driver and firmware updates.
+type DriverUpdatesInfo struct {
+	ScannedAt time.Time `json:"scanned_at"`
+	// WindowsUpdate are the driver updates from the last Windows Update scan.
+	WindowsUpdate []DriverUpdate `json:"windows_update"`
+	// WindowsUpdateError is set when Windows Update could not be asked.
+	WindowsUpdateError string `json:"windows_update_error,omitempty"`
+	// VendorTool is the installed vendor update tool, if any.
+	VendorTool string `json:"vendor_tool,omitempty"`
+	// VendorUpdates are the updates the vendor tool found; nil when the tool
+	// can't be scanned from here (Lenovo) or the scan failed.
+	VendorUpdates []DriverUpdate `json:"vendor_updates,omitempty"`
+	// VendorScanned is true when VendorUpdates is a scan result.
+	VendorScanned bool `json:"vendor_scanned,omitempty"`
+}
+
+// dellUpdatesReport is the DCUApplicableUpdates.xml written by a Dell
+// Command Update scan.
+type dellUpdatesReport struct {
+	Updates []struct {
+		Name string `xml:"name"`
+		Type string `xml:"type"`
+	} `xml:"update"`
+}
+
+// GatherDriverUpdates returns the pending driver and firmware updates from
+// Windows Update and, when installed, Dell Command Update; Lenovo's tools
+// are only detected. A scan younger than DriverScanInterval is read from
+// cacheDir.
+func GatherDriverUpdates(ctx context.Context, cacheDir string) (*DriverUpdatesInfo, error) {
+	cachePath := filepath.Join(cacheDir, DriverUpdatesFileName)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cached DriverUpdatesInfo
+		if json.Unmarshal(data, &cached) == nil && time.Since(cached.ScannedAt) < DriverScanInterval {
+			return &cached, nil
+		}
+	}
+
+	info := &DriverUpdatesInfo{ScannedAt: time.Now()}
+	updates, err := windowsDriverUpdates(ctx)
+	if err != nil {
+		info.WindowsUpdateError = err.Error()
+	}
+	info.WindowsUpdate = updates
+
+	if dcu := findExpanded(dellCommandUpdatePaths); dcu != "" {
+		info.VendorTool = "Dell Command Update"
+		scanCtx, cancel := context.WithTimeout(ctx, driverScanTimeout)
+		info.VendorUpdates, err = dellUpdates(scanCtx, dcu, filepath.Join(cacheDir, "dcu"))
+		cancel()
+		info.VendorScanned = err == nil
+	} else {
+		for _, tool := range lenovoTools {
+			if _, err := os.Stat(expandEnv(tool.Path)); err == nil {
+				info.VendorTool = tool.Name
+				break
+			}
+		}
+	}
+
+	if ctx.Err() == nil {
+		if data, err := json.Marshal(info); err == nil {
+			_ = os.WriteFile(cachePath, data, 0644)
+		}
+	}
+	if info.WindowsUpdateError != "" && !info.VendorScanned {
+		return info, fmt.Errorf("failed to read driver updates: %s", info.WindowsUpdateError)
+	}
+	return info, nil
+}
+
+// windowsDriverUpdates asks the Windows Update Agent for pending driver updates.
+func windowsDriverUpdates(ctx context.Context) ([]DriverUpdate, error) {
+	output, err := winapi.Commands.Run(ctx, "powershell.exe",
+		"-NoProfile",
+		"-ExecutionPolicy", "Bypass",
+		"-Command", driverUpdatesScript,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for driver updates: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	var found []struct {
+		Title       string
+		DriverClass string
+	}
+	if err := json.Unmarshal(output, &found); err != nil {
+		return nil, fmt.Errorf("failed to parse driver updates: %v", err)
+	}
+	updates := make([]DriverUpdate, 0, len(found))
+	for _, u := range found {
+		updates = append(updates, DriverUpdate{Title: u.Title, Firmware: strings.EqualFold(u.DriverClass, "Firmware")})
+	}
+	return updates, nil
+}
+
+// dellUpdates runs a Dell Command Update scan that writes its report to dir.
+func dellUpdates(ctx context.Context, dcu, dir string) ([]DriverUpdate, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	report := filepath.Join(dir, "DCUApplicableUpdates.xml")
+	os.Remove(report)
+	// dcu-cli exits with 500, and writes no report, when it finds no
+	// updates, so its exit code is not checked; a missing report only means
+	// failure when the scan was cut short
+	winapi.Commands.Run(ctx, dcu, "/scan", "-silent", "-report="+dir)
+	data, err := os.ReadFile(report)
+	if os.IsNotExist(err) && ctx.Err() == nil {
+		return []DriverUpdate{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Dell Command Update scan failed: %v", err)
+	}
+	var parsed dellUpdatesReport
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse Dell Command Update report: %v", err)
+	}
+	updates := []DriverUpdate{}
+	for _, u := range parsed.Updates {
+		kind := strings.ToLower(u.Type)
+		updates = append(updates, DriverUpdate{Title: u.Name, Firmware: kind == "bios" || kind == "firmware"})
+	}
+	return updates, nil
+}
+
+// findExpanded returns the first of paths (with environment variables) that exists.
+func findExpanded(paths []string) string {
+	for _, path := range paths {
+		path = expandEnv(path)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// FormatDriverUpdateLines returns the pending driver and firmware updates for
+// display, e.g. "Windows Update: 2 drivers, 1 firmware".
+func (d *DriverUpdatesInfo) FormatDriverUpdateLines() []string {
+	lines := []string{"Driver Updates", ""}
+
+	if d.WindowsUpdateError != "" {
+		lines = append(lines, "Windows Update: unknown")
+	} else {
+		lines = append(lines, "Windows Update: "+countUpdates(d.WindowsUpdate))
+		lines = append(lines, updateTitles(d.WindowsUpdate)...)
+	}
+
+	switch {
+	case d.VendorTool == "":
+	case d.VendorScanned:
+		lines = append(lines, d.VendorTool+": "+countUpdates(d.VendorUpdates))
+		lines = append(lines, updateTitles(d.VendorUpdates)...)
+	default:
+		lines = append(lines, d.VendorTool+": installed")
+	}
+	return lines
+}
+
+// countUpdates summarizes updates, e.g. "none pending" or "2 drivers, 1 firmware".
+func countUpdates(updates []DriverUpdate) string {
+	drivers, firmware := 0, 0
+	for _, u := range updates {
+		if u.Firmware {
+			firmware++
+		} else {
+			drivers++
+		}
+	}
+	var parts []string
+	if drivers == 1 {
+		parts = append(parts, "1 driver")
+	} else if drivers > 1 {
+		parts = append(parts, fmt.Sprintf("%d drivers", drivers))
+	}
+	if firmware > 0 {
+		parts = append(parts, fmt.Sprintf("%d firmware", firmware))
+	}
+	if len(parts) == 0 {
+		return "none pending"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// updateTitles lists the first few updates, firmware first.
+func updateTitles(updates []DriverUpdate) []string {
+	var lines []string
+	for _, firmware := range []bool{true, false} {
+		for _, u := range updates {
+			if u.Firmware == firmware && len(lines) < maxDriverUpdateTitles {
+				lines = append(lines, "  "+u.Title)
+			}
+		}
+	}
+	if len(updates) > maxDriverUpdateTitles {
+		lines = append(lines, fmt.Sprintf("  ... and %d more", len(updates)-maxDriverUpdateTitles))
+	}
+	return lines
+}
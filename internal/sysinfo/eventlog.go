@@ -0,0 +1,108 @@
+package sysinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Event levels in the System/Level element.
+const (
+	eventLevelCritical = 1
+	eventLevelError    = 2
+	eventLevelWarning  = 3
+)
+
+// logEvent is an event read from an event log with wevtutil.
+type logEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     int `xml:"EventID"`
+		Level       int `xml:"Level"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+	} `xml:"System"`
+	EventData []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+	RenderingInfo struct {
+		Message string `xml:"Message"`
+	} `xml:"RenderingInfo"`
+}
+
+// Time returns when the event was logged.
+func (e *logEvent) Time() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, e.System.TimeCreated.SystemTime)
+	return t
+}
+
+// Data returns the named EventData value, e.g. "TargetUserName".
+func (e *logEvent) Data(name string) string {
+	for _, d := range e.EventData {
+		if d.Name == name {
+			return strings.TrimSpace(d.Value)
+		}
+	}
+	return ""
+}
+
+// queryEvents returns up to count of the newest events in an event log that
+// match an XPath query such as "*[System[(EventID=4)]]", newest first, with
+// their rendered messages.
+func queryEvents(ctx context.Context, logName, query string, count int) ([]logEvent, error) {
+	output, err := winapi.Commands.Run(ctx, "wevtutil", "qe", logName, "/q:"+query,
+		fmt.Sprintf("/c:%d", count), "/rd:true", "/f:RenderedXml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the %s log: %v: %s", logName, err, strings.TrimSpace(string(output)))
+	}
+
+	// The events follow each other without a root element
+	var events []logEvent
+	decoder := xml.NewDecoder(bytes.NewReader(output))
+	for {
+		var e logEvent
+		err := decoder.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events, fmt.Errorf("failed to parse the %s log: %v", logName, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// eventIDQuery returns an XPath query for events with any of the IDs from the
+// named providers (any provider when none are given).
+func eventIDQuery(providers []string, ids []int) string {
+	var conditions []string
+	if len(providers) > 0 {
+		var names []string
+		for _, p := range providers {
+			names = append(names, fmt.Sprintf("@Name='%s'", p))
+		}
+		conditions = append(conditions, "Provider["+strings.Join(names, " or ")+"]")
+	}
+	if len(ids) > 0 {
+		var terms []string
+		for _, id := range ids {
+			terms = append(terms, fmt.Sprintf("EventID=%d", id))
+		}
+		conditions = append(conditions, "("+strings.Join(terms, " or ")+")")
+	}
+	if len(conditions) == 0 {
+		return "*"
+	}
+	return "*[System[" + strings.Join(conditions, " and ") + "]]"
+}
@@ -0,0 +1,46 @@
+package sysinfo
+
+import "time"
+
+// FixtureTime is the timestamp used by the fixture data.
+var FixtureTime = time.Date(2024, time.January, 15, 9, 30, 0, 0, time.UTC)
+
+// FixtureSystemInfo returns fixed, made-up system information. Rendering it gives
+// the same image on every machine, so layout changes can be checked against
+// reference renders.
+func FixtureSystemInfo() *SystemInfo {
+	return &SystemInfo{
+		Hostname:     "WS-FIXTURE-01",
+		OS:           "Windows 11 Pro 24H2",
+		CPU:          "Intel(R) Core(TM) i7-1185G7 @ 3.00GHz (4 cores)",
+		RAM:          "16 GB RAM",
+		GPU:          "Intel(R) Iris(R) Xe Graphics",
+		IPAddresses:  []string{"192.168.1.42", "10.0.0.17"},
+		DiskInfo:     []string{"C: 212GB / 476GB", "D: 1.2TB / 1.8TB"},
+		SerialNumber: "FX0123456789",
+		Uptime:       "3d 4h 12m",
+		GeneratedAt:  FixtureTime.Format("Generated: Jan 2, 2006 3:04 PM"),
+	}
+}
+
+// FixtureServices returns a fixed services summary with one stopped critical
+// service and two failed services, so every section of the panel is drawn.
+func FixtureServices() *ServicesSummary {
+	return &ServicesSummary{
+		RunningCount: 142,
+		StoppedCount: 96,
+		TotalCount:   238,
+		FailedServices: []ServiceStatus{
+			{Name: "Spooler", State: "Stopped"},
+			{Name: "edgeupdate", State: "Stopped"},
+		},
+		CriticalServices: []ServiceStatus{
+			{Name: "Dhcp", State: "Running", IsOK: true},
+			{Name: "Dnscache", State: "Running", IsOK: true},
+			{Name: "wuauserv", State: "Running", IsOK: true},
+			{Name: "WinDefend", State: "Running", IsOK: true},
+			{Name: "Spooler", State: "Stopped"},
+			{Name: "EventLog", State: "Running", IsOK: true},
+		},
+	}
+}
@@ -0,0 +1,185 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// HistoryFileName is the file in the data directory holding the utilization samples.
+const HistoryFileName = "history.json"
+
+// HistoryWindow is the time span shown by the trend graph.
+const HistoryWindow = 24 * time.Hour
+
+// maxHistorySamples caps the file size if samples are taken very frequently.
+const maxHistorySamples = 2000
+
+// cpuSampleInterval is how long CPU usage is measured for a single sample.
+const cpuSampleInterval = time.Second
+
+// Sample is a single utilization measurement. Network counters are cumulative
+// totals since boot; rates are derived from consecutive samples.
+type Sample struct {
+	Time         time.Time `json:"t"`
+	CPUPercent   float64   `json:"cpu"`
+	MemPercent   float64   `json:"mem"`
+	NetBytesSent uint64    `json:"tx"`
+	NetBytesRecv uint64    `json:"rx"`
+}
+
+// History is the rolling set of samples persisted between runs.
+type History struct {
+	Samples []Sample `json:"samples"`
+}
+
+// RatePoint is the network throughput between two samples, in bytes per second.
+type RatePoint struct {
+	Time  time.Time
+	Bytes float64
+}
+
+// TakeSample measures current CPU, memory and network counters.
+func TakeSample(ctx context.Context) (Sample, error) {
+	s := Sample{Time: time.Now()}
+
+	percents, err := cpu.PercentWithContext(ctx, cpuSampleInterval, false)
+	if err != nil || len(percents) == 0 {
+		return s, fmt.Errorf("failed to measure CPU usage: %v", err)
+	}
+	s.CPUPercent = percents[0]
+
+	memInfo, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return s, fmt.Errorf("failed to measure memory usage: %v", err)
+	}
+	s.MemPercent = memInfo.UsedPercent
+
+	counters, err := net.IOCountersWithContext(ctx, false)
+	if err == nil && len(counters) > 0 {
+		s.NetBytesSent = counters[0].BytesSent
+		s.NetBytesRecv = counters[0].BytesRecv
+	}
+
+	return s, nil
+}
+
+// LoadHistory reads the samples from dir. A missing file yields an empty history.
+func LoadHistory(dir string) (*History, error) {
+	h := &History{}
+
+	data, err := os.ReadFile(filepath.Join(dir, HistoryFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return h, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	if err := json.Unmarshal(data, h); err != nil {
+		// A corrupt file only loses the trend, so start over rather than failing
+		return &History{}, fmt.Errorf("failed to parse history (starting over): %v", err)
+	}
+	return h, nil
+}
+
+// Save writes the samples to dir.
+func (h *History) Save(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %v", err)
+	}
+
+	// Write to a temp file first so a crash mid-write can't corrupt the history
+	path := filepath.Join(dir, HistoryFileName)
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Add appends a sample and drops samples that fell out of the window.
+func (h *History) Add(s Sample) {
+	h.Samples = append(h.Samples, s)
+
+	cutoff := s.Time.Add(-HistoryWindow - time.Hour)
+	start := 0
+	for start < len(h.Samples) && h.Samples[start].Time.Before(cutoff) {
+		start++
+	}
+	if len(h.Samples)-start > maxHistorySamples {
+		start = len(h.Samples) - maxHistorySamples
+	}
+	h.Samples = h.Samples[start:]
+}
+
+// RecordSample takes a sample, appends it to the history in dir and saves it.
+func RecordSample(ctx context.Context, dir string) (*History, error) {
+	h, loadErr := LoadHistory(dir)
+
+	s, err := TakeSample(ctx)
+	if err != nil {
+		return h, err
+	}
+	h.Add(s)
+
+	if err := h.Save(dir); err != nil {
+		return h, err
+	}
+	return h, loadErr
+}
+
+// Since returns the samples taken after t.
+func (h *History) Since(t time.Time) []Sample {
+	for i, s := range h.Samples {
+		if s.Time.After(t) {
+			return h.Samples[i:]
+		}
+	}
+	return nil
+}
+
+// NetworkRates returns the combined send/receive throughput between consecutive
+// samples after t. Intervals where the counters went backwards (a reboot) are skipped.
+func (h *History) NetworkRates(t time.Time) []RatePoint {
+	var rates []RatePoint
+	samples := h.Since(t)
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		seconds := cur.Time.Sub(prev.Time).Seconds()
+		if seconds <= 0 || cur.NetBytesSent < prev.NetBytesSent || cur.NetBytesRecv < prev.NetBytesRecv {
+			continue
+		}
+		bytes := float64(cur.NetBytesSent-prev.NetBytesSent) + float64(cur.NetBytesRecv-prev.NetBytesRecv)
+		rates = append(rates, RatePoint{Time: cur.Time, Bytes: bytes / seconds})
+	}
+	return rates
+}
+
+// FormatRate formats a byte rate for display, e.g. "1.2 MB/s".
+func FormatRate(bytesPerSecond float64) string {
+	switch {
+	case bytesPerSecond >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB/s", bytesPerSecond/(1024*1024*1024))
+	case bytesPerSecond >= 1024*1024:
+		return fmt.Sprintf("%.1f MB/s", bytesPerSecond/(1024*1024))
+	case bytesPerSecond >= 1024:
+		return fmt.Sprintf("%.0f KB/s", bytesPerSecond/1024)
+	default:
+		return fmt.Sprintf("%.0f B/s", bytesPerSecond)
+	}
+}
@@ -0,0 +1,41 @@
+package sysinfo
+
+import (
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetSystemDefaultUILanguage = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemDefaultUILanguage")
+
+// rtlLanguages are the primary language IDs of the Windows display languages
+// written right to left.
+var rtlLanguages = map[uint16]bool{
+	0x01: true, // Arabic
+	0x0D: true, // Hebrew
+	0x20: true, // Urdu
+	0x29: true, // Persian
+	0x3D: true, // Yiddish
+	0x59: true, // Sindhi
+	0x5A: true, // Syriac
+	0x63: true, // Pashto
+	0x65: true, // Divehi
+	0x80: true, // Uyghur
+	0x8C: true, // Dari
+	0x92: true, // Central Kurdish
+}
+
+var (
+	rtlLanguage     bool
+	rtlLanguageOnce sync.Once
+)
+
+// RightToLeftUILanguage reports whether the system's display language, which
+// the login screen is shown in, is written right to left.
+func RightToLeftUILanguage() bool {
+	rtlLanguageOnce.Do(func() {
+		langID, _, _ := procGetSystemDefaultUILanguage.Call()
+		rtlLanguage = rtlLanguages[uint16(langID)&0x3FF]
+	})
+	return rtlLanguage
+}
@@ -0,0 +1,22 @@
+package sysinfo
+
+import (
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// legalNoticeKey (HKLM) holds the "Interactive logon: Message title/text for
+// users attempting to log on" policy.
+const legalNoticeKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System`
+
+// LegalNotice returns the logon message title and text set by policy, empty
+// when none is set.
+func LegalNotice() (caption, text string) {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, legalNoticeKey, registry.QUERY_VALUE)
+	if err != nil {
+		return "", ""
+	}
+	defer key.Close()
+	return readRegistryValue(key, "legalnoticecaption"), readRegistryValue(key, "legalnoticetext")
+}
@@ -0,0 +1,181 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// administratorsSID is the BUILTIN\Administrators group, whose name is localized.
+const administratorsSID = "S-1-5-32-544"
+
+// maxPreferredLength is MAX_PREFERRED_LENGTH: let the API size the buffer.
+const maxPreferredLength = 0xFFFFFFFF
+
+var procNetLocalGroupGetMembers = syscall.NewLazyDLL("netapi32.dll").NewProc("NetLocalGroupGetMembers")
+
+// localGroupMembersInfo2 is LOCALGROUP_MEMBERS_INFO_2.
+type localGroupMembersInfo2 struct {
+	SID           *windows.SID
+	SIDUsage      uint32
+	DomainAndName *uint16
+}
+
+// wellKnownAdminRIDs are the relative IDs of the administrator accounts and
+// groups every machine or domain has, by the name they are collapsed to.
+var wellKnownAdminRIDs = map[string]string{
+	"500": "Administrator",
+	"512": "Domain Admins",
+	"519": "Enterprise Admins",
+}
+
+// entraIDPrefix starts the SIDs of Entra ID (Azure AD) roles, which Entra
+// joined machines add to the group (Global Administrator and Azure AD Joined
+// Device Local Administrator).
+const entraIDPrefix = "S-1-12-1-"
+
+// LocalAdmin is one member of the local Administrators group.
+type LocalAdmin struct {
+	Name string
+	SID  string
+}
+
+// LocalAdminsInfo is the membership of the local Administrators group.
+type LocalAdminsInfo struct {
+	// WellKnown are the expected members, collapsed to their well-known names.
+	WellKnown []string
+	// Members are the other members, sorted by name.
+	Members []LocalAdmin
+}
+
+// GatherLocalAdmins lists the members of the local Administrators group.
+func GatherLocalAdmins(ctx context.Context) (*LocalAdminsInfo, error) {
+	var members []LocalAdmin
+	err := withContext(ctx, func() error {
+		var err error
+		members, err = administratorsMembers()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &LocalAdminsInfo{}
+	entraRoles := 0
+	seen := make(map[string]bool)
+	for _, m := range members {
+		name, wellKnown := wellKnownAdmin(m.SID)
+		switch {
+		case strings.HasPrefix(m.SID, entraIDPrefix):
+			entraRoles++
+		case wellKnown && !seen[name]:
+			seen[name] = true
+			info.WellKnown = append(info.WellKnown, name)
+		case !wellKnown:
+			info.Members = append(info.Members, m)
+		}
+	}
+	if entraRoles > 0 {
+		info.WellKnown = append(info.WellKnown, fmt.Sprintf("%d Entra ID roles", entraRoles))
+	}
+	sort.Slice(info.Members, func(i, j int) bool {
+		return strings.ToLower(info.Members[i].Name) < strings.ToLower(info.Members[j].Name)
+	})
+	return info, nil
+}
+
+// wellKnownAdmin returns the collapsed name of a well-known administrator SID.
+func wellKnownAdmin(sid string) (string, bool) {
+	if !strings.HasPrefix(sid, "S-1-5-21-") {
+		return "", false
+	}
+	rid := sid[strings.LastIndex(sid, "-")+1:]
+	name, ok := wellKnownAdminRIDs[rid]
+	return name, ok
+}
+
+// administratorsMembers reads the members of BUILTIN\Administrators.
+func administratorsMembers() ([]LocalAdmin, error) {
+	sid, err := windows.StringToSid(administratorsSID)
+	if err != nil {
+		return nil, err
+	}
+	group, _, _, err := sid.LookupAccount("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the Administrators group: %v", err)
+	}
+	groupPtr, err := syscall.UTF16PtrFromString(group)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf *localGroupMembersInfo2
+	var read, total uint32
+	ret, _, _ := procNetLocalGroupGetMembers.Call(
+		0,
+		uintptr(unsafe.Pointer(groupPtr)),
+		2,
+		uintptr(unsafe.Pointer(&buf)),
+		maxPreferredLength,
+		uintptr(unsafe.Pointer(&read)),
+		uintptr(unsafe.Pointer(&total)),
+		0,
+	)
+	if ret != 0 {
+		return nil, fmt.Errorf("failed to list %s: %v", group, syscall.Errno(ret))
+	}
+	if buf == nil {
+		return nil, nil
+	}
+	defer windows.NetApiBufferFree((*byte)(unsafe.Pointer(buf)))
+
+	entries := unsafe.Slice(buf, read)
+	members := make([]LocalAdmin, 0, read)
+	for _, e := range entries {
+		members = append(members, LocalAdmin{
+			Name: windows.UTF16PtrToString(e.DomainAndName),
+			SID:  e.SID.String(),
+		})
+	}
+	return members, nil
+}
+
+// FormatLocalAdminsLines returns the group membership as lines for display,
+// with the member names redacted with usernameMode.
+func (l *LocalAdminsInfo) FormatLocalAdminsLines(usernameMode string) []string {
+	lines := []string{}
+
+	lines = append(lines, "Local Administrators")
+	lines = append(lines, "")
+
+	if len(l.WellKnown) > 0 {
+		lines = append(lines, fmt.Sprintf("Built-in: %s", strings.Join(l.WellKnown, ", ")))
+	}
+	if len(l.Members) == 0 {
+		lines = append(lines, "No other members")
+		return lines
+	}
+
+	lines = append(lines, fmt.Sprintf("Other members (%d):", len(l.Members)))
+	// Limit to first 10 to avoid overflow
+	count := len(l.Members)
+	if count > 10 {
+		count = 10
+	}
+	for _, m := range l.Members[:count] {
+		name := RedactValue(m.Name, usernameMode)
+		if name == "" {
+			continue
+		}
+		lines = append(lines, "  "+name)
+	}
+	if len(l.Members) > count {
+		lines = append(lines, fmt.Sprintf("  ... and %d more", len(l.Members)-count))
+	}
+	return lines
+}
@@ -0,0 +1,153 @@
+package sysinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxLogonEvents bounds how many logon and logoff events are read.
+const maxLogonEvents = 200
+
+// recentLogonCount is how many logons are shown.
+const recentLogonCount = 5
+
+// logonTypes names the interactive logon types of event 4624; the others are
+// services, network shares, batch jobs and unlocks.
+var logonTypes = map[string]string{
+	"2":  "Console",
+	"10": "RDP",
+	"11": "Cached",
+}
+
+// Logon is an interactive or Remote Desktop logon session.
+type Logon struct {
+	User string
+	// Type is "Console", "RDP" or "Cached" (console logon with cached
+	// domain credentials).
+	Type   string
+	Source string
+	Time   time.Time
+	// Logoff is zero while the session is still open, or when its logoff
+	// has rolled out of the Security log.
+	Logoff time.Time
+}
+
+// LogonInfo lists the recent interactive logons, newest first.
+type LogonInfo struct {
+	Logons []Logon
+}
+
+// GatherLogons reads the recent console and Remote Desktop logons (Security
+// event 4624) and matches them with their logoffs (4634 and 4647) by logon
+// ID. Reading the Security log requires running as SYSTEM or an
+// administrator.
+func GatherLogons(ctx context.Context) (*LogonInfo, error) {
+	var types []string
+	for t := range logonTypes {
+		types = append(types, fmt.Sprintf("Data[@Name='LogonType']='%s'", t))
+	}
+	query := "*[System[(EventID=4624)] and EventData[" + strings.Join(types, " or ") + "]]"
+
+	info := &LogonInfo{}
+	var problems []string
+	logons, err := queryEvents(ctx, "Security", query, maxLogonEvents)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	logoffs, err := queryEvents(ctx, "Security", eventIDQuery(nil, []int{4634, 4647}), maxLogonEvents)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// The newest logoff of each session
+	logoffTimes := make(map[string]time.Time)
+	for _, e := range logoffs {
+		id := e.Data("TargetLogonId")
+		if id == "" {
+			continue
+		}
+		if _, ok := logoffTimes[id]; !ok {
+			logoffTimes[id] = e.Time()
+		}
+	}
+
+	// An administrator's logon with UAC logs a second, linked logon; only
+	// the one the linked session points back to is kept
+	linked := make(map[string]bool)
+	for _, e := range logons {
+		if id := e.Data("TargetLinkedLogonId"); id != "" && id != "0x0" {
+			linked[id] = true
+		}
+	}
+
+	for _, e := range logons {
+		if len(info.Logons) == recentLogonCount {
+			break
+		}
+		user := e.Data("TargetUserName")
+		domain := e.Data("TargetDomainName")
+		// Desktop Window Manager and font driver sessions log on as type 2
+		if user == "" || strings.HasSuffix(user, "$") || domain == "Window Manager" || domain == "Font Driver Host" {
+			continue
+		}
+		id := e.Data("TargetLogonId")
+		if e.Data("ElevatedToken") == "%%1843" && linked[id] {
+			continue
+		}
+		source := e.Data("IpAddress")
+		if source == "-" || source == "127.0.0.1" || source == "::1" {
+			source = ""
+		}
+		info.Logons = append(info.Logons, Logon{
+			User:   user,
+			Type:   logonTypes[e.Data("LogonType")],
+			Source: source,
+			Time:   e.Time(),
+			Logoff: logoffTimes[id],
+		})
+	}
+
+	if len(problems) > 0 {
+		return info, errors.New(strings.Join(problems, "; "))
+	}
+	return info, nil
+}
+
+// FormatLogonLines returns the recent logons as lines for display, e.g.
+// "jdoe (RDP from 10.0.0.5): Mon 14 Oct 08:12 - 17:40". Names and addresses
+// are redacted as configured.
+func (l *LogonInfo) FormatLogonLines(r Redaction) []string {
+	lines := []string{}
+	lines = append(lines, "Recent Logons")
+	lines = append(lines, "")
+
+	if len(l.Logons) == 0 {
+		lines = append(lines, "None logged")
+		return lines
+	}
+	for _, logon := range l.Logons {
+		kind := logon.Type
+		if source := RedactIP(logon.Source, r.IPAddresses); source != "" {
+			kind += " from " + source
+		}
+
+		when := logon.Time.Local().Format("Mon 2 Jan 15:04")
+		switch {
+		case logon.Logoff.IsZero():
+			when += " - still logged on"
+		case logon.Logoff.Local().YearDay() == logon.Time.Local().YearDay():
+			when += " - " + logon.Logoff.Local().Format("15:04")
+		default:
+			when += " - " + logon.Logoff.Local().Format("Mon 2 Jan 15:04")
+		}
+		if user := RedactValue(logon.User, r.Usernames); user != "" {
+			lines = append(lines, fmt.Sprintf("%s (%s): %s", user, kind, when))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", kind, when))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,51 @@
+package sysinfo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// Metrics are the current numeric readings published for dashboards.
+type Metrics struct {
+	CPUPercent    float64
+	MemPercent    float64
+	UptimeSeconds uint64
+	// DiskFreePercent is the free space of each local volume, keyed by its
+	// mount point such as "C:".
+	DiskFreePercent map[string]float64
+}
+
+// GatherMetrics measures CPU usage over one second, memory usage, uptime and
+// the free space of every local volume.
+func GatherMetrics(ctx context.Context) (*Metrics, error) {
+	m := &Metrics{DiskFreePercent: make(map[string]float64)}
+
+	sample, err := TakeSample(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.CPUPercent = sample.CPUPercent
+	m.MemPercent = sample.MemPercent
+
+	if uptime, err := host.UptimeWithContext(ctx); err == nil {
+		m.UptimeSeconds = uptime
+	}
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err == nil {
+		for _, partition := range partitions {
+			if partition.Fstype == "" {
+				continue
+			}
+			usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+			if err != nil || usage.Total == 0 {
+				continue
+			}
+			m.DiskFreePercent[strings.TrimSuffix(partition.Mountpoint, `\`)] = 100 - usage.UsedPercent
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,133 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// topProcessCount is how many processes are listed for CPU and for memory.
+const topProcessCount = 3
+
+// ProcessUsage is the combined usage of the processes sharing an image name,
+// so a browser's dozens of processes show up as one entry.
+type ProcessUsage struct {
+	Name  string
+	Count int
+	// CPUPercent is the share of the whole machine over the sample interval.
+	CPUPercent float64
+	Memory     uint64
+}
+
+// ProcessInfo lists the processes using the most CPU and memory.
+type ProcessInfo struct {
+	TopCPU    []ProcessUsage
+	TopMemory []ProcessUsage
+}
+
+// GatherProcesses measures the CPU time every process uses over one second
+// and returns the top consumers of CPU and of memory (working set).
+func GatherProcesses(ctx context.Context) (*ProcessInfo, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %v", err)
+	}
+
+	before := make(map[int32]float64)
+	for _, p := range procs {
+		if times, err := p.TimesWithContext(ctx); err == nil {
+			before[p.Pid] = times.User + times.System
+		}
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(cpuSampleInterval):
+	}
+	elapsed := time.Since(start).Seconds() * float64(runtime.NumCPU())
+
+	usage := make(map[string]*ProcessUsage)
+	for _, p := range procs {
+		// PID 0 is the System Idle Process, whose CPU time is idle time
+		if p.Pid == 0 {
+			continue
+		}
+		name, err := p.NameWithContext(ctx)
+		if err != nil || name == "" {
+			continue
+		}
+		u := usage[strings.ToLower(name)]
+		if u == nil {
+			u = &ProcessUsage{Name: name}
+			usage[strings.ToLower(name)] = u
+		}
+		u.Count++
+		if prev, ok := before[p.Pid]; ok {
+			if times, err := p.TimesWithContext(ctx); err == nil && times.User+times.System > prev {
+				u.CPUPercent += (times.User + times.System - prev) / elapsed * 100
+			}
+		}
+		if mem, err := p.MemoryInfoWithContext(ctx); err == nil {
+			u.Memory += mem.RSS
+		}
+	}
+
+	all := make([]ProcessUsage, 0, len(usage))
+	for _, u := range usage {
+		all = append(all, *u)
+	}
+
+	info := &ProcessInfo{}
+	sort.Slice(all, func(i, j int) bool { return all[i].CPUPercent > all[j].CPUPercent })
+	for _, u := range all {
+		// Idle processes aren't worth listing
+		if len(info.TopCPU) == topProcessCount || u.CPUPercent < 1 {
+			break
+		}
+		info.TopCPU = append(info.TopCPU, u)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Memory > all[j].Memory })
+	for _, u := range all {
+		if len(info.TopMemory) == topProcessCount {
+			break
+		}
+		info.TopMemory = append(info.TopMemory, u)
+	}
+	return info, nil
+}
+
+// FormatProcessLines returns the top processes as lines for display.
+func (p *ProcessInfo) FormatProcessLines() []string {
+	lines := []string{}
+	lines = append(lines, "Top Processes")
+	lines = append(lines, "")
+
+	if len(p.TopCPU) == 0 {
+		lines = append(lines, "CPU: Idle")
+	} else {
+		lines = append(lines, "CPU:")
+		for _, u := range p.TopCPU {
+			lines = append(lines, fmt.Sprintf("  %s: %.0f%%", u.label(), u.CPUPercent))
+		}
+	}
+	lines = append(lines, "Memory:")
+	for _, u := range p.TopMemory {
+		lines = append(lines, fmt.Sprintf("  %s: %s", u.label(), formatSize(int64(u.Memory))))
+	}
+	return lines
+}
+
+// label is the process name, with the number of processes when there are
+// several, e.g. "chrome.exe (14)".
+func (u ProcessUsage) label() string {
+	if u.Count > 1 {
+		return fmt.Sprintf("%s (%d)", u.Name, u.Count)
+	}
+	return u.Name
+}
@@ -0,0 +1,201 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// ProfileSizesFileName caches the last profile scan in the data directory.
+const ProfileSizesFileName = "profile_sizes.json"
+
+// ProfileScanInterval is how long a profile scan is reused: walking every
+// profile takes minutes on a lab machine with many users.
+const ProfileScanInterval = 6 * time.Hour
+
+// profileScanTimeout bounds a scan so it doesn't hold up the login screen
+// update; a scan cut short is shown as incomplete and retried on the next run.
+const profileScanTimeout = 2 * time.Minute
+
+// TopProfiles is how many of the largest profiles are shown.
+const TopProfiles = 3
+
+// profileListKey (HKLM) lists the user profiles on this machine.
+const profileListKey = `SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList`
+
+// ProfileSize is the disk usage of one user profile.
+type ProfileSize struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// ProfileSizesInfo is the disk usage of the user profiles on the system drive.
+type ProfileSizesInfo struct {
+	ScannedAt time.Time `json:"scanned_at"`
+	// Profiles are sorted largest first.
+	Profiles []ProfileSize `json:"profiles"`
+	// Partial is true when the scan was cut short by the run's deadline.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// GatherProfileSizes returns the size of every user profile on the system
+// drive. A scan younger than ProfileScanInterval is read from cacheDir.
+func GatherProfileSizes(ctx context.Context, cacheDir string) (*ProfileSizesInfo, error) {
+	cachePath := filepath.Join(cacheDir, ProfileSizesFileName)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cached ProfileSizesInfo
+		if json.Unmarshal(data, &cached) == nil && !cached.Partial &&
+			time.Since(cached.ScannedAt) < ProfileScanInterval {
+			return &cached, nil
+		}
+	}
+
+	scanCtx, cancel := context.WithTimeout(ctx, profileScanTimeout)
+	defer cancel()
+
+	info := &ProfileSizesInfo{ScannedAt: time.Now()}
+	systemDrive := strings.ToLower(os.Getenv("SystemDrive"))
+	for _, path := range profilePaths() {
+		if systemDrive != "" && !strings.HasPrefix(strings.ToLower(path), systemDrive) {
+			continue
+		}
+		size, err := directorySize(scanCtx, path)
+		if err != nil {
+			info.Partial = true
+		}
+		info.Profiles = append(info.Profiles, ProfileSize{Name: filepath.Base(path), Path: path, Bytes: size})
+		if scanCtx.Err() != nil {
+			break
+		}
+	}
+	sort.Slice(info.Profiles, func(i, j int) bool { return info.Profiles[i].Bytes > info.Profiles[j].Bytes })
+
+	if data, err := json.MarshalIndent(info, "", "  "); err == nil && cacheDir != "" {
+		os.MkdirAll(cacheDir, 0755)
+		os.WriteFile(cachePath, data, 0644)
+	}
+	if ctx.Err() != nil {
+		return info, ctx.Err()
+	}
+	return info, nil
+}
+
+// profilePaths returns the folders of the user profiles, skipping the service
+// account profiles.
+func profilePaths() []string {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, profileListKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+
+	sids, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, sid := range sids {
+		if !strings.HasPrefix(sid, "S-1-5-21-") && !strings.HasPrefix(sid, "S-1-12-1-") {
+			continue
+		}
+		sub, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, profileListKey+`\`+sid, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		path, _, err := sub.GetStringValue("ProfileImagePath")
+		sub.Close()
+		if err != nil || path == "" {
+			continue
+		}
+		if expanded, err := registry.ExpandString(path); err == nil {
+			path = expanded
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// directorySize adds up the files below dir. Junctions and symbolic links are
+// not followed, and unreadable folders are skipped. It stops when ctx is done.
+func directorySize(ctx context.Context, dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// FormatProfileSizesLines returns the largest profiles as lines for display,
+// with the profile names redacted with usernameMode.
+func (p *ProfileSizesInfo) FormatProfileSizesLines(usernameMode string) []string {
+	lines := []string{}
+
+	lines = append(lines, "Largest Profiles")
+	lines = append(lines, "")
+
+	if len(p.Profiles) == 0 {
+		lines = append(lines, "No user profiles found")
+		return lines
+	}
+
+	var total int64
+	for _, profile := range p.Profiles {
+		total += profile.Bytes
+	}
+	count := len(p.Profiles)
+	if count > TopProfiles {
+		count = TopProfiles
+	}
+	for _, profile := range p.Profiles[:count] {
+		name := RedactValue(profile.Name, usernameMode)
+		if name == "" {
+			name = "(user)"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", name, formatSize(profile.Bytes)))
+	}
+	lines = append(lines, fmt.Sprintf("All %d profiles: %s", len(p.Profiles), formatSize(total)))
+	if p.Partial {
+		lines = append(lines, "(scan incomplete)")
+	}
+	return lines
+}
+
+// formatSize formats a byte count as MB, GB or TB.
+func formatSize(bytes int64) string {
+	gb := float64(bytes) / (1024 * 1024 * 1024)
+	switch {
+	case gb >= 1024:
+		return fmt.Sprintf("%.1fTB", gb/1024)
+	case gb >= 10:
+		return fmt.Sprintf("%.0fGB", gb)
+	case gb >= 1:
+		return fmt.Sprintf("%.1fGB", gb)
+	}
+	return fmt.Sprintf("%.0fMB", gb*1024)
+}
@@ -0,0 +1,220 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheFileName is the file in the data directory holding the last system info
+// gathered while the machine was ready, used when a boot run is not.
+const CacheFileName = "sysinfo_cache.json"
+
+// readinessPollInterval is how often WaitForReady checks again.
+const readinessPollInterval = 2 * time.Second
+
+// wmiProbeTimeout bounds a single WMI readiness probe.
+const wmiProbeTimeout = 5 * time.Second
+
+// Readiness reports which of the sources the panels depend on are available.
+type Readiness struct {
+	WMI     bool
+	Network bool
+}
+
+// Ready reports whether everything is available.
+func (r Readiness) Ready() bool {
+	return r.WMI && r.Network
+}
+
+// String describes what is missing, for the event log.
+func (r Readiness) String() string {
+	switch {
+	case r.Ready():
+		return "ready"
+	case !r.WMI && !r.Network:
+		return "WMI and network not ready"
+	case !r.WMI:
+		return "WMI not ready"
+	}
+	return "no network address"
+}
+
+// HasRoutableAddress reports whether an interface has an IPv4 address that is
+// not loopback or APIPA (169.254.x.x, assigned when DHCP has not answered yet).
+func HasRoutableAddress() bool {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return false
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			if ip != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wmiReady reports whether the WMI service answers a simple query.
+func wmiReady(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, wmiProbeTimeout)
+	defer cancel()
+
+	var osInfo []Win32_OperatingSystem
+	err := queryWMI(ctx, "SELECT Caption FROM Win32_OperatingSystem", &osInfo)
+	return err == nil && len(osInfo) > 0
+}
+
+// WaitForReady waits up to timeout for the WMI service and a routable network
+// address, which can lag behind the boot task. Returns what was available when
+// it gave up; a zero timeout checks once.
+func WaitForReady(ctx context.Context, timeout time.Duration) Readiness {
+	deadline := time.Now().Add(timeout)
+	var r Readiness
+	for {
+		if !r.WMI {
+			r.WMI = wmiReady(ctx)
+		}
+		if !r.Network {
+			r.Network = HasRoutableAddress()
+		}
+		if r.Ready() || !time.Now().Before(deadline) {
+			return r
+		}
+
+		select {
+		case <-time.After(readinessPollInterval):
+		case <-ctx.Done():
+			return r
+		}
+	}
+}
+
+// settleChecks is how many consecutive polls must see the same addresses before
+// the network counts as settled.
+const settleChecks = 3
+
+// WaitForSettledAddresses waits up to timeout for a routable IPv4 address and
+// for the address list to stop changing, as it does for a while after resume
+// while DHCP renews the lease. Returns the last addresses seen and whether they
+// settled in time.
+func WaitForSettledAddresses(ctx context.Context, timeout time.Duration) ([]string, bool) {
+	deadline := time.Now().Add(timeout)
+	var last []string
+	stable := 0
+	for {
+		current := getIPAddresses()
+		if HasRoutableAddress() && sameAddresses(current, last) {
+			stable++
+		} else {
+			stable = 1
+		}
+		last = current
+		if stable >= settleChecks && HasRoutableAddress() {
+			return last, true
+		}
+		if !time.Now().Before(deadline) {
+			return last, false
+		}
+
+		select {
+		case <-time.After(readinessPollInterval):
+		case <-ctx.Done():
+			return last, false
+		}
+	}
+}
+
+// sameAddresses reports whether two address lists hold the same addresses.
+func sameAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := map[string]int{}
+	for _, ip := range a {
+		seen[ip]++
+	}
+	for _, ip := range b {
+		if seen[ip] == 0 {
+			return false
+		}
+		seen[ip]--
+	}
+	return true
+}
+
+// cachedInfo is the on-disk form of the system info cache.
+type cachedInfo struct {
+	SavedAt time.Time   `json:"saved_at"`
+	Info    *SystemInfo `json:"info"`
+}
+
+// SaveCache stores info in dir for runs that happen before the machine is ready.
+func SaveCache(dir string, info *SystemInfo) error {
+	data, err := json.Marshal(cachedInfo{SavedAt: time.Now(), Info: info})
+	if err != nil {
+		return fmt.Errorf("failed to encode system info cache: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, CacheFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write system info cache: %v", err)
+	}
+	return nil
+}
+
+// LoadCache reads the cached system info from dir and when it was saved.
+func LoadCache(dir string) (*SystemInfo, time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(dir, CacheFileName))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read system info cache: %v", err)
+	}
+	var cached cachedInfo
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Info == nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse system info cache: %v", err)
+	}
+	return cached.Info, cached.SavedAt, nil
+}
+
+// FillFromCache fills the fields that could not be gathered (empty or
+// "Unknown") from cached info, and sets Status to an "offline at boot" marker
+// naming when the cached values were gathered.
+func (s *SystemInfo) FillFromCache(cached *SystemInfo, savedAt time.Time) {
+	fill := func(field *string, value string) {
+		if *field == "" || *field == "Unknown" {
+			*field = value
+		}
+	}
+	fill(&s.OS, cached.OS)
+	fill(&s.CPU, cached.CPU)
+	fill(&s.RAM, cached.RAM)
+	fill(&s.GPU, cached.GPU)
+	fill(&s.SerialNumber, cached.SerialNumber)
+	if len(s.IPAddresses) == 0 {
+		s.IPAddresses = cached.IPAddresses
+	}
+	if len(s.DiskInfo) == 0 {
+		s.DiskInfo = cached.DiskInfo
+	}
+
+	s.Status = fmt.Sprintf("Offline at boot - cached %s", savedAt.Format("Jan 2, 3:04 PM"))
+}
@@ -0,0 +1,89 @@
+package sysinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// Redaction modes for a sensitive value. An empty mode shows the value as is.
+const (
+	RedactShow = "show"
+	// RedactMask keeps only the end of the value: the last four characters, or
+	// the last octet (IPv4) or group (IPv6) of an address.
+	RedactMask = "mask"
+	// RedactHash replaces the value with the start of its SHA-256, so a machine
+	// can still be told apart without showing what it is.
+	RedactHash = "hash"
+	// RedactOmit leaves the value out.
+	RedactOmit = "omit"
+)
+
+// Redaction is the redaction mode of each sensitive field shown on the panels.
+type Redaction struct {
+	Hostname     string
+	SerialNumber string
+	IPAddresses  string
+	Usernames    string
+}
+
+// Redacted returns a copy of s with the sensitive fields redacted for display.
+// s itself keeps the full values for the cache and exports.
+func (s *SystemInfo) Redacted(r Redaction) *SystemInfo {
+	out := *s
+	out.Hostname = RedactValue(s.Hostname, r.Hostname)
+	out.SerialNumber = RedactValue(s.SerialNumber, r.SerialNumber)
+	out.IPAddresses = nil
+	for _, ip := range s.IPAddresses {
+		if redacted := RedactIP(ip, r.IPAddresses); redacted != "" {
+			out.IPAddresses = append(out.IPAddresses, redacted)
+		}
+	}
+	return &out
+}
+
+// RedactValue redacts a value such as a serial number or account name (a
+// DOMAIN\ prefix is redacted along with the name).
+// "Unknown" is not sensitive and is kept.
+func RedactValue(value, mode string) string {
+	if value == "" || value == "Unknown" {
+		return value
+	}
+	switch mode {
+	case RedactMask:
+		runes := []rune(value)
+		if len(runes) <= 4 {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+	case RedactHash:
+		return hashValue(value)
+	case RedactOmit:
+		return ""
+	}
+	return value
+}
+
+// RedactIP redacts an IP address; masking keeps the last octet (x.x.x.42) or
+// the last IPv6 group.
+func RedactIP(ip, mode string) string {
+	if mode != RedactMask {
+		return RedactValue(ip, mode)
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return RedactValue(ip, mode)
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return "x.x.x." + strings.Split(v4.String(), ".")[3]
+	}
+	groups := strings.Split(ip, ":")
+	return "x:...:" + groups[len(groups)-1]
+}
+
+// hashValue returns the first 8 hex digits of the value's SHA-256.
+func hashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "#" + hex.EncodeToString(sum[:])[:8]
+}
@@ -0,0 +1,163 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Registry keys (HKLM) of the Remote Desktop settings. Group Policy values
+// take precedence over the local ones.
+const (
+	terminalServerKey      = `SYSTEM\CurrentControlSet\Control\Terminal Server`
+	rdpTCPKey              = `SYSTEM\CurrentControlSet\Control\Terminal Server\WinStations\RDP-Tcp`
+	terminalServicesPolicy = `SOFTWARE\Policies\Microsoft\Windows NT\Terminal Services`
+)
+
+// defaultRDPPort is the Remote Desktop port when PortNumber is not set.
+const defaultRDPPort = 3389
+
+// remoteAccessTools map remote access products to the start of their service
+// names or display names.
+var remoteAccessTools = []struct {
+	Name     string
+	Prefixes []string
+}{
+	{"TeamViewer", []string{"TeamViewer"}},
+	{"AnyDesk", []string{"AnyDesk"}},
+	{"ScreenConnect", []string{"ScreenConnect Client"}},
+	{"Splashtop", []string{"SplashtopRemoteService", "Splashtop"}},
+	{"LogMeIn", []string{"LogMeIn", "LMIGuardianSvc"}},
+	{"RustDesk", []string{"RustDesk"}},
+	{"Chrome Remote Desktop", []string{"chromoting", "Chrome Remote Desktop"}},
+	{"VNC", []string{"tvnserver", "uvnc_service", "vncserver", "winvnc"}},
+}
+
+// Win32_ServiceDisplay is used for WMI query to find services by display name.
+type Win32_ServiceDisplay struct {
+	Name        string
+	DisplayName string
+	State       string
+}
+
+// RemoteTool is an installed remote access product.
+type RemoteTool struct {
+	Name    string
+	Running bool
+}
+
+// RemoteAccessInfo is how this machine can be reached remotely.
+type RemoteAccessInfo struct {
+	RDPEnabled bool
+	RDPPort    int
+	// NLARequired is true when Network Level Authentication is enforced.
+	NLARequired bool
+	Tools       []RemoteTool
+}
+
+// GatherRemoteAccess reads the Remote Desktop settings and finds installed
+// remote access tools by their services.
+func GatherRemoteAccess(ctx context.Context) (*RemoteAccessInfo, error) {
+	info := &RemoteAccessInfo{RDPPort: defaultRDPPort}
+	info.RDPEnabled, info.NLARequired = rdpSettings()
+	info.RDPPort = int(registryDWord(rdpTCPKey, "PortNumber", defaultRDPPort))
+
+	var services []Win32_ServiceDisplay
+	err := queryWMI(ctx, "SELECT Name, DisplayName, State FROM Win32_Service", &services)
+	if err != nil {
+		return info, fmt.Errorf("failed to query services: %v", err)
+	}
+	for _, tool := range remoteAccessTools {
+		found, running := false, false
+		for _, svc := range services {
+			if hasAnyPrefix(svc.Name, tool.Prefixes) || hasAnyPrefix(svc.DisplayName, tool.Prefixes) {
+				found = true
+				running = running || svc.State == "Running"
+			}
+		}
+		if found {
+			info.Tools = append(info.Tools, RemoteTool{Name: tool.Name, Running: running})
+		}
+	}
+	return info, nil
+}
+
+// rdpSettings reports whether Remote Desktop connections are allowed and
+// require Network Level Authentication, policy taking precedence.
+func rdpSettings() (enabled, nla bool) {
+	deny := registryDWord(terminalServerKey, "fDenyTSConnections", 1)
+	deny = registryDWord(terminalServicesPolicy, "fDenyTSConnections", deny)
+	auth := registryDWord(rdpTCPKey, "UserAuthentication", 1)
+	auth = registryDWord(terminalServicesPolicy, "UserAuthentication", auth)
+	return deny == 0, auth != 0
+}
+
+// registryDWord reads a DWORD from HKLM, returning def when it doesn't exist.
+func registryDWord(path, name string, def uint64) uint64 {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return def
+	}
+	defer key.Close()
+
+	v, _, err := key.GetIntegerValue(name)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// hasAnyPrefix reports whether s starts with any of the prefixes, ignoring case.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if len(s) >= len(p) && strings.EqualFold(s[:len(p)], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Problems lists the exposures worth flagging.
+func (r *RemoteAccessInfo) Problems() []string {
+	var problems []string
+	if r.RDPEnabled && !r.NLARequired {
+		problems = append(problems, "RDP without NLA")
+	}
+	return problems
+}
+
+// FormatRemoteAccessLines returns the remote access exposure as lines for display.
+func (r *RemoteAccessInfo) FormatRemoteAccessLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "Remote Access")
+	lines = append(lines, "")
+
+	if r.RDPEnabled {
+		nla := "NLA required"
+		if !r.NLARequired {
+			nla = "NLA OFF"
+		}
+		lines = append(lines, fmt.Sprintf("RDP: Enabled, port %d, %s", r.RDPPort, nla))
+	} else {
+		lines = append(lines, "RDP: Disabled")
+	}
+
+	if len(r.Tools) == 0 {
+		lines = append(lines, "Remote tools: None")
+		return lines
+	}
+	lines = append(lines, "Remote tools:")
+	for _, tool := range r.Tools {
+		state := "installed"
+		if tool.Running {
+			state = "running"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", tool.Name, state))
+	}
+	return lines
+}
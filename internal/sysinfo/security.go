@@ -0,0 +1,201 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Registry keys of the firewall profiles: the local settings and the Group
+// Policy ones that take precedence.
+const (
+	firewallPolicyKey      = `SYSTEM\CurrentControlSet\Services\SharedAccess\Parameters\FirewallPolicy`
+	firewallGroupPolicyKey = `SOFTWARE\Policies\Microsoft\WindowsFirewall`
+	secureBootStateKey     = `SYSTEM\CurrentControlSet\Control\SecureBoot\State`
+)
+
+// firewallProfiles maps the profile names to their local and policy subkeys;
+// the private profile is "Standard" in the local settings.
+var firewallProfiles = []struct {
+	Name, Local, Policy string
+}{
+	{"Domain", "DomainProfile", "DomainProfile"},
+	{"Private", "StandardProfile", "PrivateProfile"},
+	{"Public", "PublicProfile", "PublicProfile"},
+}
+
+// Win32_EncryptableVolume is used for the BitLocker protection query.
+type Win32_EncryptableVolume struct {
+	DriveLetter      string
+	ProtectionStatus uint32
+}
+
+// SecurityFactor is one check contributing to the security score.
+type SecurityFactor struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Unknown is true when the check could not be made; it then doesn't
+	// count towards the score.
+	Unknown bool   `json:"unknown,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// SecurityScore is the security posture of the machine summed up as a grade.
+type SecurityScore struct {
+	Factors []SecurityFactor `json:"factors"`
+}
+
+// GatherSecurityScore checks the antivirus, firewall, BitLocker, Windows
+// Update, Secure Boot and Remote Desktop settings. A check that fails is
+// recorded as unknown rather than failing the others.
+func GatherSecurityScore(ctx context.Context) *SecurityScore {
+	score := &SecurityScore{}
+
+	if enabled, product, err := AntivirusEnabled(ctx); err != nil {
+		score.add(unknownFactor("Antivirus"))
+	} else {
+		score.add(SecurityFactor{Name: "Antivirus", Passed: enabled, Detail: product})
+	}
+
+	score.add(firewallFactor())
+
+	if protected, err := systemDriveProtected(ctx); err != nil {
+		score.add(unknownFactor("BitLocker"))
+	} else {
+		score.add(SecurityFactor{Name: "BitLocker", Passed: protected, Detail: os.Getenv("SystemDrive")})
+	}
+
+	if pending, err := PendingUpdates(ctx); err != nil {
+		score.add(unknownFactor("Updates"))
+	} else {
+		f := SecurityFactor{Name: "Updates", Passed: pending == 0}
+		if pending > 0 {
+			f.Detail = fmt.Sprintf("%.0f pending", pending)
+		}
+		score.add(f)
+	}
+
+	switch registryDWord(secureBootStateKey, "UEFISecureBootEnabled", 2) {
+	case 1:
+		score.add(SecurityFactor{Name: "Secure Boot", Passed: true})
+	case 0:
+		score.add(SecurityFactor{Name: "Secure Boot", Detail: "off"})
+	default:
+		score.add(SecurityFactor{Name: "Secure Boot", Detail: "legacy BIOS"})
+	}
+
+	rdp, nla := rdpSettings()
+	switch {
+	case !rdp:
+		score.add(SecurityFactor{Name: "RDP NLA", Passed: true, Detail: "RDP off"})
+	case nla:
+		score.add(SecurityFactor{Name: "RDP NLA", Passed: true})
+	default:
+		score.add(SecurityFactor{Name: "RDP NLA", Detail: "not required"})
+	}
+
+	return score
+}
+
+func (s *SecurityScore) add(f SecurityFactor) {
+	s.Factors = append(s.Factors, f)
+}
+
+func unknownFactor(name string) SecurityFactor {
+	return SecurityFactor{Name: name, Unknown: true}
+}
+
+// firewallFactor passes when every firewall profile is on.
+func firewallFactor() SecurityFactor {
+	var off []string
+	for _, p := range firewallProfiles {
+		enabled := registryDWord(firewallPolicyKey+`\`+p.Local, "EnableFirewall", 1)
+		enabled = registryDWord(firewallGroupPolicyKey+`\`+p.Policy, "EnableFirewall", enabled)
+		if enabled == 0 {
+			off = append(off, p.Name)
+		}
+	}
+	if len(off) > 0 {
+		return SecurityFactor{Name: "Firewall", Detail: strings.Join(off, ", ") + " off"}
+	}
+	return SecurityFactor{Name: "Firewall", Passed: true}
+}
+
+// systemDriveProtected reports whether BitLocker protection is on for the
+// Windows drive.
+func systemDriveProtected(ctx context.Context) (bool, error) {
+	var volumes []Win32_EncryptableVolume
+	err := withContext(ctx, func() error {
+		return winapi.WMI.QueryNamespace("SELECT DriveLetter, ProtectionStatus FROM Win32_EncryptableVolume",
+			&volumes, `root\CIMV2\Security\MicrosoftVolumeEncryption`)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to query encryptable volumes: %v", err)
+	}
+	drive := os.Getenv("SystemDrive")
+	for _, v := range volumes {
+		if strings.EqualFold(v.DriveLetter, drive) {
+			return v.ProtectionStatus == 1, nil
+		}
+	}
+	return false, nil
+}
+
+// Score returns the percentage of the known factors that passed.
+func (s *SecurityScore) Score() int {
+	known, passed := 0, 0
+	for _, f := range s.Factors {
+		if f.Unknown {
+			continue
+		}
+		known++
+		if f.Passed {
+			passed++
+		}
+	}
+	if known == 0 {
+		return 0
+	}
+	return passed * 100 / known
+}
+
+// Grade returns the score as a letter: A from 90, B from 75, C from 60, D from
+// 40 and F below. With all six checks known, one failure is a B.
+func (s *SecurityScore) Grade() string {
+	score := s.Score()
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	}
+	return "F"
+}
+
+// FormatLines returns one line per factor, e.g. "Firewall: FAILED (Public
+// off)", shown beneath the grade.
+func (s *SecurityScore) FormatLines() []string {
+	var lines []string
+	for _, f := range s.Factors {
+		status := "OK"
+		switch {
+		case f.Unknown:
+			status = "unknown"
+		case !f.Passed:
+			status = "FAILED"
+		}
+		line := f.Name + ": " + status
+		if f.Detail != "" {
+			line += " (" + f.Detail + ")"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,93 @@
+package sysinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotFileName is the status file written to the data directory on every run.
+const SnapshotFileName = "status.json"
+
+// SnapshotVersion is the version of the status file format.
+const SnapshotVersion = 1
+
+// Snapshot is the status of a machine as collected by one run, with the full
+// (unredacted) values. It is what other tools consume and what
+// "bgStatusService --render-from" renders on another machine.
+type Snapshot struct {
+	Version     int              `json:"version"`
+	Hostname    string           `json:"hostname"`
+	CollectedAt time.Time        `json:"collected_at"`
+	System      *SystemInfo      `json:"system"`
+	Services    *ServicesSummary `json:"services,omitempty"`
+	// LeftSections and RightSections are the formatted calendar and widget lines
+	// added below the services and system info, blank separator lines included.
+	LeftSections  []string `json:"left_sections,omitempty"`
+	RightSections []string `json:"right_sections,omitempty"`
+	// Security is drawn as a badge when the security score was collected.
+	Security *SecurityScore `json:"security,omitempty"`
+}
+
+// NewSnapshot returns a snapshot of the collected information.
+func NewSnapshot(system *SystemInfo, services *ServicesSummary) *Snapshot {
+	return &Snapshot{
+		Version:     SnapshotVersion,
+		Hostname:    system.Hostname,
+		CollectedAt: time.Now(),
+		System:      system,
+		Services:    services,
+	}
+}
+
+// ServiceLines returns the left panel: the services, as FormatServiceLinesFor
+// shows them in mode, followed by the left sections.
+func (s *Snapshot) ServiceLines(mode string) []string {
+	var lines []string
+	if s.Services != nil {
+		lines = s.Services.FormatServiceLinesFor(mode)
+	}
+	if len(lines) == 0 && len(s.LeftSections) > 0 && s.LeftSections[0] == "" {
+		return s.LeftSections[1:]
+	}
+	return append(lines, s.LeftSections...)
+}
+
+// SameAddresses reports whether the addresses match those of a snapshot,
+// regardless of order.
+func (s *Snapshot) SameAddresses(addresses []string) bool {
+	return s.System != nil && sameAddresses(s.System.IPAddresses, addresses)
+}
+
+// Save writes the snapshot to dir/SnapshotFileName.
+func (s *Snapshot) Save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SnapshotFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write status: %v", err)
+	}
+	return nil
+}
+
+// ReadSnapshot reads a status file, e.g. one exported by another machine.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status: %v", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse status %s: %v", path, err)
+	}
+	if s.System == nil {
+		return nil, fmt.Errorf("status %s has no system information", path)
+	}
+	if s.Version > SnapshotVersion {
+		return nil, fmt.Errorf("status %s is format version %d, this build reads up to %d", path, s.Version, SnapshotVersion)
+	}
+	return &s, nil
+}
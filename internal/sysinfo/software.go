@@ -0,0 +1,196 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Win32_ServicePath is used for WMI query to find a service's executable.
+type Win32_ServicePath struct {
+	Name     string
+	State    string
+	PathName string
+}
+
+// SoftwareCheck is a program that must be installed, found by its service,
+// its executable or both.
+type SoftwareCheck struct {
+	Name    string
+	Service string
+	// Path is the executable, with environment variables such as
+	// %ProgramFiles% expanded.
+	Path string
+}
+
+// SoftwareStatus is the state of a required program.
+type SoftwareStatus struct {
+	Name      string
+	Installed bool
+	// Running is whether its service is running; always false when no
+	// service is checked.
+	Running    bool
+	HasService bool
+	// State is the service state when it isn't running, e.g. "Stopped".
+	State   string
+	Version string
+}
+
+// SoftwareInfo is the state of every required program, in configured order.
+type SoftwareInfo struct {
+	Software []SoftwareStatus
+}
+
+// GatherSoftware checks that each required program is installed, that its
+// service is running, and reads its file version from the executable (the
+// configured path, or else the service's).
+func GatherSoftware(ctx context.Context, checks []SoftwareCheck) (*SoftwareInfo, error) {
+	info := &SoftwareInfo{}
+
+	var services []Win32_ServicePath
+	var queryErr error
+	for _, check := range checks {
+		if check.Service != "" {
+			queryErr = queryWMI(ctx, "SELECT Name, State, PathName FROM Win32_Service", &services)
+			break
+		}
+	}
+	serviceMap := make(map[string]Win32_ServicePath)
+	for _, svc := range services {
+		serviceMap[strings.ToLower(svc.Name)] = svc
+	}
+
+	for _, check := range checks {
+		status := SoftwareStatus{Name: check.Name}
+		exe := ""
+		if check.Path != "" {
+			path := expandEnv(check.Path)
+			if _, err := os.Stat(path); err == nil {
+				status.Installed = true
+				exe = path
+			}
+		}
+		if check.Service != "" {
+			status.HasService = true
+			if svc, ok := serviceMap[strings.ToLower(check.Service)]; ok {
+				status.Installed = check.Path == "" || status.Installed
+				status.Running = svc.State == "Running"
+				status.State = svc.State
+				if exe == "" {
+					exe = serviceExecutable(svc.PathName)
+				}
+			} else {
+				status.Installed = false
+			}
+		}
+		if status.Installed && exe != "" {
+			status.Version = fileVersion(exe)
+		}
+		info.Software = append(info.Software, status)
+	}
+
+	if queryErr != nil {
+		return info, fmt.Errorf("failed to query services: %v", queryErr)
+	}
+	return info, nil
+}
+
+// expandEnv expands %VARIABLE% references in a path with
+// ExpandEnvironmentStrings.
+func expandEnv(path string) string {
+	from, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return path
+	}
+	n, err := windows.ExpandEnvironmentStrings(from, nil, 0)
+	if err != nil || n == 0 {
+		return path
+	}
+	buf := make([]uint16, n)
+	if _, err := windows.ExpandEnvironmentStrings(from, &buf[0], n); err != nil {
+		return path
+	}
+	return windows.UTF16ToString(buf)
+}
+
+// serviceExecutable returns the executable of a service's command line, e.g.
+// `"C:\Program Files\Agent\agent.exe" -service`.
+func serviceExecutable(commandLine string) string {
+	commandLine = strings.TrimSpace(commandLine)
+	if strings.HasPrefix(commandLine, `"`) {
+		if end := strings.Index(commandLine[1:], `"`); end >= 0 {
+			return commandLine[1 : end+1]
+		}
+		return ""
+	}
+	if i := strings.Index(strings.ToLower(commandLine), ".exe"); i >= 0 {
+		return expandEnv(commandLine[:i+4])
+	}
+	return ""
+}
+
+// fileVersion returns the file version of an executable, e.g. "7.10.18110.0",
+// or "" when it has none.
+func fileVersion(path string) string {
+	size, err := windows.GetFileVersionInfoSize(path, nil)
+	if err != nil || size == 0 {
+		return ""
+	}
+	buf := make([]byte, size)
+	if err := windows.GetFileVersionInfo(path, 0, size, unsafe.Pointer(&buf[0])); err != nil {
+		return ""
+	}
+	var fixed *windows.VS_FIXEDFILEINFO
+	var length uint32
+	if err := windows.VerQueryValue(unsafe.Pointer(&buf[0]), `\`, unsafe.Pointer(&fixed), &length); err != nil || fixed == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.%d",
+		fixed.FileVersionMS>>16, fixed.FileVersionMS&0xFFFF,
+		fixed.FileVersionLS>>16, fixed.FileVersionLS&0xFFFF)
+}
+
+// FormatSoftwareLines returns the required software as a checklist for
+// display, e.g. "CrowdStrike Falcon: Running, 7.10.18110.0".
+func (s *SoftwareInfo) FormatSoftwareLines() []string {
+	lines := []string{}
+	lines = append(lines, "Required Software")
+	lines = append(lines, "")
+
+	for _, sw := range s.Software {
+		var status string
+		switch {
+		case !sw.Installed:
+			status = "MISSING"
+		case sw.HasService && !sw.Running:
+			status = fmt.Sprintf("NOT RUNNING (%s)", sw.State)
+		case sw.HasService:
+			status = "Running"
+		default:
+			status = "Installed"
+		}
+		if sw.Installed && sw.Version != "" {
+			status += ", " + sw.Version
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", sw.Name, status))
+	}
+	return lines
+}
+
+// Problems lists the missing and stopped programs.
+func (s *SoftwareInfo) Problems() []string {
+	var problems []string
+	for _, sw := range s.Software {
+		switch {
+		case !sw.Installed:
+			problems = append(problems, sw.Name+" is not installed")
+		case sw.HasService && !sw.Running:
+			problems = append(problems, fmt.Sprintf("%s is not running (%s)", sw.Name, sw.State))
+		}
+	}
+	return problems
+}
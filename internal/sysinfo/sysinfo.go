@@ -2,6 +2,7 @@
 package sysinfo
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -13,8 +14,9 @@ import (
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/mem"
-	"github.com/yusufpapurcu/wmi"
 	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
 )
 
 // SystemInfo contains all gathered system information.
@@ -28,7 +30,20 @@ type SystemInfo struct {
 	DiskInfo     []string
 	SerialNumber string
 	Uptime       string
-	GeneratedAt  string
+	// UptimeSeconds is the uptime Uptime was formatted from.
+	UptimeSeconds uint64
+	GeneratedAt   string
+	// Status is an optional marker line, e.g. that cached values are shown.
+	Status string
+	// RebootAfterDays, when set, adds a reboot recommendation to the uptime
+	// line once the uptime reaches that many days. It comes from the config,
+	// not the machine, so it is not saved.
+	RebootAfterDays int `json:"-"`
+}
+
+// RebootRecommended reports whether the uptime has reached RebootAfterDays.
+func (s *SystemInfo) RebootRecommended() bool {
+	return s.RebootAfterDays > 0 && s.UptimeSeconds >= uint64(s.RebootAfterDays)*86400
 }
 
 // Win32_ComputerSystemProduct is used for WMI query to get serial number.
@@ -47,10 +62,15 @@ type Win32_VideoControllerResolution struct {
 	CurrentVerticalResolution   uint32
 }
 
-// DisplayResolution contains the current display resolution.
+// DisplayResolution contains the current display resolution and, when they can
+// be detected, the pixel density of the primary monitor.
 type DisplayResolution struct {
 	Width  int
 	Height int
+	// DPI is the effective DPI of the primary monitor (96 at 100% scaling), 0 if unknown.
+	DPI int
+	// DiagonalInches is the physical size reported by the monitor's EDID, 0 if unknown.
+	DiagonalInches float64
 }
 
 // Win32_Processor is used for WMI query to get detailed CPU info.
@@ -89,7 +109,8 @@ type ServicesSummary struct {
 }
 
 // Gather collects all system information and returns a SystemInfo struct.
-func Gather() (*SystemInfo, error) {
+// Returns ctx's error if it is cancelled before everything is collected.
+func Gather(ctx context.Context) (*SystemInfo, error) {
 	info := &SystemInfo{}
 
 	// Get hostname
@@ -101,32 +122,35 @@ func Gather() (*SystemInfo, error) {
 	}
 
 	// Get OS information
-	info.OS = getOSInfo()
+	info.OS = getOSInfo(ctx)
 
 	// Get CPU information
-	info.CPU = getCPUInfo()
+	info.CPU = getCPUInfo(ctx)
 
 	// Get RAM information
-	info.RAM = getRAMInfo()
+	info.RAM = getRAMInfo(ctx)
 
 	// Get GPU information
-	info.GPU = getGPUInfo()
+	info.GPU = getGPUInfo(ctx)
 
 	// Get IP addresses
 	info.IPAddresses = getIPAddresses()
 
 	// Get disk information
-	info.DiskInfo = getDiskInfo()
+	info.DiskInfo = getDiskInfo(ctx)
 
 	// Get serial number
-	info.SerialNumber = getSerialNumber()
+	info.SerialNumber = getSerialNumber(ctx)
 
 	// Get uptime
-	info.Uptime = getUptime()
+	info.Uptime, info.UptimeSeconds = getUptime(ctx)
 
 	// Get generation timestamp
 	info.GeneratedAt = time.Now().Format("Generated: Jan 2, 2006 3:04 PM")
 
+	if err := ctx.Err(); err != nil {
+		return info, err
+	}
 	return info, nil
 }
 
@@ -134,7 +158,9 @@ func Gather() (*SystemInfo, error) {
 func (s *SystemInfo) FormatLines() []string {
 	lines := []string{}
 
-	lines = append(lines, s.Hostname)
+	if s.Hostname != "" {
+		lines = append(lines, s.Hostname)
+	}
 	lines = append(lines, s.OS)
 	lines = append(lines, s.CPU)
 	lines = append(lines, s.RAM)
@@ -162,7 +188,11 @@ func (s *SystemInfo) FormatLines() []string {
 
 	// Add uptime
 	if s.Uptime != "" {
-		lines = append(lines, fmt.Sprintf("Uptime: %s", s.Uptime))
+		line := fmt.Sprintf("Uptime: %s", s.Uptime)
+		if s.RebootRecommended() {
+			line += " — reboot recommended"
+		}
+		lines = append(lines, line)
 	}
 
 	// Add generation timestamp
@@ -170,13 +200,17 @@ func (s *SystemInfo) FormatLines() []string {
 		lines = append(lines, s.GeneratedAt)
 	}
 
+	if s.Status != "" {
+		lines = append(lines, s.Status)
+	}
+
 	return lines
 }
 
-func getOSInfo() string {
+func getOSInfo(ctx context.Context) string {
 	// Use WMI to get the accurate OS caption (e.g., "Microsoft Windows 11 Pro")
 	var osInfo []Win32_OperatingSystem
-	err := wmi.Query("SELECT Caption FROM Win32_OperatingSystem", &osInfo)
+	err := queryWMI(ctx, "SELECT Caption FROM Win32_OperatingSystem", &osInfo)
 	if err == nil && len(osInfo) > 0 {
 		caption := osInfo[0].Caption
 		// Clean up the caption - remove "Microsoft " prefix for brevity
@@ -191,7 +225,7 @@ func getOSInfo() string {
 	}
 
 	// Fallback to gopsutil if WMI fails
-	hostInfo, err := host.Info()
+	hostInfo, err := host.InfoWithContext(ctx)
 	if err != nil {
 		return "Windows"
 	}
@@ -223,7 +257,7 @@ func getOSInfo() string {
 
 // getWindowsDisplayVersion gets the display version (e.g., "24H2") from registry
 func getWindowsDisplayVersion() string {
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE,
 		`SOFTWARE\Microsoft\Windows NT\CurrentVersion`,
 		registry.QUERY_VALUE)
 	if err != nil {
@@ -239,10 +273,10 @@ func getWindowsDisplayVersion() string {
 	return displayVersion
 }
 
-func getCPUInfo() string {
+func getCPUInfo(ctx context.Context) string {
 	// Try WMI first for more detailed info
 	var processors []Win32_Processor
-	err := wmi.Query("SELECT Name, NumberOfCores FROM Win32_Processor", &processors)
+	err := queryWMI(ctx, "SELECT Name, NumberOfCores FROM Win32_Processor", &processors)
 	if err == nil && len(processors) > 0 {
 		proc := processors[0]
 		// Clean up CPU name (remove extra spaces)
@@ -251,7 +285,7 @@ func getCPUInfo() string {
 	}
 
 	// Fallback to gopsutil
-	cpuInfo, err := cpu.Info()
+	cpuInfo, err := cpu.InfoWithContext(ctx)
 	if err != nil || len(cpuInfo) == 0 {
 		// Ultimate fallback
 		return fmt.Sprintf("CPU (%d cores)", runtime.NumCPU())
@@ -260,8 +294,8 @@ func getCPUInfo() string {
 	return fmt.Sprintf("%s (%d cores)", cpuInfo[0].ModelName, runtime.NumCPU())
 }
 
-func getRAMInfo() string {
-	memInfo, err := mem.VirtualMemory()
+func getRAMInfo(ctx context.Context) string {
+	memInfo, err := mem.VirtualMemoryWithContext(ctx)
 	if err != nil {
 		return "RAM: Unknown"
 	}
@@ -270,9 +304,9 @@ func getRAMInfo() string {
 	return fmt.Sprintf("%.0f GB RAM", totalGB)
 }
 
-func getGPUInfo() string {
+func getGPUInfo(ctx context.Context) string {
 	var controllers []Win32_VideoController
-	err := wmi.Query("SELECT Name FROM Win32_VideoController", &controllers)
+	err := queryWMI(ctx, "SELECT Name FROM Win32_VideoController", &controllers)
 	if err != nil || len(controllers) == 0 {
 		return "Unknown"
 	}
@@ -321,10 +355,10 @@ func getIPAddresses() []string {
 	return ips
 }
 
-func getDiskInfo() []string {
+func getDiskInfo(ctx context.Context) []string {
 	var diskLines []string
 
-	partitions, err := disk.Partitions(false)
+	partitions, err := disk.PartitionsWithContext(ctx, false)
 	if err != nil {
 		return diskLines
 	}
@@ -335,7 +369,7 @@ func getDiskInfo() []string {
 			continue
 		}
 
-		usage, err := disk.Usage(partition.Mountpoint)
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
 		if err != nil {
 			continue
 		}
@@ -366,9 +400,9 @@ func getDiskInfo() []string {
 	return diskLines
 }
 
-func getSerialNumber() string {
+func getSerialNumber(ctx context.Context) string {
 	var products []Win32_ComputerSystemProduct
-	err := wmi.Query("SELECT IdentifyingNumber FROM Win32_ComputerSystemProduct", &products)
+	err := queryWMI(ctx, "SELECT IdentifyingNumber FROM Win32_ComputerSystemProduct", &products)
 	if err != nil || len(products) == 0 {
 		return "Unknown"
 	}
@@ -382,10 +416,10 @@ func getSerialNumber() string {
 	return serial
 }
 
-func getUptime() string {
-	uptime, err := host.Uptime()
+func getUptime(ctx context.Context) (string, uint64) {
+	uptime, err := host.UptimeWithContext(ctx)
 	if err != nil {
-		return "Unknown"
+		return "Unknown", 0
 	}
 
 	// Convert seconds to days, hours, minutes
@@ -395,12 +429,12 @@ func getUptime() string {
 
 	// Format based on duration
 	if days > 0 {
-		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes), uptime
 	}
 	if hours > 0 {
-		return fmt.Sprintf("%dh %dm", hours, minutes)
+		return fmt.Sprintf("%dh %dm", hours, minutes), uptime
 	}
-	return fmt.Sprintf("%dm", minutes)
+	return fmt.Sprintf("%dm", minutes), uptime
 }
 
 // GetDisplayResolution queries the current display resolution from the system.
@@ -415,7 +449,7 @@ func GetDisplayResolution() DisplayResolution {
 		CurrentVerticalResolution   uint32
 	}
 
-	err := wmi.Query("SELECT CurrentHorizontalResolution, CurrentVerticalResolution FROM Win32_VideoController WHERE CurrentHorizontalResolution IS NOT NULL", &controllers)
+	err := winapi.WMI.Query("SELECT CurrentHorizontalResolution, CurrentVerticalResolution FROM Win32_VideoController WHERE CurrentHorizontalResolution IS NOT NULL", &controllers)
 	if err != nil || len(controllers) == 0 {
 		return defaultRes
 	}
@@ -424,8 +458,10 @@ func GetDisplayResolution() DisplayResolution {
 	for _, ctrl := range controllers {
 		if ctrl.CurrentHorizontalResolution > 0 && ctrl.CurrentVerticalResolution > 0 {
 			return DisplayResolution{
-				Width:  int(ctrl.CurrentHorizontalResolution),
-				Height: int(ctrl.CurrentVerticalResolution),
+				Width:          int(ctrl.CurrentHorizontalResolution),
+				Height:         int(ctrl.CurrentVerticalResolution),
+				DPI:            primaryMonitorDPI(),
+				DiagonalInches: primaryMonitorDiagonal(),
 			}
 		}
 	}
@@ -434,9 +470,9 @@ func GetDisplayResolution() DisplayResolution {
 }
 
 // isWindowsServer checks if the current OS is Windows Server.
-func isWindowsServer() bool {
+func isWindowsServer(ctx context.Context) bool {
 	var osInfo []Win32_OperatingSystem
-	err := wmi.Query("SELECT Caption FROM Win32_OperatingSystem", &osInfo)
+	err := queryWMI(ctx, "SELECT Caption FROM Win32_OperatingSystem", &osInfo)
 	if err != nil || len(osInfo) == 0 {
 		return false
 	}
@@ -479,14 +515,15 @@ func getCriticalServiceNames(isServer bool) []string {
 	return services
 }
 
-// GatherServices collects information about Windows services.
-func GatherServices() (*ServicesSummary, error) {
+// GatherServices collects information about Windows services. watched are
+// extra services listed with the critical ones, by service name.
+func GatherServices(ctx context.Context, watched ...string) (*ServicesSummary, error) {
 	summary := &ServicesSummary{}
-	summary.IsServer = isWindowsServer()
+	summary.IsServer = isWindowsServer(ctx)
 
 	// Query all services
 	var services []Win32_Service
-	err := wmi.Query("SELECT Name, State, StartMode FROM Win32_Service", &services)
+	err := queryWMI(ctx, "SELECT Name, State, StartMode FROM Win32_Service", &services)
 	if err != nil {
 		return summary, fmt.Errorf("failed to query services: %v", err)
 	}
@@ -531,6 +568,38 @@ func GatherServices() (*ServicesSummary, error) {
 		})
 	}
 
+	// Watched services are listed even when missing, since a missing agent is
+	// what the admin wants to see. Service names are case-insensitive.
+	listed := make(map[string]bool)
+	for _, name := range criticalNames {
+		listed[strings.ToLower(name)] = true
+	}
+	byLowerName := make(map[string]Win32_Service)
+	for _, svc := range services {
+		byLowerName[strings.ToLower(svc.Name)] = svc
+	}
+	for _, name := range watched {
+		if name == "" || listed[strings.ToLower(name)] {
+			continue
+		}
+		listed[strings.ToLower(name)] = true
+
+		svc, exists := byLowerName[strings.ToLower(name)]
+		if !exists {
+			summary.CriticalServices = append(summary.CriticalServices, ServiceStatus{
+				Name:  name,
+				State: "Not installed",
+				IsOK:  false,
+			})
+			continue
+		}
+		summary.CriticalServices = append(summary.CriticalServices, ServiceStatus{
+			Name:  svc.Name,
+			State: svc.State,
+			IsOK:  svc.State == "Running",
+		})
+	}
+
 	return summary, nil
 }
 
@@ -589,6 +658,36 @@ func (s *ServicesSummary) FormatServiceLines() []string {
 	return lines
 }
 
+// Healthy reports whether every critical service is OK and no auto-start
+// service has failed.
+func (s *ServicesSummary) Healthy() bool {
+	if len(s.FailedServices) > 0 {
+		return false
+	}
+	for _, svc := range s.CriticalServices {
+		if !svc.IsOK {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatServiceLinesFor returns the services panel for a display mode:
+// "summary" shrinks a healthy summary to a single OK line and "failures"
+// hides it. Anything else, or any problem, gives the full panel.
+func (s *ServicesSummary) FormatServiceLinesFor(mode string) []string {
+	if !s.Healthy() {
+		return s.FormatServiceLines()
+	}
+	switch mode {
+	case "summary":
+		return []string{"Services Status", "", fmt.Sprintf("All %d monitored services OK", s.TotalCount)}
+	case "failures":
+		return nil
+	}
+	return s.FormatServiceLines()
+}
+
 // getServiceDisplayName returns a friendly display name for common services.
 func getServiceDisplayName(serviceName string) string {
 	displayNames := map[string]string{
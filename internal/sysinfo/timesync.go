@@ -0,0 +1,162 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// DefaultMaxClockDrift is how far the clock may be off its time source before
+// it is flagged. Kerberos fails at 5 minutes; this warns well before.
+const DefaultMaxClockDrift = 30 * time.Second
+
+// timeSyncTimeout bounds the w32tm calls, which wait on the network.
+const timeSyncTimeout = 15 * time.Second
+
+// Lines of "w32tm /query /status", by position: the labels are localized.
+const (
+	w32tmStratumLine  = 1
+	w32tmLastSyncLine = 6
+	w32tmSourceLine   = 7
+)
+
+// unsyncedSources are what w32tm reports when no time source is used.
+var unsyncedSources = []string{"Local CMOS Clock", "Free-running System Clock"}
+
+// TimeSyncInfo is the state of Windows Time.
+type TimeSyncInfo struct {
+	Source  string
+	Stratum int
+	// LastSync is as w32tm shows it, in the system's date format.
+	LastSync string
+	// Drift is the clock's offset from Source; DriftKnown is false when the
+	// source could not be measured.
+	Drift      time.Duration
+	DriftKnown bool
+	MaxDrift   time.Duration
+}
+
+// GatherTimeSync reads the Windows Time source, stratum and last sync time and
+// measures the clock's offset from the source.
+func GatherTimeSync(ctx context.Context, maxDrift time.Duration) (*TimeSyncInfo, error) {
+	if maxDrift <= 0 {
+		maxDrift = DefaultMaxClockDrift
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeSyncTimeout)
+	defer cancel()
+
+	output, err := winapi.Commands.Run(ctx, "w32tm", "/query", "/status")
+	if err != nil {
+		return nil, fmt.Errorf("w32tm /query /status failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	info := &TimeSyncInfo{MaxDrift: maxDrift}
+	values := w32tmValues(string(output))
+	if len(values) > w32tmSourceLine {
+		info.Source = strings.TrimSpace(strings.SplitN(values[w32tmSourceLine], ",", 2)[0])
+		info.LastSync = values[w32tmLastSyncLine]
+		fields := strings.Fields(values[w32tmStratumLine])
+		if len(fields) > 0 {
+			info.Stratum, _ = strconv.Atoi(fields[0])
+		}
+	}
+
+	if info.Synchronized() {
+		output, err := winapi.Commands.Run(ctx, "w32tm", "/stripchart", "/computer:"+info.Source, "/samples:1", "/dataonly")
+		if err == nil {
+			info.Drift, info.DriftKnown = parseStripchart(string(output))
+		}
+	}
+	return info, nil
+}
+
+// w32tmValues returns the values of "Label: value" lines in order.
+func w32tmValues(output string) []string {
+	var values []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, ": "); i >= 0 {
+			values = append(values, strings.TrimSpace(line[i+2:]))
+		}
+	}
+	return values
+}
+
+// parseStripchart reads the offset from the last line of "w32tm /stripchart
+// /dataonly", e.g. "14:02:03, +00.0012345s".
+func parseStripchart(output string) (time.Duration, bool) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	i := strings.LastIndex(last, ", ")
+	if i < 0 {
+		return 0, false
+	}
+	value := strings.TrimSuffix(strings.TrimSpace(last[i+2:]), "s")
+	seconds, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// Synchronized reports whether the clock follows a time source.
+func (t *TimeSyncInfo) Synchronized() bool {
+	if t.Source == "" {
+		return false
+	}
+	for _, s := range unsyncedSources {
+		if strings.EqualFold(t.Source, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Problems lists what needs attention.
+func (t *TimeSyncInfo) Problems() []string {
+	var problems []string
+	if !t.Synchronized() {
+		problems = append(problems, "Clock not synchronized")
+	}
+	if t.DriftKnown && (t.Drift > t.MaxDrift || t.Drift < -t.MaxDrift) {
+		problems = append(problems, fmt.Sprintf("Clock off by %s", formatSkew(t.Drift.Round(time.Second))))
+	}
+	return problems
+}
+
+// FormatTimeSyncLines returns the time sync state as lines for display.
+func (t *TimeSyncInfo) FormatTimeSyncLines() []string {
+	lines := []string{}
+
+	lines = append(lines, "Time Sync")
+	lines = append(lines, "")
+
+	source := t.Source
+	if source == "" {
+		source = "Unknown"
+	}
+	lines = append(lines, fmt.Sprintf("Source: %s", source))
+	if t.Stratum > 0 {
+		lines = append(lines, fmt.Sprintf("Stratum: %d", t.Stratum))
+	}
+	if t.LastSync != "" {
+		lines = append(lines, fmt.Sprintf("Last sync: %s", t.LastSync))
+	}
+	if t.DriftKnown {
+		lines = append(lines, fmt.Sprintf("Offset: %s", formatSkew(t.Drift.Round(time.Millisecond))))
+	}
+
+	if problems := t.Problems(); len(problems) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, "Problems:")
+		for _, p := range problems {
+			lines = append(lines, "  "+p)
+		}
+	}
+	return lines
+}
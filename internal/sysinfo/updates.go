@@ -0,0 +1,36 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// pendingUpdatesScript counts the applicable software updates that are not
+// installed or hidden, from the Windows Update Agent's offline cache so the
+// count doesn't wait for an online scan.
+const pendingUpdatesScript = `$searcher = (New-Object -ComObject Microsoft.Update.Session).CreateUpdateSearcher()
+$searcher.Online = $false
+$searcher.Search("IsInstalled=0 and IsHidden=0 and Type='Software'").Updates.Count`
+
+// PendingUpdates returns the number of updates Windows Update found but has
+// not installed, as of its last scan.
+func PendingUpdates(ctx context.Context) (float64, error) {
+	output, err := winapi.Commands.Run(ctx, "powershell.exe",
+		"-NoProfile",
+		"-ExecutionPolicy", "Bypass",
+		"-Command", pendingUpdatesScript,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search for updates: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	text := strings.TrimSpace(string(output))
+	count, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse update count %q: %v", text, err)
+	}
+	return float64(count), nil
+}
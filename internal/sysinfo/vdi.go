@@ -0,0 +1,169 @@
+package sysinfo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// Kinds of virtual desktop DetectVDI recognizes.
+const (
+	VDICitrixPVS      = "Citrix PVS"
+	VDIFSLogix        = "FSLogix"
+	VDIWindowsSandbox = "Windows Sandbox"
+)
+
+const (
+	// pvsStackKey (HKLM) is the Citrix Provisioning Services network boot
+	// driver, present on every machine streamed from a vDisk.
+	pvsStackKey = `SYSTEM\CurrentControlSet\Services\bnistack`
+	// pvsPersonalityFile, on the system drive, describes the streamed vDisk.
+	pvsPersonalityFile = "Personality.ini"
+	// fslogixProfilesKey (HKLM) holds the FSLogix profile container settings.
+	fslogixProfilesKey = `SOFTWARE\FSLogix\Profiles`
+	// sandboxAccount is the account Windows Sandbox signs in as.
+	sandboxAccount = "WDAGUtilityAccount"
+	// currentVersionKey (HKLM) holds the Windows build.
+	currentVersionKey = `SOFTWARE\Microsoft\Windows NT\CurrentVersion`
+)
+
+// VDIInfo describes the virtual desktop environment the machine runs in.
+type VDIInfo struct {
+	// Kind is one of the VDI* constants, empty on an ordinary machine.
+	Kind string
+	// NonPersistent is true when changes to the system disk are lost at
+	// reboot: a PVS vDisk in standard image mode, a pooled FSLogix host or
+	// Windows Sandbox.
+	NonPersistent bool
+	// ImageName is the golden image, e.g. the PVS vDisk name.
+	ImageName string
+	// ImageVersion is read from the configured registry value.
+	ImageVersion string
+	// BaseBuild is the Windows build the image is based on, e.g. "19045.4291".
+	BaseBuild string
+}
+
+// DetectVDI detects Citrix PVS, FSLogix and Windows Sandbox.
+// imageVersionValue is the full path of a registry value holding the image
+// version, empty when there is none.
+func DetectVDI(imageVersionValue string) VDIInfo {
+	var info VDIInfo
+	systemDrive := os.Getenv("SystemDrive")
+	if systemDrive == "" {
+		systemDrive = "C:"
+	}
+
+	switch {
+	case fileExists(filepath.Join(systemDrive+`\`, "Users", sandboxAccount)):
+		info.Kind = VDIWindowsSandbox
+		info.NonPersistent = true
+	case registryKeyExists(pvsStackKey):
+		info.Kind = VDICitrixPVS
+		personality := readPersonality(filepath.Join(systemDrive+`\`, pvsPersonalityFile))
+		info.ImageName = personality["$DiskName"]
+		// Write cache type 0 is private image mode, where the vDisk itself is
+		// written to; every other type discards the writes at reboot
+		info.NonPersistent = personality["$WriteCacheType"] != "0"
+	case fslogixEnabled():
+		// Profile containers are how pooled hosts keep user data, so their
+		// system disk is assumed to be reset
+		info.Kind = VDIFSLogix
+		info.NonPersistent = true
+	}
+
+	if imageVersionValue != "" {
+		info.ImageVersion = registryPathValue(imageVersionValue)
+	}
+	info.BaseBuild = windowsBuild()
+	return info
+}
+
+// FormatVDILines formats the image line shown with the system information,
+// e.g. "Image: Win11-Golden 2024.06 (base build 22631.3737)". It is empty on an
+// ordinary machine.
+func (v VDIInfo) FormatVDILines() []string {
+	if v.Kind == "" {
+		return nil
+	}
+	image := v.Kind
+	if v.ImageName != "" {
+		image = v.ImageName
+	}
+	if v.ImageVersion != "" {
+		image += " " + v.ImageVersion
+	}
+	if v.BaseBuild != "" {
+		image += fmt.Sprintf(" (base build %s)", v.BaseBuild)
+	}
+
+	lines := []string{fmt.Sprintf("Image: %s", image)}
+	if v.NonPersistent {
+		lines = append(lines, fmt.Sprintf("%s: non-persistent", v.Kind))
+	} else {
+		lines = append(lines, fmt.Sprintf("%s: persistent", v.Kind))
+	}
+	return lines
+}
+
+// readPersonality reads the "name=value" lines of Personality.ini.
+func readPersonality(path string) map[string]string {
+	values := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// fslogixEnabled reports whether FSLogix profile containers are turned on.
+func fslogixEnabled() bool {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, fslogixProfilesKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	return readRegistryValue(key, "Enabled") == "1"
+}
+
+// windowsBuild returns the build and update revision, e.g. "22631.3737".
+func windowsBuild() string {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, currentVersionKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	build := readRegistryValue(key, "CurrentBuild")
+	if ubr := readRegistryValue(key, "UBR"); build != "" && ubr != "" {
+		build += "." + ubr
+	}
+	return build
+}
+
+func registryKeyExists(path string) bool {
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	key.Close()
+	return true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
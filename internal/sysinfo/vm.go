@@ -0,0 +1,283 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/winapi"
+	"golang.org/x/sys/windows/registry"
+)
+
+// metadataTimeout bounds each instance metadata request; the link-local
+// endpoints answer within milliseconds or not at all.
+const metadataTimeout = 2 * time.Second
+
+// Instance metadata endpoints.
+const (
+	azureMetadataURL = "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01"
+	awsTokenURL      = "http://169.254.169.254/latest/api/token"
+	awsIdentityURL   = "http://169.254.169.254/latest/dynamic/instance-identity/document"
+	gcpMetadataURL   = "http://metadata.google.internal/computeMetadata/v1/instance/?recursive=true"
+)
+
+// hyperVGuestKey (HKLM) is where Hyper-V integration services publish the
+// host's name to the guest.
+const hyperVGuestKey = `SOFTWARE\Microsoft\Virtual Machine\Guest\Parameters`
+
+// metadataClient talks to the link-local metadata services directly; a
+// configured proxy could not reach them.
+var metadataClient = &http.Client{
+	Timeout:   metadataTimeout,
+	Transport: &http.Transport{Proxy: nil},
+}
+
+// Win32_ComputerSystemModel is used for WMI query to get the vendor and model.
+type Win32_ComputerSystemModel struct {
+	Manufacturer string
+	Model        string
+}
+
+// VMInfo identifies the virtual machine and where it runs.
+type VMInfo struct {
+	// Platform is "Azure", "AWS", "Google Cloud", "Hyper-V", "VMware" or
+	// the vendor and model of another hypervisor.
+	Platform string
+	// InstanceID is the cloud instance or VM ID.
+	InstanceID string
+	Name       string
+	// Size is the instance type, e.g. "Standard_D4s_v5" or "t3.medium".
+	Size string
+	// Location is the region or zone, with the Azure resource group.
+	Location string
+	// Host is the Hyper-V host running the VM.
+	Host string
+	// Tools is the VMware Tools version.
+	Tools string
+}
+
+// GatherVM detects a hypervisor or cloud from the system vendor and model and
+// reads the instance metadata (Azure, AWS, Google Cloud), the Hyper-V host
+// name or the VMware Tools version. It returns nil on physical hardware.
+func GatherVM(ctx context.Context) (*VMInfo, error) {
+	var systems []Win32_ComputerSystemModel
+	if err := queryWMI(ctx, "SELECT Manufacturer, Model FROM Win32_ComputerSystem", &systems); err != nil {
+		return nil, fmt.Errorf("failed to read the system model: %v", err)
+	}
+	if len(systems) == 0 {
+		return nil, nil
+	}
+	manufacturer := strings.TrimSpace(systems[0].Manufacturer)
+	model := strings.TrimSpace(systems[0].Model)
+	vendor := strings.ToLower(manufacturer + " " + model)
+
+	switch {
+	case strings.Contains(vendor, "microsoft") && strings.Contains(vendor, "virtual"):
+		if info, err := azureMetadata(ctx); err == nil {
+			return info, nil
+		}
+		return hyperVInfo(), nil
+	case strings.Contains(vendor, "amazon") || strings.Contains(vendor, "xen"):
+		if info, err := awsMetadata(ctx); err == nil {
+			return info, nil
+		}
+		if strings.Contains(vendor, "amazon") {
+			return &VMInfo{Platform: "AWS"}, nil
+		}
+	case strings.Contains(vendor, "google"):
+		if info, err := gcpMetadata(ctx); err == nil {
+			return info, nil
+		}
+		return &VMInfo{Platform: "Google Cloud"}, nil
+	case strings.Contains(vendor, "vmware"):
+		return &VMInfo{Platform: "VMware", Tools: vmwareToolsVersion(ctx)}, nil
+	}
+
+	for _, hint := range []string{"virtual", "kvm", "qemu", "xen", "parallels", "bochs"} {
+		if strings.Contains(vendor, hint) {
+			return &VMInfo{Platform: strings.TrimSpace(manufacturer + " " + model)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// FormatVMLines returns the VM identity for the system info panel, e.g.
+// "Azure VM: web01 (Standard_D4s_v5)" and "Region: westeurope / rg-web".
+func (v *VMInfo) FormatVMLines() []string {
+	var lines []string
+	title := v.Platform + " VM"
+	switch {
+	case v.Name != "" && v.Size != "":
+		title += fmt.Sprintf(": %s (%s)", v.Name, v.Size)
+	case v.Name != "":
+		title += ": " + v.Name
+	case v.Size != "":
+		title += ": " + v.Size
+	}
+	lines = append(lines, title)
+	if v.InstanceID != "" {
+		lines = append(lines, "Instance: "+v.InstanceID)
+	}
+	if v.Location != "" {
+		lines = append(lines, "Region: "+v.Location)
+	}
+	if v.Host != "" {
+		lines = append(lines, "Host: "+v.Host)
+	}
+	if v.Tools != "" {
+		lines = append(lines, "VMware Tools: "+v.Tools)
+	}
+	return lines
+}
+
+// getMetadata GETs a metadata URL with the given headers and decodes the
+// JSON response into dst.
+func getMetadata(ctx context.Context, url string, headers map[string]string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("metadata service returned %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
+
+// azureMetadata reads the VM from the Azure Instance Metadata Service.
+func azureMetadata(ctx context.Context) (*VMInfo, error) {
+	var compute struct {
+		Name              string `json:"name"`
+		VMID              string `json:"vmId"`
+		VMSize            string `json:"vmSize"`
+		Location          string `json:"location"`
+		Zone              string `json:"zone"`
+		ResourceGroupName string `json:"resourceGroupName"`
+	}
+	if err := getMetadata(ctx, azureMetadataURL, map[string]string{"Metadata": "true"}, &compute); err != nil {
+		return nil, err
+	}
+	location := compute.Location
+	if compute.Zone != "" {
+		location += " zone " + compute.Zone
+	}
+	if compute.ResourceGroupName != "" {
+		location += " / " + compute.ResourceGroupName
+	}
+	return &VMInfo{
+		Platform:   "Azure",
+		InstanceID: compute.VMID,
+		Name:       compute.Name,
+		Size:       compute.VMSize,
+		Location:   location,
+	}, nil
+}
+
+// awsMetadata reads the instance identity document with an IMDSv2 session
+// token, which works whether or not IMDSv1 is disabled.
+func awsMetadata(ctx context.Context) (*VMInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, awsTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	token, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata token request returned %s", resp.Status)
+	}
+
+	var identity struct {
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": strings.TrimSpace(string(token))}
+	if err := getMetadata(ctx, awsIdentityURL, headers, &identity); err != nil {
+		return nil, err
+	}
+	location := identity.AvailabilityZone
+	if location == "" {
+		location = identity.Region
+	}
+	return &VMInfo{
+		Platform:   "AWS",
+		InstanceID: identity.InstanceID,
+		Size:       identity.InstanceType,
+		Location:   location,
+	}, nil
+}
+
+// gcpMetadata reads the instance from the Compute Engine metadata server.
+func gcpMetadata(ctx context.Context) (*VMInfo, error) {
+	var instance struct {
+		ID          json.Number `json:"id"`
+		Name        string      `json:"name"`
+		MachineType string      `json:"machineType"`
+		Zone        string      `json:"zone"`
+	}
+	if err := getMetadata(ctx, gcpMetadataURL, map[string]string{"Metadata-Flavor": "Google"}, &instance); err != nil {
+		return nil, err
+	}
+	// machineType and zone are resource paths such as
+	// projects/123/machineTypes/e2-medium
+	return &VMInfo{
+		Platform:   "Google Cloud",
+		InstanceID: instance.ID.String(),
+		Name:       instance.Name,
+		Size:       instance.MachineType[strings.LastIndex(instance.MachineType, "/")+1:],
+		Location:   instance.Zone[strings.LastIndex(instance.Zone, "/")+1:],
+	}, nil
+}
+
+// hyperVInfo reads the VM and host names that Hyper-V integration services
+// publish to the guest (the data exchange service must be enabled).
+func hyperVInfo() *VMInfo {
+	info := &VMInfo{Platform: "Hyper-V"}
+	key, err := winapi.Reg.OpenKey(registry.LOCAL_MACHINE, hyperVGuestKey, registry.QUERY_VALUE)
+	if err != nil {
+		return info
+	}
+	defer key.Close()
+	info.Name = readRegistryValue(key, "VirtualMachineName")
+	info.Host = readRegistryValue(key, "PhysicalHostNameFullyQualified")
+	if info.Host == "" {
+		info.Host = readRegistryValue(key, "HostName")
+	}
+	return info
+}
+
+// vmwareToolsVersion returns the installed VMware Tools version, or "" when
+// the tools are missing.
+func vmwareToolsVersion(ctx context.Context) string {
+	toolbox := filepath.Join(os.Getenv("ProgramFiles"), "VMware", "VMware Tools", "VMwareToolboxCmd.exe")
+	if _, err := os.Stat(toolbox); err != nil {
+		return ""
+	}
+	output, err := winapi.Commands.Run(ctx, toolbox, "-v")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,97 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// vpnAdapterNames are parts of the adapter descriptions of VPN clients that
+// bring their own virtual adapter. Built-in Windows VPN connections are PPP
+// adapters and are found by type.
+var vpnAdapterNames = []string{
+	"anyconnect",
+	"pangp", // GlobalProtect
+	"globalprotect",
+	"fortinet",
+	"wireguard",
+	"tap-windows", // OpenVPN
+	"openvpn",
+	"juniper",
+	"pulse secure",
+	"ivanti",
+	"check point",
+	"sonicwall",
+	"zscaler",
+	"tailscale",
+	"zerotier",
+}
+
+// VPNInfo lists the connected VPN adapters.
+type VPNInfo struct {
+	// Connections are the names of the connected VPN adapters.
+	Connections []string
+}
+
+// GatherVPN finds the connected VPN adapters: Windows VPN (PPP) connections
+// and the virtual adapters of common VPN clients.
+func GatherVPN(ctx context.Context) (*VPNInfo, error) {
+	info := &VPNInfo{}
+	err := withContext(ctx, func() error {
+		size := uint32(15000)
+		var buf []byte
+		for {
+			buf = make([]byte, size)
+			err := windows.GetAdaptersAddresses(windows.AF_UNSPEC,
+				windows.GAA_FLAG_SKIP_ANYCAST|windows.GAA_FLAG_SKIP_MULTICAST|windows.GAA_FLAG_SKIP_DNS_SERVER,
+				0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+			if err == nil {
+				break
+			}
+			if err != windows.ERROR_BUFFER_OVERFLOW {
+				return fmt.Errorf("failed to list network adapters: %v", err)
+			}
+		}
+
+		for a := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); a != nil; a = a.Next {
+			if a.OperStatus != windows.IfOperStatusUp {
+				continue
+			}
+			name := windows.UTF16PtrToString(a.FriendlyName)
+			description := windows.UTF16PtrToString(a.Description)
+			if a.IfType == windows.IF_TYPE_PPP || hasVPNName(description) {
+				info.Connections = append(info.Connections, name)
+			}
+		}
+		return nil
+	})
+	return info, err
+}
+
+// hasVPNName reports whether an adapter description is a VPN client's.
+func hasVPNName(description string) bool {
+	description = strings.ToLower(description)
+	for _, name := range vpnAdapterNames {
+		if strings.Contains(description, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatVPNLines returns the VPN state as lines for display.
+func (v *VPNInfo) FormatVPNLines() []string {
+	lines := []string{}
+	lines = append(lines, "VPN")
+	lines = append(lines, "")
+
+	if len(v.Connections) == 0 {
+		lines = append(lines, "Not connected")
+		return lines
+	}
+	lines = append(lines, fmt.Sprintf("Connected: %s", strings.Join(v.Connections, ", ")))
+	return lines
+}
@@ -0,0 +1,328 @@
+// Package thresholds evaluates the configured threshold rules against the
+// collected values. The resulting severity decides, in one place, the word
+// shown on the login screen (which picks its color), the event log ID and
+// whether the webhook is told about a change.
+package thresholds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// StateFileName keeps the severities last posted to the webhook.
+const StateFileName = "thresholds.json"
+
+// WebhookTimeout bounds one webhook POST.
+const WebhookTimeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: WebhookTimeout}
+
+// Severity is the outcome of a rule, ordered from OK to Critical.
+type Severity int
+
+const (
+	OK Severity = iota
+	Warning
+	Critical
+)
+
+// Event log IDs of breached rules. Everything else BgStatusService logs
+// uses ID 1.
+const (
+	EventIDWarning  uint32 = 2
+	EventIDCritical uint32 = 3
+)
+
+// String returns "ok", "warning" or "critical".
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	}
+	return "ok"
+}
+
+// Marker returns the word shown on the login screen, "WARNING" or
+// "CRITICAL", which the overlay draws in the warning or alert color.
+func (s Severity) Marker() string {
+	return strings.ToUpper(s.String())
+}
+
+// EventID returns the event log ID for a breached rule of this severity.
+func (s Severity) EventID() uint32 {
+	if s == Critical {
+		return EventIDCritical
+	}
+	return EventIDWarning
+}
+
+// Result is a rule evaluated against its value.
+type Result struct {
+	Rule     config.ThresholdRule
+	Value    float64
+	Severity Severity
+}
+
+// Label returns the rule's label, or its metric.
+func (r Result) Label() string {
+	if r.Rule.Label != "" {
+		return r.Rule.Label
+	}
+	return r.Rule.Metric
+}
+
+// Line returns the login screen line, e.g. "uptime_days: 35 WARNING (> 30)".
+func (r Result) Line() string {
+	limit := r.Rule.Warn
+	if r.Severity == Critical {
+		limit = r.Rule.Crit
+	}
+	return fmt.Sprintf("%s: %s %s (%s %s)", r.Label(), formatValue(r.Value), r.Severity.Marker(),
+		comparator(r.Rule), formatValue(*limit))
+}
+
+// Evaluate checks each rule against values, keyed by metric. Rules whose
+// metric has no value or whose comparator is unknown are reported as
+// problems and skipped.
+func Evaluate(rules []config.ThresholdRule, values map[string]float64) ([]Result, []string) {
+	var results []Result
+	var problems []string
+	for _, rule := range rules {
+		value, ok := values[strings.ToLower(rule.Metric)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("no value for %q", rule.Metric))
+			continue
+		}
+		breached, err := compare(comparator(rule))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", rule.Metric, err))
+			continue
+		}
+		result := Result{Rule: rule, Value: value}
+		switch {
+		case rule.Crit != nil && breached(value, *rule.Crit):
+			result.Severity = Critical
+		case rule.Warn != nil && breached(value, *rule.Warn):
+			result.Severity = Warning
+		}
+		results = append(results, result)
+	}
+	return results, problems
+}
+
+// Metrics returns the metrics the rules use, lower case.
+func Metrics(rules []config.ThresholdRule) map[string]bool {
+	metrics := make(map[string]bool)
+	for _, rule := range rules {
+		metrics[strings.ToLower(rule.Metric)] = true
+	}
+	return metrics
+}
+
+// Worst returns the highest severity of the results.
+func Worst(results []Result) Severity {
+	worst := OK
+	for _, r := range results {
+		if r.Severity > worst {
+			worst = r.Severity
+		}
+	}
+	return worst
+}
+
+// Breached returns the results that are not OK, critical ones first.
+func Breached(results []Result) []Result {
+	var breached []Result
+	for _, r := range results {
+		if r.Severity != OK {
+			breached = append(breached, r)
+		}
+	}
+	sort.SliceStable(breached, func(i, j int) bool { return breached[i].Severity > breached[j].Severity })
+	return breached
+}
+
+// FormatLines returns the breached rules for display, or nil when every
+// rule is OK.
+func FormatLines(results []Result) []string {
+	breached := Breached(results)
+	if len(breached) == 0 {
+		return nil
+	}
+	lines := []string{"Thresholds", ""}
+	for _, r := range breached {
+		lines = append(lines, r.Line())
+	}
+	return lines
+}
+
+// comparator returns the rule's comparator, ">" by default.
+func comparator(rule config.ThresholdRule) string {
+	if c := strings.TrimSpace(rule.Comparator); c != "" {
+		return c
+	}
+	return ">"
+}
+
+// ValidateRule checks that a rule has a metric, a known comparator and at
+// least one limit.
+func ValidateRule(rule config.ThresholdRule) error {
+	if strings.TrimSpace(rule.Metric) == "" {
+		return fmt.Errorf("metric is required")
+	}
+	if _, err := compare(comparator(rule)); err != nil {
+		return err
+	}
+	if rule.Warn == nil && rule.Crit == nil {
+		return fmt.Errorf("rule for %s has neither warn nor crit", rule.Metric)
+	}
+	return nil
+}
+
+// compare returns the test for a comparator.
+func compare(op string) (func(value, limit float64) bool, error) {
+	switch op {
+	case ">":
+		return func(v, l float64) bool { return v > l }, nil
+	case ">=":
+		return func(v, l float64) bool { return v >= l }, nil
+	case "<":
+		return func(v, l float64) bool { return v < l }, nil
+	case "<=":
+		return func(v, l float64) bool { return v <= l }, nil
+	}
+	return nil, fmt.Errorf("unknown comparator %q (use >, >=, < or <=)", op)
+}
+
+// formatValue prints whole numbers without decimals and others with one.
+func formatValue(v float64) string {
+	if v == float64(int64(v)) {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// state is what was last posted to the webhook.
+type state struct {
+	Severity string   `json:"severity"`
+	Breached []string `json:"breached,omitempty"`
+}
+
+// WebhookBreach is one breached rule in a webhook payload.
+type WebhookBreach struct {
+	Metric   string  `json:"metric"`
+	Label    string  `json:"label"`
+	Value    float64 `json:"value"`
+	Severity string  `json:"severity"`
+	Line     string  `json:"line"`
+}
+
+// WebhookPayload is the JSON posted to the webhook.
+type WebhookPayload struct {
+	Hostname         string          `json:"hostname"`
+	Time             time.Time       `json:"time"`
+	Severity         string          `json:"severity"`
+	PreviousSeverity string          `json:"previous_severity"`
+	Breached         []WebhookBreach `json:"breached"`
+	// Text is a one-line summary for chat webhooks that show "text".
+	Text string `json:"text"`
+}
+
+// Notify posts the results to the webhook when the worst severity or the set
+// of breached rules changed since the last successful post, recorded in dir.
+// It returns whether a post was made.
+func Notify(ctx context.Context, cfg config.ThresholdsConfig, dir, hostname string, results []Result) (bool, error) {
+	if cfg.WebhookURL == "" {
+		return false, nil
+	}
+	statePath := filepath.Join(dir, StateFileName)
+	previous := state{Severity: OK.String()}
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, &previous)
+	}
+
+	breached := Breached(results)
+	current := state{Severity: Worst(results).String()}
+	for _, r := range breached {
+		current.Breached = append(current.Breached, r.Rule.Metric+"="+r.Severity.String())
+	}
+	sort.Strings(current.Breached)
+	if current.Severity == previous.Severity && strings.Join(current.Breached, ",") == strings.Join(previous.Breached, ",") {
+		return false, nil
+	}
+
+	payload := WebhookPayload{
+		Hostname:         hostname,
+		Time:             time.Now().UTC(),
+		Severity:         current.Severity,
+		PreviousSeverity: previous.Severity,
+		Breached:         []WebhookBreach{},
+	}
+	var lines []string
+	for _, r := range breached {
+		payload.Breached = append(payload.Breached, WebhookBreach{
+			Metric:   r.Rule.Metric,
+			Label:    r.Label(),
+			Value:    r.Value,
+			Severity: r.Severity.String(),
+			Line:     r.Line(),
+		})
+		lines = append(lines, r.Line())
+	}
+	if len(lines) == 0 {
+		payload.Text = fmt.Sprintf("%s: all thresholds OK", hostname)
+	} else {
+		payload.Text = fmt.Sprintf("%s: %s", hostname, strings.Join(lines, "; "))
+	}
+
+	if err := post(ctx, cfg, payload); err != nil {
+		return false, err
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return true, fmt.Errorf("failed to encode threshold state: %w", err)
+	}
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		return true, fmt.Errorf("failed to save threshold state: %w", err)
+	}
+	return true, nil
+}
+
+// post sends the payload as JSON with the configured headers.
+func post(ctx context.Context, cfg config.ThresholdsConfig, payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cfg.WebhookHeaders {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("webhook returned " + resp.Status)
+	}
+	return nil
+}
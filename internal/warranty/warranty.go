@@ -0,0 +1,280 @@
+// Package warranty looks up a computer's warranty end date with the vendor's
+// warranty API (Dell, Lenovo and HP) and caches it, since warranties rarely change
+// and the APIs are rate limited.
+package warranty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// CacheFileName is the lookup cache in the data directory.
+const CacheFileName = "warranty.json"
+
+// CacheDuration is how long a lookup is reused.
+const CacheDuration = 30 * 24 * time.Hour
+
+// HTTPTimeout bounds every request to a warranty API.
+const HTTPTimeout = 30 * time.Second
+
+// Vendor API endpoints.
+const (
+	dellTokenURL       = "https://apigtwb2c.us.dell.com/auth/oauth/v2/token"
+	dellEntitlementURL = "https://apigtwb2c.us.dell.com/PROD/sbil/eapi/v5/asset-entitlements"
+	lenovoWarrantyURL  = "https://supportapi.lenovo.com/v2.5/warranty"
+	hpTokenURL         = "https://warranty.api.hp.com/oauth/v1/token"
+	hpQueryURL         = "https://warranty.api.hp.com/productwarranty/v2/queries"
+)
+
+var httpClient = &http.Client{Timeout: HTTPTimeout}
+
+// Result is a looked-up warranty.
+type Result struct {
+	Vendor    string    `json:"vendor"`
+	Serial    string    `json:"serial"`
+	EndDate   time.Time `json:"end_date"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Expired reports whether the warranty has ended.
+func (r *Result) Expired() bool {
+	return time.Now().After(r.EndDate)
+}
+
+// Line returns the login screen line, e.g. "Warranty: expires 2026-03-02".
+func (r *Result) Line() string {
+	date := r.EndDate.Format("2006-01-02")
+	if r.Expired() {
+		return "Warranty: EXPIRED " + date
+	}
+	return "Warranty: expires " + date
+}
+
+// Lookup returns the warranty end date for the serial from the vendor's API,
+// or from the cache in cacheDir when it was looked up in the last 30 days.
+// product is the product number HP uses to tell serials apart and may be
+// empty. Vendors without configured API keys return nil.
+func Lookup(ctx context.Context, cfg config.WarrantyConfig, manufacturer, serial, product, cacheDir string) (*Result, error) {
+	vendor := vendorOf(manufacturer)
+	if vendor == "" || serial == "" || serial == "Unknown" {
+		return nil, nil
+	}
+
+	cachePath := filepath.Join(cacheDir, CacheFileName)
+	var cached Result
+	if data, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(data, &cached) == nil {
+		if cached.Serial == serial && time.Since(cached.CheckedAt) < CacheDuration {
+			return &cached, nil
+		}
+	}
+
+	var end time.Time
+	var err error
+	switch vendor {
+	case "Dell":
+		if cfg.DellClientID == "" || cfg.DellClientSecret == "" {
+			return nil, nil
+		}
+		end, err = lookupDell(ctx, cfg, serial)
+	case "Lenovo":
+		if cfg.LenovoClientID == "" {
+			return nil, nil
+		}
+		end, err = lookupLenovo(ctx, cfg, serial)
+	case "HP":
+		if cfg.HPAPIKey == "" || cfg.HPAPISecret == "" {
+			return nil, nil
+		}
+		end, err = lookupHP(ctx, cfg, serial, product)
+	}
+	if err != nil {
+		// A stale result beats none while the API is unreachable
+		if cached.Serial == serial && !cached.EndDate.IsZero() {
+			return &cached, err
+		}
+		return nil, err
+	}
+
+	result := &Result{Vendor: vendor, Serial: serial, EndDate: end, CheckedAt: time.Now()}
+	if data, err := json.MarshalIndent(result, "", "  "); err == nil {
+		os.MkdirAll(cacheDir, 0755)
+		os.WriteFile(cachePath, data, 0644)
+	}
+	return result, nil
+}
+
+// vendorOf maps a WMI manufacturer to a supported vendor, or "".
+func vendorOf(manufacturer string) string {
+	m := strings.ToLower(manufacturer)
+	switch {
+	case strings.HasPrefix(m, "dell"):
+		return "Dell"
+	case strings.HasPrefix(m, "lenovo"):
+		return "Lenovo"
+	case m == "hp" || strings.HasPrefix(m, "hp ") || strings.HasPrefix(m, "hewlett"):
+		return "HP"
+	}
+	return ""
+}
+
+// lookupDell gets an OAuth token for the TechDirect API key and returns the
+// latest entitlement end date of the service tag.
+func lookupDell(ctx context.Context, cfg config.WarrantyConfig, serviceTag string) (time.Time, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {cfg.DellClientID},
+		"client_secret": {cfg.DellClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", dellTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(req, &token); err != nil {
+		return time.Time{}, fmt.Errorf("dell token: %w", err)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", dellEntitlementURL+"?servicetags="+url.QueryEscape(serviceTag), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	var assets []struct {
+		Entitlements []struct {
+			EndDate time.Time `json:"endDate"`
+		} `json:"entitlements"`
+	}
+	if err := doJSON(req, &assets); err != nil {
+		return time.Time{}, fmt.Errorf("dell entitlements: %w", err)
+	}
+
+	var end time.Time
+	for _, asset := range assets {
+		for _, e := range asset.Entitlements {
+			if e.EndDate.After(end) {
+				end = e.EndDate
+			}
+		}
+	}
+	if end.IsZero() {
+		return end, fmt.Errorf("dell: no entitlements for %s", serviceTag)
+	}
+	return end, nil
+}
+
+// lookupLenovo returns the latest warranty end date of the serial from the
+// Lenovo support API.
+func lookupLenovo(ctx context.Context, cfg config.WarrantyConfig, serial string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", lenovoWarrantyURL+"?Serial="+url.QueryEscape(serial), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("ClientID", cfg.LenovoClientID)
+
+	var result struct {
+		Warranty []struct {
+			End time.Time `json:"End"`
+		} `json:"Warranty"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return time.Time{}, fmt.Errorf("lenovo warranty: %w", err)
+	}
+
+	var end time.Time
+	for _, w := range result.Warranty {
+		if w.End.After(end) {
+			end = w.End
+		}
+	}
+	if end.IsZero() {
+		return end, fmt.Errorf("lenovo: no warranty for %s", serial)
+	}
+	return end, nil
+}
+
+// lookupHP gets an OAuth token for the HP Warranty API key and returns the
+// latest end date of the serial's warranty offers. The product number is sent
+// when known; HP needs it for serials shared by several products.
+func lookupHP(ctx context.Context, cfg config.WarrantyConfig, serial, product string) (time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, "POST", hpTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.SetBasicAuth(cfg.HPAPIKey, cfg.HPAPISecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(req, &token); err != nil {
+		return time.Time{}, fmt.Errorf("hp token: %w", err)
+	}
+
+	type query struct {
+		SN string `json:"sn"`
+		PN string `json:"pn,omitempty"`
+	}
+	body, err := json.Marshal([]query{{SN: serial, PN: product}})
+	if err != nil {
+		return time.Time{}, err
+	}
+	req, err = http.NewRequestWithContext(ctx, "POST", hpQueryURL, bytes.NewReader(body))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	var products []struct {
+		Offers []struct {
+			EndDate string `json:"serviceObligationLineItemEndDate"`
+		} `json:"offers"`
+	}
+	if err := doJSON(req, &products); err != nil {
+		return time.Time{}, fmt.Errorf("hp warranty: %w", err)
+	}
+
+	var end time.Time
+	for _, p := range products {
+		for _, offer := range p.Offers {
+			date, err := time.Parse("2006-01-02", offer.EndDate)
+			if err == nil && date.After(end) {
+				end = date
+			}
+		}
+	}
+	if end.IsZero() {
+		return end, fmt.Errorf("hp: no warranty for %s", serial)
+	}
+	return end, nil
+}
+
+// doJSON sends a request and decodes a JSON response into v.
+func doJSON(req *http.Request, v interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
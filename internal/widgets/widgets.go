@@ -0,0 +1,242 @@
+// Package widgets formats the simple informational widgets configured in the
+// layout file (countdowns, on-call rotation lines and key/value tables).
+package widgets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/backgroundchanger/internal/config"
+)
+
+// fetchTimeout bounds the on-call endpoint request so a slow endpoint doesn't
+// delay the login screen update.
+const fetchTimeout = 15 * time.Second
+
+// placeholder matches {field} or {dotted.path} in an on-call format string.
+var placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// FormatLines returns the lines for a single widget, including its title.
+// On error the returned lines still describe the failure so the panel isn't silently empty.
+func FormatLines(ctx context.Context, w config.WidgetConfig, now time.Time) ([]string, error) {
+	var body []string
+	var err error
+
+	switch strings.ToLower(w.Type) {
+	case config.WidgetCountdown:
+		body, err = countdownLines(w, now)
+	case config.WidgetOnCall:
+		body, err = onCallLines(ctx, w)
+	case config.WidgetTable:
+		body = tableLines(w)
+	default:
+		return nil, fmt.Errorf("unknown widget type %q", w.Type)
+	}
+
+	lines := []string{}
+	if w.Title != "" {
+		lines = append(lines, w.Title)
+	}
+	return append(lines, body...), err
+}
+
+// Validate checks a widget without fetching anything: its type and panel, the
+// countdown date and the on-call url and format.
+func Validate(w config.WidgetConfig) error {
+	switch strings.ToLower(w.Panel) {
+	case "", config.PanelLeft, config.PanelRight:
+	default:
+		return fmt.Errorf("unknown panel %q (use left or right)", w.Panel)
+	}
+
+	switch strings.ToLower(w.Type) {
+	case config.WidgetCountdown:
+		_, err := parseDate(w.Date)
+		return err
+	case config.WidgetOnCall:
+		if w.URL == "" {
+			return fmt.Errorf("on-call widget has no url")
+		}
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid url %q (expected http:// or https://)", w.URL)
+		}
+		if rest := placeholder.ReplaceAllString(w.Format, ""); strings.ContainsAny(rest, "{}") {
+			return fmt.Errorf("format %q has an unmatched or empty {placeholder}", w.Format)
+		}
+		return nil
+	case config.WidgetTable:
+		return nil
+	}
+	return fmt.Errorf("unknown widget type %q", w.Type)
+}
+
+// parseDate accepts a plain date ("2006-01-02") in local time or an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC 3339)", value)
+	}
+	return t.Local(), nil
+}
+
+// countdownLines formats e.g. "14 days until migration cutover".
+func countdownLines(w config.WidgetConfig, now time.Time) ([]string, error) {
+	target, err := parseDate(w.Date)
+	if err != nil {
+		return []string{"Countdown: " + err.Error()}, err
+	}
+
+	// Count calendar days so the number changes at midnight rather than at the target's time of day
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	ty, tm, td := target.Date()
+	targetDay := time.Date(ty, tm, td, 0, 0, 0, 0, time.Local)
+	days := int(targetDay.Sub(today).Hours()/24 + 0.5)
+
+	label := w.Label
+	if label == "" {
+		label = "until " + target.Format("Jan 2, 2006")
+	}
+	event := strings.TrimPrefix(label, "until ")
+
+	switch {
+	case days > 1:
+		return []string{fmt.Sprintf("%d days %s", days, label)}, nil
+	case days == 1:
+		return []string{fmt.Sprintf("1 day %s", label)}, nil
+	case w.DoneText != "":
+		return []string{w.DoneText}, nil
+	case days == 0:
+		return []string{"Today: " + event}, nil
+	case days == -1:
+		return []string{"1 day since " + event}, nil
+	default:
+		return []string{fmt.Sprintf("%d days since %s", -days, event)}, nil
+	}
+}
+
+// onCallLines fetches the rotation endpoint and fills the format placeholders.
+func onCallLines(ctx context.Context, w config.WidgetConfig) ([]string, error) {
+	format := w.Format
+	if format == "" {
+		format = "On call: {name}"
+	}
+
+	data, err := fetchJSON(ctx, w.URL, w.Headers)
+	if err != nil {
+		return []string{"On call: unavailable"}, err
+	}
+
+	var missing []string
+	line := placeholder.ReplaceAllStringFunc(format, func(match string) string {
+		path := match[1 : len(match)-1]
+		value, ok := lookup(data, path)
+		if !ok {
+			missing = append(missing, path)
+			return "?"
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return []string{line}, fmt.Errorf("on-call response has no field(s) %s", strings.Join(missing, ", "))
+	}
+	return []string{line}, nil
+}
+
+// fetchJSON downloads and decodes a JSON document.
+func fetchJSON(ctx context.Context, url string, headers map[string]string) (interface{}, error) {
+	if url == "" {
+		return nil, fmt.Errorf("on-call widget has no url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch on-call rotation: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("on-call endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	var data interface{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse on-call response: %v", err)
+	}
+	return data, nil
+}
+
+// lookup resolves a dotted path such as "primary.name" or "shifts.0.user" in decoded JSON.
+func lookup(data interface{}, path string) (string, bool) {
+	current := data
+	for _, part := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[part]
+			if !ok {
+				return "", false
+			}
+			current = value
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscanf(part, "%d", &index); err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			current = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case float64:
+		return fmt.Sprintf("%g", value), true
+	default:
+		encoded, _ := json.Marshal(value)
+		return string(encoded), true
+	}
+}
+
+// tableLines formats the rows with the values aligned (the overlay font is monospaced).
+func tableLines(w config.WidgetConfig) []string {
+	width := 0
+	for _, row := range w.Rows {
+		if n := utf8.RuneCountInString(row.Key); n > width {
+			width = n
+		}
+	}
+
+	lines := []string{}
+	for _, row := range w.Rows {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width+1, row.Key+":", row.Value))
+	}
+	return lines
+}
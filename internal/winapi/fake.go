@@ -0,0 +1,563 @@
+package winapi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Call is one command run through a FakeRunner.
+type Call struct {
+	Name string
+	Args []string
+}
+
+// String returns the command line, e.g. "schtasks /create /tn ...".
+func (c Call) String() string {
+	return strings.TrimSpace(c.Name + " " + strings.Join(c.Args, " "))
+}
+
+// FakeResult is the canned outcome of a faked command.
+type FakeResult struct {
+	Output []byte
+	Err    error
+}
+
+// FakeRunner records commands instead of running them. Results are looked up
+// by command name (e.g. "schtasks", "powershell.exe"); unknown commands succeed
+// with no output.
+type FakeRunner struct {
+	mu      sync.Mutex
+	Results map[string]FakeResult
+	Calls   []Call
+}
+
+func (f *FakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Calls = append(f.Calls, Call{Name: name, Args: append([]string(nil), args...)})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r := f.Results[name]
+	return r.Output, r.Err
+}
+
+// FakeWMI answers WMI queries from Results, keyed by the query text. Each
+// result must be a slice of the element type the caller decodes into.
+// Unknown queries return an empty result.
+type FakeWMI struct {
+	Results map[string]interface{}
+}
+
+func (f *FakeWMI) Query(query string, dst interface{}) error {
+	result, ok := f.Results[query]
+	if !ok {
+		return nil
+	}
+
+	out := reflect.ValueOf(dst)
+	in := reflect.ValueOf(result)
+	if out.Kind() != reflect.Ptr || out.Elem().Kind() != reflect.Slice || in.Type() != out.Elem().Type() {
+		return fmt.Errorf("fake WMI result for %q is %T, caller expects %T", query, result, dst)
+	}
+	out.Elem().Set(in)
+	return nil
+}
+
+// QueryNamespace answers from Results like Query; the namespace is ignored.
+func (f *FakeWMI) QueryNamespace(query string, dst interface{}, namespace string) error {
+	return f.Query(query, dst)
+}
+
+// SPICall is one SystemParametersInfo call made through a FakeSPI.
+type SPICall struct {
+	Action uint32
+	Value  string
+	Flags  uint32
+}
+
+// FakeSPI records SystemParametersInfo calls. Err, when set, is returned for
+// every call. GetString returns Values[action].
+type FakeSPI struct {
+	mu     sync.Mutex
+	Err    error
+	Calls  []SPICall
+	Values map[uint32]string
+}
+
+func (f *FakeSPI) SetString(action uint32, value string, flags uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Calls = append(f.Calls, SPICall{Action: action, Value: value, Flags: flags})
+	return f.Err
+}
+
+func (f *FakeSPI) GetString(action uint32) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return "", f.Err
+	}
+	return f.Values[action], nil
+}
+
+// RegistryValue is a value held by a FakeRegistry. Type is a registry value
+// type such as registry.SZ; the field matching it holds the data.
+type RegistryValue struct {
+	Type    uint32
+	String  string   // SZ and EXPAND_SZ
+	Strings []string // MULTI_SZ
+	Integer uint64   // DWORD and QWORD
+	Binary  []byte   // BINARY and other types
+}
+
+// bytes encodes the value as the registry stores it.
+func (v RegistryValue) bytes() []byte {
+	switch v.Type {
+	case registry.SZ, registry.EXPAND_SZ:
+		return utf16Bytes(v.String)
+	case registry.MULTI_SZ:
+		var data []byte
+		for _, s := range v.Strings {
+			data = append(data, utf16Bytes(s)...)
+		}
+		return append(data, 0, 0)
+	case registry.DWORD:
+		return binary.LittleEndian.AppendUint32(nil, uint32(v.Integer))
+	case registry.QWORD:
+		return binary.LittleEndian.AppendUint64(nil, v.Integer)
+	}
+	return v.Binary
+}
+
+// utf16Bytes encodes s as a NUL-terminated UTF-16LE string.
+func utf16Bytes(s string) []byte {
+	var data []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		data = binary.LittleEndian.AppendUint16(data, c)
+	}
+	return append(data, 0, 0)
+}
+
+// FakeRegistry is an in-memory registry. Paths and value names are
+// case-insensitive, as in the real registry, and the predefined roots always
+// exist. The zero value is an empty registry.
+type FakeRegistry struct {
+	mu   sync.Mutex
+	keys map[string]*fakeKeyData
+}
+
+// fakeKeyData is one key of a FakeRegistry.
+type fakeKeyData struct {
+	// name is the key's last path element as it was created.
+	name   string
+	values map[string]RegistryValue
+	// names maps lower-case value names to the names they were set with.
+	names map[string]string
+}
+
+// fakeKeyPath returns the lower-case full path of a key, e.g.
+// "hklm\software\x".
+func fakeKeyPath(root registry.Key, path string) string {
+	var name string
+	switch root {
+	case registry.LOCAL_MACHINE:
+		name = "hklm"
+	case registry.CURRENT_USER:
+		name = "hkcu"
+	case registry.USERS:
+		name = "hku"
+	case registry.CLASSES_ROOT:
+		name = "hkcr"
+	default:
+		name = fmt.Sprintf("0x%x", uint64(root))
+	}
+	if path = strings.Trim(path, `\`); path != "" {
+		name += `\` + path
+	}
+	return strings.ToLower(name)
+}
+
+// isRoot reports whether a full key path is a predefined root.
+func isRoot(full string) bool {
+	return !strings.Contains(full, `\`)
+}
+
+// lookup returns the key at a full path; roots always exist. It must be
+// called with f.mu held.
+func (f *FakeRegistry) lookup(full string) (*fakeKeyData, bool) {
+	if f.keys == nil {
+		f.keys = make(map[string]*fakeKeyData)
+	}
+	k, ok := f.keys[full]
+	if !ok && isRoot(full) {
+		k = &fakeKeyData{name: full, values: map[string]RegistryValue{}, names: map[string]string{}}
+		f.keys[full] = k
+		ok = true
+	}
+	return k, ok
+}
+
+// create returns the key at root\path, creating it and its parents as
+// needed. It must be called with f.mu held.
+func (f *FakeRegistry) create(root registry.Key, path string) (full string, existed bool) {
+	full = fakeKeyPath(root, path)
+	_, existed = f.lookup(full)
+	elements := strings.Split(strings.Trim(path, `\`), `\`)
+	for i := range elements {
+		p := fakeKeyPath(root, strings.Join(elements[:i+1], `\`))
+		if _, ok := f.lookup(p); !ok {
+			f.keys[p] = &fakeKeyData{name: elements[i], values: map[string]RegistryValue{}, names: map[string]string{}}
+		}
+	}
+	return full, existed
+}
+
+func (f *FakeRegistry) OpenKey(root registry.Key, path string, access uint32) (RegistryKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	full := fakeKeyPath(root, path)
+	if _, ok := f.lookup(full); !ok {
+		return nil, registry.ErrNotExist
+	}
+	return &fakeKey{reg: f, full: full}, nil
+}
+
+func (f *FakeRegistry) CreateKey(root registry.Key, path string, access uint32) (RegistryKey, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	full, existed := f.create(root, path)
+	return &fakeKey{reg: f, full: full}, existed, nil
+}
+
+// DeleteKey deletes a key without subkeys, as RegDeleteKey does.
+func (f *FakeRegistry) DeleteKey(root registry.Key, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	full := fakeKeyPath(root, path)
+	if _, ok := f.lookup(full); !ok || isRoot(full) {
+		return registry.ErrNotExist
+	}
+	if len(f.subKeys(full)) > 0 {
+		return fmt.Errorf("%s has subkeys", full)
+	}
+	delete(f.keys, full)
+	return nil
+}
+
+// subKeys returns the names of a key's direct subkeys in order. It must be
+// called with f.mu held.
+func (f *FakeRegistry) subKeys(full string) []string {
+	var names []string
+	for p, k := range f.keys {
+		if rest, ok := strings.CutPrefix(p, full+`\`); ok && !strings.Contains(rest, `\`) {
+			names = append(names, k.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set stores a value, creating its key as needed.
+func (f *FakeRegistry) Set(root registry.Key, path, name string, value RegistryValue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	full, _ := f.create(root, path)
+	k := f.keys[full]
+	k.values[strings.ToLower(name)] = value
+	k.names[strings.ToLower(name)] = name
+}
+
+// Value returns a stored value.
+func (f *FakeRegistry) Value(root registry.Key, path, name string) (RegistryValue, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	k, ok := f.lookup(fakeKeyPath(root, path))
+	if !ok {
+		return RegistryValue{}, false
+	}
+	v, ok := k.values[strings.ToLower(name)]
+	return v, ok
+}
+
+// KeyExists reports whether a key exists.
+func (f *FakeRegistry) KeyExists(root registry.Key, path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.lookup(fakeKeyPath(root, path))
+	return ok
+}
+
+// fakeKey is an open key of a FakeRegistry. Using it after the key was
+// deleted fails with registry.ErrNotExist.
+type fakeKey struct {
+	reg  *FakeRegistry
+	full string
+}
+
+// data runs fn on the key's data under the registry's lock.
+func (k *fakeKey) data(fn func(d *fakeKeyData) error) error {
+	k.reg.mu.Lock()
+	defer k.reg.mu.Unlock()
+
+	d, ok := k.reg.lookup(k.full)
+	if !ok {
+		return registry.ErrNotExist
+	}
+	return fn(d)
+}
+
+// get returns a value, or registry.ErrNotExist.
+func (k *fakeKey) get(name string) (RegistryValue, error) {
+	var v RegistryValue
+	err := k.data(func(d *fakeKeyData) error {
+		var ok bool
+		if v, ok = d.values[strings.ToLower(name)]; !ok {
+			return registry.ErrNotExist
+		}
+		return nil
+	})
+	return v, err
+}
+
+// set stores a value.
+func (k *fakeKey) set(name string, v RegistryValue) error {
+	return k.data(func(d *fakeKeyData) error {
+		d.values[strings.ToLower(name)] = v
+		d.names[strings.ToLower(name)] = name
+		return nil
+	})
+}
+
+func (k *fakeKey) GetValue(name string, buf []byte) (int, uint32, error) {
+	v, err := k.get(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	data := v.bytes()
+	if buf != nil && len(buf) < len(data) {
+		return len(data), v.Type, registry.ErrShortBuffer
+	}
+	copy(buf, data)
+	return len(data), v.Type, nil
+}
+
+func (k *fakeKey) GetStringValue(name string) (string, uint32, error) {
+	v, err := k.get(name)
+	if err != nil {
+		return "", 0, err
+	}
+	if v.Type != registry.SZ && v.Type != registry.EXPAND_SZ {
+		return "", v.Type, registry.ErrUnexpectedType
+	}
+	return v.String, v.Type, nil
+}
+
+func (k *fakeKey) GetStringsValue(name string) ([]string, uint32, error) {
+	v, err := k.get(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if v.Type != registry.MULTI_SZ {
+		return nil, v.Type, registry.ErrUnexpectedType
+	}
+	return append([]string(nil), v.Strings...), v.Type, nil
+}
+
+func (k *fakeKey) GetIntegerValue(name string) (uint64, uint32, error) {
+	v, err := k.get(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	if v.Type != registry.DWORD && v.Type != registry.QWORD {
+		return 0, v.Type, registry.ErrUnexpectedType
+	}
+	return v.Integer, v.Type, nil
+}
+
+func (k *fakeKey) GetBinaryValue(name string) ([]byte, uint32, error) {
+	v, err := k.get(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if v.Type != registry.BINARY {
+		return nil, v.Type, registry.ErrUnexpectedType
+	}
+	return append([]byte(nil), v.Binary...), v.Type, nil
+}
+
+func (k *fakeKey) SetStringValue(name, value string) error {
+	return k.set(name, RegistryValue{Type: registry.SZ, String: value})
+}
+
+func (k *fakeKey) SetExpandStringValue(name, value string) error {
+	return k.set(name, RegistryValue{Type: registry.EXPAND_SZ, String: value})
+}
+
+func (k *fakeKey) SetStringsValue(name string, value []string) error {
+	return k.set(name, RegistryValue{Type: registry.MULTI_SZ, Strings: append([]string(nil), value...)})
+}
+
+func (k *fakeKey) SetDWordValue(name string, value uint32) error {
+	return k.set(name, RegistryValue{Type: registry.DWORD, Integer: uint64(value)})
+}
+
+func (k *fakeKey) SetQWordValue(name string, value uint64) error {
+	return k.set(name, RegistryValue{Type: registry.QWORD, Integer: value})
+}
+
+func (k *fakeKey) SetBinaryValue(name string, value []byte) error {
+	return k.set(name, RegistryValue{Type: registry.BINARY, Binary: append([]byte(nil), value...)})
+}
+
+func (k *fakeKey) DeleteValue(name string) error {
+	return k.data(func(d *fakeKeyData) error {
+		if _, ok := d.values[strings.ToLower(name)]; !ok {
+			return registry.ErrNotExist
+		}
+		delete(d.values, strings.ToLower(name))
+		delete(d.names, strings.ToLower(name))
+		return nil
+	})
+}
+
+func (k *fakeKey) ReadValueNames(n int) ([]string, error) {
+	var names []string
+	err := k.data(func(d *fakeKeyData) error {
+		for _, name := range d.names {
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	return names, err
+}
+
+func (k *fakeKey) ReadSubKeyNames(n int) ([]string, error) {
+	var names []string
+	err := k.data(func(d *fakeKeyData) error {
+		names = k.reg.subKeys(k.full)
+		return nil
+	})
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	return names, err
+}
+
+func (k *fakeKey) Stat() (*registry.KeyInfo, error) {
+	info := &registry.KeyInfo{}
+	err := k.data(func(d *fakeKeyData) error {
+		info.ValueCount = uint32(len(d.values))
+		info.SubKeyCount = uint32(len(k.reg.subKeys(k.full)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (k *fakeKey) Close() error {
+	return nil
+}
+
+// FakeTasks keeps scheduled tasks in memory: Tasks maps task names to their
+// XML, and Runs lists the tasks started, in order. Err, when set, is returned
+// by Create.
+type FakeTasks struct {
+	mu    sync.Mutex
+	Err   error
+	Tasks map[string]string
+	Runs  []string
+}
+
+func (f *FakeTasks) Create(ctx context.Context, name, xml string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return f.Err
+	}
+	if f.Tasks == nil {
+		f.Tasks = make(map[string]string)
+	}
+	f.Tasks[name] = xml
+	return nil
+}
+
+func (f *FakeTasks) Run(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.Tasks[name]; !ok {
+		return fmt.Errorf("task %s does not exist", name)
+	}
+	f.Runs = append(f.Runs, name)
+	return nil
+}
+
+func (f *FakeTasks) End(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.Tasks[name]; !ok {
+		return fmt.Errorf("task %s does not exist", name)
+	}
+	return nil
+}
+
+func (f *FakeTasks) Delete(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.Tasks[name]; !ok {
+		return fmt.Errorf("task %s does not exist", name)
+	}
+	delete(f.Tasks, name)
+	return nil
+}
+
+func (f *FakeTasks) Query(ctx context.Context, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.Tasks[name]; !ok {
+		return "", fmt.Errorf("task %s does not exist", name)
+	}
+	return fmt.Sprintf(`"\%s","N/A","Ready"`, name), nil
+}
+
+// FakeToken is a process token with fixed answers.
+type FakeToken struct {
+	Admin  bool
+	System bool
+}
+
+func (f *FakeToken) Elevated() bool {
+	return f.Admin
+}
+
+func (f *FakeToken) LocalSystem() bool {
+	return f.System
+}
@@ -0,0 +1,254 @@
+// Package winapi puts the Windows facilities the tools drive from the outside
+// (external commands such as PowerShell and taskkill, WMI queries,
+// SystemParametersInfo, the registry, the Task Scheduler and the process
+// token) behind small interfaces. The package variables hold the real
+// implementations; a harness can swap in the fakes from fake.go (winapitest.Use
+// installs all of them) to run the install, render, apply and uninstall flows
+// without touching the machine.
+package winapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/yusufpapurcu/wmi"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Runner runs an external command and returns its combined output.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// Querier runs a WMI query, decoding the results into dst (a pointer to a slice
+// of structs, as for wmi.Query).
+type Querier interface {
+	Query(query string, dst interface{}) error
+	// QueryNamespace is Query against a namespace other than root\cimv2.
+	QueryNamespace(query string, dst interface{}, namespace string) error
+}
+
+// SystemParameters calls SystemParametersInfo with a string parameter, which is
+// how the desktop and lock screen wallpapers are set and read back.
+type SystemParameters interface {
+	SetString(action uint32, value string, flags uint32) error
+	GetString(action uint32) (string, error)
+}
+
+// Registry opens, creates and deletes registry keys, as the functions of the
+// registry package do. Errors are the registry package's, e.g.
+// registry.ErrNotExist for a missing key.
+type Registry interface {
+	OpenKey(root registry.Key, path string, access uint32) (RegistryKey, error)
+	CreateKey(root registry.Key, path string, access uint32) (key RegistryKey, openedExisting bool, err error)
+	DeleteKey(root registry.Key, path string) error
+}
+
+// RegistryKey is an open registry key: the methods of registry.Key the tools
+// use.
+type RegistryKey interface {
+	GetValue(name string, buf []byte) (n int, valtype uint32, err error)
+	GetStringValue(name string) (string, uint32, error)
+	GetStringsValue(name string) ([]string, uint32, error)
+	GetIntegerValue(name string) (uint64, uint32, error)
+	GetBinaryValue(name string) ([]byte, uint32, error)
+	SetStringValue(name, value string) error
+	SetExpandStringValue(name, value string) error
+	SetStringsValue(name string, value []string) error
+	SetDWordValue(name string, value uint32) error
+	SetQWordValue(name string, value uint64) error
+	SetBinaryValue(name string, value []byte) error
+	DeleteValue(name string) error
+	ReadValueNames(n int) ([]string, error)
+	ReadSubKeyNames(n int) ([]string, error)
+	Stat() (*registry.KeyInfo, error)
+	Close() error
+}
+
+// TaskScheduler creates, starts, stops and removes scheduled tasks, as
+// schtasks does.
+type TaskScheduler interface {
+	// Create registers the task from its XML definition, replacing any task
+	// of the same name.
+	Create(ctx context.Context, name, xml string) error
+	Run(ctx context.Context, name string) error
+	End(ctx context.Context, name string) error
+	Delete(ctx context.Context, name string) error
+	// Query returns the task's "name","next run time","status" CSV line, or
+	// an error when there is no such task.
+	Query(ctx context.Context, name string) (string, error)
+}
+
+// Process reports what the current process runs as.
+type Process interface {
+	// Elevated reports whether the process has administrator privileges.
+	Elevated() bool
+	// LocalSystem reports whether the process runs as SYSTEM.
+	LocalSystem() bool
+}
+
+var (
+	// Commands runs external commands.
+	Commands Runner = execRunner{}
+	// WMI runs WMI queries.
+	WMI Querier = wmiQuerier{}
+	// SPI calls SystemParametersInfo.
+	SPI SystemParameters = user32SPI{}
+	// Reg accesses the registry.
+	Reg Registry = winRegistry{}
+	// Tasks manages scheduled tasks.
+	Tasks TaskScheduler = schtasks{}
+	// Token describes the current process.
+	Token Process = processToken{}
+)
+
+// execRunner runs commands with os/exec.
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// wmiQuerier queries the local WMI service.
+type wmiQuerier struct{}
+
+func (wmiQuerier) Query(query string, dst interface{}) error {
+	return wmi.Query(query, dst)
+}
+
+func (wmiQuerier) QueryNamespace(query string, dst interface{}, namespace string) error {
+	return wmi.QueryNamespace(query, dst, namespace)
+}
+
+// user32SPI calls SystemParametersInfoW in user32.dll.
+type user32SPI struct{}
+
+var procSystemParametersInfo = syscall.NewLazyDLL("user32.dll").NewProc("SystemParametersInfoW")
+
+func (user32SPI) SetString(action uint32, value string, flags uint32) error {
+	ptr, err := syscall.UTF16PtrFromString(value)
+	if err != nil {
+		return err
+	}
+
+	ret, _, err := procSystemParametersInfo.Call(
+		uintptr(action),
+		0,
+		uintptr(unsafe.Pointer(ptr)),
+		uintptr(flags),
+	)
+	if ret == 0 && err != nil && err != syscall.Errno(0) {
+		return err
+	}
+	return nil
+}
+
+func (user32SPI) GetString(action uint32) (string, error) {
+	// Wallpaper paths are limited to MAX_PATH
+	buf := make([]uint16, 260)
+	ret, _, err := procSystemParametersInfo.Call(
+		uintptr(action),
+		uintptr(len(buf)),
+		uintptr(unsafe.Pointer(&buf[0])),
+		0,
+	)
+	if ret == 0 {
+		return "", err
+	}
+	return syscall.UTF16ToString(buf), nil
+}
+
+// winRegistry uses the local registry.
+type winRegistry struct{}
+
+func (winRegistry) OpenKey(root registry.Key, path string, access uint32) (RegistryKey, error) {
+	key, err := registry.OpenKey(root, path, access)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (winRegistry) CreateKey(root registry.Key, path string, access uint32) (RegistryKey, bool, error) {
+	key, existing, err := registry.CreateKey(root, path, access)
+	if err != nil {
+		return nil, false, err
+	}
+	return key, existing, nil
+}
+
+func (winRegistry) DeleteKey(root registry.Key, path string) error {
+	return registry.DeleteKey(root, path)
+}
+
+// schtasks manages tasks with schtasks.exe, run through Commands.
+type schtasks struct{}
+
+func (schtasks) Create(ctx context.Context, name, xml string) error {
+	f, err := os.CreateTemp("", "bgstatus_task_*.xml")
+	if err != nil {
+		return fmt.Errorf("failed to write task XML: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(xml)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write task XML: %w", err)
+	}
+
+	_, err = runSchtasks(ctx, "/create", "/tn", name, "/xml", f.Name(), "/f")
+	return err
+}
+
+func (schtasks) Run(ctx context.Context, name string) error {
+	_, err := runSchtasks(ctx, "/run", "/tn", name)
+	return err
+}
+
+func (schtasks) End(ctx context.Context, name string) error {
+	_, err := runSchtasks(ctx, "/end", "/tn", name)
+	return err
+}
+
+func (schtasks) Delete(ctx context.Context, name string) error {
+	_, err := runSchtasks(ctx, "/delete", "/tn", name, "/f")
+	return err
+}
+
+func (schtasks) Query(ctx context.Context, name string) (string, error) {
+	return runSchtasks(ctx, "/query", "/tn", name, "/fo", "csv", "/nh")
+}
+
+// processToken reads the token of the current process.
+type processToken struct{}
+
+func (processToken) Elevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+func (processToken) LocalSystem() bool {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	return err == nil && user.User.Sid.IsWellKnown(windows.WinLocalSystemSid)
+}
+
+// runSchtasks runs schtasks and returns its trimmed output, which is added to
+// the error when it fails.
+func runSchtasks(ctx context.Context, args ...string) (string, error) {
+	output, err := Commands.Run(ctx, "schtasks", args...)
+	out := strings.TrimSpace(string(output))
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return out, fmt.Errorf("schtasks timed out")
+		}
+		return out, fmt.Errorf("%w - %s", err, out)
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+// Package winapitest sets up the winapi fakes for tests, so every package
+// runs its flows against the same fake machine.
+package winapitest
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/backgroundchanger/internal/winapi"
+)
+
+// The fake machine is Windows 11 Enterprise.
+const (
+	ProductName = "Windows 10 Enterprise"
+	EditionID   = "Enterprise"
+	Build       = "22631"
+)
+
+// Fakes are the fakes installed by Use.
+type Fakes struct {
+	Reg      *winapi.FakeRegistry
+	SPI      *winapi.FakeSPI
+	Tasks    *winapi.FakeTasks
+	Commands *winapi.FakeRunner
+	WMI      *winapi.FakeWMI
+	Token    *winapi.FakeToken
+}
+
+// Use replaces every winapi facility with a fake for the rest of the test.
+// The registry holds the Enterprise build above and the token is an elevated
+// administrator, so capability.Detect selects the methods an administrator on
+// Enterprise gets. ProgramData, ProgramFiles, LOCALAPPDATA and SystemRoot point
+// at temporary directories.
+func Use(t testing.TB) *Fakes {
+	t.Helper()
+
+	t.Setenv("ProgramData", t.TempDir())
+	t.Setenv("ProgramFiles", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	t.Setenv("SystemRoot", t.TempDir())
+
+	f := &Fakes{
+		Reg:      &winapi.FakeRegistry{},
+		SPI:      &winapi.FakeSPI{},
+		Tasks:    &winapi.FakeTasks{},
+		Commands: &winapi.FakeRunner{},
+		WMI:      &winapi.FakeWMI{},
+		Token:    &winapi.FakeToken{Admin: true},
+	}
+	version := `SOFTWARE\Microsoft\Windows NT\CurrentVersion`
+	f.Reg.Set(registry.LOCAL_MACHINE, version, "ProductName", winapi.RegistryValue{Type: registry.SZ, String: ProductName})
+	f.Reg.Set(registry.LOCAL_MACHINE, version, "EditionID", winapi.RegistryValue{Type: registry.SZ, String: EditionID})
+	f.Reg.Set(registry.LOCAL_MACHINE, version, "CurrentBuildNumber", winapi.RegistryValue{Type: registry.SZ, String: Build})
+	f.Reg.Set(registry.LOCAL_MACHINE, version, "InstallationType", winapi.RegistryValue{Type: registry.SZ, String: "Client"})
+
+	oldReg, oldSPI, oldTasks, oldCommands, oldWMI, oldToken := winapi.Reg, winapi.SPI, winapi.Tasks, winapi.Commands, winapi.WMI, winapi.Token
+	winapi.Reg, winapi.SPI, winapi.Tasks, winapi.Commands, winapi.WMI, winapi.Token = f.Reg, f.SPI, f.Tasks, f.Commands, f.WMI, f.Token
+	t.Cleanup(func() {
+		winapi.Reg, winapi.SPI, winapi.Tasks, winapi.Commands, winapi.WMI, winapi.Token = oldReg, oldSPI, oldTasks, oldCommands, oldWMI, oldToken
+	})
+	return f
+}